@@ -26,6 +26,9 @@ var helpText = func(colors logger.Colors) string {
 	return `
 ` + colors.Bold + `Usage:` + colors.Reset + `
   esbuild [options] [entry points]
+  esbuild analyze [--verbose] [--filter=pkg] metafile.json
+  esbuild replay [--substitute:recorded=file] [--outdir=dir] record-file
+  esbuild dev [options] [entry points]
 
 ` + colors.Bold + `Documentation:` + colors.Reset + `
   ` + colors.Underline + `https://esbuild.github.io/` + colors.Reset + `
@@ -37,79 +40,267 @@ var helpText = func(colors logger.Colors) string {
   --bundle              Bundle all dependencies into the output files
   --define:K=V          Substitute K with V while parsing
   --external:M          Exclude module M from the bundle (can use * wildcards)
-  --format=...          Output format (iife | cjs | esm, no default when not
-                        bundling, otherwise default is iife when platform
-                        is browser and cjs when platform is node)
+  --external:M=G        Exclude module M and read it from the global variable
+                        G instead (e.g. "react=React", not for esm format)
+  --format=...          Output format (iife | cjs | esm | umd, no default when
+                        not bundling, otherwise default is iife when
+                        platform is browser and cjs when platform is node,
+                        use commas to build several formats at once)
   --loader:X=L          Use loader L to load file extension X, where L is
-                        one of: js | jsx | ts | tsx | css | json | text |
-                        base64 | file | dataurl | binary
+                        one of: js | jsx | ts | tsx | css | local-css |
+                        json | jsonc | json5 | yaml | toml | text |
+                        base64 | file | copy | dataurl | binary |
+                        webmanifest | image
   --minify              Minify the output (sets all --minify-* flags)
-  --outdir=...          The output directory (for multiple entry points)
-  --outfile=...         The output file (for one entry point)
+  --outdir=...          The output directory (for multiple entry points,
+                        use "-" to write a tar archive to stdout)
+  --outfile=...         The output file (for one entry point, use "-" to
+                        write to stdout)
+  --packages=external   Exclude all package imports (those that aren't
+                        relative or absolute paths) from the bundle
   --platform=...        Platform target (browser | node | neutral,
                         default browser)
   --serve=...           Start a local HTTP server on this host:port for outputs
   --sourcemap           Emit a source map
-  --splitting           Enable code splitting (currently only for esm)
+  --splitting           Enable code splitting (not available for umd)
   --target=...          Environment target (e.g. es2017, chrome58, firefox57,
                         safari11, edge16, node10, default esnext)
   --watch               Watch mode: rebuild on file system changes
 
 ` + colors.Bold + `Advanced options:` + colors.Reset + `
-  --allow-overwrite         Allow output files to overwrite input files
+  --access-list             List every file and directory that the build read
+                            or checked for in the metafile
+  --alias:P=Q               Replace imports of package P with imports of Q,
+                            which can be a package or a path to a file
+  --allow-overwrite         Allow output files to overwrite input files (same
+                            as "--on-conflict=overwrite")
   --analyze                 Print a report about the contents of the bundle
                             (use "--analyze=verbose" for a detailed report)
+  --annotations=...         Whether to generate /* @__PURE__ */ annotations
+                            for other tools (default | always | never, default
+                            omits them when minifying whitespace). Use
+                            "--annotations:esm=never" to set this per format
   --asset-names=...         Path template to use for "file" loader files
                             (default "[name]-[hash]")
   --banner:T=...            Text to be prepended to each output file of type T
                             where T is one of: css | js
+  --budget:K=N              Warn about JS output files that exceed N for K
+                            where K is one of: bytes | functions | statements
+  --bundle-dynamic-paths    Bundle all files that a require() or import() path
+                            could refer to if it starts with "./" or "../"
   --charset=utf8            Do not escape UTF-8 code points
+  --charset-escape=...      Always escape these code points (non-bmp or a
+                            Unicode script name such as Han, comma-separated)
+  --charset-identifiers=... Escape identifiers differently than strings
+                            (ascii | utf8)
+  --charset-normalization=... Report identifiers that aren't in this Unicode
+                            normalization form (nfc | nfd)
+  --chunk:N=P               Put files matching the path P (which can use a *
+                            wildcard) in a chunk named N (enables code
+                            splitting)
+  --chunk-max-size=...      Split chunks larger than this many bytes of input
+                            into several chunks
+  --chunk-min-size=...      Merge shared chunks smaller than this many bytes of
+                            input into other chunks when it's safe
   --chunk-names=...         Path template to use for code splitting chunks
                             (default "[name]-[hash]")
+  --cjs-wrapper             Write a .cjs file next to each ESM entry point that
+                            loads it with require() and lists its exports
   --color=...               Force use of color terminal escapes (true | false)
+  --completions=...         Print a shell completion script (bash | zsh | fish |
+                            powershell)
+  --config=...              Load options from this JSON file (default
+                            "esbuild.config.json" if it exists)
+  --content-manifest=...    Name all output files by their content hash and
+                            write a manifest of their original names to this
+                            path in the output directory
+  --csp-report=...          Write the hashes needed to allow all output scripts
+                            with a Content Security Policy to this path in the
+                            output directory
+  --css-layer:P=L           Put CSS files from package P in the cascade layer L
+  --css-order-report=...    Write where each CSS file ended up in each output
+                            file and why to this path in the output directory
+  --deno-dir=...            Look up "npm:" imports with exact versions in this
+                            Deno cache directory (default $DENO_DIR)
+  --detect-workspaces       Resolve packages in the enclosing npm, Yarn, or
+                            pnpm workspace to their source directories
+  --dev                     Same as "esbuild dev": serve with live reload,
+                            rebuild on changes, inline source maps, set
+                            process.env.NODE_ENV to "development", and don't
+                            minify (uses the "dev" section of the config file)
+  --directory-imports=...   How to resolve import paths that are directories
+                            (cjs | no-index | node-esm, default cjs)
+  --drop:...                Remove certain constructs (console | debugger)
+  --dual-package            Generate both a CommonJS .cjs file and an ESM .mjs
+                            file for each entry point
+  --dynamic-import-loader=... How to load external import() expressions when
+                            import() isn't supported (require | script,
+                            default require)
   --entry-names=...         Path template to use for entry point output paths
+  --external-helpers=...    Import esbuild's helper functions from this module
+                            instead of including them in every output file
+  --external-rewrite:M=P    Keep module M external but import path P instead
+                            (can use * wildcards)
                             (default "[dir]/[name]", can also use "[hash]")
+  --feature:F=...           Set the feature flag F to true or false and remove
+                            the code that only runs with the other value
+  --feature-report=...      Write where each feature flag is used and how much
+                            code it kept or removed to this JSON file
   --footer:T=...            Text to be appended to each output file of type T
                             where T is one of: css | js
-  --global-name=...         The name of the global for the IIFE format
+  --flags-json              Print every flag, its value type, and the commands
+                            it applies to as JSON and exit
+  --global-name=...         The name of the global for the IIFE and UMD formats
   --ignore-annotations      Enable this to work with packages that have
                             incorrect tree-shaking annotations
+  --indent=...              Indentation for code that isn't minified (a number
+                            of spaces or "tab", default 2)
+  --index-extensions=...    A comma-separated list of extensions to try for
+                            "index" files of directories (default is the
+                            same as --resolve-extensions)
+  --infer-pure              Treat calls to local functions without side effects
+                            as if they were annotated with /* @__PURE__ */
+  --infer-target            Use the oldest node version allowed by "engines"
+                            in package.json as the target (platform=node)
   --inject:F                Import the file F into all input files and
                             automatically replace matching globals with imports
+  --inject-css-link         Make JS entry points that import CSS add a <link>
+                            tag for the generated CSS file when they run
+  --isolate-chunk:P         Always put package P (or files matching the path P,
+                            which can use a * wildcard) in its own chunk
+                            (enables code splitting)
+  --isolate-package:P       Keep the module wrappers for files in package P so
+                            they are evaluated when first imported
   --jsx-factory=...         What to use for JSX instead of React.createElement
   --jsx-fragment=...        What to use for JSX instead of React.Fragment
+  --jsx-import-source=...   Where to import the automatic JSX runtime from
+                            (default "react")
   --jsx=...                 Set to "preserve" to disable transforming JSX to JS
+                            or "automatic" to use the automatic JSX runtime
   --keep-names              Preserve "name" on functions and classes
+  --lazy-package:P          Only evaluate files in package P when one of their
+                            exports is first used
   --legal-comments=...      Where to place legal comments (none | inline |
                             eof | linked | external, default eof when bundling
                             and inline otherwise)
+  --line-ending=...         Line endings for all output files (lf | crlf,
+                            default lf)
+  --log-file=...            Also write all errors and warnings to this file
+  --log-file-format=...     Format of the log file (text | json, default text)
   --log-level=...           Disable logging (verbose | debug | info | warning |
                             error | silent, default info)
   --log-limit=...           Maximum message count or 0 to disable (default 6)
+  --magic-comments=...      Whether to keep magic comments such as
+                            webpackChunkName in import() expressions (default |
+                            always | never, default omits them when minifying
+                            whitespace)
   --main-fields=...         Override the main file order in package.json
                             (default "browser,module,main" when platform is
                             browser and "main,module" when platform is node)
+  --mangle-cache=...        Read and update this JSON file to keep mangled
+                            property names the same across builds
+  --mangle-props=/.../      Rename all properties matching this regular
+                            expression (see also "--reserve-props")
   --metafile=...            Write metadata about the build to a JSON file
   --minify-whitespace       Remove whitespace in output files
   --minify-identifiers      Shorten identifiers in output files
   --minify-syntax           Use equivalent but shorter syntax in output files
+  --minify-seed=...         Use a different order for minified identifiers
+  --module-map              Write the output range of each input file to a
+                            JSON file next to each JavaScript output file
+  --name-cache=...          Read and update this JSON file to keep minified
+                            top-level names the same across builds
+  --node-polyfills          Use browser versions of node's "buffer", "events",
+                            "path", and "process" modules and globals (only
+                            when platform is browser)
+  --on-conflict=...         What to do when an output file would overwrite an
+                            input file or another output file (error | rename
+                            | overwrite, default error)
   --out-extension:.js=.mjs  Use a custom output extension instead of ".js"
+  --out-extension:esm=.mjs  Use a custom output extension for one of several
+                            formats (defaults: .iife.js, .cjs, .mjs, .umd.js)
   --outbase=...             The base path used to determine entry point output
                             paths (for multiple entry points)
+  --pragma:N                Preserve comments with the pragma "@N" and list
+                            them in the metafile
+  --precache-manifest=...   Write a Workbox precache manifest of all output
+                            files to this path in the output directory
+  --preserve-comments=/.../ Keep statement-level comments matching this regular
+                            expression in place, even when minifying
+  --preserve-modules        Compile every file imported by the entry points
+                            into "outdir" instead of bundling them, keeping
+                            the directory structure and import statements
   --preserve-symlinks       Disable symlink resolution for module lookup
   --public-path=...         Set the base URL for the "file" loader
   --pure:N                  Mark the name N as a pure function for tree shaking
+  --record=...              Save the options and input files of this build to
+                            this file so it can be run again later with
+                            "esbuild replay"
+  --recover-syntax-errors   Keep parsing after a syntax error in a top-level
+                            statement to report more errors per file
+  --reserve-props=/.../     Don't rename properties matching this regular
+                            expression when using "--mangle-props"
   --resolve-extensions=...  A comma-separated list of implicit extensions
                             (default ".tsx,.ts,.jsx,.js,.css,.json")
+  --rewrite-imports         Add output file extensions to relative import
+                            paths when not bundling (e.g. "./a" => "./a.js")
+  --serve-api=...           Run an HTTP service on this host:port that exposes
+                            the build and transform APIs (see the changelog)
+  --serve-api-token=...     Require this bearer token for "--serve-api"
+  --serve-fallback-proxy=.. Forward requests that don't match an output file or
+                            a file in "servedir" to this origin
+  --serve-throttle:P=...    Slow down responses for URL paths matching P (can
+                            use * wildcards) by a latency and/or a bandwidth
+                            such as "300ms,50kb/s"
+  --service-worker=...      Write a service worker that precaches all output
+                            files to this path in the output directory
   --servedir=...            What to serve in addition to generated output files
+  --shared-chunk-dir=...    Write chunks that aren't entry points to this
+                            directory so builds can share identical chunks
+                            (only with --splitting)
+  --skip-unchanged          Don't rewrite output files whose contents are the
+                            same as the existing file on disk
   --source-root=...         Sets the "sourceRoot" field in generated source maps
   --sourcefile=...          Set the source file for the source map (for stdin)
   --sourcemap=external      Do not link to the source map with a comment
   --sourcemap=inline        Emit the source map with an inline data URL
+  --sourcemap-banners       Map the banner and footer to the virtual sources
+                            "<banner>" and "<footer>" in the source map
   --sources-content=false   Omit "sourcesContent" in generated source maps
+  --splitting-preset=vendor Enable code splitting and put the runtime and code
+                            from node_modules in separate "runtime" and
+                            "vendor" chunks (none | vendor, default none)
+  --strict-case             Fail the build when an import path has different
+                            casing than the file on disk
+  --supported:F=...         Override whether feature F is supported by the
+                            target environment (true | false)
+  --supported-file=...      Read feature overrides from this JSON file, which
+                            maps feature names to true or false
+  --strip-between:S=E       Remove the code between the comments "/* S */" and
+                            "/* E */" (e.g. "test:start=test:end")
+  --strip-if:N              Remove "if" statements whose condition is N (e.g.
+                            "import.meta.vitest"), keeping any "else" branch
+  --tree-shake-members      Remove unused methods of classes and properties of
+                            objects that never escape their file
   --tree-shaking=...        Force tree shaking on or off (false | true)
+  --ts-enums=...            How to emit TypeScript enums (classic | frozen |
+                            inline, default classic)
+  --ts-version=...          Allow TypeScript syntax up to this version (default
+                            is 4.5, the newest syntax allowed is from 5.0)
   --tsconfig=...            Use this tsconfig.json file instead of other ones
+  --type-check=...          Run this command (e.g. "tsc --noEmit") in parallel
+                            with the build and report its diagnostics as
+                            build errors and warnings (in watch and serve
+                            mode, it's started once and should keep running,
+                            e.g. "tsc --noEmit --watch")
+  --unused-exports=...      Report exports of non-entry files that are never
+                            imported (ignore | warning | error, default ignore)
   --version                 Print the current version (` + esbuildVersion + `) and exit
+  --watch=once              Exit after the first rebuild instead of watching
+                            forever (forever | once, default forever)
+  --watch-max-failures=N    Exit watch mode after N builds in a row fail
+  --workspace:P=DIR         Resolve imports of package P to directory DIR
+                            instead of searching "node_modules"
 
 ` + colors.Bold + `Examples:` + colors.Reset + `
   ` + colors.Dim + `# Produces dist/entry_point.js and dist/entry_point.js.map` + colors.Reset + `
@@ -130,6 +321,12 @@ var helpText = func(colors logger.Colors) string {
   ` + colors.Dim + `# Start a local HTTP server for everything in "www"` + colors.Reset + `
   esbuild app.ts --bundle --servedir=www --outdir=www/js
 
+  ` + colors.Dim + `# Also rebuild on changes and reload the pages in "www"` + colors.Reset + `
+  esbuild app.ts --bundle --servedir=www --outdir=www/js --watch
+
+  ` + colors.Dim + `# Do the same with development-friendly defaults` + colors.Reset + `
+  esbuild dev app.ts --bundle --servedir=www --outdir=www/js
+
 `
 }
 
@@ -142,6 +339,8 @@ func main() {
 	cpuprofileFile := ""
 	isRunningService := false
 	sendPings := false
+	serveAPIAddress := ""
+	serveAPIToken := ""
 
 	// Do an initial scan over the argument list
 	argsEnd := 0
@@ -190,6 +389,14 @@ func main() {
 		case strings.HasPrefix(arg, "--ping"):
 			sendPings = true
 
+		// This flag turns the process into a long-running HTTP service that
+		// exposes the build and transform APIs
+		case strings.HasPrefix(arg, "--serve-api="):
+			serveAPIAddress = arg[len("--serve-api="):]
+
+		case strings.HasPrefix(arg, "--serve-api-token="):
+			serveAPIToken = arg[len("--serve-api-token="):]
+
 		default:
 			// Strip any arguments that were handled above
 			osArgs[argsEnd] = arg
@@ -203,6 +410,14 @@ func main() {
 		runService(sendPings)
 		return
 	}
+	if serveAPIAddress != "" {
+		if len(osArgs) > 0 {
+			logger.PrintErrorToStderr(osArgs, fmt.Sprintf(
+				"Unexpected argument %q when using \"--serve-api\" (pass build flags in each request instead)", osArgs[0]))
+			os.Exit(1)
+		}
+		os.Exit(runServeAPI(osArgs, serveAPIAddress, serveAPIToken))
+	}
 
 	// Print help text when there are no arguments
 	isStdinTTY := logger.GetTerminalInfo(os.Stdin).IsTTY
@@ -260,7 +475,7 @@ func main() {
 			// Don't disable the GC if this is a long-running process
 			isServeOrWatch := false
 			for _, arg := range osArgs {
-				if arg == "--serve" || arg == "--watch" || strings.HasPrefix(arg, "--serve=") {
+				if arg == "--serve" || arg == "--watch" || strings.HasPrefix(arg, "--serve=") || strings.HasPrefix(arg, "--watch=") {
 					isServeOrWatch = true
 					break
 				}
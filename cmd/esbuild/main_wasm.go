@@ -23,3 +23,8 @@ func createCpuprofileFile(osArgs []string, cpuprofileFile string) func() {
 	logger.PrintErrorToStderr(osArgs, "The \"--cpuprofile\" flag is not supported when using WebAssembly")
 	return nil
 }
+
+func runServeAPI(osArgs []string, address string, token string) int {
+	logger.PrintErrorToStderr(osArgs, "The \"--serve-api\" flag is not supported when using WebAssembly")
+	return 1
+}
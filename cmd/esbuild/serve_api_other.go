@@ -0,0 +1,364 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+// This implements a long-running HTTP service that exposes the build and
+// transform APIs. Each request has a JSON body containing an array of
+// command-line flags in the same format as the stdin/stdout service. This
+// means the service understands all options that the CLI understands.
+//
+// Requests must be made using "POST" to "/build" or "/transform". If a token
+// was provided when the service was started, every request must include it
+// in an "Authorization: Bearer <token>" header.
+//
+// Each build request runs in its own working directory, which must be inside
+// the directory that the service was started in. Entry points and every
+// option that names a file or directory must be inside the working directory
+// of the request after following symlinks. Note that this does not prevent
+// the build from reading files elsewhere through import paths, so this should
+// still be combined with operating-system level sandboxing if the input code
+// is not trusted.
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/evanw/esbuild/pkg/cli"
+)
+
+type serveAPIBuildRequest struct {
+	Flags       []string `json:"flags"`
+	EntryPoints []string `json:"entryPoints"`
+	Cwd         string   `json:"cwd"`
+	Write       bool     `json:"write"`
+	Stdin       *string  `json:"stdin"`
+}
+
+type serveAPITransformRequest struct {
+	Flags []string `json:"flags"`
+	Input string   `json:"input"`
+}
+
+type serveAPI struct {
+	absRootDir string
+	token      string
+}
+
+func runServeAPI(osArgs []string, address string, token string) int {
+	if token == "" {
+		token = os.Getenv("ESBUILD_SERVE_API_TOKEN")
+	}
+
+	absRootDir, err := os.Getwd()
+	if err == nil {
+		absRootDir, err = filepath.EvalSymlinks(absRootDir)
+	}
+	if err != nil {
+		logger.PrintErrorToStderr(osArgs, fmt.Sprintf("Failed to get the current directory: %s", err.Error()))
+		return 1
+	}
+
+	// Refuse to expose the service without authentication on other machines
+	if token == "" {
+		if host, _, err := net.SplitHostPort(address); err != nil || (host != "localhost" && !net.ParseIP(host).IsLoopback()) {
+			logger.PrintErrorToStderr(osArgs, "Cannot serve the API on a non-loopback address without a token "+
+				"(use \"--serve-api-token=...\" or the \"ESBUILD_SERVE_API_TOKEN\" environment variable)")
+			return 1
+		}
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.PrintErrorToStderr(osArgs, fmt.Sprintf("Failed to listen on %q: %s", address, err.Error()))
+		return 1
+	}
+
+	service := &serveAPI{absRootDir: absRootDir, token: token}
+	logger.PrintText(os.Stderr, logger.LevelInfo, osArgs, func(colors logger.Colors) string {
+		return fmt.Sprintf("\n%s > API:%s %shttp://%s/%s\n\n",
+			colors.Dim, colors.Reset, colors.Bold, listener.Addr().String(), colors.Reset)
+	})
+
+	if err := http.Serve(listener, service); err != nil {
+		logger.PrintErrorToStderr(osArgs, err.Error())
+		return 1
+	}
+	return 0
+}
+
+func (service *serveAPI) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	if service.token != "" {
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(service.token)) != 1 {
+			respondWithJSON(res, http.StatusUnauthorized, map[string]interface{}{"error": "Invalid token"})
+			return
+		}
+	}
+
+	if req.URL.Path != "/build" && req.URL.Path != "/transform" {
+		respondWithJSON(res, http.StatusNotFound, map[string]interface{}{"error": "Unknown endpoint: " + req.URL.Path})
+		return
+	}
+	if req.Method != "POST" {
+		res.Header().Set("Allow", "POST")
+		respondWithJSON(res, http.StatusMethodNotAllowed, map[string]interface{}{"error": "Expected a POST request"})
+		return
+	}
+
+	var response map[string]interface{}
+	var err error
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if req.URL.Path == "/build" {
+		request := serveAPIBuildRequest{}
+		if err = decoder.Decode(&request); err == nil {
+			response, err = service.handleBuild(request)
+		}
+	} else {
+		request := serveAPITransformRequest{}
+		if err = decoder.Decode(&request); err == nil {
+			response, err = service.handleTransform(request)
+		}
+	}
+
+	if err != nil {
+		respondWithJSON(res, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (service *serveAPI) handleBuild(request serveAPIBuildRequest) (map[string]interface{}, error) {
+	options, err := cli.ParseBuildOptions(request.Flags)
+	if err != nil {
+		return nil, err
+	}
+	if options.Watch != nil {
+		return nil, fmt.Errorf("Cannot use \"watch\" with the API service")
+	}
+	if options.TypeCheck != "" {
+		return nil, fmt.Errorf("Cannot use \"type-check\" with the API service")
+	}
+
+	// Scope the working directory to the directory of the service
+	absWorkingDir, ok := scopedPath(service.absRootDir, request.Cwd)
+	if !ok {
+		return nil, fmt.Errorf("The working directory %q is outside of the service directory", request.Cwd)
+	}
+	options.AbsWorkingDir = absWorkingDir
+	options.EntryPoints = append(options.EntryPoints, request.EntryPoints...)
+	options.LogLevel = api.LogLevelSilent
+	options.Write = request.Write
+
+	if request.Stdin != nil {
+		if options.Stdin == nil {
+			options.Stdin = &api.StdinOptions{}
+		}
+		options.Stdin.Contents = *request.Stdin
+		options.Stdin.ResolveDir = absWorkingDir
+	}
+
+	if request.Write && options.Outfile == "" && options.Outdir == "" {
+		return nil, fmt.Errorf("Cannot write output files without \"outfile\" or \"outdir\"")
+	}
+	if err := checkScopedBuildPaths(&options, absWorkingDir); err != nil {
+		return nil, err
+	}
+
+	result := api.Build(options)
+	response := map[string]interface{}{
+		"errors":   encodeMessages(result.Errors),
+		"warnings": encodeMessages(result.Warnings),
+	}
+	if !request.Write {
+		// Contents are base64-encoded since output files may be binary
+		response["outputFiles"] = encodeOutputFiles(result.OutputFiles)
+	}
+	if options.Metafile {
+		response["metafile"] = result.Metafile
+	}
+	return response, nil
+}
+
+func (service *serveAPI) handleTransform(request serveAPITransformRequest) (map[string]interface{}, error) {
+	options, err := cli.ParseTransformOptions(request.Flags)
+	if err != nil {
+		return nil, err
+	}
+	options.LogLevel = api.LogLevelSilent
+
+	// Transforms don't have a working directory, so the only path option is
+	// relative to the service directory
+	if options.SupportedFile != "" {
+		absPath, ok := scopedPath(service.absRootDir, options.SupportedFile)
+		if !ok {
+			return nil, fmt.Errorf("The supported-file %q is outside of the service directory", options.SupportedFile)
+		}
+		options.SupportedFile = absPath
+	}
+
+	result := api.Transform(request.Input, options)
+	return map[string]interface{}{
+		"errors":   encodeMessages(result.Errors),
+		"warnings": encodeMessages(result.Warnings),
+		"code":     string(result.Code),
+		"map":      string(result.Map),
+	}, nil
+}
+
+type scopedBuildPath struct {
+	name string
+	path string
+
+	// Otherwise the path is relative to the working directory
+	isRelativeToOutputDir bool
+
+	// Entry points can't be absolute paths
+	mustBeRelative bool
+}
+
+// This is the list of every build option that names a file or a directory
+// (other than "outfile" and "outdir", which the others may be relative to).
+// Make sure to add new path-valued options here. Note that "metafile" isn't
+// included because the service returns the metafile instead of writing it.
+func scopedBuildPaths(options *api.BuildOptions) []scopedBuildPath {
+	paths := []scopedBuildPath{
+		{name: "outbase", path: options.Outbase},
+		{name: "tsconfig", path: options.Tsconfig},
+		{name: "name-cache", path: options.NameCache},
+		{name: "mangle-cache", path: options.MangleCache},
+		{name: "record", path: options.Record},
+		{name: "deno-dir", path: options.DenoDir},
+		{name: "shared-chunk-dir", path: options.SharedChunkDir},
+		{name: "supported-file", path: options.SupportedFile},
+		{name: "precache-manifest", path: options.PrecacheManifest, isRelativeToOutputDir: true},
+		{name: "content-manifest", path: options.ContentManifest, isRelativeToOutputDir: true},
+		{name: "service-worker", path: options.ServiceWorker, isRelativeToOutputDir: true},
+		{name: "csp-report", path: options.CSPReport, isRelativeToOutputDir: true},
+		{name: "feature-report", path: options.FeatureReport, isRelativeToOutputDir: true},
+		{name: "css-order-report", path: options.CSSOrderReport, isRelativeToOutputDir: true},
+		{name: "entry-names", path: options.EntryNames, isRelativeToOutputDir: true},
+		{name: "chunk-names", path: options.ChunkNames, isRelativeToOutputDir: true},
+		{name: "asset-names", path: options.AssetNames, isRelativeToOutputDir: true},
+	}
+	for _, path := range options.Inject {
+		paths = append(paths, scopedBuildPath{name: "inject", path: path})
+	}
+	for _, path := range options.NodePaths {
+		paths = append(paths, scopedBuildPath{name: "node-paths", path: path})
+	}
+	for _, path := range options.Workspaces {
+		paths = append(paths, scopedBuildPath{name: "workspace", path: path})
+	}
+	for _, path := range options.Alias {
+		// Aliases can also substitute one package for another
+		if strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || filepath.IsAbs(path) {
+			paths = append(paths, scopedBuildPath{name: "alias", path: path})
+		}
+	}
+	for _, path := range options.EntryPoints {
+		paths = append(paths, scopedBuildPath{name: "entry point", path: path, mustBeRelative: true})
+	}
+	for _, entryPoint := range options.EntryPointsAdvanced {
+		paths = append(paths,
+			scopedBuildPath{name: "entry point", path: entryPoint.InputPath, mustBeRelative: true},
+			scopedBuildPath{name: "entry point output", path: entryPoint.OutputPath, isRelativeToOutputDir: true})
+	}
+	return paths
+}
+
+func checkScopedBuildPaths(options *api.BuildOptions, absWorkingDir string) error {
+	absOutputDir, ok := scopedPath(absWorkingDir, options.Outdir)
+	if options.Outfile != "" {
+		var absOutfile string
+		absOutfile, ok = scopedPath(absWorkingDir, options.Outfile)
+		absOutputDir = filepath.Dir(absOutfile)
+	}
+	if !ok {
+		return fmt.Errorf("The output path is outside of the working directory")
+	}
+
+	for _, path := range scopedBuildPaths(options) {
+		if path.path == "" {
+			continue
+		}
+		if path.mustBeRelative && filepath.IsAbs(path.path) {
+			return fmt.Errorf("The %s %q must be a relative path", path.name, path.path)
+		}
+		absPath := path.path
+		if path.isRelativeToOutputDir && !filepath.IsAbs(absPath) {
+			absPath = filepath.Join(absOutputDir, absPath)
+		}
+		if _, ok := scopedPath(absWorkingDir, absPath); !ok {
+			return fmt.Errorf("The %s %q is outside of the working directory", path.name, path.path)
+		}
+	}
+	return nil
+}
+
+// Returns the absolute path for "path" relative to "absBaseDir", but only if
+// that path is inside of "absBaseDir" after following symlinks. Symlinks in
+// "absBaseDir" itself must already be resolved. The returned path has its
+// symlinks resolved too.
+func scopedPath(absBaseDir string, path string) (string, bool) {
+	absPath := path
+	if !filepath.IsAbs(absPath) {
+		absPath = filepath.Join(absBaseDir, path)
+	}
+	absPath, ok := evalSymlinksInExistingPath(filepath.Clean(absPath))
+	if !ok {
+		return "", false
+	}
+	relPath, err := filepath.Rel(absBaseDir, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return absPath, true
+}
+
+// Output paths may not exist yet, so only the part of the path that exists is
+// resolved. This fails if part of the path exists but can't be resolved, such
+// as a symlink to a file that doesn't exist yet.
+func evalSymlinksInExistingPath(absPath string) (string, bool) {
+	var missing []string
+	for {
+		if realPath, err := filepath.EvalSymlinks(absPath); err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				realPath = filepath.Join(realPath, missing[i])
+			}
+			return realPath, true
+		}
+		if _, err := os.Lstat(absPath); !os.IsNotExist(err) {
+			return "", false
+		}
+		dir := filepath.Dir(absPath)
+		if dir == absPath {
+			return "", false
+		}
+		missing = append(missing, filepath.Base(absPath))
+		absPath = dir
+	}
+}
+
+func respondWithJSON(res http.ResponseWriter, status int, value interface{}) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		status = http.StatusInternalServerError
+		bytes = []byte(fmt.Sprintf("{\"error\":%q}", err.Error()))
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Content-Length", fmt.Sprintf("%d", len(bytes)))
+	res.WriteHeader(status)
+	res.Write(bytes)
+}
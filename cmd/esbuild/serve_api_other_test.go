@@ -0,0 +1,221 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func makeServeAPIForTest(t *testing.T) (*serveAPI, string) {
+	t.Helper()
+	absRootDir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(absRootDir, "root")
+	for _, dir := range []string{"root/src", "root/sub", "outside"} {
+		if err := os.MkdirAll(filepath.Join(absRootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"root/src/entry.js", "outside/secret.js"} {
+		if err := os.WriteFile(filepath.Join(absRootDir, file), []byte("export let x = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &serveAPI{absRootDir: root}, absRootDir
+}
+
+func symlinkForTest(t *testing.T, target string, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("Cannot create symlinks: %s", err.Error())
+	}
+}
+
+func TestServeAPIScopedPath(t *testing.T) {
+	service, absTempDir := makeServeAPIForTest(t)
+	root := service.absRootDir
+	symlinkForTest(t, filepath.Join(absTempDir, "outside"), filepath.Join(root, "link"))
+	symlinkForTest(t, filepath.Join(absTempDir, "outside", "missing.js"), filepath.Join(root, "dangling.js"))
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", root},
+		{"src/entry.js", filepath.Join(root, "src", "entry.js")},
+		{"src/../sub/new/file.js", filepath.Join(root, "sub", "new", "file.js")},
+		{filepath.Join(root, "src"), filepath.Join(root, "src")},
+		{"..", ""},
+		{"../outside/secret.js", ""},
+		{"src/../../outside", ""},
+		{filepath.Join(absTempDir, "outside"), ""},
+		{"link", ""},
+		{"link/secret.js", ""},
+		{"link/new/file.js", ""},
+		{"dangling.js", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			absPath, ok := scopedPath(root, tt.path)
+			test.AssertEqual(t, ok, tt.expected != "")
+			test.AssertEqual(t, absPath, tt.expected)
+		})
+	}
+}
+
+func TestServeAPIBuildPaths(t *testing.T) {
+	service, absTempDir := makeServeAPIForTest(t)
+	root := service.absRootDir
+	symlinkForTest(t, filepath.Join(absTempDir, "outside"), filepath.Join(root, "link"))
+
+	tests := []struct {
+		name        string
+		request     serveAPIBuildRequest
+		expectedErr string
+	}{
+		{
+			name:    "Valid",
+			request: serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outdir=out", "--content-manifest=manifest.json"}},
+		},
+		{
+			name:        "WorkingDirectoryEscape",
+			request:     serveAPIBuildRequest{Cwd: "../outside", EntryPoints: []string{"secret.js"}},
+			expectedErr: "The working directory \"../outside\" is outside of the service directory",
+		},
+		{
+			name:        "WorkingDirectorySymlink",
+			request:     serveAPIBuildRequest{Cwd: "link", EntryPoints: []string{"secret.js"}},
+			expectedErr: "The working directory \"link\" is outside of the service directory",
+		},
+		{
+			name:        "EntryPointEscape",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"../outside/secret.js"}},
+			expectedErr: "The entry point \"../outside/secret.js\" is outside of the working directory",
+		},
+		{
+			name:        "EntryPointSymlink",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"link/secret.js"}},
+			expectedErr: "The entry point \"link/secret.js\" is outside of the working directory",
+		},
+		{
+			name:        "EntryPointAbsolute",
+			request:     serveAPIBuildRequest{EntryPoints: []string{filepath.Join(root, "src", "entry.js")}},
+			expectedErr: fmt.Sprintf("The entry point %q must be a relative path", filepath.Join(root, "src", "entry.js")),
+		},
+		{
+			name:        "EntryPointInFlags",
+			request:     serveAPIBuildRequest{Flags: []string{"out=../outside/secret.js"}},
+			expectedErr: "The entry point \"../outside/secret.js\" is outside of the working directory",
+		},
+		{
+			name:        "EntryPointOutputEscape",
+			request:     serveAPIBuildRequest{Flags: []string{"--outdir=out", "../../x=src/entry.js"}},
+			expectedErr: "The entry point output \"../../x\" is outside of the working directory",
+		},
+		{
+			name:        "OutdirSymlink",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outdir=link/out"}},
+			expectedErr: "The output path is outside of the working directory",
+		},
+		{
+			name:        "OutfileAbsolute",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outfile=" + filepath.Join(absTempDir, "outside", "out.js")}},
+			expectedErr: "The output path is outside of the working directory",
+		},
+		{
+			name:        "MetafileIsNotAPath",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--metafile=../meta.json"}},
+			expectedErr: "Invalid build flag: \"--metafile=../meta.json\"",
+		},
+		{
+			name:        "ContentManifestEscape",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outdir=out", "--content-manifest=../../manifest.json"}},
+			expectedErr: "The content-manifest \"../../manifest.json\" is outside of the working directory",
+		},
+		{
+			name:        "CSSOrderReportSymlink",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outdir=.", "--css-order-report=link/report.json"}},
+			expectedErr: "The css-order-report \"link/report.json\" is outside of the working directory",
+		},
+		{
+			name:        "NameCacheAbsolute",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--name-cache=" + filepath.Join(absTempDir, "cache.json")}},
+			expectedErr: fmt.Sprintf("The name-cache %q is outside of the working directory", filepath.Join(absTempDir, "cache.json")),
+		},
+		{
+			name:        "EntryNamesEscape",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--outdir=out", "--entry-names=../../[name]"}},
+			expectedErr: "The entry-names \"../../[name]\" is outside of the working directory",
+		},
+		{
+			name:        "InjectEscape",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--inject:../outside/secret.js"}},
+			expectedErr: "The inject \"../outside/secret.js\" is outside of the working directory",
+		},
+		{
+			name:        "SupportedFileEscape",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--supported-file=../outside/secret.js"}},
+			expectedErr: "The supported-file \"../outside/secret.js\" is outside of the working directory",
+		},
+		{
+			name:        "AliasFileSymlink",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--alias:x=./link/secret.js"}},
+			expectedErr: "The alias \"./link/secret.js\" is outside of the working directory",
+		},
+		{
+			name:    "AliasPackage",
+			request: serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--alias:react=preact/compat"}},
+		},
+		{
+			name:        "TypeCheck",
+			request:     serveAPIBuildRequest{EntryPoints: []string{"src/entry.js"}, Flags: []string{"--type-check=tsc"}},
+			expectedErr: "Cannot use \"type-check\" with the API service",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.handleBuild(tt.request)
+			errText := ""
+			if err != nil {
+				errText = err.Error()
+			}
+			test.AssertEqual(t, errText, tt.expectedErr)
+		})
+	}
+}
+
+func TestServeAPITransformPaths(t *testing.T) {
+	service, absTempDir := makeServeAPIForTest(t)
+	root := service.absRootDir
+	if err := os.WriteFile(filepath.Join(root, "tv.json"), []byte(`{ "arrow": false }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The feature support file is relative to the service directory
+	response, err := service.handleTransform(serveAPITransformRequest{Input: "let f = () => 1", Flags: []string{"--supported-file=tv.json"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertEqual(t, len(response["errors"].([]interface{})), 0)
+	test.AssertEqual(t, response["code"], "let f = function() {\n  return 1;\n};\n")
+
+	// Other files can't be read, including through the parse error message
+	for _, path := range []string{"../outside/secret.js", filepath.Join(absTempDir, "outside", "secret.js")} {
+		_, err := service.handleTransform(serveAPITransformRequest{Flags: []string{"--supported-file=" + path}})
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		test.AssertEqual(t, errText, fmt.Sprintf("The supported-file %q is outside of the service directory", path))
+	}
+}
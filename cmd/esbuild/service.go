@@ -6,6 +6,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -26,13 +27,16 @@ import (
 
 type responseCallback = func(interface{})
 type rebuildCallback = func(uint32) []byte
+type rebuildUpdateCallback = func(api.UpdateOptions) error
 type watchStopCallback = func()
-type serverStopCallback = func()
+type serverStopCallback = func(graceful bool)
 
 type serviceType struct {
 	mutex           sync.Mutex
 	callbacks       map[uint32]responseCallback
 	rebuilds        map[int]rebuildCallback
+	rebuildUpdates  map[int]rebuildUpdateCallback
+	rebuildStops    map[int]watchStopCallback
 	watchStops      map[int]watchStopCallback
 	serveStops      map[int]serverStopCallback
 	nextID          uint32
@@ -52,6 +56,8 @@ func runService(sendPings bool) {
 	service := serviceType{
 		callbacks:       make(map[uint32]responseCallback),
 		rebuilds:        make(map[int]rebuildCallback),
+		rebuildUpdates:  make(map[int]rebuildUpdateCallback),
+		rebuildStops:    make(map[int]watchStopCallback),
 		watchStops:      make(map[int]watchStopCallback),
 		serveStops:      make(map[int]serverStopCallback),
 		outgoingPackets: make(chan outgoingPacket),
@@ -218,6 +224,29 @@ func (service *serviceType) handleIncomingPacket(bytes []byte) (result outgoingP
 				bytes: rebuild(p.id),
 			}
 
+		case "rebuild-update":
+			rebuildID := request["rebuildID"].(int)
+			update, ok := func() (rebuildUpdateCallback, bool) {
+				service.mutex.Lock()
+				defer service.mutex.Unlock()
+				update, ok := service.rebuildUpdates[rebuildID]
+				return update, ok
+			}()
+			value := make(map[string]interface{})
+			if !ok {
+				value["error"] = "Cannot update"
+			} else if options, err := decodeUpdateOptions(request); err != nil {
+				value["error"] = err.Error()
+			} else if err := update(options); err != nil {
+				value["error"] = err.Error()
+			}
+			return outgoingPacket{
+				bytes: encodePacket(packet{
+					id:    p.id,
+					value: value,
+				}),
+			}
+
 		case "watch-stop":
 			watchID := request["watchID"].(int)
 			refCount := 0
@@ -246,6 +275,7 @@ func (service *serviceType) handleIncomingPacket(bytes []byte) (result outgoingP
 
 		case "serve-stop":
 			serveID := request["serveID"].(int)
+			graceful, _ := request["graceful"].(bool)
 			refCount := 0
 			serveStop := func() serverStopCallback {
 				// Only mutate the map while inside a mutex
@@ -260,7 +290,7 @@ func (service *serviceType) handleIncomingPacket(bytes []byte) (result outgoingP
 				return nil
 			}()
 			if serveStop != nil {
-				serveStop()
+				serveStop(graceful)
 			}
 			return outgoingPacket{
 				bytes: encodePacket(packet{
@@ -282,6 +312,13 @@ func (service *serviceType) handleIncomingPacket(bytes []byte) (result outgoingP
 					// count at the return of the first build call for this rebuild chain.
 					refCount = -1
 					delete(service.rebuilds, rebuildID)
+					delete(service.rebuildUpdates, rebuildID)
+
+					// This stops the type check command if there is one
+					if stop, ok := service.rebuildStops[rebuildID]; ok {
+						delete(service.rebuildStops, rebuildID)
+						go stop()
+					}
 				}
 			}()
 			return outgoingPacket{
@@ -436,9 +473,18 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 		if options.Metafile {
 			response["metafile"] = result.Metafile
 		}
+		if result.NameCache != "" {
+			response["nameCache"] = result.NameCache
+		}
+		if result.MangleCache != "" {
+			response["mangleCache"] = result.MangleCache
+		}
 		if writeToStdout && len(result.OutputFiles) == 1 {
 			response["writeToStdout"] = result.OutputFiles[0].Contents
 		}
+		if result.WatchChanges != nil {
+			response["watchChanges"] = encodeWatchChanges(result.WatchChanges)
+		}
 		return response
 	}
 
@@ -455,6 +501,9 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 	if !writeToStdout {
 		options.Write = write
 	}
+
+	// The output files aren't passed back to the caller when they are written
+	options.StreamCopiedFiles = true
 	options.Incremental = incremental
 	result := api.Build(options)
 	response := resultToResponse(result)
@@ -473,6 +522,10 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 					value: response,
 				})
 			}
+			service.rebuildUpdates[rebuildID] = result.Update
+			if result.Stop != nil && options.Watch == nil {
+				service.rebuildStops[rebuildID] = result.Stop
+			}
 		}()
 
 		// Make sure the build doesn't finish until "dispose" has been called
@@ -485,7 +538,9 @@ func (service *serviceType) handleBuildRequest(id uint32, request map[string]int
 			service.mutex.Lock()
 			defer service.mutex.Unlock()
 			service.watchStops[watchID] = func() {
-				result.Stop()
+				// Don't wait for a rebuild that's in progress to finish since that
+				// rebuild may be waiting for a response from the same JS callback
+				go result.Stop()
 			}
 		}()
 
@@ -515,6 +570,39 @@ func (service *serviceType) handleServeRequest(id uint32, options api.BuildOptio
 	if servedir, ok := serve["servedir"]; ok {
 		serveOptions.Servedir = servedir.(string)
 	}
+	if proxy, ok := serve["proxy"]; ok {
+		serveOptions.Proxy = proxy.(string)
+	}
+	if throttle, ok := serve["throttle"]; ok {
+		for _, rule := range throttle.([]interface{}) {
+			rule := rule.(map[string]interface{})
+			serveOptions.Throttle = append(serveOptions.Throttle, api.ServeThrottle{
+				Pattern:        rule["pattern"].(string),
+				LatencyInMS:    rule["latency"].(int),
+				BytesPerSecond: rule["bytesPerSecond"].(int),
+			})
+		}
+	}
+	if projects, ok := serve["projects"]; ok {
+		serveOptions.Projects = make(map[string]api.BuildOptions)
+		for _, project := range projects.([]interface{}) {
+			project := project.(map[string]interface{})
+			projectOptions, err := cli.ParseBuildOptions(decodeStringArray(project["flags"].([]interface{})))
+			if err != nil {
+				return outgoingPacket{bytes: encodeErrorPacket(id, err)}
+			}
+			projectOptions.AbsWorkingDir = project["absWorkingDir"].(string)
+			projectOptions.NodePaths = decodeStringArray(project["nodePaths"].([]interface{}))
+			for _, entry := range project["entries"].([]interface{}) {
+				entry := entry.([]interface{})
+				projectOptions.EntryPointsAdvanced = append(projectOptions.EntryPointsAdvanced, api.EntryPoint{
+					OutputPath: entry[0].(string),
+					InputPath:  entry[1].(string),
+				})
+			}
+			serveOptions.Projects[project["prefix"].(string)] = projectOptions
+		}
+	}
 	serveOptions.OnRequest = func(args api.ServeOnRequestArgs) {
 		service.sendRequest(map[string]interface{}{
 			"command": "serve-request",
@@ -586,6 +674,8 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 
 	var onResolveCallbacks []filteredCallback
 	var onLoadCallbacks []filteredCallback
+	var onTransformCallbacks []filteredCallback
+	var onSourceMapCallbacks []filteredCallback
 
 	filteredCallbacks := func(pluginName string, kind string, items []interface{}) (result []filteredCallback, err error) {
 		for _, item := range items {
@@ -594,11 +684,12 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 			if err != nil {
 				return nil, err
 			}
+			namespace, _ := item["namespace"].(string) // Source map callbacks don't have a namespace
 			result = append(result, filteredCallback{
 				pluginName: pluginName,
 				id:         item["id"].(int),
 				filter:     filter,
-				namespace:  item["namespace"].(string),
+				namespace:  namespace,
 			})
 		}
 		return
@@ -619,6 +710,18 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 		} else {
 			onLoadCallbacks = append(onLoadCallbacks, callbacks...)
 		}
+
+		if callbacks, err := filteredCallbacks(pluginName, "onTransform", p["onTransform"].([]interface{})); err != nil {
+			return nil, err
+		} else {
+			onTransformCallbacks = append(onTransformCallbacks, callbacks...)
+		}
+
+		if callbacks, err := filteredCallbacks(pluginName, "onSourceMap", p["onSourceMap"].([]interface{})); err != nil {
+			return nil, err
+		} else {
+			onSourceMapCallbacks = append(onSourceMapCallbacks, callbacks...)
+		}
 	}
 
 	// We want to minimize the amount of IPC traffic. Instead of adding one Go
@@ -679,6 +782,8 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 					kind = "import-rule"
 				case api.ResolveCSSURLToken:
 					kind = "url-token"
+				case api.ResolveCSSComposesFrom:
+					kind = "composes-from"
 
 				default:
 					panic("Internal error")
@@ -816,6 +921,137 @@ func (service *serviceType) convertPlugins(key int, jsPlugins interface{}) ([]ap
 
 				return result, nil
 			})
+
+			// Only ask the host about transforms if there are any transform
+			// callbacks since every file would otherwise cost a round trip
+			if len(onTransformCallbacks) > 0 {
+				build.OnTransform(api.OnTransformOptions{Filter: ".*"}, func(args api.OnTransformArgs) (api.OnTransformResult, error) {
+					var ids []interface{}
+					applyPath := logger.Path{Text: args.Path, Namespace: args.Namespace}
+					for _, item := range onTransformCallbacks {
+						if config.PluginAppliesToPath(applyPath, item.filter, item.namespace) {
+							ids = append(ids, item.id)
+						}
+					}
+
+					result := api.OnTransformResult{}
+					if len(ids) == 0 {
+						return result, nil
+					}
+
+					request := map[string]interface{}{
+						"command":    "on-transform",
+						"key":        key,
+						"ids":        ids,
+						"path":       args.Path,
+						"namespace":  args.Namespace,
+						"pluginData": args.PluginData,
+						"loader":     cli_helpers.LoaderName(args.Loader),
+						"contents":   []byte(args.Contents),
+					}
+					if args.SourceMap != "" {
+						request["sourceMap"] = args.SourceMap
+					}
+					response := service.sendRequest(request).(map[string]interface{})
+
+					if value, ok := response["id"]; ok {
+						id := value.(int)
+						for _, item := range onTransformCallbacks {
+							if item.id == id {
+								result.PluginName = item.pluginName
+								break
+							}
+						}
+					}
+					if value, ok := response["error"]; ok {
+						return result, errors.New(value.(string))
+					}
+					if value, ok := response["pluginName"]; ok {
+						result.PluginName = value.(string)
+					}
+					if value, ok := response["contents"]; ok {
+						contents := string(value.([]byte))
+						result.Contents = &contents
+					}
+					if value, ok := response["sourceMap"]; ok {
+						result.SourceMap = value.(string)
+					}
+					if value, ok := response["errors"]; ok {
+						result.Errors = decodeMessages(value.([]interface{}))
+					}
+					if value, ok := response["warnings"]; ok {
+						result.Warnings = decodeMessages(value.([]interface{}))
+					}
+					if value, ok := response["watchFiles"]; ok {
+						result.WatchFiles = decodeStringArray(value.([]interface{}))
+					}
+					if value, ok := response["watchDirs"]; ok {
+						result.WatchDirs = decodeStringArray(value.([]interface{}))
+					}
+
+					return result, nil
+				})
+			}
+
+			if len(onSourceMapCallbacks) > 0 {
+				build.OnSourceMap(api.OnSourceMapOptions{Filter: ".*"}, func(args api.OnSourceMapArgs) (api.OnSourceMapResult, error) {
+					var ids []interface{}
+					applyPath := logger.Path{Text: args.Path, Namespace: "file"}
+					for _, item := range onSourceMapCallbacks {
+						if config.PluginAppliesToPath(applyPath, item.filter, item.namespace) {
+							ids = append(ids, item.id)
+						}
+					}
+
+					result := api.OnSourceMapResult{}
+					if len(ids) == 0 {
+						return result, nil
+					}
+
+					// The source map is sent as JSON text and is parsed by the host
+					sourceMap, err := json.Marshal(args.SourceMap)
+					if err != nil {
+						return result, err
+					}
+					response := service.sendRequest(map[string]interface{}{
+						"command":       "on-source-map",
+						"key":           key,
+						"ids":           ids,
+						"path":          args.Path,
+						"sourceMapPath": args.SourceMapPath,
+						"sourceMap":     string(sourceMap),
+					}).(map[string]interface{})
+
+					if value, ok := response["id"]; ok {
+						id := value.(int)
+						for _, item := range onSourceMapCallbacks {
+							if item.id == id {
+								result.PluginName = item.pluginName
+								break
+							}
+						}
+					}
+					if value, ok := response["error"]; ok {
+						return result, errors.New(value.(string))
+					}
+					if value, ok := response["pluginName"]; ok {
+						result.PluginName = value.(string)
+					}
+					if value, ok := response["sourceMap"]; ok {
+						if err := json.Unmarshal([]byte(value.(string)), &result.SourceMap); err != nil {
+							return result, err
+						}
+					}
+					if value, ok := response["errors"]; ok {
+						result.Errors = decodeMessages(value.([]interface{}))
+					}
+					if value, ok := response["warnings"]; ok {
+						result.Warnings = decodeMessages(value.([]interface{}))
+					}
+
+					return result, nil
+				})
+			}
 		},
 	})
 
@@ -881,6 +1117,9 @@ func (service *serviceType) handleTransformRequest(id uint32, request map[string
 
 			"mapFS": mapFS,
 			"map":   string(result.Map),
+
+			"bannerLine": result.BannerLine,
+			"footerLine": result.FooterLine,
 		},
 	})
 }
@@ -921,6 +1160,9 @@ func (service *serviceType) handleAnalyzeMetafileRequest(id uint32, request map[
 	if value, ok := request["verbose"].(bool); ok {
 		options.Verbose = value
 	}
+	if value, ok := request["filter"].(string); ok {
+		options.Filter = value
+	}
 
 	result := api.AnalyzeMetafile(metafile, options)
 
@@ -948,6 +1190,26 @@ func decodeStringArray(values []interface{}) []string {
 	return strings
 }
 
+func encodeWatchChanges(changes []api.WatchChange) []interface{} {
+	values := make([]interface{}, len(changes))
+	for i, change := range changes {
+		var kind string
+		switch change.Kind {
+		case api.WatchChangeModified:
+			kind = "modified"
+		case api.WatchChangeAdded:
+			kind = "added"
+		case api.WatchChangeDeleted:
+			kind = "deleted"
+		}
+		value := make(map[string]interface{})
+		values[i] = value
+		value["path"] = change.Path
+		value["kind"] = kind
+	}
+	return values
+}
+
 func encodeOutputFiles(outputFiles []api.OutputFile) []interface{} {
 	values := make([]interface{}, len(outputFiles))
 	for i, outputFile := range outputFiles {
@@ -955,6 +1217,8 @@ func encodeOutputFiles(outputFiles []api.OutputFile) []interface{} {
 		values[i] = value
 		value["path"] = outputFile.Path
 		value["contents"] = outputFile.Contents
+		value["bannerLine"] = outputFile.BannerLine
+		value["footerLine"] = outputFile.FooterLine
 	}
 	return values
 }
@@ -1083,3 +1347,59 @@ func decodeMessageToPrivate(obj map[string]interface{}) logger.Msg {
 	}
 	return msg
 }
+
+func decodeUpdateOptions(request map[string]interface{}) (api.UpdateOptions, error) {
+	var options api.UpdateOptions
+
+	if value, ok := request["logLevel"].(string); ok {
+		var logLevel api.LogLevel
+		switch value {
+		case "verbose":
+			logLevel = api.LogLevelVerbose
+		case "debug":
+			logLevel = api.LogLevelDebug
+		case "info":
+			logLevel = api.LogLevelInfo
+		case "warning":
+			logLevel = api.LogLevelWarning
+		case "error":
+			logLevel = api.LogLevelError
+		case "silent":
+			logLevel = api.LogLevelSilent
+		default:
+			return api.UpdateOptions{}, fmt.Errorf("Invalid log level: %q", value)
+		}
+		options.LogLevel = &logLevel
+	}
+
+	decodeMap := func(key string) map[string]string {
+		value, ok := request[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		result := make(map[string]string, len(value))
+		for k, v := range value {
+			result[k] = v.(string)
+		}
+		return result
+	}
+	options.Define = decodeMap("define")
+	options.Banner = decodeMap("banner")
+
+	if value, ok := request["drop"].([]interface{}); ok {
+		var drop api.Drop
+		for _, item := range value {
+			switch item.(string) {
+			case "console":
+				drop |= api.DropConsole
+			case "debugger":
+				drop |= api.DropDebugger
+			default:
+				return api.UpdateOptions{}, fmt.Errorf("Invalid drop value: %q", item.(string))
+			}
+		}
+		options.Drop = &drop
+	}
+
+	return options, nil
+}
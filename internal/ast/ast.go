@@ -32,6 +32,9 @@ const (
 
 	// A CSS "url(...)" token
 	ImportURL
+
+	// A CSS "composes: ... from" declaration
+	ImportComposesFrom
 )
 
 func (kind ImportKind) StringForMetafile() string {
@@ -48,6 +51,8 @@ func (kind ImportKind) StringForMetafile() string {
 		return "import-rule"
 	case ImportURL:
 		return "url-token"
+	case ImportComposesFrom:
+		return "composes-from"
 	case ImportEntryPoint:
 		return "entry-point"
 	default:
@@ -56,7 +61,12 @@ func (kind ImportKind) StringForMetafile() string {
 }
 
 func (kind ImportKind) IsFromCSS() bool {
-	return kind == ImportAt || kind == ImportURL
+	return kind == ImportAt || kind == ImportURL || kind == ImportComposesFrom
+}
+
+// These imports are resolved the same way as "@import" rules
+func (kind ImportKind) IsCSSImport() bool {
+	return kind == ImportAt || kind == ImportAtConditional || kind == ImportComposesFrom
 }
 
 type ImportRecord struct {
@@ -92,6 +102,17 @@ type ImportRecord struct {
 	// Tell the printer to use the runtime "__require()" instead of "require()"
 	CallRuntimeRequire bool
 
+	// Tell the printer to use the runtime "__loadScript()" instead of "import()"
+	CallRuntimeLoadScript bool
+
+	// Tell the printer to use "Promise.resolve().then(() => require())" instead
+	// of "import()" even though "import()" is supported
+	CallRequireInsteadOfImport bool
+
+	// Tell the printer to read this external import from a global variable
+	// instead of calling "require()". The path is the import path as written.
+	IsExternalGlobal bool
+
 	// True for the following cases:
 	//
 	//   try { require('x') } catch { handle }
@@ -0,0 +1,69 @@
+package bundler
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/sourcemap"
+)
+
+// With "--sourcemap-banners", the banner and footer are mapped to these
+// virtual sources in the source map instead of being left unmapped. Each
+// virtual source contains the text of the banner or footer.
+const bannerVirtualSource = "<banner>"
+const footerVirtualSource = "<footer>"
+
+func (c *linkerContext) mapsBannersToVirtualSources() bool {
+	return c.options.SourceMapBanners && c.options.SourceMap != config.SourceMapNone
+}
+
+// This maps each line of the text to the same line of a virtual source. The
+// offset is relative to the end of the previous source map chunk.
+func appendVirtualSourceMapping(
+	results []compileResultForSourceMap,
+	name string,
+	text string,
+	offset sourcemap.LineColumnOffset,
+) []compileResultForSourceMap {
+	output := []byte(text)
+	builder := sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(text, int32(strings.Count(text, "\n")+1)))
+	builder.AddSourceMapping(logger.Loc{Start: 0}, output[:0])
+	for i, c := range text {
+		if c == '\n' && i+1 < len(text) {
+			builder.AddSourceMapping(logger.Loc{Start: int32(i + 1)}, output[:i+1])
+		}
+	}
+	return append(results, compileResultForSourceMap{
+		sourceMapChunk:        builder.GenerateChunk(output),
+		generatedOffset:       offset,
+		virtualSourceName:     name,
+		virtualSourceContents: text,
+	})
+}
+
+// The footer comes last, after text that isn't tracked with line and column
+// offsets (e.g. the closing of the IIFE wrapper and any legal comments). The
+// offset is computed from the contents so far instead. The "mappedEnd" byte
+// offset is where the last source map chunk ended.
+func (c *linkerContext) appendFooter(
+	j *helpers.Joiner,
+	results *[]compileResultForSourceMap,
+	chunk *chunkInfo,
+	footer string,
+	mappedEnd uint32,
+) {
+	contents := j.Done()
+	before := sourcemap.LineColumnOffset{}
+	before.AdvanceBytes(contents[:mappedEnd])
+	after := sourcemap.LineColumnOffset{}
+	after.AdvanceBytes(contents[mappedEnd:])
+	chunk.footerLine = before.Lines + after.Lines
+
+	if c.mapsBannersToVirtualSources() {
+		*results = appendVirtualSourceMapping(*results, footerVirtualSource, footer, after)
+	}
+	j.AddString(footer)
+	j.AddString("\n")
+}
@@ -1,7 +1,6 @@
 package bundler
 
 import (
-	"bytes"
 	"encoding/base32"
 	"encoding/base64"
 	"fmt"
@@ -38,6 +37,13 @@ type scannerFile struct {
 	inputFile  graph.InputFile
 	pluginData interface{}
 
+	// For files in a namespace other than "file", these say where the file came
+	// from. The plugin name is the plugin that loaded the file's contents, or
+	// the plugin that resolved its path if no plugin loaded it. The specifier is
+	// the import path that was resolved to this file the first time it was seen.
+	pluginName string
+	specifier  string
+
 	// If "AbsMetadataFile" is present, this will be filled out with information
 	// about this file in JSON format. This is a partial JSON file that will be
 	// fully assembled later.
@@ -70,6 +76,15 @@ type Bundle struct {
 	// chunk during linking. These unique keys are used to identify each chunk
 	// before the final output paths have been computed.
 	uniqueKeyPrefix string
+
+	// When generating a dual package, this bundle is the CommonJS half and this
+	// is the ESM half. Each half must be scanned separately because parsing
+	// depends on the output format.
+	esmBundle *Bundle
+
+	// When building multiple formats, each format is linked from one of these
+	// bundles. Formats that parse the same way share a bundle.
+	formatBundles []formatBundle
 }
 
 type parseArgs struct {
@@ -84,6 +99,8 @@ type parseArgs struct {
 	sideEffects     graph.SideEffects
 	importPathRange logger.Range
 	pluginData      interface{}
+	pluginName      string
+	specifier       string
 	options         config.Options
 	results         chan parseResult
 	inject          chan config.InjectedFile
@@ -116,6 +133,8 @@ func parseFile(args parseArgs) {
 	var absResolveDir string
 	var pluginName string
 	var pluginData interface{}
+	var contentsAbsPath string
+	var contentsLen int
 
 	if stdin := args.options.Stdin; stdin != nil {
 		// Special-case stdin
@@ -137,6 +156,7 @@ func parseFile(args parseArgs) {
 			args.importPathRange,
 			args.pluginData,
 			args.options.WatchMode,
+			args.options.ExtensionToLoader,
 		)
 		if !ok {
 			if args.inject != nil {
@@ -151,6 +171,8 @@ func parseFile(args parseArgs) {
 		absResolveDir = result.absResolveDir
 		pluginName = result.pluginName
 		pluginData = result.pluginData
+		contentsAbsPath = result.contentsAbsPath
+		contentsLen = result.contentsLen
 	}
 
 	_, base, ext := logger.PlatformIndependentPathDirBaseExt(source.KeyPath.Text)
@@ -160,17 +182,56 @@ func parseFile(args parseArgs) {
 		loader = loaderFromFileExtension(args.options.ExtensionToLoader, base+ext)
 	}
 
+	// Let plugins transform the contents before they are parsed. This is skipped
+	// for files that were never read into memory.
+	var transformSourceMap *string
+	if contentsAbsPath == "" {
+		sourceMap, ok := runOnTransformPlugins(
+			args.options.Plugins,
+			args.res,
+			args.fs,
+			&args.caches.FSCache,
+			args.log,
+			&source,
+			args.importSource,
+			args.importPathRange,
+			pluginData,
+			loader,
+		)
+		if !ok {
+			if args.inject != nil {
+				args.inject <- config.InjectedFile{
+					Source: source,
+				}
+			}
+			args.results <- parseResult{}
+			return
+		}
+		transformSourceMap = sourceMap
+	}
+
 	result := parseResult{
 		file: scannerFile{
 			inputFile: graph.InputFile{
-				Source:      source,
-				Loader:      loader,
-				SideEffects: args.sideEffects,
+				Source:          source,
+				Loader:          loader,
+				SideEffects:     args.sideEffects,
+				ContentsAbsPath: contentsAbsPath,
+				ContentsLen:     contentsLen,
 			},
 			pluginData: pluginData,
 		},
 	}
 
+	// Remember where files from other namespaces came from for the metafile
+	if source.KeyPath.Namespace != "file" {
+		result.file.pluginName = pluginName
+		if result.file.pluginName == "" {
+			result.file.pluginName = args.pluginName
+		}
+		result.file.specifier = args.specifier
+	}
+
 	defer func() {
 		r := recover()
 		if r != nil {
@@ -181,6 +242,19 @@ func parseFile(args parseArgs) {
 		}
 	}()
 
+	// Let the parser find the files that partially-dynamic import paths match
+	if args.options.BundleDynamicPaths && args.options.Mode == config.ModeBundle && absResolveDir != "" {
+		args.options.ExpandRequireContext = func(pattern string) []string {
+			return expandRequireContext(args.fs, args.options.ExtensionToLoader, absResolveDir, source.KeyPath, pattern)
+		}
+	}
+
+	// The node polyfills don't use the "process" and "Buffer" globals, so they
+	// shouldn't import the injected files that replace those globals
+	if source.KeyPath.Namespace == runtime.NodePolyfillNamespace {
+		args.options.InjectedFiles = nil
+	}
+
 	switch loader {
 	case config.LoaderJS:
 		ast, ok := args.caches.JSCache.Parse(args.log, source, js_parser.OptionsFromConfig(&args.options))
@@ -219,17 +293,39 @@ func parseFile(args parseArgs) {
 		result.file.inputFile.Repr = &graph.JSRepr{AST: ast}
 		result.ok = ok
 
-	case config.LoaderCSS:
+	case config.LoaderCSS, config.LoaderLocalCSS:
 		ast := args.caches.CSSCache.Parse(args.log, source, css_parser.Options{
 			MangleSyntax:           args.options.MangleSyntax,
 			RemoveWhitespace:       args.options.RemoveWhitespace,
 			UnsupportedCSSFeatures: args.options.UnsupportedCSSFeatures,
+			LocalCSS:               loader == config.LoaderLocalCSS,
 		})
 		result.file.inputFile.Repr = &graph.CSSRepr{AST: ast}
 		result.ok = true
 
-	case config.LoaderJSON:
-		expr, ok := args.caches.JSONCache.Parse(args.log, source, js_parser.JSONOptions{})
+	case config.LoaderJSON, config.LoaderJSONC, config.LoaderJSON5:
+		expr, ok := args.caches.JSONCache.Parse(args.log, source, js_parser.JSONOptions{
+			AllowComments:       loader == config.LoaderJSONC,
+			AllowTrailingCommas: loader == config.LoaderJSONC,
+			AllowJSON5:          loader == config.LoaderJSON5,
+		})
+		ast := js_parser.LazyExportAST(args.log, source, js_parser.OptionsFromConfig(&args.options), expr, "")
+		if pluginName != "" {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData_FromPlugin
+		} else {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData
+		}
+		result.file.inputFile.Repr = &graph.JSRepr{AST: ast}
+		result.ok = ok
+
+	case config.LoaderYAML, config.LoaderTOML:
+		var expr js_ast.Expr
+		var ok bool
+		if loader == config.LoaderYAML {
+			expr, ok = js_parser.ParseYAML(args.log, source)
+		} else {
+			expr, ok = js_parser.ParseTOML(args.log, source)
+		}
 		ast := js_parser.LazyExportAST(args.log, source, js_parser.OptionsFromConfig(&args.options), expr, "")
 		if pluginName != "" {
 			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData_FromPlugin
@@ -315,6 +411,63 @@ func parseFile(args parseArgs) {
 		// Mark that this file is from the "file" loader
 		result.file.inputFile.UniqueKeyForFileLoader = uniqueKey
 
+	case config.LoaderCopy:
+		// This file is copied to the output directory like the "file" loader.
+		// But instead of turning into a string, imports of this file are kept
+		// and point to the copy. The linker does that using the URL here.
+		uniqueKey := fmt.Sprintf("%sA%08d", args.uniqueKeyPrefix, args.sourceIndex)
+		uniqueKeyPath := uniqueKey + source.KeyPath.IgnoredSuffix
+		ast := js_parser.LazyExportAST(args.log, source, js_parser.OptionsFromConfig(&args.options), js_ast.Expr{Data: js_ast.ENullShared}, "")
+		ast.URLForCSS = uniqueKeyPath
+		result.file.inputFile.Repr = &graph.JSRepr{AST: ast}
+		result.ok = true
+		result.file.inputFile.UniqueKeyForFileLoader = uniqueKey
+
+	case config.LoaderImage:
+		// The query is consumed by the transform, so it's not part of the final URL
+		transform, err := parseImageTransform(source.KeyPath.IgnoredSuffix)
+		if err == nil {
+			result.file.inputFile.Source.Contents, err = transformImage(source.Contents, transform, args.options.ImageEncoders)
+		}
+		if err != nil {
+			tracker := logger.MakeLineColumnTracker(args.importSource)
+			args.log.Add(logger.Error, &tracker, args.importPathRange, fmt.Sprintf("%s: %s", source.PrettyPath, err.Error()))
+			break
+		}
+		uniqueKey := fmt.Sprintf("%sA%08d", args.uniqueKeyPrefix, args.sourceIndex)
+		expr := js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(uniqueKey)}}
+		ast := js_parser.LazyExportAST(args.log, source, js_parser.OptionsFromConfig(&args.options), expr, "")
+		ast.URLForCSS = uniqueKey
+		if pluginName != "" {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData_FromPlugin
+		} else {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData
+		}
+		result.file.inputFile.Repr = &graph.JSRepr{AST: ast}
+		result.ok = true
+
+		// Mark that this file is copied to the output directory like the "file" loader
+		result.file.inputFile.UniqueKeyForFileLoader = uniqueKey
+
+	case config.LoaderWebManifest:
+		expr, ok := args.caches.JSONCache.Parse(args.log, source, js_parser.JSONOptions{})
+		uniqueKey := fmt.Sprintf("%sA%08d", args.uniqueKeyPrefix, args.sourceIndex)
+		uniqueKeyPath := uniqueKey + source.KeyPath.IgnoredSuffix
+		ast := js_parser.LazyExportAST(args.log, source, js_parser.OptionsFromConfig(&args.options),
+			js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(uniqueKeyPath)}}, "")
+		ast.URLForCSS = uniqueKeyPath
+		ast.ImportRecords = webManifestImportRecords(&source, expr)
+		if pluginName != "" {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData_FromPlugin
+		} else {
+			result.file.inputFile.SideEffects.Kind = graph.NoSideEffects_PureData
+		}
+		result.file.inputFile.Repr = &graph.JSRepr{AST: ast}
+		result.ok = ok
+
+		// The manifest is written to the output directory like the "file" loader
+		result.file.inputFile.UniqueKeyForFileLoader = uniqueKey
+
 	default:
 		var message string
 		if source.KeyPath.Namespace == "file" && ext != "" {
@@ -355,6 +508,13 @@ func parseFile(args parseArgs) {
 		return
 	}
 
+	if args.options.RewriteImports && args.options.Mode != config.ModeBundle && absResolveDir != "" {
+		// Clone the import records because the parse result may be cached
+		recordsPtr := result.file.inputFile.Repr.ImportRecords()
+		*recordsPtr = append([]ast.ImportRecord{}, *recordsPtr...)
+		result.resolveResults = rewriteRelativeImportPaths(&args, &source, absResolveDir, *recordsPtr)
+	}
+
 	// Run the resolver on the parse thread so it's not run on the main thread.
 	// That way the main thread isn't blocked if the resolver takes a while.
 	if args.options.Mode == config.ModeBundle && !args.skipResolve {
@@ -406,6 +566,7 @@ func parseFile(args parseArgs) {
 					record.Kind,
 					absResolveDir,
 					pluginData,
+					args.options.StrictCase,
 				)
 				cache[record.Path.Text] = resolveResult
 
@@ -481,8 +642,15 @@ func parseFile(args parseArgs) {
 		}
 	}
 
-	// Attempt to parse the source map if present
-	if loader.CanHaveSourceMap() && args.options.SourceMap != config.SourceMapNone {
+	// Attempt to parse the source map if present. A source map from a transform
+	// plugin takes precedence over a source map comment in the file.
+	if loader.CanHaveSourceMap() && args.options.SourceMap != config.SourceMapNone && transformSourceMap != nil {
+		result.file.inputFile.InputSourceMap = js_parser.ParseSourceMap(args.log, logger.Source{
+			KeyPath:    logger.Path{Text: source.PrettyPath, IgnoredSuffix: "#onTransform"},
+			PrettyPath: source.PrettyPath,
+			Contents:   *transformSourceMap,
+		})
+	} else if loader.CanHaveSourceMap() && args.options.SourceMap != config.SourceMapNone {
 		var sourceMapComment logger.Span
 		switch repr := result.file.inputFile.Repr.(type) {
 		case *graph.JSRepr:
@@ -680,6 +848,7 @@ func runOnResolvePlugins(
 	kind ast.ImportKind,
 	absResolveDir string,
 	pluginData interface{},
+	strictCase bool,
 ) (*resolver.ResolveResult, bool, resolver.DebugMeta) {
 	resolverArgs := config.OnResolveArgs{
 		Path:       path,
@@ -767,6 +936,8 @@ func runOnResolvePlugins(
 				PathPair:               resolver.PathPair{Primary: result.Path},
 				IsExternal:             result.External,
 				PluginData:             result.PluginData,
+				PluginName:             pluginName,
+				PluginSpecifier:        path,
 				PrimarySideEffectsData: sideEffectsData,
 			}, false, resolver.DebugMeta{}
 		}
@@ -777,14 +948,31 @@ func runOnResolvePlugins(
 	// can also configure a custom resolve directory for files in other namespaces.
 	result, debug := res.Resolve(absResolveDir, path, kind)
 
-	// Warn when the case used for importing differs from the actual file name
-	if result != nil && result.DifferentCase != nil && !helpers.IsInsideNodeModules(absResolveDir) {
-		diffCase := *result.DifferentCase
-		log.Add(logger.Warning, &tracker, importPathRange, fmt.Sprintf(
-			"Use %q instead of %q to avoid issues with case-sensitive file systems",
-			res.PrettyPath(logger.Path{Text: fs.Join(diffCase.Dir, diffCase.Actual), Namespace: "file"}),
-			res.PrettyPath(logger.Path{Text: fs.Join(diffCase.Dir, diffCase.Query), Namespace: "file"}),
-		))
+	// Warn when the case used for importing differs from the actual file name.
+	// This is an error instead if the build should fail on case-sensitive file
+	// systems, which is where these paths will stop working.
+	if result != nil && !helpers.IsInsideNodeModules(absResolveDir) {
+		kind := logger.Warning
+		if strictCase {
+			kind = logger.Error
+		}
+		if diffCase := result.DifferentCase; diffCase != nil {
+			log.Add(kind, &tracker, importPathRange, fmt.Sprintf(
+				"Use %q instead of %q to avoid issues with case-sensitive file systems",
+				res.PrettyPath(logger.Path{Text: fs.Join(diffCase.Dir, diffCase.Actual), Namespace: "file"}),
+				res.PrettyPath(logger.Path{Text: fs.Join(diffCase.Dir, diffCase.Query), Namespace: "file"}),
+			))
+		} else if primary := result.PathPair.Primary; primary.Namespace == "file" && !result.IsExternal {
+			// The resolver only checks the case of the last path component, so also
+			// check the directories that the import path passes through
+			if actual, ok := fs.ActualCasingBelowDir(absResolveDir, primary.Text); ok && actual != primary.Text {
+				log.Add(kind, &tracker, importPathRange, fmt.Sprintf(
+					"Use %q instead of %q to avoid issues with case-sensitive file systems",
+					res.PrettyPath(logger.Path{Text: actual, Namespace: "file"}),
+					res.PrettyPath(primary),
+				))
+			}
+		}
 	}
 
 	return result, false, debug
@@ -795,6 +983,11 @@ type loaderPluginResult struct {
 	absResolveDir string
 	pluginName    string
 	pluginData    interface{}
+
+	// This is only set for the "file" loader, in which case the contents are
+	// copied from this path later on instead of being loaded into memory now
+	contentsAbsPath string
+	contentsLen     int
 }
 
 func runOnLoadPlugins(
@@ -808,6 +1001,7 @@ func runOnLoadPlugins(
 	importPathRange logger.Range,
 	pluginData interface{},
 	isWatchMode bool,
+	extensionToLoader map[string]config.Loader,
 ) (loaderPluginResult, bool) {
 	loaderArgs := config.OnLoadArgs{
 		Path:       source.KeyPath,
@@ -848,15 +1042,35 @@ func runOnLoadPlugins(
 			}
 
 			// Otherwise, continue on to the next loader if this loader didn't succeed
-			if result.Contents == nil {
+			if result.Contents == nil && result.AbsContentsFile == "" {
 				continue
 			}
 
-			source.Contents = *result.Contents
 			loader := result.Loader
 			if loader == config.LoaderNone {
 				loader = config.LoaderJS
 			}
+
+			// Contents loaded from a file are only read if the loader needs them
+			var contentsAbsPath string
+			var contentsLen int
+			if result.Contents != nil {
+				source.Contents = *result.Contents
+			} else {
+				var err error
+				if loader == config.LoaderFile || loader == config.LoaderCopy {
+					if contentsLen, err, _ = fileLen(fs, result.AbsContentsFile); err == nil {
+						contentsAbsPath = result.AbsContentsFile
+					}
+				} else {
+					source.Contents, err, _ = fsCache.ReadFile(fs, result.AbsContentsFile)
+				}
+				if err != nil {
+					log.Add(logger.Error, &tracker, importPathRange,
+						fmt.Sprintf("Cannot read file %q from plugin %q: %s", result.AbsContentsFile, pluginName, err.Error()))
+					return loaderPluginResult{}, false
+				}
+			}
 			if result.AbsResolveDir == "" && source.KeyPath.Namespace == "file" {
 				result.AbsResolveDir = fs.Dir(source.KeyPath.Text)
 			}
@@ -864,10 +1078,12 @@ func runOnLoadPlugins(
 				fsCache.ReadFile(fs, source.KeyPath.Text) // Read the file for watch mode tracking
 			}
 			return loaderPluginResult{
-				loader:        loader,
-				absResolveDir: result.AbsResolveDir,
-				pluginName:    pluginName,
-				pluginData:    result.PluginData,
+				loader:          loader,
+				absResolveDir:   result.AbsResolveDir,
+				pluginName:      pluginName,
+				pluginData:      result.PluginData,
+				contentsAbsPath: contentsAbsPath,
+				contentsLen:     contentsLen,
 			}, true
 		}
 	}
@@ -879,6 +1095,21 @@ func runOnLoadPlugins(
 
 	// Read normal modules from disk
 	if source.KeyPath.Namespace == "file" {
+		// Files that use the "file" or "copy" loaders are copied later without being read
+		if loader := loaderFromFileExtension(extensionToLoader, fs.Base(source.KeyPath.Text)); loader == config.LoaderFile || loader == config.LoaderCopy {
+			if n, err, _ := fileLen(fs, source.KeyPath.Text); err == nil {
+				if isWatchMode {
+					fsCache.ReadFile(fs, source.KeyPath.Text) // Read the file for watch mode tracking
+				}
+				return loaderPluginResult{
+					loader:          loader,
+					absResolveDir:   fs.Dir(source.KeyPath.Text),
+					contentsAbsPath: source.KeyPath.Text,
+					contentsLen:     n,
+				}, true
+			}
+		}
+
 		if contents, err, originalError := fsCache.ReadFile(fs, source.KeyPath.Text); err == nil {
 			source.Contents = contents
 			return loaderPluginResult{
@@ -925,10 +1156,117 @@ func runOnLoadPlugins(
 		}
 	}
 
+	// Browser versions of node's built-in modules
+	if source.KeyPath.Namespace == runtime.NodePolyfillNamespace {
+		if contents, ok := runtime.NodePolyfills[source.KeyPath.Text]; ok {
+			source.Contents = contents
+			return loaderPluginResult{loader: config.LoaderJS}, true
+		}
+		if contents, ok := runtime.NodePolyfillGlobals[source.KeyPath.Text]; ok {
+			source.Contents = contents
+			return loaderPluginResult{loader: config.LoaderJS}, true
+		}
+	}
+
 	// Otherwise, fail to load the path
 	return loaderPluginResult{loader: config.LoaderNone}, true
 }
 
+func runOnTransformPlugins(
+	plugins []config.Plugin,
+	res resolver.Resolver,
+	fs fs.FS,
+	fsCache *cache.FSCache,
+	log logger.Log,
+	source *logger.Source,
+	importSource *logger.Source,
+	importPathRange logger.Range,
+	pluginData interface{},
+	loader config.Loader,
+) (*string, bool) {
+	transformArgs := config.OnTransformArgs{
+		Path:       source.KeyPath,
+		PluginData: pluginData,
+		Loader:     loader,
+	}
+	var sourceMap *string
+
+	// Unlike loader plugins, every matching transform plugin is applied. Each
+	// one is given the contents returned by the previous one.
+	for _, plugin := range plugins {
+		for _, onTransform := range plugin.OnTransform {
+			if !config.PluginAppliesToPath(source.KeyPath, onTransform.Filter, onTransform.Namespace) {
+				continue
+			}
+
+			transformArgs.Contents = source.Contents
+			transformArgs.SourceMap = sourceMap
+			result := onTransform.Callback(transformArgs)
+			pluginName := result.PluginName
+			if pluginName == "" {
+				pluginName = plugin.Name
+			}
+			didLogError := logPluginMessages(res, log, pluginName, result.Msgs, result.ThrownError, importSource, importPathRange)
+
+			// Plugins can also provide additional file system paths to watch
+			for _, file := range result.AbsWatchFiles {
+				fsCache.ReadFile(fs, file)
+			}
+			for _, dir := range result.AbsWatchDirs {
+				if entries, err, _ := fs.ReadDirectory(dir); err == nil {
+					entries.SortedKeys()
+				}
+			}
+
+			// Stop now if there was an error
+			if didLogError {
+				return nil, false
+			}
+
+			// New contents without a source map make the previous source map invalid
+			if result.Contents != nil {
+				source.Contents = *result.Contents
+				sourceMap = result.SourceMap
+			}
+		}
+	}
+
+	return sourceMap, true
+}
+
+// Every matching source map plugin is applied in order. Each one is given the
+// source map returned by the previous one. Errors are logged and stop the
+// remaining callbacks from running, but the source map is still returned.
+func runOnSourceMapPlugins(
+	plugins []config.Plugin,
+	res resolver.Resolver,
+	log logger.Log,
+	args config.OnSourceMapArgs,
+) []byte {
+	path := logger.Path{Text: args.Path, Namespace: "file"}
+	for _, plugin := range plugins {
+		for _, onSourceMap := range plugin.OnSourceMap {
+			if !config.PluginAppliesToPath(path, onSourceMap.Filter, "") {
+				continue
+			}
+
+			result := onSourceMap.Callback(args)
+			pluginName := result.PluginName
+			if pluginName == "" {
+				pluginName = plugin.Name
+			}
+			if logPluginMessages(res, log, pluginName, result.Msgs, result.ThrownError, nil, logger.Range{}) {
+				return []byte(args.SourceMap)
+			}
+
+			if result.SourceMap != nil {
+				args.SourceMap = *result.SourceMap
+			}
+		}
+	}
+	return []byte(args.SourceMap)
+}
+
 func loaderFromFileExtension(extensionToLoader map[string]config.Loader, base string) config.Loader {
 	// Pick the loader with the longest matching extension. So if there's an
 	// extension for ".css" and for ".module.css", we want to match the one for
@@ -974,6 +1312,10 @@ type scanner struct {
 	visited       map[logger.Path]uint32
 	resultChannel chan parseResult
 	remaining     int
+
+	// Files that are compiled because an entry point depends on them when
+	// "PreserveModules" is enabled
+	preservedModules []graph.EntryPoint
 }
 
 type EntryPoint struct {
@@ -1001,22 +1343,52 @@ func ScanBundle(
 	entryPoints []EntryPoint,
 	options config.Options,
 	timer *helpers.Timer,
+) Bundle {
+	if options.DualPackage {
+		cjsOptions, esmOptions := dualPackageOptions(options)
+		bundle := scanBundle(log, fs, res, caches, entryPoints, cjsOptions, timer, true /* runOnStart */)
+		if !log.HasErrors() {
+			esmBundle := scanBundle(log, fs, res, caches, entryPoints, esmOptions, timer, false /* runOnStart */)
+			bundle.esmBundle = &esmBundle
+		}
+		return bundle
+	}
+	if len(options.OutputFormats) > 0 {
+		return scanMultipleFormats(log, fs, res, caches, entryPoints, options, timer)
+	}
+	return scanBundle(log, fs, res, caches, entryPoints, options, timer, true /* runOnStart */)
+}
+
+func scanBundle(
+	log logger.Log,
+	fs fs.FS,
+	res resolver.Resolver,
+	caches *cache.CacheSet,
+	entryPoints []EntryPoint,
+	options config.Options,
+	timer *helpers.Timer,
+	runOnStart bool,
 ) Bundle {
 	timer.Begin("Scan phase")
 	defer timer.End("Scan phase")
 
 	applyOptionDefaults(&options)
 
-	// Run "onStart" plugins in parallel
+	// Run "onStart" plugins in parallel. These only run once per build, even
+	// when both halves of a dual package are scanned. They must all finish
+	// before any "onResolve" or "onLoad" callbacks run so that plugins can use
+	// them to reset any state that they keep between builds.
 	onStartWaitGroup := sync.WaitGroup{}
-	for _, plugin := range options.Plugins {
-		for _, onStart := range plugin.OnStart {
-			onStartWaitGroup.Add(1)
-			go func(plugin config.Plugin, onStart config.OnStart) {
-				result := onStart.Callback()
-				logPluginMessages(res, log, plugin.Name, result.Msgs, result.ThrownError, nil, logger.Range{})
-				onStartWaitGroup.Done()
-			}(plugin, onStart)
+	if runOnStart {
+		for _, plugin := range options.Plugins {
+			for _, onStart := range plugin.OnStart {
+				onStartWaitGroup.Add(1)
+				go func(plugin config.Plugin, onStart config.OnStart) {
+					result := onStart.Callback()
+					logPluginMessages(res, log, plugin.Name, result.Msgs, result.ThrownError, nil, logger.Range{})
+					onStartWaitGroup.Done()
+				}(plugin, onStart)
+			}
 		}
 	}
 
@@ -1055,12 +1427,13 @@ func ScanBundle(
 		}
 	}()
 
+	onStartWaitGroup.Wait()
 	s.preprocessInjectedFiles()
 	entryPointMeta := s.addEntryPoints(entryPoints)
 	s.scanAllDependencies()
-	files := s.processScannedFiles()
+	entryPointMeta = append(entryPointMeta, s.sortedPreservedModules()...)
+	files := s.processScannedFiles(entryPointMeta)
 
-	onStartWaitGroup.Wait()
 	return Bundle{
 		fs:              fs,
 		res:             res,
@@ -1115,6 +1488,12 @@ func (s *scanner) maybeParseFile(
 	if len(resolveResult.JSXFragment) > 0 {
 		optionsClone.JSX.Fragment = config.JSXExpr{Parts: resolveResult.JSXFragment}
 	}
+	if resolveResult.JSXAutomaticRuntime && !optionsClone.JSX.Preserve {
+		optionsClone.JSX.AutomaticRuntime = true
+	}
+	if resolveResult.JSXImportSource != "" {
+		optionsClone.JSX.ImportSource = resolveResult.JSXImportSource
+	}
 	if resolveResult.UseDefineForClassFieldsTS != config.Unspecified {
 		optionsClone.UseDefineForClassFields = resolveResult.UseDefineForClassFieldsTS
 	}
@@ -1159,6 +1538,9 @@ func (s *scanner) maybeParseFile(
 		sideEffects.Kind = graph.NoSideEffects_PackageJSON
 		sideEffects.Data = resolveResult.PrimarySideEffectsData
 	}
+	if path.Namespace == runtime.NodePolyfillNamespace {
+		sideEffects.Kind = graph.NoSideEffects_NodePolyfill
+	}
 
 	go parseFile(parseArgs{
 		fs:              s.fs,
@@ -1172,6 +1554,8 @@ func (s *scanner) maybeParseFile(
 		sideEffects:     sideEffects,
 		importPathRange: importPathRange,
 		pluginData:      pluginData,
+		pluginName:      resolveResult.PluginName,
+		specifier:       resolveResult.PluginSpecifier,
 		options:         optionsClone,
 		results:         s.resultChannel,
 		inject:          inject,
@@ -1254,7 +1638,16 @@ func (s *scanner) preprocessInjectedFiles() {
 		go func() { s.resultChannel <- result }()
 	}
 
-	results := make([]config.InjectedFile, len(s.options.InjectAbsPaths))
+	// The "process" and "Buffer" globals are replaced with the node polyfills
+	var nodePolyfillGlobals []string
+	if s.options.NodePolyfills && s.options.Platform == config.PlatformBrowser {
+		for name := range runtime.NodePolyfillGlobals {
+			nodePolyfillGlobals = append(nodePolyfillGlobals, name)
+		}
+		sort.Strings(nodePolyfillGlobals) // Sort for determinism
+	}
+
+	results := make([]config.InjectedFile, len(s.options.InjectAbsPaths)+len(nodePolyfillGlobals))
 	j := 0
 	for _, absPath := range s.options.InjectAbsPaths {
 		prettyPath := s.res.PrettyPath(logger.Path{Text: absPath, Namespace: "file"})
@@ -1286,6 +1679,19 @@ func (s *scanner) preprocessInjectedFiles() {
 		j++
 	}
 
+	for _, name := range nodePolyfillGlobals {
+		path := logger.Path{Text: name, Namespace: runtime.NodePolyfillNamespace}
+		resolveResult := resolver.ResolveResult{PathPair: resolver.PathPair{Primary: path}}
+		channel := make(chan config.InjectedFile)
+		s.maybeParseFile(resolveResult, s.res.PrettyPath(path), nil, logger.Range{}, nil, inputKindNormal, channel)
+		injectWaitGroup.Add(1)
+		go func(i int) {
+			results[i] = <-channel
+			injectWaitGroup.Done()
+		}(j)
+		j++
+	}
+
 	injectWaitGroup.Wait()
 	injectedFiles = append(injectedFiles, results[:j]...)
 
@@ -1381,6 +1787,7 @@ func (s *scanner) addEntryPoints(entryPoints []EntryPoint) []graph.EntryPoint {
 				ast.ImportEntryPoint,
 				entryPointAbsResolveDir,
 				nil,
+				s.options.StrictCase,
 			)
 			if resolveResult != nil {
 				if resolveResult.IsExternal {
@@ -1558,9 +1965,26 @@ func (s *scanner) scanAllDependencies() {
 	defer s.timer.End("Scan all dependencies")
 
 	// Continue scanning until all dependencies have been discovered
+	filesParsed := 0
+	isRuntimeParsed := false
 	for s.remaining > 0 {
 		result := <-s.resultChannel
 		s.remaining--
+
+		// Report progress, but don't count the runtime file
+		if result.file.inputFile.Source.Index == runtime.SourceIndex {
+			isRuntimeParsed = true
+		} else {
+			filesParsed++
+		}
+		if s.options.OnScanProgress != nil {
+			filesDiscovered := filesParsed + s.remaining
+			if !isRuntimeParsed {
+				filesDiscovered--
+			}
+			s.options.OnScanProgress(filesParsed, filesDiscovered)
+		}
+
 		if !result.ok {
 			continue
 		}
@@ -1583,6 +2007,14 @@ func (s *scanner) scanAllDependencies() {
 					sourceIndex := s.maybeParseFile(*resolveResult, s.res.PrettyPath(path),
 						&result.file.inputFile.Source, record.Range, resolveResult.PluginData, inputKindNormal, nil)
 					record.SourceIndex = ast.MakeIndex32(sourceIndex)
+				} else if _, ok := s.options.ExternalGlobals[record.Path.Text]; ok && s.options.OutputFormat != config.FormatESModule {
+					// Imports that are read from a global variable keep the path as
+					// written since it's used to look up the variable when printing
+					record.IsExternalGlobal = true
+				} else if rewritten, ok := rewriteExternalPath(s.options.ExternalRewrites, record.Path.Text); ok {
+					// Rewrite rules match the import path as it was written, and the
+					// replacement is used in the output as-is
+					record.Path = logger.Path{Text: rewritten}
 				} else {
 					// If the path to the external module is relative to the source
 					// file, rewrite the path to be relative to the working directory
@@ -1604,14 +2036,55 @@ func (s *scanner) scanAllDependencies() {
 			}
 		}
 
+		if s.options.PreserveModules {
+			s.addPreservedModules(&result)
+		}
+
 		s.results[result.file.inputFile.Source.Index] = result
 	}
 }
 
-func (s *scanner) processScannedFiles() []scannerFile {
+func rewriteExternalPath(rewrites []config.ExternalRewrite, importPath string) (string, bool) {
+	for _, rewrite := range rewrites {
+		if !rewrite.IsWildcard {
+			if importPath == rewrite.Pattern.Prefix {
+				return rewrite.Replacement, true
+			}
+			continue
+		}
+		prefix, suffix := rewrite.Pattern.Prefix, rewrite.Pattern.Suffix
+		if len(importPath) >= len(prefix)+len(suffix) && strings.HasPrefix(importPath, prefix) && strings.HasSuffix(importPath, suffix) {
+			wildcard := importPath[len(prefix) : len(importPath)-len(suffix)]
+			return strings.ReplaceAll(rewrite.Replacement, "*", wildcard), true
+		}
+	}
+	return "", false
+}
+
+func (s *scanner) processScannedFiles(entryPointMeta []graph.EntryPoint) []scannerFile {
 	s.timer.Begin("Process scanned files")
 	defer s.timer.End("Process scanned files")
 
+	// File paths are compared case-insensitively, so paths that differ only in
+	// case are bundled as the same file. Remember the first import of each path
+	// to point to it when this happens.
+	type firstImportOfPath struct {
+		text         string
+		importSource *logger.Source
+		importRange  logger.Range
+	}
+	firstImportOfLowerCasePath := make(map[string]firstImportOfPath)
+	reportedDifferentCase := make(map[string]bool)
+
+	// Files that use the "local-css" loader need their local names to be
+	// renamed before their JavaScript stubs can be generated
+	localCSSExports := s.renameLocalCSSNames()
+
+	isEntryPoint := make(map[uint32]bool, len(entryPointMeta))
+	for _, entryPoint := range entryPointMeta {
+		isEntryPoint[entryPoint.SourceIndex] = true
+	}
+
 	// Now that all files have been scanned, process the final file import records
 	for i, result := range s.results {
 		if !result.ok {
@@ -1624,7 +2097,11 @@ func (s *scanner) processScannedFiles() []scannerFile {
 		// Begin the metadata chunk
 		if s.options.NeedsMetafile {
 			sb.Write(js_printer.QuoteForJSON(result.file.inputFile.Source.PrettyPath, s.options.ASCIIOnly))
-			sb.WriteString(fmt.Sprintf(": {\n      \"bytes\": %d,\n      \"imports\": [", len(result.file.inputFile.Source.Contents)))
+			n := len(result.file.inputFile.Source.Contents)
+			if result.file.inputFile.ContentsAbsPath != "" {
+				n = result.file.inputFile.ContentsLen
+			}
+			sb.WriteString(fmt.Sprintf(": {\n      \"bytes\": %d,\n      \"imports\": [", n))
 		}
 
 		// Don't try to resolve paths if we're not bundling
@@ -1641,6 +2118,32 @@ func (s *scanner) processScannedFiles() []scannerFile {
 					continue
 				}
 
+				// Report files that are imported using paths that differ only in case
+				if primary := resolveResult.PathPair.Primary; primary.Namespace == "file" {
+					key := strings.ToLower(primary.Text)
+					if first, ok := firstImportOfLowerCasePath[key]; !ok {
+						firstImportOfLowerCasePath[key] = firstImportOfPath{
+							text:         primary.Text,
+							importSource: &result.file.inputFile.Source,
+							importRange:  record.Range,
+						}
+					} else if first.text != primary.Text && !reportedDifferentCase[primary.Text] {
+						reportedDifferentCase[primary.Text] = true
+						kind := logger.Warning
+						if s.options.StrictCase {
+							kind = logger.Error
+						}
+						firstTracker := logger.MakeLineColumnTracker(first.importSource)
+						s.log.AddWithNotes(kind, &tracker, record.Range, fmt.Sprintf("The paths %q and %q differ only in case",
+							s.res.PrettyPath(logger.Path{Text: first.text, Namespace: "file"}), s.res.PrettyPath(primary)),
+							[]logger.MsgData{
+								firstTracker.MsgData(first.importRange, "The other path was imported here:"),
+								{Text: "Both paths are bundled as the same file, which is only correct on case-insensitive file systems. " +
+									"On case-sensitive file systems, these paths don't refer to the same file."},
+							})
+					}
+				}
+
 				// Now that all files have been scanned, look for packages that are imported
 				// both with "import" and "require". Rewrite any imports that reference the
 				// "module" package.json field to the "main" package.json field instead.
@@ -1674,7 +2177,7 @@ func (s *scanner) processScannedFiles() []scannerFile {
 				}
 
 				switch record.Kind {
-				case ast.ImportAt, ast.ImportAtConditional:
+				case ast.ImportAt, ast.ImportAtConditional, ast.ImportComposesFrom:
 					// Using a JavaScript file with CSS "@import" is not allowed
 					otherFile := &s.results[record.SourceIndex.GetIndex()].file
 					if _, ok := otherFile.inputFile.Repr.(*graph.JSRepr); ok {
@@ -1716,6 +2219,10 @@ func (s *scanner) processScannedFiles() []scannerFile {
 								stubKey.Text = canonicalFileSystemPathForWindows(stubKey.Text)
 							}
 							sourceIndex := s.allocateSourceIndex(stubKey, cache.SourceIndexJSStubForCSS)
+							exports, ok := localCSSExports[record.SourceIndex.GetIndex()]
+							if !ok {
+								exports = js_ast.Expr{Data: &js_ast.EObject{}}
+							}
 							source := logger.Source{
 								Index:      sourceIndex,
 								PrettyPath: otherFile.inputFile.Source.PrettyPath,
@@ -1726,7 +2233,7 @@ func (s *scanner) processScannedFiles() []scannerFile {
 										Source: source,
 										Repr: &graph.JSRepr{
 											AST: js_parser.LazyExportAST(s.log, source,
-												js_parser.OptionsFromConfig(&s.options), js_ast.Expr{Data: &js_ast.EObject{}}, ""),
+												js_parser.OptionsFromConfig(&s.options), exports, ""),
 											CSSSourceIndex: ast.MakeIndex32(record.SourceIndex.GetIndex()),
 										},
 									},
@@ -1759,6 +2266,9 @@ func (s *scanner) processScannedFiles() []scannerFile {
 						// effect.
 						otherModule.SideEffects.Kind != graph.NoSideEffects_PureData_FromPlugin &&
 
+						// Do not warn about the node polyfills since the user didn't write them
+						otherModule.SideEffects.Kind != graph.NoSideEffects_NodePolyfill &&
+
 						// Do not warn if this has no side effects because the parsed AST
 						// is empty. This is the case for ".d.ts" files, for example.
 						otherModule.SideEffects.Kind != graph.NoSideEffects_EmptyAST {
@@ -1792,68 +2302,107 @@ func (s *scanner) processScannedFiles() []scannerFile {
 			if !isFirstImport {
 				sb.WriteString("\n      ")
 			}
-			sb.WriteString("]\n    }")
+			sb.WriteString("]")
+
+			// Say where files that aren't on the file system came from. Otherwise
+			// the opaque paths of virtual modules can't be traced back to a plugin.
+			if namespace := result.file.inputFile.Source.KeyPath.Namespace; namespace != "file" && namespace != "" {
+				sb.WriteString(fmt.Sprintf(",\n      \"namespace\": %s", js_printer.QuoteForJSON(namespace, s.options.ASCIIOnly)))
+				if result.file.specifier != "" {
+					sb.WriteString(fmt.Sprintf(",\n      \"specifier\": %s", js_printer.QuoteForJSON(result.file.specifier, s.options.ASCIIOnly)))
+				}
+				if result.file.pluginName != "" {
+					sb.WriteString(fmt.Sprintf(",\n      \"plugin\": %s", js_printer.QuoteForJSON(result.file.pluginName, s.options.ASCIIOnly)))
+				}
+			}
+
+			// Report any custom pragmas found in this file
+			if repr, ok := result.file.inputFile.Repr.(*graph.JSRepr); ok && len(repr.AST.Pragmas) > 0 {
+				sb.WriteString(",\n      \"pragmas\": [")
+				for i, pragma := range repr.AST.Pragmas {
+					if i > 0 {
+						sb.WriteString(",")
+					}
+					sb.WriteString(fmt.Sprintf("\n        {\n          \"name\": %s,\n          \"value\": %s\n        }",
+						js_printer.QuoteForJSON(pragma.Name, s.options.ASCIIOnly),
+						js_printer.QuoteForJSON(pragma.Value, s.options.ASCIIOnly)))
+				}
+				sb.WriteString("\n      ]")
+			}
+
+			// Estimate the cost of evaluating files in packages, which helps with
+			// choosing packages to evaluate lazily
+			if repr, ok := result.file.inputFile.Repr.(*graph.JSRepr); ok && s.options.Mode == config.ModeBundle &&
+				helpers.IsInsideNodeModules(result.file.inputFile.Source.KeyPath.Text) {
+				if cost := evaluationCost(repr); cost > 0 {
+					sb.WriteString(fmt.Sprintf(",\n      \"evaluationCost\": %d", cost))
+				}
+			}
+
+			sb.WriteString("\n    }")
 		}
 
 		result.file.jsonMetadataChunk = sb.String()
 
-		// If this file is from the "file" loader, generate an additional file
-		if result.file.inputFile.UniqueKeyForFileLoader != "" {
-			bytes := []byte(result.file.inputFile.Source.Contents)
+		// If this file is from the "file" loader, generate an additional file.
+		// Web app manifests are generated later since they contain the final
+		// paths of other additional files.
+		if result.file.inputFile.UniqueKeyForFileLoader != "" && result.file.inputFile.Loader != config.LoaderWebManifest {
+			// Very large files may not have been loaded into memory, in which case
+			// they are streamed from their original location instead
+			var bytes []byte
+			n := result.file.inputFile.ContentsLen
+			copyFromAbsPath := result.file.inputFile.ContentsAbsPath
+			if copyFromAbsPath == "" {
+				bytes = []byte(result.file.inputFile.Source.Contents)
+				n = len(bytes)
+			}
+
+			// Entry points from the "copy" loader are named like other entry points
+			template := s.options.AssetPathTemplate
+			if result.file.inputFile.Loader == config.LoaderCopy && isEntryPoint[uint32(i)] {
+				template = s.options.EntryPathTemplate
+			}
 
 			// Add a hash to the file name to prevent multiple files with the same name
 			// but different contents from colliding
 			var hash string
-			if config.HasPlaceholder(s.options.AssetPathTemplate, config.HashPlaceholder) {
+			if s.options.AbsContentManifestFile != "" || config.HasPlaceholder(template, config.HashPlaceholder) {
 				h := xxhash.New()
-				h.Write(bytes)
+				if copyFromAbsPath == "" {
+					h.Write(bytes)
+				} else if err := streamFileContents(s.fs, copyFromAbsPath, h); err != nil {
+					s.log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot read file %q: %s",
+						result.file.inputFile.Source.PrettyPath, err.Error()))
+				}
 				hash = hashForFileName(h.Sum(nil))
 			}
 
-			// Generate the input for the template
-			_, _, originalExt := logger.PlatformIndependentPathDirBaseExt(result.file.inputFile.Source.KeyPath.Text)
-			dir, base := pathRelativeToOutbase(
-				&result.file.inputFile,
-				&s.options,
-				s.fs,
-				/* avoidIndex */ false,
-				/* customFilePath */ "",
-			)
-
-			// Apply the asset path template
-			templateExt := strings.TrimPrefix(originalExt, ".")
-			relPath := config.TemplateToString(config.SubstituteTemplate(s.options.AssetPathTemplate, config.PathPlaceholders{
-				Dir:  &dir,
-				Name: &base,
-				Hash: &hash,
-				Ext:  &templateExt,
-			})) + originalExt
-
-			// Optionally add metadata about the file
-			var jsonMetadataChunk string
-			if s.options.NeedsMetafile {
-				inputs := fmt.Sprintf("{\n        %s: {\n          \"bytesInOutput\": %d\n        }\n      }",
-					js_printer.QuoteForJSON(result.file.inputFile.Source.PrettyPath, s.options.ASCIIOnly),
-					len(bytes),
-				)
-				jsonMetadataChunk = fmt.Sprintf(
-					"{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": %s,\n      \"bytes\": %d\n    }",
-					inputs,
-					len(bytes),
-				)
-			}
-
 			// Generate the additional file to copy into the output directory
+			absPath, logicalAbsPath := s.assetOutputPath(&result.file.inputFile, template, hash)
 			result.file.inputFile.AdditionalFiles = []graph.OutputFile{{
-				AbsPath:           s.fs.Join(s.options.AbsOutputDir, relPath),
+				AbsPath:           absPath,
 				Contents:          bytes,
-				JSONMetadataChunk: jsonMetadataChunk,
+				CopyFromAbsPath:   copyFromAbsPath,
+				LogicalAbsPath:    logicalAbsPath,
+				Kind:              graph.OutputAsset,
+				InputPath:         result.file.inputFile.Source.PrettyPath,
+				JSONMetadataChunk: s.assetMetadataChunk(&result.file.inputFile, n),
+				IsHashed:          hash != "",
 			}}
 		}
 
 		s.results[i] = result
 	}
 
+	// Generate web app manifests now that the paths of all other additional
+	// files are known
+	for i := range s.results {
+		if result := &s.results[i]; result.ok && result.file.inputFile.Loader == config.LoaderWebManifest {
+			s.generateWebManifest(&result.file.inputFile)
+		}
+	}
+
 	// The linker operates on an array of files, so construct that now. This
 	// can't be constructed earlier because we generate new parse results for
 	// JavaScript stub files for CSS imports above.
@@ -1867,6 +2416,74 @@ func (s *scanner) processScannedFiles() []scannerFile {
 	return files
 }
 
+// Returns the path of an asset in the output directory. With a content
+// manifest, the asset is named by its hash alone and the path it would have
+// had otherwise is returned as the logical path.
+func (s *scanner) assetOutputPath(inputFile *graph.InputFile, template []config.PathTemplate, hash string) (absPath string, logicalAbsPath string) {
+	// Generate the input for the template
+	_, _, originalExt := logger.PlatformIndependentPathDirBaseExt(inputFile.Source.KeyPath.Text)
+	if inputFile.Loader == config.LoaderImage {
+		if transform, err := parseImageTransform(inputFile.Source.KeyPath.IgnoredSuffix); err == nil {
+			originalExt = imageOutputExt(originalExt, transform)
+		}
+	}
+	dir, base := pathRelativeToOutbase(
+		inputFile,
+		&s.options,
+		s.fs,
+		/* avoidIndex */ false,
+		/* customFilePath */ "",
+	)
+
+	// Apply the asset path template
+	templateExt := strings.TrimPrefix(originalExt, ".")
+	relPath := config.TemplateToString(config.SubstituteTemplate(template, config.PathPlaceholders{
+		Dir:  &dir,
+		Name: &base,
+		Hash: &hash,
+		Ext:  &templateExt,
+	})) + originalExt
+
+	// With a content manifest, the asset is named by its hash alone
+	if s.options.AbsContentManifestFile != "" {
+		logicalAbsPath = s.fs.Join(s.options.AbsOutputDir, relPath)
+		relPath = config.TemplateToString(config.SubstituteTemplate(contentAddressedTemplate(originalExt), config.PathPlaceholders{
+			Hash: &hash,
+		}))
+	}
+
+	absPath = s.fs.Join(s.options.AbsOutputDir, relPath)
+	return
+}
+
+// Optionally generates metadata about an asset
+func (s *scanner) assetMetadataChunk(inputFile *graph.InputFile, n int) string {
+	if !s.options.NeedsMetafile {
+		return ""
+	}
+	inputs := fmt.Sprintf("{\n        %s: {\n          \"bytesInOutput\": %d\n        }\n      }",
+		js_printer.QuoteForJSON(inputFile.Source.PrettyPath, s.options.ASCIIOnly),
+		n,
+	)
+	return fmt.Sprintf(
+		"{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": %s,\n      \"bytes\": %d\n    }",
+		inputs,
+		n,
+	)
+}
+
+// The cost of evaluating a file is estimated as the number of top-level
+// statements that must run when the file is evaluated. Statements that can be
+// removed if unused (such as function declarations) don't count.
+func evaluationCost(repr *graph.JSRepr) (cost int) {
+	for _, part := range repr.AST.Parts {
+		if !part.CanBeRemovedIfUnused {
+			cost += len(part.Stmts)
+		}
+	}
+	return
+}
+
 func (s *scanner) validateTLA(sourceIndex uint32) tlaCheck {
 	result := &s.results[sourceIndex]
 
@@ -1956,17 +2573,24 @@ func (s *scanner) validateTLA(sourceIndex uint32) tlaCheck {
 
 func DefaultExtensionToLoaderMap() map[string]config.Loader {
 	return map[string]config.Loader{
-		".js":   config.LoaderJS,
-		".mjs":  config.LoaderJS,
-		".cjs":  config.LoaderJS,
-		".jsx":  config.LoaderJSX,
-		".ts":   config.LoaderTS,
-		".cts":  config.LoaderTSNoAmbiguousLessThan,
-		".mts":  config.LoaderTSNoAmbiguousLessThan,
-		".tsx":  config.LoaderTSX,
-		".css":  config.LoaderCSS,
-		".json": config.LoaderJSON,
-		".txt":  config.LoaderText,
+		".js":          config.LoaderJS,
+		".mjs":         config.LoaderJS,
+		".cjs":         config.LoaderJS,
+		".jsx":         config.LoaderJSX,
+		".ts":          config.LoaderTS,
+		".cts":         config.LoaderTSNoAmbiguousLessThan,
+		".mts":         config.LoaderTSNoAmbiguousLessThan,
+		".tsx":         config.LoaderTSX,
+		".css":         config.LoaderCSS,
+		".module.css":  config.LoaderLocalCSS,
+		".json":        config.LoaderJSON,
+		".jsonc":       config.LoaderJSONC,
+		".json5":       config.LoaderJSON5,
+		".yaml":        config.LoaderYAML,
+		".yml":         config.LoaderYAML,
+		".toml":        config.LoaderTOML,
+		".txt":         config.LoaderText,
+		".webmanifest": config.LoaderWebManifest,
 	}
 }
 
@@ -2004,7 +2628,15 @@ func applyOptionDefaults(options *config.Options) {
 	options.ProfilerNames = !options.MinifyIdentifiers
 }
 
-func (b *Bundle) Compile(log logger.Log, options config.Options, timer *helpers.Timer) ([]graph.OutputFile, string) {
+// The name cache and the mangle cache are generated along with the output
+// files, but like the metafile they aren't output files. They're never written
+// to stdout and aren't listed in the metafile.
+type GeneratedCaches struct {
+	NameCacheJSON   string
+	MangleCacheJSON string
+}
+
+func (b *Bundle) Compile(log logger.Log, options config.Options, timer *helpers.Timer, linkCache *LinkCache) ([]graph.OutputFile, string, GeneratedCaches) {
 	timer.Begin("Compile phase")
 	defer timer.End("Compile phase")
 
@@ -2023,115 +2655,237 @@ func (b *Bundle) Compile(log logger.Log, options config.Options, timer *helpers.
 	// Get the base path from the options or choose the lowest common ancestor of all entry points
 	allReachableFiles := findReachableFiles(files, b.entryPoints)
 
+	if options.MangleProps != nil {
+		timer.Begin("Mangle properties")
+		options.MangledPropNames = computeMangledPropNames(files, allReachableFiles, options.MangleCache)
+		timer.End("Mangle properties")
+	}
+
+	if options.UnusedExports != config.UnusedExportsIgnore && options.Mode == config.ModeBundle {
+		timer.Begin("Report unused exports")
+		reportUnusedExports(log, &options, files, b.entryPoints, allReachableFiles)
+		timer.End("Report unused exports")
+	}
+
+	if options.TS.Enums != config.TSEnumsClassic {
+		reportTSEnums(log, files, allReachableFiles)
+	}
+
+	var outputFiles []graph.OutputFile
+	if b.esmBundle != nil {
+		outputFiles = b.linkDualPackage(log, options, timer, linkCache)
+	} else if len(b.formatBundles) > 0 {
+		outputFiles = b.linkMultipleFormats(log, options, timer, linkCache)
+	} else {
+		outputFiles = b.linkEntryPoints(log, options, timer, linkCache)
+	}
+
+	// Also generate the metadata file if necessary
+	var metafileJSON string
+	if options.NeedsMetafile {
+		timer.Begin("Generate metadata JSON")
+		metafileJSON = b.generateMetadataJSON(outputFiles, allReachableFiles, options.ASCIIOnly)
+		timer.End("Generate metadata JSON")
+	}
+
+	if !options.WriteToStdout {
+		outputFiles = b.handleOutputConflicts(log, &options, allReachableFiles, outputFiles)
+	}
+
+	// The content manifest maps the logical names of all output files to their
+	// content-addressed names
+	if options.AbsContentManifestFile != "" {
+		timer.Begin("Generate content manifest")
+		outputFiles = append(outputFiles, generateContentManifest(&options, b.fs, outputFiles))
+		timer.End("Generate content manifest")
+	}
+
+	// Generate the precache manifest and service worker last since they list
+	// all other output files
+	if options.AbsPrecacheManifestFile != "" || options.AbsServiceWorkerFile != "" {
+		timer.Begin("Generate precache files")
+		outputFiles = append(outputFiles, generatePrecacheFiles(&options, b.fs, outputFiles)...)
+		timer.End("Generate precache files")
+	}
+
+	// The CSP report is generated after the service worker since it includes it
+	if options.AbsCSPReportFile != "" {
+		timer.Begin("Generate CSP report")
+		outputFiles = append(outputFiles, generateCSPReport(&options, b.fs, outputFiles))
+		timer.End("Generate CSP report")
+	}
+
+	// The feature flag report and the CSS order report aren't files that get
+	// deployed, so they're not included in any of the manifests above
+	if options.AbsFeatureReportFile != "" {
+		timer.Begin("Generate feature flag report")
+		outputFiles = append(outputFiles, generateFeatureReport(&options, files, allReachableFiles))
+		timer.End("Generate feature flag report")
+	}
+	if options.AbsCSSOrderReportFile != "" {
+		timer.Begin("Generate CSS order report")
+		outputFiles = append(outputFiles, generateCSSOrderReport(&options, b.fs, outputFiles))
+		timer.End("Generate CSS order report")
+	}
+	if options.StdoutStream == config.StdoutStreamSingle && len(outputFiles) > 1 {
+		b.reportMultipleStdoutOutputs(log, outputFiles)
+	}
+
+	var caches GeneratedCaches
+	if options.AbsNameCacheFile != "" && options.NameCache != nil {
+		timer.Begin("Generate name cache")
+		caches.NameCacheJSON = generateNameCache(&options)
+		timer.End("Generate name cache")
+	}
+	if options.AbsMangleCacheFile != "" && options.MangleCache != nil {
+		timer.Begin("Generate mangle cache")
+		caches.MangleCacheJSON = generateMangleCache(&options)
+		timer.End("Generate mangle cache")
+	}
+
+	return outputFiles, metafileJSON, caches
+}
+
+func (b *Bundle) linkEntryPoints(log logger.Log, options config.Options, timer *helpers.Timer, linkCache *LinkCache) []graph.OutputFile {
+	files := make([]graph.InputFile, len(b.files))
+	for i, file := range b.files {
+		files[i] = file.inputFile
+	}
+
+	// Entry points that use the "copy" loader are copied to the output
+	// directory without being linked
+	var copiedFiles []graph.OutputFile
+	entryPoints := make([]graph.EntryPoint, 0, len(b.entryPoints))
+	for _, entryPoint := range b.entryPoints {
+		if file := &files[entryPoint.SourceIndex]; file.Loader == config.LoaderCopy {
+			copiedFiles = append(copiedFiles, file.AdditionalFiles...)
+		} else {
+			entryPoints = append(entryPoints, entryPoint)
+		}
+	}
+	if len(entryPoints) == 0 {
+		return copiedFiles
+	}
+
+	allReachableFiles := findReachableFiles(files, entryPoints)
+
 	// Compute source map data in parallel with linking
 	timer.Begin("Spawn source map tasks")
 	dataForSourceMaps := b.computeDataForSourceMapsInParallel(&options, allReachableFiles)
 	timer.End("Spawn source map tasks")
 
+	// Report progress as entry points are linked
+	progressMutex := sync.Mutex{}
+	entryPointsLinked := 0
+	reportLinkProgress := func(newlyLinked int) {
+		if options.OnLinkProgress != nil {
+			progressMutex.Lock()
+			defer progressMutex.Unlock()
+			entryPointsLinked += newlyLinked
+			options.OnLinkProgress(entryPointsLinked, len(entryPoints))
+		}
+	}
+	reportLinkProgress(0)
+
 	var resultGroups [][]graph.OutputFile
-	if options.CodeSplitting || len(b.entryPoints) == 1 {
+	var chunkPaths *chunkPathReservations
+	if options.CodeSplitting || len(entryPoints) == 1 {
 		// If code splitting is enabled or if there's only one entry point, link all entry points together
+		if options.OnConflict == config.OnConflictRename {
+			chunkPaths = newChunkPathReservations(1)
+		}
 		resultGroups = [][]graph.OutputFile{link(
-			&options, timer, log, b.fs, b.res, files, b.entryPoints, b.uniqueKeyPrefix, allReachableFiles, dataForSourceMaps)}
+			&options, timer, log, b.fs, b.res, files, entryPoints, b.uniqueKeyPrefix, allReachableFiles, dataForSourceMaps, chunkPaths, 0)}
+		reportLinkProgress(len(entryPoints))
 	} else {
+		if options.OnConflict == config.OnConflictRename {
+			chunkPaths = newChunkPathReservations(len(entryPoints))
+		}
 		// Otherwise, link each entry point with the runtime file separately
 		waitGroup := sync.WaitGroup{}
-		resultGroups = make([][]graph.OutputFile, len(b.entryPoints))
-		for i, entryPoint := range b.entryPoints {
+		resultGroups = make([][]graph.OutputFile, len(entryPoints))
+		fingerprints := make([]uint64, len(entryPoints))
+		wasReused := make([]bool, len(entryPoints))
+		var optionsHash uint64
+		if linkCache != nil {
+			optionsHash = linkCacheOptionsHash(&options)
+		}
+		for i, entryPoint := range entryPoints {
 			waitGroup.Add(1)
 			go func(i int, entryPoint graph.EntryPoint) {
 				entryPoints := []graph.EntryPoint{entryPoint}
-				forked := timer.Fork()
 				reachableFiles := findReachableFiles(files, entryPoints)
+
+				// Don't bother re-linking this entry point if none of the files that
+				// it can reach have changed since the previous build
+				if linkCache != nil {
+					fingerprints[i] = linkCacheFingerprint(files, reachableFiles, options.MangledPropNames, optionsHash)
+					if outputFiles, ok := linkCache.get(makeLinkCacheKey(files, entryPoint, options.OutputFormat), fingerprints[i]); ok {
+						group := make([]graph.OutputFile, len(outputFiles))
+						for j, outputFile := range outputFiles {
+							// Copied files are always written again because their contents
+							// aren't part of the fingerprint
+							outputFile.IsReused = outputFile.CopyFromAbsPath == ""
+							group[j] = outputFile
+						}
+						resultGroups[i] = group
+						wasReused[i] = true
+						if chunkPaths != nil {
+							chunkPaths.waitForTurn(i)
+							chunkPaths.reserveOutputFiles(group)
+							chunkPaths.endTurn(i)
+						}
+						reportLinkProgress(1)
+						waitGroup.Done()
+						return
+					}
+				}
+
+				forked := timer.Fork()
 				resultGroups[i] = link(
-					&options, forked, log, b.fs, b.res, files, entryPoints, b.uniqueKeyPrefix, reachableFiles, dataForSourceMaps)
+					&options, forked, log, b.fs, b.res, files, entryPoints, b.uniqueKeyPrefix, reachableFiles, dataForSourceMaps, chunkPaths, i)
+				if chunkPaths != nil {
+					chunkPaths.endTurn(i)
+				}
 				timer.Join(forked)
+				reportLinkProgress(1)
 				waitGroup.Done()
 			}(i, entryPoint)
 		}
 		waitGroup.Wait()
-	}
 
-	// Join the results in entry point order for determinism
-	var outputFiles []graph.OutputFile
-	for _, group := range resultGroups {
-		outputFiles = append(outputFiles, group...)
-	}
-
-	// Also generate the metadata file if necessary
-	var metafileJSON string
-	if options.NeedsMetafile {
-		timer.Begin("Generate metadata JSON")
-		metafileJSON = b.generateMetadataJSON(outputFiles, allReachableFiles, options.ASCIIOnly)
-		timer.End("Generate metadata JSON")
-	}
-
-	if !options.WriteToStdout {
-		// Make sure an output file never overwrites an input file
-		if !options.AllowOverwrite {
-			sourceAbsPaths := make(map[string]uint32)
-			for _, sourceIndex := range allReachableFiles {
-				keyPath := b.files[sourceIndex].inputFile.Source.KeyPath
-				if keyPath.Namespace == "file" {
-					absPathKey := canonicalFileSystemPathForWindows(keyPath.Text)
-					sourceAbsPaths[absPathKey] = sourceIndex
-				}
-			}
-			for _, outputFile := range outputFiles {
-				absPathKey := canonicalFileSystemPathForWindows(outputFile.AbsPath)
-				if sourceIndex, ok := sourceAbsPaths[absPathKey]; ok {
-					hint := ""
-					switch logger.API {
-					case logger.CLIAPI:
-						hint = " (use \"--allow-overwrite\" to allow this)"
-					case logger.JSAPI:
-						hint = " (use \"allowOverwrite: true\" to allow this)"
-					case logger.GoAPI:
-						hint = " (use \"AllowOverwrite: true\" to allow this)"
+		if linkCache != nil {
+			// Only remember the results of successful builds. Otherwise the cached
+			// output files may not match the files that were last written out.
+			if !log.HasErrors() {
+				for i, entryPoint := range entryPoints {
+					if !wasReused[i] {
+						linkCache.set(makeLinkCacheKey(files, entryPoint, options.OutputFormat), fingerprints[i], resultGroups[i])
 					}
-					log.Add(logger.Error, nil, logger.Range{},
-						fmt.Sprintf("Refusing to overwrite input file %q%s",
-							b.files[sourceIndex].inputFile.Source.PrettyPath, hint))
 				}
 			}
-		}
-
-		// Make sure an output file never overwrites another output file. This
-		// is almost certainly unintentional and would otherwise happen silently.
-		//
-		// Make an exception for files that have identical contents. In that case
-		// the duplicate is just silently filtered out. This can happen with the
-		// "file" loader, for example.
-		outputFileMap := make(map[string][]byte)
-		end := 0
-		for _, outputFile := range outputFiles {
-			absPathKey := canonicalFileSystemPathForWindows(outputFile.AbsPath)
-			contents, ok := outputFileMap[absPathKey]
-
-			// If this isn't a duplicate, keep the output file
-			if !ok {
-				outputFileMap[absPathKey] = outputFile.Contents
-				outputFiles[end] = outputFile
-				end++
-				continue
-			}
 
-			// If the names and contents are both the same, only keep the first one
-			if bytes.Equal(contents, outputFile.Contents) {
-				continue
+			// Report which entry points were skipped
+			var notes []logger.MsgData
+			for i, entryPoint := range entryPoints {
+				if wasReused[i] {
+					notes = append(notes, logger.MsgData{Text: b.files[entryPoint.SourceIndex].inputFile.Source.PrettyPath})
+				}
 			}
-
-			// Otherwise, generate an error
-			outputPath := outputFile.AbsPath
-			if relPath, ok := b.fs.Rel(b.fs.Cwd(), outputPath); ok {
-				outputPath = relPath
+			if len(notes) > 0 {
+				log.AddWithNotes(logger.Info, nil, logger.Range{}, fmt.Sprintf(
+					"Skipped re-linking %d of %d entry points because none of their input files changed",
+					len(notes), len(entryPoints)), notes)
 			}
-			log.Add(logger.Error, nil, logger.Range{}, "Two output files share the same path but have different contents: "+outputPath)
 		}
-		outputFiles = outputFiles[:end]
 	}
 
-	return outputFiles, metafileJSON
+	// Join the results in entry point order for determinism
+	var outputFiles []graph.OutputFile
+	for _, group := range resultGroups {
+		outputFiles = append(outputFiles, group...)
+	}
+	return append(outputFiles, copiedFiles...)
 }
 
 // Find all files reachable from all entry points. This order should be
@@ -2297,6 +3051,7 @@ type runtimeCacheKey struct {
 	MangleSyntax      bool
 	MinifyIdentifiers bool
 	ES6               bool
+	LoadScript        bool
 }
 
 type runtimeCache struct {
@@ -2312,12 +3067,18 @@ func (cache *runtimeCache) parseRuntime(options *config.Options) (source logger.
 		MangleSyntax:      options.MangleSyntax,
 		MinifyIdentifiers: options.MinifyIdentifiers,
 		ES6:               runtime.CanUseES6(options.UnsupportedJSFeatures),
+		LoadScript:        options.DynamicImportLoader == config.DynamicImportLoaderScript,
 	}
 
 	// Determine which source to use
-	if key.ES6 {
+	switch {
+	case key.ES6 && key.LoadScript:
+		source = runtime.ES6SourceWithLoadScript
+	case key.ES6:
 		source = runtime.ES6Source
-	} else {
+	case key.LoadScript:
+		source = runtime.ES5SourceWithLoadScript
+	default:
 		source = runtime.ES5Source
 	}
 
@@ -3,6 +3,7 @@ package bundler
 import (
 	"testing"
 
+	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
 )
 
@@ -450,6 +451,78 @@ func TestFileImportURLInCSS(t *testing.T) {
 	})
 }
 
+func TestFileImportURLInCSSImageSet(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				a { background: image-set("./one.png" 1x, url(./two.png) 2x) }
+				b { background: -webkit-image-set('./one.png' 1x, "./two.png" 2x) }
+				c { background: image-set(linear-gradient(red, blue) 1x, "./two.png" type("image/png")) }
+			`,
+			"/one.png": "one",
+			"/two.png": "two",
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".css": config.LoaderCSS,
+				".png": config.LoaderFile,
+			},
+		},
+	})
+}
+
+func TestFileImportURLInCSSFontFace(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@font-face {
+					font-family: Example;
+					src:
+						local(Example),
+						url(./example.woff2) format("woff2"),
+						url("./example.woff") format("woff"),
+						url(./example.ttf) format("truetype");
+				}
+			`,
+			"/example.woff2": "woff2",
+			"/example.woff":  "woff",
+			"/example.ttf":   "ttf",
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".css":   config.LoaderCSS,
+				".woff2": config.LoaderFile,
+				".woff":  config.LoaderFile,
+				".ttf":   config.LoaderFile,
+			},
+		},
+	})
+}
+
+func TestMissingImportURLInCSSImageSet(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/entry.css": `
+				a { background: image-set("./one.png" 1x, "./two.png" 2x) }
+			`,
+		},
+		entryPaths: []string{"/src/entry.css"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+		},
+		expectedScanLog: `src/entry.css: ERROR: Could not resolve "./one.png"
+src/entry.css: ERROR: Could not resolve "./two.png"
+`,
+	})
+}
+
 func TestIgnoreURLsInAtRulePrelude(t *testing.T) {
 	css_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -597,7 +670,207 @@ func TestCSSAtImportConditionsBundle(t *testing.T) {
 	})
 }
 
+func TestCSSAtImportLayer(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@layer reset, base;
+				@import "./reset.css" layer(reset);
+				@import "./base.css" layer(base);
+				@import "./theme.css?layer=theme";
+				@import "https://example.com/external.css" layer(base);
+				.unlayered { color: red }
+			`,
+			"/reset.css": `* { margin: 0 }`,
+			"/base.css": `
+				@import "./shared.css" layer(inner);
+				a { color: blue }
+			`,
+			"/shared.css": `.shared { color: green }`,
+			"/theme.css":  `:root { --fg: black }`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+		},
+	})
+}
+
+func TestCSSAtImportLayerSameFileTwice(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@import "./shared.css" layer(a);
+				@import "./shared.css" layer(b);
+				@import "./shared.css" layer(a);
+			`,
+			"/shared.css": `.shared { color: green }`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+		},
+	})
+}
+
+func TestCSSLayersOption(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@import "pkg/index.css";
+				@import "pkg/other.css" layer(override);
+				.app { color: red }
+			`,
+			"/node_modules/pkg/index.css": `
+				@import "./grid.css";
+				.btn { padding: 0 }
+			`,
+			"/node_modules/pkg/grid.css":  `.row { display: flex }`,
+			"/node_modules/pkg/other.css": `.other { display: none }`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.css",
+			CSSLayers:     map[string]string{"pkg": "framework"},
+		},
+	})
+}
+
+func TestCSSAtImportLayerLowered(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@layer base, components;
+				@import "./components.css" layer(components);
+				@import "./base.css" layer(base);
+				.app .title { color: red }
+			`,
+			"/base.css":       `.title { color: blue }`,
+			"/components.css": `.card .title { color: green }`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:                   config.ModeBundle,
+			AbsOutputFile:          "/out.css",
+			UnsupportedCSSFeatures: compat.CascadeLayers,
+		},
+	})
+}
+
+func TestCSSAtImportLayerLoweredUnsafe(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				@import "./base.css" layer(base);
+				.title { color: red }
+			`,
+			"/base.css": `.card .title { color: blue }`,
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:                   config.ModeBundle,
+			AbsOutputFile:          "/out.css",
+			UnsupportedCSSFeatures: compat.CascadeLayers,
+		},
+		expectedCompileLog: `WARNING: Cannot safely remove "@layer" rules from the CSS for "entry.css" for the configured target environment because the specificity of selectors in an earlier layer is higher than in a later layer
+`,
+	})
+}
+
 // This test mainly just makes sure that this scenario doesn't crash
+func TestCSSAtImportPackageStyleField(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.css": `
+				@import "pkg";
+				.entry { color: red }
+			`,
+			"/Users/user/project/node_modules/pkg/package.json": `
+				{
+					"main": "index.js",
+					"style": "dist/pkg.css"
+				}
+			`,
+			"/Users/user/project/node_modules/pkg/index.js":     `console.log('FAILURE')`,
+			"/Users/user/project/node_modules/pkg/dist/pkg.css": `.pkg { color: blue }`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.css",
+		},
+	})
+}
+
+func TestCSSAtImportPackageExportsStyleCondition(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.css": `
+				@import "pkg";
+				@import "pkg/theme";
+				.entry { color: red }
+			`,
+			"/Users/user/project/node_modules/pkg/package.json": `
+				{
+					"exports": {
+						".": {
+							"import": "./pkg.mjs",
+							"style": "./pkg.css",
+							"default": "./pkg.js"
+						},
+						"./theme": {
+							"sass": "./theme.scss",
+							"style": "./theme.css"
+						}
+					}
+				}
+			`,
+			"/Users/user/project/node_modules/pkg/pkg.mjs":    `console.log('FAILURE')`,
+			"/Users/user/project/node_modules/pkg/pkg.js":     `console.log('FAILURE')`,
+			"/Users/user/project/node_modules/pkg/pkg.css":    `.pkg { color: blue }`,
+			"/Users/user/project/node_modules/pkg/theme.scss": `$color: green; .theme { color: $color }`,
+			"/Users/user/project/node_modules/pkg/theme.css":  `.theme { color: green }`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.css",
+		},
+	})
+}
+
+func TestCSSAtImportPackageExportsSassCondition(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.css": `
+				@import "pkg";
+			`,
+			"/Users/user/project/node_modules/pkg/package.json": `
+				{
+					"exports": {
+						"sass": "./pkg.scss",
+						"style": "./pkg.css"
+					}
+				}
+			`,
+			"/Users/user/project/node_modules/pkg/pkg.scss": `.pkg { color: green }`,
+			"/Users/user/project/node_modules/pkg/pkg.css":  `.pkg { color: blue }`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.css",
+			ExtensionToLoader: map[string]config.Loader{
+				".css":  config.LoaderCSS,
+				".scss": config.LoaderCSS,
+			},
+		},
+	})
+}
+
 func TestCSSAndJavaScriptCodeSplittingIssue1064(t *testing.T) {
 	css_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -686,3 +959,208 @@ func TestCSSExternalQueryAndHashMatchIssue1822(t *testing.T) {
 		},
 	})
 }
+
+func TestCSSInjectLinkESM(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/app.js": `
+				import './app.css'
+				import { shared } from './shared.js'
+				console.log(shared)
+			`,
+			"/src/other.js": `
+				import { shared } from './shared.js'
+				console.log(shared)
+			`,
+			"/src/shared.js": `
+				export let shared = 123
+			`,
+			"/src/app.css": `
+				body { color: red }
+			`,
+		},
+		entryPaths: []string{"/src/app.js", "/src/other.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			CodeSplitting: true,
+			AbsOutputDir:  "/out",
+			InjectCSSLink: true,
+			EntryPathTemplate: []config.PathTemplate{
+				{Data: "./", Placeholder: config.NamePlaceholder},
+				{Data: "-", Placeholder: config.HashPlaceholder},
+			},
+		},
+	})
+}
+
+func TestCSSInjectLinkIIFE(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './entry.css'
+				console.log('loaded')
+			`,
+			"/entry.css": `
+				body { color: red }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatIIFE,
+			AbsOutputDir:  "/out",
+			InjectCSSLink: true,
+		},
+	})
+}
+
+func TestCSSInjectLinkMinify(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './entry.css'
+				console.log('loaded')
+			`,
+			"/entry.css": `
+				body { color: red }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			OutputFormat:     config.FormatESModule,
+			AbsOutputDir:     "/out",
+			PublicPath:       "https://example.com/assets/",
+			RemoveWhitespace: true,
+			InjectCSSLink:    true,
+		},
+	})
+}
+
+func TestCSSLocalNames(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import styles from './button.module.css'
+				import { primary } from './button.module.css'
+				console.log(styles, primary)
+			`,
+			"/button.module.css": `
+				.primary { composes: base; composes: shared from "./shared.module.css"; color: red }
+				.base { composes: btn from global; border: none }
+				:global(.dark) .primary:hover { color: blue }
+			`,
+			"/shared.module.css": `
+				.shared { composes: inner; padding: 0 }
+				.inner { margin: 0 }
+			`,
+			"/other/shared.module.css": `
+				.shared { color: green }
+			`,
+			"/other/entry.js": `
+				import './shared.module.css'
+			`,
+		},
+		entryPaths: []string{"/entry.js", "/other/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			OutputFormat: config.FormatESModule,
+			AbsOutputDir: "/out",
+		},
+	})
+}
+
+func TestCSSLocalNamesLoader(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import styles from './styles.css'
+				console.log(styles)
+			`,
+			"/styles.css": `
+				.a:global(.b) { color: red }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			OutputFormat: config.FormatESModule,
+			AbsOutputDir: "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".css": config.LoaderLocalCSS,
+			},
+		},
+	})
+}
+
+func TestCSSLocalNamesComposesErrors(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import styles from './entry.module.css'
+				console.log(styles)
+			`,
+			"/entry.module.css": `
+				.a { composes: b }
+				.c { composes: d from "./other.module.css" }
+				.e { composes: f from "./global.css" }
+				.g { composes: h from "./file.js" }
+			`,
+			"/other.module.css": `
+				.x {}
+			`,
+			"/global.css": `
+				.f {}
+			`,
+			"/file.js": ``,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			OutputFormat: config.FormatESModule,
+			AbsOutputDir: "/out",
+		},
+		expectedScanLog: `entry.module.css: ERROR: The name "b" is not a local class name in this file
+entry.module.css: ERROR: The name "d" is not a local class name in "other.module.css"
+entry.module.css: ERROR: Cannot use "composes" with "global.css" because it doesn't use the "local-css" loader
+entry.module.css: ERROR: Cannot import "file.js" into a CSS file
+`,
+	})
+}
+
+func TestCSSOrderReport(t *testing.T) {
+	css_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.css": `
+				@import "./x.css";
+				@import "./y.css";
+				@import "./shared.css";
+				a { color: red }
+			`,
+			"/b.css": `
+				@import "./y.css";
+				@import "./x.css";
+				@import "./shared.css" layer(base);
+				b { color: blue }
+			`,
+			"/x.css": `
+				@import "./shared.css";
+				.x { color: green }
+			`,
+			"/y.css": `
+				.y { color: yellow }
+			`,
+			"/shared.css": `
+				.shared { margin: 0 }
+			`,
+		},
+		entryPaths: []string{"/a.css", "/b.css"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			AbsOutputDir:          "/out",
+			AbsCSSOrderReportFile: "/out/css-order.json",
+		},
+	})
+}
@@ -1961,3 +1961,157 @@ func TestDCEVarExports(t *testing.T) {
 		},
 	})
 }
+
+func TestDCEClassAndObjectMembers(t *testing.T) {
+	dce_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { used, values } from './util'
+				class Base {
+					baseUsed() {}
+					baseUnused() {}
+				}
+				class Sub extends Base {
+					subUsed() { return super.baseUsed() }
+					subUnused() {}
+				}
+				const helpers = {
+					used: 1,
+					unused: 2,
+					method() {},
+					sideEffect: foo(),
+					/* @__KEEP__ */ keep: 3,
+				}
+				console.log(used(), window.fromOtherFile(), helpers.used, new Sub().subUsed(), values)
+			`,
+			"/util.js": `
+				class Util {
+					used() { return this.helper() }
+					helper() {}
+					fromOtherFile() {}
+					unused() {}
+					static unusedStatic() {}
+					/* @__KEEP__ */ keep() {}
+					toString() { return 'Util' }
+				}
+				const util = new Util
+				export function used() { return util.used() }
+
+				/* @__KEEP__ */ class Keep { unused() {} }
+				class Escapes { unused() {} }
+				class Reflected { unused() {} }
+				class Constructed { unused() {} }
+				const constructed = new Constructed
+				export let values = [new Keep, Escapes, Reflected.prototype, constructed.constructor]
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			TreeShakingMembers: true,
+		},
+	})
+}
+
+func TestDCEClassAndObjectMembersInstanceEscapes(t *testing.T) {
+	dce_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				class Safe { used() {} unused() {} }
+				class Returned { unused() {} }
+				class Passed { unused() {} }
+				class ThisEscapes { unused() {} self() { return this } }
+				class Base { unused() {} }
+				class Derived extends Base {}
+				const object = { unused() {}, self() { return this } }
+				function make() { return new Returned }
+				const safe = new Safe
+				const passed = new Passed
+				const thisEscapes = new ThisEscapes
+				safe.used()
+				console.log(make(), [passed], thisEscapes.self(), Derived, object.self())
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			TreeShakingMembers: true,
+		},
+	})
+}
+
+func TestDCEClassAndObjectMembersComputedAccess(t *testing.T) {
+	dce_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { key } from './key'
+				class C { a() {} b() {} }
+				const c = new C()
+				c[key]()
+			`,
+			"/key.js": `
+				export let key = Math.random() < 0.5 ? 'a' : 'b'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			TreeShakingMembers: true,
+		},
+	})
+}
+
+func TestDCEClassAndObjectMembersReflection(t *testing.T) {
+	dce_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './reflect'
+				class C { a() {} b() {} }
+				const c = new C()
+				c.a()
+			`,
+			"/reflect.js": `
+				class D { unused() {} }
+				const d = new D()
+				console.log(Object.getOwnPropertyNames(Object.getPrototypeOf(d)))
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			TreeShakingMembers: true,
+		},
+	})
+}
+
+func TestDCEInferPure(t *testing.T) {
+	dce_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './lib'
+				const defaults = { z: 0 }
+				function withDefaults(point) { return [point, defaults] }
+				function createPoint(x, y = 0) { return withDefaults({ x, y }) }
+				const createLogger = () => { console.log('created') }
+				let unusedPoint = createPoint(1, 2)
+				let unusedLogger = createLogger()
+				let usedPoint = createPoint(3, 4)
+				console.log(usedPoint)
+			`,
+			"/lib.js": `
+				function readsGlobal() { return window.foo }
+				let unused = readsGlobal()
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			InferPureFunctions: true,
+		},
+	})
+}
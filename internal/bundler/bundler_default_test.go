@@ -4,11 +4,13 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
 	"github.com/evanw/esbuild/internal/logger"
 )
 
@@ -481,6 +483,52 @@ func TestJSXImportsES6(t *testing.T) {
 	})
 }
 
+func TestJSXAutomaticImportsES6(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.jsx": `
+				let _jsx = 'user code'
+				console.log(_jsx, <div key="k">text</div>, <><a/><b/></>)
+			`,
+			"/node_modules/react/jsx-runtime.js": `
+				export function jsx() {}
+				export function jsxs() {}
+				export const Fragment = 'Fragment'
+			`,
+		},
+		entryPaths: []string{"/entry.jsx"},
+		options: config.Options{
+			Mode: config.ModeBundle,
+			JSX: config.JSXOptions{
+				AutomaticRuntime: true,
+			},
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestJSXAutomaticImportSourceNotFound(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.jsx": `
+				console.log(<div/>)
+			`,
+		},
+		entryPaths: []string{"/entry.jsx"},
+		options: config.Options{
+			Mode: config.ModeBundle,
+			JSX: config.JSXOptions{
+				AutomaticRuntime: true,
+				ImportSource:     "preact",
+			},
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `entry.jsx: ERROR: Could not resolve "preact/jsx-runtime"
+NOTE: You can mark the path "preact/jsx-runtime" as external to exclude it from the bundle, which will remove this error.
+`,
+	})
+}
+
 func TestJSXSyntaxInJS(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -863,6 +911,105 @@ func TestRequireAndDynamicImportInvalidTemplate(t *testing.T) {
 	})
 }
 
+func TestBundleDynamicPaths(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				const lang = navigator.language
+				console.log(require(` + "`./locales/${lang}.json`" + `))
+				console.log(require('./pages/' + page + '/index.js'))
+				import(` + "`./locales/${lang}.json`" + `).then(console.log)
+			`,
+			"/locales/en.json":       `{ "hello": "Hello" }`,
+			"/locales/fr.json":       `{ "hello": "Bonjour" }`,
+			"/locales/README.md":     `# Locales`,
+			"/pages/home/index.js":   `module.exports = 'home'`,
+			"/pages/about/index.js":  `module.exports = 'about'`,
+			"/pages/about/styles.js": `module.exports = 'styles'`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			BundleDynamicPaths: true,
+		},
+	})
+}
+
+func TestBundleDynamicPathsWarnings(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				require(name)
+				require(` + "`${dir}/file.js`" + `)
+				require(` + "`./missing/${name}.js`" + `)
+				import(name)
+				try {
+					require(name)
+				} catch {
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:               config.ModeBundle,
+			AbsOutputFile:      "/out.js",
+			BundleDynamicPaths: true,
+		},
+		expectedScanLog: `entry.js: WARNING: This call to "require" will not be bundled because the argument has no static prefix
+NOTE: Only paths that start with a relative path such as "./dir/" can be bundled with "--bundle-dynamic-paths".
+entry.js: WARNING: This call to "require" will not be bundled because the argument has no static prefix
+NOTE: Only paths that start with a relative path such as "./dir/" can be bundled with "--bundle-dynamic-paths".
+entry.js: WARNING: No files match the pattern "./missing/*.js"
+entry.js: WARNING: This "import" expression will not be bundled because the argument has no static prefix
+NOTE: Only paths that start with a relative path such as "./dir/" can be bundled with "--bundle-dynamic-paths".
+`,
+	})
+}
+
+func TestOutputBudgets(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/small.js": `
+				console.log('small')
+			`,
+			"/large.js": `
+				import { helper } from './helper.js'
+				export function a() { return () => helper() }
+				export function b() { return function() {} }
+				export class C { method() {} }
+				console.log(a, b, C)
+			`,
+			"/helper.js": `
+				export function helper() {}
+			`,
+			"/wrapped.js": `
+				module.exports = function() { return function() {} }
+			`,
+			"/uses-wrapped.js": `
+				console.log(require('./wrapped.js'))
+			`,
+		},
+		entryPaths: []string{"/small.js", "/large.js", "/uses-wrapped.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			OutputBudgets: config.OutputBudgets{
+				Bytes:      200,
+				Functions:  3,
+				Statements: 2,
+			},
+		},
+		expectedCompileLog: `WARNING: The output file "out/uses-wrapped.js" exceeds its parse-time cost budget
+NOTE: The file is 219 bytes (the budget is 200).
+WARNING: The output file "out/large.js" exceeds its parse-time cost budget
+NOTE: The file is 218 bytes (the budget is 200).
+NOTE: The file contains 6 functions (the budget is 3).
+NOTE: The file contains 5 top-level statements (the budget is 2).
+`,
+	})
+}
+
 func TestDynamicImportWithExpressionCJS(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -2415,6 +2562,103 @@ entry.js: ERROR: Could not resolve "./file.ping"
 	})
 }
 
+func TestExternalRewrite(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import _ from "lodash"
+				import fp from "lodash/fp"
+				import { h } from "preact"
+				import "preact/hooks"
+				import "react"
+				export const lazy = () => import("lodash")
+				console.log(_, fp, h)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/out.js",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"lodash": true,
+					"preact": true,
+					"react":  true,
+				},
+				Patterns: []config.WildcardPattern{
+					{Prefix: "lodash/"},
+					{Prefix: "preact/"},
+				},
+			},
+			ExternalRewrites: []config.ExternalRewrite{
+				{Pattern: config.WildcardPattern{Prefix: "lodash"}, Replacement: "https://cdn.example.com/lodash@4/+esm"},
+				{Pattern: config.WildcardPattern{Prefix: "preact"}, Replacement: "https://cdn.example.com/preact@10"},
+				{Pattern: config.WildcardPattern{Prefix: "lodash/"}, IsWildcard: true, Replacement: "https://cdn.example.com/lodash@4/*/+esm"},
+				{Pattern: config.WildcardPattern{Prefix: "preact/"}, IsWildcard: true, Replacement: "https://cdn.example.com/preact@10/*"},
+			},
+		},
+	})
+}
+
+func TestExternalGlobalsIIFE(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import React, { useState } from "react"
+				import * as DOM from "react-dom"
+				export * from "react-dom"
+				let React2 = useState
+				export const lazy = () => import("react")
+				export const req = () => require("react")
+				DOM.render(React.createElement("div"), React2)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatIIFE,
+			GlobalName:    []string{"widget"},
+			AbsOutputFile: "/out.js",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"react":     true,
+					"react-dom": true,
+				},
+			},
+			ExternalGlobals: map[string][]string{
+				"react":     {"React"},
+				"react-dom": {"window", "react-dom"},
+			},
+		},
+	})
+}
+
+func TestExternalGlobalsESM(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import React from "react"
+				console.log(React)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/out.js",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"react": true,
+				},
+			},
+			ExternalGlobals: map[string][]string{
+				"react": {"React"},
+			},
+		},
+	})
+}
+
 // This test case makes sure many entry points don't cause a crash
 func TestManyEntryPoints(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
@@ -2759,7 +3003,166 @@ func TestNoOverwriteInputFileError(t *testing.T) {
 			Mode:         config.ModeBundle,
 			AbsOutputDir: "/",
 		},
-		expectedCompileLog: `ERROR: Refusing to overwrite input file "entry.js" (use "AllowOverwrite: true" to allow this)
+		expectedCompileLog: `ERROR: Refusing to overwrite input file "entry.js" with the output for entry point "entry.js" (use "OnConflict: api.OnConflictOverwrite" to allow this)
+`,
+	})
+}
+
+func TestNoOverwriteInputFileStdoutStream(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				console.log(123)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/",
+			StdoutStream: config.StdoutStreamSingle,
+		},
+	})
+}
+
+func TestStdoutStreamMultipleOutputFiles(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import "./style.css"
+				import url from "./image.png"
+				console.log(url)
+			`,
+			"/style.css": `a { color: red }`,
+			"/image.png": `png`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			StdoutStream: config.StdoutStreamSingle,
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".css": config.LoaderCSS,
+				".png": config.LoaderFile,
+			},
+		},
+		expectedCompileLog: `ERROR: Cannot write 3 output files to stdout
+NOTE: The asset "image.png" would be written to out/image-PVIPRHR2.png
+NOTE: The output for entry point "entry.js" would be written to out/entry.js
+NOTE: The output for entry point "entry.js" would be written to out/entry.css
+NOTE: Set "Outdir" to "-" to write all output files to stdout as a tar archive.
+`,
+	})
+}
+
+func TestNoOverwriteInputFileRename(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import "./entry-2.js"
+				console.log(123)
+			`,
+			"/entry-2.js": `
+				console.log(234)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/",
+			OnConflict:   config.OnConflictRename,
+		},
+	})
+}
+
+func TestNoOverwriteInputFileAsset(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/entry.js": `
+				import url from "./image.png"
+				console.log(url)
+			`,
+			"/src/image.png": `png`,
+		},
+		entryPaths: []string{"/src/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			OnConflict:   config.OnConflictRename,
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".png": config.LoaderFile,
+			},
+			AssetPathTemplate: []config.PathTemplate{
+				{Data: "../src/", Placeholder: config.NamePlaceholder},
+			},
+		},
+		expectedCompileLog: `ERROR: Refusing to overwrite input file "src/image.png" with the asset "src/image.png" (use "OnConflict: api.OnConflictOverwrite" to allow this)
+`,
+	})
+}
+
+func TestOutputFilesConflictError(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a/entry.js": `console.log("a")`,
+			"/b/entry.js": `console.log("b")`,
+		},
+		entryPathsAdvanced: []EntryPoint{
+			{InputPath: "/a/entry.js", OutputPath: "entry"},
+			{InputPath: "/b/entry.js", OutputPath: "entry"},
+		},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			SourceMap:    config.SourceMapLinkedWithComment,
+		},
+		expectedCompileLog: `ERROR: Two output files share the same path but have different contents: out/entry.js.map
+NOTE: One file is the source map for entry point "a/entry.js"
+NOTE: The other file is the source map for entry point "b/entry.js"
+ERROR: Two output files share the same path but have different contents: out/entry.js
+NOTE: One file is the output for entry point "a/entry.js"
+NOTE: The other file is the output for entry point "b/entry.js"
+`,
+	})
+}
+
+func TestOutputFilesConflictRename(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a/entry.js": `console.log("a")`,
+			"/b/entry.js": `console.log("b")`,
+		},
+		entryPathsAdvanced: []EntryPoint{
+			{InputPath: "/a/entry.js", OutputPath: "entry"},
+			{InputPath: "/b/entry.js", OutputPath: "entry"},
+		},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			SourceMap:    config.SourceMapLinkedWithComment,
+			OnConflict:   config.OnConflictRename,
+		},
+	})
+}
+
+func TestOutputFilesConflictOverwrite(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a/entry.js": `console.log("a")`,
+			"/b/entry.js": `console.log("b")`,
+		},
+		entryPathsAdvanced: []EntryPoint{
+			{InputPath: "/a/entry.js", OutputPath: "entry"},
+			{InputPath: "/b/entry.js", OutputPath: "entry"},
+		},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			OnConflict:   config.OnConflictOverwrite,
+		},
+		expectedCompileLog: `WARNING: Two output files share the same path but have different contents: out/entry.js
+NOTE: The output for entry point "a/entry.js" was overwritten by the output for entry point "b/entry.js"
 `,
 	})
 }
@@ -3149,35 +3552,143 @@ func TestLegalCommentsExternal(t *testing.T) {
 	})
 }
 
-func TestLegalCommentsModifyIndent(t *testing.T) {
+func TestLegalCommentsCustomPragmas(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
 			"/entry.js": `
-				export default () => {
-					/**
-					 * @preserve
-					 */
-				}
+				/* @generated */
+				import './a'
+				//! @license-category: MIT
+				console.log('in entry')
 			`,
-			"/entry.css": `
-				@media (x: y) {
-					/**
-					 * @preserve
-					 */
-					z { zoom: 2 }
-				}
+			"/a.js": `
+				// @coverage-ignore
+				console.log('in a') //! Copyright notice 1
+				/* @generated-by-other-tool */
 			`,
 		},
-		entryPaths: []string{"/entry.js", "/entry.css"},
+		entryPaths: []string{"/entry.js"},
 		options: config.Options{
-			Mode:          config.ModeBundle,
-			AbsOutputDir:  "/out",
-			LegalComments: config.LegalCommentsInline,
+			Mode:             config.ModeBundle,
+			AbsOutputDir:     "/out",
+			RemoveWhitespace: true,
+			LegalComments:    config.LegalCommentsEndOfFile,
+			CustomPragmas:    []string{"generated", "license-category", "coverage-ignore"},
 		},
 	})
 }
 
-func TestLegalCommentsAvoidSlashTagInline(t *testing.T) {
+func TestLegalCommentsPreserveComments(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				// keep: entry
+				import './a'
+				// drop: entry
+				console.log('in entry')
+			`,
+			"/a.js": `
+				//! Copyright notice 1
+				export function foo() {
+					/* keep: nested */
+					return 123
+				}
+				console.log('in a', foo()) // keep: trailing
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputDir:     "/out",
+			RemoveWhitespace: true,
+			LegalComments:    config.LegalCommentsEndOfFile,
+			PreserveComments: regexp.MustCompile(`^\s*keep:`),
+		},
+	})
+}
+
+func TestAnnotationsAlwaysMinified(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export let foo = /* @__PURE__ */ bar()
+				export let baz = /* @__PURE__ */ new Baz()
+				export let lazy = () => import(/* webpackChunkName: "pkg" */ 'pkg')
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			OutputFormat:     config.FormatESModule,
+			AbsOutputFile:    "/out.js",
+			RemoveWhitespace: true,
+			Annotations:      config.AnnotationsAlways,
+			MagicComments:    config.AnnotationsAlways,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"pkg": true,
+				},
+			},
+		},
+	})
+}
+
+func TestAnnotationsNeverForFormat(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export let foo = /* @__PURE__ */ bar()
+				export let lazy = () => import(/* webpackChunkName: "pkg" */ 'pkg')
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatCommonJS,
+			Platform:      config.PlatformNode,
+			AbsOutputFile: "/out.js",
+			FormatAnnotations: map[config.Format]config.Annotations{
+				config.FormatCommonJS: config.AnnotationsNever,
+			},
+			MagicComments: config.AnnotationsNever,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"pkg": true,
+				},
+			},
+		},
+	})
+}
+
+func TestLegalCommentsModifyIndent(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export default () => {
+					/**
+					 * @preserve
+					 */
+				}
+			`,
+			"/entry.css": `
+				@media (x: y) {
+					/**
+					 * @preserve
+					 */
+					z { zoom: 2 }
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js", "/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputDir:  "/out",
+			LegalComments: config.LegalCommentsInline,
+		},
+	})
+}
+
+func TestLegalCommentsAvoidSlashTagInline(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
 			"/entry.js": `
@@ -3258,6 +3769,44 @@ func TestIIFE_ES5(t *testing.T) {
 	})
 }
 
+func TestUMD(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { x } from "./foo"
+				export default x
+				export const y = 2
+			`,
+			"/foo.js": `export const x = 1`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatUMD,
+			GlobalName:    []string{"my", "lib-name"},
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestUMDCommonJSEntryPointMinifyES5(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				module.exports = { x: 1 }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			OutputFormat:          config.FormatUMD,
+			UnsupportedJSFeatures: es(5),
+			RemoveWhitespace:      true,
+			AbsOutputFile:         "/out.js",
+		},
+	})
+}
+
 func TestOutputExtensionRemappingFile(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -3747,6 +4296,54 @@ write-getter.js: WARNING: Writing to getter-only property "#foo" will throw
 	})
 }
 
+func TestPluginOnStartFinishesBeforeOnResolve(t *testing.T) {
+	// The state is reset by "onStart" and must be visible to "onResolve"
+	state := "stale"
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import state from "virtual:state"
+				console.log(state)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			Plugins: []config.Plugin{{
+				Name: "state",
+				OnStart: []config.OnStart{{
+					Name: "state",
+					Callback: func() config.OnStartResult {
+						time.Sleep(10 * time.Millisecond)
+						state = "fresh"
+						return config.OnStartResult{Msgs: []logger.Msg{{
+							Kind: logger.Warning,
+							Data: logger.MsgData{Text: "Starting a new build"},
+						}}}
+					},
+				}},
+				OnResolve: []config.OnResolve{{
+					Filter: regexp.MustCompile("^virtual:"),
+					Callback: func(args config.OnResolveArgs) config.OnResolveResult {
+						return config.OnResolveResult{Path: logger.Path{Text: state, Namespace: "virtual"}}
+					},
+				}},
+				OnLoad: []config.OnLoad{{
+					Filter:    regexp.MustCompile("."),
+					Namespace: "virtual",
+					Callback: func(args config.OnLoadArgs) config.OnLoadResult {
+						contents := "export default " + string(js_printer.QuoteForJSON(args.Path.Text, false))
+						return config.OnLoadResult{Contents: &contents, Loader: config.LoaderJS}
+					},
+				}},
+			}},
+		},
+		expectedScanLog: `WARNING: Starting a new build
+`,
+	})
+}
+
 func TestRequireResolve(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -4280,6 +4877,79 @@ func TestDefineThis(t *testing.T) {
 	})
 }
 
+func TestDefineEnvSpreadAndDestructuring(t *testing.T) {
+	defines := config.ProcessDefines(map[string]config.DefineData{
+		"process.env.NODE_ENV": {
+			DefineFunc: func(args config.DefineArgs) js_ast.E {
+				return &js_ast.EString{Value: js_lexer.StringToUTF16("production")}
+			},
+		},
+		"process.env.API_URL": {
+			DefineFunc: func(args config.DefineArgs) js_ast.E {
+				return &js_ast.EString{Value: js_lexer.StringToUTF16("/api")}
+			},
+		},
+		"import.meta.env.MODE": {
+			DefineFunc: func(args config.DefineArgs) js_ast.E {
+				return &js_ast.EString{Value: js_lexer.StringToUTF16("dev")}
+			},
+		},
+	})
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				// These should be replaced with object literals
+				console.log({ ...process.env })
+				console.log({ a: 1, ...import.meta.env, b: 2 })
+
+				// These should be split up into separate variables
+				const { API_URL, NODE_ENV: mode } = process.env
+				const { MISSING } = process.env
+				const { OTHER = 'default', ...rest } = process.env
+				const { MODE } = import.meta.env
+				console.log(API_URL, mode, MISSING, OTHER, rest, MODE)
+
+				// These should not be changed
+				const { [API_URL]: computed } = process.env
+				console.log(computed, { ...process.env.NODE_ENV }, { ...import.meta })
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			Defines:       &defines,
+		},
+		expectedScanLog: `entry.js: WARNING: The environment variable "MISSING" will be undefined because there is no define for "process.env.MISSING"
+`,
+	})
+}
+
+func TestDefineEnvSpreadPlatformNode(t *testing.T) {
+	defines := config.ProcessDefines(map[string]config.DefineData{
+		"process.env.NODE_ENV": {
+			DefineFunc: func(args config.DefineArgs) js_ast.E {
+				return &js_ast.EString{Value: js_lexer.StringToUTF16("production")}
+			},
+		},
+	})
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				const { NODE_ENV, MISSING } = process.env
+				console.log({ ...process.env }, NODE_ENV, MISSING)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			Platform:      config.PlatformNode,
+			AbsOutputFile: "/out.js",
+			Defines:       &defines,
+		},
+	})
+}
+
 func TestKeepNamesTreeShaking(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -4874,87 +5544,186 @@ func TestRequireShimSubstitution(t *testing.T) {
 	})
 }
 
-// This guards against a bad interaction between the strict mode nested function
-// declarations, name keeping, and initialized variable inlining. See this issue
-// for full context: https://github.com/evanw/esbuild/issues/1552.
-func TestStrictModeNestedFnDeclKeepNamesVariableInliningIssue1552(t *testing.T) {
+func TestDynamicImportLoadScriptIIFE(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
 			"/entry.js": `
-				export function outer() {
-					{
-						function inner() {
-							return Math.random();
-						}
-						const x = inner();
-						console.log(x);
-					}
-				}
-				outer();
+				import('./internal').then(console.log)
+				import('some-path').then(console.log)
+				import(window.SOME_PATH).then(console.log)
 			`,
+			"/internal.js": `export default 123`,
 		},
 		entryPaths: []string{"/entry.js"},
 		options: config.Options{
-			Mode:         config.ModePassThrough,
-			AbsOutputDir: "/out",
-			KeepNames:    true,
-			MangleSyntax: true,
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			OutputFormat:  config.FormatIIFE,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"some-path": true,
+				},
+			},
+			UnsupportedJSFeatures: compat.DynamicImport,
+			DynamicImportLoader:   config.DynamicImportLoaderScript,
 		},
 	})
 }
 
-func TestBuiltInNodeModulePrecedence(t *testing.T) {
+func TestDynamicImportLoadScriptESM(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
 			"/entry.js": `
-				console.log([
-					// These are node core modules
-					require('fs'),
-					require('fs/promises'),
-					require('node:foo'),
-
-					// These are not node core modules
-					require('fs/abc'),
-					require('fs/'),
-				])
-			`,
-			"/node_modules/fs/abc.js": `
-				console.log('include this')
-			`,
-			"/node_modules/fs/index.js": `
-				console.log('include this too')
-			`,
-			"/node_modules/fs/promises.js": `
-				throw 'DO NOT INCLUDE THIS'
+				import('some-path').then(console.log)
+				import(window.SOME_PATH).then(console.log)
 			`,
 		},
 		entryPaths: []string{"/entry.js"},
 		options: config.Options{
-			Mode:         config.ModeBundle,
-			Platform:     config.PlatformNode,
-			OutputFormat: config.FormatCommonJS,
-			AbsOutputDir: "/out",
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			OutputFormat:  config.FormatESModule,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"some-path": true,
+				},
+			},
+			UnsupportedJSFeatures: compat.DynamicImport,
+			DynamicImportLoader:   config.DynamicImportLoaderScript,
 		},
 	})
 }
 
-func TestEntryNamesNoSlashAfterDir(t *testing.T) {
+// The format defaults to "esm" while bundling, so both of these must be
+// loaded as module scripts even though no format was specified
+func TestDynamicImportLoadScriptNoFormat(t *testing.T) {
 	default_suite.expectBundled(t, bundled{
 		files: map[string]string{
-			"/src/app1/main.ts": `console.log(1)`,
-			"/src/app2/main.ts": `console.log(2)`,
-			"/src/app3/main.ts": `console.log(3)`,
-		},
-		entryPathsAdvanced: []EntryPoint{
-			{InputPath: "/src/app1/main.ts"},
-			{InputPath: "/src/app2/main.ts"},
-			{InputPath: "/src/app3/main.ts", OutputPath: "customPath"},
+			"/entry.js": `
+				import('some-path').then(console.log)
+				import(window.SOME_PATH).then(console.log)
+			`,
 		},
+		entryPaths: []string{"/entry.js"},
 		options: config.Options{
-			Mode: config.ModePassThrough,
-			EntryPathTemplate: []config.PathTemplate{
-				// "[dir]-[name]"
-				{Data: "./", Placeholder: config.DirPlaceholder},
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"some-path": true,
+				},
+			},
+			UnsupportedJSFeatures: compat.DynamicImport,
+			DynamicImportLoader:   config.DynamicImportLoaderScript,
+		},
+	})
+}
+
+func TestDynamicImportLoadScriptCommonJS(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import('some-path').then(console.log)
+				import(window.SOME_PATH).then(console.log)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			OutputFormat:  config.FormatCommonJS,
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"some-path": true,
+				},
+			},
+			UnsupportedJSFeatures: compat.DynamicImport,
+			DynamicImportLoader:   config.DynamicImportLoaderScript,
+		},
+	})
+}
+
+// This guards against a bad interaction between the strict mode nested function
+// declarations, name keeping, and initialized variable inlining. See this issue
+// for full context: https://github.com/evanw/esbuild/issues/1552.
+func TestStrictModeNestedFnDeclKeepNamesVariableInliningIssue1552(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export function outer() {
+					{
+						function inner() {
+							return Math.random();
+						}
+						const x = inner();
+						console.log(x);
+					}
+				}
+				outer();
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModePassThrough,
+			AbsOutputDir: "/out",
+			KeepNames:    true,
+			MangleSyntax: true,
+		},
+	})
+}
+
+func TestBuiltInNodeModulePrecedence(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				console.log([
+					// These are node core modules
+					require('fs'),
+					require('fs/promises'),
+					require('node:foo'),
+
+					// These are not node core modules
+					require('fs/abc'),
+					require('fs/'),
+				])
+			`,
+			"/node_modules/fs/abc.js": `
+				console.log('include this')
+			`,
+			"/node_modules/fs/index.js": `
+				console.log('include this too')
+			`,
+			"/node_modules/fs/promises.js": `
+				throw 'DO NOT INCLUDE THIS'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			Platform:     config.PlatformNode,
+			OutputFormat: config.FormatCommonJS,
+			AbsOutputDir: "/out",
+		},
+	})
+}
+
+func TestEntryNamesNoSlashAfterDir(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/app1/main.ts": `console.log(1)`,
+			"/src/app2/main.ts": `console.log(2)`,
+			"/src/app3/main.ts": `console.log(3)`,
+		},
+		entryPathsAdvanced: []EntryPoint{
+			{InputPath: "/src/app1/main.ts"},
+			{InputPath: "/src/app2/main.ts"},
+			{InputPath: "/src/app3/main.ts", OutputPath: "customPath"},
+		},
+		options: config.Options{
+			Mode: config.ModePassThrough,
+			EntryPathTemplate: []config.PathTemplate{
+				// "[dir]-[name]"
+				{Data: "./", Placeholder: config.DirPlaceholder},
 				{Data: "-", Placeholder: config.NamePlaceholder},
 			},
 			AbsOutputDir: "/out",
@@ -5013,3 +5782,773 @@ func TestEntryNamesChunkNamesExtPlaceholder(t *testing.T) {
 		},
 	})
 }
+
+func TestIsolatedPackages(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import "./setup"
+				import {legacy} from "legacy"
+				import {modern} from "modern"
+				console.log(legacy, modern)
+			`,
+			"/Users/user/project/src/setup.js": `
+				globalThis.config = { debug: true }
+			`,
+			"/Users/user/project/node_modules/legacy/index.js": `
+				import {helper} from "./helper"
+				export let legacy = helper(globalThis.config)
+			`,
+			"/Users/user/project/node_modules/legacy/helper.js": `
+				export function helper(config) { return config.debug }
+			`,
+			"/Users/user/project/node_modules/modern/index.js": `
+				export let modern = 123
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputFile:    "/out.js",
+			IsolatedPackages: []string{"legacy"},
+		},
+	})
+}
+
+func TestLazyPackages(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import "polyfill"
+				import {format, Chart} from "charts"
+				import * as charts from "charts"
+				export {version} from "charts"
+				export function render() {
+					return new Chart({format}, charts)
+				}
+			`,
+			"/Users/user/project/node_modules/polyfill/index.js": `
+				globalThis.polyfilled = true
+			`,
+			"/Users/user/project/node_modules/charts/index.js": `
+				import {format} from "./format"
+				export {format}
+				export class Chart {}
+				export let version = "1.0"
+				console.log("evaluating charts")
+			`,
+			"/Users/user/project/node_modules/charts/format.js": `
+				export let format = x => x.toFixed(2)
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/out.js",
+			LazyPackages:  []string{"polyfill", "charts"},
+		},
+	})
+}
+
+func TestLazyPackagesCommonJS(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import {Chart} from "charts"
+				export let chart = () => new Chart
+				export {version} from "charts"
+			`,
+			"/Users/user/project/node_modules/charts/index.js": `
+				export class Chart {}
+				export let version = "1.0"
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputFile: "/out.js",
+			LazyPackages:  []string{"charts"},
+		},
+	})
+}
+
+func TestImportPathsDifferOnlyInCase(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import foo from "./foo.js"
+				import Foo from "./Foo.js"
+				import foo2 from "./foo.js"
+				import Foo2 from "./Foo.js"
+				console.log(foo, Foo, foo2, Foo2)
+			`,
+			"/foo.js": `export default "foo"`,
+			"/Foo.js": `export default "Foo"`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `entry.js: WARNING: The paths "foo.js" and "Foo.js" differ only in case
+entry.js: NOTE: The other path was imported here:
+NOTE: Both paths are bundled as the same file, which is only correct on case-insensitive file systems. On case-sensitive file systems, these paths don't refer to the same file.
+entry.js: WARNING: Use "foo.js" instead of "Foo.js" to avoid issues with case-sensitive file systems
+`,
+	})
+}
+
+func TestImportPathsDifferOnlyInCaseStrictCase(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import foo from "./foo.js"
+				import Foo from "./Foo.js"
+				console.log(foo, Foo)
+			`,
+			"/foo.js": `export default "foo"`,
+			"/Foo.js": `export default "Foo"`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			StrictCase:    true,
+		},
+		expectedScanLog: `entry.js: ERROR: The paths "foo.js" and "Foo.js" differ only in case
+entry.js: NOTE: The other path was imported here:
+NOTE: Both paths are bundled as the same file, which is only correct on case-insensitive file systems. On case-sensitive file systems, these paths don't refer to the same file.
+entry.js: ERROR: Use "foo.js" instead of "Foo.js" to avoid issues with case-sensitive file systems
+`,
+	})
+}
+
+func TestModuleMap(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {greet} from "./greet.js"
+				import data from "./data.json"
+				console.log(greet(data.name))
+			`,
+			"/greet.js": `
+				export function greet(name) {
+					return "Hello, " + name
+				}
+			`,
+			"/data.json": `{ "name": "é😀" }`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatIIFE,
+			AbsOutputFile: "/out.js",
+			ModuleMap:     true,
+		},
+	})
+}
+
+func TestModuleMapMinified(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {greet} from "./greet.js"
+				console.log(greet("world"))
+			`,
+			"/greet.js": `
+				export function greet(name) {
+					return "Hello, " + name
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			OutputFormat:     config.FormatESModule,
+			AbsOutputFile:    "/out.js",
+			RemoveWhitespace: true,
+			ModuleMap:        true,
+		},
+	})
+}
+
+func TestModuleMapSplitting(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {shared} from "./shared.js"
+				import("./lazy.js").then(() => shared("a"))
+			`,
+			"/b.js": `
+				import {shared} from "./shared.js"
+				shared("b")
+			`,
+			"/shared.js": `export let shared = x => console.log(x)`,
+			"/lazy.js":   `console.log("lazy")`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			OutputFormat:     config.FormatESModule,
+			CodeSplitting:    true,
+			AbsOutputDir:     "/out",
+			RemoveWhitespace: true,
+			ModuleMap:        true,
+		},
+	})
+}
+
+func TestCJSWrapper(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/index.js": `
+				export let foo = 1
+				export function bar() {}
+				let keyword = 2
+				export { keyword as if, keyword as "not an identifier" }
+				export default 3
+			`,
+			"/src/empty.js": `console.log("no exports")`,
+		},
+		entryPaths: []string{"/src/index.js", "/src/empty.js"},
+		options: config.Options{
+			Mode:              config.ModeBundle,
+			OutputFormat:      config.FormatESModule,
+			AbsOutputDir:      "/out",
+			OutputExtensionJS: ".mjs",
+			CJSWrapper:        true,
+		},
+	})
+}
+
+func TestCJSWrapperTopLevelAwait(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export let foo = await Promise.resolve(1)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			OutputFormat: config.FormatESModule,
+			AbsOutputDir: "/out",
+			CJSWrapper:   true,
+		},
+		expectedCompileLog: `entry.js: WARNING: Cannot generate a CommonJS wrapper for entry point "entry.js" because it uses top-level await
+NOTE: Node can't use "require()" to load ES modules that use top-level await, either directly or in one of the modules that they import.
+`,
+	})
+}
+
+func TestMinifySeed(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export function foo(first, second, third) {
+					let sum = first + second + third
+					return sum * sum
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:              config.ModeBundle,
+			OutputFormat:      config.FormatESModule,
+			AbsOutputFile:     "/out.js",
+			MinifyIdentifiers: true,
+			MinifySeed:        "release-2",
+		},
+	})
+}
+
+func TestNameCache(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { formatDate, parseDate } from "./util"
+				function helper() { return 1 }
+				function render(x) {
+					let value = formatDate(parseDate(x))
+					return value + helper()
+				}
+				console.log(render("2020"))
+			`,
+			"/util.js": `
+				export function formatDate(d) { return d.toISOString() }
+				export function parseDate(s) { return new Date(s) }
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:              config.ModeBundle,
+			OutputFormat:      config.FormatESModule,
+			AbsOutputFile:     "/out.js",
+			MinifyIdentifiers: true,
+			AbsNameCacheFile:  "/names.json",
+			NameCache: config.NewNameCache(map[string]string{
+				"util.js:formatDate": "fmt",
+				"util.js:parseDate":  "fmt", // Already taken, so this gets a new name
+				"entry.js:render":    "if",  // Reserved, so this gets a new name
+				"entry.js:helper":    "h",
+				"removed.js:gone":    "g", // Not part of this build, but kept
+			}),
+		},
+	})
+}
+
+func TestUnusedExportsWarning(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import {used} from './lib'
+				import {viaStar} from './barrel'
+				import * as ns from './captured'
+				import * as props from './props'
+				export {reExported} from './re-exported'
+				import 'pkg'
+				console.log(used, viaStar, ns, props.a, require('./required'), import('./dynamic'))
+			`,
+			"/lib.js": `
+				export let used = 1
+				export let unused = 2
+				export default function() {}
+			`,
+			"/barrel.js": `
+				export * from './star'
+				export let unusedInBarrel = 3
+			`,
+			"/star.js": `
+				export let viaStar = 4
+				export let unusedInStar = 5
+			`,
+			"/captured.js":    `export let a = 6, b = 7`,
+			"/props.js":       `export let a = 8, b = 9`,
+			"/re-exported.js": `export let reExported = 10, notReExported = 11`,
+			"/required.js":    `export let a = 12`,
+			"/dynamic.js":     `export let a = 13`,
+			"/node_modules/pkg/index.js": `
+				export let fromPackage = 14
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			UnusedExports: config.UnusedExportsWarning,
+		},
+		expectedCompileLog: `barrel.js: WARNING: The export "unusedInBarrel" is never imported by any other file
+lib.js: WARNING: The export "unused" is never imported by any other file
+lib.js: WARNING: The export "default" is never imported by any other file
+props.js: WARNING: The export "b" is never imported by any other file
+re-exported.js: WARNING: The export "notReExported" is never imported by any other file
+star.js: WARNING: The export "unusedInStar" is never imported by any other file
+`,
+	})
+}
+
+func TestUnusedExportsError(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {x} from './shared'
+				console.log(x)
+			`,
+			"/b.js": `
+				import {y} from './shared'
+				console.log(y)
+			`,
+			"/shared.js": `export let x = 1, y = 2, z = 3`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputDir:  "/out",
+			UnusedExports: config.UnusedExportsError,
+		},
+		expectedCompileLog: `shared.js: ERROR: The export "z" is never imported by any other file
+`,
+	})
+}
+
+func TestIndentUnitIIFE(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import "./style.css"
+				import {greet} from "./greet.js"
+				if (window.ready) {
+					console.log(greet("world"))
+				}
+			`,
+			"/greet.js": `
+				export function greet(name) {
+					return "Hello, " + name
+				}
+			`,
+			"/style.css": `
+				@media screen {
+					a { color: red }
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			OutputFormat: config.FormatIIFE,
+			AbsOutputDir: "/out",
+			IndentUnit:   "\t",
+		},
+	})
+}
+
+func TestDirectoryImportsNoIndex(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from "./a"
+				import b from "./b"
+				console.log(a, b)
+			`,
+			"/a/index.js":     `export default "a"`,
+			"/b/package.json": `{ "main": "./main.js" }`,
+			"/b/main.js":      `export default "b"`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputFile:    "/out.js",
+			DirectoryImports: config.DirectoryImportsNoIndex,
+		},
+		expectedScanLog: `entry.js: ERROR: Could not resolve "./a"
+`,
+	})
+}
+
+func TestDirectoryImportsNodeESM(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from "./a"
+				import b from "./b/"
+				console.log(a, b, require("./a"), import("./c/file"))
+			`,
+			"/a/index.js":     `export default "a"`,
+			"/b/index.mjs":    `export default "b"`,
+			"/c/file/file.js": `export default "c"`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputFile:    "/out.js",
+			ExtensionOrder:   []string{".mjs", ".js"},
+			DirectoryImports: config.DirectoryImportsNodeESM,
+		},
+		expectedScanLog: `entry.js: ERROR: Could not resolve "./a"
+NOTE: Importing the directory "a" is not supported in node's ECMAScript module mode
+entry.js: NOTE: Import from "./a/index.js" to get the file "a/index.js":
+entry.js: ERROR: Could not resolve "./b/"
+NOTE: Importing the directory "b" is not supported in node's ECMAScript module mode
+entry.js: NOTE: Import from "./b/index.mjs" to get the file "b/index.mjs":
+entry.js: ERROR: Could not resolve "./c/file"
+NOTE: Importing the directory "c/file" is not supported in node's ECMAScript module mode
+`,
+	})
+}
+
+func TestDirectoryImportsIndexExtensions(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from "./a"
+				import b from "./b"
+				console.log(a, b)
+			`,
+			"/a/index.js": `export default "a.js"`,
+			"/a/index.ts": `export default "a.ts"`,
+			"/b.js":       `export default "b.js"`,
+			"/b.ts":       `export default "b.ts"`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:            config.ModeBundle,
+			AbsOutputFile:   "/out.js",
+			IndexExtensions: []string{".js", ".ts"},
+		},
+	})
+}
+
+func TestMangleProps(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { make } from './shape'
+				let shape = make(1, 2)
+				shape.area_ = shape.width_ * shape.height_
+				console.log(shape, shape.area_, shape?.width_, shape["height_"])
+				let { width_, height_: h, ...rest } = shape
+				console.log(width_, h, rest)
+			`,
+			"/shape.js": `
+				export function make(width_, height_) {
+					return { width_, height_, "quoted_": true, a: 1, keep_: 2 }
+				}
+				export class Shape {
+					size_ = 0
+					constructor() { this.keep_ = this.size_ }
+					grow_() { return super.grow_ }
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			MangleProps:   regexp.MustCompile(`_$`),
+			ReserveProps:  regexp.MustCompile(`^keep`),
+		},
+	})
+}
+
+func TestManglePropsCache(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				let x = { cached_: 1, fresh_: 2, stale_: 3, b: 4 }
+				console.log(x.cached_, x.fresh_, x.stale_, x.fresh_)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			MangleProps:  regexp.MustCompile(`_$`),
+			MangleCache: map[string]string{
+				"cached_":  "x",
+				"stale_":   "b",
+				"notHere_": "a",
+			},
+			AbsMangleCacheFile: "/out/mangle-cache.json",
+		},
+	})
+}
+
+func TestManglePropsMinifySyntax(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				let x = { foo_: 1, bar: 2 }
+				console.log(x['foo_'], x['bar'], x.foo_, 1..foo_)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModePassThrough,
+			AbsOutputFile: "/out.js",
+			MangleSyntax:  true,
+			MangleProps:   regexp.MustCompile(`_$`),
+		},
+	})
+}
+
+func TestFeatureFlags(t *testing.T) {
+	featureFlag := func(name string, value bool) config.DefineData {
+		return config.DefineData{
+			DefineFunc:  func(config.DefineArgs) js_ast.E { return &js_ast.EBoolean{Value: value} },
+			FeatureFlag: name,
+		}
+	}
+	defines := config.ProcessDefines(map[string]config.DefineData{
+		"NEW_CHECKOUT":          featureFlag("NEW_CHECKOUT", true),
+		"flags.darkMode":        featureFlag("flags.darkMode", false),
+		"flags.neverReferenced": featureFlag("flags.neverReferenced", true),
+	})
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { theme } from './theme'
+				if (NEW_CHECKOUT) {
+					console.log('new checkout')
+				} else {
+					console.log('old checkout')
+				}
+				if (!NEW_CHECKOUT) console.log('legacy')
+				console.log(theme, NEW_CHECKOUT)
+			`,
+			"/theme.js": `
+				export let theme = flags.darkMode ? 'dark' : 'light'
+				if (flags.darkMode && NEW_CHECKOUT) {
+					console.log('dark checkout')
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                 config.ModeBundle,
+			AbsOutputDir:         "/out",
+			Defines:              &defines,
+			FeatureFlags:         map[string]bool{"NEW_CHECKOUT": true, "flags.darkMode": false, "flags.neverReferenced": true},
+			AbsFeatureReportFile: "/out/features.json",
+		},
+	})
+}
+
+func TestRewriteImports(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/entry.ts": `
+				import { a } from './util'
+				import { b } from './util.ts'
+				import c from './dir'
+				import d from './data.json'
+				import e from 'pkg'
+				import type { T } from './types'
+				export * from '../shared/index.mjs'
+				import('./util')
+				console.log(a, b, c, d, e)
+			`,
+			"/src/util.ts":               `export let a = 1, b = 2`,
+			"/src/dir/index.jsx":         `export default 3`,
+			"/src/data.json":             `{}`,
+			"/src/types.ts":              `export type T = number`,
+			"/shared/index.mjs":          `export let f = 4`,
+			"/node_modules/pkg/index.js": `export default 5`,
+		},
+		entryPaths: []string{"/src/entry.ts"},
+		options: config.Options{
+			Mode:              config.ModeConvertFormat,
+			OutputFormat:      config.FormatESModule,
+			OutputExtensionJS: ".mjs",
+			AbsOutputFile:     "/out.js",
+			RewriteImports:    true,
+		},
+	})
+}
+
+func TestRewriteImportsUnresolved(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import './missing'
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:           config.ModeConvertFormat,
+			OutputFormat:   config.FormatESModule,
+			AbsOutputFile:  "/out.js",
+			RewriteImports: true,
+		},
+		expectedScanLog: `entry.js: WARNING: Could not resolve "./missing" to rewrite its path
+`,
+	})
+}
+
+func TestPreserveModules(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/entry.ts": `
+				import { a } from './util'
+				import c from './dir'
+				import e from 'pkg'
+				import('./lazy')
+				console.log(a, c, e)
+			`,
+			"/src/util.ts":               `export { b as a } from './nested/b.ts'`,
+			"/src/nested/b.ts":           `import { a } from '../util'; export let b = 1; console.log(a)`,
+			"/src/dir/index.jsx":         `export default <div/>`,
+			"/src/lazy.js":               `export default 3`,
+			"/node_modules/pkg/index.js": `export default 5`,
+		},
+		entryPaths: []string{"/src/entry.ts"},
+		options: config.Options{
+			Mode:            config.ModeConvertFormat,
+			OutputFormat:    config.FormatESModule,
+			AbsOutputDir:    "/out",
+			RewriteImports:  true,
+			PreserveModules: true,
+		},
+	})
+}
+
+func TestPreserveModulesOutsideOutbase(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/entry.js": `
+				import '../shared/util.js'
+			`,
+			"/shared/util.js": `console.log('util')`,
+		},
+		entryPaths: []string{"/src/entry.js"},
+		options: config.Options{
+			Mode:            config.ModeConvertFormat,
+			OutputFormat:    config.FormatESModule,
+			AbsOutputDir:    "/out",
+			RewriteImports:  true,
+			PreserveModules: true,
+		},
+		expectedScanLog: `src/entry.js: ERROR: Cannot preserve the module "shared/util.js" because it's outside of the output base directory
+NOTE: The output base directory is "src". You can use "--outbase" to choose a directory that contains all input files.
+`,
+	})
+}
+
+func TestNodePolyfills(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import { join } from 'node:path'
+				console.log(join('a', 'b'), process.platform)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			Platform:      config.PlatformBrowser,
+			AbsOutputFile: "/out.js",
+			NodePolyfills: true,
+		},
+	})
+}
+
+func TestNodePolyfillsUnusedGlobals(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import 'events'
+				let process = { platform: 'custom' }
+				console.log(process.platform)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			Platform:      config.PlatformBrowser,
+			AbsOutputFile: "/out.js",
+			NodePolyfills: true,
+		},
+	})
+}
+
+func TestNodePolyfillsUnsupportedModule(t *testing.T) {
+	default_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import fs from 'fs'
+				console.log(fs)
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			Platform:      config.PlatformBrowser,
+			AbsOutputFile: "/out.js",
+			NodePolyfills: true,
+		},
+		expectedScanLog: `entry.js: ERROR: Could not resolve "fs"
+NOTE: The package "fs" wasn't found on the file system but is built into node. Are you trying to bundle for node? You can use "Platform: api.PlatformNode" to do that, which will remove this error.
+`,
+	})
+}
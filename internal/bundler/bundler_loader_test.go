@@ -1,6 +1,10 @@
 package bundler
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
 	"testing"
 
 	"github.com/evanw/esbuild/internal/compat"
@@ -309,6 +313,233 @@ func TestLoaderJSONMissingES6(t *testing.T) {
 	})
 }
 
+func TestLoaderJSONCAndJSON5(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import jsonc from './settings.jsonc'
+				import {name, sizes} from './config.json5'
+				console.log(jsonc, name, sizes)
+			`,
+			"/settings.jsonc": `
+				// Comments and trailing commas are allowed
+				{
+					"editor.tabSize": 2, /* spaces */
+				}
+			`,
+			"/config.json5": `
+				{
+					name: 'json5',
+					sizes: [+1, 0x10, .5, Infinity,],
+				}
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestLoaderYAML(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import config from './config.yaml'
+				import {name, servers} from './config.yml'
+				console.log(config, name, servers)
+			`,
+			"/config.yaml": "# YAML indentation can't use tabs\n" +
+				"debug: false\n" +
+				"ports: [80, 443]\n",
+			"/config.yml": "name: yaml\n" +
+				"defaults: &defaults\n" +
+				"  timeout: 30\n" +
+				"servers:\n" +
+				"  - host: a.example.com\n" +
+				"    <<: *defaults\n" +
+				"  - host: b.example.com\n" +
+				"    timeout: 60\n",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestLoaderYAMLSyntaxError(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import config from './config.yaml'
+				console.log(config)
+			`,
+			"/config.yaml": "first: 1\n" +
+				"---\n" +
+				"second: 2\n",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `config.yaml: ERROR: Multiple documents in one YAML file are not supported
+`,
+	})
+}
+
+func TestLoaderTOML(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import config from './config.toml'
+				import {name, servers} from './Cargo.toml'
+				console.log(config, name, servers)
+			`,
+			"/config.toml": "debug = false\n" +
+				"ports = [80, 443]\n",
+			"/Cargo.toml": "name = \"toml\"\n" +
+				"version = \"1.0.0\"\n" +
+				"\n" +
+				"[[servers]]\n" +
+				"host = \"a.example.com\"\n" +
+				"timeout = 30\n" +
+				"\n" +
+				"[[servers]]\n" +
+				"host = \"b.example.com\"\n" +
+				"timeout = 60\n",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+	})
+}
+
+func TestLoaderTOMLSyntaxError(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import config from './config.toml'
+				console.log(config)
+			`,
+			"/config.toml": "[package]\n" +
+				"name = \"a\"\n" +
+				"\n" +
+				"[package]\n" +
+				"version = \"1.0.0\"\n",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `config.toml: ERROR: Cannot define the table "package" more than once
+config.toml: NOTE: The key "package" was originally defined here:
+`,
+	})
+}
+
+func TestLoaderCopyWithBundleFromJS(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import wasmURL from "../assets/some.file"
+				import "./worker.js"
+				console.log(wasmURL, require("../assets/some.file"))
+			`,
+			"/Users/user/project/src/worker.js": `
+				self.onmessage = () => {}
+			`,
+			"/Users/user/project/assets/some.file": `stuff`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputBase: "/Users/user/project",
+			AbsOutputDir:  "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":   config.LoaderJS,
+				".file": config.LoaderCopy,
+			},
+		},
+	})
+}
+
+func TestLoaderCopyWithBundleFromCSS(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.css": `
+				body {
+					background: url(../assets/some.file);
+				}
+			`,
+			"/Users/user/project/assets/some.file": `stuff`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.css"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputBase: "/Users/user/project",
+			AbsOutputDir:  "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".css":  config.LoaderCSS,
+				".file": config.LoaderCopy,
+			},
+		},
+	})
+}
+
+func TestLoaderCopyEntryPoint(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import data from "../assets/data.file"
+				console.log(data)
+			`,
+			"/Users/user/project/assets/data.file": `stuff`,
+			"/Users/user/project/assets/static.file": `static`,
+		},
+		entryPaths: []string{
+			"/Users/user/project/src/entry.js",
+			"/Users/user/project/assets/static.file",
+		},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputBase: "/Users/user/project",
+			AbsOutputDir:  "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":   config.LoaderJS,
+				".file": config.LoaderCopy,
+			},
+		},
+	})
+}
+
+func TestLoaderJSONCInvalidJSON5(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import jsonc from './settings.jsonc'
+				console.log(jsonc)
+			`,
+			"/settings.jsonc": `{ tabSize: 2 }`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+		},
+		expectedScanLog: `settings.jsonc: ERROR: Expected string but found "tabSize"
+`,
+	})
+}
+
 func TestLoaderTextCommonJSAndES6(t *testing.T) {
 	loader_suite.expectBundled(t, bundled{
 		files: map[string]string{
@@ -959,3 +1190,168 @@ func TestLoaderDataURLUnknownMIME(t *testing.T) {
 		},
 	})
 }
+
+func TestLoaderWebManifest(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import manifest from './app.webmanifest'
+				console.log(manifest)
+			`,
+			"/app.webmanifest": `{
+				"name": "Example",
+				"start_url": "/",
+				"icons": [
+					{ "src": "icons/192.png", "sizes": "192x192", "type": "image/png" },
+					{ "src": "/icons/512.png", "sizes": "512x512", "type": "image/png" }
+				],
+				"screenshots": [
+					{ "src": "./screenshot.png" },
+					{ "src": "https://example.com/screenshot.png" }
+				],
+				"shortcuts": [
+					{ "name": "New", "url": "/new", "icons": [{ "src": "icons/new.svg" }] }
+				]
+			}`,
+			"/icons/192.png":  "192",
+			"/screenshot.png": "screenshot",
+			"/icons/new.svg":  "<svg></svg>",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out/",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":          config.LoaderJS,
+				".png":         config.LoaderFile,
+				".svg":         config.LoaderDataURL,
+				".webmanifest": config.LoaderWebManifest,
+			},
+		},
+	})
+}
+
+func TestLoaderWebManifestAssetPathTemplate(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.css": `
+				link { background: url(public/app.webmanifest) }
+			`,
+			"/public/app.webmanifest": `{ "icons": [{ "src": "../images/icon.png" }] }`,
+			"/images/icon.png":        "icon",
+		},
+		entryPaths: []string{"/entry.css"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out/",
+			ExtensionToLoader: map[string]config.Loader{
+				".css":         config.LoaderCSS,
+				".png":         config.LoaderFile,
+				".webmanifest": config.LoaderWebManifest,
+			},
+			AssetPathTemplate: []config.PathTemplate{
+				{Data: "manifest/", Placeholder: config.DirPlaceholder},
+				{Data: "/", Placeholder: config.NamePlaceholder},
+				{Data: "-", Placeholder: config.HashPlaceholder},
+			},
+		},
+	})
+}
+
+func TestLoaderWebManifestMissingIcon(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import manifest from './app.webmanifest'
+				console.log(manifest)
+			`,
+			"/app.webmanifest": `{ "icons": [{ "src": "missing.png" }, { "src": "entry.js" }] }`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out/",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":          config.LoaderJS,
+				".png":         config.LoaderFile,
+				".webmanifest": config.LoaderWebManifest,
+			},
+		},
+		expectedScanLog: `app.webmanifest: ERROR: Could not resolve "missing.png"
+NOTE: You can mark the path "missing.png" as external to exclude it from the bundle, which will remove this error.
+app.webmanifest: ERROR: Cannot use "entry.js" as a URL
+`,
+	})
+}
+
+// This is a 4x2 PNG image that's used by the image loader tests
+func testImagePNG() string {
+	buffer := bytes.Buffer{}
+	png.Encode(&buffer, image.NewRGBA(image.Rect(0, 0, 4, 2)))
+	return buffer.String()
+}
+
+// The output of this encoder is text so that it can be read in snapshots
+func testImageEncoder(img image.Image, quality int) ([]byte, error) {
+	size := img.Bounds().Size()
+	return []byte(fmt.Sprintf("%dx%d quality=%d", size.X, size.Y, quality)), nil
+}
+
+func TestLoaderImage(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from './hero.png?format=txt'
+				import b from './hero.png?w=2&format=txt'
+				import c from './hero.png?height=4&quality=50&format=txt'
+				import d from './hero.png?w=1&h=1&format=txt#ignored'
+				console.log(a, b, c, d)
+			`,
+			"/entry.css": `
+				a { background: url(./hero.png?w=8&format=txt) }
+			`,
+			"/hero.png": testImagePNG(),
+		},
+		entryPaths: []string{"/entry.js", "/entry.css"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".css": config.LoaderCSS,
+				".png": config.LoaderImage,
+			},
+			ImageEncoders: map[string]func(image.Image, int) ([]byte, error){
+				"txt": testImageEncoder,
+			},
+		},
+	})
+}
+
+func TestLoaderImageErrors(t *testing.T) {
+	loader_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import a from './hero.png?format=webp'
+				import b from './hero.png?w=0'
+				import c from './broken.png?w=10'
+				console.log(a, b, c)
+			`,
+			"/hero.png":   testImagePNG(),
+			"/broken.png": "not an image",
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:         config.ModeBundle,
+			AbsOutputDir: "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".png": config.LoaderImage,
+			},
+		},
+		expectedScanLog: `entry.js: ERROR: hero.png?format=webp: Cannot encode images in the "webp" format without a custom image encoder
+entry.js: ERROR: hero.png?w=0: Invalid value for "w": "0" (must be an integer from 1 to 65535)
+entry.js: ERROR: broken.png?w=10: Cannot decode image: image: unknown format
+`,
+	})
+}
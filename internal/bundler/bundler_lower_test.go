@@ -2120,3 +2120,69 @@ func TestStaticClassBlockES2021(t *testing.T) {
 		},
 	})
 }
+
+func TestLowerExternalHelpersESM(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				import * as ns from './foo.cjs'
+				export class A { x = 1; static y = 2 }
+				export async function f() { await 1 }
+				console.log({...ns})
+			`,
+			"/foo.cjs": `
+				module.exports = 123
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			OutputFormat:          config.FormatESModule,
+			AbsOutputFile:         "/out.js",
+			UnsupportedJSFeatures: es(2016),
+			ExternalHelpers:       "esbuild-helpers",
+		},
+	})
+}
+
+func TestLowerExternalHelpersCommonJS(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.js": `
+				export class A { x = 1 }
+				console.log({...a})
+			`,
+		},
+		entryPaths: []string{"/entry.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			OutputFormat:          config.FormatCommonJS,
+			AbsOutputFile:         "/out.js",
+			UnsupportedJSFeatures: es(2016),
+			ExternalHelpers:       "esbuild-helpers",
+		},
+	})
+}
+
+func TestLowerExternalHelpersCodeSplitting(t *testing.T) {
+	lower_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				export class A { x = 1 }
+			`,
+			"/b.js": `
+				export class B { y = 2 }
+				console.log({...b})
+			`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:                  config.ModeBundle,
+			CodeSplitting:         true,
+			OutputFormat:          config.FormatESModule,
+			AbsOutputDir:          "/out",
+			UnsupportedJSFeatures: es(2016),
+			ExternalHelpers:       "esbuild-helpers",
+		},
+	})
+}
@@ -2193,3 +2193,514 @@ NOTE: You can mark the path "foo" as external to exclude it from the bundle, whi
 `,
 	})
 }
+
+func TestPackageJsonWorkspace(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/packages/app/src/entry.js": `
+				import { fn } from '@repo/utils'
+				import '@repo/utils/extra'
+				console.log(fn())
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/utils/package.json": `
+				{
+					"main": "./stale.js"
+				}
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/utils/stale.js": `
+				export let fn = () => 'stale'
+			`,
+			"/Users/user/project/packages/utils/package.json": `
+				{
+					"main": "./src/index.js"
+				}
+			`,
+			"/Users/user/project/packages/utils/src/index.js": `
+				import dep from 'dep'
+				export let fn = () => dep
+			`,
+			"/Users/user/project/packages/utils/extra.js": `
+				console.log('extra')
+			`,
+			"/Users/user/project/packages/utils/node_modules/dep/index.js": `
+				export default 'dep'
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/packages/app/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			Workspaces: map[string]string{
+				"@repo/utils": "/Users/user/project/packages/utils",
+			},
+		},
+	})
+}
+
+func TestPackageJsonWorkspaceExports(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/packages/app/src/entry.js": `
+				import '@repo/utils'
+				import '@repo/utils/internal'
+			`,
+			"/Users/user/project/packages/utils/package.json": `
+				{
+					"exports": {
+						".": "./src/index.js"
+					}
+				}
+			`,
+			"/Users/user/project/packages/utils/src/index.js": `
+				console.log('index')
+			`,
+			"/Users/user/project/packages/utils/internal.js": `
+				console.log('internal')
+			`,
+			"/Users/user/project/node_modules/@repo/utils/internal.js": `
+				console.log('stale')
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/packages/app/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			Workspaces: map[string]string{
+				"@repo/utils": "/Users/user/project/packages/utils",
+			},
+		},
+		expectedScanLog: `Users/user/project/packages/app/src/entry.js: ERROR: Could not resolve "@repo/utils/internal"
+Users/user/project/packages/utils/package.json: NOTE: The path "./internal" is not exported by package "@repo/utils":
+NOTE: You can mark the path "@repo/utils/internal" as external to exclude it from the bundle, which will remove this error.
+`,
+	})
+}
+
+func TestPackageJsonWorkspaceFallback(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/packages/app/src/entry.js": `
+				import { fn } from '@repo/utils'
+				import '@repo/utils/generated'
+				import '@repo/missing'
+				console.log(fn())
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/utils/package.json": `
+				{
+					"main": "./stale.js"
+				}
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/utils/stale.js": `
+				export let fn = () => 'stale'
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/utils/generated.js": `
+				console.log('generated')
+			`,
+			"/Users/user/project/packages/app/node_modules/@repo/missing/index.js": `
+				console.log('missing')
+			`,
+			"/Users/user/project/packages/utils/package.json": `
+				{
+					"main": "./src/index.js"
+				}
+			`,
+			"/Users/user/project/packages/utils/src/index.js": `
+				export let fn = () => 'utils'
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/packages/app/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			Workspaces: map[string]string{
+				"@repo/utils":   "/Users/user/project/packages/utils",
+				"@repo/missing": "/Users/user/project/packages/missing",
+			},
+		},
+	})
+}
+
+func TestPackageJsonDetectWorkspacesNPM(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/package.json": `
+				{
+					"private": true,
+					"workspaces": ["packages/*", "!packages/ignored"]
+				}
+			`,
+			"/packages/app/src/entry.js": `
+				import a from 'a'
+				import b from '@scope/b'
+				import ignored from 'ignored'
+				console.log(a, b, ignored)
+			`,
+			"/packages/a/package.json": `
+				{
+					"name": "a"
+				}
+			`,
+			"/packages/a/index.js": `
+				export default 'a'
+			`,
+			"/packages/b/package.json": `
+				{
+					"name": "@scope/b",
+					"main": "main.js"
+				}
+			`,
+			"/packages/b/main.js": `
+				export default 'b'
+			`,
+			"/packages/ignored/package.json": `
+				{
+					"name": "ignored"
+				}
+			`,
+			"/packages/ignored/index.js": `
+				export default 'ignored'
+			`,
+		},
+		entryPaths: []string{"/packages/app/src/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputFile:    "/out.js",
+			DetectWorkspaces: true,
+		},
+		expectedScanLog: `packages/app/src/entry.js: ERROR: Could not resolve "ignored"
+NOTE: You can mark the path "ignored" as external to exclude it from the bundle, which will remove this error.
+`,
+	})
+}
+
+func TestPackageJsonDetectWorkspacesPNPM(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/pnpm-workspace.yaml": `
+packages:
+  # Every package in "libs"
+  - 'libs/**'
+  - "!libs/private/**"
+			`,
+			"/apps/web/entry.js": `
+				import a from 'a'
+				import b from 'b'
+				console.log(a, b)
+			`,
+			"/libs/a/package.json": `
+				{
+					"name": "a"
+				}
+			`,
+			"/libs/a/index.js": `
+				export default 'a'
+			`,
+			"/libs/nested/b/package.json": `
+				{
+					"name": "b"
+				}
+			`,
+			"/libs/nested/b/index.js": `
+				export default 'b'
+			`,
+		},
+		entryPaths: []string{"/apps/web/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			AbsOutputFile:    "/out.js",
+			DetectWorkspaces: true,
+		},
+	})
+}
+
+func TestPackageJsonDenoSpecifiers(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import react from 'npm:react@^18.2.0'
+				import runtime from 'npm:/react@18/jsx-runtime'
+				import node from 'npm:@types/node'
+				import { join } from 'jsr:@std/path@1'
+				import { posix } from 'jsr:@std/path@^1.0.0/posix'
+				console.log(react, runtime, node, join, posix)
+			`,
+			"/Users/user/project/node_modules/react/index.js":       `export default 'react'`,
+			"/Users/user/project/node_modules/react/jsx-runtime.js": `export default 'runtime'`,
+			"/Users/user/project/node_modules/@types/node/index.js": `export default 'node'`,
+			"/Users/user/project/node_modules/@jsr/std__path/package.json": `
+				{
+					"exports": {
+						".": "./mod.js",
+						"./posix": "./posix.js"
+					}
+				}
+			`,
+			"/Users/user/project/node_modules/@jsr/std__path/mod.js":   `export let join = 'join'`,
+			"/Users/user/project/node_modules/@jsr/std__path/posix.js": `export let posix = 'posix'`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+	})
+}
+
+func TestPackageJsonDenoDir(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import exact from 'npm:pkg@1.2.3/sub'
+				import ranged from 'npm:pkg@^1.2.3/sub'
+				console.log(exact, ranged)
+			`,
+			"/Users/user/project/node_modules/pkg/sub.js":                    `export default 'node_modules'`,
+			"/Users/user/deno/npm/registry.npmjs.org/pkg/1.2.3/sub.js":       `export default 'deno cache'`,
+			"/Users/user/deno/npm/registry.npmjs.org/pkg/1.2.3/package.json": `{ "name": "pkg" }`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			AbsDenoDir:    "/Users/user/deno",
+		},
+	})
+}
+
+func TestPackageJsonDenoSpecifierExternal(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import react from 'npm:react@18.2.0'
+				import runtime from 'npm:react@^18/jsx-runtime'
+				import { join } from 'jsr:@std/path@1'
+				import other from 'npm:other@1.0.0'
+				console.log(react, runtime, join, other)
+			`,
+			"/Users/user/deno/npm/registry.npmjs.org/react/18.2.0/index.js": `export default 'deno cache'`,
+			"/Users/user/deno/npm/registry.npmjs.org/other/1.0.0/index.js":  `export default 'deno cache'`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputFile: "/Users/user/project/out.js",
+			AbsDenoDir:    "/Users/user/deno",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"react": true,
+				},
+				Patterns: []config.WildcardPattern{
+					{Prefix: "@jsr/"},
+				},
+			},
+		},
+	})
+}
+
+func TestPackageJsonDenoDirDependencies(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import pkg from 'npm:pkg@1.0.0'
+				console.log(pkg)
+			`,
+			"/Users/user/project/node_modules/caret/index.js": `export default 'node_modules'`,
+			"/Users/user/deno/npm/registry.npmjs.org/pkg/1.0.0/package.json": `
+				{
+					"dependencies": {
+						"caret": "^1.2.0",
+						"tilde": "~2.1",
+						"@scope/either": "1.x || >=3.0.0 <3.1.0",
+						"hyphen": "1.0.0 - 1.5"
+					},
+					"peerDependencies": {
+						"undeclared-range": "latest"
+					}
+				}
+			`,
+			"/Users/user/deno/npm/registry.npmjs.org/pkg/1.0.0/index.js": `
+				import caret from 'caret'
+				import tilde from 'tilde/sub'
+				import either from '@scope/either'
+				import hyphen from 'hyphen'
+				import undeclared from 'undeclared'
+				import undeclaredRange from 'undeclared-range'
+				export default [caret, tilde, either, hyphen, undeclared, undeclaredRange]
+			`,
+			"/Users/user/deno/npm/registry.npmjs.org/caret/1.1.0/index.js":            `export default 'caret 1.1.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/caret/1.10.0/index.js":           `export default 'caret 1.10.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/caret/1.11.0-beta/index.js":      `export default 'caret 1.11.0-beta'`,
+			"/Users/user/deno/npm/registry.npmjs.org/caret/2.0.0/index.js":            `export default 'caret 2.0.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/tilde/2.1.9/sub.js":              `export default 'tilde 2.1.9'`,
+			"/Users/user/deno/npm/registry.npmjs.org/tilde/2.2.0/sub.js":              `export default 'tilde 2.2.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/@scope/either/2.0.0/index.js":    `export default 'either 2.0.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/@scope/either/3.0.5/index.js":    `export default 'either 3.0.5'`,
+			"/Users/user/deno/npm/registry.npmjs.org/@scope/either/3.1.0/index.js":    `export default 'either 3.1.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/hyphen/1.5.3/index.js":           `export default 'hyphen 1.5.3'`,
+			"/Users/user/deno/npm/registry.npmjs.org/hyphen/1.6.0/index.js":           `export default 'hyphen 1.6.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/undeclared/0.9.0/index.js":       `export default 'undeclared 0.9.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/undeclared/0.10.0/index.js":      `export default 'undeclared 0.10.0'`,
+			"/Users/user/deno/npm/registry.npmjs.org/undeclared-range/1.0.0/index.js": `export default 'undeclared-range 1.0.0'`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			AbsDenoDir:    "/Users/user/deno",
+		},
+	})
+}
+
+func TestPackageJsonDenoSpecifierMissing(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import 'npm:missing@1.0.0'
+				import 'jsr:unscoped'
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+		},
+		expectedScanLog: `Users/user/project/src/entry.js: ERROR: Could not resolve "npm:missing@1.0.0"
+NOTE: You can mark the path "npm:missing@1.0.0" as external to exclude it from the bundle, which will remove this error.
+Users/user/project/src/entry.js: ERROR: Could not resolve "jsr:unscoped"
+NOTE: You can mark the path "jsr:unscoped" as external to exclude it from the bundle, which will remove this error.
+`,
+	})
+}
+
+func TestPackageJsonEnginesNewerThanTarget(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import a from 'old'
+				import b from 'new'
+				import c from 'invalid'
+				console.log(a, b, c)
+			`,
+			"/Users/user/project/package.json":              `{ "engines": { "node": ">=20" } }`,
+			"/Users/user/project/node_modules/old/index.js": `module.exports = 1`,
+			"/Users/user/project/node_modules/old/package.json": `{
+				"engines": { "node": ">=12 <18" }
+			}`,
+			"/Users/user/project/node_modules/new/index.js": `module.exports = 2`,
+			"/Users/user/project/node_modules/new/package.json": `{
+				"engines": { "node": "^14.18 || >=16" }
+			}`,
+			"/Users/user/project/node_modules/invalid/index.js": `module.exports = 3`,
+			"/Users/user/project/node_modules/invalid/package.json": `{
+				"engines": { "node": "lts" }
+			}`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:              config.ModeBundle,
+			Platform:          config.PlatformNode,
+			AbsOutputFile:     "/Users/user/project/out.js",
+			TargetNodeVersion: []int{14, 17},
+		},
+		expectedScanLog: `Users/user/project/node_modules/new/package.json: WARNING: This package requires node ^14.18 || >=16, which is newer than the target of node 14.17
+`,
+	})
+}
+
+func TestPackageAlias(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import React from 'react'
+				import { jsx } from 'react/jsx-runtime'
+				import shim from 'old-shim'
+				import '@scope/pkg/sub'
+				import 'react-dom'
+				console.log(React, jsx, shim)
+			`,
+			"/Users/user/project/node_modules/preact/compat/index.js":             `export default 'preact/compat'`,
+			"/Users/user/project/node_modules/preact/compat/jsx-runtime/index.js": `export let jsx = 'preact/compat/jsx-runtime'`,
+			"/Users/user/project/node_modules/react-dom/index.js":                 `console.log('react-dom')`,
+			"/Users/user/project/node_modules/@other/pkg/sub.js":                  `console.log('@other/pkg/sub')`,
+			"/Users/user/project/shims/new-shim.js":                               `export default 'new-shim'`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/Users/user/project/out.js",
+			PackageAliases: map[string]string{
+				"react":      "preact/compat",
+				"old-shim":   "/Users/user/project/shims/new-shim.js",
+				"@scope/pkg": "@other/pkg",
+			},
+		},
+	})
+}
+
+func TestPackageAliasExternal(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import fs from 'fs-alias'
+				import React from 'react'
+				console.log(fs, React)
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			OutputFormat:  config.FormatESModule,
+			Platform:      config.PlatformNode,
+			AbsOutputFile: "/Users/user/project/out.js",
+			ExternalModules: config.ExternalModules{
+				NodeModules: map[string]bool{
+					"preact": true,
+				},
+			},
+			PackageAliases: map[string]string{
+				"fs-alias": "node:fs",
+				"react":    "preact/compat",
+			},
+		},
+	})
+}
+
+func TestPackagesExternal(t *testing.T) {
+	packagejson_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/Users/user/project/src/entry.js": `
+				import foo from 'foo'
+				import bar from '@scope/bar/sub'
+				import util from '@app/util'
+				import local from './local'
+				console.log(foo, bar, util, local)
+			`,
+			"/Users/user/project/src/local.js": `
+				export default 'local'
+			`,
+			"/Users/user/project/src/util.js": `
+				export default 'util'
+			`,
+			"/Users/user/project/tsconfig.json": `
+				{
+					"compilerOptions": {
+						"paths": {
+							"@app/*": ["./src/*"]
+						}
+					}
+				}
+			`,
+			"/Users/user/project/node_modules/foo/index.js": `
+				export default 'foo'
+			`,
+		},
+		entryPaths: []string{"/Users/user/project/src/entry.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			OutputFormat:     config.FormatESModule,
+			AbsOutputFile:    "/Users/user/project/out.js",
+			ExternalPackages: true,
+		},
+	})
+}
@@ -1,9 +1,11 @@
 package bundler
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logger"
 )
 
 var splitting_suite = suite{
@@ -525,3 +527,519 @@ func TestSplittingPublicPathEntryName(t *testing.T) {
 		},
 	})
 }
+
+func TestSplittingPrecacheManifest(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				import logo from "./logo.png"
+				console.log(foo, logo)
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/shared.js": `export let foo = 123`,
+			"/logo.png":  `PNG`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".png": config.LoaderFile,
+			},
+			SourceMap:               config.SourceMapLinkedWithComment,
+			AbsPrecacheManifestFile: "/out/precache/manifest.json",
+		},
+	})
+}
+
+func TestSplittingServiceWorkerPublicPath(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import("./b.js")
+			`,
+			"/b.js": `
+				console.log('b')
+			`,
+		},
+		entryPaths: []string{"/a.js"},
+		options: config.Options{
+			Mode:                 config.ModeBundle,
+			CodeSplitting:        true,
+			OutputFormat:         config.FormatESModule,
+			AbsOutputDir:         "/out",
+			PublicPath:           "https://example.com/static",
+			AbsServiceWorkerFile: "/out/sw.js",
+		},
+	})
+}
+
+func TestSplittingCSPReport(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo, "__ESBUILD_CSP_NONCE__")
+			`,
+			"/shared.js": `export let foo = 123`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			CodeSplitting:    true,
+			OutputFormat:     config.FormatESModule,
+			AbsOutputDir:     "/out",
+			SourceMap:        config.SourceMapLinkedWithComment,
+			AbsCSPReportFile: "/out/csp.json",
+		},
+	})
+}
+
+func TestSplittingPresetVendor(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				import {lib} from "lib"
+				console.log(foo, lib)
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				const other = require("other")
+				console.log(foo, other)
+			`,
+			"/c.js": `
+				import {lib} from "lib"
+				console.log(lib)
+			`,
+			"/shared.js":                   `export let foo = 123`,
+			"/node_modules/lib/index.js":   `export let lib = 234`,
+			"/node_modules/other/index.js": `module.exports = 345`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			Mode:            config.ModeBundle,
+			CodeSplitting:   true,
+			SplittingPreset: config.SplittingPresetVendor,
+			OutputFormat:    config.FormatESModule,
+			AbsOutputDir:    "/out",
+		},
+	})
+}
+
+func TestSplittingIsolatedChunks(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {track} from "@corp/analytics"
+				import {variant} from "./experiments/ab.js"
+				console.log(track, variant)
+			`,
+			"/b.js": `
+				import {variant} from "./experiments/ab.js"
+				console.log(variant)
+			`,
+			"/experiments/ab.js":                     `export let variant = 123`,
+			"/node_modules/@corp/analytics/index.js": `export {track} from "./track.js"`,
+			"/node_modules/@corp/analytics/track.js": `export let track = 234`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			IsolatedChunks: []config.IsolatedChunk{
+				{Name: "corp-analytics", Package: "@corp/analytics"},
+				{Name: "experiments", Pattern: config.WildcardPattern{Prefix: "/experiments"}},
+			},
+		},
+	})
+}
+
+func TestSplittingManualChunks(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/a.js": `
+				import {react} from "react"
+				import {format} from "./utils/format.js"
+				console.log(react, format)
+			`,
+			"/src/b.js": `
+				import {lodash} from "lodash"
+				import {log} from "./utils/log.js"
+				console.log(lodash, log)
+			`,
+			"/src/utils/format.js":          `export let format = 123`,
+			"/src/utils/log.js":             `export let log = 234`,
+			"/node_modules/react/index.js":  `export let react = 345`,
+			"/node_modules/lodash/index.js": `export let lodash = 456`,
+		},
+		entryPaths: []string{"/src/a.js", "/src/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			IsolatedChunks: []config.IsolatedChunk{
+				{Name: "vendor", Pattern: config.WildcardPattern{Prefix: "/node_modules/", Suffix: ""}, IsWildcard: true},
+			},
+			ManualChunks: func(path logger.Path) string {
+				if strings.HasPrefix(path.Text, "/src/utils/") {
+					return "app"
+				}
+				return ""
+			},
+		},
+	})
+}
+
+func TestSplittingContentManifest(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/a.js": `
+				import {foo} from "./shared.js"
+				import logo from "./logo.png"
+				console.log(foo, logo)
+			`,
+			"/src/b.js": `
+				import {foo} from "./shared.js"
+				import "./b.css"
+				console.log(foo)
+			`,
+			"/src/b.css":     `body { color: red }`,
+			"/src/shared.js": `export let foo = 123`,
+			"/src/logo.png":  `PNG`,
+		},
+		entryPaths: []string{"/src/a.js", "/src/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ExtensionToLoader: map[string]config.Loader{
+				".js":  config.LoaderJS,
+				".css": config.LoaderCSS,
+				".png": config.LoaderFile,
+			},
+			SourceMap:              config.SourceMapLinkedWithComment,
+			AbsContentManifestFile: "/out/manifest.json",
+		},
+	})
+}
+
+func TestSplittingDualPackage(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/index.js": `
+				import {foo} from "./shared.js"
+				import "./style.css"
+				export default function greet() { return foo }
+			`,
+			"/src/other.js": `
+				import {foo} from "./shared.js"
+				export let bar = foo + 1
+			`,
+			"/src/style.css": `body { color: red }`,
+			"/src/shared.js": `export let foo = 123`,
+		},
+		entryPaths: []string{"/src/index.js", "/src/other.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			DualPackage:   true,
+			AbsOutputDir:  "/out",
+		},
+		expectedCompileLog: `INFO: Suggested "exports" field for "package.json":
+NOTE: "exports": {
+  ".": {
+    "import": {
+      "types": "./out/index.d.mts",
+      "default": "./out/index.mjs"
+    },
+    "require": {
+      "types": "./out/index.d.cts",
+      "default": "./out/index.cjs"
+    }
+  },
+  "./other": {
+    "import": {
+      "types": "./out/other.d.mts",
+      "default": "./out/other.mjs"
+    },
+    "require": {
+      "types": "./out/other.d.cts",
+      "default": "./out/other.cjs"
+    }
+  }
+}
+`,
+	})
+}
+
+func TestSplittingMultipleFormats(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/src/index.js": `
+				import {foo} from "./shared.js"
+				export default foo
+				export let lazy = () => import("./lazy.js")
+			`,
+			"/src/shared.js": `export let foo = 123`,
+			"/src/lazy.js":   `export let bar = 234`,
+		},
+		entryPaths: []string{"/src/index.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			OutputFormats: []config.FormatOutput{
+				{Format: config.FormatESModule, OutputExtensionJS: ".mjs"},
+				{Format: config.FormatCommonJS, OutputExtensionJS: ".cjs"},
+				{Format: config.FormatIIFE, OutputExtensionJS: ".iife.js"},
+				{Format: config.FormatUMD, OutputExtensionJS: ".umd.js"},
+			},
+			GlobalName:   []string{"lib"},
+			AbsOutputDir: "/out",
+		},
+	})
+}
+
+func TestSplittingDualPackageNoBundle(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/index.js": `
+				import {foo} from "./shared.js"
+				console.log(import.meta.url)
+				export default foo
+			`,
+		},
+		entryPaths: []string{"/index.js"},
+		options: config.Options{
+			DualPackage:  true,
+			AbsOutputDir: "/out",
+		},
+		expectedCompileLog: `INFO: Suggested "exports" field for "package.json":
+NOTE: "exports": {
+  ".": {
+    "import": {
+      "types": "./out/index.d.mts",
+      "default": "./out/index.mjs"
+    },
+    "require": {
+      "types": "./out/index.d.cts",
+      "default": "./out/index.cjs"
+    }
+  }
+}
+`,
+	})
+}
+
+func TestSplittingSharedChunkDir(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/shared.js": `export let foo = 123`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:              config.ModeBundle,
+			CodeSplitting:     true,
+			OutputFormat:      config.FormatESModule,
+			AbsOutputDir:      "/out/app",
+			AbsSharedChunkDir: "/out/shared",
+		},
+	})
+}
+
+func TestSplittingCommonJS(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo, setFoo} from "./shared.js"
+				setFoo(1)
+				console.log(foo)
+				export let lazy = () => import("./lazy.js")
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				export default foo
+			`,
+			"/shared.js": `
+				export let foo = 123
+				export function setFoo(value) { foo = value }
+			`,
+			"/lazy.js": `export let bar = 234`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatCommonJS,
+			AbsOutputDir:  "/out",
+		},
+	})
+}
+
+func TestSplittingIIFE(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+				document.onclick = () => import("./lazy.js").then(ns => console.log(ns.bar))
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/shared.js": `export let foo = 123`,
+			"/lazy.js":   `export let bar = 234`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatIIFE,
+			Platform:      config.PlatformBrowser,
+			AbsOutputDir:  "/out",
+		},
+	})
+}
+
+func TestSplittingIIFEMinify(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/b.js": `
+				import {foo} from "./shared.js"
+				console.log(foo)
+			`,
+			"/shared.js": `export let foo = 123`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:             config.ModeBundle,
+			CodeSplitting:    true,
+			OutputFormat:     config.FormatIIFE,
+			Platform:         config.PlatformBrowser,
+			RemoveWhitespace: true,
+			AbsOutputDir:     "/out",
+		},
+	})
+}
+
+func TestSplittingChunkMinSize(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/b.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/c.js": `
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/ab.js":  `export let ab = 1`,
+			"/abc.js": `export let abc = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ChunkMinSize:  1000,
+		},
+	})
+}
+
+func TestSplittingChunkMinSizeSideEffects(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/b.js": `
+				import {ab} from "./ab.js"
+				import {abc} from "./abc.js"
+				console.log(ab, abc)
+			`,
+			"/c.js": `
+				import {abc} from "./abc.js"
+				console.log(abc)
+			`,
+			"/ab.js":  `export let ab = 1; console.log('side effect')`,
+			"/abc.js": `export let abc = 2`,
+		},
+		entryPaths: []string{"/a.js", "/b.js", "/c.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ChunkMinSize:  1000,
+		},
+	})
+}
+
+func TestSplittingChunkMaxSize(t *testing.T) {
+	splitting_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/a.js": `
+				import {one, two, three} from "./shared.js"
+				console.log(one, two, three)
+			`,
+			"/b.js": `
+				import {one, two, three} from "./shared.js"
+				console.log(one, two, three)
+			`,
+			"/shared.js": `
+				export {one} from "./one.js"
+				export {two} from "./two.js"
+				export {three} from "./three.js"
+			`,
+			"/one.js":   `export let one = "this is the first shared file"`,
+			"/two.js":   `export let two = "this is the second shared file"`,
+			"/three.js": `export let three = "this is the third shared file"`,
+		},
+		entryPaths: []string{"/a.js", "/b.js"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			CodeSplitting: true,
+			OutputFormat:  config.FormatESModule,
+			AbsOutputDir:  "/out",
+			ChunkMaxSize:  100,
+		},
+	})
+}
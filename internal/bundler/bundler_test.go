@@ -7,6 +7,7 @@ package bundler
 // inspect the diff to ensure the expected values are valid.
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -101,7 +102,7 @@ func (s *suite) expectBundled(t *testing.T, args bundled) {
 
 		log = logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
 		args.options.OmitRuntimeForTests = true
-		results, _ := bundle.Compile(log, args.options, nil)
+		results, _, generatedCaches := bundle.Compile(log, args.options, nil, nil)
 		msgs = log.Done()
 		assertLog(t, msgs, args.expectedCompileLog)
 
@@ -119,7 +120,22 @@ func (s *suite) expectBundled(t *testing.T, args bundled) {
 				if generated != "" {
 					generated += "\n"
 				}
-				generated += fmt.Sprintf("---------- %s ----------\n%s", result.AbsPath, string(result.Contents))
+				contents := bytes.Buffer{}
+				writeOutputFileContents(fs, &result, &contents)
+				generated += fmt.Sprintf("---------- %s ----------\n%s", result.AbsPath, contents.String())
+			}
+		}
+
+		// The name and mangle caches aren't output files, but they're part of the snapshot
+		for _, cache := range []struct{ absPath, json string }{
+			{args.options.AbsNameCacheFile, generatedCaches.NameCacheJSON},
+			{args.options.AbsMangleCacheFile, generatedCaches.MangleCacheJSON},
+		} {
+			if cache.json != "" {
+				if generated != "" {
+					generated += "\n"
+				}
+				generated += fmt.Sprintf("---------- %s ----------\n%s", cache.absPath, cache.json)
 			}
 		}
 		s.compareSnapshot(t, testName, generated)
@@ -1571,3 +1571,28 @@ func TestTSEnumDefine(t *testing.T) {
 		},
 	})
 }
+
+func TestTSEnumsFrozenAndInline(t *testing.T) {
+	ts_suite.expectBundled(t, bundled{
+		files: map[string]string{
+			"/entry.ts": `
+				import { Shared } from './shared'
+				enum Inlined { A = 1, B = 'b' }
+				enum Reversed { A, B }
+				console.log(Inlined.A, Inlined.B, Reversed[0], Shared.X)
+			`,
+			"/shared.ts": `
+				export enum Shared { X = 'x', Y = 'y' }
+			`,
+		},
+		entryPaths: []string{"/entry.ts"},
+		options: config.Options{
+			Mode:          config.ModeBundle,
+			AbsOutputFile: "/out.js",
+			TS:            config.TSOptions{Enums: config.TSEnumsInline},
+		},
+		expectedCompileLog: `INFO: TypeScript enums: 2 frozen, 1 inlined, 0 classic
+NOTE: These enums are about 95 bytes smaller when minified than if they were all classic enums.
+`,
+	})
+}
@@ -0,0 +1,245 @@
+package bundler
+
+// Automatic code splitting creates one chunk for each combination of entry
+// points that share code, which can result in many tiny chunks. The chunk size
+// limits change which chunk each file goes in before any code is generated:
+//
+//   - Shared chunks that are smaller than the minimum size are merged into
+//     another shared chunk. The merged chunk is imported by every entry point
+//     that needs either chunk, so some entry points load code that they don't
+//     need. A chunk is only merged if that code has no side effects, since it
+//     would otherwise run for entry points that never imported it. The target
+//     is the shared chunk that adds the least unneeded code.
+//
+//   - Chunks that are larger than the maximum size are split into several
+//     chunks, each of which is imported by the same entry points. Files are
+//     kept in order with their dependencies first, and the files that the
+//     rest of the chunk depends on are moved out first. Entry point files
+//     always stay in their own chunk.
+//
+// Sizes are estimated using the size of the input files since the output
+// hasn't been generated yet. Files that already belong to a named chunk (from
+// a splitting preset, an isolated chunk, or a manual chunk) are left alone.
+// The merged and split chunks reuse the named chunk mechanism with names such
+// as "chunk#00000001", where everything after the "#" is left out of the path.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/js_ast"
+)
+
+type sizeLimitGroup struct {
+	key         string
+	entryBits   helpers.BitSet
+	files       []uint32
+	size        int
+	hasEffects  bool
+	isShared    bool
+	isMerged    bool
+	chunkName   string
+	wasConsumed bool
+}
+
+func (c *linkerContext) computeSizeLimitChunkNames() map[uint32]string {
+	entryPointCount := uint(len(c.graph.EntryPoints()))
+	groups := make(map[string]*sizeLimitGroup)
+	var sortedKeys []string
+
+	// Group the files the same way automatic chunks are formed. The reachable
+	// files are in order with dependencies first, so the files in each group are
+	// too.
+	for _, sourceIndex := range c.graph.ReachableFiles {
+		file := &c.graph.Files[sourceIndex]
+		if !file.IsLive || c.presetChunkNameForFile(sourceIndex) != "" {
+			continue
+		}
+		if _, ok := file.InputFile.Repr.(*graph.JSRepr); !ok {
+			continue
+		}
+		key := file.EntryBits.String()
+		group, ok := groups[key]
+		if !ok {
+			// Only chunks for more than one entry point are shared chunks
+			group = &sizeLimitGroup{
+				key:       key,
+				entryBits: file.EntryBits,
+				isShared:  countBits(file.EntryBits, entryPointCount) > 1,
+			}
+			groups[key] = group
+			sortedKeys = append(sortedKeys, key)
+		}
+		group.files = append(group.files, sourceIndex)
+		group.size += len(file.InputFile.Source.Contents)
+		group.hasEffects = group.hasEffects || c.fileHasSideEffectsWhenLoaded(sourceIndex)
+	}
+	sort.Strings(sortedKeys)
+
+	nextName := 0
+	newName := func() string {
+		nextName++
+		return fmt.Sprintf("chunk#%08d", nextName)
+	}
+
+	// Merge small shared chunks, starting with the smallest one
+	if minSize := c.options.ChunkMinSize; minSize > 0 {
+		for {
+			var smallest *sizeLimitGroup
+			var target *sizeLimitGroup
+			for _, key := range sortedKeys {
+				group := groups[key]
+				if group.wasConsumed || !group.isShared || group.size >= minSize || (smallest != nil && group.size >= smallest.size) {
+					continue
+				}
+				if candidate := c.findMergeTarget(group, groups, sortedKeys, entryPointCount); candidate != nil {
+					smallest, target = group, candidate
+				}
+			}
+			if smallest == nil {
+				break
+			}
+
+			// Merge the smaller group into the target group
+			merged := &sizeLimitGroup{
+				key:        target.key,
+				entryBits:  helpers.NewBitSet(entryPointCount),
+				size:       target.size + smallest.size,
+				hasEffects: target.hasEffects || smallest.hasEffects,
+				isShared:   true,
+				isMerged:   true,
+				chunkName:  target.chunkName,
+			}
+			if merged.chunkName == "" {
+				merged.chunkName = newName()
+			}
+			for i := uint(0); i < entryPointCount; i++ {
+				if target.entryBits.HasBit(i) || smallest.entryBits.HasBit(i) {
+					merged.entryBits.SetBit(i)
+				}
+			}
+			merged.files = mergeFilesInOrder(c.graph.StableSourceIndices, target.files, smallest.files)
+			smallest.wasConsumed = true
+			groups[target.key] = merged
+		}
+	}
+
+	names := make(map[uint32]string)
+	for _, key := range sortedKeys {
+		group := groups[key]
+		if group.wasConsumed {
+			continue
+		}
+		if group.isMerged {
+			for _, sourceIndex := range group.files {
+				names[sourceIndex] = group.chunkName
+			}
+		}
+
+		// Split large chunks into pieces. Files are moved out starting with the
+		// first one, and the last piece stays in the original chunk.
+		if maxSize := c.options.ChunkMaxSize; maxSize > 0 && group.size > maxSize {
+			remaining := group.size
+			pieceName := ""
+			pieceSize := 0
+			for _, sourceIndex := range group.files {
+				if remaining <= maxSize || c.graph.Files[sourceIndex].IsEntryPoint() {
+					break
+				}
+				size := len(c.graph.Files[sourceIndex].InputFile.Source.Contents)
+				if pieceName == "" || pieceSize+size > maxSize {
+					pieceName = newName()
+					pieceSize = 0
+				}
+				names[sourceIndex] = pieceName
+				pieceSize += size
+				remaining -= size
+			}
+		}
+	}
+	return names
+}
+
+// Code from this chunk will be loaded by entry points that don't need it if
+// it's merged into another chunk. Returns the chunk to merge into that loads
+// the fewest bytes of unneeded code, or nil if there isn't one that's safe.
+func (c *linkerContext) findMergeTarget(group *sizeLimitGroup, groups map[string]*sizeLimitGroup, sortedKeys []string, entryPointCount uint) *sizeLimitGroup {
+	var best *sizeLimitGroup
+	bestCost := 0
+	for _, key := range sortedKeys {
+		other := groups[key]
+		if other == group || other.wasConsumed || !other.isShared {
+			continue
+		}
+		if maxSize := c.options.ChunkMaxSize; maxSize > 0 && group.size+other.size > maxSize {
+			continue
+		}
+
+		// Count the entry points that only need one of the two chunks
+		onlyGroup, onlyOther := 0, 0
+		for i := uint(0); i < entryPointCount; i++ {
+			if group.entryBits.HasBit(i) && !other.entryBits.HasBit(i) {
+				onlyGroup++
+			} else if other.entryBits.HasBit(i) && !group.entryBits.HasBit(i) {
+				onlyOther++
+			}
+		}
+		if (onlyOther > 0 && group.hasEffects) || (onlyGroup > 0 && other.hasEffects) {
+			continue
+		}
+		cost := onlyOther*group.size + onlyGroup*other.size
+		if best == nil || cost < bestCost {
+			best, bestCost = other, cost
+		}
+	}
+	return best
+}
+
+// Returns true if loading this file runs code that has side effects. Code in
+// wrapped files only runs when the wrapper is called, so it doesn't count.
+func (c *linkerContext) fileHasSideEffectsWhenLoaded(sourceIndex uint32) bool {
+	repr, ok := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr)
+	if !ok || repr.Meta.Wrap != graph.WrapNone {
+		return false
+	}
+	for partIndex, part := range repr.AST.Parts {
+		if part.IsLive && uint32(partIndex) != js_ast.NSExportPartIndex && !part.CanBeRemovedIfUnused {
+			return true
+		}
+	}
+	return false
+}
+
+func countBits(bits helpers.BitSet, count uint) int {
+	n := 0
+	for i := uint(0); i < count; i++ {
+		if bits.HasBit(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// Both lists are in the order of the reachable files, which has dependencies
+// first. The merged list keeps that order.
+func mergeFilesInOrder(stableSourceIndices []uint32, a []uint32, b []uint32) []uint32 {
+	files := make([]uint32, 0, len(a)+len(b))
+	files = append(files, a...)
+	files = append(files, b...)
+	sort.SliceStable(files, func(i int, j int) bool {
+		return stableSourceIndices[files[i]] < stableSourceIndices[files[j]]
+	})
+	return files
+}
+
+// The part of a size limit chunk name after the "#" keeps the chunks apart
+// but isn't used in the output path
+func sizeLimitChunkBaseName(name string) string {
+	if hash := strings.IndexByte(name, '#'); hash != -1 {
+		return name[:hash]
+	}
+	return name
+}
@@ -0,0 +1,102 @@
+package bundler
+
+// A CommonJS wrapper is a small ".cjs" file next to an ESM entry point that
+// lets "require()" load the entry point. It relies on node being able to
+// "require()" ES modules, which returns the module namespace object. The
+// wrapper also annotates the export names so that code in node that imports
+// the wrapper with an "import" statement can use named imports:
+//
+//   // dist/index.cjs
+//   module.exports = require("./index.mjs");
+//
+//   // Annotate the CommonJS export names for ESM import in node:
+//   0 && (module.exports = {
+//     a,
+//     b
+//   });
+//
+// This is much smaller than a CommonJS copy of the whole package, but unlike
+// a dual package, both "require()" and "import" get the same module instance.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+func (c *linkerContext) generateCJSWrapper(chunk *chunkInfo, inputPath string) (graph.OutputFile, bool) {
+	if _, ok := chunk.chunkRepr.(*chunkReprJS); !ok {
+		return graph.OutputFile{}, false
+	}
+	file := &c.graph.Files[chunk.sourceIndex]
+	repr, ok := file.InputFile.Repr.(*graph.JSRepr)
+	if !ok || !file.IsUserSpecifiedEntryPoint() {
+		return graph.OutputFile{}, false
+	}
+
+	// ES modules that use top-level await can't be loaded with "require()"
+	if repr.Meta.IsAsyncOrHasAsyncDependency || repr.AST.TopLevelAwaitKeyword.Len > 0 {
+		var tracker *logger.LineColumnTracker
+		var r logger.Range
+		if repr.AST.TopLevelAwaitKeyword.Len > 0 {
+			tracker = file.LineColumnTracker()
+			r = repr.AST.TopLevelAwaitKeyword
+		}
+		c.log.AddWithNotes(logger.Warning, tracker, r, fmt.Sprintf(
+			"Cannot generate a CommonJS wrapper for entry point %q because it uses top-level await", inputPath),
+			[]logger.MsgData{{Text: "Node can't use \"require()\" to load ES modules that use top-level await, " +
+				"either directly or in one of the modules that they import."}})
+		return graph.OutputFile{}, false
+	}
+
+	ext := c.fs.Ext(chunk.finalRelPath)
+	finalRelPathForWrapper := strings.TrimSuffix(chunk.finalRelPath, ext) + ".cjs"
+	importPath := c.pathBetweenChunks(c.fs.Dir(finalRelPathForWrapper), chunk.finalRelPath)
+
+	space := " "
+	if c.options.RemoveWhitespace {
+		space = ""
+	}
+	sb := strings.Builder{}
+	sb.WriteString("module.exports" + space + "=" + space + "require(")
+	sb.Write(js_printer.QuoteForJSON(importPath, c.options.ASCIIOnly))
+	sb.WriteString(");\n")
+
+	// "0 && (module.exports = {a, b, if: null});"
+	var names []string
+	for _, export := range repr.Meta.SortedAndFilteredExportAliases {
+		// In node the default export is always "module.exports" regardless of
+		// what the annotation says. So don't bother generating "default".
+		if export == "default" || !js_lexer.IsIdentifier(export) {
+			continue
+		}
+		if _, ok := js_lexer.Keywords[export]; ok {
+			names = append(names, export+": null")
+		} else {
+			names = append(names, export)
+		}
+	}
+	if len(names) > 0 {
+		if !c.options.RemoveWhitespace {
+			sb.WriteString("\n// Annotate the CommonJS export names for ESM import in node:\n")
+			sb.WriteString("0 && (module.exports = {\n")
+			sb.WriteString("  " + strings.Join(names, ",\n  ") + "\n")
+			sb.WriteString("});\n")
+		} else {
+			sb.WriteString("0&&(module.exports={" + strings.Join(names, ",") + "});\n")
+		}
+	}
+
+	contents := c.convertLineEndings([]byte(sb.String()))
+	return graph.OutputFile{
+		AbsPath:           c.fs.Join(c.options.AbsOutputDir, finalRelPathForWrapper),
+		Contents:          contents,
+		Kind:              graph.OutputCJSWrapper,
+		InputPath:         inputPath,
+		JSONMetadataChunk: metafileEntryForGeneratedFile(len(contents)),
+	}, true
+}
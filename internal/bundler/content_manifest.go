@@ -0,0 +1,91 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_printer"
+)
+
+// When a content manifest is requested, every output file (including entry
+// points) is named by nothing but its content hash. That makes every output
+// file immutable, so it can be deployed to a CDN with a long cache lifetime
+// and shared between builds that happen to generate the same file. The names
+// that the output files would have had otherwise are written to the manifest
+// along with the hashed names so that the server can find the entry points.
+//
+// Both the keys and the values in the manifest are relative to the output
+// directory and always use "/" as the path separator:
+//
+//   {
+//     "app.js": "SXFOZ7KZ.js",
+//     "app.css": "2DYTPCGP.css",
+//     "logo.png": "JBGKLJ2T.png"
+//   }
+//
+
+func contentAddressedTemplate(ext string) []config.PathTemplate {
+	return []config.PathTemplate{
+		{Data: "./", Placeholder: config.HashPlaceholder},
+		{Data: ext},
+	}
+}
+
+func generateContentManifest(options *config.Options, fs fs.FS, outputFiles []graph.OutputFile) graph.OutputFile {
+	type manifestEntry struct {
+		logicalPath string
+		hashedPath  string
+	}
+	var entries []manifestEntry
+
+	relPathInOutputDir := func(absPath string) (string, bool) {
+		relPath, ok := fs.Rel(options.AbsOutputDir, absPath)
+		return strings.ReplaceAll(relPath, "\\", "/"), ok
+	}
+
+	for _, outputFile := range outputFiles {
+		if outputFile.LogicalAbsPath == "" {
+			continue
+		}
+		logicalPath, ok := relPathInOutputDir(outputFile.LogicalAbsPath)
+		if !ok {
+			continue
+		}
+		hashedPath, ok := relPathInOutputDir(outputFile.AbsPath)
+		if !ok {
+			continue
+		}
+		entries = append(entries, manifestEntry{logicalPath: logicalPath, hashedPath: hashedPath})
+	}
+
+	// Sort the entries so the manifest is deterministic
+	sort.Slice(entries, func(i int, j int) bool {
+		return entries[i].logicalPath < entries[j].logicalPath
+	})
+
+	sb := strings.Builder{}
+	sb.WriteString("{")
+	for i, entry := range entries {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n  %s: %s",
+			js_printer.QuoteForJSON(entry.logicalPath, options.ASCIIOnly),
+			js_printer.QuoteForJSON(entry.hashedPath, options.ASCIIOnly)))
+	}
+	if len(entries) > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("}\n")
+	manifest := sb.String()
+
+	return graph.OutputFile{
+		AbsPath:           options.AbsContentManifestFile,
+		Contents:          []byte(manifest),
+		JSONMetadataChunk: metafileEntryForGeneratedFile(len(manifest)),
+	}
+}
@@ -0,0 +1,75 @@
+package bundler
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+)
+
+// Files that use the "file" loader are copied to the output directory without
+// esbuild ever looking at their contents. These files can be very large (e.g.
+// videos or machine learning models) so they are never loaded into memory all
+// at once. Instead they are streamed in fixed-size pieces whenever something
+// needs their contents, such as computing a content hash for the file name.
+
+const copiedFileChunkSize = 64 * 1024
+
+func fileLen(fs fs.FS, absPath string) (int, error, error) {
+	file, err, originalError := fs.OpenFile(absPath)
+	if err != nil {
+		return 0, err, originalError
+	}
+	defer file.Close()
+	return file.Len(), nil, nil
+}
+
+func streamFileContents(fs fs.FS, absPath string, w io.Writer) error {
+	file, err, _ := fs.OpenFile(absPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	n := file.Len()
+	for start := 0; start < n; start += copiedFileChunkSize {
+		end := start + copiedFileChunkSize
+		if end > n {
+			end = n
+		}
+		chunk, err := file.Read(start, end)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeOutputFileContents(fs fs.FS, outputFile *graph.OutputFile, w io.Writer) error {
+	if outputFile.CopyFromAbsPath != "" {
+		return streamFileContents(fs, outputFile.CopyFromAbsPath, w)
+	}
+	_, err := w.Write(outputFile.Contents)
+	return err
+}
+
+func outputFileContentsEqual(fs fs.FS, a *graph.OutputFile, b *graph.OutputFile) bool {
+	if a.CopyFromAbsPath == "" && b.CopyFromAbsPath == "" {
+		return bytes.Equal(a.Contents, b.Contents)
+	}
+	if a.CopyFromAbsPath != "" && a.CopyFromAbsPath == b.CopyFromAbsPath {
+		return true
+	}
+
+	// This is rare (it only happens when the asset path template has no hash),
+	// so just compare the contents in memory instead of streaming both of them
+	var bufA, bufB bytes.Buffer
+	if writeOutputFileContents(fs, a, &bufA) != nil || writeOutputFileContents(fs, b, &bufB) != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
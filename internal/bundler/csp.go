@@ -0,0 +1,104 @@
+package bundler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_printer"
+)
+
+// This token can be used in banners and footers (or anywhere else in the code)
+// as a placeholder for a Content Security Policy nonce. The server is expected
+// to replace it with a fresh nonce for every response. Files that contain this
+// token don't have a fixed hash, so they are listed separately in the report.
+const cspNoncePlaceholder = "__ESBUILD_CSP_NONCE__"
+
+// This generates a report that can be used to automate a strict Content
+// Security Policy. It contains the "sha256" source expressions for all
+// generated JavaScript files (including the service worker, if any) along
+// with a "script-src" value that allows all of them. Hashes can be used with
+// inline scripts or with external scripts that have an "integrity" attribute.
+func generateCSPReport(options *config.Options, fs fs.FS, outputFiles []graph.OutputFile) graph.OutputFile {
+	absBaseDir := fs.Dir(options.AbsCSPReportFile)
+	var hashes []string
+	var noncePaths []string
+
+	sb := strings.Builder{}
+	sb.WriteString("{\n  \"hashes\": {")
+	isFirst := true
+
+	for _, outputFile := range outputFiles {
+		if !strings.HasSuffix(outputFile.AbsPath, ".js") && !strings.HasSuffix(outputFile.AbsPath, ".mjs") &&
+			!strings.HasSuffix(outputFile.AbsPath, ".cjs") {
+			continue
+		}
+		relPath, ok := fs.Rel(absBaseDir, outputFile.AbsPath)
+		if !ok {
+			relPath = outputFile.AbsPath
+		}
+		relPath = strings.ReplaceAll(relPath, "\\", "/")
+
+		// Files with a nonce placeholder will have different contents every time
+		// they are served, so they can't be allowed using a hash
+		if strings.Contains(string(outputFile.Contents), cspNoncePlaceholder) {
+			noncePaths = append(noncePaths, relPath)
+			continue
+		}
+
+		sum := sha256.Sum256(outputFile.Contents)
+		hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+		hashes = append(hashes, hash)
+
+		if !isFirst {
+			sb.WriteString(",")
+		}
+		isFirst = false
+		sb.WriteString(fmt.Sprintf("\n    %s: %s",
+			js_printer.QuoteForJSON(relPath, options.ASCIIOnly),
+			js_printer.QuoteForJSON(hash, options.ASCIIOnly)))
+	}
+
+	if !isFirst {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("},\n")
+
+	// Sort and deduplicate the hashes so the policy doesn't depend on file names
+	sort.Strings(hashes)
+	scriptSrc := "'self'"
+	for i, hash := range hashes {
+		if i == 0 || hash != hashes[i-1] {
+			scriptSrc += " " + hash
+		}
+	}
+	if len(noncePaths) > 0 {
+		scriptSrc += " 'nonce-" + cspNoncePlaceholder + "'"
+	}
+	sb.WriteString(fmt.Sprintf("  \"scriptSrc\": %s,\n", js_printer.QuoteForJSON(scriptSrc, options.ASCIIOnly)))
+
+	sb.WriteString(fmt.Sprintf("  \"noncePlaceholder\": %s,\n", js_printer.QuoteForJSON(cspNoncePlaceholder, false)))
+	sb.WriteString("  \"filesWithNoncePlaceholder\": [")
+	for i, path := range noncePaths {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n    %s", js_printer.QuoteForJSON(path, options.ASCIIOnly)))
+	}
+	if len(noncePaths) > 0 {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("]\n}\n")
+
+	contents := sb.String()
+	return graph.OutputFile{
+		AbsPath:           options.AbsCSPReportFile,
+		Contents:          []byte(contents),
+		JSONMetadataChunk: metafileEntryForGeneratedFile(len(contents)),
+	}
+}
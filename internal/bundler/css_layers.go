@@ -0,0 +1,530 @@
+package bundler
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// CSS cascade layers ("@layer") are handled in the linker because the layer
+// that a file ends up in depends on how that file was imported. A file can be
+// assigned to a layer in three ways, in order of precedence:
+//
+//   @import "foo.css" layer(name);
+//   @import "foo.css?layer=name";
+//   the "CSSLayers" option, which maps package names to layer names
+//
+// Layers are represented as a list of layer names from the outermost layer to
+// the innermost layer. Each layer name may contain dots (e.g. "a.b"). An empty
+// layer name is an anonymous layer.
+//
+// The order of layers is determined by the first time each layer is mentioned
+// when the browser evaluates the CSS. Bundling only keeps the last evaluation
+// of each file, so bundling can change which mention comes first. To fix this,
+// the bundled output starts with a "@layer" statement that lists all named
+// layers in their original order.
+
+func appendCSSLayer(layer []string, name string) []string {
+	result := make([]string, 0, len(layer)+1)
+	result = append(result, layer...)
+	return append(result, name)
+}
+
+// This returns the layer as a single dot-separated name, or false if it can't
+// be represented as a name because some part of it is an anonymous layer
+func cssLayerName(layer []string) (string, bool) {
+	for _, name := range layer {
+		if name == "" {
+			return "", false
+		}
+	}
+	return strings.Join(layer, "."), true
+}
+
+// This is used to deduplicate files that are imported into the same layer
+func cssLayerKey(layer []string) string {
+	return fmt.Sprintf("%d:%s", len(layer), strings.Join(layer, "\x00"))
+}
+
+// This returns the innermost package containing the path if there is a layer
+// configured for that package
+func (c *linkerContext) cssPackageLayer(path logger.Path) (pkg string, layer string, ok bool) {
+	if len(c.options.CSSLayers) == 0 || path.Namespace != "file" {
+		return
+	}
+	text := strings.ReplaceAll(path.Text, "\\", "/")
+	index := strings.LastIndex(text, "/node_modules/")
+	if index == -1 {
+		return
+	}
+	parts := strings.SplitN(text[index+len("/node_modules/"):], "/", 3)
+	if strings.HasPrefix(parts[0], "@") && len(parts) > 2 {
+		pkg = parts[0] + "/" + parts[1]
+	} else if len(parts) > 1 {
+		pkg = parts[0]
+	}
+	layer, ok = c.options.CSSLayers[pkg]
+	return
+}
+
+// This handles paths such as "foo.css?layer=name". An empty name is an
+// anonymous layer.
+func cssQueryLayer(path logger.Path) (string, bool) {
+	query := path.IgnoredSuffix
+	if !strings.HasPrefix(query, "?") {
+		return "", false
+	}
+	if hash := strings.IndexByte(query, '#'); hash != -1 {
+		query = query[:hash]
+	}
+	values, err := url.ParseQuery(query[1:])
+	if err != nil {
+		return "", false
+	}
+	if names, ok := values["layer"]; ok && len(names) > 0 {
+		return names[0], true
+	}
+	return "", false
+}
+
+// This returns the layer of a CSS file imported from a file in "parentLayer".
+// Entry points are handled by passing an empty importer path.
+func (c *linkerContext) cssImportedLayer(
+	parentLayer []string, importerPath logger.Path, importConditions []css_ast.Token, importedPath logger.Path,
+) []string {
+	if name, _, ok := css_ast.ImportConditionsLayer(importConditions); ok {
+		return appendCSSLayer(parentLayer, name)
+	}
+	if name, ok := cssQueryLayer(importedPath); ok {
+		return appendCSSLayer(parentLayer, name)
+	}
+
+	// Files within a package import each other, so only add the layer when the
+	// import crosses into the package from outside of it
+	if pkg, name, ok := c.cssPackageLayer(importedPath); ok {
+		if importerPkg, _, ok := c.cssPackageLayer(importerPath); !ok || importerPkg != pkg {
+			return appendCSSLayer(parentLayer, name)
+		}
+	}
+	return parentLayer
+}
+
+// External "@import" rules are moved to the top of the bundle, so they must be
+// explicitly put in the layer of the file that contained them
+func (c *linkerContext) cssExternalImportConditions(
+	layer []string, conditions []css_ast.Token, importerSourceIndex uint32, r logger.Range, path string,
+) []css_ast.Token {
+	if len(layer) == 0 {
+		return conditions
+	}
+	fullLayer := layer
+	rest := conditions
+	if name, remaining, ok := css_ast.ImportConditionsLayer(conditions); ok {
+		fullLayer = appendCSSLayer(layer, name)
+		rest = remaining
+	}
+
+	name, ok := cssLayerName(fullLayer)
+	if !ok {
+		file := &c.graph.Files[importerSourceIndex]
+		c.log.Add(logger.Warning, file.LineColumnTracker(), r, fmt.Sprintf(
+			"The external import %q cannot be put in an anonymous cascade layer", path))
+		return conditions
+	}
+
+	token := css_ast.LayerToken(name)
+	if !c.options.RemoveWhitespace {
+		token.Whitespace = css_ast.WhitespaceBefore
+	}
+	result := append([]css_ast.Token{token}, rest...)
+	if len(rest) > 0 && !c.options.RemoveWhitespace {
+		result[1].Whitespace |= css_ast.WhitespaceBefore
+	}
+	return result
+}
+
+// This finds all named layers in the order that the browser would first
+// encounter them if the CSS files were not bundled. Import conditions other
+// than layers aren't supported when bundling, so every file is evaluated.
+func (c *linkerContext) findCSSLayerOrder(entryPoints []uint32) (order []string) {
+	type visitKey struct {
+		sourceIndex uint32
+		layer       string
+	}
+	visited := make(map[visitKey]bool)
+	seen := make(map[string]bool)
+
+	mention := func(layer []string) {
+		if len(layer) > 0 {
+			if name, ok := cssLayerName(layer); ok && !seen[name] {
+				seen[name] = true
+				order = append(order, name)
+			}
+		}
+	}
+
+	var visit func(sourceIndex uint32, layer []string)
+	var visitRules func(sourceIndex uint32, rules []css_ast.Rule, layer []string)
+
+	visitRules = func(sourceIndex uint32, rules []css_ast.Rule, layer []string) {
+		file := &c.graph.Files[sourceIndex]
+		repr := file.InputFile.Repr.(*graph.CSSRepr)
+		for _, rule := range rules {
+			switch r := rule.Data.(type) {
+			case *css_ast.RAtLayer:
+				if r.Rules == nil {
+					for _, name := range r.Names {
+						mention(appendCSSLayer(layer, name))
+					}
+				} else {
+					var name string
+					if len(r.Names) > 0 {
+						name = r.Names[0]
+					}
+					nested := appendCSSLayer(layer, name)
+					mention(nested)
+					visitRules(sourceIndex, r.Rules, nested)
+				}
+
+			case *css_ast.RAtImport:
+				record := &repr.AST.ImportRecords[r.ImportRecordIndex]
+				if record.SourceIndex.IsValid() {
+					otherIndex := record.SourceIndex.GetIndex()
+					otherLayer := c.cssImportedLayer(layer, file.InputFile.Source.KeyPath,
+						r.ImportConditions, c.graph.Files[otherIndex].InputFile.Source.KeyPath)
+					if len(otherLayer) > len(layer) {
+						mention(otherLayer)
+					}
+					visit(otherIndex, otherLayer)
+				} else if name, _, ok := css_ast.ImportConditionsLayer(r.ImportConditions); ok {
+					mention(appendCSSLayer(layer, name))
+				}
+			}
+		}
+	}
+
+	visit = func(sourceIndex uint32, layer []string) {
+		key := visitKey{sourceIndex: sourceIndex, layer: cssLayerKey(layer)}
+		if visited[key] {
+			return
+		}
+		visited[key] = true
+		repr := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.CSSRepr)
+		visitRules(sourceIndex, repr.AST.Rules, layer)
+	}
+
+	for _, sourceIndex := range entryPoints {
+		layer := c.cssImportedLayer(nil, logger.Path{}, nil, c.graph.Files[sourceIndex].InputFile.Source.KeyPath)
+		mention(layer)
+		visit(sourceIndex, layer)
+	}
+	return
+}
+
+// This wraps the rules of a file in the layer that the file was imported into.
+// Adjacent named layers are combined into a single dot-separated name.
+func wrapRulesInCSSLayer(rules []css_ast.Rule, layer []string) []css_ast.Rule {
+	if len(rules) == 0 {
+		return rules
+	}
+	for end := len(layer); end > 0; {
+		start := end - 1
+		if layer[start] != "" {
+			for start > 0 && layer[start-1] != "" {
+				start--
+			}
+		}
+		var names []string
+		if layer[start] != "" {
+			names = []string{strings.Join(layer[start:end], ".")}
+		}
+		rules = []css_ast.Rule{{Data: &css_ast.RAtLayer{Names: names, Rules: rules}}}
+		end = start
+	}
+	return rules
+}
+
+type cssLayerPiece struct {
+	sourceIndex uint32
+	layer       []string
+	rules       []css_ast.Rule
+}
+
+type cssLayeredRule struct {
+	sourceIndex uint32
+	layer       string
+	rule        css_ast.Rule
+}
+
+// Browsers that don't support cascade layers ignore "@layer" blocks entirely.
+// Layers can be removed by moving all rules into the order of their layers
+// instead, which is only equivalent if the specificity of the selectors
+// doesn't matter. That is the case when no selector in a layer has a higher
+// specificity than any selector in a later layer, and when there are no
+// "!important" declarations inside layers (which reverse the layer order).
+// The reason is returned if the layers can't be removed safely.
+func lowerCSSLayers(order []string, pieces []cssLayerPiece) (lowered []cssLayerPiece, reason string) {
+	var layeredRules []cssLayeredRule
+	var flatten func(sourceIndex uint32, rules []css_ast.Rule, layer string) string
+	flatten = func(sourceIndex uint32, rules []css_ast.Rule, layer string) string {
+		for _, rule := range rules {
+			if r, ok := rule.Data.(*css_ast.RAtLayer); ok {
+				if r.Rules == nil {
+					continue // The order of all layers is already known
+				}
+				if len(r.Names) == 0 {
+					return "it contains an anonymous layer"
+				}
+				nested := r.Names[0]
+				if layer != "" {
+					nested = layer + "." + nested
+				}
+				if reason := flatten(sourceIndex, r.Rules, nested); reason != "" {
+					return reason
+				}
+				continue
+			}
+			if reason := checkRuleForLoweringCSSLayers(rule, layer != ""); reason != "" {
+				return reason
+			}
+			layeredRules = append(layeredRules, cssLayeredRule{sourceIndex: sourceIndex, layer: layer, rule: rule})
+		}
+		return ""
+	}
+	for _, piece := range pieces {
+		layer, ok := cssLayerName(piece.layer)
+		if !ok {
+			return nil, "it contains an anonymous layer"
+		}
+		if reason := flatten(piece.sourceIndex, piece.rules, layer); reason != "" {
+			return nil, reason
+		}
+	}
+
+	// Layers have lower precedence than their parent layer, so assign ranks
+	// using a post-order traversal of the layer tree. Unlayered rules belong
+	// to the root and come last.
+	children := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, name := range order {
+		parts := strings.Split(name, ".")
+		for i := range parts {
+			prefix := strings.Join(parts[:i+1], ".")
+			if !seen[prefix] {
+				seen[prefix] = true
+				parent := strings.Join(parts[:i], ".")
+				children[parent] = append(children[parent], prefix)
+			}
+		}
+	}
+	ranks := make(map[string]int)
+	var assignRanks func(name string)
+	assignRanks = func(name string) {
+		for _, child := range children[name] {
+			assignRanks(child)
+		}
+		ranks[name] = len(ranks)
+	}
+	assignRanks("")
+
+	// Check specificity in order of precedence
+	type rankInfo struct {
+		min      cssSpecificity
+		max      cssSpecificity
+		hasRules bool
+	}
+	infos := make([]rankInfo, len(ranks))
+	for _, layered := range layeredRules {
+		rank, ok := ranks[layered.layer]
+		if !ok {
+			return nil, fmt.Sprintf("the order of layer %q is unknown", layered.layer)
+		}
+		info := &infos[rank]
+		var reason string
+		visitCSSSpecificities(layered.rule, func(spec cssSpecificity) {
+			if !info.hasRules || spec.isLessThan(info.min) {
+				info.min = spec
+			}
+			if !info.hasRules || info.max.isLessThan(spec) {
+				info.max = spec
+			}
+			info.hasRules = true
+		}, &reason)
+		if reason != "" {
+			return nil, reason
+		}
+	}
+	var maxSoFar cssSpecificity
+	for _, info := range infos {
+		if info.hasRules {
+			if info.min.isLessThan(maxSoFar) {
+				return nil, "the specificity of selectors in an earlier layer is higher than in a later layer"
+			}
+			if maxSoFar.isLessThan(info.max) {
+				maxSoFar = info.max
+			}
+		}
+	}
+
+	// Sort rules by layer and then group adjacent rules from the same file
+	sort.SliceStable(layeredRules, func(i int, j int) bool {
+		return ranks[layeredRules[i].layer] < ranks[layeredRules[j].layer]
+	})
+	for _, layered := range layeredRules {
+		if n := len(lowered); n > 0 && lowered[n-1].sourceIndex == layered.sourceIndex {
+			lowered[n-1].rules = append(lowered[n-1].rules, layered.rule)
+		} else {
+			lowered = append(lowered, cssLayerPiece{sourceIndex: layered.sourceIndex, rules: []css_ast.Rule{layered.rule}})
+		}
+	}
+	return lowered, ""
+}
+
+func checkRuleForLoweringCSSLayers(rule css_ast.Rule, isLayered bool) string {
+	switch r := rule.Data.(type) {
+	case *css_ast.RAtLayer:
+		return "it contains a layer that is nested inside another rule"
+
+	case *css_ast.RDeclaration:
+		if r.Important && isLayered {
+			return "it contains an \"!important\" declaration inside a layer"
+		}
+
+	case *css_ast.RSelector:
+		for _, child := range r.Rules {
+			if reason := checkRuleForLoweringCSSLayers(child, isLayered); reason != "" {
+				return reason
+			}
+		}
+
+	case *css_ast.RKnownAt:
+		for _, child := range r.Rules {
+			if reason := checkRuleForLoweringCSSLayers(child, isLayered); reason != "" {
+				return reason
+			}
+		}
+
+	case *css_ast.RQualified:
+		for _, child := range r.Rules {
+			if reason := checkRuleForLoweringCSSLayers(child, isLayered); reason != "" {
+				return reason
+			}
+		}
+	}
+	return ""
+}
+
+// This is the number of ID selectors, class-like selectors, and type-like
+// selectors: https://www.w3.org/TR/selectors-4/#specificity-rules
+type cssSpecificity [3]uint32
+
+func (a cssSpecificity) isLessThan(b cssSpecificity) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// These pseudo-elements can also be written with a single colon
+var legacyCSSPseudoElements = map[string]bool{
+	"after":        true,
+	"before":       true,
+	"first-letter": true,
+	"first-line":   true,
+}
+
+// The specificity of these pseudo-classes doesn't depend on their arguments
+var simpleCSSPseudoClassesWithArgs = map[string]bool{
+	"dir":              true,
+	"lang":             true,
+	"nth-child":        true,
+	"nth-last-child":   true,
+	"nth-last-of-type": true,
+	"nth-of-type":      true,
+}
+
+func visitCSSSpecificities(rule css_ast.Rule, visit func(cssSpecificity), reason *string) {
+	switch r := rule.Data.(type) {
+	case *css_ast.RSelector:
+		for _, complex := range r.Selectors {
+			spec, ok := complexSelectorSpecificity(complex)
+			if !ok {
+				*reason = "the specificity of a selector could not be determined"
+				return
+			}
+			visit(spec)
+		}
+		for _, child := range r.Rules {
+			if _, ok := child.Data.(*css_ast.RDeclaration); !ok {
+				*reason = "it contains nested style rules"
+				return
+			}
+		}
+
+	case *css_ast.RQualified:
+		*reason = "the specificity of a selector could not be determined"
+
+	case *css_ast.RUnknownAt:
+		if r.Block != nil {
+			*reason = fmt.Sprintf("it contains an unknown \"@%s\" rule", r.AtToken)
+		}
+
+	case *css_ast.RKnownAt:
+		for _, child := range r.Rules {
+			visitCSSSpecificities(child, visit, reason)
+			if *reason != "" {
+				return
+			}
+		}
+	}
+}
+
+func complexSelectorSpecificity(complex css_ast.ComplexSelector) (spec cssSpecificity, ok bool) {
+	for _, compound := range complex.Selectors {
+		if compound.HasNestPrefix {
+			return
+		}
+		if t := compound.TypeSelector; t != nil && t.Name.Kind != css_lexer.TDelimAsterisk {
+			spec[2]++
+		}
+		for _, ss := range compound.SubclassSelectors {
+			switch s := ss.(type) {
+			case *css_ast.SSHash:
+				spec[0]++
+
+			case *css_ast.SSClass, *css_ast.SSAttribute:
+				spec[1]++
+
+			case *css_ast.SSPseudoClass:
+				if s.IsElement || legacyCSSPseudoElements[s.Name] {
+					// Arguments to pseudo-elements such as "::slotted()" add specificity
+					if len(s.Args) > 0 {
+						return
+					}
+					spec[2]++
+				} else {
+					// Arguments to pseudo-classes such as ":is()" add specificity
+					if len(s.Args) > 0 && !simpleCSSPseudoClassesWithArgs[s.Name] {
+						return
+					}
+					for _, arg := range s.Args {
+						if arg.Kind == css_lexer.TIdent && arg.Text == "of" {
+							return
+						}
+					}
+					spec[1]++
+				}
+			}
+		}
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,264 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/sourcemap"
+)
+
+// The CSS order report explains where each input file ended up in each CSS
+// output file, which makes it possible to debug specificity regressions
+// without bisecting the imports:
+//
+//   {
+//     "outputs": {
+//       "out/a.css": {
+//         "entryPoint": "src/a.css",
+//         "files": [
+//           { "path": "src/shared.css", "importedBy": "src/b.css", "overriddenImportsFrom": ["src/a.css"] },
+//           { "path": "src/b.css", "importedBy": "src/a.css" },
+//           { "path": "src/a.css" }
+//         ],
+//         "ranges": [
+//           { "path": "src/shared.css", "startLine": 2, "startColumn": 1, "endLine": 5, "endColumn": 1 }
+//         ]
+//       }
+//     },
+//     "duplicates": [
+//       { "path": "src/shared.css", "outputs": ["out/a.css", "out/b.css"] }
+//     ],
+//     "orderConflicts": [
+//       { "first": "src/x.css", "second": "src/y.css", "outputs": ["out/a.css"], "reversedIn": ["out/b.css"] }
+//     ]
+//   }
+//
+// CSS "@import" rules are evaluated every time, so only the last import of a
+// file decides where it goes. The "importedBy" file is the one with that last
+// import and "overriddenImportsFrom" lists the files whose earlier imports
+// were dropped. A file imported into more than one cascade layer appears
+// once for each layer.
+//
+// CSS isn't split into shared chunks, so a file imported by several entry
+// points is duplicated into each of their output files. The order conflicts
+// are pairs of those files that are evaluated in a different order in
+// different output files. These pairs couldn't be moved into a shared file
+// without changing the cascade for some entry point.
+
+func (c *linkerContext) cssOrderForChunk(
+	chunkRepr *chunkReprCSS,
+	ranges []moduleMapRange,
+	shifts []sourcemap.SourceMapShift,
+) *graph.CSSOrder {
+	prettyPath := func(sourceIndex uint32) string {
+		return c.graph.Files[sourceIndex].InputFile.Source.PrettyPath
+	}
+	order := &graph.CSSOrder{}
+
+	for i, sourceIndex := range chunkRepr.filesInChunkInOrder {
+		file := graph.CSSOrderFile{Path: prettyPath(sourceIndex)}
+		if chunkRepr.layersInChunkInOrder != nil {
+			file.Layer = chunkRepr.layersInChunkInOrder[i]
+		}
+		if chunkRepr.orderReasons != nil {
+			reason := chunkRepr.orderReasons[i]
+			if reason.importer.IsValid() {
+				file.ImportedBy = prettyPath(reason.importer.GetIndex())
+			}
+			for _, importer := range reason.overriddenImporters {
+				file.OverriddenImportsFrom = append(file.OverriddenImportsFrom, prettyPath(importer))
+			}
+		}
+		order.Files = append(order.Files, file)
+	}
+
+	for _, r := range ranges {
+		start := shiftLineColumnOffset(shifts, r.start)
+		end := shiftLineColumnOffset(shifts, r.end)
+		order.Ranges = append(order.Ranges, graph.CSSOrderRange{
+			Path:        prettyPath(r.sourceIndex),
+			StartLine:   start.Lines + 1,
+			StartColumn: start.Columns + 1,
+			EndLine:     end.Lines + 1,
+			EndColumn:   end.Columns + 1,
+		})
+	}
+
+	return order
+}
+
+func generateCSSOrderReport(options *config.Options, fs fs.FS, outputFiles []graph.OutputFile) graph.OutputFile {
+	absBaseDir := fs.Dir(options.AbsCSSOrderReportFile)
+	quote := func(text string) []byte {
+		return js_printer.QuoteForJSON(text, options.ASCIIOnly)
+	}
+	quoteList := func(list []string) string {
+		quoted := make([]string, len(list))
+		for i, text := range list {
+			quoted[i] = string(quote(text))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	// Each output file may show up more than once when building several formats
+	type outputOrder struct {
+		relPath string
+		order   *graph.CSSOrder
+	}
+	var outputs []outputOrder
+	seen := make(map[string]bool)
+
+	sb := strings.Builder{}
+	sb.WriteString("{\n  \"outputs\": {")
+
+	for _, outputFile := range outputFiles {
+		if outputFile.CSSOrder == nil || seen[outputFile.AbsPath] {
+			continue
+		}
+		seen[outputFile.AbsPath] = true
+		relPath, ok := fs.Rel(absBaseDir, outputFile.AbsPath)
+		if !ok {
+			relPath = outputFile.AbsPath
+		}
+		relPath = strings.ReplaceAll(relPath, "\\", "/")
+		order := outputFile.CSSOrder
+		outputs = append(outputs, outputOrder{relPath: relPath, order: order})
+
+		if len(outputs) > 1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n    %s: {\n      \"entryPoint\": %s,\n      \"files\": [", quote(relPath), quote(outputFile.InputPath)))
+		for i, file := range order.Files {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(fmt.Sprintf("\n        { \"path\": %s", quote(file.Path)))
+			if len(file.Layer) > 0 {
+				sb.WriteString(fmt.Sprintf(", \"layer\": %s", quote(strings.Join(file.Layer, "."))))
+			}
+			if file.ImportedBy != "" {
+				sb.WriteString(fmt.Sprintf(", \"importedBy\": %s", quote(file.ImportedBy)))
+			}
+			if len(file.OverriddenImportsFrom) > 0 {
+				sb.WriteString(fmt.Sprintf(", \"overriddenImportsFrom\": %s", quoteList(file.OverriddenImportsFrom)))
+			}
+			sb.WriteString(" }")
+		}
+		if len(order.Files) > 0 {
+			sb.WriteString("\n      ")
+		}
+		sb.WriteString("],\n      \"ranges\": [")
+		for i, r := range order.Ranges {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(fmt.Sprintf("\n        { \"path\": %s, \"startLine\": %d, \"startColumn\": %d, \"endLine\": %d, \"endColumn\": %d }",
+				quote(r.Path), r.StartLine, r.StartColumn, r.EndLine, r.EndColumn))
+		}
+		if len(order.Ranges) > 0 {
+			sb.WriteString("\n      ")
+		}
+		sb.WriteString("]\n    }")
+	}
+
+	if len(outputs) > 0 {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("},\n  \"duplicates\": [")
+
+	// Find the position of each input file in each output file. Files that are
+	// in more than one layer use the position of their first appearance.
+	positions := make([]map[string]int, len(outputs))
+	var paths []string
+	outputsForPath := make(map[string][]int)
+	for i, output := range outputs {
+		positions[i] = make(map[string]int)
+		for j, file := range output.order.Files {
+			if _, ok := positions[i][file.Path]; !ok {
+				positions[i][file.Path] = j
+				if outputsForPath[file.Path] == nil {
+					paths = append(paths, file.Path)
+				}
+				outputsForPath[file.Path] = append(outputsForPath[file.Path], i)
+			}
+		}
+	}
+	var duplicates []string
+	for _, path := range paths {
+		if len(outputsForPath[path]) > 1 {
+			duplicates = append(duplicates, path)
+		}
+	}
+	relPathsForOutputs := func(indices []int) string {
+		relPaths := make([]string, len(indices))
+		for i, index := range indices {
+			relPaths[i] = outputs[index].relPath
+		}
+		return quoteList(relPaths)
+	}
+
+	for i, path := range duplicates {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n    { \"path\": %s, \"outputs\": %s }", quote(path), relPathsForOutputs(outputsForPath[path])))
+	}
+	if len(duplicates) > 0 {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("],\n  \"orderConflicts\": [")
+
+	// The order of each pair of duplicated files is taken from the first output
+	// file that contains both of them
+	isFirstConflict := true
+	for i, first := range duplicates {
+		for _, second := range duplicates[i+1:] {
+			var inOrder []int
+			var reversed []int
+			isFirstBefore := false
+			for index := range outputs {
+				a, okA := positions[index][first]
+				b, okB := positions[index][second]
+				if !okA || !okB {
+					continue
+				}
+				if len(inOrder) == 0 {
+					isFirstBefore = a < b
+				}
+				if (a < b) == isFirstBefore {
+					inOrder = append(inOrder, index)
+				} else {
+					reversed = append(reversed, index)
+				}
+			}
+			if len(reversed) == 0 {
+				continue
+			}
+			before, after := first, second
+			if !isFirstBefore {
+				before, after = second, first
+			}
+			if !isFirstConflict {
+				sb.WriteString(",")
+			}
+			isFirstConflict = false
+			sb.WriteString(fmt.Sprintf("\n    { \"first\": %s, \"second\": %s, \"outputs\": %s, \"reversedIn\": %s }",
+				quote(before), quote(after), relPathsForOutputs(inOrder), relPathsForOutputs(reversed)))
+		}
+	}
+	if !isFirstConflict {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("]\n}\n")
+
+	contents := sb.String()
+	return graph.OutputFile{
+		AbsPath:           options.AbsCSSOrderReportFile,
+		Contents:          []byte(contents),
+		JSONMetadataChunk: metafileEntryForGeneratedFile(len(contents)),
+	}
+}
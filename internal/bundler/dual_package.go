@@ -0,0 +1,125 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// A dual package contains both a CommonJS version and an ESM version of each
+// entry point so that the package works with both "require()" and "import" in
+// node. The CommonJS version uses the ".cjs" extension and the ESM version uses
+// the ".mjs" extension so that node doesn't need a "type" field to know which
+// is which:
+//
+//   dist/index.cjs
+//   dist/index.mjs
+//
+// Each version is scanned and linked separately because the parser generates
+// different code for different output formats (e.g. for "import.meta" and for
+// calls to "require"). Code splitting only works for the ESM version, so the
+// CommonJS version never has any shared chunks. Output files that are the same
+// for both versions (such as CSS and files from the "file" loader) are only
+// generated once.
+//
+// Both versions use the same interop for the default export. The CommonJS
+// version sets the "__esModule" marker, so "require(pkg).default" is the same
+// value as "import pkg from 'pkg'". Export names are also always annotated in
+// a form that node understands, so named imports of the CommonJS version work
+// in node too.
+
+func dualPackageOptions(options config.Options) (cjs config.Options, esm config.Options) {
+	cjs = options
+	cjs.OutputFormat = config.FormatCommonJS
+	cjs.OutputExtensionJS = ".cjs"
+	cjs.CodeSplitting = false
+
+	esm = options
+	esm.OutputFormat = config.FormatESModule
+	esm.OutputExtensionJS = ".mjs"
+
+	// Converting the format requires a mode that does this even when not bundling
+	if options.Mode == config.ModePassThrough {
+		cjs.Mode = config.ModeConvertFormat
+		esm.Mode = config.ModeConvertFormat
+	}
+	return
+}
+
+func (b *Bundle) linkDualPackage(log logger.Log, options config.Options, timer *helpers.Timer, linkCache *LinkCache) []graph.OutputFile {
+	cjsOptions, esmOptions := dualPackageOptions(options)
+	cjsOutputFiles := b.linkEntryPoints(log, cjsOptions, timer, linkCache)
+	esmOutputFiles := b.esmBundle.linkEntryPoints(log, esmOptions, timer, linkCache)
+
+	if !log.HasErrors() {
+		b.logSuggestedPackageExports(log, cjsOutputFiles, esmOutputFiles)
+	}
+	return append(cjsOutputFiles, esmOutputFiles...)
+}
+
+// Generating type declarations is out of scope for esbuild, so the suggestion
+// just assumes that they will be generated next to each output file
+func (b *Bundle) logSuggestedPackageExports(log logger.Log, cjsOutputFiles []graph.OutputFile, esmOutputFiles []graph.OutputFile) {
+	findEntryPointPath := func(outputFiles []graph.OutputFile, sourceIndex uint32) string {
+		for _, outputFile := range outputFiles {
+			if outputFile.JSEntryPointSourceIndex.IsValid() && outputFile.JSEntryPointSourceIndex.GetIndex() == sourceIndex {
+				return outputFile.AbsPath
+			}
+		}
+		return ""
+	}
+
+	relPathFromCwd := func(absPath string) string {
+		if relPath, ok := b.fs.Rel(b.fs.Cwd(), absPath); ok {
+			absPath = relPath
+		}
+		absPath = strings.ReplaceAll(absPath, "\\", "/")
+		if !strings.HasPrefix(absPath, "../") {
+			absPath = "./" + absPath
+		}
+		return absPath
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("\"exports\": {")
+	count := 0
+	for i, entryPoint := range b.entryPoints {
+		cjsPath := findEntryPointPath(cjsOutputFiles, entryPoint.SourceIndex)
+		esmPath := findEntryPointPath(esmOutputFiles, b.esmBundle.entryPoints[i].SourceIndex)
+		if cjsPath == "" || esmPath == "" {
+			continue
+		}
+
+		// The entry point named "index" is the main export of the package
+		base := b.fs.Base(cjsPath)
+		subpath := "./" + strings.TrimSuffix(base, ".cjs")
+		if subpath == "./index" {
+			subpath = "."
+		}
+
+		cjsPath = relPathFromCwd(cjsPath)
+		esmPath = relPathFromCwd(esmPath)
+		if count > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n  %s: {\n    \"import\": {\n      \"types\": %s,\n      \"default\": %s\n    },\n"+
+			"    \"require\": {\n      \"types\": %s,\n      \"default\": %s\n    }\n  }",
+			js_printer.QuoteForJSON(subpath, false),
+			js_printer.QuoteForJSON(strings.TrimSuffix(esmPath, ".mjs")+".d.mts", false),
+			js_printer.QuoteForJSON(esmPath, false),
+			js_printer.QuoteForJSON(strings.TrimSuffix(cjsPath, ".cjs")+".d.cts", false),
+			js_printer.QuoteForJSON(cjsPath, false)))
+		count++
+	}
+	sb.WriteString("\n}")
+
+	if count > 0 {
+		log.AddWithNotes(logger.Info, nil, logger.Range{}, "Suggested \"exports\" field for \"package.json\":",
+			[]logger.MsgData{{Text: sb.String()}})
+	}
+}
@@ -0,0 +1,158 @@
+package bundler
+
+import (
+	"sort"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/renamer"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+// The size of one of esbuild's own helpers (e.g. "__publicField") in a chunk.
+// Helpers that are imported from "--external-helpers=" have a size of zero.
+type helperSize struct {
+	name  string
+	bytes int
+}
+
+// Helpers that are only used by other helpers (e.g. "__defNormalProp") don't
+// need to be imported from the external helpers module since the helpers that
+// use them come from there too
+func (c *linkerContext) computeExternalHelperRefs() {
+	c.externalHelperRefs = make(map[js_ast.Ref]bool)
+	for _, sourceIndex := range c.graph.ReachableFiles {
+		if sourceIndex == runtime.SourceIndex {
+			continue
+		}
+		if repr, ok := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr); ok {
+			for _, part := range repr.AST.Parts {
+				if !part.IsLive {
+					continue
+				}
+				for ref := range part.SymbolUses {
+					if ref = js_ast.FollowSymbols(c.graph.Symbols, ref); ref.SourceIndex == runtime.SourceIndex {
+						c.externalHelperRefs[ref] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// This is the name of the helper declared by a part of the runtime
+func (c *linkerContext) helperNameForPart(part *js_ast.Part) (js_ast.Ref, string, bool) {
+	for _, declared := range part.DeclaredSymbols {
+		if declared.IsTopLevel {
+			return declared.Ref, c.graph.Symbols.Get(declared.Ref).OriginalName, true
+		}
+	}
+	return js_ast.Ref{}, "", false
+}
+
+// Instead of including the code for esbuild's helpers, this imports the
+// helpers that are used by this chunk from the external helpers module:
+//
+//   import { __publicField } from "helpers";
+//   var { __publicField } = require("helpers");
+//
+func (c *linkerContext) generateExternalHelpersImportJS(r renamer.Renamer, partRanges []partRange) compileResultJS {
+	repr := c.graph.Files[runtime.SourceIndex].InputFile.Repr.(*graph.JSRepr)
+	var items []js_ast.ClauseItem
+	var helperSizes []helperSize
+	for _, partRange := range partRanges {
+		if partRange.sourceIndex != runtime.SourceIndex {
+			continue
+		}
+		for partIndex := partRange.partIndexBegin; partIndex < partRange.partIndexEnd; partIndex++ {
+			part := &repr.AST.Parts[partIndex]
+			if !part.IsLive {
+				continue
+			}
+			if ref, name, ok := c.helperNameForPart(part); ok && c.externalHelperRefs[js_ast.FollowSymbols(c.graph.Symbols, ref)] {
+				items = append(items, js_ast.ClauseItem{Alias: name, Name: js_ast.LocRef{Ref: ref}})
+				helperSizes = append(helperSizes, helperSize{name: name})
+			}
+		}
+	}
+	if len(items) == 0 {
+		return compileResultJS{sourceIndex: runtime.SourceIndex}
+	}
+	sort.Slice(items, func(i int, j int) bool {
+		return items[i].Alias < items[j].Alias
+	})
+
+	var stmt js_ast.Stmt
+	if c.options.OutputFormat == config.FormatCommonJS {
+		properties := make([]js_ast.PropertyBinding, len(items))
+		for i, item := range items {
+			properties[i] = js_ast.PropertyBinding{
+				Key:   js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(item.Alias)}},
+				Value: js_ast.Binding{Data: &js_ast.BIdentifier{Ref: item.Name.Ref}},
+			}
+		}
+		stmt = js_ast.Stmt{Data: &js_ast.SLocal{Decls: []js_ast.Decl{{
+			Binding:    js_ast.Binding{Data: &js_ast.BObject{Properties: properties, IsSingleLine: true}},
+			ValueOrNil: js_ast.Expr{Data: &js_ast.ERequireString{ImportRecordIndex: 0}},
+		}}}}
+	} else {
+		stmt = js_ast.Stmt{Data: &js_ast.SImport{
+			NamespaceRef:      js_ast.InvalidRef,
+			Items:             &items,
+			ImportRecordIndex: 0,
+			IsSingleLine:      true,
+		}}
+	}
+
+	tree := repr.AST
+	tree.Directive = ""
+	tree.Parts = []js_ast.Part{{Stmts: []js_ast.Stmt{stmt}}}
+	tree.ImportRecords = []ast.ImportRecord{{
+		Kind: ast.ImportStmt,
+		Path: logger.Path{Text: c.options.ExternalHelpers},
+	}}
+	printOptions := js_printer.Options{
+		IndentUnit:        c.options.IndentUnit,
+		OutputFormat:      c.options.OutputFormat,
+		RemoveWhitespace:  c.options.RemoveWhitespace,
+		MangleSyntax:      c.options.MangleSyntax,
+		ASCIIOnly:         c.options.ASCIIOnly,
+		CharsetEscapes:    c.options.CharsetEscapes,
+		IdentifierCharset: c.options.IdentifierCharset,
+	}
+	return compileResultJS{
+		PrintResult: js_printer.Print(tree, c.graph.Symbols, r, printOptions),
+		sourceIndex: runtime.SourceIndex,
+		helperSizes: helperSizes,
+	}
+}
+
+// Each helper is printed on its own to measure how much it contributes to the
+// size of the chunk. This is only done when generating the metafile.
+func (c *linkerContext) measureHelpersJS(r renamer.Renamer, partRange partRange, printOptions js_printer.Options) (helperSizes []helperSize) {
+	repr := c.graph.Files[runtime.SourceIndex].InputFile.Repr.(*graph.JSRepr)
+	tree := repr.AST
+	tree.Directive = ""
+	printOptions.AddSourceMappings = false
+	for partIndex := partRange.partIndexBegin; partIndex < partRange.partIndexEnd; partIndex++ {
+		part := &repr.AST.Parts[partIndex]
+		if !part.IsLive {
+			continue
+		}
+		if _, name, ok := c.helperNameForPart(part); ok {
+			stmtList := stmtList{}
+			c.convertStmtsForChunk(runtime.SourceIndex, &stmtList, part.Stmts)
+			tree.Parts = []js_ast.Part{{Stmts: stmtList.insideWrapperSuffix}}
+			helperSizes = append(helperSizes, helperSize{
+				name:  name,
+				bytes: len(js_printer.Print(tree, c.graph.Symbols, r, printOptions).JS),
+			})
+		}
+	}
+	return
+}
@@ -0,0 +1,86 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This generates a report of every feature flag from "--feature:" with its
+// value, where it was referenced, and how many bytes of source code were kept
+// and removed because of its value. Flags that were never referenced are
+// included too since they are probably left over from a removed feature.
+func generateFeatureReport(options *config.Options, files []graph.InputFile, reachableFiles []uint32) graph.OutputFile {
+	type flagReport struct {
+		references    []string
+		keptBytes     int32
+		strippedBytes int32
+	}
+
+	names := make([]string, 0, len(options.FeatureFlags))
+	reports := make(map[string]*flagReport, len(options.FeatureFlags))
+	for name := range options.FeatureFlags {
+		names = append(names, name)
+		reports[name] = &flagReport{}
+	}
+	sort.Strings(names)
+
+	for _, sourceIndex := range reachableFiles {
+		file := &files[sourceIndex]
+		repr, ok := file.Repr.(*graph.JSRepr)
+		if !ok || len(repr.AST.FeatureFlagUses) == 0 {
+			continue
+		}
+		tracker := logger.MakeLineColumnTracker(&file.Source)
+		for _, use := range repr.AST.FeatureFlagUses {
+			report, ok := reports[use.Name]
+			if !ok {
+				continue
+			}
+			location := tracker.MsgLocationOrNil(logger.Range{Loc: use.Loc})
+			report.references = append(report.references, fmt.Sprintf(
+				"{ \"path\": %s, \"line\": %d, \"column\": %d }",
+				js_printer.QuoteForJSON(file.Source.PrettyPath, options.ASCIIOnly), location.Line, location.Column))
+			report.keptBytes += use.KeptBytes
+			report.strippedBytes += use.StrippedBytes
+		}
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString("{\n  \"flags\": {")
+	for i, name := range names {
+		report := reports[name]
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n    %s: {\n      \"value\": %t,\n      \"keptBytes\": %d,\n      \"strippedBytes\": %d,\n      \"references\": [",
+			js_printer.QuoteForJSON(name, options.ASCIIOnly), options.FeatureFlags[name], report.keptBytes, report.strippedBytes))
+		for j, reference := range report.references {
+			if j > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n        ")
+			sb.WriteString(reference)
+		}
+		if len(report.references) > 0 {
+			sb.WriteString("\n      ")
+		}
+		sb.WriteString("]\n    }")
+	}
+	if len(names) > 0 {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("}\n}\n")
+
+	contents := sb.String()
+	return graph.OutputFile{
+		AbsPath:           options.AbsFeatureReportFile,
+		Contents:          []byte(contents),
+		JSONMetadataChunk: metafileEntryForGeneratedFile(len(contents)),
+	}
+}
@@ -0,0 +1,191 @@
+package bundler
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The "image" loader works like the "file" loader except that images can be
+// resized and converted to another format using query parameters in the
+// import path, such as "./hero.png?w=800&format=jpeg". The processed image is
+// written to the output directory instead of the original file. Each import
+// path with a different query is a separate file, so one image can be used in
+// several sizes.
+//
+// Setting only the width or only the height keeps the aspect ratio. Other
+// query parameters are ignored since they may be used for cache busting.
+type imageTransform struct {
+	format  string
+	width   int
+	height  int
+	quality int
+}
+
+func (t imageTransform) isEmpty() bool {
+	return t == imageTransform{}
+}
+
+func parseImageTransform(suffix string) (imageTransform, error) {
+	var t imageTransform
+	if !strings.HasPrefix(suffix, "?") {
+		return t, nil
+	}
+	if hash := strings.IndexByte(suffix, '#'); hash != -1 {
+		suffix = suffix[:hash]
+	}
+	values, err := url.ParseQuery(suffix[1:])
+	if err != nil {
+		return t, fmt.Errorf("Invalid query %q", suffix)
+	}
+
+	parseInt := func(names []string, min int, max int) (int, error) {
+		for _, name := range names {
+			if text := values.Get(name); text != "" {
+				n, err := strconv.Atoi(text)
+				if err != nil || n < min || n > max {
+					return 0, fmt.Errorf("Invalid value for %q: %q (must be an integer from %d to %d)", name, text, min, max)
+				}
+				return n, nil
+			}
+		}
+		return 0, nil
+	}
+	if t.width, err = parseInt([]string{"w", "width"}, 1, 65535); err != nil {
+		return t, err
+	}
+	if t.height, err = parseInt([]string{"h", "height"}, 1, 65535); err != nil {
+		return t, err
+	}
+	if t.quality, err = parseInt([]string{"q", "quality"}, 1, 100); err != nil {
+		return t, err
+	}
+	t.format = strings.ToLower(values.Get("format"))
+	return t, nil
+}
+
+// This returns the extension of the output file, which changes when the image
+// is converted to another format
+func imageOutputExt(originalExt string, t imageTransform) string {
+	if t.format != "" {
+		return "." + t.format
+	}
+	return originalExt
+}
+
+func transformImage(contents string, t imageTransform, encoders map[string]func(image.Image, int) ([]byte, error)) (string, error) {
+	if t.isEmpty() {
+		return contents, nil
+	}
+
+	img, format, err := image.Decode(strings.NewReader(contents))
+	if err != nil {
+		return "", fmt.Errorf("Cannot decode image: %s", err.Error())
+	}
+
+	if t.width != 0 || t.height != 0 {
+		img = resizeImage(img, t.width, t.height)
+	}
+	if t.format != "" {
+		format = t.format
+	}
+
+	// Custom encoders take precedence over the built-in ones
+	if encoder, ok := encoders[format]; ok {
+		data, err := encoder(img, t.quality)
+		if err != nil {
+			return "", fmt.Errorf("Cannot encode image as %q: %s", format, err.Error())
+		}
+		return string(data), nil
+	}
+
+	buffer := bytes.Buffer{}
+	switch format {
+	case "png":
+		err = png.Encode(&buffer, img)
+
+	case "jpeg", "jpg":
+		quality := jpeg.DefaultQuality
+		if t.quality != 0 {
+			quality = t.quality
+		}
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: quality})
+
+	case "gif":
+		err = gif.Encode(&buffer, img, nil)
+
+	default:
+		return "", fmt.Errorf("Cannot encode images in the %q format without a custom image encoder", format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot encode image as %q: %s", format, err.Error())
+	}
+	return buffer.String(), nil
+}
+
+// Each pixel in the resized image is the average of the pixels in the original
+// image that it covers. This avoids the aliasing that sampling would cause when
+// shrinking an image, which is the common case. Averaging is done using
+// premultiplied alpha so that transparent pixels don't bleed into their
+// neighbors.
+func resizeImage(img image.Image, width int, height int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW == 0 || srcH == 0 {
+		return img
+	}
+	if width == 0 {
+		width = (srcW*height + srcH/2) / srcH
+		if width < 1 {
+			width = 1
+		}
+	} else if height == 0 {
+		height = (srcH*width + srcW/2) / srcW
+		if height < 1 {
+			height = 1
+		}
+	}
+
+	src := image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcH / height
+		y1 := (y + 1) * srcH / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := x * srcW / width
+			x1 := (x + 1) * srcW / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+			count := (x1 - x0) * (y1 - y0)
+			i := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + count/2) / count)
+			}
+		}
+	}
+
+	return dst
+}
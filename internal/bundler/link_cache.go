@@ -0,0 +1,249 @@
+package bundler
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/xxhash"
+)
+
+// When code splitting is disabled, each entry point is linked separately. This
+// is a cache of the output files for each entry point from the previous build
+// so that rebuilds in watch mode or incremental mode only have to re-link the
+// entry points that can reach a file that changed.
+//
+// Each entry point is keyed on a fingerprint of every file reachable from it.
+// The fingerprint includes the path, loader, and contents of each file as well
+// as the import graph order and the build options, so any change that could
+// affect the linked output also changes the fingerprint.
+type LinkCache struct {
+	mutex   sync.Mutex
+	entries map[linkCacheKey]linkCacheEntry
+}
+
+type linkCacheKey struct {
+	namespace  string
+	path       string
+	outputPath string
+
+	// Each half of a dual package links the same entry point separately
+	format config.Format
+}
+
+type linkCacheEntry struct {
+	fingerprint uint64
+	outputFiles []graph.OutputFile
+}
+
+func MakeLinkCache() *LinkCache {
+	return &LinkCache{
+		entries: make(map[linkCacheKey]linkCacheEntry),
+	}
+}
+
+func makeLinkCacheKey(files []graph.InputFile, entryPoint graph.EntryPoint, format config.Format) linkCacheKey {
+	keyPath := files[entryPoint.SourceIndex].Source.KeyPath
+	return linkCacheKey{
+		namespace:  keyPath.Namespace,
+		path:       keyPath.Text,
+		outputPath: entryPoint.OutputPath,
+		format:     format,
+	}
+}
+
+// The build options are hashed by walking their values instead of comparing
+// pointers because they are created again for every rebuild. Functions (e.g.
+// plugin callbacks) can't be compared, so only whether they are present is
+// included. This is done once per build since the options are the same for
+// every entry point.
+func linkCacheOptionsHash(options *config.Options) uint64 {
+	hash := xxhash.New()
+	hashLinkCacheValue(hash, reflect.ValueOf(options).Elem(), make(map[uintptr]bool))
+	return hash.Sum64()
+}
+
+var regexpType = reflect.TypeOf(regexp.Regexp{})
+
+func hashLinkCacheValue(hash *xxhash.Digest, value reflect.Value, visited map[uintptr]bool) {
+	var buffer [8]byte
+	writeUint := func(n uint64) {
+		binary.LittleEndian.PutUint64(buffer[:], n)
+		hash.Write(buffer[:])
+	}
+	writeBool := func(b bool) {
+		if b {
+			writeUint(1)
+		} else {
+			writeUint(0)
+		}
+	}
+	writeString := func(text string) {
+		writeUint(uint64(len(text)))
+		hash.Write([]byte(text))
+	}
+
+	switch value.Kind() {
+	case reflect.Bool:
+		writeBool(value.Bool())
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(uint64(value.Int()))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(value.Uint())
+
+	case reflect.Float32, reflect.Float64:
+		writeUint(math.Float64bits(value.Float()))
+
+	case reflect.String:
+		writeString(value.String())
+
+	case reflect.Ptr:
+		writeBool(value.IsNil())
+		if value.IsNil() {
+			return
+		}
+
+		// Compiled regular expressions are identified by their source. This reads
+		// the field directly since the pointer may come from an unexported field.
+		if value.Type().Elem() == regexpType {
+			writeString(value.Elem().FieldByName("expr").String())
+			return
+		}
+
+		// Avoid following cycles
+		if ptr := value.Pointer(); !visited[ptr] {
+			visited[ptr] = true
+			hashLinkCacheValue(hash, value.Elem(), visited)
+		}
+
+	case reflect.Interface:
+		writeBool(value.IsNil())
+		if !value.IsNil() {
+			writeString(value.Elem().Type().String())
+			hashLinkCacheValue(hash, value.Elem(), visited)
+		}
+
+	case reflect.Slice, reflect.Array:
+		writeUint(uint64(value.Len()))
+		for i, n := 0, value.Len(); i < n; i++ {
+			hashLinkCacheValue(hash, value.Index(i), visited)
+		}
+
+	case reflect.Map:
+		// Map iteration order is random, so hash each entry separately and then
+		// combine the entry hashes in sorted order
+		entries := make([]uint64, 0, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			entry := xxhash.New()
+			hashLinkCacheValue(entry, iter.Key(), visited)
+			hashLinkCacheValue(entry, iter.Value(), visited)
+			entries = append(entries, entry.Sum64())
+		}
+		sort.Slice(entries, func(i int, j int) bool { return entries[i] < entries[j] })
+		writeUint(uint64(len(entries)))
+		for _, entry := range entries {
+			writeUint(entry)
+		}
+
+	case reflect.Struct:
+		// Locks don't affect the output and their state changes while building
+		if value.Type().PkgPath() == "sync" {
+			return
+		}
+		for i, n := 0, value.NumField(); i < n; i++ {
+			hashLinkCacheValue(hash, value.Field(i), visited)
+		}
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		writeBool(value.IsNil())
+	}
+}
+
+func linkCacheFingerprint(files []graph.InputFile, reachableFiles []uint32, mangledProps map[string]string, optionsHash uint64) uint64 {
+	var buffer [4]byte
+	hash := xxhash.New()
+	var optionsBuffer [8]byte
+	binary.LittleEndian.PutUint64(optionsBuffer[:], optionsHash)
+	hash.Write(optionsBuffer[:])
+	for _, sourceIndex := range reachableFiles {
+		source := &files[sourceIndex].Source
+
+		// Include lengths so that adjacent strings can't run together
+		binary.LittleEndian.PutUint32(buffer[:], uint32(len(source.KeyPath.Namespace)))
+		hash.Write(buffer[:])
+		hash.Write([]byte(source.KeyPath.Namespace))
+		binary.LittleEndian.PutUint32(buffer[:], uint32(len(source.KeyPath.Text)))
+		hash.Write(buffer[:])
+		hash.Write([]byte(source.KeyPath.Text))
+		binary.LittleEndian.PutUint32(buffer[:], uint32(files[sourceIndex].Loader))
+		hash.Write(buffer[:])
+		binary.LittleEndian.PutUint32(buffer[:], uint32(len(source.Contents)))
+		hash.Write(buffer[:])
+		hash.Write([]byte(source.Contents))
+
+		// Files that weren't loaded into memory are identified by where they are
+		// copied from instead. Their contents aren't in memory to hash, but the
+		// output path of the copy contains the content hash if there is one, and
+		// that path is what the linked output refers to. Copied files themselves
+		// are always written again instead of being reused.
+		contentsAbsPath := files[sourceIndex].ContentsAbsPath
+		binary.LittleEndian.PutUint32(buffer[:], uint32(len(contentsAbsPath)))
+		hash.Write(buffer[:])
+		hash.Write([]byte(contentsAbsPath))
+		binary.LittleEndian.PutUint32(buffer[:], uint32(files[sourceIndex].ContentsLen))
+		hash.Write(buffer[:])
+		for _, additionalFile := range files[sourceIndex].AdditionalFiles {
+			binary.LittleEndian.PutUint32(buffer[:], uint32(len(additionalFile.AbsPath)))
+			hash.Write(buffer[:])
+			hash.Write([]byte(additionalFile.AbsPath))
+		}
+	}
+
+	// Mangled property names depend on every file in the build, not just the
+	// files reachable from this entry point
+	names := make([]string, 0, len(mangledProps))
+	for name := range mangledProps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, text := range []string{name, mangledProps[name]} {
+			binary.LittleEndian.PutUint32(buffer[:], uint32(len(text)))
+			hash.Write(buffer[:])
+			hash.Write([]byte(text))
+		}
+	}
+	return hash.Sum64()
+}
+
+func (c *LinkCache) get(key linkCacheKey, fingerprint uint64) ([]graph.OutputFile, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entry, ok := c.entries[key]; ok && entry.fingerprint == fingerprint {
+		return entry.outputFiles, true
+	}
+	return nil, false
+}
+
+func (c *LinkCache) set(key linkCacheKey, fingerprint uint64, outputFiles []graph.OutputFile) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = linkCacheEntry{fingerprint: fingerprint, outputFiles: outputFiles}
+}
+
+// This drops all cached output files. The fingerprint includes the build
+// options, so this is only needed to free memory for outputs that can no
+// longer be reused once options that affect them have changed.
+func (c *LinkCache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[linkCacheKey]linkCacheEntry)
+}
@@ -44,6 +44,22 @@ type linkerContext struct {
 	// We may need to refer to the CommonJS "module" symbol for exports
 	unboundModuleRef js_ast.Ref
 
+	// The chunk name that the "ManualChunks" callback returned for each file.
+	// This is only called once for each file since the callback may be slow.
+	manualChunkNames map[uint32]string
+
+	// This is the named chunk for each file that the chunk size limits moved
+	sizeLimitChunkNames map[uint32]string
+
+	// Chunks in the "iife" format load other chunks using a function that is
+	// passed to them by the chunk loader
+	unboundImportChunkRef js_ast.Ref
+
+	// External "import()" expressions are loaded with "__loadScript()" when
+	// this is true. It's decided once here and passed to the printer so that
+	// the parser, the linker, and the printer all agree.
+	callRuntimeLoadScript bool
+
 	// We may need to refer to the "__esm" and/or "__commonJS" runtime symbols
 	cjsRuntimeRef js_ast.Ref
 	esmRuntimeRef js_ast.Ref
@@ -53,9 +69,21 @@ type linkerContext struct {
 	// is shared between threads and must be treated as immutable.
 	dataForSourceMaps func() []dataForSourceMap
 
+	// These class and object members are never used anywhere in the bundle and
+	// are omitted from the output when member tree shaking is enabled
+	removedMembers map[*js_ast.Property]bool
+
 	// This is passed to us from the bundling phase
 	uniqueKeyPrefix      string
 	uniqueKeyPrefixBytes []byte // This is just "uniqueKeyPrefix" in byte form
+
+	// This is only present if chunks should be renamed to avoid conflicts
+	chunkPaths     *chunkPathReservations
+	chunkPathsTurn int
+
+	// These are the runtime helpers that are used outside of the runtime. They
+	// are imported from "ExternalHelpers" instead of being included.
+	externalHelperRefs map[js_ast.Ref]bool
 }
 
 type partRange struct {
@@ -72,11 +100,22 @@ type chunkInfo struct {
 	filesWithPartsInChunk map[uint32]bool
 	entryBits             helpers.BitSet
 
+	// This is non-empty for the "runtime" and "vendor" chunks that are created
+	// by the "vendor" splitting preset. These chunks aren't keyed by entry bits.
+	presetChunkName string
+
 	// This information is only useful if "isEntryPoint" is true
 	isEntryPoint  bool
 	sourceIndex   uint32 // An index into "c.sources"
 	entryPointBit uint   // An index into "c.graph.EntryPoints"
 
+	// JS entry points that import CSS files also generate a CSS chunk. This is
+	// the index of that chunk, if there is one. If "injectsCSSBundle" is true,
+	// the JS chunk adds a "<link>" tag for the CSS chunk when it's run, so the
+	// path of the CSS chunk is part of the JS chunk's contents.
+	cssBundleChunkIndex ast.Index32
+	injectsCSSBundle    bool
+
 	// For code splitting
 	crossChunkImports []chunkImport
 
@@ -91,9 +130,24 @@ type chunkInfo struct {
 	// is the substitution of the final hash into "finalTemplate".
 	finalRelPath string
 
+	// When the content manifest is enabled, the final path is just the content
+	// hash and this is the path the chunk would have had otherwise
+	logicalTemplate []config.PathTemplate
+	logicalRelPath  string
+
 	// If non-empty, this chunk needs to generate an external legal comments file.
 	externalLegalComments []byte
 
+	// These are the zero-based lines that the banner and footer start on, or
+	// zero if this chunk doesn't have one
+	bannerLine int
+	footerLine int
+
+	// These are the ranges of the intermediate output that came from each input
+	// file. They are only generated for JavaScript chunks with a module map and
+	// for CSS chunks when there is a CSS order report.
+	moduleMapRanges []moduleMapRange
+
 	// When this chunk is initially generated in isolation, the output pieces
 	// will contain slices of the output with the unique keys of other chunks
 	// omitted.
@@ -166,11 +220,35 @@ type chunkReprJS struct {
 	crossChunkSuffixStmts  []js_ast.Stmt
 	exportsToOtherChunks   map[js_ast.Ref]string
 	importsFromOtherChunks map[uint32]crossChunkImportItemArray
+
+	// Chunks in the "iife" format are loaded by a small loader instead of using
+	// statements (see "split_formats.go")
+	loaderImports []chunkLoaderImport
+	loaderExports []js_ast.ClauseItem
 }
 
 type chunkReprCSS struct {
 	externalImportsInOrder []externalImportCSS
 	filesInChunkInOrder    []uint32
+
+	// This is either nil or the cascade layer of each file in the order above,
+	// followed by the order of all named cascade layers in this chunk
+	layersInChunkInOrder [][]string
+	layerOrder           []string
+
+	// This is either nil or why each file is where it is in the order above.
+	// It's only generated when there is a CSS order report.
+	orderReasons []cssOrderReason
+}
+
+type cssOrderReason struct {
+	// This is the file whose "@import" decided where this file goes. It's
+	// invalid for entry points and for files imported from JavaScript.
+	importer ast.Index32
+
+	// These are the other files that imported this file. Their imports were
+	// dropped because this file is evaluated again later on.
+	overriddenImporters []uint32
 }
 
 type externalImportCSS struct {
@@ -216,6 +294,8 @@ func link(
 	uniqueKeyPrefix string,
 	reachableFiles []uint32,
 	dataForSourceMaps func() []dataForSourceMap,
+	chunkPaths *chunkPathReservations,
+	chunkPathsTurn int,
 ) []graph.OutputFile {
 	timer.Begin("Link")
 	defer timer.End("Link")
@@ -232,6 +312,8 @@ func link(
 		dataForSourceMaps:    dataForSourceMaps,
 		uniqueKeyPrefix:      uniqueKeyPrefix,
 		uniqueKeyPrefixBytes: []byte(uniqueKeyPrefix),
+		chunkPaths:           chunkPaths,
+		chunkPathsTurn:       chunkPathsTurn,
 		graph: graph.CloneLinkerGraph(
 			inputFiles,
 			reachableFiles,
@@ -251,7 +333,9 @@ func link(
 		c.esmRuntimeRef = runtimeRepr.AST.NamedExports["__esmMin"].Ref
 	}
 
-	for _, entryPoint := range entryPoints {
+	// This includes the entry points for dynamic imports when code splitting is
+	// active since their exports are observed by the code that imports them
+	for _, entryPoint := range c.graph.EntryPoints() {
 		if repr, ok := c.graph.Files[entryPoint.SourceIndex].InputFile.Repr.(*graph.JSRepr); ok {
 			// Loaders default to CommonJS when they are the entry point and the output
 			// format is not ESM-compatible since that avoids generating the ESM-to-CJS
@@ -263,10 +347,12 @@ func link(
 
 			// Entry points with ES6 exports must generate an exports object when
 			// targeting non-ES6 formats. Note that the IIFE format only needs this
-			// when the global name is present, since that's the only way the exports
-			// can actually be observed externally.
+			// when the global name is present or when code splitting is enabled,
+			// since that's the only way the exports can actually be observed
+			// externally.
 			if repr.AST.ExportKeyword.Len > 0 && (options.OutputFormat == config.FormatCommonJS ||
-				(options.OutputFormat == config.FormatIIFE && len(options.GlobalName) > 0)) {
+				options.OutputFormat == config.FormatUMD ||
+				(options.OutputFormat == config.FormatIIFE && (len(options.GlobalName) > 0 || options.CodeSplitting))) {
 				repr.AST.UsesExportsRef = true
 				repr.Meta.ForceIncludeExportsForEntryPoint = true
 			}
@@ -279,6 +365,13 @@ func link(
 	} else {
 		c.unboundModuleRef = js_ast.InvalidRef
 	}
+	if c.options.OutputFormat == config.FormatIIFE && c.options.CodeSplitting {
+		c.unboundImportChunkRef = c.graph.GenerateNewSymbol(runtime.SourceIndex, js_ast.SymbolUnbound, "__importChunk")
+	} else {
+		c.unboundImportChunkRef = js_ast.InvalidRef
+	}
+	c.callRuntimeLoadScript = c.options.UnsupportedJSFeatures.Has(compat.DynamicImport) &&
+		config.ShouldCallRuntimeLoadScript(c.options.Mode, c.options.OutputFormat, c.options.DynamicImportLoader)
 
 	c.scanImportsAndExports()
 
@@ -289,6 +382,10 @@ func link(
 
 	c.treeShakingAndCodeSplitting()
 
+	if c.options.TreeShakingMembers {
+		c.removedMembers = c.findRemovedMembers()
+	}
+
 	if c.options.Mode == config.ModePassThrough {
 		for _, entryPoint := range c.graph.EntryPoints() {
 			c.preventExportsFromBeingRenamed(entryPoint.SourceIndex)
@@ -302,6 +399,10 @@ func link(
 	// won't hit concurrent map mutation hazards
 	js_ast.FollowAllSymbols(c.graph.Symbols)
 
+	if c.options.ExternalHelpers != "" {
+		c.computeExternalHelperRefs()
+	}
+
 	return c.generateChunksInParallel(chunks)
 }
 
@@ -342,6 +443,12 @@ func (c *linkerContext) enforceNoCyclicChunkImports(chunks []chunkInfo) {
 	}
 }
 
+// Files that esbuild generates alongside the output files, such as source maps
+// and manifests, have no inputs. Their metafile entry only has their size.
+func metafileEntryForGeneratedFile(bytes int) string {
+	return fmt.Sprintf("{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": {},\n      \"bytes\": %d\n    }", bytes)
+}
+
 func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.OutputFile {
 	c.timer.Begin("Generate chunks")
 	defer c.timer.End("Generate chunks")
@@ -383,6 +490,16 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 		chunk.finalRelPath = config.TemplateToString(config.SubstituteTemplate(chunk.finalTemplate, config.PathPlaceholders{
 			Hash: hashSubstitution,
 		}))
+		if chunk.logicalTemplate != nil {
+			chunk.logicalRelPath = config.TemplateToString(config.SubstituteTemplate(chunk.logicalTemplate, config.PathPlaceholders{
+				Hash: hashSubstitution,
+			}))
+		}
+	}
+
+	// Rename chunks that would overwrite other files if requested
+	if c.chunkPaths != nil {
+		c.renameConflictingChunks(chunks)
 	}
 
 	// Generate the final output files by joining file pieces together
@@ -413,6 +530,12 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 				commentSuffix = " */"
 			}
 
+			// This is used to say where output files came from in error messages
+			var inputPath string
+			if chunk.isEntryPoint {
+				inputPath = c.graph.Files[chunk.sourceIndex].InputFile.Source.PrettyPath
+			}
+
 			// Path substitution for the chunk itself
 			finalRelDir := c.fs.Dir(chunk.finalRelPath)
 			outputContentsJoiner, outputSourceMapShifts := c.substituteFinalPaths(chunks, chunk.intermediateOutput,
@@ -435,19 +558,58 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 				}
 
 				// Write the external legal comments file
+				legalComments := c.convertLineEndings(chunk.externalLegalComments)
 				outputFiles = append(outputFiles, graph.OutputFile{
-					AbsPath:  c.fs.Join(c.options.AbsOutputDir, finalRelPathForLegalComments),
-					Contents: chunk.externalLegalComments,
-					JSONMetadataChunk: fmt.Sprintf(
-						"{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": {},\n      \"bytes\": %d\n    }", len(chunk.externalLegalComments)),
+					AbsPath:           c.fs.Join(c.options.AbsOutputDir, finalRelPathForLegalComments),
+					Contents:          legalComments,
+					Kind:              graph.OutputLegalComments,
+					InputPath:         inputPath,
+					JSONMetadataChunk: metafileEntryForGeneratedFile(len(legalComments)),
 				})
 			}
 
+			// Generate the optional module map for this chunk
+			if _, ok := chunk.chunkRepr.(*chunkReprJS); ok && c.options.ModuleMap {
+				finalRelPathForModuleMap := chunk.finalRelPath + ".modules.json"
+				moduleMap := c.convertLineEndings(c.generateModuleMap(chunk.moduleMapRanges, outputSourceMapShifts, c.fs.Base(chunk.finalRelPath)))
+				outputFiles = append(outputFiles, graph.OutputFile{
+					AbsPath:           c.fs.Join(c.options.AbsOutputDir, finalRelPathForModuleMap),
+					Contents:          moduleMap,
+					Kind:              graph.OutputModuleMap,
+					InputPath:         inputPath,
+					JSONMetadataChunk: metafileEntryForGeneratedFile(len(moduleMap)),
+				})
+			}
+
+			// Generate the optional CommonJS wrapper for this entry point
+			if c.options.CJSWrapper && chunk.isEntryPoint {
+				if wrapper, ok := c.generateCJSWrapper(&chunk, inputPath); ok {
+					outputFiles = append(outputFiles, wrapper)
+				}
+			}
+
 			// Generate the optional source map for this chunk
 			if c.options.SourceMap != config.SourceMapNone && chunk.outputSourceMap.HasContent() {
 				outputSourceMap := chunk.outputSourceMap.Finalize(outputSourceMapShifts)
 				finalRelPathForSourceMap := chunk.finalRelPath + ".map"
 
+				// Let plugins change the source map before it's written. This happens
+				// before the source map comment is generated so inline source maps
+				// include the changes too.
+				if len(c.options.Plugins) > 0 {
+					var absSourceMapPath string
+					switch c.options.SourceMap {
+					case config.SourceMapLinkedWithComment, config.SourceMapInlineAndExternal, config.SourceMapExternalWithoutComment:
+						absSourceMapPath = c.fs.Join(c.options.AbsOutputDir, finalRelPathForSourceMap)
+					}
+					outputSourceMap = runOnSourceMapPlugins(c.options.Plugins, c.res, c.log, config.OnSourceMapArgs{
+						Path:          c.fs.Join(c.options.AbsOutputDir, chunk.finalRelPath),
+						SourceMapPath: absSourceMapPath,
+						SourceMap:     string(outputSourceMap),
+					})
+				}
+				outputSourceMap = c.convertLineEndings(outputSourceMap)
+
 				// Potentially write a trailing source map comment
 				switch c.options.SourceMap {
 				case config.SourceMapLinkedWithComment:
@@ -473,16 +635,18 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 				switch c.options.SourceMap {
 				case config.SourceMapLinkedWithComment, config.SourceMapInlineAndExternal, config.SourceMapExternalWithoutComment:
 					outputFiles = append(outputFiles, graph.OutputFile{
-						AbsPath:  c.fs.Join(c.options.AbsOutputDir, finalRelPathForSourceMap),
-						Contents: outputSourceMap,
-						JSONMetadataChunk: fmt.Sprintf(
-							"{\n      \"imports\": [],\n      \"exports\": [],\n      \"inputs\": {},\n      \"bytes\": %d\n    }", len(outputSourceMap)),
+						AbsPath:           c.fs.Join(c.options.AbsOutputDir, finalRelPathForSourceMap),
+						Contents:          outputSourceMap,
+						Kind:              graph.OutputSourceMap,
+						InputPath:         inputPath,
+						JSONMetadataChunk: metafileEntryForGeneratedFile(len(outputSourceMap)),
 					})
 				}
 			}
 
-			// Finalize the output contents
-			outputContents := outputContentsJoiner.Done()
+			// Finalize the output contents. Changing the line endings doesn't affect
+			// the source map because a "\r\n" sequence is still a single line break.
+			outputContents := c.convertLineEndings(outputContentsJoiner.Done())
 
 			// Path substitution for the JSON metadata
 			var jsonMetadataChunk string
@@ -495,11 +659,31 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 			}
 
 			// Generate the output file for this chunk
+			var logicalAbsPath string
+			if chunk.logicalRelPath != "" {
+				logicalAbsPath = c.fs.Join(c.options.AbsOutputDir, chunk.logicalRelPath)
+			}
+			var jsEntryPointSourceIndex ast.Index32
+			if _, ok := chunk.chunkRepr.(*chunkReprJS); ok && chunk.isEntryPoint {
+				jsEntryPointSourceIndex = ast.MakeIndex32(chunk.sourceIndex)
+			}
+			var cssOrder *graph.CSSOrder
+			if chunkRepr, ok := chunk.chunkRepr.(*chunkReprCSS); ok && c.options.AbsCSSOrderReportFile != "" {
+				cssOrder = c.cssOrderForChunk(chunkRepr, chunk.moduleMapRanges, outputSourceMapShifts)
+			}
 			outputFiles = append(outputFiles, graph.OutputFile{
-				AbsPath:           c.fs.Join(c.options.AbsOutputDir, chunk.finalRelPath),
-				Contents:          outputContents,
-				JSONMetadataChunk: jsonMetadataChunk,
-				IsExecutable:      chunk.isExecutable,
+				AbsPath:                 c.fs.Join(c.options.AbsOutputDir, chunk.finalRelPath),
+				LogicalAbsPath:          logicalAbsPath,
+				Contents:                outputContents,
+				Kind:                    graph.OutputChunk,
+				InputPath:               inputPath,
+				JSONMetadataChunk:       jsonMetadataChunk,
+				IsExecutable:            chunk.isExecutable,
+				IsHashed:                config.HasPlaceholder(chunk.finalTemplate, config.HashPlaceholder),
+				JSEntryPointSourceIndex: jsEntryPointSourceIndex,
+				BannerLine:              chunk.bannerLine,
+				FooterLine:              chunk.footerLine,
+				CSSOrder:                cssOrder,
 			})
 
 			results[chunkIndex] = outputFiles
@@ -509,6 +693,9 @@ func (c *linkerContext) generateChunksInParallel(chunks []chunkInfo) []graph.Out
 	resultsWaitGroup.Wait()
 	c.timer.End("Generate final output files")
 
+	// Warn about output files that will be expensive to parse and evaluate
+	c.checkOutputBudgets(chunks, results)
+
 	// Merge the output files from the different goroutines together in order
 	outputFilesLen := 0
 	for _, result := range results {
@@ -550,7 +737,7 @@ func (c *linkerContext) substituteFinalPaths(
 		switch piece.kind {
 		case outputPieceAssetIndex:
 			file := c.graph.Files[piece.index]
-			if len(file.InputFile.AdditionalFiles) != 1 {
+			if len(file.InputFile.AdditionalFiles) == 0 {
 				panic("Internal error")
 			}
 			relPath, _ := c.fs.Rel(c.options.AbsOutputDir, file.InputFile.AdditionalFiles[0].AbsPath)
@@ -748,6 +935,12 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkInfo) {
 								record.Path.Text = chunks[otherChunkIndex].uniqueKey
 								record.SourceIndex = ast.Index32{}
 
+								// Chunks in the "cjs" format export using "module.exports", so
+								// they must be loaded with "require()" to see those exports
+								if c.options.CodeSplitting && c.options.OutputFormat == config.FormatCommonJS {
+									record.CallRequireInsteadOfImport = true
+								}
+
 								// Track this cross-chunk dynamic import so we make sure to
 								// include its hash when we're calculating the hashes of all
 								// dependencies of this chunk.
@@ -922,26 +1115,35 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkInfo) {
 		}
 
 		chunkRepr.exportsToOtherChunks = make(map[js_ast.Ref]string)
+		r := renamer.ExportRenamer{}
+		var items []js_ast.ClauseItem
+		for _, export := range c.sortedCrossChunkExportItems(chunkMetas[chunkIndex].exports) {
+			var alias string
+			if c.options.MinifyIdentifiers {
+				alias = r.NextMinifiedName()
+			} else {
+				alias = r.NextRenamedName(c.graph.Symbols.Get(export.Ref).OriginalName)
+			}
+			items = append(items, js_ast.ClauseItem{Name: js_ast.LocRef{Ref: export.Ref}, Alias: alias})
+			chunkRepr.exportsToOtherChunks[export.Ref] = alias
+		}
+
 		switch c.options.OutputFormat {
 		case config.FormatESModule:
-			r := renamer.ExportRenamer{}
-			var items []js_ast.ClauseItem
-			for _, export := range c.sortedCrossChunkExportItems(chunkMetas[chunkIndex].exports) {
-				var alias string
-				if c.options.MinifyIdentifiers {
-					alias = r.NextMinifiedName()
-				} else {
-					alias = r.NextRenamedName(c.graph.Symbols.Get(export.Ref).OriginalName)
-				}
-				items = append(items, js_ast.ClauseItem{Name: js_ast.LocRef{Ref: export.Ref}, Alias: alias})
-				chunkRepr.exportsToOtherChunks[export.Ref] = alias
-			}
 			if len(items) > 0 {
 				chunkRepr.crossChunkSuffixStmts = []js_ast.Stmt{{Data: &js_ast.SExportClause{
 					Items: items,
 				}}}
 			}
 
+		case config.FormatCommonJS:
+			if len(items) > 0 {
+				chunkRepr.crossChunkSuffixStmts = c.crossChunkExportStmtsCJS(items, chunk.isEntryPoint)
+			}
+
+		case config.FormatIIFE:
+			chunkRepr.loaderExports = items
+
 		default:
 			panic("Internal error")
 		}
@@ -960,13 +1162,14 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkInfo) {
 		var crossChunkPrefixStmts []js_ast.Stmt
 
 		for _, crossChunkImport := range c.sortedCrossChunkImports(chunks, chunkRepr.importsFromOtherChunks) {
+			var items []js_ast.ClauseItem
+			for _, item := range crossChunkImport.sortedImportItems {
+				items = append(items, js_ast.ClauseItem{Name: js_ast.LocRef{Ref: item.ref}, Alias: item.exportAlias})
+			}
+			importRecordIndex := uint32(len(chunk.crossChunkImports))
+
 			switch c.options.OutputFormat {
 			case config.FormatESModule:
-				var items []js_ast.ClauseItem
-				for _, item := range crossChunkImport.sortedImportItems {
-					items = append(items, js_ast.ClauseItem{Name: js_ast.LocRef{Ref: item.ref}, Alias: item.exportAlias})
-				}
-				importRecordIndex := uint32(len(chunk.crossChunkImports))
 				chunk.crossChunkImports = append(chunk.crossChunkImports, chunkImport{
 					importKind: ast.ImportStmt,
 					chunkIndex: crossChunkImport.chunkIndex,
@@ -984,6 +1187,23 @@ func (c *linkerContext) computeCrossChunkDependencies(chunks []chunkInfo) {
 					}})
 				}
 
+			case config.FormatCommonJS:
+				chunk.crossChunkImports = append(chunk.crossChunkImports, chunkImport{
+					importKind: ast.ImportRequire,
+					chunkIndex: crossChunkImport.chunkIndex,
+				})
+				crossChunkPrefixStmts = append(crossChunkPrefixStmts, crossChunkImportStmtCJS(items, importRecordIndex))
+
+			case config.FormatIIFE:
+				chunk.crossChunkImports = append(chunk.crossChunkImports, chunkImport{
+					importKind: ast.ImportStmt,
+					chunkIndex: crossChunkImport.chunkIndex,
+				})
+				chunkRepr.loaderImports = append(chunkRepr.loaderImports, chunkLoaderImport{
+					chunkIndex: crossChunkImport.chunkIndex,
+					items:      items,
+				})
+
 			default:
 				panic("Internal error")
 			}
@@ -1118,6 +1338,19 @@ func (c *linkerContext) scanImportsAndExports() {
 				otherFile := &c.graph.Files[record.SourceIndex.GetIndex()]
 				otherRepr := otherFile.InputFile.Repr.(*graph.JSRepr)
 
+				// Imports of files from the "copy" loader are kept as imports of the
+				// copy in the output directory, which makes them external imports
+				if otherFile.InputFile.Loader == config.LoaderCopy {
+					record.Path.Text = otherRepr.AST.URLForCSS
+					record.Path.Namespace = ""
+					record.SourceIndex = ast.Index32{}
+
+					// Copy the file to the output directory along with this file
+					additionalFiles := file.InputFile.AdditionalFiles
+					file.InputFile.AdditionalFiles = append(additionalFiles[:len(additionalFiles):len(additionalFiles)], otherFile.InputFile.AdditionalFiles...)
+					continue
+				}
+
 				switch record.Kind {
 				case ast.ImportStmt:
 					// Importing using ES6 syntax from a file without any ES6 syntax
@@ -1173,9 +1406,35 @@ func (c *linkerContext) scanImportsAndExports() {
 			// resulting wrapper won't be invoked by other files. An exception is made
 			// for entry point files in CommonJS format (or when in pass-through mode).
 			if repr.AST.ExportsKind == js_ast.ExportsCommonJS && (!file.IsEntryPoint() ||
-				c.options.OutputFormat == config.FormatIIFE || c.options.OutputFormat == config.FormatESModule) {
+				c.options.OutputFormat.IsWrappedInFunction() || c.options.OutputFormat == config.FormatESModule) {
 				repr.Meta.Wrap = graph.WrapCJS
 			}
+
+			// Files in isolated packages keep their module wrappers. That way they
+			// are evaluated when they are first imported instead of being hoisted
+			// to the top level of the bundle along with the code that imports them.
+			if repr.Meta.Wrap == graph.WrapNone && c.isInsideOneOfPackages(file.InputFile.Source.KeyPath, c.options.IsolatedPackages) {
+				if repr.AST.ExportsKind == js_ast.ExportsCommonJS {
+					repr.Meta.Wrap = graph.WrapCJS
+				} else {
+					repr.Meta.Wrap = graph.WrapESM
+				}
+			}
+
+			// Files in lazy packages are wrapped too, but initializing them is
+			// deferred further until one of their exports is first used. This
+			// isn't done with code splitting because the initializer would then
+			// need to be imported into every chunk that uses one of the exports.
+			if !c.options.CodeSplitting && c.isInsideOneOfPackages(file.InputFile.Source.KeyPath, c.options.LazyPackages) {
+				if repr.Meta.Wrap == graph.WrapNone {
+					if repr.AST.ExportsKind == js_ast.ExportsCommonJS {
+						repr.Meta.Wrap = graph.WrapCJS
+					} else {
+						repr.Meta.Wrap = graph.WrapESM
+					}
+				}
+				repr.Meta.IsLazy = true
+			}
 		}
 	}
 	c.timer.End("Step 1")
@@ -1475,6 +1734,7 @@ func (c *linkerContext) scanImportsAndExports() {
 		for partIndex, part := range repr.AST.Parts {
 			toModuleUses := uint32(0)
 			runtimeRequireUses := uint32(0)
+			loadScriptUses := uint32(0)
 
 			// Imports of wrapped files must depend on the wrapper
 			for _, importRecordIndex := range part.ImportRecordIndices {
@@ -1482,7 +1742,28 @@ func (c *linkerContext) scanImportsAndExports() {
 
 				// Don't follow external imports (this includes import() expressions)
 				if !record.SourceIndex.IsValid() || c.isExternalDynamicImport(record, sourceIndex) {
-					// This is an external import. Check if it will be a "require()" call.
+					// External imports that are read from a global variable don't call
+					// "require()" but still need the "__toModule" wrapper if they weren't
+					// originally a CommonJS import
+					if record.IsExternalGlobal {
+						if record.Kind != ast.ImportRequire {
+							record.WrapWithToModule = true
+							toModuleUses++
+						}
+						continue
+					}
+
+					// This is an external import. Check if it will be loaded with a
+					// script tag because this browser doesn't support "import()".
+					if record.Kind == ast.ImportDynamic && (c.callRuntimeLoadScript || c.loadsChunkWithScript(record, sourceIndex)) {
+						record.CallRuntimeLoadScript = true
+						if c.unboundImportChunkRef == js_ast.InvalidRef {
+							loadScriptUses++
+						}
+						continue
+					}
+
+					// Check if it will be a "require()" call.
 					if record.Kind == ast.ImportRequire || !c.options.OutputFormat.KeepES6ImportExportSyntax() ||
 						(record.Kind == ast.ImportDynamic && c.options.UnsupportedJSFeatures.Has(compat.DynamicImport)) {
 						// We should use "__require" instead of "require" if we're not
@@ -1543,6 +1824,10 @@ func (c *linkerContext) scanImportsAndExports() {
 			// code for node, then substitute a "__require" wrapper for "require".
 			c.graph.GenerateRuntimeSymbolImportAndUse(sourceIndex, uint32(partIndex), "__require", runtimeRequireUses)
 
+			// If there are external "import()" expressions in a browser without
+			// "import()", then we need "__loadScript" to load them instead
+			c.graph.GenerateRuntimeSymbolImportAndUse(sourceIndex, uint32(partIndex), "__loadScript", loadScriptUses)
+
 			// If there's an ES6 export star statement of a non-ES6 module, then we're
 			// going to need the "__reExport" symbol from the runtime
 			reExportUses := uint32(0)
@@ -2276,6 +2561,28 @@ func (c *linkerContext) recursivelyWrapDependencies(sourceIndex uint32) {
 	}
 }
 
+// Returns true if this file is inside the directory for one of the named
+// packages in a "node_modules" directory
+func (c *linkerContext) isInsideOneOfPackages(path logger.Path, names []string) bool {
+	if path.Namespace != "file" {
+		return false
+	}
+	text := strings.ReplaceAll(path.Text, "\\", "/")
+	for _, name := range names {
+		if strings.Contains(text, "/node_modules/"+name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Lazy files are only initialized when one of their exports is first used.
+// This only works for ESM files that aren't affected by top-level await since
+// the initializer must be called synchronously.
+func (c *linkerContext) isLazyESM(repr *graph.JSRepr) bool {
+	return repr.Meta.IsLazy && repr.Meta.Wrap == graph.WrapESM && !repr.Meta.IsAsyncOrHasAsyncDependency
+}
+
 func (c *linkerContext) hasDynamicExportsDueToExportStar(sourceIndex uint32, visited map[uint32]bool) bool {
 	// Terminate the traversal now if this file already has dynamic exports
 	repr := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr)
@@ -2489,6 +2796,40 @@ func (c *linkerContext) advanceImportTracker(tracker importTracker) (importTrack
 	return importTracker{sourceIndex: otherSourceIndex}, importNoMatch, nil
 }
 
+// Members are removed by name. The parser has already determined which class
+// and object members don't escape from their file, so a member can be removed
+// if no live file in the bundle uses a property with the same name. Nothing is
+// removed if any live file can access properties without using their names.
+// The runtime is exempt since its helpers are only ever passed values that
+// have already escaped.
+func (c *linkerContext) findRemovedMembers() map[*js_ast.Property]bool {
+	namesUsed := make(map[string]bool)
+	for _, sourceIndex := range c.graph.ReachableFiles {
+		file := &c.graph.Files[sourceIndex]
+		if repr, ok := file.InputFile.Repr.(*graph.JSRepr); ok && file.IsLive {
+			if repr.AST.UsesDynamicPropertyAccess && sourceIndex != runtime.SourceIndex {
+				return nil
+			}
+			for name := range repr.AST.PropertyNamesUsed {
+				namesUsed[name] = true
+			}
+		}
+	}
+
+	removedMembers := make(map[*js_ast.Property]bool)
+	for _, sourceIndex := range c.graph.ReachableFiles {
+		file := &c.graph.Files[sourceIndex]
+		if repr, ok := file.InputFile.Repr.(*graph.JSRepr); ok && file.IsLive {
+			for _, member := range repr.AST.RemovableMembers {
+				if !namesUsed[member.Name] {
+					removedMembers[member.Property] = true
+				}
+			}
+		}
+	}
+	return removedMembers
+}
+
 func (c *linkerContext) treeShakingAndCodeSplitting() {
 	// Tree shaking: Each entry point marks all files reachable from itself
 	c.timer.Begin("Tree shaking")
@@ -2770,25 +3111,52 @@ func (c *linkerContext) findImportedCSSFilesInJSOrder(entryPoint uint32) (order
 //
 // If A imports B and then C, B imports D, and C imports D, then the CSS
 // traversal order is B D C A.
-func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (externalOrder []externalImportCSS, internalOrder []uint32) {
+//
+// Evaluating the same file in different cascade layers is not equivalent to
+// evaluating it once, so a file may appear once for each layer it's in.
+func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (
+	externalOrder []externalImportCSS, internalOrder []uint32, layerOrder [][]string, orderReasons []cssOrderReason,
+) {
 	type externalImportsCSS struct {
 		unconditional bool
 		conditions    [][]css_ast.Token
 	}
+	type visitKey struct {
+		sourceIndex uint32
+		layer       string
+	}
 
-	visited := make(map[uint32]bool)
+	visited := make(map[visitKey]int)
 	externals := make(map[logger.Path]externalImportsCSS)
-	var visit func(uint32, ast.Index32)
+	hasReport := c.options.AbsCSSOrderReportFile != ""
+	var visit func(uint32, ast.Index32, []string)
 
 	// Include this file and all files it imports
-	visit = func(sourceIndex uint32, importerIndex ast.Index32) {
-		if !visited[sourceIndex] {
-			visited[sourceIndex] = true
-			repr := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.CSSRepr)
+	visit = func(sourceIndex uint32, importerIndex ast.Index32, layer []string) {
+		key := visitKey{sourceIndex: sourceIndex, layer: cssLayerKey(layer)}
+		if index, ok := visited[key]; ok {
+			// Remember which imports were dropped for the CSS order report
+			if hasReport && importerIndex.IsValid() {
+				reason := &orderReasons[index]
+				reason.overriddenImporters = append(reason.overriddenImporters, importerIndex.GetIndex())
+			}
+		} else {
+			visited[key] = len(internalOrder)
+			file := &c.graph.Files[sourceIndex]
+			repr := file.InputFile.Repr.(*graph.CSSRepr)
 			topLevelRules := repr.AST.Rules
 
 			// Iterate in reverse preorder (will be reversed again later)
 			internalOrder = append(internalOrder, sourceIndex)
+			if hasReport {
+				orderReasons = append(orderReasons, cssOrderReason{importer: importerIndex})
+			}
+			if len(layer) > 0 && layerOrder == nil {
+				layerOrder = make([][]string, len(internalOrder)-1, cap(internalOrder))
+			}
+			if layerOrder != nil {
+				layerOrder = append(layerOrder, layer)
+			}
 
 			// Iterate in the inverse order of top-level "@import" rules
 		outer:
@@ -2796,7 +3164,9 @@ func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (exter
 				if atImport, ok := topLevelRules[i].Data.(*css_ast.RAtImport); ok {
 					if record := &repr.AST.ImportRecords[atImport.ImportRecordIndex]; record.SourceIndex.IsValid() {
 						// Follow internal dependencies
-						visit(record.SourceIndex.GetIndex(), ast.MakeIndex32(sourceIndex))
+						otherIndex := record.SourceIndex.GetIndex()
+						visit(otherIndex, ast.MakeIndex32(sourceIndex), c.cssImportedLayer(layer, file.InputFile.Source.KeyPath,
+							atImport.ImportConditions, c.graph.Files[otherIndex].InputFile.Source.KeyPath))
 					} else {
 						// Record external dependencies
 						external := externals[record.Path]
@@ -2808,23 +3178,27 @@ func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (exter
 							continue
 						}
 
-						if len(atImport.ImportConditions) == 0 {
+						// Imports from a file in a layer must stay in that layer
+						importConditions := c.cssExternalImportConditions(layer, atImport.ImportConditions,
+							sourceIndex, record.Range, record.Path.Text)
+
+						if len(importConditions) == 0 {
 							external.unconditional = true
 						} else {
 							// Check for a conditional import. A conditional import does not
 							// mask an earlier unconditional import because re-evaluating a
 							// CSS file can have observable results.
 							for _, tokens := range external.conditions {
-								if css_ast.TokensEqualIgnoringWhitespace(tokens, atImport.ImportConditions) {
+								if css_ast.TokensEqualIgnoringWhitespace(tokens, importConditions) {
 									continue outer
 								}
 							}
-							external.conditions = append(external.conditions, atImport.ImportConditions)
+							external.conditions = append(external.conditions, importConditions)
 						}
 
 						// Clone any import records associated with the condition tokens
 						conditions, conditionImportRecords := css_ast.CloneTokensWithImportRecords(
-							atImport.ImportConditions, repr.AST.ImportRecords, nil, nil)
+							importConditions, repr.AST.ImportRecords, nil, nil)
 
 						externals[record.Path] = external
 						externalOrder = append(externalOrder, externalImportCSS{
@@ -2835,18 +3209,35 @@ func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (exter
 					}
 				}
 			}
+
+			// Files referenced by "composes" declarations come before this file
+			for i := len(repr.AST.ImportRecords) - 1; i >= 0; i-- {
+				if record := &repr.AST.ImportRecords[i]; record.Kind == ast.ImportComposesFrom && record.SourceIndex.IsValid() {
+					otherIndex := record.SourceIndex.GetIndex()
+					if _, ok := c.graph.Files[otherIndex].InputFile.Repr.(*graph.CSSRepr); ok {
+						visit(otherIndex, ast.MakeIndex32(sourceIndex), layer)
+					}
+				}
+			}
 		}
 	}
 
 	// Include all files reachable from any entry point
 	for i := len(entryPoints) - 1; i >= 0; i-- {
-		visit(entryPoints[i], ast.Index32{})
+		visit(entryPoints[i], ast.Index32{}, c.cssImportedLayer(nil, logger.Path{}, nil,
+			c.graph.Files[entryPoints[i]].InputFile.Source.KeyPath))
 	}
 
 	// Reverse the order afterward when traversing in CSS order
 	for i, j := 0, len(internalOrder)-1; i < j; i, j = i+1, j-1 {
 		internalOrder[i], internalOrder[j] = internalOrder[j], internalOrder[i]
 	}
+	for i, j := 0, len(layerOrder)-1; i < j; i, j = i+1, j-1 {
+		layerOrder[i], layerOrder[j] = layerOrder[j], layerOrder[i]
+	}
+	for i, j := 0, len(orderReasons)-1; i < j; i, j = i+1, j-1 {
+		orderReasons[i], orderReasons[j] = orderReasons[j], orderReasons[i]
+	}
 	for i, j := 0, len(externalOrder)-1; i < j; i, j = i+1, j-1 {
 		externalOrder[i], externalOrder[j] = externalOrder[j], externalOrder[i]
 	}
@@ -2854,12 +3245,86 @@ func (c *linkerContext) findImportedFilesInCSSOrder(entryPoints []uint32) (exter
 	return
 }
 
+// The "vendor" splitting preset moves the runtime and all code from inside a
+// "node_modules" directory out of the chunks they would normally be in and
+// into dedicated "runtime" and "vendor" chunks. That way editing application
+// code doesn't change the hash of the vendor chunk, so it can stay cached.
+// Files matching an isolated chunk rule are moved into a chunk named after
+// the rule in the same way, which takes precedence over the preset. Manual
+// chunks take precedence over both. The chunk size limits only move files
+// that none of these apply to. Entry points are left alone since they always
+// get their own chunk.
+func (c *linkerContext) presetChunkNameForFile(sourceIndex uint32) string {
+	if c.options.SplittingPreset != config.SplittingPresetVendor && len(c.options.IsolatedChunks) == 0 &&
+		c.options.ManualChunks == nil && c.sizeLimitChunkNames == nil {
+		return ""
+	}
+	file := &c.graph.Files[sourceIndex]
+	if file.IsEntryPoint() {
+		return ""
+	}
+	if name := c.manualChunkNames[sourceIndex]; name != "" {
+		return name
+	}
+	if path := file.InputFile.Source.KeyPath; path.Namespace == "file" {
+		for _, rule := range c.options.IsolatedChunks {
+			if isolatedChunkMatches(rule, path.Text) {
+				return rule.Name
+			}
+		}
+	}
+	if c.options.SplittingPreset == config.SplittingPresetVendor {
+		if sourceIndex == runtime.SourceIndex {
+			return "runtime"
+		}
+		if file.InputFile.Source.KeyPath.Namespace == "file" && helpers.IsInsideNodeModules(file.InputFile.Source.KeyPath.Text) {
+			return "vendor"
+		}
+	}
+	return c.sizeLimitChunkNames[sourceIndex]
+}
+
+func isolatedChunkMatches(rule config.IsolatedChunk, path string) bool {
+	if rule.Package != "" {
+		return strings.Contains(strings.ReplaceAll(path, "\\", "/"), "/node_modules/"+rule.Package+"/")
+	}
+	prefix, suffix := rule.Pattern.Prefix, rule.Pattern.Suffix
+	if rule.IsWildcard {
+		return len(path) >= len(prefix)+len(suffix) && strings.HasPrefix(path, prefix) && strings.HasSuffix(path, suffix)
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return rest == "" || rest[0] == '/' || rest[0] == '\\'
+}
+
 func (c *linkerContext) computeChunks() []chunkInfo {
 	c.timer.Begin("Compute chunks")
 	defer c.timer.End("Compute chunks")
 
 	jsChunks := make(map[string]chunkInfo)
 	cssChunks := make(map[string]chunkInfo)
+	presetChunks := make(map[string]chunkInfo)
+
+	// Ask the "ManualChunks" callback about each file up front
+	if c.options.ManualChunks != nil {
+		c.manualChunkNames = make(map[uint32]string)
+		for _, sourceIndex := range c.graph.ReachableFiles {
+			if file := &c.graph.Files[sourceIndex]; file.IsLive && sourceIndex != runtime.SourceIndex {
+				if _, ok := file.InputFile.Repr.(*graph.JSRepr); ok {
+					if name := c.options.ManualChunks(file.InputFile.Source.KeyPath); name != "" {
+						c.manualChunkNames[sourceIndex] = name
+					}
+				}
+			}
+		}
+	}
+
+	// Merge small chunks and split large chunks
+	if c.options.CodeSplitting && (c.options.ChunkMinSize > 0 || c.options.ChunkMaxSize > 0) {
+		c.sizeLimitChunkNames = c.computeSizeLimitChunkNames()
+	}
 
 	// Create chunks for entry points
 	for i, entryPoint := range c.graph.EntryPoints() {
@@ -2890,7 +3355,7 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 			// consistent for dynamic imports. Then we run the CSS import order
 			// algorithm to determine the final CSS file order for the chunk.
 			if cssSourceIndices := c.findImportedCSSFilesInJSOrder(entryPoint.SourceIndex); len(cssSourceIndices) > 0 {
-				externalOrder, internalOrder := c.findImportedFilesInCSSOrder(cssSourceIndices)
+				externalOrder, internalOrder, layersInOrder, orderReasons := c.findImportedFilesInCSSOrder(cssSourceIndices)
 				cssFilesWithPartsInChunk := make(map[uint32]bool)
 				for _, sourceIndex := range internalOrder {
 					cssFilesWithPartsInChunk[uint32(sourceIndex)] = true
@@ -2904,18 +3369,24 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 					chunkRepr: &chunkReprCSS{
 						externalImportsInOrder: externalOrder,
 						filesInChunkInOrder:    internalOrder,
+						layersInChunkInOrder:   layersInOrder,
+						layerOrder:             c.findCSSLayerOrder(cssSourceIndices),
+						orderReasons:           orderReasons,
 					},
 				}
 			}
 
 		case *graph.CSSRepr:
-			externalOrder, internalOrder := c.findImportedFilesInCSSOrder([]uint32{entryPoint.SourceIndex})
+			externalOrder, internalOrder, layersInOrder, orderReasons := c.findImportedFilesInCSSOrder([]uint32{entryPoint.SourceIndex})
 			for _, sourceIndex := range internalOrder {
 				chunk.filesWithPartsInChunk[uint32(sourceIndex)] = true
 			}
 			chunk.chunkRepr = &chunkReprCSS{
 				externalImportsInOrder: externalOrder,
 				filesInChunkInOrder:    internalOrder,
+				layersInChunkInOrder:   layersInOrder,
+				layerOrder:             c.findCSSLayerOrder([]uint32{entryPoint.SourceIndex}),
+				orderReasons:           orderReasons,
 			}
 			cssChunks[key] = chunk
 		}
@@ -2925,6 +3396,28 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 	for _, sourceIndex := range c.graph.ReachableFiles {
 		if file := &c.graph.Files[sourceIndex]; file.IsLive {
 			if _, ok := file.InputFile.Repr.(*graph.JSRepr); ok {
+				// Files that a splitting preset moves into a named chunk don't go in
+				// the chunk for their entry bits. The entry bits of the named chunk
+				// are the union of the entry bits of all files in it, which means
+				// every entry point that needs any of these files will import it.
+				if name := c.presetChunkNameForFile(sourceIndex); name != "" {
+					chunk, ok := presetChunks[name]
+					if !ok {
+						chunk.entryBits = helpers.NewBitSet(uint(len(c.graph.EntryPoints())))
+						chunk.presetChunkName = name
+						chunk.filesWithPartsInChunk = make(map[uint32]bool)
+						chunk.chunkRepr = &chunkReprJS{}
+					}
+					for i := range c.graph.EntryPoints() {
+						if file.EntryBits.HasBit(uint(i)) {
+							chunk.entryBits.SetBit(uint(i))
+						}
+					}
+					chunk.filesWithPartsInChunk[uint32(sourceIndex)] = true
+					presetChunks[name] = chunk
+					continue
+				}
+
 				key := file.EntryBits.String()
 				chunk, ok := jsChunks[key]
 				if !ok {
@@ -2940,8 +3433,8 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 
 	// Sort the chunks for determinism. This matters because we use chunk indices
 	// as sorting keys in a few places.
-	sortedChunks := make([]chunkInfo, 0, len(jsChunks)+len(cssChunks))
-	sortedKeys := make([]string, 0, len(jsChunks)+len(cssChunks))
+	sortedChunks := make([]chunkInfo, 0, len(jsChunks)+len(presetChunks)+len(cssChunks))
+	sortedKeys := make([]string, 0, len(jsChunks)+len(presetChunks)+len(cssChunks))
 	for key := range jsChunks {
 		sortedKeys = append(sortedKeys, key)
 	}
@@ -2950,6 +3443,14 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 		sortedChunks = append(sortedChunks, jsChunks[key])
 	}
 	sortedKeys = sortedKeys[:0]
+	for key := range presetChunks {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+	for _, key := range sortedKeys {
+		sortedChunks = append(sortedChunks, presetChunks[key])
+	}
+	sortedKeys = sortedKeys[:0]
 	for key := range cssChunks {
 		sortedKeys = append(sortedKeys, key)
 	}
@@ -2967,9 +3468,13 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 
 			// JS entry points that import CSS files generate two chunks, a JS chunk
 			// and a CSS chunk. Don't link the CSS chunk to the JS file since the CSS
-			// chunk is secondary (the JS chunk is primary).
+			// chunk is secondary (the JS chunk is primary). Instead, link the CSS
+			// chunk to the JS chunk, which always comes first in sorted order.
 			if _, ok := chunk.chunkRepr.(*chunkReprCSS); ok {
 				if _, ok := file.InputFile.Repr.(*graph.JSRepr); ok {
+					jsChunk := &sortedChunks[file.EntryPointChunkIndex]
+					jsChunk.cssBundleChunkIndex = ast.MakeIndex32(uint32(chunkIndex))
+					jsChunk.injectsCSSBundle = c.options.InjectCSSLink
 					continue
 				}
 			}
@@ -3044,6 +3549,9 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 		} else {
 			dir = "/"
 			base = "chunk"
+			if chunk.presetChunkName != "" {
+				base = sizeLimitChunkBaseName(chunk.presetChunkName)
+			}
 			ext = stdExt
 			template = c.options.ChunkPathTemplate
 		}
@@ -3056,6 +3564,18 @@ func (c *linkerContext) computeChunks() []chunkInfo {
 			Name: &base,
 			Ext:  &templateExt,
 		})
+
+		// With a content manifest, every chunk is named by its hash alone. The
+		// path from the templates above is only used as the key in the manifest.
+		if c.options.AbsContentManifestFile != "" {
+			chunk.logicalTemplate = chunk.finalTemplate
+			chunk.finalTemplate = contentAddressedTemplate(ext)
+		}
+
+		// Chunks that aren't entry points can be moved to a shared directory
+		if !chunk.isEntryPoint && c.options.AbsSharedChunkDir != "" {
+			chunk.finalTemplate = c.templateInSharedChunkDir(chunk.finalTemplate)
+		}
 	}
 
 	return sortedChunks
@@ -3142,7 +3662,12 @@ func (c *linkerContext) findImportedPartsInJSOrder(chunk *chunkInfo) (js []uint3
 		file := &c.graph.Files[sourceIndex]
 
 		if repr, ok := file.InputFile.Repr.(*graph.JSRepr); ok {
-			isFileInThisChunk := chunk.entryBits.Equals(file.EntryBits)
+			var isFileInThisChunk bool
+			if name := c.presetChunkNameForFile(sourceIndex); name != "" || chunk.presetChunkName != "" {
+				isFileInThisChunk = name == chunk.presetChunkName
+			} else {
+				isFileInThisChunk = chunk.entryBits.Equals(file.EntryBits)
+			}
 
 			// Wrapped files can't be split because they are all inside the wrapper
 			canFileBeSplit := repr.Meta.Wrap == graph.WrapNone
@@ -3267,6 +3792,12 @@ func (c *linkerContext) shouldRemoveImportExportStmt(
 			break
 		}
 
+		// Lazy files are initialized where their imports are used instead. Bare
+		// imports don't have any imports to use, so they still initialize here.
+		if c.isLazyESM(otherRepr) && !record.WasOriginallyBareImport {
+			break
+		}
+
 		// Replace the statement with a call to "init()"
 		value := js_ast.Expr{Loc: loc, Data: &js_ast.ECall{Target: js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: otherRepr.AST.WrapperRef}}}}
 		if otherRepr.Meta.IsAsyncOrHasAsyncDependency {
@@ -3562,12 +4093,16 @@ type compileResultJS struct {
 	// This is the line and column offset since the previous JavaScript string
 	// or the start of the file if this is the first JavaScript string.
 	generatedOffset sourcemap.LineColumnOffset
+
+	// This is only present for the runtime when generating the metafile
+	helperSizes []helperSize
 }
 
 func (c *linkerContext) requireOrImportMetaForSource(sourceIndex uint32) (meta js_printer.RequireOrImportMeta) {
 	repr := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr)
 	meta.WrapperRef = repr.AST.WrapperRef
 	meta.IsWrapperAsync = repr.Meta.IsAsyncOrHasAsyncDependency
+	meta.IsLazy = c.isLazyESM(repr)
 	if repr.Meta.Wrap == graph.WrapESM {
 		meta.ExportsRef = repr.AST.ExportsRef
 	} else {
@@ -3584,6 +4119,7 @@ func (c *linkerContext) generateCodeForFileInChunkJS(
 	chunkAbsDir string,
 	toModuleRef js_ast.Ref,
 	runtimeRequireRef js_ast.Ref,
+	loadScriptRef js_ast.Ref,
 	result *compileResultJS,
 	dataForSourceMaps []dataForSourceMap,
 ) {
@@ -3777,32 +4313,50 @@ func (c *linkerContext) generateCodeForFileInChunkJS(
 
 	// Indent the file if everything is wrapped in an IIFE
 	indent := 0
-	if c.options.OutputFormat == config.FormatIIFE {
+	if c.options.OutputFormat.IsWrappedInFunction() {
 		indent++
 	}
 
 	// Convert the AST to JavaScript code
 	printOptions := js_printer.Options{
 		Indent:                       indent,
+		IndentUnit:                   c.options.IndentUnit,
 		OutputFormat:                 c.options.OutputFormat,
 		RemoveWhitespace:             c.options.RemoveWhitespace,
 		MangleSyntax:                 c.options.MangleSyntax,
 		ASCIIOnly:                    c.options.ASCIIOnly,
+		CharsetEscapes:               c.options.CharsetEscapes,
+		IdentifierCharset:            c.options.IdentifierCharset,
 		ToModuleRef:                  toModuleRef,
 		RuntimeRequireRef:            runtimeRequireRef,
+		LoadScriptRef:                loadScriptRef,
+		CallRuntimeLoadScript:        c.callRuntimeLoadScript,
+		LoadScriptAsModule:           c.options.OutputFormat == config.FormatESModule,
+		ExternalGlobals:              c.options.ExternalGlobals,
 		LegalComments:                c.options.LegalComments,
+		Annotations:                  c.options.AnnotationsForFormat(c.options.OutputFormat),
+		MagicComments:                c.options.MagicComments,
 		UnsupportedFeatures:          c.options.UnsupportedJSFeatures,
 		AddSourceMappings:            addSourceMappings,
 		InputSourceMap:               inputSourceMap,
 		LineOffsetTables:             lineOffsetTables,
 		RequireOrImportMetaForSource: c.requireOrImportMetaForSource,
+		RemovedMembers:               c.removedMembers,
+		MangledProps:                 c.options.MangledPropNames,
+	}
+	// Measure esbuild's own helpers separately for the metafile
+	var helperSizes []helperSize
+	if partRange.sourceIndex == runtime.SourceIndex && c.options.NeedsMetafile {
+		helperSizes = c.measureHelpersJS(r, partRange, printOptions)
 	}
+
 	tree := repr.AST
 	tree.Directive = "" // This is handled elsewhere
 	tree.Parts = []js_ast.Part{{Stmts: stmts}}
 	*result = compileResultJS{
 		PrintResult: js_printer.Print(tree, c.graph.Symbols, r, printOptions),
 		sourceIndex: partRange.sourceIndex,
+		helperSizes: helperSizes,
 	}
 
 	waitGroup.Done()
@@ -3827,9 +4381,13 @@ func (c *linkerContext) generateEntryPointTailJS(
 			}}}})
 		}
 
-	case config.FormatIIFE:
+	case config.FormatIIFE, config.FormatUMD:
+		// The UMD factory function always returns the exports, and chunks loaded
+		// by another chunk return their exports to the chunk loader
+		returnsExports := len(c.options.GlobalName) > 0 || c.options.OutputFormat == config.FormatUMD || c.options.CodeSplitting
+
 		if repr.Meta.Wrap == graph.WrapCJS {
-			if len(c.options.GlobalName) > 0 {
+			if returnsExports {
 				// "return require_foo();"
 				stmts = append(stmts, js_ast.Stmt{Data: &js_ast.SReturn{ValueOrNil: js_ast.Expr{Data: &js_ast.ECall{
 					Target: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: repr.AST.WrapperRef}},
@@ -3847,7 +4405,7 @@ func (c *linkerContext) generateEntryPointTailJS(
 					Target: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: repr.AST.WrapperRef}},
 				}}}})
 			}
-			if repr.Meta.ForceIncludeExportsForEntryPoint && len(c.options.GlobalName) > 0 {
+			if repr.Meta.ForceIncludeExportsForEntryPoint && returnsExports {
 				// "return exports;"
 				stmts = append(stmts, js_ast.Stmt{Data: &js_ast.SReturn{
 					ValueOrNil: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: repr.AST.ExportsRef}},
@@ -3878,8 +4436,11 @@ func (c *linkerContext) generateEntryPointTailJS(
 		// a form that node can understand them. This relies on the specific behavior
 		// of this parser, which the node project uses to detect named exports in
 		// CommonJS files: https://github.com/guybedford/cjs-module-lexer. Think of
-		// this code as an annotation for that parser.
-		if c.options.Platform == config.PlatformNode && len(repr.Meta.SortedAndFilteredExportAliases) > 0 {
+		// this code as an annotation for that parser. Dual packages are always
+		// meant for node, so they always get this annotation. It's omitted if
+		// annotations have been turned off for this output format.
+		if (c.options.Platform == config.PlatformNode || c.options.DualPackage) && len(repr.Meta.SortedAndFilteredExportAliases) > 0 &&
+			c.options.AnnotationsForFormat(c.options.OutputFormat) != config.AnnotationsNever {
 			// Add a comment since otherwise people will surely wonder what this is.
 			// This annotation means you can do this and have it work:
 			//
@@ -3979,6 +4540,7 @@ func (c *linkerContext) generateEntryPointTailJS(
 				// export statement that's not top-level. Instead, we will export the CommonJS
 				// exports as a default export later on.
 				var items []js_ast.ClauseItem
+				initializedLazyFiles := make(map[uint32]bool)
 
 				for i, alias := range repr.Meta.SortedAndFilteredExportAliases {
 					export := repr.Meta.ResolvedExports[alias]
@@ -4065,6 +4627,14 @@ func (c *linkerContext) generateEntryPointTailJS(
 						//     foo
 						//   };
 						//
+						// An export clause can't initialize a lazy file when the export is
+						// first used, so lazy files are initialized before being exported.
+						if otherRepr := c.graph.Files[export.SourceIndex].InputFile.Repr.(*graph.JSRepr); export.SourceIndex != sourceIndex &&
+							c.isLazyESM(otherRepr) && !initializedLazyFiles[export.SourceIndex] {
+							initializedLazyFiles[export.SourceIndex] = true
+							stmts = append(stmts, js_ast.Stmt{Data: &js_ast.SExpr{Value: js_ast.Expr{Data: &js_ast.ECall{
+								Target: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: otherRepr.AST.WrapperRef}}}}}})
+						}
 						items = append(items, js_ast.ClauseItem{
 							Name:  js_ast.LocRef{Ref: export.Ref},
 							Alias: alias,
@@ -4086,19 +4656,24 @@ func (c *linkerContext) generateEntryPointTailJS(
 
 	// Indent the file if everything is wrapped in an IIFE
 	indent := 0
-	if c.options.OutputFormat == config.FormatIIFE {
+	if c.options.OutputFormat.IsWrappedInFunction() {
 		indent++
 	}
 
 	// Convert the AST to JavaScript code
 	printOptions := js_printer.Options{
 		Indent:                       indent,
+		IndentUnit:                   c.options.IndentUnit,
 		OutputFormat:                 c.options.OutputFormat,
 		RemoveWhitespace:             c.options.RemoveWhitespace,
 		MangleSyntax:                 c.options.MangleSyntax,
 		ASCIIOnly:                    c.options.ASCIIOnly,
+		CharsetEscapes:               c.options.CharsetEscapes,
+		IdentifierCharset:            c.options.IdentifierCharset,
 		ToModuleRef:                  toModuleRef,
 		LegalComments:                c.options.LegalComments,
+		Annotations:                  c.options.AnnotationsForFormat(c.options.OutputFormat),
+		MagicComments:                c.options.MagicComments,
 		UnsupportedFeatures:          c.options.UnsupportedJSFeatures,
 		RequireOrImportMetaForSource: c.requireOrImportMetaForSource,
 	}
@@ -4128,6 +4703,24 @@ func (c *linkerContext) renameSymbolsInChunk(chunk *chunkInfo, filesInOrder []ui
 		reservedNames["require"] = 1
 		reservedNames["Promise"] = 1
 	}
+
+	// Cross-chunk exports are assigned to "module.exports", but the runtime
+	// (which would otherwise reserve this name) may be in another chunk
+	if c.options.OutputFormat == config.FormatCommonJS && c.options.CodeSplitting {
+		reservedNames["module"] = 1
+	}
+
+	// Chunks in the "iife" format are passed a function that loads other chunks
+	if c.unboundImportChunkRef != js_ast.InvalidRef {
+		reservedNames["__importChunk"] = 1
+	}
+
+	// Global variables that external imports are read from must not be shadowed
+	if c.options.OutputFormat != config.FormatESModule {
+		for _, parts := range c.options.ExternalGlobals {
+			reservedNames[parts[0]] = 1
+		}
+	}
 	timer.End("Compute reserved names")
 
 	// Make sure imports get a chance to be renamed too
@@ -4213,6 +4806,13 @@ func (c *linkerContext) renameSymbolsInChunk(chunk *chunkInfo, filesInOrder []ui
 		timer.End("Serial phase")
 		timer.End("Accumulate symbol counts")
 
+		// Give top-level symbols the names they had in the previous build
+		if c.options.NameCache != nil {
+			r.AssignCachedNames(func(ref js_ast.Ref) string {
+				return c.graph.Files[ref.SourceIndex].InputFile.Source.PrettyPath + ":" + c.graph.Symbols.Get(ref).OriginalName
+			}, c.options.NameCache.Get)
+		}
+
 		// Add all of the character frequency histograms for all files in this
 		// chunk together, then use it to compute the character sequence used to
 		// generate minified names. This results in slightly better gzip compression
@@ -4220,9 +4820,23 @@ func (c *linkerContext) renameSymbolsInChunk(chunk *chunkInfo, filesInOrder []ui
 		// it's a very small win, we still do it because it's simple to do and very
 		// cheap to compute.
 		minifier := freq.Compile()
+		if c.options.NameCache != nil {
+			// The character frequencies change whenever any code in the chunk
+			// changes, which would give most nested symbols new names. Don't use
+			// them with a name cache since the goal is to minimize changes.
+			minifier = js_ast.DefaultNameMinifier
+		}
+		if c.options.MinifySeed != "" {
+			hash := xxhash.New()
+			hash.Write([]byte(c.options.MinifySeed))
+			minifier = minifier.Shuffle(hash.Sum64())
+		}
 		timer.Begin("Assign names by frequency")
 		r.AssignNamesByFrequency(&minifier)
 		timer.End("Assign names by frequency")
+		if c.options.NameCache != nil {
+			c.options.NameCache.Update(r.TopLevelNamesByKey())
+		}
 		return r
 	}
 
@@ -4358,6 +4972,12 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	runtimeMembers := c.graph.Files[runtime.SourceIndex].InputFile.Repr.(*graph.JSRepr).AST.ModuleScope.Members
 	toModuleRef := js_ast.FollowSymbols(c.graph.Symbols, runtimeMembers["__toModule"].Ref)
 	runtimeRequireRef := js_ast.FollowSymbols(c.graph.Symbols, runtimeMembers["__require"].Ref)
+	loadScriptRef := js_ast.InvalidRef
+	if c.unboundImportChunkRef != js_ast.InvalidRef {
+		loadScriptRef = c.unboundImportChunkRef
+	} else if c.callRuntimeLoadScript {
+		loadScriptRef = js_ast.FollowSymbols(c.graph.Symbols, runtimeMembers["__loadScript"].Ref)
+	}
 	r := c.renameSymbolsInChunk(chunk, chunkRepr.filesInChunkInOrder, timer)
 	dataForSourceMaps := c.dataForSourceMaps()
 
@@ -4373,7 +4993,18 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	// Generate JavaScript for each file in parallel
 	timer.Begin("Print JavaScript files")
 	waitGroup := sync.WaitGroup{}
+	hasExternalHelpersImport := false
 	for _, partRange := range chunkRepr.partsInChunkInOrder {
+		// Import all helpers used by this chunk in one place instead of including
+		// them. This goes where the first part of the runtime would have been.
+		if partRange.sourceIndex == runtime.SourceIndex && c.options.ExternalHelpers != "" {
+			if !hasExternalHelpersImport {
+				hasExternalHelpersImport = true
+				compileResults = append(compileResults, c.generateExternalHelpersImportJS(r, chunkRepr.partsInChunkInOrder))
+			}
+			continue
+		}
+
 		// Skip the runtime in test output
 		if partRange.sourceIndex == runtime.SourceIndex && c.options.OmitRuntimeForTests {
 			continue
@@ -4391,6 +5022,7 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 			chunkAbsDir,
 			toModuleRef,
 			runtimeRequireRef,
+			loadScriptRef,
 			compileResult,
 			dataForSourceMaps,
 		)
@@ -4402,11 +5034,12 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	{
 		// Indent the file if everything is wrapped in an IIFE
 		indent := 0
-		if c.options.OutputFormat == config.FormatIIFE {
+		if c.options.OutputFormat.IsWrappedInFunction() {
 			indent++
 		}
 		printOptions := js_printer.Options{
 			Indent:           indent,
+			IndentUnit:       c.options.IndentUnit,
 			OutputFormat:     c.options.OutputFormat,
 			RemoveWhitespace: c.options.RemoveWhitespace,
 			MangleSyntax:     c.options.MangleSyntax,
@@ -4425,6 +5058,11 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		crossChunkSuffix = js_printer.Print(js_ast.AST{
 			Parts: []js_ast.Part{{Stmts: chunkRepr.crossChunkSuffixStmts}},
 		}, c.graph.Symbols, r, printOptions).JS
+
+		// Chunks in the "iife" format run inside of a call to the chunk loader
+		if c.options.OutputFormat == config.FormatIIFE && c.options.CodeSplitting {
+			crossChunkPrefix, crossChunkSuffix = c.generateChunkLoaderJS(chunks, chunkRepr, r)
+		}
 	}
 
 	// Generate the exports for the entry point, if there are any
@@ -4476,8 +5114,17 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		}
 	}
 
+	var compileResultsForSourceMap []compileResultForSourceMap
+	mappedEnd := uint32(0)
 	if len(c.options.JSBanner) > 0 {
-		prevOffset.AdvanceString(c.options.JSBanner)
+		chunk.bannerLine = prevOffset.Lines
+		if c.mapsBannersToVirtualSources() {
+			compileResultsForSourceMap = appendVirtualSourceMapping(compileResultsForSourceMap, bannerVirtualSource, c.options.JSBanner, prevOffset)
+			prevOffset = sourcemap.LineColumnOffset{}
+			mappedEnd = j.Length() + uint32(len(c.options.JSBanner))
+		} else {
+			prevOffset.AdvanceString(c.options.JSBanner)
+		}
 		prevOffset.AdvanceString("\n")
 		j.AddString(c.options.JSBanner)
 		j.AddString("\n")
@@ -4486,7 +5133,7 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	// Optionally wrap with an IIFE
 	if c.options.OutputFormat == config.FormatIIFE {
 		var text string
-		indent = "  "
+		indent = c.indentUnit()
 		if len(c.options.GlobalName) > 0 {
 			text = c.generateGlobalNamePrefix()
 		}
@@ -4498,6 +5145,12 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		prevOffset.AdvanceString(text)
 		j.AddString(text)
 		newlineBeforeComment = false
+	} else if c.options.OutputFormat == config.FormatUMD {
+		indent = c.indentUnit()
+		text := c.generateUMDPrefix()
+		prevOffset.AdvanceString(text)
+		j.AddString(text)
+		newlineBeforeComment = false
 	}
 
 	// Put the cross-chunk prefix inside the IIFE
@@ -4507,6 +5160,14 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		j.AddBytes(crossChunkPrefix)
 	}
 
+	// Add the CSS bundle to the document before running any code in this chunk
+	if chunk.injectsCSSBundle {
+		text := c.generateCSSLinkPrologue(chunks[chunk.cssBundleChunkIndex.GetIndex()].uniqueKey, indent, space, newline)
+		newlineBeforeComment = true
+		prevOffset.AdvanceString(text)
+		j.AddString(text)
+	}
+
 	// Start the metadata
 	jMeta := helpers.Joiner{}
 	if c.options.NeedsMetafile {
@@ -4564,25 +5225,37 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		}
 		if chunk.isEntryPoint {
 			entryPoint := c.graph.Files[chunk.sourceIndex].InputFile.Source.PrettyPath
-			jMeta.AddString(fmt.Sprintf("],\n      \"entryPoint\": %s,\n      \"inputs\": {", js_printer.QuoteForJSON(entryPoint, c.options.ASCIIOnly)))
+			jMeta.AddString(fmt.Sprintf("],\n      \"entryPoint\": %s", js_printer.QuoteForJSON(entryPoint, c.options.ASCIIOnly)))
+			if chunk.cssBundleChunkIndex.IsValid() {
+				cssBundle := c.res.PrettyPath(logger.Path{Text: chunks[chunk.cssBundleChunkIndex.GetIndex()].uniqueKey, Namespace: "file"})
+				jMeta.AddString(fmt.Sprintf(",\n      \"cssBundle\": %s", js_printer.QuoteForJSON(cssBundle, c.options.ASCIIOnly)))
+			}
+			jMeta.AddString(",\n      \"inputs\": {")
 		} else {
 			jMeta.AddString("],\n      \"inputs\": {")
 		}
 	}
 
 	// Concatenate the generated JavaScript chunks together
-	var compileResultsForSourceMap []compileResultForSourceMap
 	var legalCommentList []string
 	var metaOrder []uint32
 	var metaByteCount map[string]int
+	var metaHelperByteCount map[string]int
+	var moduleMapByteRanges []moduleMapByteRange
 	legalCommentSet := make(map[string]bool)
 	prevFileNameComment := uint32(0)
 	if c.options.NeedsMetafile {
 		metaOrder = make([]uint32, 0, len(compileResults))
 		metaByteCount = make(map[string]int, len(compileResults))
+		metaHelperByteCount = make(map[string]int)
 	}
 	for _, compileResult := range compileResults {
 		isRuntime := compileResult.sourceIndex == runtime.SourceIndex
+		if c.options.NeedsMetafile {
+			for _, helper := range compileResult.helperSizes {
+				metaHelperByteCount[helper.name] += helper.bytes
+			}
+		}
 		for text := range compileResult.ExtractedLegalComments {
 			if !legalCommentSet[text] {
 				legalCommentSet[text] = true
@@ -4621,6 +5294,13 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 		} else {
 			// Save the offset to the start of the stored JavaScript
 			compileResult.generatedOffset = prevOffset
+			if c.options.ModuleMap && len(compileResult.JS) > 0 {
+				moduleMapByteRanges = append(moduleMapByteRanges, moduleMapByteRange{
+					sourceIndex: compileResult.sourceIndex,
+					start:       j.Length(),
+					end:         j.Length() + uint32(len(compileResult.JS)),
+				})
+			}
 			j.AddBytes(compileResult.JS)
 
 			// Ignore empty source map chunks
@@ -4628,6 +5308,7 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 				prevOffset.AdvanceBytes(compileResult.JS)
 			} else {
 				prevOffset = sourcemap.LineColumnOffset{}
+				mappedEnd = j.Length()
 
 				// Include this file in the source map
 				if c.options.SourceMap != config.SourceMapNone {
@@ -4674,6 +5355,8 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	// Optionally wrap with an IIFE
 	if c.options.OutputFormat == config.FormatIIFE {
 		j.AddString("})();" + newline)
+	} else if c.options.OutputFormat == config.FormatUMD {
+		j.AddString("});" + newline)
 	}
 
 	// Make sure the file ends with a newline
@@ -4681,8 +5364,13 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 	maybeAppendLegalComments(c.options.LegalComments, legalCommentList, chunk, &j, "/script")
 
 	if len(c.options.JSFooter) > 0 {
-		j.AddString(c.options.JSFooter)
-		j.AddString("\n")
+		c.appendFooter(&j, &compileResultsForSourceMap, chunk, c.options.JSFooter, mappedEnd)
+	}
+
+	// Convert the module map to line and column offsets while the JavaScript
+	// contents are still in one piece
+	if c.options.ModuleMap {
+		chunk.moduleMapRanges = moduleMapRangesFromByteRanges(j.Done(), moduleMapByteRanges)
 	}
 
 	// The JavaScript contents are done now that the source map comment is in
@@ -4715,7 +5403,27 @@ func (c *linkerContext) generateChunkJS(chunks []chunkInfo, chunkIndex int, chun
 			if !isFirstMeta {
 				jMeta.AddString("\n      ")
 			}
-			jMeta.AddString(fmt.Sprintf("},\n      \"bytes\": %d\n    }", finalOutputSize))
+			jMeta.AddString("}")
+
+			// Report how much of this output is esbuild's own helpers
+			if len(metaHelperByteCount) > 0 {
+				names := make([]string, 0, len(metaHelperByteCount))
+				for name := range metaHelperByteCount {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				jMeta.AddString(",\n      \"helpers\": {")
+				for i, name := range names {
+					if i > 0 {
+						jMeta.AddString(",")
+					}
+					jMeta.AddString(fmt.Sprintf("\n        %s: {\n          \"bytesInOutput\": %d\n        }",
+						js_printer.QuoteForJSON(name, c.options.ASCIIOnly), metaHelperByteCount[name]))
+				}
+				jMeta.AddString("\n      }")
+			}
+
+			jMeta.AddString(fmt.Sprintf(",\n      \"bytes\": %d\n    }", finalOutputSize))
 			return jMeta
 		}
 	}
@@ -4762,6 +5470,116 @@ func (c *linkerContext) generateGlobalNamePrefix() string {
 	return text
 }
 
+// This generates the start of the UMD wrapper. The factory function that
+// contains the code is passed as an argument, so it can't see the wrapper's
+// "root" and "factory" parameters.
+func (c *linkerContext) generateUMDPrefix() string {
+	space := " "
+	newline := "\n"
+	indent := c.indentUnit()
+	if c.options.RemoveWhitespace {
+		space = ""
+		newline = ""
+		indent = ""
+	}
+
+	// Script tags assign the exports to the global name, if there is one
+	global := "factory()"
+	if len(c.options.GlobalName) > 0 {
+		var chain []string
+		prefix := "root"
+		for i, name := range c.options.GlobalName {
+			if js_printer.CanEscapeIdentifier(name, c.options.UnsupportedJSFeatures, c.options.ASCIIOnly) {
+				if c.options.ASCIIOnly {
+					name = string(js_printer.QuoteIdentifier(nil, name, c.options.UnsupportedJSFeatures))
+				}
+				prefix = fmt.Sprintf("%s.%s", prefix, name)
+			} else {
+				prefix = fmt.Sprintf("%s[%s]", prefix, js_printer.QuoteForJSON(name, c.options.ASCIIOnly))
+			}
+			if i+1 < len(c.options.GlobalName) {
+				chain = append(chain, fmt.Sprintf("%s%s=%s%s%s||%s{}", prefix, space, space, prefix, space, space))
+			} else {
+				chain = append(chain, fmt.Sprintf("%s%s=%sfactory()", prefix, space, space))
+			}
+		}
+		global = strings.Join(chain, ","+space)
+	}
+
+	factory := "()" + space + "=>" + space + "{"
+	if c.options.UnsupportedJSFeatures.Has(compat.Arrow) {
+		factory = "function()" + space + "{"
+	}
+
+	return fmt.Sprintf("(function(root,%sfactory)%s{%s", space, space, newline) +
+		fmt.Sprintf("%sif%s(typeof define%s===%s\"function\"%s&&%sdefine.amd)%sdefine([],%sfactory);%s",
+			indent, space, space, space, space, space, space, space, newline) +
+		fmt.Sprintf("%selse if%s(typeof module%s===%s\"object\"%s&&%smodule.exports)%smodule.exports%s=%sfactory();%s",
+			indent, space, space, space, space, space, space, space, space, newline) +
+		fmt.Sprintf("%selse %s;%s", indent, global, newline) +
+		fmt.Sprintf("})(typeof self%s!==%s\"undefined\"%s?%sself%s:%sthis,%s%s%s",
+			space, space, space, space, space, space, space, factory, newline)
+}
+
+// This generates code that adds a "<link>" tag for a CSS chunk to the document.
+// The path to the CSS chunk is resolved relative to the URL of the JS chunk so
+// that it works no matter which page the JS chunk is loaded from.
+func (c *linkerContext) indentUnit() string {
+	if c.options.IndentUnit != "" {
+		return c.options.IndentUnit
+	}
+	return "  "
+}
+
+// All code is generated with "\n" line endings and then converted at the end
+// if necessary. Any "\r\n" sequences that are already present are left alone.
+func (c *linkerContext) convertLineEndings(contents []byte) []byte {
+	if c.options.LineEnding != config.LineEndingCRLF {
+		return contents
+	}
+	count := 0
+	for i, b := range contents {
+		if b == '\n' && (i == 0 || contents[i-1] != '\r') {
+			count++
+		}
+	}
+	if count == 0 {
+		return contents
+	}
+	result := make([]byte, 0, len(contents)+count)
+	for i, b := range contents {
+		if b == '\n' && (i == 0 || contents[i-1] != '\r') {
+			result = append(result, '\r')
+		}
+		result = append(result, b)
+	}
+	return result
+}
+
+func (c *linkerContext) generateCSSLinkPrologue(cssUniqueKey string, indent string, space string, newline string) string {
+	var base string
+	if c.options.OutputFormat == config.FormatESModule {
+		base = "import.meta.url"
+	} else {
+		base = fmt.Sprintf("document.currentScript%s&&%sdocument.currentScript.src%s||%slocation.href", space, space, space, space)
+	}
+	inner := indent + c.indentUnit()
+	if c.options.RemoveWhitespace {
+		indent = ""
+		inner = ""
+	}
+	lines := []string{
+		indent + "(function()" + space + "{",
+		inner + "if" + space + "(typeof document" + space + "===" + space + "\"undefined\")" + space + "return;",
+		inner + "var link" + space + "=" + space + "document.createElement(\"link\");",
+		inner + "link.rel" + space + "=" + space + "\"stylesheet\";",
+		inner + "link.href" + space + "=" + space + "new URL(" + string(js_printer.QuoteForJSON(cssUniqueKey, c.options.ASCIIOnly)) + "," + space + base + ").href;",
+		inner + "document.head.appendChild(link);",
+		indent + "})();",
+	}
+	return strings.Join(lines, newline) + newline
+}
+
 type compileResultCSS struct {
 	css_printer.PrintResult
 
@@ -4770,8 +5588,6 @@ type compileResultCSS struct {
 	// This is the line and column offset since the previous CSS string
 	// or the start of the file if this is the first CSS string.
 	generatedOffset sourcemap.LineColumnOffset
-
-	hasCharset bool
 }
 
 func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chunkWaitGroup *sync.WaitGroup) {
@@ -4788,9 +5604,73 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 	}
 
 	chunkRepr := chunk.chunkRepr.(*chunkReprCSS)
-	compileResults := make([]compileResultCSS, len(chunkRepr.filesInChunkInOrder))
 	dataForSourceMaps := c.dataForSourceMaps()
 
+	// Filter out "@charset" and "@import" rules
+	pieces := make([]cssLayerPiece, 0, len(chunkRepr.filesInChunkInOrder))
+	hasCharset := false
+	hasLayers := len(chunkRepr.layerOrder) > 0 || chunkRepr.layersInChunkInOrder != nil
+	for i, sourceIndex := range chunkRepr.filesInChunkInOrder {
+		ast := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.CSSRepr).AST
+		rules := make([]css_ast.Rule, 0, len(ast.Rules))
+		for _, rule := range ast.Rules {
+			switch rule.Data.(type) {
+			case *css_ast.RAtCharset:
+				hasCharset = true
+				continue
+			case *css_ast.RAtImport:
+				continue
+			case *css_ast.RAtLayer:
+				hasLayers = true
+			}
+			rules = append(rules, rule)
+		}
+		piece := cssLayerPiece{sourceIndex: sourceIndex, rules: rules}
+		if chunkRepr.layersInChunkInOrder != nil {
+			piece.layer = chunkRepr.layersInChunkInOrder[i]
+		}
+		pieces = append(pieces, piece)
+	}
+
+	// Put each file in the cascade layer that it was imported into, or remove
+	// all layers if they aren't supported and it's safe to do so
+	layerOrder := chunkRepr.layerOrder
+	if hasLayers {
+		isLowered := false
+		if c.options.UnsupportedCSSFeatures.Has(compat.CascadeLayers) {
+			var lowered []cssLayerPiece
+			reason := ""
+			for _, external := range chunkRepr.externalImportsInOrder {
+				if _, _, ok := css_ast.ImportConditionsLayer(external.conditions); ok {
+					reason = "it contains an external import in a layer"
+					break
+				}
+			}
+			if reason == "" {
+				lowered, reason = lowerCSSLayers(layerOrder, pieces)
+			}
+			if reason == "" {
+				pieces = lowered
+				layerOrder = nil
+				isLowered = true
+			} else {
+				where := "the configured target environment"
+				if c.options.OriginalTargetEnv != "" {
+					where = fmt.Sprintf("%s (%s)", where, c.options.OriginalTargetEnv)
+				}
+				c.log.Add(logger.Warning, nil, logger.Range{}, fmt.Sprintf(
+					"Cannot safely remove \"@layer\" rules from the CSS for %q for %s because %s",
+					c.graph.Files[chunk.sourceIndex].InputFile.Source.PrettyPath, where, reason))
+			}
+		}
+		if !isLowered {
+			for i, piece := range pieces {
+				pieces[i].rules = wrapRulesInCSSLayer(piece.rules, piece.layer)
+			}
+		}
+	}
+	compileResults := make([]compileResultCSS, len(pieces))
+
 	// Note: This contains placeholders instead of what the placeholders are
 	// substituted with. That should be fine though because this should only
 	// ever be used for figuring out how many "../" to add to a relative path
@@ -4803,28 +5683,14 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 	// Generate CSS for each file in parallel
 	timer.Begin("Print CSS files")
 	waitGroup := sync.WaitGroup{}
-	for i, sourceIndex := range chunkRepr.filesInChunkInOrder {
+	for i, piece := range pieces {
 		// Create a goroutine for this file
 		waitGroup.Add(1)
-		go func(sourceIndex uint32, compileResult *compileResultCSS) {
+		go func(sourceIndex uint32, rules []css_ast.Rule, compileResult *compileResultCSS) {
 			defer c.recoverInternalError(&waitGroup, sourceIndex)
 
 			file := &c.graph.Files[sourceIndex]
 			ast := file.InputFile.Repr.(*graph.CSSRepr).AST
-
-			// Filter out "@charset" and "@import" rules
-			rules := make([]css_ast.Rule, 0, len(ast.Rules))
-			hasCharset := false
-			for _, rule := range ast.Rules {
-				switch rule.Data.(type) {
-				case *css_ast.RAtCharset:
-					hasCharset = true
-					continue
-				case *css_ast.RAtImport:
-					continue
-				}
-				rules = append(rules, rule)
-			}
 			ast.Rules = rules
 
 			// Only generate a source map if needed
@@ -4840,18 +5706,20 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 			cssOptions := css_printer.Options{
 				RemoveWhitespace:  c.options.RemoveWhitespace,
 				ASCIIOnly:         c.options.ASCIIOnly,
+				CharsetEscapes:    c.options.CharsetEscapes,
 				LegalComments:     c.options.LegalComments,
+				IndentUnit:        c.options.IndentUnit,
 				AddSourceMappings: addSourceMappings,
 				InputSourceMap:    inputSourceMap,
 				LineOffsetTables:  lineOffsetTables,
+				LocalNames:        file.InputFile.Repr.(*graph.CSSRepr).LocalNames,
 			}
 			*compileResult = compileResultCSS{
 				PrintResult: css_printer.Print(ast, cssOptions),
 				sourceIndex: sourceIndex,
-				hasCharset:  hasCharset,
 			}
 			waitGroup.Done()
-		}(sourceIndex, &compileResults[i])
+		}(piece.sourceIndex, piece.rules, &compileResults[i])
 	}
 
 	waitGroup.Wait()
@@ -4861,8 +5729,17 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 	prevOffset := sourcemap.LineColumnOffset{}
 	newlineBeforeComment := false
 
+	var compileResultsForSourceMap []compileResultForSourceMap
+	mappedEnd := uint32(0)
 	if len(c.options.CSSBanner) > 0 {
-		prevOffset.AdvanceString(c.options.CSSBanner)
+		chunk.bannerLine = prevOffset.Lines
+		if c.mapsBannersToVirtualSources() {
+			compileResultsForSourceMap = appendVirtualSourceMapping(compileResultsForSourceMap, bannerVirtualSource, c.options.CSSBanner, prevOffset)
+			prevOffset = sourcemap.LineColumnOffset{}
+			mappedEnd = j.Length() + uint32(len(c.options.CSSBanner))
+		} else {
+			prevOffset.AdvanceString(c.options.CSSBanner)
+		}
 		j.AddString(c.options.CSSBanner)
 		prevOffset.AdvanceString("\n")
 		j.AddString("\n")
@@ -4873,11 +5750,15 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 		tree := css_ast.AST{}
 
 		// "@charset" is the only thing that comes before "@import"
-		for _, compileResult := range compileResults {
-			if compileResult.hasCharset {
-				tree.Rules = append(tree.Rules, css_ast.Rule{Data: &css_ast.RAtCharset{Encoding: "UTF-8"}})
-				break
-			}
+		if hasCharset {
+			tree.Rules = append(tree.Rules, css_ast.Rule{Data: &css_ast.RAtCharset{Encoding: "UTF-8"}})
+		}
+
+		// Bundling can change which mention of each layer comes first, so list
+		// all layers in their original order before anything else. This is
+		// allowed to come before "@import" rules.
+		if len(layerOrder) > 0 && (len(pieces) > 1 || len(chunkRepr.externalImportsInOrder) > 0) {
+			tree.Rules = append(tree.Rules, css_ast.Rule{Data: &css_ast.RAtLayer{Names: layerOrder}})
 		}
 
 		// Insert all external "@import" rules at the front. In CSS, all "@import"
@@ -4900,6 +5781,8 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 			result := css_printer.Print(tree, css_printer.Options{
 				RemoveWhitespace: c.options.RemoveWhitespace,
 				ASCIIOnly:        c.options.ASCIIOnly,
+				CharsetEscapes:   c.options.CharsetEscapes,
+				IndentUnit:       c.options.IndentUnit,
 			})
 			if len(result.CSS) > 0 {
 				prevOffset.AdvanceBytes(result.CSS)
@@ -4946,9 +5829,11 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 	isFirstMeta := true
 
 	// Concatenate the generated CSS chunks together
-	var compileResultsForSourceMap []compileResultForSourceMap
 	var legalCommentList []string
 	legalCommentSet := make(map[string]bool)
+	var metaOrder []uint32
+	var cssOrderByteRanges []moduleMapByteRange
+	metaBytes := make(map[uint32]int)
 	for _, compileResult := range compileResults {
 		for text := range compileResult.ExtractedLegalComments {
 			if !legalCommentSet[text] {
@@ -4972,6 +5857,13 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 
 		// Save the offset to the start of the stored JavaScript
 		compileResult.generatedOffset = prevOffset
+		if c.options.AbsCSSOrderReportFile != "" && len(compileResult.CSS) > 0 {
+			cssOrderByteRanges = append(cssOrderByteRanges, moduleMapByteRange{
+				sourceIndex: compileResult.sourceIndex,
+				start:       j.Length(),
+				end:         j.Length() + uint32(len(compileResult.CSS)),
+			})
+		}
 		j.AddBytes(compileResult.CSS)
 
 		// Ignore empty source map chunks
@@ -4979,6 +5871,7 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 			prevOffset.AdvanceBytes(compileResult.CSS)
 		} else {
 			prevOffset = sourcemap.LineColumnOffset{}
+			mappedEnd = j.Length()
 
 			// Include this file in the source map
 			if c.options.SourceMap != config.SourceMapNone {
@@ -4990,26 +5883,38 @@ func (c *linkerContext) generateChunkCSS(chunks []chunkInfo, chunkIndex int, chu
 			}
 		}
 
-		// Include this file in the metadata
+		// Include this file in the metadata. Files may be split into several
+		// pieces when cascade layers are removed.
 		if c.options.NeedsMetafile {
-			if isFirstMeta {
-				isFirstMeta = false
-			} else {
-				jMeta.AddString(",")
+			if _, ok := metaBytes[compileResult.sourceIndex]; !ok {
+				metaOrder = append(metaOrder, compileResult.sourceIndex)
 			}
-			jMeta.AddString(fmt.Sprintf("\n        %s: {\n          \"bytesInOutput\": %d\n        }",
-				js_printer.QuoteForJSON(c.graph.Files[compileResult.sourceIndex].InputFile.Source.PrettyPath, c.options.ASCIIOnly),
-				len(compileResult.CSS)))
+			metaBytes[compileResult.sourceIndex] += len(compileResult.CSS)
 		}
 	}
+	for _, sourceIndex := range metaOrder {
+		if isFirstMeta {
+			isFirstMeta = false
+		} else {
+			jMeta.AddString(",")
+		}
+		jMeta.AddString(fmt.Sprintf("\n        %s: {\n          \"bytesInOutput\": %d\n        }",
+			js_printer.QuoteForJSON(c.graph.Files[sourceIndex].InputFile.Source.PrettyPath, c.options.ASCIIOnly),
+			metaBytes[sourceIndex]))
+	}
 
 	// Make sure the file ends with a newline
 	j.EnsureNewlineAtEnd()
 	maybeAppendLegalComments(c.options.LegalComments, legalCommentList, chunk, &j, "/style")
 
 	if len(c.options.CSSFooter) > 0 {
-		j.AddString(c.options.CSSFooter)
-		j.AddString("\n")
+		c.appendFooter(&j, &compileResultsForSourceMap, chunk, c.options.CSSFooter, mappedEnd)
+	}
+
+	// Convert the ranges for the CSS order report to line and column offsets
+	// while the CSS contents are still in one piece
+	if c.options.AbsCSSOrderReportFile != "" {
+		chunk.moduleMapRanges = moduleMapRangesFromByteRanges(j.Done(), cssOrderByteRanges)
 	}
 
 	// The CSS contents are done now that the source map comment is in
@@ -5093,6 +5998,9 @@ func appendIsolatedHashesForImportedChunks(
 	for _, chunkImport := range chunk.crossChunkImports {
 		appendIsolatedHashesForImportedChunks(hash, chunks, chunkImport.chunkIndex, visited, visitedKey)
 	}
+	if chunk.injectsCSSBundle {
+		appendIsolatedHashesForImportedChunks(hash, chunks, chunk.cssBundleChunkIndex.GetIndex(), visited, visitedKey)
+	}
 
 	// Mix in the hash for this chunk
 	hash.Write(chunk.waitForIsolatedHash())
@@ -5226,6 +6134,12 @@ func (c *linkerContext) generateIsolatedHash(chunk *chunkInfo, channel chan []by
 		hashWriteLengthPrefixed(hash, []byte(part.Data))
 	}
 
+	// Line endings are converted after the hash is computed, so the line ending
+	// style must be part of the hash too
+	if c.options.LineEnding == config.LineEndingCRLF {
+		hashWriteLengthPrefixed(hash, []byte("\r\n"))
+	}
+
 	// Include the generated output content in the hash. This excludes the
 	// randomly-generated import paths (the unique keys) and only includes the
 	// data in the spans between them.
@@ -5372,6 +6286,10 @@ type compileResultForSourceMap struct {
 	sourceMapChunk  sourcemap.Chunk
 	generatedOffset sourcemap.LineColumnOffset
 	sourceIndex     uint32
+
+	// If present, this maps to a virtual source instead of to an input file
+	virtualSourceName     string
+	virtualSourceContents string
 }
 
 func (c *linkerContext) generateSourceMapForChunk(
@@ -5385,6 +6303,7 @@ func (c *linkerContext) generateSourceMapForChunk(
 
 	// Only write out the sources for a given source index once
 	sourceIndexToSourcesIndex := make(map[uint32]int)
+	virtualSourceToSourcesIndex := make(map[string]int)
 
 	// Generate the "sources" and "sourcesContent" arrays
 	type item struct {
@@ -5395,6 +6314,21 @@ func (c *linkerContext) generateSourceMapForChunk(
 	items := make([]item, 0, len(results))
 	nextSourcesIndex := 0
 	for _, result := range results {
+		if result.virtualSourceName != "" {
+			if _, ok := virtualSourceToSourcesIndex[result.virtualSourceName]; !ok {
+				virtualSourceToSourcesIndex[result.virtualSourceName] = nextSourcesIndex
+				var quotedContents []byte
+				if !c.options.ExcludeSourcesContent {
+					quotedContents = js_printer.QuoteForJSON(result.virtualSourceContents, c.options.ASCIIOnly)
+				}
+				items = append(items, item{
+					prettyPath:     result.virtualSourceName,
+					quotedContents: quotedContents,
+				})
+				nextSourcesIndex++
+			}
+			continue
+		}
 		if _, ok := sourceIndexToSourcesIndex[result.sourceIndex]; ok {
 			continue
 		}
@@ -5490,6 +6424,9 @@ func (c *linkerContext) generateSourceMapForChunk(
 		chunk := result.sourceMapChunk
 		offset := result.generatedOffset
 		sourcesIndex := sourceIndexToSourcesIndex[result.sourceIndex]
+		if result.virtualSourceName != "" {
+			sourcesIndex = virtualSourceToSourcesIndex[result.virtualSourceName]
+		}
 
 		// This should have already been checked earlier
 		if chunk.ShouldIgnore {
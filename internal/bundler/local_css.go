@@ -0,0 +1,192 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Files with the "local-css" loader implement CSS modules. Each class name
+// in these files is local to the file by default. It's renamed to a name
+// that's unique across the whole bundle, and the JavaScript stub for the file
+// exports an object that maps each original name to the new name:
+//
+//   /* button.module.css */
+//   .primary { composes: base; color: red }
+//   .base { border: none }
+//
+//   /* JavaScript stub */
+//   export default {
+//     primary: "button_module_primary button_module_base",
+//     base: "button_module_base",
+//   };
+//
+// Names that are composed with "composes" are appended to the exported value
+// after the new name, which includes everything they compose in turn.
+
+type localCSSName struct {
+	sourceIndex uint32
+	name        string
+}
+
+// This assigns new names to the local names of all files with the "local-css"
+// loader and returns the object that each file's JavaScript stub will export
+func (s *scanner) renameLocalCSSNames() map[uint32]js_ast.Expr {
+	var sourceIndices []uint32
+	for i, result := range s.results {
+		if result.ok && result.file.inputFile.Loader == config.LoaderLocalCSS {
+			if _, ok := result.file.inputFile.Repr.(*graph.CSSRepr); ok {
+				sourceIndices = append(sourceIndices, uint32(i))
+			}
+		}
+	}
+	if len(sourceIndices) == 0 {
+		return nil
+	}
+
+	// Source indices are assigned in a nondeterministic order, so sort by path
+	// to make sure the new names are deterministic
+	sort.Slice(sourceIndices, func(i int, j int) bool {
+		a := s.results[sourceIndices[i]].file.inputFile.Source.KeyPath
+		b := s.results[sourceIndices[j]].file.inputFile.Source.KeyPath
+		return a.Text < b.Text || (a.Text == b.Text && a.Namespace < b.Namespace)
+	})
+
+	// Rename all local names first since "composes" can reference other files
+	used := make(map[string]bool)
+	for _, sourceIndex := range sourceIndices {
+		file := &s.results[sourceIndex].file
+		repr := file.inputFile.Repr.(*graph.CSSRepr)
+		prefix := file.inputFile.Source.IdentifierName
+		repr.LocalNames = make(map[string]string, len(repr.AST.LocalNames))
+		for _, local := range repr.AST.LocalNames {
+			base := prefix + "_" + local.Name
+			name := base
+			for i := 2; used[name]; i++ {
+				name = fmt.Sprintf("%s%d", base, i)
+			}
+			used[name] = true
+			repr.LocalNames[local.Name] = name
+		}
+	}
+
+	// Resolve the names in each "composes" declaration
+	composes := make(map[localCSSName][]localCSSName)
+	for _, sourceIndex := range sourceIndices {
+		file := &s.results[sourceIndex].file
+		repr := file.inputFile.Repr.(*graph.CSSRepr)
+		for _, local := range repr.AST.LocalNames {
+			key := localCSSName{sourceIndex: sourceIndex, name: local.Name}
+			for _, composed := range local.Composes {
+				if target, ok := s.resolveComposedName(sourceIndex, composed); ok {
+					composes[key] = append(composes[key], target)
+				}
+			}
+		}
+	}
+
+	// Generate the object for each JavaScript stub
+	exports := make(map[uint32]js_ast.Expr, len(sourceIndices))
+	for _, sourceIndex := range sourceIndices {
+		repr := s.results[sourceIndex].file.inputFile.Repr.(*graph.CSSRepr)
+		properties := make([]js_ast.Property, 0, len(repr.AST.LocalNames))
+		for _, local := range repr.AST.LocalNames {
+			var names []string
+			visited := make(map[localCSSName]bool)
+			s.appendComposedNames(&names, visited, composes, localCSSName{sourceIndex: sourceIndex, name: local.Name})
+			properties = append(properties, js_ast.Property{
+				Key:        js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(local.Name)}},
+				ValueOrNil: js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(strings.Join(names, " "))}},
+			})
+		}
+		exports[sourceIndex] = js_ast.Expr{Data: &js_ast.EObject{Properties: properties}}
+	}
+	return exports
+}
+
+// Global names are returned with an invalid source index
+func (s *scanner) resolveComposedName(sourceIndex uint32, composed css_ast.ComposedName) (localCSSName, bool) {
+	file := &s.results[sourceIndex].file
+	repr := file.inputFile.Repr.(*graph.CSSRepr)
+	tracker := logger.MakeLineColumnTracker(&file.inputFile.Source)
+
+	if composed.IsGlobal {
+		return localCSSName{sourceIndex: ^uint32(0), name: composed.Name}, true
+	}
+
+	// Names without "from" are local names in the same file
+	if !composed.ImportRecordIndex.IsValid() {
+		if _, ok := repr.LocalNames[composed.Name]; !ok {
+			s.log.Add(logger.Error, &tracker, composed.Range,
+				fmt.Sprintf("The name %q is not a local class name in this file", composed.Name))
+			return localCSSName{}, false
+		}
+		return localCSSName{sourceIndex: sourceIndex, name: composed.Name}, true
+	}
+
+	recordIndex := composed.ImportRecordIndex.GetIndex()
+	record := &repr.AST.ImportRecords[recordIndex]
+	if !record.SourceIndex.IsValid() {
+		if s.options.Mode != config.ModeBundle {
+			s.log.Add(logger.Error, &tracker, record.Range,
+				"Cannot use \"composes\" with another file when bundling is disabled")
+		} else if s.results[sourceIndex].resolveResults[recordIndex] != nil {
+			s.log.Add(logger.Error, &tracker, record.Range,
+				fmt.Sprintf("Cannot use \"composes\" with the external file %q", record.Path.Text))
+		}
+		return localCSSName{}, false
+	}
+
+	// Using a JavaScript file here is reported elsewhere
+	otherIndex := record.SourceIndex.GetIndex()
+	otherFile := &s.results[otherIndex].file
+	otherRepr, ok := otherFile.inputFile.Repr.(*graph.CSSRepr)
+	if !ok {
+		return localCSSName{}, false
+	}
+	if otherFile.inputFile.Loader != config.LoaderLocalCSS {
+		s.log.Add(logger.Error, &tracker, record.Range,
+			fmt.Sprintf("Cannot use \"composes\" with %q because it doesn't use the \"local-css\" loader",
+				otherFile.inputFile.Source.PrettyPath))
+		return localCSSName{}, false
+	}
+	if _, ok := otherRepr.LocalNames[composed.Name]; !ok {
+		s.log.Add(logger.Error, &tracker, composed.Range,
+			fmt.Sprintf("The name %q is not a local class name in %q", composed.Name, otherFile.inputFile.Source.PrettyPath))
+		return localCSSName{}, false
+	}
+	return localCSSName{sourceIndex: otherIndex, name: composed.Name}, true
+}
+
+// This appends the new name for a local name followed by the names of
+// everything it composes. Each name is only appended once, which also stops
+// cycles of "composes" declarations from recursing forever.
+func (s *scanner) appendComposedNames(
+	names *[]string,
+	visited map[localCSSName]bool,
+	composes map[localCSSName][]localCSSName,
+	key localCSSName,
+) {
+	if visited[key] {
+		return
+	}
+	visited[key] = true
+
+	if key.sourceIndex == ^uint32(0) {
+		*names = append(*names, key.name)
+		return
+	}
+
+	repr := s.results[key.sourceIndex].file.inputFile.Repr.(*graph.CSSRepr)
+	*names = append(*names, repr.LocalNames[key.name])
+	for _, composed := range composes[key] {
+		s.appendComposedNames(names, visited, composes, composed)
+	}
+}
@@ -0,0 +1,89 @@
+package bundler
+
+import (
+	"sort"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+)
+
+// Property names matching "--mangle-props" are renamed consistently across all
+// files in the build, so the new names can only be picked once every file has
+// been parsed. Names that are used more often are given shorter names. Names
+// of properties that weren't mangled are never reused since the mangled
+// property may be on the same object as one of them.
+//
+// Properties in the mangle cache keep the name they had in a previous build
+// if it's still available. No other property is given a name from the cache,
+// even if that property isn't part of this build, since separate builds that
+// share a mangle cache may end up operating on the same objects.
+func computeMangledPropNames(files []graph.InputFile, reachableFiles []uint32, cache map[string]string) map[string]string {
+	counts := make(map[string]uint32)
+	reserved := make(map[string]bool)
+	for _, sourceIndex := range reachableFiles {
+		if repr, ok := files[sourceIndex].Repr.(*graph.JSRepr); ok {
+			for name, count := range repr.AST.MangledPropCounts {
+				counts[name] += count
+			}
+			for name := range repr.AST.ReservedPropNames {
+				reserved[name] = true
+			}
+		}
+	}
+
+	names := make(map[string]string, len(counts))
+	used := make(map[string]bool, len(cache))
+	for _, name := range cache {
+		used[name] = true
+	}
+	for name := range counts {
+		if cached, ok := cache[name]; ok && !reserved[cached] {
+			names[name] = cached
+		}
+	}
+
+	type mangledProp struct {
+		name  string
+		count uint32
+	}
+	sorted := make([]mangledProp, 0, len(counts))
+	for name, count := range counts {
+		if _, ok := names[name]; !ok {
+			sorted = append(sorted, mangledProp{name: name, count: count})
+		}
+	}
+	sort.Slice(sorted, func(i int, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		return a.name < b.name
+	})
+
+	next := 0
+	for _, prop := range sorted {
+		for {
+			name := js_ast.DefaultNameMinifier.NumberToMinifiedName(next)
+			next++
+			if !reserved[name] && !used[name] {
+				names[prop.name] = name
+				break
+			}
+		}
+	}
+	return names
+}
+
+// Properties that weren't part of this build keep their previous names so
+// that code that comes back later gets its old names back
+func generateMangleCache(options *config.Options) string {
+	names := make(map[string]string, len(options.MangleCache)+len(options.MangledPropNames))
+	for name, mangled := range options.MangleCache {
+		names[name] = mangled
+	}
+	for name, mangled := range options.MangledPropNames {
+		names[name] = mangled
+	}
+	return generateNameMapJSON(options, names)
+}
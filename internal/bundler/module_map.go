@@ -0,0 +1,113 @@
+package bundler
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/sourcemap"
+)
+
+// A module map is a JSON file next to each JavaScript output file that says
+// which ranges of the output file came from which input file:
+//
+//   {
+//     "file": "out.js",
+//     "modules": [
+//       { "path": "src/util.js", "startLine": 2, "startColumn": 1, "endLine": 5, "endColumn": 1 },
+//       { "path": "src/index.js", "startLine": 7, "startColumn": 1, "endLine": 9, "endColumn": 1 }
+//     ]
+//   }
+//
+// This lets errors from production code be attributed to a module using only
+// the line and column from a stack trace, even when the code is minified and
+// there is no source map. Lines and columns are 1-based like in stack traces
+// and columns are measured in UTF-16 code units like in JavaScript. The start
+// is inclusive and the end is exclusive.
+//
+// Code that esbuild generates itself (such as the runtime helpers and the
+// exports of an entry point) isn't part of any module. A module may appear
+// more than once if its code isn't contiguous in the output file.
+
+type moduleMapRange struct {
+	sourceIndex uint32
+	start       sourcemap.LineColumnOffset
+	end         sourcemap.LineColumnOffset
+}
+
+type moduleMapByteRange struct {
+	sourceIndex uint32
+	start       uint32
+	end         uint32
+}
+
+// The byte ranges must be in order. Ranges that are next to each other and
+// that came from the same file are merged together.
+func moduleMapRangesFromByteRanges(contents []byte, byteRanges []moduleMapByteRange) (ranges []moduleMapRange) {
+	offset := sourcemap.LineColumnOffset{}
+	prev := uint32(0)
+	advance := func(end uint32) sourcemap.LineColumnOffset {
+		offset.AdvanceBytes(contents[prev:end])
+		prev = end
+		return offset
+	}
+
+	for i, byteRange := range byteRanges {
+		if i > 0 && byteRanges[i-1].sourceIndex == byteRange.sourceIndex && byteRanges[i-1].end == byteRange.start {
+			ranges[len(ranges)-1].end = advance(byteRange.end)
+			continue
+		}
+		ranges = append(ranges, moduleMapRange{
+			sourceIndex: byteRange.sourceIndex,
+			start:       advance(byteRange.start),
+			end:         advance(byteRange.end),
+		})
+	}
+	return
+}
+
+// Substituting the final import paths into the output file moves code around,
+// so the ranges must be shifted the same way that source map mappings are
+func shiftLineColumnOffset(shifts []sourcemap.SourceMapShift, offset sourcemap.LineColumnOffset) sourcemap.LineColumnOffset {
+	shift := shifts[0]
+	for _, next := range shifts[1:] {
+		if offset.ComesBefore(next.Before) {
+			break
+		}
+		shift = next
+	}
+	if offset.Lines == shift.Before.Lines {
+		return sourcemap.LineColumnOffset{
+			Lines:   shift.After.Lines,
+			Columns: shift.After.Columns + offset.Columns - shift.Before.Columns,
+		}
+	}
+	return sourcemap.LineColumnOffset{
+		Lines:   shift.After.Lines + offset.Lines - shift.Before.Lines,
+		Columns: offset.Columns,
+	}
+}
+
+func (c *linkerContext) generateModuleMap(ranges []moduleMapRange, shifts []sourcemap.SourceMapShift, outputFileBase string) []byte {
+	j := helpers.Joiner{}
+	j.AddString("{\n  \"file\": ")
+	j.AddBytes(js_printer.QuoteForJSON(outputFileBase, c.options.ASCIIOnly))
+	j.AddString(",\n  \"modules\": [")
+	for i, r := range ranges {
+		if i > 0 {
+			j.AddString(",")
+		}
+		start := shiftLineColumnOffset(shifts, r.start)
+		end := shiftLineColumnOffset(shifts, r.end)
+		path := c.graph.Files[r.sourceIndex].InputFile.Source.PrettyPath
+		j.AddString("\n    { \"path\": ")
+		j.AddBytes(js_printer.QuoteForJSON(path, c.options.ASCIIOnly))
+		j.AddString(fmt.Sprintf(", \"startLine\": %d, \"startColumn\": %d, \"endLine\": %d, \"endColumn\": %d }",
+			start.Lines+1, start.Columns+1, end.Lines+1, end.Columns+1))
+	}
+	if len(ranges) > 0 {
+		j.AddString("\n  ")
+	}
+	j.AddString("]\n}\n")
+	return j.Done()
+}
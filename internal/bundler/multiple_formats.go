@@ -0,0 +1,100 @@
+package bundler
+
+import (
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/resolver"
+)
+
+// Building multiple formats in one build links each entry point once for each
+// format, with a separate JS output extension for each format:
+//
+//   dist/index.mjs
+//   dist/index.cjs
+//
+// The parser generates different code for some formats (e.g. for calls to
+// "require" and for "import()" expressions), so the module graph is scanned
+// once for each group of formats that parse the same way. The file system is
+// only read once though, and "onStart" plugins only run once per build. Like
+// with dual packages, output files that are the same for every format are only
+// generated once. Code splitting applies to each format that supports it.
+
+type formatBundle struct {
+	output config.FormatOutput
+
+	// This is nil if the format is linked from the bundle that contains it
+	bundle *Bundle
+}
+
+// The "umd" format is just like the "iife" format except for the wrapper
+// around the code, so both can be linked from the same scan
+func scanFormatFor(format config.Format) config.Format {
+	if format == config.FormatUMD {
+		return config.FormatIIFE
+	}
+	return format
+}
+
+func multipleFormatOptions(options config.Options, output config.FormatOutput) config.Options {
+	options.OutputFormat = output.Format
+	options.OutputFormats = nil
+	options.OutputExtensionJS = output.OutputExtensionJS
+	if !output.Format.SupportsCodeSplitting(options.Platform) {
+		options.CodeSplitting = false
+	}
+	if output.Format != config.FormatESModule {
+		options.CJSWrapper = false
+	}
+
+	// Converting the format requires a mode that does this even when not bundling
+	if options.Mode == config.ModePassThrough {
+		options.Mode = config.ModeConvertFormat
+	}
+	return options
+}
+
+func scanMultipleFormats(
+	log logger.Log,
+	fs fs.FS,
+	res resolver.Resolver,
+	caches *cache.CacheSet,
+	entryPoints []EntryPoint,
+	options config.Options,
+	timer *helpers.Timer,
+) Bundle {
+	first := options.OutputFormats[0]
+	bundle := scanBundle(log, fs, res, caches, entryPoints, multipleFormatOptions(options, first), timer, true /* runOnStart */)
+	bundle.formatBundles = []formatBundle{{output: first}}
+	scans := map[config.Format]*Bundle{scanFormatFor(first.Format): nil}
+
+	for _, output := range options.OutputFormats[1:] {
+		if log.HasErrors() {
+			break
+		}
+		key := scanFormatFor(output.Format)
+		scan, ok := scans[key]
+		if !ok {
+			other := scanBundle(log, fs, res, caches, entryPoints, multipleFormatOptions(options, output), timer, false /* runOnStart */)
+			scan = &other
+			scans[key] = scan
+		}
+		bundle.formatBundles = append(bundle.formatBundles, formatBundle{output: output, bundle: scan})
+	}
+	return bundle
+}
+
+func (b *Bundle) linkMultipleFormats(log logger.Log, options config.Options, timer *helpers.Timer, linkCache *LinkCache) []graph.OutputFile {
+	var outputFiles []graph.OutputFile
+	for _, format := range b.formatBundles {
+		bundle := b
+		if format.bundle != nil {
+			bundle = format.bundle
+		}
+		outputFiles = append(outputFiles, bundle.linkEntryPoints(log, multipleFormatOptions(options, format.output), timer, linkCache)...)
+	}
+	return outputFiles
+}
@@ -0,0 +1,56 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/js_printer"
+)
+
+// The name cache is a JSON object that maps each top-level symbol to the name
+// that it was given when minifying. Symbols are identified by the path of the
+// file that declares them and their original name:
+//
+//   {
+//     "src/util.js:formatDate": "a",
+//     "src/app.js:render": "b"
+//   }
+//
+// Passing the same file to the next build makes top-level symbols keep their
+// names as long as the name is still available, which keeps the difference
+// between two versions of a minified bundle small.
+//
+// The name cache isn't an output file. It's returned separately and written
+// next to the output files, like the metafile, so it's never written to stdout
+// and isn't listed in the metafile.
+
+func generateNameCache(options *config.Options) string {
+	return generateNameMapJSON(options, options.NameCache.Names())
+}
+
+// This is also used for the mangle cache, which has the same format
+func generateNameMapJSON(options *config.Options, names map[string]string) string {
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sb := strings.Builder{}
+	sb.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf("\n  %s: %s",
+			js_printer.QuoteForJSON(key, options.ASCIIOnly),
+			js_printer.QuoteForJSON(names[key], options.ASCIIOnly)))
+	}
+	if len(keys) > 0 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
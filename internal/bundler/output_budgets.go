@@ -0,0 +1,104 @@
+package bundler
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+// These are rough estimates of how expensive a JavaScript output file is to
+// parse and evaluate. Browsers parse and compile the top-level code eagerly
+// and every function lazily, so the number of functions and the number of
+// top-level statements are better predictors of main-thread time than the
+// size of the file alone.
+type outputCost struct {
+	bytes      int
+	functions  int
+	statements int
+}
+
+func (c *linkerContext) estimateOutputCost(chunkRepr *chunkReprJS, contents []byte) outputCost {
+	cost := outputCost{bytes: len(contents)}
+	wrappedFiles := make(map[uint32]bool)
+
+	for _, partRange := range chunkRepr.partsInChunkInOrder {
+		if partRange.sourceIndex == runtime.SourceIndex && c.options.OmitRuntimeForTests {
+			continue
+		}
+		file := &c.graph.Files[partRange.sourceIndex]
+		repr := file.InputFile.Repr.(*graph.JSRepr)
+
+		// The code in a wrapped file is inside a closure, so the whole file only
+		// adds a single top-level statement and a single function
+		isWrapped := repr.Meta.Wrap != graph.WrapNone
+		if isWrapped && !wrappedFiles[partRange.sourceIndex] {
+			wrappedFiles[partRange.sourceIndex] = true
+			cost.functions++
+			cost.statements++
+		}
+
+		for partIndex := partRange.partIndexBegin; partIndex < partRange.partIndexEnd; partIndex++ {
+			part := &repr.AST.Parts[partIndex]
+			if !part.IsLive {
+				continue
+			}
+			for _, scope := range part.Scopes {
+				if scope.Kind == js_ast.ScopeFunctionArgs {
+					cost.functions++
+				}
+			}
+			if !isWrapped {
+				cost.statements += len(part.Stmts)
+			}
+		}
+	}
+
+	return cost
+}
+
+// This generates a warning for each JavaScript output file that exceeds one
+// or more of the configured budgets. It's meant to flag bundles that will be
+// slow to start up even if they aren't particularly large when compressed.
+func (c *linkerContext) checkOutputBudgets(chunks []chunkInfo, results [][]graph.OutputFile) {
+	budgets := c.options.OutputBudgets
+	if budgets == (config.OutputBudgets{}) {
+		return
+	}
+
+	for chunkIndex, chunk := range chunks {
+		chunkRepr, ok := chunk.chunkRepr.(*chunkReprJS)
+		if !ok {
+			continue
+		}
+
+		// The output file for the chunk itself always comes last
+		outputFiles := results[chunkIndex]
+		outputFile := outputFiles[len(outputFiles)-1]
+		cost := c.estimateOutputCost(chunkRepr, outputFile.Contents)
+
+		var notes []logger.MsgData
+		if budgets.Bytes > 0 && cost.bytes > budgets.Bytes {
+			notes = append(notes, logger.MsgData{Text: fmt.Sprintf(
+				"The file is %d bytes (the budget is %d).", cost.bytes, budgets.Bytes)})
+		}
+		if budgets.Functions > 0 && cost.functions > budgets.Functions {
+			notes = append(notes, logger.MsgData{Text: fmt.Sprintf(
+				"The file contains %d functions (the budget is %d).", cost.functions, budgets.Functions)})
+		}
+		if budgets.Statements > 0 && cost.statements > budgets.Statements {
+			notes = append(notes, logger.MsgData{Text: fmt.Sprintf(
+				"The file contains %d top-level statements (the budget is %d).", cost.statements, budgets.Statements)})
+		}
+		if notes == nil {
+			continue
+		}
+
+		prettyPath := c.res.PrettyPath(logger.Path{Text: outputFile.AbsPath, Namespace: "file"})
+		c.log.AddWithNotes(logger.Warning, nil, logger.Range{},
+			fmt.Sprintf("The output file %q exceeds its parse-time cost budget", prettyPath), notes)
+	}
+}
@@ -0,0 +1,261 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// An output file conflicts with an input file when it would overwrite that
+// input file (e.g. when the output directory is the source directory), and
+// with another output file when both have the same path (e.g. when two entry
+// points are given the same output path). What happens then depends on the
+// "on conflict" setting:
+//
+//   error      Every conflict is an error. This is the default.
+//
+//   rename     Chunks are renamed by adding a number to the end of their name.
+//              Only chunks can be renamed because the paths of other output
+//              files (such as assets) are already part of the generated code,
+//              so conflicts involving other output files are still errors.
+//
+//   overwrite  Output files are allowed to overwrite input files. When two
+//              output files conflict, the later one is written and there is a
+//              warning about the one that was dropped.
+//
+// Output files with the same path and the same contents never conflict. That
+// can happen with the "file" loader, for example.
+
+// This is used to say exactly which output files are involved in a conflict
+func describeOutputFile(outputFile *graph.OutputFile) string {
+	what := "a shared chunk"
+	if outputFile.InputPath != "" {
+		what = fmt.Sprintf("entry point %q", outputFile.InputPath)
+	}
+	switch outputFile.Kind {
+	case graph.OutputAsset:
+		return fmt.Sprintf("the asset %q", outputFile.InputPath)
+	case graph.OutputSourceMap:
+		return "the source map for " + what
+	case graph.OutputLegalComments:
+		return "the legal comments for " + what
+	case graph.OutputModuleMap:
+		return "the module map for " + what
+	case graph.OutputCJSWrapper:
+		return "the CommonJS wrapper for " + what
+	}
+	if outputFile.InputPath != "" {
+		return "the output for " + what
+	}
+	return what
+}
+
+func capitalize(text string) string {
+	return strings.ToUpper(text[:1]) + text[1:]
+}
+
+func (b *Bundle) handleOutputConflicts(
+	log logger.Log,
+	options *config.Options,
+	allReachableFiles []uint32,
+	outputFiles []graph.OutputFile,
+) []graph.OutputFile {
+	// Make sure an output file never overwrites an input file
+	if options.OnConflict != config.OnConflictOverwrite && options.StdoutStream == config.StdoutStreamNone {
+		sourceAbsPaths := make(map[string]uint32)
+		for _, sourceIndex := range allReachableFiles {
+			keyPath := b.files[sourceIndex].inputFile.Source.KeyPath
+			if keyPath.Namespace == "file" {
+				absPathKey := canonicalFileSystemPathForWindows(keyPath.Text)
+				sourceAbsPaths[absPathKey] = sourceIndex
+			}
+		}
+		for i := range outputFiles {
+			outputFile := &outputFiles[i]
+			absPathKey := canonicalFileSystemPathForWindows(outputFile.AbsPath)
+			if sourceIndex, ok := sourceAbsPaths[absPathKey]; ok {
+				hint := ""
+				switch logger.API {
+				case logger.CLIAPI:
+					hint = " (use \"--on-conflict=overwrite\" to allow this)"
+				case logger.JSAPI:
+					hint = " (use \"onConflict: 'overwrite'\" to allow this)"
+				case logger.GoAPI:
+					hint = " (use \"OnConflict: api.OnConflictOverwrite\" to allow this)"
+				}
+				log.Add(logger.Error, nil, logger.Range{},
+					fmt.Sprintf("Refusing to overwrite input file %q with %s%s",
+						b.files[sourceIndex].inputFile.Source.PrettyPath, describeOutputFile(outputFile), hint))
+			}
+		}
+	}
+
+	// Make sure an output file never overwrites another output file. This is
+	// almost certainly unintentional and would otherwise happen silently.
+	outputFileIndices := make(map[string]int)
+	end := 0
+	for _, outputFile := range outputFiles {
+		absPathKey := canonicalFileSystemPathForWindows(outputFile.AbsPath)
+		existingIndex, ok := outputFileIndices[absPathKey]
+
+		// If this isn't a duplicate, keep the output file
+		if !ok {
+			outputFileIndices[absPathKey] = end
+			outputFiles[end] = outputFile
+			end++
+			continue
+		}
+
+		// If the names and contents are both the same, only keep the first one
+		existing := &outputFiles[existingIndex]
+		if outputFileContentsEqual(b.fs, existing, &outputFile) {
+			continue
+		}
+
+		outputPath := outputFile.AbsPath
+		if relPath, ok := b.fs.Rel(b.fs.Cwd(), outputPath); ok {
+			outputPath = relPath
+		}
+		text := "Two output files share the same path but have different contents: " + outputPath
+
+		// Either let the later file win or generate an error
+		if options.OnConflict == config.OnConflictOverwrite {
+			log.AddWithNotes(logger.Warning, nil, logger.Range{}, text, []logger.MsgData{{
+				Text: fmt.Sprintf("%s was overwritten by %s", capitalize(describeOutputFile(existing)), describeOutputFile(&outputFile)),
+			}})
+			outputFiles[existingIndex] = outputFile
+		} else {
+			log.AddWithNotes(logger.Error, nil, logger.Range{}, text, []logger.MsgData{
+				{Text: fmt.Sprintf("One file is %s", describeOutputFile(existing))},
+				{Text: fmt.Sprintf("The other file is %s", describeOutputFile(&outputFile))},
+			})
+		}
+	}
+	return outputFiles[:end]
+}
+
+// Entry points that are linked separately must not rename their chunks to the
+// same path, so they share the set of paths that have been taken. Each linker
+// takes its turn in entry point order so that renaming is deterministic.
+type chunkPathReservations struct {
+	taken map[string]bool
+	turns []*chunkPathTurn
+}
+
+type chunkPathTurn struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func newChunkPathReservations(turnCount int) *chunkPathReservations {
+	r := &chunkPathReservations{
+		taken: make(map[string]bool),
+		turns: make([]*chunkPathTurn, turnCount),
+	}
+	for i := range r.turns {
+		r.turns[i] = &chunkPathTurn{done: make(chan struct{})}
+	}
+	return r
+}
+
+func (r *chunkPathReservations) waitForTurn(turn int) {
+	if turn > 0 {
+		<-r.turns[turn-1].done
+	}
+}
+
+// This is safe to call more than once, and must be called even if linking
+// failed so that later turns don't wait forever
+func (r *chunkPathReservations) endTurn(turn int) {
+	r.turns[turn].once.Do(func() {
+		close(r.turns[turn].done)
+	})
+}
+
+// Output files that were reused from a previous build can't be renamed, but
+// they still take up their paths
+func (r *chunkPathReservations) reserveOutputFiles(outputFiles []graph.OutputFile) {
+	for _, outputFile := range outputFiles {
+		if outputFile.Kind == graph.OutputChunk {
+			r.taken[canonicalFileSystemPathForWindows(outputFile.AbsPath)] = true
+		}
+	}
+}
+
+// This must be called after the final paths of all chunks are known but before
+// they are substituted into the generated code
+func (c *linkerContext) renameConflictingChunks(chunks []chunkInfo) {
+	c.chunkPaths.waitForTurn(c.chunkPathsTurn)
+	defer c.chunkPaths.endTurn(c.chunkPathsTurn)
+
+	// Chunks must not overwrite input files or assets
+	taken := c.chunkPaths.taken
+	for _, sourceIndex := range c.graph.ReachableFiles {
+		file := &c.graph.Files[sourceIndex]
+		if keyPath := file.InputFile.Source.KeyPath; keyPath.Namespace == "file" {
+			taken[canonicalFileSystemPathForWindows(keyPath.Text)] = true
+		}
+		for _, additionalFile := range file.InputFile.AdditionalFiles {
+			taken[canonicalFileSystemPathForWindows(additionalFile.AbsPath)] = true
+		}
+	}
+
+	// Chunks must also not overwrite each other. Chunks are renamed in order
+	// so earlier chunks keep their name.
+	for chunkIndex := range chunks {
+		chunk := &chunks[chunkIndex]
+		relPath := chunk.finalRelPath
+		isTaken := func(relPath string) bool {
+			return taken[canonicalFileSystemPathForWindows(c.fs.Join(c.options.AbsOutputDir, relPath))]
+		}
+
+		if isTaken(relPath) {
+			base, ext := relPath, ""
+			if dot := strings.LastIndexByte(relPath, '.'); dot > strings.LastIndexByte(relPath, '/') {
+				base, ext = relPath[:dot], relPath[dot:]
+			}
+			for i := 2; isTaken(relPath); i++ {
+				relPath = fmt.Sprintf("%s-%d%s", base, i, ext)
+			}
+			c.log.Add(logger.Debug, nil, logger.Range{},
+				fmt.Sprintf("Renamed the output file %q to %q because it would overwrite another file", chunk.finalRelPath, relPath))
+			chunk.finalRelPath = relPath
+		}
+
+		taken[canonicalFileSystemPathForWindows(c.fs.Join(c.options.AbsOutputDir, relPath))] = true
+	}
+}
+
+// Only one output file can be streamed to stdout with "--outfile=-". All of the
+// output files are listed since it's not always obvious why there are several,
+// such as when a JavaScript file imports a CSS file.
+func (b *Bundle) reportMultipleStdoutOutputs(log logger.Log, outputFiles []graph.OutputFile) {
+	notes := make([]logger.MsgData, 0, len(outputFiles)+1)
+	for i := range outputFiles {
+		outputFile := &outputFiles[i]
+		outputPath := outputFile.AbsPath
+		if relPath, ok := b.fs.Rel(b.fs.Cwd(), outputPath); ok {
+			outputPath = relPath
+		}
+		notes = append(notes, logger.MsgData{Text: fmt.Sprintf("%s would be written to %s",
+			capitalize(describeOutputFile(outputFile)), outputPath)})
+	}
+
+	hint := ""
+	switch logger.API {
+	case logger.CLIAPI:
+		hint = "Use \"--outdir=-\" to write all output files to stdout as a tar archive."
+	case logger.JSAPI:
+		hint = "Use \"outdir: '-'\" to write all output files to stdout as a tar archive."
+	case logger.GoAPI:
+		hint = "Set \"Outdir\" to \"-\" to write all output files to stdout as a tar archive."
+	}
+	notes = append(notes, logger.MsgData{Text: hint})
+
+	log.AddWithNotes(logger.Error, nil, logger.Range{},
+		fmt.Sprintf("Cannot write %d output files to stdout", len(outputFiles)), notes)
+}
@@ -0,0 +1,135 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/xxhash"
+)
+
+// This generates a precache manifest in the format used by Workbox and/or a
+// minimal service worker that precaches every output file. Output files with
+// a content hash in their name don't need a revision since their URL changes
+// whenever their contents change. Other files get a revision derived from
+// their contents so that caches are updated when they change.
+//
+// These are regenerated as part of every build, so they are always in sync
+// with the output files from that build (including during watch mode).
+func generatePrecacheFiles(options *config.Options, fs fs.FS, outputFiles []graph.OutputFile) []graph.OutputFile {
+	var results []graph.OutputFile
+
+	if options.AbsPrecacheManifestFile != "" {
+		manifest := precacheManifestJSON(options, fs, outputFiles, fs.Dir(options.AbsPrecacheManifestFile), "")
+		results = append(results, graph.OutputFile{
+			AbsPath:           options.AbsPrecacheManifestFile,
+			Contents:          []byte(manifest + "\n"),
+			JSONMetadataChunk: metafileEntryForGeneratedFile(len(manifest) + 1),
+		})
+	}
+
+	if options.AbsServiceWorkerFile != "" {
+		manifest := precacheManifestJSON(options, fs, outputFiles, fs.Dir(options.AbsServiceWorkerFile), "  ")
+
+		// The cache name includes a hash of the manifest. This means the service
+		// worker changes whenever any output file changes, which causes browsers
+		// to install the new service worker and delete the old cache.
+		hash := xxhash.New()
+		hash.Write([]byte(manifest))
+		cacheName := "esbuild-precache-" + hashForFileName(hash.Sum(nil))
+
+		contents := fmt.Sprintf(serviceWorkerTemplate, manifest, js_printer.QuoteForJSON(cacheName, false))
+		results = append(results, graph.OutputFile{
+			AbsPath:           options.AbsServiceWorkerFile,
+			Contents:          []byte(contents),
+			JSONMetadataChunk: metafileEntryForGeneratedFile(len(contents)),
+		})
+	}
+
+	return results
+}
+
+func precacheManifestJSON(options *config.Options, fs fs.FS, outputFiles []graph.OutputFile, absBaseDir string, indent string) string {
+	sb := strings.Builder{}
+	sb.WriteString("[")
+	isFirst := true
+
+	for _, outputFile := range outputFiles {
+		// Source maps are only fetched by developer tools
+		if strings.HasSuffix(outputFile.AbsPath, ".map") {
+			continue
+		}
+
+		// Prefer the public path if there is one since that's the URL that the
+		// code uses to load other output files
+		var url string
+		if options.PublicPath != "" {
+			relPath, ok := fs.Rel(options.AbsOutputDir, outputFile.AbsPath)
+			if !ok {
+				continue
+			}
+			url = joinWithPublicPath(options.PublicPath, strings.ReplaceAll(relPath, "\\", "/"))
+		} else {
+			relPath, ok := fs.Rel(absBaseDir, outputFile.AbsPath)
+			if !ok {
+				continue
+			}
+			url = strings.ReplaceAll(relPath, "\\", "/")
+		}
+
+		revision := "null"
+		if !outputFile.IsHashed {
+			hash := xxhash.New()
+			writeOutputFileContents(fs, &outputFile, hash)
+			revision = string(js_printer.QuoteForJSON(hashForFileName(hash.Sum(nil)), false))
+		}
+
+		if !isFirst {
+			sb.WriteString(",")
+		}
+		isFirst = false
+		sb.WriteString(fmt.Sprintf("\n%s  {\n%s    \"url\": %s,\n%s    \"revision\": %s\n%s  }",
+			indent, indent, js_printer.QuoteForJSON(url, options.ASCIIOnly), indent, revision, indent))
+	}
+
+	if !isFirst {
+		sb.WriteString("\n" + indent)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+const serviceWorkerTemplate = `// This service worker was generated by esbuild. It precaches all output files
+// when it's installed and then serves them from the cache.
+(() => {
+  const manifest = %s;
+  const cacheName = %s;
+  const precachedURLs = new Set(manifest.map((entry) => new URL(entry.url, self.location).href));
+
+  self.addEventListener("install", (event) => {
+    event.waitUntil(caches.open(cacheName)
+      .then((cache) => cache.addAll([...precachedURLs]))
+      .then(() => self.skipWaiting()));
+  });
+
+  self.addEventListener("activate", (event) => {
+    event.waitUntil(caches.keys()
+      .then((keys) => Promise.all(keys
+        .filter((key) => key.startsWith("esbuild-precache-") && key !== cacheName)
+        .map((key) => caches.delete(key))))
+      .then(() => self.clients.claim()));
+  });
+
+  self.addEventListener("fetch", (event) => {
+    const url = event.request.url.split("#")[0];
+    if (event.request.method === "GET" && precachedURLs.has(url)) {
+      event.respondWith(caches.open(cacheName)
+        .then((cache) => cache.match(url))
+        .then((response) => response || fetch(event.request)));
+    }
+  });
+})();
+`
@@ -0,0 +1,76 @@
+package bundler
+
+// With "--preserve-modules", each file is compiled on its own without
+// bundling, but relative imports are still followed so that every file the
+// entry points depend on is compiled too. Each file is written to the output
+// directory at the same location relative to "outbase" as its input file, and
+// its import paths are rewritten to point at the other output files (see
+// "relative_imports.go"). This gives libraries a tree of modules that is as
+// fast to build as a bundle, but that downstream bundlers can still
+// tree-shake and split however they want.
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+func (s *scanner) addPreservedModules(result *parseResult) {
+	if len(result.resolveResults) == 0 {
+		return
+	}
+	records := *result.file.inputFile.Repr.ImportRecords()
+
+	for importRecordIndex, resolveResult := range result.resolveResults {
+		if resolveResult == nil {
+			continue
+		}
+
+		// Files that are already being compiled (including the entry points
+		// themselves) don't need another output file
+		path := resolveResult.PathPair.Primary
+		visitedKey := path
+		if visitedKey.Namespace == "file" {
+			visitedKey.Text = canonicalFileSystemPathForWindows(visitedKey.Text)
+		}
+		if _, ok := s.visited[visitedKey]; ok {
+			continue
+		}
+
+		// The output file must end up inside the output directory, so the input
+		// file must be inside the "outbase" directory
+		record := &records[importRecordIndex]
+		relPath, ok := s.fs.Rel(s.options.AbsOutputBase, path.Text)
+		if !ok || relPath == ".." || strings.HasPrefix(relPath, "../") || strings.HasPrefix(relPath, "..\\") {
+			tracker := logger.MakeLineColumnTracker(&result.file.inputFile.Source)
+			s.log.AddWithNotes(logger.Error, &tracker, record.Range,
+				fmt.Sprintf("Cannot preserve the module %q because it's outside of the output base directory", s.res.PrettyPath(path)),
+				[]logger.MsgData{{Text: fmt.Sprintf("The output base directory is %q. You can use \"--outbase\" to choose a directory that contains all input files.",
+					s.res.PrettyPath(logger.Path{Text: s.options.AbsOutputBase, Namespace: "file"}))}})
+			continue
+		}
+
+		// Strip the file extension from the output path so the "out extension"
+		// setting is used instead
+		relPath = relPath[:len(relPath)-len(s.fs.Ext(relPath))]
+
+		sourceIndex := s.maybeParseFile(*resolveResult, s.res.PrettyPath(path),
+			&result.file.inputFile.Source, record.Range, resolveResult.PluginData, inputKindEntryPoint, nil)
+		s.preservedModules = append(s.preservedModules, graph.EntryPoint{
+			OutputPath:                 relPath,
+			SourceIndex:                sourceIndex,
+			OutputPathWasAutoGenerated: true,
+		})
+	}
+}
+
+// Files are discovered in parallel, so sort them for determinism
+func (s *scanner) sortedPreservedModules() []graph.EntryPoint {
+	sort.Slice(s.preservedModules, func(i int, j int) bool {
+		return s.preservedModules[i].OutputPath < s.preservedModules[j].OutputPath
+	})
+	return s.preservedModules
+}
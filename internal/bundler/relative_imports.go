@@ -0,0 +1,91 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/resolver"
+)
+
+// Without bundling, import paths are printed exactly as they were written.
+// Node's ESM loader doesn't search for file extensions or index files, so
+// "--rewrite-imports" rewrites each relative import path to point
+// at the output file that the imported file compiles to:
+//
+//   import "./util"         => import "./util.js"
+//   import "./util.ts"      => import "./util.js"
+//   import "./components"   => import "./components/index.js"
+//
+// This assumes the imported files are compiled alongside the importer, so
+// their output files keep the same relative layout. Package paths are left
+// alone since Node resolves those itself using the package's "package.json".
+//
+// With "--preserve-modules", the resolve result for each rewritten import is
+// returned so the scanner can compile the imported file too (see
+// "preserve_modules.go"). Otherwise this returns nil.
+func rewriteRelativeImportPaths(args *parseArgs, source *logger.Source, absResolveDir string, records []ast.ImportRecord) []*resolver.ResolveResult {
+	outExt := args.options.OutputExtensionJS
+	if outExt == "" {
+		outExt = ".js"
+	}
+
+	var resolveResults []*resolver.ResolveResult
+	if args.options.PreserveModules {
+		resolveResults = make([]*resolver.ResolveResult, len(records))
+	}
+
+	for i := range records {
+		record := &records[i]
+		if record.IsUnused || record.SourceIndex.IsValid() || resolver.IsPackagePath(record.Path.Text) {
+			continue
+		}
+		if record.Kind != ast.ImportStmt && record.Kind != ast.ImportDynamic {
+			continue
+		}
+
+		result, _ := args.res.Resolve(absResolveDir, record.Path.Text, record.Kind)
+		if result == nil || result.IsExternal || result.PathPair.Primary.Namespace != "file" {
+			tracker := logger.MakeLineColumnTracker(source)
+			args.log.Add(logger.Warning, &tracker, record.Range,
+				fmt.Sprintf("Could not resolve %q to rewrite its path", record.Path.Text))
+			continue
+		}
+
+		// Only files that compile to JavaScript have an output file to point at
+		absPath := result.PathPair.Primary.Text
+		base, ext := args.fs.Base(absPath), args.fs.Ext(absPath)
+		if !compilesToJS(loaderFromFileExtension(args.options.ExtensionToLoader, base)) {
+			continue
+		}
+
+		relPath, ok := args.fs.Rel(absResolveDir, absPath[:len(absPath)-len(ext)]+outExt)
+		if !ok {
+			continue
+		}
+
+		// Prevent issues with path separators being different on Windows
+		relPath = strings.ReplaceAll(relPath, "\\", "/")
+		if resolver.IsPackagePath(relPath) {
+			relPath = "./" + relPath
+		}
+		record.Path.Text = relPath
+
+		if resolveResults != nil {
+			resolveResults[i] = result
+		}
+	}
+
+	return resolveResults
+}
+
+func compilesToJS(loader config.Loader) bool {
+	switch loader {
+	case config.LoaderJS, config.LoaderJSX, config.LoaderTS, config.LoaderTSNoAmbiguousLessThan, config.LoaderTSX:
+		return true
+	default:
+		return false
+	}
+}
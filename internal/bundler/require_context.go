@@ -0,0 +1,114 @@
+package bundler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// When "--bundle-dynamic-paths" is enabled, the parser turns calls such as
+// "require(`./locales/${lang}.json`)" into the glob pattern "./locales/*.json"
+// and asks the bundler for the files that match it. Each "*" matches any
+// sequence of characters within a single path segment, so a pattern like
+// "./pages/*/index.js" matches files in multiple directories.
+//
+// The returned paths start with the same relative prefix as the pattern so
+// they can be compared against the value of the path at run-time. Files that
+// don't have a loader are skipped since they couldn't be bundled anyway, as is
+// the importing file itself. The results are sorted so that the output is
+// deterministic.
+func expandRequireContext(
+	fsys fs.FS,
+	extensionToLoader map[string]config.Loader,
+	absResolveDir string,
+	importerPath logger.Path,
+	pattern string,
+) []string {
+	type candidate struct {
+		absPath string
+		relPath string
+	}
+
+	segments := strings.Split(pattern, "/")
+	candidates := []candidate{{absPath: absResolveDir}}
+
+	for i, segment := range segments {
+		isLast := i+1 == len(segments)
+		var next []candidate
+
+		for _, c := range candidates {
+			// Static segments (including "." and "..") don't need a directory listing
+			if !isLast && !strings.ContainsRune(segment, '*') {
+				relPath := segment
+				if c.relPath != "" {
+					relPath = c.relPath + "/" + segment
+				}
+				next = append(next, candidate{absPath: fsys.Join(c.absPath, segment), relPath: relPath})
+				continue
+			}
+
+			entries, err, _ := fsys.ReadDirectory(c.absPath)
+			if err != nil {
+				continue
+			}
+			for _, name := range entries.SortedKeys() {
+				if !matchesWildcardSegment(segment, name) {
+					continue
+				}
+				entry, _ := entries.Get(name)
+				if entry == nil {
+					continue
+				}
+				kind := entry.Kind(fsys)
+				if (isLast && kind != fs.FileEntry) || (!isLast && kind != fs.DirEntry) {
+					continue
+				}
+				next = append(next, candidate{absPath: fsys.Join(c.absPath, name), relPath: c.relPath + "/" + name})
+			}
+		}
+
+		candidates = next
+	}
+
+	var paths []string
+	for _, c := range candidates {
+		if importerPath.Namespace == "file" && c.absPath == importerPath.Text {
+			continue
+		}
+		if loaderFromFileExtension(extensionToLoader, fsys.Base(c.absPath)) == config.LoaderNone {
+			continue
+		}
+		paths = append(paths, c.relPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Matches a single path segment against a pattern where "*" matches any
+// sequence of characters (including an empty one).
+func matchesWildcardSegment(pattern string, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+
+	// The first and last parts are anchored to the start and end of the name
+	first, last := parts[0], parts[len(parts)-1]
+	if len(name) < len(first)+len(last) || !strings.HasPrefix(name, first) || !strings.HasSuffix(name, last) {
+		return false
+	}
+	name = name[len(first) : len(name)-len(last)]
+
+	// The parts in the middle must appear in order
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package bundler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This moves a chunk into the shared chunk directory. The paths of chunks are
+// relative to the output directory, so this just adds the relative path from
+// the output directory to the shared chunk directory to the start of the path.
+// Chunk names contain a hash of their contents by default, so identical chunks
+// from separate builds end up with the same path in the shared directory.
+func (c *linkerContext) templateInSharedChunkDir(template []config.PathTemplate) []config.PathTemplate {
+	relDir, ok := c.fs.Rel(c.options.AbsOutputDir, c.options.AbsSharedChunkDir)
+	if !ok {
+		c.log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Cannot traverse from directory %q to the shared chunk directory %q", c.options.AbsOutputDir, c.options.AbsSharedChunkDir))
+		return template
+	}
+	relDir = strings.ReplaceAll(relDir, "\\", "/")
+
+	result := make([]config.PathTemplate, 0, len(template)+1)
+	result = append(result, config.PathTemplate{Data: "./" + relDir + "/"})
+	for i, part := range template {
+		if i == 0 {
+			part.Data = strings.TrimPrefix(part.Data, "./")
+		}
+		result = append(result, part)
+	}
+	return result
+}
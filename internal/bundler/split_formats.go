@@ -0,0 +1,233 @@
+package bundler
+
+// Code splitting was originally only supported for the "esm" format since
+// chunks can then import each other using "import" and "export" statements.
+// This file contains the code that lets chunks import each other in other
+// formats instead:
+//
+//   - With the "cjs" format, each chunk assigns the symbols that other chunks
+//     use to "module.exports" and chunks get symbols from other chunks using
+//     "require()". Dynamic imports of other chunks also use "require()".
+//
+//   - With the "iife" format, there's no module system to load other chunks.
+//     Instead, each chunk passes its code to a small loader that is included
+//     in every chunk. The loader loads the chunks that this chunk depends on
+//     using script tags, runs the code once they have all been loaded, and
+//     stores a promise for what the code returns in a global registry keyed by
+//     the URL of the chunk. Dynamic imports of other chunks call a function
+//     that the loader passes to the code, which resolves paths relative to the
+//     URL of the chunk and reads the chunk's exports from that registry.
+//     Chunks that are already loaded by a script tag in the page are found in
+//     the registry and aren't loaded again.
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/renamer"
+)
+
+type chunkLoaderImport struct {
+	items      []js_ast.ClauseItem
+	chunkIndex uint32
+}
+
+// "var {a, b} = require('./chunk.js')"
+// "require('./chunk.js')"
+func crossChunkImportStmtCJS(items []js_ast.ClauseItem, importRecordIndex uint32) js_ast.Stmt {
+	value := js_ast.Expr{Data: &js_ast.ERequireString{ImportRecordIndex: importRecordIndex}}
+	if len(items) == 0 {
+		return js_ast.Stmt{Data: &js_ast.SExpr{Value: value}}
+	}
+	properties := make([]js_ast.PropertyBinding, 0, len(items))
+	for _, item := range items {
+		properties = append(properties, js_ast.PropertyBinding{
+			Key:   js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(item.Alias)}},
+			Value: js_ast.Binding{Data: &js_ast.BIdentifier{Ref: item.Name.Ref}},
+		})
+	}
+	return js_ast.Stmt{Data: &js_ast.SLocal{
+		Kind: js_ast.LocalVar,
+		Decls: []js_ast.Decl{{
+			Binding:    js_ast.Binding{Data: &js_ast.BObject{Properties: properties}},
+			ValueOrNil: value,
+		}},
+	}}
+}
+
+// "module.exports = {a, b}"
+// "module.exports.a = a"
+func (c *linkerContext) crossChunkExportStmtsCJS(items []js_ast.ClauseItem, isEntryPoint bool) []js_ast.Stmt {
+	moduleExports := js_ast.Expr{Data: &js_ast.EDot{
+		Target: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: c.unboundModuleRef}},
+		Name:   "exports",
+	}}
+
+	// Entry points already have their own exports, so add to them instead of
+	// replacing them
+	if isEntryPoint {
+		stmts := make([]js_ast.Stmt, 0, len(items))
+		for _, item := range items {
+			stmts = append(stmts, js_ast.AssignStmt(
+				js_ast.Expr{Data: &js_ast.EDot{Target: moduleExports, Name: item.Alias}},
+				js_ast.Expr{Data: &js_ast.EIdentifier{Ref: item.Name.Ref}},
+			))
+		}
+		return stmts
+	}
+
+	properties := make([]js_ast.Property, 0, len(items))
+	for _, item := range items {
+		properties = append(properties, js_ast.Property{
+			Key:        js_ast.Expr{Data: &js_ast.EString{Value: js_lexer.StringToUTF16(item.Alias)}},
+			ValueOrNil: js_ast.Expr{Data: &js_ast.EIdentifier{Ref: item.Name.Ref}},
+		})
+	}
+	return []js_ast.Stmt{js_ast.AssignStmt(moduleExports, js_ast.Expr{Data: &js_ast.EObject{Properties: properties}})}
+}
+
+// Dynamic imports of other chunks in the "iife" format use "__importChunk()"
+func (c *linkerContext) loadsChunkWithScript(record *ast.ImportRecord, sourceIndex uint32) bool {
+	return c.options.CodeSplitting && c.options.OutputFormat == config.FormatIIFE &&
+		record.SourceIndex.IsValid() && c.isExternalDynamicImport(record, sourceIndex)
+}
+
+// This is the same code as "chunkLoaderJS" without the whitespace
+const chunkLoaderMinifiedJS = `return((r,s,d,f)=>{var l=u=>{if(!r[u]){var e=document.createElement("script"),y;` +
+	`r[u]=new Promise((a,b)=>{y=a;e.onload=()=>a({});e.onerror=()=>b(new Error('Failed to load chunk "'+u+'"'))});` +
+	`r[u].resolve=y;e.src=u;document.head.appendChild(e)}return r[u]},p=r[s],i=u=>l(new URL(u,s).href);` +
+	`if(p&&!p.resolve)return p;var x=Promise.all(d.map(i)).then(m=>f(m,i));` +
+	`if(p)p.resolve(x),p.resolve=0;else r[s]=x;return x})(`
+
+var chunkLoaderJS = strings.Join([]string{
+	`return ((registry, src, deps, body) => {`,
+	`  var load = (url) => {`,
+	`    if (!registry[url]) {`,
+	`      var script = document.createElement("script"), resolve;`,
+	`      registry[url] = new Promise((yes, no) => {`,
+	`        resolve = yes;`,
+	`        script.onload = () => yes({});`,
+	`        script.onerror = () => no(new Error('Failed to load chunk "' + url + '"'));`,
+	`      });`,
+	`      registry[url].resolve = resolve;`,
+	`      script.src = url;`,
+	`      document.head.appendChild(script);`,
+	`    }`,
+	`    return registry[url];`,
+	`  };`,
+	``,
+	`  // Only run the code in each chunk once`,
+	`  var pending = registry[src];`,
+	`  if (pending && !pending.resolve) return pending;`,
+	`  var importChunk = (path) => load(new URL(path, src).href);`,
+	`  var exports = Promise.all(deps.map(importChunk)).then((imports) => body(imports, importChunk));`,
+	`  if (pending) pending.resolve(exports), pending.resolve = 0;`,
+	`  else registry[src] = exports;`,
+	`  return exports;`,
+	`})(`,
+}, "\n")
+
+func (c *linkerContext) generateChunkLoaderJS(chunks []chunkInfo, chunkRepr *chunkReprJS, r renamer.Renamer) (prefix []byte, suffix []byte) {
+	indent := c.indentUnit()
+	space := " "
+	newline := "\n"
+	if c.options.RemoveWhitespace {
+		indent = ""
+		space = ""
+		newline = ""
+	}
+	comma := "," + space
+
+	// Pass the registry, this chunk's URL, and the URLs of the chunks that this
+	// chunk depends on to the loader, followed by a function containing the code
+	sb := strings.Builder{}
+	if c.options.RemoveWhitespace {
+		sb.WriteString(chunkLoaderMinifiedJS)
+	} else {
+		for i, line := range strings.Split(chunkLoaderJS, "\n") {
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			if line != "" {
+				sb.WriteString(indent + line)
+			}
+		}
+	}
+	sb.WriteString("globalThis.__esbuildChunks" + space + "||" + space + "(globalThis.__esbuildChunks" + space + "=" + space + "{})")
+	sb.WriteString(comma + "document.currentScript.src" + comma + "[")
+	for i, loaderImport := range chunkRepr.loaderImports {
+		if i > 0 {
+			sb.WriteString(comma)
+		}
+		sb.Write(js_printer.QuoteForJSON(chunks[loaderImport.chunkIndex].uniqueKey, c.options.ASCIIOnly))
+	}
+	sb.WriteString("]" + comma + "(")
+
+	// "([{ a, b: b2 }, {}], __importChunk) => {"
+	importsChunks := c.chunkImportsChunksWithScript(chunkRepr)
+	if len(chunkRepr.loaderImports) > 0 || importsChunks {
+		sb.WriteString("[")
+		for i, loaderImport := range chunkRepr.loaderImports {
+			if i > 0 {
+				sb.WriteString(comma)
+			}
+			sb.WriteString(c.chunkLoaderObjectText(loaderImport.items, r, space))
+		}
+		sb.WriteString("]")
+	}
+	if importsChunks {
+		sb.WriteString(comma + r.NameForSymbol(c.unboundImportChunkRef))
+	}
+	sb.WriteString(")" + space + "=>" + space + "{" + newline)
+	prefix = []byte(sb.String())
+
+	// "return { a, b: b2 };"
+	// "});"
+	sb = strings.Builder{}
+	if len(chunkRepr.loaderExports) > 0 {
+		sb.WriteString(fmt.Sprintf("%sreturn%s%s;%s", indent, space, c.chunkLoaderObjectText(chunkRepr.loaderExports, r, space), newline))
+	}
+	sb.WriteString(indent + "});" + newline)
+	suffix = []byte(sb.String())
+	return
+}
+
+func (c *linkerContext) chunkImportsChunksWithScript(chunkRepr *chunkReprJS) bool {
+	for _, sourceIndex := range chunkRepr.filesInChunkInOrder {
+		if repr, ok := c.graph.Files[sourceIndex].InputFile.Repr.(*graph.JSRepr); ok {
+			for _, record := range repr.AST.ImportRecords {
+				if record.CallRuntimeLoadScript {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (c *linkerContext) chunkLoaderObjectText(items []js_ast.ClauseItem, r renamer.Renamer, space string) string {
+	if len(items) == 0 {
+		return "{}"
+	}
+	sb := strings.Builder{}
+	sb.WriteString("{" + space)
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString("," + space)
+		}
+		name := r.NameForSymbol(item.Name.Ref)
+		if name == item.Alias {
+			sb.WriteString(name)
+		} else {
+			sb.WriteString(item.Alias + ":" + space + name)
+		}
+	}
+	sb.WriteString(space + "}")
+	return sb.String()
+}
@@ -0,0 +1,136 @@
+package bundler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+// This reports exports that are never imported by any other file in the build.
+// Exports of entry points are the public API of the build, so only exports of
+// other files are reported. Tree shaking already removes these exports from
+// the output, but it's useful to know about them so that they can be deleted
+// from the source code too.
+//
+// This must look at the whole build at once instead of being part of linking
+// because each entry point may be linked separately, and an export only has
+// to be imported from one of them to be considered used.
+func reportUnusedExports(log logger.Log, options *config.Options, files []graph.InputFile, entryPoints []graph.EntryPoint, reachableFiles []uint32) {
+	kind := logger.Warning
+	if options.UnusedExports == config.UnusedExportsError {
+		kind = logger.Error
+	}
+
+	usedAliases := make(map[uint32]map[string]bool)
+	allAliasesUsed := make(map[uint32]bool)
+
+	// Importing an export that comes from an "export * from" statement uses the
+	// export in the file that the export star statement refers to
+	var markAliasUsed func(sourceIndex uint32, alias string)
+	markAliasUsed = func(sourceIndex uint32, alias string) {
+		aliases := usedAliases[sourceIndex]
+		if aliases == nil {
+			aliases = make(map[string]bool)
+			usedAliases[sourceIndex] = aliases
+		} else if aliases[alias] {
+			return
+		}
+		aliases[alias] = true
+
+		if repr, ok := files[sourceIndex].Repr.(*graph.JSRepr); ok && alias != "default" {
+			if _, ok := repr.AST.NamedExports[alias]; !ok {
+				for _, importRecordIndex := range repr.AST.ExportStarImportRecords {
+					if record := &repr.AST.ImportRecords[importRecordIndex]; record.SourceIndex.IsValid() {
+						markAliasUsed(record.SourceIndex.GetIndex(), alias)
+					}
+				}
+			}
+		}
+	}
+
+	// Anything that can access the export namespace object can use any export
+	var markAllAliasesUsed func(sourceIndex uint32)
+	markAllAliasesUsed = func(sourceIndex uint32) {
+		if allAliasesUsed[sourceIndex] {
+			return
+		}
+		allAliasesUsed[sourceIndex] = true
+
+		if repr, ok := files[sourceIndex].Repr.(*graph.JSRepr); ok {
+			for _, importRecordIndex := range repr.AST.ExportStarImportRecords {
+				if record := &repr.AST.ImportRecords[importRecordIndex]; record.SourceIndex.IsValid() {
+					markAllAliasesUsed(record.SourceIndex.GetIndex())
+				}
+			}
+		}
+	}
+
+	for _, entryPoint := range entryPoints {
+		markAllAliasesUsed(entryPoint.SourceIndex)
+	}
+
+	for _, sourceIndex := range reachableFiles {
+		repr, ok := files[sourceIndex].Repr.(*graph.JSRepr)
+		if !ok {
+			continue
+		}
+
+		for _, record := range repr.AST.ImportRecords {
+			if record.SourceIndex.IsValid() && (record.Kind == ast.ImportRequire || record.Kind == ast.ImportDynamic) {
+				markAllAliasesUsed(record.SourceIndex.GetIndex())
+			}
+		}
+
+		for ref, namedImport := range repr.AST.NamedImports {
+			record := &repr.AST.ImportRecords[namedImport.ImportRecordIndex]
+			if !record.SourceIndex.IsValid() {
+				continue
+			}
+			otherSourceIndex := record.SourceIndex.GetIndex()
+
+			// A namespace import only uses the whole namespace if it's "captured"
+			// (i.e. used for something other than reading a property off of it)
+			if namedImport.AliasIsStar {
+				if namedImport.IsExported || repr.AST.Symbols[ref.InnerIndex].UseCountEstimate > 0 {
+					markAllAliasesUsed(otherSourceIndex)
+				}
+			} else {
+				markAliasUsed(otherSourceIndex, namedImport.Alias)
+			}
+		}
+	}
+
+	for _, sourceIndex := range reachableFiles {
+		file := &files[sourceIndex]
+		repr, ok := file.Repr.(*graph.JSRepr)
+		if !ok || sourceIndex == runtime.SourceIndex || allAliasesUsed[sourceIndex] ||
+			repr.AST.ExportsKind == js_ast.ExportsCommonJS || helpers.IsInsideNodeModules(file.Source.KeyPath.Text) {
+			continue
+		}
+
+		aliases := make([]string, 0, len(repr.AST.NamedExports))
+		for alias := range repr.AST.NamedExports {
+			if !usedAliases[sourceIndex][alias] {
+				aliases = append(aliases, alias)
+			}
+		}
+		if len(aliases) == 0 {
+			continue
+		}
+		sort.Strings(aliases)
+
+		tracker := logger.MakeLineColumnTracker(&file.Source)
+		for _, alias := range aliases {
+			r := js_lexer.RangeOfIdentifier(file.Source, repr.AST.NamedExports[alias].AliasLoc)
+			log.Add(kind, &tracker, r, fmt.Sprintf("The export %q is never imported by any other file", alias))
+		}
+	}
+}
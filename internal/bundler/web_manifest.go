@@ -0,0 +1,163 @@
+package bundler
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/graph"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/xxhash"
+)
+
+// The "webmanifest" loader handles web app manifests. The manifest is copied
+// to the output directory like the "file" loader, but the images that it
+// references are bundled too and their URLs are rewritten to point to the
+// output files:
+//
+//   {
+//     "name": "Example",
+//     "icons": [
+//       { "src": "icons/192.png", "sizes": "192x192", "type": "image/png" }
+//     ],
+//     "shortcuts": [
+//       { "name": "New", "url": "/new", "icons": [{ "src": "icons/new.png" }] }
+//     ]
+//   }
+//
+// Only the "src" properties of "icons", "screenshots", and the icons of each
+// entry in "shortcuts" are bundled. Each one is treated like a CSS "url()" so
+// it's loaded using the loader configured for its file extension. URLs that
+// start with "/" are relative to the origin instead of the manifest, so they
+// are left alone along with "data:" URLs. The rest of the manifest is copied
+// over unchanged.
+
+func webManifestImportRecords(source *logger.Source, root js_ast.Expr) (records []ast.ImportRecord) {
+	addImageResources := func(images js_ast.Expr) {
+		array, ok := images.Data.(*js_ast.EArray)
+		if !ok {
+			return
+		}
+		for _, image := range array.Items {
+			src := webManifestProperty(image, "src")
+			if str, ok := src.Data.(*js_ast.EString); ok {
+				path := js_lexer.UTF16ToString(str.Value)
+				if path == "" || strings.HasPrefix(path, "/") || strings.HasPrefix(path, "data:") {
+					continue
+				}
+				records = append(records, ast.ImportRecord{
+					Kind:  ast.ImportURL,
+					Path:  logger.Path{Text: path},
+					Range: source.RangeOfString(src.Loc),
+				})
+			}
+		}
+	}
+
+	addImageResources(webManifestProperty(root, "icons"))
+	addImageResources(webManifestProperty(root, "screenshots"))
+	if shortcuts, ok := webManifestProperty(root, "shortcuts").Data.(*js_ast.EArray); ok {
+		for _, shortcut := range shortcuts.Items {
+			addImageResources(webManifestProperty(shortcut, "icons"))
+		}
+	}
+	return
+}
+
+func webManifestProperty(expr js_ast.Expr, name string) js_ast.Expr {
+	if object, ok := expr.Data.(*js_ast.EObject); ok {
+		for _, property := range object.Properties {
+			if key, ok := property.Key.Data.(*js_ast.EString); ok && js_lexer.UTF16EqualsString(key.Value, name) {
+				return property.ValueOrNil
+			}
+		}
+	}
+	return js_ast.Expr{}
+}
+
+// This must be called after all files from the "file" loader have been given
+// their final paths. The manifest is generated with the URLs of the images
+// that it references, and the images are copied to the output directory along
+// with the manifest.
+func (s *scanner) generateWebManifest(inputFile *graph.InputFile) {
+	source := &inputFile.Source
+	records := *inputFile.Repr.ImportRecords()
+	urls := make([]string, len(records))
+	isRelativeToOutputDir := make([]bool, len(records))
+	var additionalFiles []graph.OutputFile
+
+	// Find the output path of each image. Images that weren't bundled (such as
+	// external images) keep their original path.
+	for i, record := range records {
+		urls[i] = record.Path.Text
+		if !record.SourceIndex.IsValid() {
+			continue
+		}
+		otherFile := &s.results[record.SourceIndex.GetIndex()].file.inputFile
+		if otherRepr, ok := otherFile.Repr.(*graph.JSRepr); ok {
+			if otherFile.UniqueKeyForFileLoader != "" && len(otherFile.AdditionalFiles) > 0 {
+				relPath, _ := s.fs.Rel(s.options.AbsOutputDir, otherFile.AdditionalFiles[0].AbsPath)
+				urls[i] = strings.ReplaceAll(relPath, "\\", "/")
+				isRelativeToOutputDir[i] = true
+				additionalFiles = append(additionalFiles, otherFile.AdditionalFiles...)
+			} else {
+				urls[i] = otherRepr.AST.URLForCSS
+			}
+		}
+	}
+
+	// The hash can't include the final contents of the manifest because the
+	// final contents depend on the path of the manifest. Instead, it includes
+	// the original contents and the paths of all images.
+	var hash string
+	if s.options.AbsContentManifestFile != "" || config.HasPlaceholder(s.options.AssetPathTemplate, config.HashPlaceholder) {
+		h := xxhash.New()
+		h.Write([]byte(source.Contents))
+		for _, url := range urls {
+			h.Write([]byte{0})
+			h.Write([]byte(url))
+		}
+		hash = hashForFileName(h.Sum(nil))
+	}
+	absPath, logicalAbsPath := s.assetOutputPath(inputFile, s.options.AssetPathTemplate, hash)
+
+	// Images are referenced relative to the manifest unless there's a public path
+	for i, url := range urls {
+		if !isRelativeToOutputDir[i] {
+			continue
+		}
+		if s.options.PublicPath != "" {
+			urls[i] = joinWithPublicPath(s.options.PublicPath, url)
+		} else if relPath, ok := s.fs.Rel(s.fs.Dir(absPath), s.fs.Join(s.options.AbsOutputDir, url)); ok {
+			relPath = strings.ReplaceAll(relPath, "\\", "/")
+			if !strings.HasPrefix(relPath, "./") && !strings.HasPrefix(relPath, "../") {
+				relPath = "./" + relPath
+			}
+			urls[i] = relPath
+		}
+	}
+
+	// Substitute the new URLs into the original contents
+	sb := strings.Builder{}
+	end := int32(0)
+	for i, record := range records {
+		sb.WriteString(source.Contents[end:record.Range.Loc.Start])
+		sb.Write(js_printer.QuoteForJSON(urls[i], s.options.ASCIIOnly))
+		end = record.Range.End()
+	}
+	sb.WriteString(source.Contents[end:])
+	contents := []byte(sb.String())
+
+	inputFile.AdditionalFiles = append([]graph.OutputFile{{
+		AbsPath:           absPath,
+		Contents:          contents,
+		LogicalAbsPath:    logicalAbsPath,
+		Kind:              graph.OutputAsset,
+		InputPath:         source.PrettyPath,
+		JSONMetadataChunk: s.assetMetadataChunk(inputFile, len(contents)),
+		IsHashed:          hash != "",
+	}}, additionalFiles...)
+}
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 
 	"github.com/evanw/esbuild/internal/css_ast"
@@ -147,6 +148,10 @@ type jsCacheEntry struct {
 	ast     js_ast.AST
 	ok      bool
 	msgs    []logger.Msg
+
+	// This is true if the defines or the dropped statements have changed since
+	// this entry was created in a way that can't have affected this file
+	reusableWithNewDefines bool
 }
 
 func (c *JSCache) Parse(log logger.Log, source logger.Source, options js_parser.Options) (js_ast.AST, bool) {
@@ -158,11 +163,26 @@ func (c *JSCache) Parse(log logger.Log, source logger.Source, options js_parser.
 	}()
 
 	// Cache hit
-	if entry != nil && entry.source == source && entry.options.Equal(&options) {
-		for _, msg := range entry.msgs {
-			log.AddMsg(msg)
+	if entry != nil && entry.source == source && !entry.ast.HasRequireContext {
+		if entry.reusableWithNewDefines && entry.options.EqualExceptDefinesAndDrop(&options) {
+			// Remember the new options so that the entry is compared normally next time
+			entry = &jsCacheEntry{
+				source:  entry.source,
+				options: options,
+				ast:     entry.ast,
+				ok:      entry.ok,
+				msgs:    entry.msgs,
+			}
+			c.mutex.Lock()
+			c.entries[source.KeyPath] = entry
+			c.mutex.Unlock()
+		}
+		if !entry.reusableWithNewDefines && entry.options.Equal(&options) {
+			for _, msg := range entry.msgs {
+				log.AddMsg(msg)
+			}
+			return entry.ast, entry.ok
 		}
-		return entry.ast, entry.ok
 	}
 
 	// Cache miss
@@ -188,3 +208,31 @@ func (c *JSCache) Parse(log logger.Log, source logger.Source, options js_parser.
 	c.entries[source.KeyPath] = entry
 	return ast, ok
 }
+
+// This is called when the defines or the dropped statements of a build change
+// between builds. A file can only be affected if it mentions one of the given
+// names, so the ASTs of all other files are kept and reused with the new
+// options. Identifiers can contain escape sequences, so files with a "\u"
+// anywhere in them are always parsed again.
+func (c *JSCache) InvalidateFilesMentioning(names []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for path, entry := range c.entries {
+		contents := entry.source.Contents
+		isAffected := strings.Contains(contents, "\\u")
+		for _, name := range names {
+			if isAffected {
+				break
+			}
+			isAffected = strings.Contains(contents, name)
+		}
+		if isAffected {
+			delete(c.entries, path)
+		} else if !entry.reusableWithNewDefines {
+			entryCopy := *entry
+			entryCopy.reusableWithNewDefines = true
+			c.entries[path] = &entryCopy
+		}
+	}
+}
@@ -5,6 +5,7 @@ package cli_helpers
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/evanw/esbuild/pkg/api"
 )
@@ -21,36 +22,84 @@ func MakeErrorWithNote(text string, note string) *ErrorWithNote {
 	}
 }
 
+// These are the loaders that can be specified by name, in the order that they
+// are listed in error messages. The name "default" is also accepted.
+var loaders = []struct {
+	name   string
+	loader api.Loader
+}{
+	{"js", api.LoaderJS},
+	{"jsx", api.LoaderJSX},
+	{"ts", api.LoaderTS},
+	{"tsx", api.LoaderTSX},
+	{"css", api.LoaderCSS},
+	{"local-css", api.LoaderLocalCSS},
+	{"json", api.LoaderJSON},
+	{"jsonc", api.LoaderJSONC},
+	{"json5", api.LoaderJSON5},
+	{"yaml", api.LoaderYAML},
+	{"toml", api.LoaderTOML},
+	{"text", api.LoaderText},
+	{"base64", api.LoaderBase64},
+	{"dataurl", api.LoaderDataURL},
+	{"file", api.LoaderFile},
+	{"copy", api.LoaderCopy},
+	{"binary", api.LoaderBinary},
+	{"webmanifest", api.LoaderWebManifest},
+	{"image", api.LoaderImage},
+}
+
+// This returns the names accepted by "ParseLoader" other than "default"
+func LoaderNames() []string {
+	names := make([]string, len(loaders))
+	for i, it := range loaders {
+		names[i] = it.name
+	}
+	return names
+}
+
 func ParseLoader(text string) (api.Loader, *ErrorWithNote) {
-	switch text {
-	case "js":
-		return api.LoaderJS, nil
-	case "jsx":
-		return api.LoaderJSX, nil
-	case "ts":
-		return api.LoaderTS, nil
-	case "tsx":
-		return api.LoaderTSX, nil
-	case "css":
-		return api.LoaderCSS, nil
-	case "json":
-		return api.LoaderJSON, nil
-	case "text":
-		return api.LoaderText, nil
-	case "base64":
-		return api.LoaderBase64, nil
-	case "dataurl":
-		return api.LoaderDataURL, nil
-	case "file":
-		return api.LoaderFile, nil
-	case "binary":
-		return api.LoaderBinary, nil
-	case "default":
+	if text == "default" {
 		return api.LoaderDefault, nil
+	}
+	for _, it := range loaders {
+		if it.name == text {
+			return it.loader, nil
+		}
+	}
+	return api.LoaderNone, MakeErrorWithNote(
+		fmt.Sprintf("Invalid loader value: %q", text),
+		ValidValuesNote(LoaderNames()),
+	)
+}
+
+// This is the inverse of "ParseLoader"
+func LoaderName(loader api.Loader) string {
+	if loader == api.LoaderDefault {
+		return "default"
+	}
+	for _, it := range loaders {
+		if it.loader == loader {
+			return it.name
+		}
+	}
+	return ""
+}
+
+// This formats a note such as "Valid values are "a", "b", or "c"." for an error
+// about a flag that only accepts certain values
+func ValidValuesNote(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	switch len(quoted) {
+	case 1:
+		return fmt.Sprintf("The only valid value is %s.", quoted[0])
+	case 2:
+		return fmt.Sprintf("Valid values are %s or %s.", quoted[0], quoted[1])
 	default:
-		return api.LoaderNone, MakeErrorWithNote(
-			fmt.Sprintf("Invalid loader value: %q", text),
-			"Valid values are \"js\", \"jsx\", \"ts\", \"tsx\", \"css\", \"json\", \"text\", \"base64\", \"dataurl\", \"file\", or \"binary\".",
-		)
+		return fmt.Sprintf("Valid values are %s, or %s.",
+			strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
 	}
 }
@@ -15,12 +15,23 @@ const (
 	Modern_RGB_HSL
 
 	InsetProperty
+
+	CascadeLayers
 )
 
 func (features CSSFeature) Has(feature CSSFeature) bool {
 	return (features & feature) != 0
 }
 
+// These names are used by the "supported" setting to override the table
+var StringToCSSFeature = map[string]CSSFeature{
+	"cascade-layers": CascadeLayers,
+	"hex-rgba":       HexRGBA,
+	"inset-property": InsetProperty,
+	"modern-rgb-hsl": Modern_RGB_HSL,
+	"rebecca-purple": RebeccaPurple,
+}
+
 var cssTable = map[CSSFeature]map[Engine][]versionRange{
 	// Data from: https://developer.mozilla.org/en-US/docs/Web/CSS/color_value
 	HexRGBA: {
@@ -53,6 +64,15 @@ var cssTable = map[CSSFeature]map[Engine][]versionRange{
 		IOS:     {{start: v{14, 5, 0}}},
 		Safari:  {{start: v{14, 1, 0}}},
 	},
+
+	// Data from: https://developer.mozilla.org/en-US/docs/Web/CSS/@layer
+	CascadeLayers: {
+		Chrome:  {{start: v{99, 0, 0}}},
+		Edge:    {{start: v{99, 0, 0}}},
+		Firefox: {{start: v{97, 0, 0}}},
+		IOS:     {{start: v{15, 4, 0}}},
+		Safari:  {{start: v{15, 4, 0}}},
+	},
 }
 
 // Return all features that are not available in at least one environment
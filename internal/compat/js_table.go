@@ -88,6 +88,54 @@ func (features JSFeature) Has(feature JSFeature) bool {
 	return (features & feature) != 0
 }
 
+var StringToJSFeature = map[string]JSFeature{
+	"arbitrary-module-namespace-names": ArbitraryModuleNamespaceNames,
+	"array-spread":                     ArraySpread,
+	"arrow":                            Arrow,
+	"async-await":                      AsyncAwait,
+	"async-generator":                  AsyncGenerator,
+	"bigint":                           BigInt,
+	"class":                            Class,
+	"class-field":                      ClassField,
+	"class-private-accessor":           ClassPrivateAccessor,
+	"class-private-brand-check":        ClassPrivateBrandCheck,
+	"class-private-field":              ClassPrivateField,
+	"class-private-method":             ClassPrivateMethod,
+	"class-private-static-accessor":    ClassPrivateStaticAccessor,
+	"class-private-static-field":       ClassPrivateStaticField,
+	"class-private-static-method":      ClassPrivateStaticMethod,
+	"class-static-blocks":              ClassStaticBlocks,
+	"class-static-field":               ClassStaticField,
+	"const":                            Const,
+	"default-argument":                 DefaultArgument,
+	"destructuring":                    Destructuring,
+	"dynamic-import":                   DynamicImport,
+	"exponent-operator":                ExponentOperator,
+	"export-star-as":                   ExportStarAs,
+	"for-await":                        ForAwait,
+	"for-of":                           ForOf,
+	"generator":                        Generator,
+	"hashbang":                         Hashbang,
+	"import-assertions":                ImportAssertions,
+	"import-meta":                      ImportMeta,
+	"let":                              Let,
+	"logical-assignment":               LogicalAssignment,
+	"nested-rest-binding":              NestedRestBinding,
+	"new-target":                       NewTarget,
+	"node-colon-prefix-import":         NodeColonPrefixImport,
+	"node-colon-prefix-require":        NodeColonPrefixRequire,
+	"nullish-coalescing":               NullishCoalescing,
+	"object-accessors":                 ObjectAccessors,
+	"object-extensions":                ObjectExtensions,
+	"object-rest-spread":               ObjectRestSpread,
+	"optional-catch-binding":           OptionalCatchBinding,
+	"optional-chain":                   OptionalChain,
+	"rest-argument":                    RestArgument,
+	"template-literal":                 TemplateLiteral,
+	"top-level-await":                  TopLevelAwait,
+	"unicode-escapes":                  UnicodeEscapes,
+}
+
 var jsTable = map[JSFeature]map[Engine][]versionRange{
 	ArbitraryModuleNamespaceNames: {
 		Chrome:  {{start: v{90, 0, 0}}},
@@ -0,0 +1,126 @@
+package compat
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Returns <0 if "a < b"
+// Returns 0 if "a == b"
+// Returns >0 if "a > b"
+func CompareVersionArrays(a []int, b []int) int {
+	for i := 0; i < 3; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// This formats a version such as "14.17" with only the parts that were given
+func VersionToString(version []int) string {
+	parts := make([]string, len(version))
+	for i, part := range version {
+		parts[i] = strconv.Itoa(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// This returns the lowest version allowed by a semver range such as the ones
+// in the "engines" field in "package.json" (e.g. ">=14.17", "^16 || >=18", or
+// "12.x - 16"). Ranges without a lower bound return "0.0.0". Prerelease tags
+// are ignored. This returns false if the range can't be parsed.
+func MinVersionOfRange(text string) ([]int, bool) {
+	var min []int
+	for _, alternative := range strings.Split(text, "||") {
+		fields := strings.Fields(alternative)
+
+		// Hyphen ranges only have a lower bound on the left of the hyphen
+		if len(fields) == 3 && fields[1] == "-" {
+			fields = fields[:1]
+		}
+
+		// Every comparator in a set must be satisfied, so the largest lower bound
+		// is the lower bound of the whole set
+		lower := []int{0, 0, 0}
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+
+			// Allow a space between the operator and the version such as ">= 14"
+			text := strings.TrimLeft(field, "<>=^~")
+			op := field[:len(field)-len(text)]
+			if text == "" && op != "" && i+1 < len(fields) {
+				i++
+				text = fields[i]
+			}
+			version, last, ok := parseRangeVersion(text)
+			if !ok {
+				return nil, false
+			}
+
+			switch op {
+			case "", "=", ">=", "^", "~", "~>":
+			case ">":
+				// ">14" means ">=15.0.0" and ">14.1.2" means ">=14.1.3"
+				version = append([]int{}, version...)
+				version[last]++
+				for j := last + 1; j < 3; j++ {
+					version[j] = 0
+				}
+			case "<", "<=":
+				continue
+			default:
+				return nil, false
+			}
+			if CompareVersionArrays(version, lower) > 0 {
+				lower = version
+			}
+		}
+
+		if min == nil || CompareVersionArrays(lower, min) < 0 {
+			min = lower
+		}
+	}
+	return min, true
+}
+
+// The version is always returned with three parts. The index of the last part
+// that was present is returned too, since a missing or wildcard part such as
+// in "14.x" means that any value is allowed there.
+func parseRangeVersion(text string) ([]int, int, bool) {
+	text = strings.TrimPrefix(text, "v")
+	if i := strings.IndexAny(text, "-+"); i != -1 {
+		text = text[:i]
+	}
+	version := []int{0, 0, 0}
+	last := 2
+	if text == "" {
+		return version, 0, true
+	}
+	parts := strings.Split(text, ".")
+	if len(parts) > 3 {
+		return nil, 0, false
+	}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if i == 0 {
+				return version, 0, true
+			}
+			return version, i - 1, true
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, 0, false
+		}
+		version[i] = n
+		last = i
+	}
+	return version, last, true
+}
@@ -0,0 +1,42 @@
+package compat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func expectMinVersion(t *testing.T, text string, expected string) {
+	t.Helper()
+	t.Run(text, func(t *testing.T) {
+		t.Helper()
+		actual := "invalid"
+		if version, ok := MinVersionOfRange(text); ok {
+			actual = fmt.Sprintf("%d.%d.%d", version[0], version[1], version[2])
+		}
+		test.AssertEqual(t, actual, expected)
+	})
+}
+
+func TestMinVersionOfRange(t *testing.T) {
+	expectMinVersion(t, "14", "14.0.0")
+	expectMinVersion(t, "v14.17.1", "14.17.1")
+	expectMinVersion(t, ">=14.17", "14.17.0")
+	expectMinVersion(t, ">= 14.17", "14.17.0")
+	expectMinVersion(t, "^16.3.0", "16.3.0")
+	expectMinVersion(t, "~12.22", "12.22.0")
+	expectMinVersion(t, "14.x", "14.0.0")
+	expectMinVersion(t, ">14", "15.0.0")
+	expectMinVersion(t, ">14.1", "14.2.0")
+	expectMinVersion(t, ">14.1.2", "14.1.3")
+	expectMinVersion(t, ">=12 <18", "12.0.0")
+	expectMinVersion(t, "^16 || >=14.18 <15", "14.18.0")
+	expectMinVersion(t, "12.x - 16", "12.0.0")
+	expectMinVersion(t, ">=16.0.0-rc.1", "16.0.0")
+	expectMinVersion(t, "<18", "0.0.0")
+	expectMinVersion(t, "*", "0.0.0")
+	expectMinVersion(t, "", "0.0.0")
+	expectMinVersion(t, "latest", "invalid")
+	expectMinVersion(t, "!14", "invalid")
+}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"image"
 	"regexp"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/compat"
@@ -17,6 +19,12 @@ type JSXOptions struct {
 	Fragment JSXExpr
 	Parse    bool
 	Preserve bool
+
+	// With the automatic runtime, JSX elements become calls to "jsx()" and
+	// "jsxs()" which are automatically imported from "<ImportSource>/jsx-runtime"
+	// instead of calls to the factory
+	AutomaticRuntime bool
+	ImportSource     string
 }
 
 type JSXExpr struct {
@@ -27,6 +35,32 @@ type JSXExpr struct {
 type TSOptions struct {
 	Parse               bool
 	NoAmbiguousLessThan bool
+	Version             TSVersion
+	Enums               TSEnums
+}
+
+// Classic enums are objects that also map numeric values back to their names.
+// Frozen enums are smaller objects without the reverse mapping, which are only
+// used when all values are constants. Inlined enums are frozen enums that are
+// removed entirely when every use of the enum was replaced with its value.
+type TSEnums uint8
+
+const (
+	TSEnumsClassic TSEnums = iota
+	TSEnumsFrozen
+	TSEnumsInline
+)
+
+// TypeScript syntax that's newer than this version is still parsed, but is
+// reported as an error that says which version is needed. This is so that
+// new syntax can't silently change the meaning of existing code.
+type TSVersion struct {
+	Major uint8
+	Minor uint8
+}
+
+func (v TSVersion) IsAtLeast(major uint8, minor uint8) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
 }
 
 type Platform uint8
@@ -68,6 +102,102 @@ const (
 	LegalCommentsExternalWithoutComment
 )
 
+// This is what to do when an output file would overwrite an input file or
+// another output file
+type OnConflict uint8
+
+const (
+	OnConflictError OnConflict = iota
+	OnConflictRename
+	OnConflictOverwrite
+)
+
+// This is used with an output path of "-", which streams the output files to
+// stdout instead of writing them to the output directory
+type StdoutStream uint8
+
+const (
+	StdoutStreamNone StdoutStream = iota
+
+	// "--outfile=-" writes the contents of the only output file
+	StdoutStreamSingle
+
+	// "--outdir=-" writes a tar archive containing all output files
+	StdoutStreamTar
+)
+
+// This is how relative and absolute import paths that refer to a directory
+// (e.g. "./lib" for "./lib/index.js") are resolved
+type DirectoryImports uint8
+
+const (
+	// Like CommonJS: use "main" in "package.json", then look for "index" files
+	DirectoryImportsCommonJS DirectoryImports = iota
+
+	// Only use "main" in "package.json" and don't look for "index" files
+	DirectoryImportsNoIndex
+
+	// Like node: directory imports are errors in "import" statements and
+	// "import()" expressions but still work like CommonJS for "require()"
+	DirectoryImportsNodeESM
+)
+
+type LineEnding uint8
+
+const (
+	LineEndingLF LineEnding = iota
+	LineEndingCRLF
+)
+
+// The code from the start marker comment to the end marker comment is removed
+type StripMarkers struct {
+	Start string
+	End   string
+}
+
+// A limit of zero means that there is no limit
+type OutputBudgets struct {
+	Bytes      int
+	Functions  int
+	Statements int
+}
+
+type UnusedExports uint8
+
+const (
+	UnusedExportsIgnore UnusedExports = iota
+	UnusedExportsWarning
+	UnusedExportsError
+)
+
+type DynamicImportLoader uint8
+
+const (
+	DynamicImportLoaderRequire DynamicImportLoader = iota
+	DynamicImportLoaderScript
+)
+
+type SplittingPreset uint8
+
+const (
+	SplittingPresetNone SplittingPreset = iota
+
+	// Move the runtime and all code from "node_modules" into separate "runtime"
+	// and "vendor" chunks so they can be cached independently of application code
+	SplittingPresetVendor
+)
+
+// This controls comments that are generated for other tools to read such as
+// "/* @__PURE__ */" annotations and magic comments in "import()" expressions.
+// By default these are omitted when minifying whitespace.
+type Annotations uint8
+
+const (
+	AnnotationsDefault Annotations = iota
+	AnnotationsAlways
+	AnnotationsNever
+)
+
 func (lc LegalComments) HasExternalFile() bool {
 	return lc == LegalCommentsLinkedWithComment || lc == LegalCommentsExternalWithoutComment
 }
@@ -82,12 +212,20 @@ const (
 	LoaderTSNoAmbiguousLessThan // Used with ".mts" and ".cts"
 	LoaderTSX
 	LoaderJSON
+	LoaderJSONC
+	LoaderJSON5
+	LoaderYAML
+	LoaderTOML
 	LoaderText
 	LoaderBase64
 	LoaderDataURL
 	LoaderFile
+	LoaderCopy
 	LoaderBinary
 	LoaderCSS
+	LoaderLocalCSS
+	LoaderWebManifest
+	LoaderImage
 	LoaderDefault
 )
 
@@ -102,13 +240,17 @@ func (loader Loader) IsTypeScript() bool {
 
 func (loader Loader) CanHaveSourceMap() bool {
 	switch loader {
-	case LoaderJS, LoaderJSX, LoaderTS, LoaderTSNoAmbiguousLessThan, LoaderTSX, LoaderCSS:
+	case LoaderJS, LoaderJSX, LoaderTS, LoaderTSNoAmbiguousLessThan, LoaderTSX, LoaderCSS, LoaderLocalCSS:
 		return true
 	default:
 		return false
 	}
 }
 
+func (loader Loader) IsCSS() bool {
+	return loader == LoaderCSS || loader == LoaderLocalCSS
+}
+
 type Format uint8
 
 const (
@@ -146,12 +288,43 @@ const (
 	//   export {...};
 	//
 	FormatESModule
+
+	// UMD stands for universal module definition. The code is wrapped in a
+	// factory function that works with AMD, CommonJS, and script tags:
+	//
+	//   (function(root, factory) {
+	//     if (typeof define === "function" && define.amd) define([], factory);
+	//     else if (typeof module === "object" && module.exports) module.exports = factory();
+	//     else root.globalName = factory();
+	//   })(typeof self !== "undefined" ? self : this, () => {
+	//     ... bundled code ...
+	//     return exports;
+	//   });
+	//
+	FormatUMD
 )
 
+type FormatOutput struct {
+	Format            Format
+	OutputExtensionJS string
+}
+
 func (f Format) KeepES6ImportExportSyntax() bool {
 	return f == FormatPreserve || f == FormatESModule
 }
 
+// The IIFE and UMD formats put all of the code inside a function
+func (f Format) IsWrappedInFunction() bool {
+	return f == FormatIIFE || f == FormatUMD
+}
+
+// The UMD format can't be split because its chunks would need to be able to
+// load each other in every environment that it supports. Chunks in the IIFE
+// format load each other using script tags, so they need a browser.
+func (f Format) SupportsCodeSplitting(platform Platform) bool {
+	return f == FormatESModule || f == FormatCommonJS || (f == FormatIIFE && platform == PlatformBrowser)
+}
+
 func (f Format) String() string {
 	switch f {
 	case FormatIIFE:
@@ -160,6 +333,8 @@ func (f Format) String() string {
 		return "cjs"
 	case FormatESModule:
 		return "esm"
+	case FormatUMD:
+		return "umd"
 	}
 	return ""
 }
@@ -182,6 +357,78 @@ type ExternalModules struct {
 	Patterns    []WildcardPattern
 }
 
+// A rule for files that always go in their own chunk. The rule either matches
+// all files in a package or all files whose absolute path matches a pattern.
+// Patterns without a "*" wildcard match a file or everything in a directory.
+type IsolatedChunk struct {
+	Name       string
+	Package    string
+	Pattern    WildcardPattern
+	IsWildcard bool
+}
+
+// External imports that match a rewrite rule keep their import but use a
+// different path in the output. If the pattern has a "*" wildcard, any "*" in
+// the replacement is substituted with the text that matched the wildcard.
+type ExternalRewrite struct {
+	Pattern     WildcardPattern
+	IsWildcard  bool
+	Replacement string
+}
+
+// This maps a key for each top-level symbol (the path of the file and the
+// original name) to the name that it was given when minifying. It's shared by
+// all linkers in a build, which may run in parallel.
+type NameCache struct {
+	previous map[string]string
+	mutex    sync.Mutex
+	current  map[string]string
+}
+
+func NewNameCache(previous map[string]string) *NameCache {
+	return &NameCache{previous: previous, current: make(map[string]string)}
+}
+
+func (c *NameCache) Get(key string) (string, bool) {
+	name, ok := c.previous[key]
+	return name, ok
+}
+
+func (c *NameCache) Update(names map[string]string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, name := range names {
+		// When entry points are linked separately, the same symbol may be given
+		// different names in different output files. Prefer the cached name in
+		// that case so the cache doesn't depend on the order of linking.
+		if old, ok := c.current[key]; ok && old != name {
+			previous, hasPrevious := c.previous[key]
+			if hasPrevious && previous == old {
+				continue
+			}
+			if !(hasPrevious && previous == name) && old < name {
+				continue
+			}
+		}
+		c.current[key] = name
+	}
+}
+
+// Symbols that weren't part of this build keep their previous names so that
+// code that comes back later gets its old names back
+func (c *NameCache) Names() map[string]string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	names := make(map[string]string, len(c.previous)+len(c.current))
+	for key, name := range c.previous {
+		names[key] = name
+	}
+	for key, name := range c.current {
+		names[key] = name
+	}
+	return names
+}
+
 type Mode uint8
 
 const (
@@ -215,20 +462,111 @@ type Options struct {
 	MangleSyntax      bool
 	ProfilerNames     bool
 	CodeSplitting     bool
+	SplittingPreset   SplittingPreset
+	UnusedExports     UnusedExports
 	WatchMode         bool
-	AllowOverwrite    bool
+	OnConflict        OnConflict
 	LegalComments     LegalComments
+	LineEnding        LineEnding
+
+	// This is how external "import()" expressions are loaded when the target
+	// environment doesn't support "import()"
+	DynamicImportLoader DynamicImportLoader
+
+	// This is the string used for each level of indentation in output that
+	// isn't minified. It's empty if the default of two spaces should be used.
+	IndentUnit string
 
 	// If true, make sure to generate a single file that can be written to stdout
 	WriteToStdout bool
 
+	// If set, the output files are streamed to stdout after the build. The
+	// output directory is only used to name them, so they can't overwrite any
+	// input files.
+	StdoutStream StdoutStream
+
+	// If true, both a CommonJS and an ESM version of each entry point are
+	// generated. The output format and the JS output extension are overridden
+	// separately for each of the two versions.
+	DualPackage bool
+
+	// If there are multiple formats, each entry point is linked once for each
+	// format using that format's JS output extension. The output format is the
+	// first of these formats.
+	OutputFormats []FormatOutput
+
 	OmitRuntimeForTests     bool
 	UnusedImportsTS         UnusedImportsTS
 	UseDefineForClassFields MaybeBool
 	ASCIIOnly               bool
+	CharsetEscapes          CharsetEscapes
+	IdentifierCharset       IdentifierCharset
+	IdentifierNormalization Normalization
 	KeepNames               bool
 	IgnoreDCEAnnotations    bool
+	InferPureFunctions      bool
 	TreeShaking             bool
+	TreeShakingMembers      bool
+
+	// Calls to methods on the global "console" object and "debugger" statements
+	// are removed. The arguments of removed calls are not evaluated.
+	DropConsole  bool
+	DropDebugger bool
+
+	// Comments containing "@name" for one of these names are preserved and are
+	// reported in the metafile
+	CustomPragmas []string
+
+	// These control whether "/* @__PURE__ */" annotations and the CommonJS
+	// export annotation for node are generated, and whether magic comments
+	// in "import()" expressions (e.g. "webpackChunkName") are passed through.
+	// The per-format annotation setting overrides the general one.
+	Annotations       Annotations
+	FormatAnnotations map[Format]Annotations
+	MagicComments     Annotations
+
+	// Statement-level comments matching this are printed where they are, even
+	// when minifying
+	PreserveComments *regexp.Regexp
+
+	// Property names matching "MangleProps" but not "ReserveProps" are renamed.
+	// The new names are picked by the bundler after all files have been parsed
+	// and are stored in "MangledPropNames" for the printer.
+	MangleProps      *regexp.Regexp
+	ReserveProps     *regexp.Regexp
+	MangledPropNames map[string]string
+
+	// If present, mangled properties are given the same names that they had in
+	// a previous build, and the names from this build are written to the mangle
+	// cache file afterward. Builds that share a mangle cache never give two
+	// different properties the same name.
+	MangleCache        map[string]string
+	AbsMangleCacheFile string
+
+	// Code that's only meant for testing or development is removed before it's
+	// parsed. This removes "if" statements whose condition is one of these
+	// dot-separated names (e.g. "import.meta.vitest") except for any "else"
+	// branch. It also removes the code between comments containing each pair
+	// of markers (e.g. "/* test:start */" and "/* test:end */").
+	StripIf      [][]string
+	StripBetween []StripMarkers
+
+	// If enabled, the parser tries to recover from syntax errors in top-level
+	// statements so that several of them can be reported for a single file. It
+	// gives up after this many syntax errors in one file. Zero means there is
+	// no limit.
+	RecoverSyntaxErrors bool
+	SyntaxErrorLimit    int
+
+	// If present, minified names are assigned in a pseudo-random order derived
+	// from this seed instead of in order of character frequency
+	MinifySeed string
+
+	// If present, top-level symbols are given the same minified names that
+	// they had in a previous build, and the names from this build are written
+	// to the name cache file afterward
+	NameCache        *NameCache
+	AbsNameCacheFile string
 
 	Defines  *ProcessedDefines
 	TS       TSOptions
@@ -244,12 +582,114 @@ type Options struct {
 	// unsupported feature sets above. It's used for error messages.
 	OriginalTargetEnv string
 
+	// This is the oldest version of node that the output must run in, if one
+	// was configured. There's a warning for each package in "node_modules" with
+	// an "engines" field that requires a newer version.
+	TargetNodeVersion []int
+
 	ExtensionOrder  []string
 	MainFields      []string
 	Conditions      []string
 	AbsNodePaths    []string // The "NODE_PATH" variable from Node.js
+	AbsDenoDir      string   // The "DENO_DIR" variable from Deno
 	ExternalModules ExternalModules
 
+	// If true, all package paths (i.e. import paths that aren't relative or
+	// absolute) are external except for entry points
+	ExternalPackages bool
+
+	// These are sorted so that the most specific rule comes first
+	ExternalRewrites []ExternalRewrite
+
+	// Maps external import paths to the global variable that non-ESM output
+	// formats read them from instead of calling "require()"
+	ExternalGlobals map[string][]string
+
+	// When not bundling, relative import paths are rewritten to point to the
+	// output file that the imported file compiles to (e.g. "./a" => "./a.js")
+	RewriteImports bool
+
+	// When not bundling, files imported by the entry points using relative
+	// paths are compiled too. Each one is written to the output directory at
+	// the same location relative to "outbase" as its input file. This needs
+	// "RewriteImports" so the imports point at the other output files.
+	PreserveModules bool
+
+	// Maps package names to absolute directory paths. These take precedence
+	// over "node_modules" directories when resolving package imports.
+	Workspaces       map[string]string
+	DetectWorkspaces bool
+
+	// Maps package names to the import paths that replace them. Paths that
+	// aren't package paths have already been made absolute.
+	PackageAliases map[string]string
+
+	// Files in these packages are always wrapped in a closure when bundling
+	// instead of being concatenated with the rest of the bundle. This keeps
+	// them from being evaluated before the code that imports them.
+	IsolatedPackages []string
+
+	// JS files matching one of these rules always go in a chunk of their own
+	// when code splitting, no matter which entry points use them. Rules for
+	// manual chunks come first and can put files from many places in one chunk.
+	IsolatedChunks []IsolatedChunk
+
+	// If present, this is called for each JS file when code splitting. It
+	// returns the name of the chunk to put the file in, or an empty string to
+	// let the rules above and automatic splitting decide. This takes precedence
+	// over all of the rules above.
+	ManualChunks func(path logger.Path) string
+
+	// When code splitting, automatic shared chunks smaller than the minimum size
+	// are merged into other chunks and chunks larger than the maximum size are
+	// split into several chunks. Sizes are in bytes and zero means no limit.
+	ChunkMinSize int
+	ChunkMaxSize int
+
+	// When the platform is "browser", some of node's built-in modules and the
+	// "process" and "Buffer" globals are replaced with bundled browser versions
+	NodePolyfills bool
+
+	// ESM files in these packages are also wrapped in a closure when bundling,
+	// but the closure isn't called until one of the file's exports is first
+	// used. This defers the cost of evaluating large packages at startup.
+	LazyPackages []string
+
+	// If true, "require()" and "import()" calls with a path that has a static
+	// relative prefix such as "`./locales/${lang}.json`" bundle all files that
+	// the path could refer to. The bundler sets "ExpandRequireContext" for each
+	// file to find the matching files relative to that file's directory.
+	BundleDynamicPaths   bool
+	ExpandRequireContext func(pattern string) []string
+
+	// Import paths with the wrong case work on case-insensitive file systems
+	// (the default on macOS and Windows) but fail to resolve on case-sensitive
+	// ones (the default on Linux). These are warnings by default and errors if
+	// this is enabled, which catches them before the code is deployed.
+	StrictCase bool
+
+	// These control how directory imports are resolved. If "IndexExtensions" is
+	// present, it's used instead of "ExtensionOrder" to look for "index" files.
+	DirectoryImports DirectoryImports
+	IndexExtensions  []string
+
+	// This generates a JSON file next to each JavaScript output file with the
+	// range of the output file that each input file ended up in
+	ModuleMap bool
+
+	// This generates a ".cjs" file next to each ESM entry point that loads it
+	// with "require()" and annotates its export names for node
+	CJSWrapper bool
+
+	// Maps package names to CSS cascade layer names. CSS files from these
+	// packages are wrapped in the corresponding "@layer" when bundling.
+	CSSLayers map[string]string
+
+	// Warnings are generated for JavaScript output files that exceed any of
+	// these limits. They are rough estimates of how long the output will take
+	// to parse and evaluate on the main thread.
+	OutputBudgets OutputBudgets
+
 	AbsOutputFile      string
 	AbsOutputDir       string
 	AbsOutputBase      string
@@ -264,6 +704,10 @@ type Options struct {
 	InjectedDefines    []InjectedDefine
 	InjectedFiles      []InjectedFile
 
+	// If true, JS entry points that import CSS will add a "<link>" tag for the
+	// generated CSS file to the document when they are run
+	InjectCSSLink bool
+
 	JSBanner  string
 	JSFooter  string
 	CSSBanner string
@@ -275,15 +719,138 @@ type Options struct {
 
 	Plugins []Plugin
 
+	// Custom encoders for the "image" loader, indexed by format name
+	ImageEncoders map[string]func(img image.Image, quality int) ([]byte, error)
+
+	// If present, these are called as the build makes progress. Calls are never
+	// made concurrently, so these don't need to be thread-safe. The discovered
+	// file count grows as more import paths are resolved. When code splitting
+	// is enabled, all entry points are linked together.
+	OnScanProgress func(filesParsed int, filesDiscovered int)
+	OnLinkProgress func(entryPointsLinked int, entryPointsTotal int)
+
 	NeedsMetafile bool
 
+	// If these are present, a precache manifest in Workbox's format and/or a
+	// service worker that precaches all output files will be generated
+	AbsPrecacheManifestFile string
+	AbsServiceWorkerFile    string
+
+	// If present, every output file is named only by its content hash and a
+	// JSON manifest mapping the usual output paths to the hashed paths is
+	// written here
+	AbsContentManifestFile string
+
+	// If present, chunks that aren't entry points are written to this directory
+	// instead of to the output directory. Builds that use the same directory
+	// share identical chunks instead of each emitting their own copy.
+	AbsSharedChunkDir string
+
+	// If present, a report of the hashes needed to allow all generated scripts
+	// using a Content Security Policy will be written to this file
+	AbsCSPReportFile string
+
+	// These are the values of the flags from "--feature:" (which are also
+	// substituted using defines). If present, a report of where each flag was
+	// referenced and how much code it kept or removed is written to this file.
+	FeatureFlags         map[string]bool
+	AbsFeatureReportFile string
+
+	// If present, a report of where each input file ended up in each CSS output
+	// file and why will be written to this file
+	AbsCSSOrderReportFile string
+
+	// If present, esbuild's own helpers (e.g. "__publicField") are imported
+	// from this module instead of being included in every output file
+	ExternalHelpers string
+
 	SourceMap             SourceMap
 	SourceRoot            string
 	ExcludeSourcesContent bool
 
+	// If true, the banner and footer are mapped to the virtual sources
+	// "<banner>" and "<footer>" in the source map instead of being unmapped
+	SourceMapBanners bool
+
 	Stdin *StdinInfo
 }
 
+// These escapes are applied in addition to "ASCIIOnly". They exist for
+// embedded JavaScript engines that only have partial Unicode support.
+type CharsetEscapes struct {
+	// Escape code points outside of the Basic Multilingual Plane. Some engines
+	// use UCS-2 internally and mishandle raw 4-byte UTF-8 sequences.
+	NonBMP bool
+
+	// Escape code points that belong to any of these Unicode scripts
+	Scripts []*unicode.RangeTable
+}
+
+func (escapes CharsetEscapes) IsEmpty() bool {
+	return !escapes.NonBMP && len(escapes.Scripts) == 0
+}
+
+func (escapes CharsetEscapes) ShouldEscape(c rune) bool {
+	if c < 0x80 {
+		return false
+	}
+	if escapes.NonBMP && c > 0xFFFF {
+		return true
+	}
+	for _, script := range escapes.Scripts {
+		if unicode.Is(script, c) {
+			return true
+		}
+	}
+	return false
+}
+
+type IdentifierCharset uint8
+
+const (
+	// Identifiers are escaped the same way as strings
+	IdentifierCharsetSameAsStrings IdentifierCharset = iota
+
+	// Non-ASCII characters in identifiers are always escaped using "\u"
+	IdentifierCharsetASCII
+
+	// Identifiers are never escaped. This is for engines that can parse UTF-8
+	// identifiers but that don't support "\u" escapes inside identifiers.
+	IdentifierCharsetUTF8
+)
+
+// Returns true if this code point must be escaped inside an identifier. The
+// parser uses this to report identifiers that can't be printed for the target
+// and the printer uses this to escape them, so they must always agree.
+func (charset IdentifierCharset) MustEscape(c rune, asciiOnly bool, escapes CharsetEscapes) bool {
+	switch charset {
+	case IdentifierCharsetASCII:
+		return c > 0x7F
+	case IdentifierCharsetUTF8:
+		return false
+	default:
+		return (asciiOnly && c > 0x7F) || escapes.ShouldEscape(c)
+	}
+}
+
+type Normalization uint8
+
+const (
+	NormalizationNone Normalization = iota
+	NormalizationNFC
+	NormalizationNFD
+)
+
+func (n Normalization) String() string {
+	switch n {
+	case NormalizationNFC:
+		return "NFC"
+	case NormalizationNFD:
+		return "NFD"
+	}
+	return ""
+}
+
 type TargetFromAPI uint8
 
 const (
@@ -437,10 +1004,26 @@ func SubstituteTemplate(template []PathTemplate, placeholders PathPlaceholders)
 	return result
 }
 
+// The per-format setting takes precedence over the general one
+func (options *Options) AnnotationsForFormat(format Format) Annotations {
+	if annotations, ok := options.FormatAnnotations[format]; ok {
+		return annotations
+	}
+	return options.Annotations
+}
+
 func ShouldCallRuntimeRequire(mode Mode, outputFormat Format) bool {
 	return mode == ModeBundle && outputFormat != FormatCommonJS
 }
 
+// Browsers that don't support "import()" don't have "require()" either, so
+// external "import()" expressions can be lowered to a script tag loader
+// instead. This is opt-in because scripts loaded this way can't export
+// anything.
+func ShouldCallRuntimeLoadScript(mode Mode, outputFormat Format, loader DynamicImportLoader) bool {
+	return loader == DynamicImportLoaderScript && ShouldCallRuntimeRequire(mode, outputFormat)
+}
+
 type InjectedDefine struct {
 	Source logger.Source
 	Data   js_ast.E
@@ -517,10 +1100,12 @@ func PluginAppliesToPath(path logger.Path, filter *regexp.Regexp, namespace stri
 // Plugin API
 
 type Plugin struct {
-	Name      string
-	OnStart   []OnStart
-	OnResolve []OnResolve
-	OnLoad    []OnLoad
+	Name        string
+	OnStart     []OnStart
+	OnResolve   []OnResolve
+	OnLoad      []OnLoad
+	OnTransform []OnTransform
+	OnSourceMap []OnSourceMap
 }
 
 type OnStart struct {
@@ -583,9 +1168,77 @@ type OnLoadResult struct {
 	Loader        Loader
 	PluginData    interface{}
 
+	// This can be used instead of "Contents" to load the contents from a file.
+	// The file is only read if the loader needs the contents, so files using
+	// the "file" loader are copied to the output directory without ever being
+	// held in memory.
+	AbsContentsFile string
+
 	Msgs        []logger.Msg
 	ThrownError error
 
 	AbsWatchFiles []string
 	AbsWatchDirs  []string
 }
+
+type OnTransform struct {
+	Name      string
+	Filter    *regexp.Regexp
+	Namespace string
+	Callback  func(OnTransformArgs) OnTransformResult
+}
+
+type OnTransformArgs struct {
+	Path       logger.Path
+	PluginData interface{}
+	Loader     Loader
+	Contents   string
+
+	// This is the source map returned by the previous transform callback, if
+	// any. A callback that returns new contents should also return a source
+	// map that maps the new contents all the way back to the original file.
+	SourceMap *string
+}
+
+type OnTransformResult struct {
+	PluginName string
+
+	Contents  *string
+	SourceMap *string
+
+	Msgs        []logger.Msg
+	ThrownError error
+
+	AbsWatchFiles []string
+	AbsWatchDirs  []string
+}
+
+type OnSourceMap struct {
+	Name     string
+	Filter   *regexp.Regexp
+	Callback func(OnSourceMapArgs) OnSourceMapResult
+}
+
+type OnSourceMapArgs struct {
+	// This is the absolute path of the output file that the source map is for.
+	// The filter is matched against this path.
+	Path string
+
+	// This is the absolute path of the source map file, or empty if the source
+	// map is only inlined into the output file
+	SourceMapPath string
+
+	// This is the JSON text of the source map returned by the previous source
+	// map callback, or generated by esbuild if there isn't one
+	SourceMap string
+}
+
+type OnSourceMapResult struct {
+	PluginName string
+
+	// This must be the JSON text of a source map, or nil to leave it unchanged
+	SourceMap *string
+
+	Msgs        []logger.Msg
+	ThrownError error
+}
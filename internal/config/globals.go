@@ -845,6 +845,12 @@ type DefineData struct {
 	// example, a bare call to "Object()" can be removed because it does not
 	// have any observable side effects.
 	CallCanBeUnwrappedIfUnused bool
+
+	// If present, this define is the feature flag with this name. References to
+	// feature flags are recorded for the feature flag report, and "if"
+	// statements and "?:" expressions that test them have their dead branches
+	// removed even when not minifying.
+	FeatureFlag string
 }
 
 func mergeDefineData(old DefineData, new DefineData) DefineData {
@@ -865,6 +871,7 @@ type DotDefine struct {
 type ProcessedDefines struct {
 	IdentifierDefines map[string]DefineData
 	DotDefines        map[string][]DotDefine
+	HasFeatureFlags   bool
 }
 
 // This transformation is expensive, so we only want to do it once. Make sure
@@ -918,6 +925,9 @@ func ProcessDefines(userDefines map[string]DefineData) ProcessedDefines {
 	// any known globals above.
 	for key, data := range userDefines {
 		parts := strings.Split(key, ".")
+		if data.FeatureFlag != "" {
+			result.HasFeatureFlags = true
+		}
 
 		// Identifier defines are special-cased
 		if len(parts) == 1 {
@@ -2,6 +2,7 @@ package css_ast
 
 import (
 	"strconv"
+	"strings"
 
 	"github.com/evanw/esbuild/internal/ast"
 	"github.com/evanw/esbuild/internal/css_lexer"
@@ -28,6 +29,33 @@ type AST struct {
 	Rules                []Rule
 	SourceMapComment     logger.Span
 	ApproximateLineCount int32
+
+	// This is only present for files parsed with the "local-css" loader. It
+	// contains each class name that is local to this file in the order that
+	// it first appears.
+	LocalNames []LocalName
+}
+
+type LocalName struct {
+	Name     string
+	Composes []ComposedName
+}
+
+// This is one of the names from a "composes" declaration:
+//
+//   .a { composes: b }                     => local name "b" in this file
+//   .a { composes: b from "./other.css" }  => local name "b" in another file
+//   .a { composes: b from global }         => global name "b"
+//
+type ComposedName struct {
+	Name  string
+	Range logger.Range
+
+	// If valid, this name is a local name in the file that this import
+	// record points to instead of a local name in this file
+	ImportRecordIndex ast.Index32
+
+	IsGlobal bool
 }
 
 // We create a lot of tokens, so make sure this layout is memory-efficient.
@@ -256,6 +284,52 @@ func CloneTokensWithImportRecords(
 	return tokensOut, importRecordsOut
 }
 
+// This returns the cascade layer for the conditions of an "@import" rule if
+// they start with "layer" or "layer(name)" along with the remaining conditions.
+// The name is empty for the anonymous layer created by a bare "layer" keyword.
+func ImportConditionsLayer(conditions []Token) (name string, rest []Token, ok bool) {
+	if len(conditions) == 0 {
+		return "", nil, false
+	}
+	switch t := conditions[0]; t.Kind {
+	case css_lexer.TIdent:
+		if strings.EqualFold(t.Text, "layer") {
+			return "", conditions[1:], true
+		}
+
+	case css_lexer.TFunction:
+		// The name must alternate between identifiers and "." without whitespace
+		if strings.EqualFold(t.Text, "layer") && t.Children != nil && len(*t.Children)%2 == 1 {
+			children := *t.Children
+			for i, child := range children {
+				if (i%2 == 0 && child.Kind != css_lexer.TIdent) || (i%2 == 1 && child.Kind != css_lexer.TDelimDot) ||
+					(i > 0 && (child.Whitespace&WhitespaceBefore) != 0) ||
+					(i+1 < len(children) && (child.Whitespace&WhitespaceAfter) != 0) {
+					return "", nil, false
+				}
+				name += child.Text
+			}
+			return name, conditions[1:], true
+		}
+	}
+	return "", nil, false
+}
+
+// This is the inverse of "ImportConditionsLayer"
+func LayerToken(name string) Token {
+	if name == "" {
+		return Token{Kind: css_lexer.TIdent, Text: "layer"}
+	}
+	var children []Token
+	for i, part := range strings.Split(name, ".") {
+		if i > 0 {
+			children = append(children, Token{Kind: css_lexer.TDelimDot, Text: "."})
+		}
+		children = append(children, Token{Kind: css_lexer.TIdent, Text: part})
+	}
+	return Token{Kind: css_lexer.TFunction, Text: "layer", Children: &children}
+}
+
 type Rule struct {
 	Loc  logger.Loc
 	Data R
@@ -366,6 +440,36 @@ func (r *RAtKeyframes) Hash() (uint32, bool) {
 	return hash, true
 }
 
+// This is either the statement form "@layer a, b;" or the block form
+// "@layer a { ... }". Anonymous layers use the block form without a name.
+type RAtLayer struct {
+	// Each name is a dot-separated list of identifiers (e.g. "a.b")
+	Names []string
+
+	// This is nil for the statement form
+	Rules []Rule
+}
+
+func (a *RAtLayer) Equal(rule R) bool {
+	b, ok := rule.(*RAtLayer)
+	if ok && len(a.Names) == len(b.Names) && (a.Rules == nil) == (b.Rules == nil) {
+		for i, name := range a.Names {
+			if name != b.Names[i] {
+				return false
+			}
+		}
+		return RulesEqual(a.Rules, b.Rules)
+	}
+	return false
+}
+
+// Layer rules are never considered duplicates of each other. The first
+// mention of a layer name determines the order of that layer, so removing all
+// but the last copy of a duplicate layer rule could reorder the layers.
+func (r *RAtLayer) Hash() (uint32, bool) {
+	return 0, false
+}
+
 type RKnownAt struct {
 	AtToken string
 	Prelude []Token
@@ -593,11 +697,15 @@ func (ss *SSHash) Hash() uint32 {
 
 type SSClass struct {
 	Name string
+
+	// Local names are renamed when printed. This is only ever true for files
+	// parsed with the "local-css" loader.
+	IsLocal bool
 }
 
 func (a *SSClass) Equal(ss SS) bool {
 	b, ok := ss.(*SSClass)
-	return ok && a.Name == b.Name
+	return ok && a.Name == b.Name && a.IsLocal == b.IsLocal
 }
 
 func (ss *SSClass) Hash() uint32 {
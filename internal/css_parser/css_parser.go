@@ -27,26 +27,27 @@ type parser struct {
 	legalCommentIndex int
 	prevError         logger.Loc
 	importRecords     []ast.ImportRecord
+
+	// These are only used with the "local-css" loader
+	localNames       []css_ast.LocalName
+	localNameIndices map[string]int
+	composesTarget   int
+	makeLocal        bool
 }
 
 type Options struct {
 	UnsupportedCSSFeatures compat.CSSFeature
 	MangleSyntax           bool
 	RemoveWhitespace       bool
+
+	// This is used for CSS modules. Class names are local to the file by
+	// default and must be wrapped in ":global(...)" to be global instead.
+	LocalCSS bool
 }
 
 func Parse(log logger.Log, source logger.Source, options Options) css_ast.AST {
 	result := css_lexer.Tokenize(log, source)
-	p := parser{
-		log:           log,
-		source:        source,
-		tracker:       logger.MakeLineColumnTracker(&source),
-		options:       options,
-		tokens:        result.Tokens,
-		legalComments: result.LegalComments,
-		prevError:     logger.Loc{Start: -1},
-	}
-	p.end = len(p.tokens)
+	p := newParser(log, source, options, result)
 	rules := p.parseListOfRules(ruleContext{
 		isTopLevel:     true,
 		parseSelectors: true,
@@ -57,9 +58,43 @@ func Parse(log logger.Log, source logger.Source, options Options) css_ast.AST {
 		ImportRecords:        p.importRecords,
 		ApproximateLineCount: result.ApproximateLineCount,
 		SourceMapComment:     result.SourceMapComment,
+		LocalNames:           p.localNames,
 	}
 }
 
+// This parses a list of declarations without a surrounding rule, such as the
+// contents of a "style" attribute in HTML. The declarations are stored as the
+// top-level rules of the returned AST.
+func ParseDeclarationList(log logger.Log, source logger.Source, options Options) css_ast.AST {
+	result := css_lexer.Tokenize(log, source)
+	p := newParser(log, source, options, result)
+	rules := p.parseListOfDeclarations()
+	p.expect(css_lexer.TEndOfFile)
+	return css_ast.AST{
+		Rules:                rules,
+		ImportRecords:        p.importRecords,
+		ApproximateLineCount: result.ApproximateLineCount,
+		SourceMapComment:     result.SourceMapComment,
+	}
+}
+
+func newParser(log logger.Log, source logger.Source, options Options, result css_lexer.TokenizeResult) parser {
+	p := parser{
+		log:           log,
+		source:        source,
+		tracker:       logger.MakeLineColumnTracker(&source),
+		options:       options,
+		tokens:        result.Tokens,
+		legalComments: result.LegalComments,
+		prevError:     logger.Loc{Start: -1},
+
+		composesTarget: -1,
+		makeLocal:      options.LocalCSS,
+	}
+	p.end = len(p.tokens)
+	return p
+}
+
 func (p *parser) advance() {
 	if p.index < p.end {
 		p.index++
@@ -227,6 +262,9 @@ loop:
 					if !didWarnAboutImport {
 					importLoop:
 						for i, before := range rules {
+							if layer, ok := before.Data.(*css_ast.RAtLayer); ok && layer.Rules == nil {
+								continue // "@layer" statements are allowed to come before "@import"
+							}
 							switch before.Data.(type) {
 							case *css_ast.RComment, *css_ast.RAtCharset, *css_ast.RAtImport:
 							default:
@@ -293,7 +331,9 @@ func (p *parser) parseListOfDeclarations() (list []css_ast.Rule) {
 			list = append(list, p.parseSelectorRule())
 
 		default:
-			list = append(list, p.parseDeclaration())
+			if rule := p.parseDeclaration(); rule.Data != nil {
+				list = append(list, rule)
+			}
 		}
 	}
 }
@@ -672,7 +712,11 @@ func (p *parser) parseAtRule(context atRuleContext) css_ast.Rule {
 
 			// Insert or remove whitespace before the first token
 			if len(importConditions) > 0 {
-				kind = ast.ImportAtConditional
+				// Assigning the imported file to a cascade layer is supported when
+				// bundling, so it doesn't count as a condition
+				if _, rest, ok := css_ast.ImportConditionsLayer(importConditions); !ok || len(rest) > 0 {
+					kind = ast.ImportAtConditional
+				}
 				if p.options.RemoveWhitespace {
 					importConditions[0].Whitespace &= ^css_ast.WhitespaceBefore
 				} else {
@@ -693,6 +737,35 @@ func (p *parser) parseAtRule(context atRuleContext) css_ast.Rule {
 			}}
 		}
 
+	case "layer":
+		// Reference: https://drafts.csswg.org/css-cascade-5/#layering
+		names, ok := p.parseLayerNames()
+		if !ok {
+			break
+		}
+
+		// "@layer a, b;"
+		if len(names) > 0 && p.eat(css_lexer.TSemicolon) {
+			return css_ast.Rule{Loc: atRange.Loc, Data: &css_ast.RAtLayer{Names: names}}
+		}
+
+		// "@layer a { ... }" or "@layer { ... }"
+		if len(names) <= 1 && p.eat(css_lexer.TOpenBrace) {
+			var rules []css_ast.Rule
+			if context.isDeclarationList {
+				rules = p.parseListOfDeclarations()
+			} else {
+				rules = p.parseListOfRules(ruleContext{
+					parseSelectors: true,
+				})
+			}
+			p.expect(css_lexer.TCloseBrace)
+			if rules == nil {
+				rules = []css_ast.Rule{}
+			}
+			return css_ast.Rule{Loc: atRange.Loc, Data: &css_ast.RAtLayer{Names: names, Rules: rules}}
+		}
+
 	case "keyframes", "-webkit-keyframes", "-moz-keyframes", "-ms-keyframes", "-o-keyframes":
 		p.eat(css_lexer.TWhitespace)
 		var name string
@@ -876,6 +949,34 @@ prelude:
 	}
 }
 
+// Layer names are dot-separated identifiers without whitespace (e.g. "a.b").
+// This returns no names for the block form of an anonymous layer.
+func (p *parser) parseLayerNames() (names []string, ok bool) {
+	for {
+		p.eat(css_lexer.TWhitespace)
+		if len(names) == 0 && p.peek(css_lexer.TOpenBrace) {
+			return nil, true
+		}
+		if !p.peek(css_lexer.TIdent) {
+			return nil, false
+		}
+		name := p.decoded()
+		p.advance()
+		for p.eat(css_lexer.TDelimDot) {
+			if !p.peek(css_lexer.TIdent) {
+				return nil, false
+			}
+			name += "." + p.decoded()
+			p.advance()
+		}
+		names = append(names, name)
+		p.eat(css_lexer.TWhitespace)
+		if !p.eat(css_lexer.TComma) {
+			return names, true
+		}
+	}
+}
+
 func (p *parser) convertTokens(tokens []css_lexer.Token) []css_ast.Token {
 	result, _ := p.convertTokensHelper(tokens, css_lexer.TEndOfFile, convertTokensOpts{})
 	return result
@@ -1007,6 +1108,26 @@ loop:
 				})
 			}
 
+			// Each option in "image-set()" can be a string instead of a URL, which
+			// is also a URL. Treat these just like URL tokens so that the files
+			// they reference are included in the bundle too.
+			if token.Text == "image-set" || token.Text == "-webkit-image-set" {
+				ranges := topLevelStringRanges(original[1:])
+				for i := range nested {
+					if t := &nested[i]; t.Kind == css_lexer.TString && (i == 0 || nested[i-1].Kind == css_lexer.TComma) {
+						t.Kind = css_lexer.TURL
+						t.ImportRecordIndex = uint32(len(p.importRecords))
+						p.importRecords = append(p.importRecords, ast.ImportRecord{
+							Kind:     ast.ImportURL,
+							Path:     logger.Path{Text: t.Text},
+							Range:    ranges[i],
+							IsUnused: !opts.allowImports,
+						})
+						t.Text = ""
+					}
+				}
+			}
+
 		case css_lexer.TOpenParen:
 			var nested []css_ast.Token
 			nested, tokens = p.convertTokensHelper(tokens, css_lexer.TCloseParen, opts)
@@ -1083,6 +1204,39 @@ loop:
 	return result, tokens
 }
 
+// This returns the range of each string token that isn't nested inside a
+// block, indexed by the position of the token among the converted tokens
+func topLevelStringRanges(tokens []css_lexer.Token) map[int]logger.Range {
+	ranges := make(map[int]logger.Range)
+	depth := 0
+	index := 0
+	for _, t := range tokens {
+		switch t.Kind {
+		case css_lexer.TWhitespace:
+			continue
+		case css_lexer.TFunction, css_lexer.TOpenParen, css_lexer.TOpenBracket, css_lexer.TOpenBrace:
+			depth++
+			if depth == 1 {
+				index++
+			}
+			continue
+		case css_lexer.TCloseParen, css_lexer.TCloseBracket, css_lexer.TCloseBrace:
+			depth--
+			if depth < 0 {
+				return ranges
+			}
+			continue
+		}
+		if depth == 0 {
+			if t.Kind == css_lexer.TString {
+				ranges[index] = t.Range
+			}
+			index++
+		}
+	}
+	return ranges
+}
+
 func shiftDot(text string, dotOffset int) (string, bool) {
 	// This doesn't handle numbers with exponents
 	if strings.ContainsAny(text, "eE") {
@@ -1190,7 +1344,10 @@ func (p *parser) parseSelectorRule() css_ast.Rule {
 	if list, ok := p.parseSelectorList(); ok {
 		selector := css_ast.RSelector{Selectors: list}
 		if p.expect(css_lexer.TOpenBrace) {
+			oldComposesTarget := p.composesTarget
+			p.composesTarget = p.composesTargetForSelectors(list)
 			selector.Rules = p.parseListOfDeclarations()
+			p.composesTarget = oldComposesTarget
 			p.expect(css_lexer.TCloseBrace)
 			return css_ast.Rule{Loc: p.tokens[preludeStart].Range.Loc, Data: &selector}
 		}
@@ -1281,6 +1438,12 @@ stop:
 	keyToken := p.tokens[keyStart]
 	keyText := keyToken.DecodedText(p.source.Contents)
 	value := p.tokens[valueStart:p.index]
+
+	// The "composes" declaration from CSS modules is removed from the output
+	if p.options.LocalCSS && keyText == "composes" {
+		p.parseComposes(keyToken.Range, value)
+		return css_ast.Rule{}
+	}
 	verbatimWhitespace := strings.HasPrefix(keyText, "--")
 
 	// Remove trailing "!important"
@@ -0,0 +1,157 @@
+package css_parser
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/ast"
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This file implements the parts of CSS modules that need the parser. Local
+// names are only recorded here. They are renamed by the bundler once the
+// local names of all files are known, since the new names must not collide.
+
+func (p *parser) recordLocalName(name string) {
+	if _, ok := p.localNameIndices[name]; ok {
+		return
+	}
+	if p.localNameIndices == nil {
+		p.localNameIndices = make(map[string]int)
+	}
+	p.localNameIndices[name] = len(p.localNames)
+	p.localNames = append(p.localNames, css_ast.LocalName{Name: name})
+}
+
+// This handles ":global(.a)" and ":local(.a)", which change whether the
+// class names inside them are local. The compound selector inside is merged
+// into the compound selector that contains it.
+func (p *parser) parseLocalOrGlobalSelector(sel *css_ast.CompoundSelector, isLocal bool) bool {
+	p.advance()
+	p.advance()
+	p.eat(css_lexer.TWhitespace)
+
+	oldMakeLocal := p.makeLocal
+	p.makeLocal = isLocal
+	inner, ok := p.parseCompoundSelector()
+	p.makeLocal = oldMakeLocal
+	if !ok {
+		return false
+	}
+
+	p.eat(css_lexer.TWhitespace)
+	if !p.expect(css_lexer.TCloseParen) {
+		return false
+	}
+
+	if inner.TypeSelector != nil {
+		if sel.TypeSelector != nil || len(sel.SubclassSelectors) > 0 {
+			p.log.Add(logger.Warning, &p.tracker, p.at(p.index-1).Range,
+				"Type selectors inside \":global(...)\" and \":local(...)\" must come first")
+			return false
+		}
+		sel.TypeSelector = inner.TypeSelector
+	}
+	sel.HasNestPrefix = sel.HasNestPrefix || inner.HasNestPrefix
+	sel.SubclassSelectors = append(sel.SubclassSelectors, inner.SubclassSelectors...)
+	return true
+}
+
+// A "composes" declaration is only allowed inside a rule for a single local
+// class name. This returns the index of that name or -1 if there isn't one.
+func (p *parser) composesTargetForSelectors(list []css_ast.ComplexSelector) int {
+	if !p.options.LocalCSS || len(list) != 1 || len(list[0].Selectors) != 1 {
+		return -1
+	}
+	sel := list[0].Selectors[0]
+	if sel.HasNestPrefix || sel.TypeSelector != nil || len(sel.SubclassSelectors) != 1 {
+		return -1
+	}
+	if class, ok := sel.SubclassSelectors[0].(*css_ast.SSClass); ok && class.IsLocal {
+		if index, ok := p.localNameIndices[class.Name]; ok {
+			return index
+		}
+	}
+	return -1
+}
+
+// This parses the value of a "composes" declaration:
+//
+//   composes: a b;
+//   composes: a b from "./other.css";
+//   composes: a b from global;
+//
+func (p *parser) parseComposes(keyRange logger.Range, tokens []css_lexer.Token) {
+	if p.composesTarget == -1 {
+		p.log.Add(logger.Warning, &p.tracker, keyRange,
+			"\"composes\" only works inside a rule for a single local class name")
+		return
+	}
+
+	// Whitespace doesn't matter here
+	values := make([]css_lexer.Token, 0, len(tokens))
+	for _, t := range tokens {
+		if t.Kind != css_lexer.TWhitespace {
+			values = append(values, t)
+		}
+	}
+
+	var names []css_ast.ComposedName
+	for len(values) > 0 && values[0].Kind == css_lexer.TIdent {
+		name := values[0].DecodedText(p.source.Contents)
+		if name == "from" && len(names) > 0 {
+			break
+		}
+		names = append(names, css_ast.ComposedName{Name: name, Range: values[0].Range})
+		values = values[1:]
+	}
+	if len(names) == 0 {
+		p.composesWarning(keyRange, values, "Expected identifier")
+		return
+	}
+
+	if len(values) > 0 && values[0].Kind == css_lexer.TIdent {
+		fromRange := values[0].Range
+		values = values[1:]
+		if len(values) > 0 && values[0].Kind == css_lexer.TString {
+			importRecordIndex := ast.MakeIndex32(uint32(len(p.importRecords)))
+			p.importRecords = append(p.importRecords, ast.ImportRecord{
+				Kind:  ast.ImportComposesFrom,
+				Path:  logger.Path{Text: values[0].DecodedText(p.source.Contents)},
+				Range: values[0].Range,
+			})
+			for i := range names {
+				names[i].ImportRecordIndex = importRecordIndex
+			}
+			values = values[1:]
+		} else if len(values) > 0 && values[0].Kind == css_lexer.TIdent && values[0].DecodedText(p.source.Contents) == "global" {
+			for i := range names {
+				names[i].IsGlobal = true
+			}
+			values = values[1:]
+		} else {
+			p.composesWarning(fromRange, values, "Expected string or \"global\"")
+			return
+		}
+	}
+
+	if len(values) > 0 {
+		p.composesWarning(keyRange, values, "Expected \";\"")
+		return
+	}
+
+	local := &p.localNames[p.composesTarget]
+	local.Composes = append(local.Composes, names...)
+}
+
+func (p *parser) composesWarning(before logger.Range, values []css_lexer.Token, expected string) {
+	if len(values) == 0 {
+		p.log.Add(logger.Warning, &p.tracker, logger.Range{Loc: logger.Loc{Start: before.End()}},
+			fmt.Sprintf("%s after %q", expected, p.source.TextForRange(before)))
+	} else {
+		t := values[0]
+		p.log.Add(logger.Warning, &p.tracker, t.Range,
+			fmt.Sprintf("%s but found %q", expected, p.source.TextForRange(t.Range)))
+	}
+}
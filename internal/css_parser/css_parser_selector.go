@@ -117,8 +117,10 @@ subclassSelectors:
 		case css_lexer.TDelimDot:
 			p.advance()
 			name := p.decoded()
-			sel.SubclassSelectors = append(sel.SubclassSelectors, &css_ast.SSClass{Name: name})
-			p.expect(css_lexer.TIdent)
+			sel.SubclassSelectors = append(sel.SubclassSelectors, &css_ast.SSClass{Name: name, IsLocal: p.makeLocal})
+			if p.expect(css_lexer.TIdent) && p.makeLocal {
+				p.recordLocalName(name)
+			}
 
 		case css_lexer.TOpenBracket:
 			p.advance()
@@ -129,6 +131,14 @@ subclassSelectors:
 			sel.SubclassSelectors = append(sel.SubclassSelectors, &attr)
 
 		case css_lexer.TColon:
+			if p.options.LocalCSS && p.next().Kind == css_lexer.TFunction {
+				if text := p.next().DecodedText(p.source.Contents); text == "global" || text == "local" {
+					if !p.parseLocalOrGlobalSelector(&sel, text == "local") {
+						return
+					}
+					continue
+				}
+			}
 			if p.next().Kind == css_lexer.TColon {
 				// Special-case the start of the pseudo-element selector section
 				for p.current().Kind == css_lexer.TColon {
@@ -51,6 +51,27 @@ func expectPrintedCommon(t *testing.T, name string, contents string, expected st
 	})
 }
 
+func expectPrintedDeclarationList(t *testing.T, contents string, expected string, options config.Options) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		tree := ParseDeclarationList(log, test.SourceForTest(contents), Options{
+			MangleSyntax:     options.MangleSyntax,
+			RemoveWhitespace: options.RemoveWhitespace,
+		})
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		result := css_printer.Print(tree, css_printer.Options{
+			RemoveWhitespace: options.RemoveWhitespace,
+		})
+		test.AssertEqualWithDiff(t, text+string(result.CSS), expected)
+	})
+}
+
 func expectPrinted(t *testing.T, contents string, expected string) {
 	t.Helper()
 	expectPrintedCommon(t, contents, contents, expected, config.Options{})
@@ -86,6 +107,41 @@ func expectPrintedMangleMinify(t *testing.T, contents string, expected string) {
 	})
 }
 
+// Local names are printed with a "local_" prefix and the "composes" lists are
+// printed after the CSS so they can be checked too
+func expectPrintedLocal(t *testing.T, contents string, expected string, expectedLog string) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		tree := Parse(log, test.SourceForTest(contents), Options{LocalCSS: true})
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqualWithDiff(t, text, expectedLog)
+		localNames := make(map[string]string)
+		for _, local := range tree.LocalNames {
+			localNames[local.Name] = "local_" + local.Name
+		}
+		result := css_printer.Print(tree, css_printer.Options{LocalNames: localNames})
+		output := string(result.CSS)
+		for _, local := range tree.LocalNames {
+			for _, composed := range local.Composes {
+				output += fmt.Sprintf("/* %s composes %s", local.Name, composed.Name)
+				if composed.IsGlobal {
+					output += " from global"
+				} else if composed.ImportRecordIndex.IsValid() {
+					output += fmt.Sprintf(" from %q", tree.ImportRecords[composed.ImportRecordIndex.GetIndex()].Path.Text)
+				}
+				output += " */\n"
+			}
+		}
+		test.AssertEqualWithDiff(t, output, expected)
+	})
+}
+
 func TestEscapes(t *testing.T) {
 	// TIdent
 	expectPrinted(t, "a { value: id\\65nt }", "a {\n  value: ident;\n}\n")
@@ -245,6 +301,15 @@ func TestEscapes(t *testing.T) {
 	expectPrinted(t, "[\\2a|attr] {}", "[\\*|attr] {\n}\n")
 }
 
+func TestImageSet(t *testing.T) {
+	expectPrinted(t, "a { b: image-set(\"a.png\" 1x, 'b.png' 2x) }", "a {\n  b: image-set(url(a.png) 1x, url(b.png) 2x);\n}\n")
+	expectPrinted(t, "a { b: -webkit-image-set(\"a.png\" 1x, url(b.png) 2x) }", "a {\n  b: -webkit-image-set(url(a.png) 1x, url(b.png) 2x);\n}\n")
+	expectPrinted(t, "a { b: image-set(\"a.png\" type(\"image/png\")) }", "a {\n  b: image-set(url(a.png) type(\"image/png\"));\n}\n")
+	expectPrinted(t, "a { b: image-set(linear-gradient(red, blue) 1x) }", "a {\n  b: image-set(linear-gradient(red, blue) 1x);\n}\n")
+	expectPrinted(t, "a { b: c(\"a.png\" 1x) }", "a {\n  b: c(\"a.png\" 1x);\n}\n")
+	expectPrintedMangleMinify(t, "a { b: image-set(\"a.png\" 1x, \"b.png\" 2x) }", "a{b:image-set(url(a.png) 1x,url(b.png) 2x)}")
+}
+
 func TestString(t *testing.T) {
 	expectPrinted(t, "a:after { content: 'a\\\rb' }", "a:after {\n  content: \"ab\";\n}\n")
 	expectPrinted(t, "a:after { content: 'a\\\nb' }", "a:after {\n  content: \"ab\";\n}\n")
@@ -824,6 +889,8 @@ func TestAtImport(t *testing.T) {
 	expectPrinted(t, "@import url(\"foo.css\");", "@import \"foo.css\";\n")
 	expectPrinted(t, "@import url(\"foo.css\") ;", "@import \"foo.css\";\n")
 	expectPrinted(t, "@import url(\"foo.css\") print;", "@import \"foo.css\" print;\n")
+	expectPrinted(t, "@import url(\"foo.css\") layer;", "@import \"foo.css\" layer;\n")
+	expectPrinted(t, "@import url(\"foo.css\") layer(a.b);", "@import \"foo.css\" layer(a.b);\n")
 	expectPrinted(t, "@import url(\"foo.css\") screen and (orientation:landscape);", "@import \"foo.css\" screen and (orientation:landscape);\n")
 
 	expectParseError(t, "@import;", "<stdin>: WARNING: Expected URL token but found \";\"\n")
@@ -898,6 +965,25 @@ func TestAtKeyframes(t *testing.T) {
 	expectParseError(t, "@keyframes name { 1%,,2% {} }", "<stdin>: WARNING: Expected percentage but found \",\"\n")
 }
 
+func TestAtLayer(t *testing.T) {
+	expectPrinted(t, "@layer a;", "@layer a;\n")
+	expectPrinted(t, "@layer a,b.c ;", "@layer a, b.c;\n")
+	expectPrinted(t, "@layer a{}", "@layer a {\n}\n")
+	expectPrinted(t, "@layer a.b { c { color: red } }", "@layer a.b {\n  c {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer { c { color: red } }", "@layer {\n  c {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer a { @layer b { c { color: red } } }", "@layer a {\n  @layer b {\n    c {\n      color: red;\n    }\n  }\n}\n")
+	expectPrinted(t, "a { @layer b { color: red } }", "a {\n  @layer b {\n    color: red;\n  }\n}\n")
+
+	// Invalid layer rules are passed through unmodified
+	expectPrinted(t, "@layer a .b;", "@layer a .b;\n")
+	expectPrinted(t, "@layer a, b {}", "@layer a, b {}\n")
+	expectPrinted(t, "@layer;", "@layer;\n")
+
+	// Empty layers still affect the layer order, and duplicate layers may not be
+	// the first mention of that layer
+	expectPrintedMangle(t, "@layer a {} @layer b; @layer a {}", "@layer a {\n}\n@layer b;\n@layer a {\n}\n")
+}
+
 func TestAtRuleValidation(t *testing.T) {
 	expectParseError(t, "a {} @charset \"UTF-8\";",
 		"<stdin>: WARNING: \"@charset\" must be the first rule in the file\n"+
@@ -906,6 +992,11 @@ func TestAtRuleValidation(t *testing.T) {
 	expectParseError(t, "a {} @import \"foo\";",
 		"<stdin>: WARNING: All \"@import\" rules must come first\n"+
 			"<stdin>: NOTE: This rule cannot come before an \"@import\" rule\n")
+
+	expectParseError(t, "@layer a; @import \"foo\";", "")
+	expectParseError(t, "@layer a {} @import \"foo\";",
+		"<stdin>: WARNING: All \"@import\" rules must come first\n"+
+			"<stdin>: NOTE: This rule cannot come before an \"@import\" rule\n")
 }
 
 func TestEmptyRule(t *testing.T) {
@@ -1601,3 +1692,44 @@ func TestFont(t *testing.T) {
 	expectPrintedMangleMinify(t, "a { font: italic small-caps bold ultra-condensed 1rem/1.2 'aaa bbb' }", "a{font:italic small-caps 700 ultra-condensed 1rem/1.2 aaa bbb}")
 	expectPrintedMangleMinify(t, "a { font: italic small-caps bold ultra-condensed 1rem / 1.2 'aaa bbb' }", "a{font:italic small-caps 700 ultra-condensed 1rem/1.2 aaa bbb}")
 }
+
+func TestDeclarationList(t *testing.T) {
+	expectPrintedDeclarationList(t, "", "", config.Options{})
+	expectPrintedDeclarationList(t, "color: red", "color: red;\n", config.Options{})
+	expectPrintedDeclarationList(t, "color: red;; margin: 0 auto !important;", "color: red;\nmargin: 0 auto !important;\n", config.Options{})
+	expectPrintedDeclarationList(t, "color: #ff0000; margin: 0px", "color: red;\nmargin: 0;\n", config.Options{MangleSyntax: true})
+	expectPrintedDeclarationList(t, "color: red; margin: 0 auto", "color:red;margin:0 auto", config.Options{RemoveWhitespace: true})
+	expectPrintedDeclarationList(t, "a { color: red }", "<stdin>: WARNING: Expected \":\"\na { color: red };\n", config.Options{})
+	expectPrintedDeclarationList(t, "color: red }", "<stdin>: WARNING: Expected end of file but found \"}\"\ncolor: red;\n", config.Options{})
+}
+
+func TestLocalCSS(t *testing.T) {
+	expectPrintedLocal(t, ".a {}", ".local_a {\n}\n", "")
+	expectPrintedLocal(t, ".a.b, div .c:hover {}", ".local_a.local_b,\ndiv .local_c:hover {\n}\n", "")
+	expectPrintedLocal(t, ":global(.a) .b {}", ".a .local_b {\n}\n", "")
+	expectPrintedLocal(t, ":global(div.a).b {}", "div.a.local_b {\n}\n", "")
+	expectPrintedLocal(t, ":global(.a:local(.b)) {}", ".a.local_b {\n}\n", "")
+	expectPrintedLocal(t, ":hover:global(div) {}", ":hover:global(div) {\n}\n",
+		"<stdin>: WARNING: Type selectors inside \":global(...)\" and \":local(...)\" must come first\n")
+	expectPrintedLocal(t, "#a:not(.b) {}", "#a:not(.b) {\n}\n", "")
+
+	expectPrintedLocal(t, ".a { composes: b c; color: red } .b {} .c {}",
+		".local_a {\n  color: red;\n}\n.local_b {\n}\n.local_c {\n}\n/* a composes b */\n/* a composes c */\n", "")
+	expectPrintedLocal(t, ".a { composes: b from \"./b.css\"; composes: c from global }",
+		".local_a {\n}\n/* a composes b from \"./b.css\" */\n/* a composes c from global */\n", "")
+	expectPrintedLocal(t, ".a .b { composes: c }", ".local_a .local_b {\n}\n",
+		"<stdin>: WARNING: \"composes\" only works inside a rule for a single local class name\n")
+	expectPrintedLocal(t, ":global(.a) { composes: c }", ".a {\n}\n",
+		"<stdin>: WARNING: \"composes\" only works inside a rule for a single local class name\n")
+	expectPrintedLocal(t, ".a { composes: }", ".local_a {\n}\n",
+		"<stdin>: WARNING: Expected identifier after \"composes\"\n")
+	expectPrintedLocal(t, ".a { composes: b from }", ".local_a {\n}\n",
+		"<stdin>: WARNING: Expected string or \"global\" after \"from\"\n")
+	expectPrintedLocal(t, ".a { composes: b from url(b.css) }", ".local_a {\n}\n",
+		"<stdin>: WARNING: Expected string or \"global\" but found \"url(b.css)\"\n")
+	expectPrintedLocal(t, ".a { composes: b, c }", ".local_a {\n}\n",
+		"<stdin>: WARNING: Expected \";\" but found \",\"\n")
+
+	// Class names are only local with the "local-css" loader
+	expectPrinted(t, ":global(.a) .b { composes: c }", ":global(.a) .b {\n  composes: c;\n}\n")
+}
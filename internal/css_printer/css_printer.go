@@ -26,8 +26,10 @@ type printer struct {
 type Options struct {
 	RemoveWhitespace  bool
 	ASCIIOnly         bool
+	CharsetEscapes    config.CharsetEscapes
 	AddSourceMappings bool
 	LegalComments     config.LegalComments
+	IndentUnit        string // Defaults to two spaces if empty
 
 	// If we're writing out a source map, this table of line start indices lets
 	// us do binary search on to figure out what line a given AST node came from
@@ -36,6 +38,10 @@ type Options struct {
 	// This will be present if the input file had a source map. In that case we
 	// want to map all the way back to the original input file(s).
 	InputSourceMap *sourcemap.SourceMap
+
+	// This maps local class names from the "local-css" loader to the names
+	// they should be printed as
+	LocalNames map[string]string
 }
 
 type PrintResult struct {
@@ -50,8 +56,11 @@ func Print(tree css_ast.AST, options Options) PrintResult {
 		importRecords: tree.ImportRecords,
 		builder:       sourcemap.MakeChunkBuilder(options.InputSourceMap, options.LineOffsetTables),
 	}
-	for _, rule := range tree.Rules {
-		p.printRule(rule, 0, false)
+	for i, rule := range tree.Rules {
+		// Top-level declarations come from "ParseDeclarationList" and are
+		// separated by semicolons just like declarations inside a rule
+		omitTrailingSemicolon := options.RemoveWhitespace && i+1 == len(tree.Rules)
+		p.printRule(rule, 0, omitTrailingSemicolon)
 	}
 	return PrintResult{
 		CSS:                    p.css,
@@ -150,6 +159,32 @@ func (p *printer) printRule(rule css_ast.Rule, indent int32, omitTrailingSemicol
 		}
 		p.print("}")
 
+	case *css_ast.RAtLayer:
+		p.print("@layer")
+		for i, name := range r.Names {
+			if i == 0 {
+				p.print(" ")
+			} else if p.options.RemoveWhitespace {
+				p.print(",")
+			} else {
+				p.print(", ")
+			}
+			for j, part := range strings.Split(name, ".") {
+				if j > 0 {
+					p.print(".")
+				}
+				p.printIdent(part, identNormal, canDiscardWhitespaceAfter)
+			}
+		}
+		if r.Rules == nil {
+			p.print(";")
+		} else {
+			if !p.options.RemoveWhitespace {
+				p.print(" ")
+			}
+			p.printRuleBlock(r.Rules, indent)
+		}
+
 	case *css_ast.RKnownAt:
 		p.print("@")
 		whitespace := mayNeedWhitespaceAfter
@@ -338,8 +373,14 @@ func (p *printer) printCompoundSelector(sel css_ast.CompoundSelector, isFirst bo
 			p.printIdent(s.Name, identNormal, whitespace)
 
 		case *css_ast.SSClass:
+			name := s.Name
+			if s.IsLocal {
+				if local, ok := p.options.LocalNames[name]; ok {
+					name = local
+				}
+			}
 			p.print(".")
-			p.printIdent(s.Name, identNormal, whitespace)
+			p.printIdent(name, identNormal, whitespace)
 
 		case *css_ast.SSAttribute:
 			p.print("[")
@@ -542,7 +583,7 @@ func (p *printer) printQuotedWithQuote(text string, quote byte) {
 			}
 
 		default:
-			if (p.options.ASCIIOnly && c >= 0x80) || c == '\uFEFF' {
+			if p.mustEscape(c) || c == '\uFEFF' {
 				escape = escapeHex
 			}
 		}
@@ -555,6 +596,10 @@ func (p *printer) printQuotedWithQuote(text string, quote byte) {
 	}
 }
 
+func (p *printer) mustEscape(c rune) bool {
+	return (p.options.ASCIIOnly && c >= 0x80) || p.options.CharsetEscapes.ShouldEscape(c)
+}
+
 type identMode uint8
 
 const (
@@ -574,7 +619,7 @@ func (p *printer) printIdent(text string, mode identMode, whitespace trailingWhi
 	for i, c := range text {
 		escape := escapeNone
 
-		if p.options.ASCIIOnly && c >= 0x80 {
+		if p.mustEscape(c) {
 			escape = escapeHex
 		} else if c == '\r' || c == '\n' || c == '\f' || c == '\uFEFF' {
 			// Use a hexadecimal escape for characters that would be invalid escapes
@@ -621,8 +666,12 @@ func (p *printer) printIdent(text string, mode identMode, whitespace trailingWhi
 }
 
 func (p *printer) printIndent(indent int32) {
+	indentUnit := p.options.IndentUnit
+	if indentUnit == "" {
+		indentUnit = "  "
+	}
 	for i, n := 0, int(indent); i < n; i++ {
-		p.css = append(p.css, "  "...)
+		p.css = append(p.css, indentUnit...)
 	}
 }
 
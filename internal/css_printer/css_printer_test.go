@@ -299,6 +299,16 @@ func TestAtRule(t *testing.T) {
 	expectPrintedMinify(t, "@unknown x ( a , b ) ;", "@unknown x (a,b);")
 }
 
+func TestAtLayer(t *testing.T) {
+	expectPrinted(t, "@layer a, b.c;", "@layer a, b.c;\n")
+	expectPrinted(t, "@layer a { b { color: red } }", "@layer a {\n  b {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer { b { color: red } }", "@layer {\n  b {\n    color: red;\n  }\n}\n")
+	expectPrinted(t, "@layer \\31 a {}", "@layer \\31 a {\n}\n")
+	expectPrintedMinify(t, "@layer a, b.c;", "@layer a,b.c;")
+	expectPrintedMinify(t, "@layer a { b { color: red } }", "@layer a{b{color:red}}")
+	expectPrintedMinify(t, "@layer { b { color: red } }", "@layer{b{color:red}}")
+}
+
 func TestAtCharset(t *testing.T) {
 	expectPrinted(t, "@charset \"UTF-8\";", "@charset \"UTF-8\";\n")
 	expectPrintedMinify(t, "@charset \"UTF-8\";", "@charset \"UTF-8\";")
@@ -354,6 +364,17 @@ func TestMsGridColumnsWhitespace(t *testing.T) {
 	expectPrintedMinify(t, "div { -ms-grid-columns: 1fr (20px 1fr)[3] }", "div{-ms-grid-columns:1fr (20px 1fr)[3]}")
 }
 
+func TestIndentUnit(t *testing.T) {
+	tab := Options{IndentUnit: "\t"}
+	expectPrintedCommon(t, "a { color: red } [tab]", "a { color: red }", "a {\n\tcolor: red;\n}\n", tab)
+	expectPrintedCommon(t, "@media screen { a { color: red } } [tab]", "@media screen { a { color: red } }",
+		"@media screen {\n\ta {\n\t\tcolor: red;\n\t}\n}\n", tab)
+
+	fourSpaces := Options{IndentUnit: "    "}
+	expectPrintedCommon(t, "@media screen { a { color: red } } [4 spaces]", "@media screen { a { color: red } }",
+		"@media screen {\n    a {\n        color: red;\n    }\n}\n", fourSpaces)
+}
+
 func TestASCII(t *testing.T) {
 	expectPrintedASCII(t, "* { background: url(🐈) }", "* {\n  background: url(\\1f408);\n}\n")
 	expectPrintedASCII(t, "* { background: url(🐈6) }", "* {\n  background: url(\\1f408 6);\n}\n")
@@ -111,6 +111,34 @@ func (entries DirEntries) Get(query string) (*Entry, *DifferentCase) {
 	return nil, nil
 }
 
+func actualCasingBelowDir(fs FS, root string, path string) (string, bool) {
+	dir := fs.Dir(path)
+	if dir == path || isSameOrAncestorIgnoringCase(dir, root) {
+		return path, true
+	}
+	actualDir, ok := actualCasingBelowDir(fs, root, dir)
+	if !ok {
+		return "", false
+	}
+	entries, err, _ := fs.ReadDirectory(dir)
+	if err != nil {
+		return "", false
+	}
+	entry, _ := entries.Get(fs.Base(path))
+	if entry == nil {
+		return "", false
+	}
+	return fs.Join(actualDir, entry.base), true
+}
+
+func isSameOrAncestorIgnoringCase(dir string, path string) bool {
+	if len(dir) > len(path) || !strings.EqualFold(dir, path[:len(dir)]) {
+		return false
+	}
+	return len(dir) == len(path) || path[len(dir)] == '/' || path[len(dir)] == '\\' ||
+		strings.HasSuffix(dir, "/") || strings.HasSuffix(dir, "\\")
+}
+
 func (entries DirEntries) SortedKeys() (keys []string) {
 	if entries.data != nil {
 		keys = make([]string, 0, len(entries.data))
@@ -186,6 +214,13 @@ type FS interface {
 	Cwd() string
 	Rel(base string, target string) (string, bool)
 
+	// Returns the path with each component below "root" changed to the casing
+	// it has on disk. Paths with the wrong casing work on case-insensitive file
+	// systems but break on case-sensitive ones. The components of "root" itself
+	// aren't checked since they don't come from an import path. This returns
+	// false if any of the checked components doesn't exist.
+	ActualCasingBelowDir(root string, path string) (string, bool)
+
 	// This is used in the implementation of "Entry"
 	kind(dir string, base string) (symlink string, kind EntryKind)
 
@@ -195,11 +230,36 @@ type FS interface {
 }
 
 type WatchData struct {
-	// These functions return a non-empty path as a string if the file system
+	// These functions return a change with a non-empty path if the file system
 	// entry has been modified. For files, the returned path is the same as the
 	// file path. For directories, the returned path is either the directory
-	// itself or a file in the directory that was changed.
-	Paths map[string]func() string
+	// itself or a file in the directory that was added or deleted.
+	Paths map[string]func() WatchChange
+
+	// This says how each path in "Paths" was accessed. It's used to tell build
+	// systems about the exact set of inputs that a build depended on.
+	Accesses map[string]AccessKind
+}
+
+type AccessKind uint8
+
+const (
+	AccessFile AccessKind = iota
+	AccessDirectory
+	AccessMissing
+)
+
+type WatchChangeKind uint8
+
+const (
+	WatchChangeModified WatchChangeKind = iota
+	WatchChangeAdded
+	WatchChangeDeleted
+)
+
+type WatchChange struct {
+	Path string
+	Kind WatchChangeKind
 }
 
 type ModKey struct {
@@ -7,6 +7,7 @@ package fs
 import (
 	"errors"
 	"path"
+	"sort"
 	"strings"
 	"syscall"
 )
@@ -20,8 +21,16 @@ func MockFS(input map[string]string) FS {
 	dirs := make(map[string]DirEntries)
 	files := make(map[string]string)
 
-	for k, v := range input {
-		files[k] = v
+	// Paths that differ only in case share a directory entry, so build the
+	// directory map in a consistent order to make the shared entry consistent
+	keys := make([]string, 0, len(input))
+	for k := range input {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		files[k] = input[k]
 		original := k
 
 		// Build the directory map
@@ -148,6 +157,10 @@ func (*mockFS) Rel(base string, target string) (string, bool) {
 	return commonParent + target, true
 }
 
+func (fs *mockFS) ActualCasingBelowDir(root string, path string) (string, bool) {
+	return actualCasingBelowDir(fs, root, path)
+}
+
 func (fs *mockFS) kind(dir string, base string) (symlink string, kind EntryKind) {
 	panic("This should never be called")
 }
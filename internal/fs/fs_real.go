@@ -327,6 +327,10 @@ func (fs *realFS) Rel(base string, target string) (string, bool) {
 	return "", false
 }
 
+func (fs *realFS) ActualCasingBelowDir(root string, path string) (string, bool) {
+	return actualCasingBelowDir(fs, root, path)
+}
+
 func (fs *realFS) readdir(dirname string) (entries []string, canonicalError error, originalError error) {
 	BeforeFileOpen()
 	defer AfterFileClose()
@@ -433,7 +437,8 @@ func (fs *realFS) kind(dir string, base string) (symlink string, kind EntryKind)
 }
 
 func (fs *realFS) WatchData() WatchData {
-	paths := make(map[string]func() string)
+	paths := make(map[string]func() WatchChange)
+	accesses := make(map[string]AccessKind)
 
 	for path, data := range fs.watchData {
 		// Each closure below needs its own copy of these loop variables
@@ -453,33 +458,49 @@ func (fs *realFS) WatchData() WatchData {
 			}
 		}
 
+		switch data.state {
+		case stateDirMissing, stateFileMissing:
+			accesses[path] = AccessMissing
+		case stateDirHasAccessedEntries:
+			accesses[path] = AccessDirectory
+		default:
+			accesses[path] = AccessFile
+		}
+
 		switch data.state {
 		case stateDirMissing:
-			paths[path] = func() string {
+			paths[path] = func() WatchChange {
 				info, err := os.Stat(path)
 				if err == nil && info.IsDir() {
-					return path
+					return WatchChange{Path: path, Kind: WatchChangeAdded}
 				}
-				return ""
+				return WatchChange{}
 			}
 
 		case stateDirHasAccessedEntries:
-			paths[path] = func() string {
+			paths[path] = func() WatchChange {
 				names, err, _ := fs.readdir(path)
 				if err != nil {
-					return path
+					return WatchChange{Path: path, Kind: WatchChangeDeleted}
 				}
 				data.accessedEntries.mutex.Lock()
 				defer data.accessedEntries.mutex.Unlock()
 				if allEntries := data.accessedEntries.allEntries; allEntries != nil {
 					// Check all entries
-					if len(names) != len(allEntries) {
-						return path
-					}
 					sort.Strings(names)
-					for i, s := range names {
-						if s != allEntries[i] {
-							return path
+					wasPresent := make(map[string]bool, len(allEntries))
+					for _, name := range allEntries {
+						wasPresent[name] = true
+					}
+					for _, name := range names {
+						if !wasPresent[name] {
+							return WatchChange{Path: fs.Join(path, name), Kind: WatchChangeAdded}
+						}
+						delete(wasPresent, name)
+					}
+					for _, name := range allEntries {
+						if wasPresent[name] {
+							return WatchChange{Path: fs.Join(path, name), Kind: WatchChangeDeleted}
 						}
 					}
 				} else {
@@ -490,40 +511,58 @@ func (fs *realFS) WatchData() WatchData {
 					}
 					for name, wasPresent := range data.accessedEntries.wasPresent {
 						if wasPresent != isPresent[name] {
-							return fs.Join(path, name)
+							kind := WatchChangeAdded
+							if wasPresent {
+								kind = WatchChangeDeleted
+							}
+							return WatchChange{Path: fs.Join(path, name), Kind: kind}
 						}
 					}
 				}
-				return ""
+				return WatchChange{}
 			}
 
 		case stateFileMissing:
-			paths[path] = func() string {
+			paths[path] = func() WatchChange {
 				if info, err := os.Stat(path); err == nil && !info.IsDir() {
-					return path
+					return WatchChange{Path: path, Kind: WatchChangeAdded}
 				}
-				return ""
+				return WatchChange{}
 			}
 
 		case stateFileHasModKey:
-			paths[path] = func() string {
-				if key, err := modKey(path); err != nil || key != data.modKey {
-					return path
+			paths[path] = func() WatchChange {
+				if key, err := modKey(path); err != nil {
+					return fileChangeFromError(path, err)
+				} else if key != data.modKey {
+					return WatchChange{Path: path, Kind: WatchChangeModified}
 				}
-				return ""
+				return WatchChange{}
 			}
 
 		case stateFileUnusableModKey:
-			paths[path] = func() string {
-				if buffer, err := ioutil.ReadFile(path); err != nil || string(buffer) != data.fileContents {
-					return path
+			paths[path] = func() WatchChange {
+				if buffer, err := ioutil.ReadFile(path); err != nil {
+					return fileChangeFromError(path, err)
+				} else if string(buffer) != data.fileContents {
+					return WatchChange{Path: path, Kind: WatchChangeModified}
 				}
-				return ""
+				return WatchChange{}
 			}
 		}
 	}
 
 	return WatchData{
-		Paths: paths,
+		Paths:    paths,
+		Accesses: accesses,
+	}
+}
+
+// A file that can no longer be read has either been deleted or has become
+// unreadable for some other reason, which counts as a modification
+func fileChangeFromError(path string, err error) WatchChange {
+	if os.IsNotExist(err) {
+		return WatchChange{Path: path, Kind: WatchChangeDeleted}
 	}
+	return WatchChange{Path: path, Kind: WatchChangeModified}
 }
@@ -0,0 +1,229 @@
+package fs
+
+// This records every file system access made during a build so that the build
+// can be replayed later without the original files. Replaying answers each
+// query with the recorded result instead of going to the real file system, so
+// the build sees exactly the same files, directories, and symlinks as before.
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+type Recording struct {
+	Cwd         string                  `json:"cwd"`
+	Files       map[string]string       `json:"files"`
+	Directories map[string][]string     `json:"directories"`
+	Kinds       map[string]RecordedKind `json:"kinds,omitempty"`
+}
+
+type RecordedKind struct {
+	Symlink string    `json:"symlink,omitempty"`
+	Kind    EntryKind `json:"kind"`
+}
+
+type recordingFS struct {
+	FS
+	mutex     sync.Mutex
+	recording Recording
+}
+
+func RecordingFS(inner FS) FS {
+	return &recordingFS{
+		FS: inner,
+		recording: Recording{
+			Cwd:         inner.Cwd(),
+			Files:       make(map[string]string),
+			Directories: make(map[string][]string),
+			Kinds:       make(map[string]RecordedKind),
+		},
+	}
+}
+
+// Returns a copy of everything that has been recorded so far, or false if
+// this file system isn't recording
+func RecordingOf(fs FS) (Recording, bool) {
+	r, ok := fs.(*recordingFS)
+	if !ok {
+		return Recording{}, false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	result := Recording{
+		Cwd:         r.recording.Cwd,
+		Files:       make(map[string]string, len(r.recording.Files)),
+		Directories: make(map[string][]string, len(r.recording.Directories)),
+		Kinds:       make(map[string]RecordedKind, len(r.recording.Kinds)),
+	}
+	for k, v := range r.recording.Files {
+		result.Files[k] = v
+	}
+	for k, v := range r.recording.Directories {
+		result.Directories[k] = v
+	}
+	for k, v := range r.recording.Kinds {
+		result.Kinds[k] = v
+	}
+	return result, true
+}
+
+func (r *recordingFS) ReadDirectory(path string) (DirEntries, error, error) {
+	entries, canonicalError, originalError := r.FS.ReadDirectory(path)
+	if canonicalError == nil {
+		// Don't use "SortedKeys" since that affects how watch mode tracks changes
+		names := make([]string, 0, len(entries.data))
+		for _, entry := range entries.data {
+			names = append(names, entry.base)
+		}
+		sort.Strings(names)
+		r.mutex.Lock()
+		r.recording.Directories[path] = names
+		r.mutex.Unlock()
+	}
+	return entries, canonicalError, originalError
+}
+
+func (r *recordingFS) ReadFile(path string) (string, error, error) {
+	contents, canonicalError, originalError := r.FS.ReadFile(path)
+	if canonicalError == nil {
+		r.mutex.Lock()
+		r.recording.Files[path] = contents
+		r.mutex.Unlock()
+	}
+	return contents, canonicalError, originalError
+}
+
+func (r *recordingFS) OpenFile(path string) (OpenedFile, error, error) {
+	file, canonicalError, originalError := r.FS.OpenFile(path)
+	if canonicalError == nil {
+		// Read the whole file since replaying needs the contents
+		bytes, err := file.Read(0, file.Len())
+		if err != nil {
+			file.Close()
+			return nil, err, err
+		}
+		r.mutex.Lock()
+		r.recording.Files[path] = string(bytes)
+		r.mutex.Unlock()
+	}
+	return file, canonicalError, originalError
+}
+
+func (r *recordingFS) ActualCasingBelowDir(root string, path string) (string, bool) {
+	return actualCasingBelowDir(r, root, path)
+}
+
+func (r *recordingFS) kind(dir string, base string) (string, EntryKind) {
+	symlink, kind := r.FS.kind(dir, base)
+	r.mutex.Lock()
+	r.recording.Kinds[r.FS.Join(dir, base)] = RecordedKind{Symlink: symlink, Kind: kind}
+	r.mutex.Unlock()
+	return symlink, kind
+}
+
+type replayFS struct {
+	// This is only used for path manipulation, which depends on the platform
+	paths     FS
+	recording Recording
+}
+
+// The real file system is only used for manipulating paths. All file system
+// queries are answered from the recording.
+func ReplayFS(recording Recording) (FS, error) {
+	paths, err := RealFS(RealFSOptions{AbsWorkingDir: recording.Cwd})
+	if err != nil {
+		return nil, err
+	}
+	return &replayFS{paths: paths, recording: recording}, nil
+}
+
+func (r *replayFS) ReadDirectory(path string) (DirEntries, error, error) {
+	names, ok := r.recording.Directories[path]
+	if !ok {
+		return DirEntries{}, syscall.ENOENT, syscall.ENOENT
+	}
+	entries := MakeEmptyDirEntries(path)
+	for _, name := range names {
+		entries.data[strings.ToLower(name)] = &Entry{dir: path, base: name, needStat: true}
+	}
+	return entries, nil, nil
+}
+
+func (r *replayFS) ReadFile(path string) (string, error, error) {
+	if contents, ok := r.recording.Files[path]; ok {
+		return contents, nil, nil
+	}
+	return "", syscall.ENOENT, syscall.ENOENT
+}
+
+func (r *replayFS) OpenFile(path string) (OpenedFile, error, error) {
+	if contents, ok := r.recording.Files[path]; ok {
+		return &InMemoryOpenedFile{Contents: []byte(contents)}, nil, nil
+	}
+	return nil, syscall.ENOENT, syscall.ENOENT
+}
+
+func (r *replayFS) ModKey(path string) (ModKey, error) {
+	return ModKey{}, modKeyUnusable
+}
+
+func (r *replayFS) IsAbs(path string) bool {
+	return r.paths.IsAbs(path)
+}
+
+func (r *replayFS) Abs(path string) (string, bool) {
+	return r.paths.Abs(path)
+}
+
+func (r *replayFS) Dir(path string) string {
+	return r.paths.Dir(path)
+}
+
+func (r *replayFS) Base(path string) string {
+	return r.paths.Base(path)
+}
+
+func (r *replayFS) Ext(path string) string {
+	return r.paths.Ext(path)
+}
+
+func (r *replayFS) Join(parts ...string) string {
+	return r.paths.Join(parts...)
+}
+
+func (r *replayFS) Cwd() string {
+	return r.recording.Cwd
+}
+
+func (r *replayFS) Rel(base string, target string) (string, bool) {
+	return r.paths.Rel(base, target)
+}
+
+func (r *replayFS) ActualCasingBelowDir(root string, path string) (string, bool) {
+	return actualCasingBelowDir(r, root, path)
+}
+
+func (r *replayFS) kind(dir string, base string) (string, EntryKind) {
+	path := r.Join(dir, base)
+	if kind, ok := r.recording.Kinds[path]; ok {
+		return kind.Symlink, kind.Kind
+	}
+	if _, ok := r.recording.Directories[path]; ok {
+		return "", DirEntry
+	}
+	return "", FileEntry
+}
+
+func (r *replayFS) WatchData() WatchData {
+	// Replayed builds don't watch anything, but they still know what was read
+	accesses := make(map[string]AccessKind, len(r.recording.Files)+len(r.recording.Directories))
+	for path := range r.recording.Files {
+		accesses[path] = AccessFile
+	}
+	for path := range r.recording.Directories {
+		accesses[path] = AccessDirectory
+	}
+	return WatchData{Paths: make(map[string]func() WatchChange), Accesses: accesses}
+}
@@ -0,0 +1,59 @@
+package fs
+
+import (
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	if CheckIfWindows() {
+		t.Skip("The mock file system uses Unix-style paths")
+	}
+
+	fs := RecordingFS(MockFS(map[string]string{
+		"/package.json": "// package.json",
+		"/src/index.js": "// src/index.js",
+		"/src/util.js":  "// src/util.js",
+	}))
+
+	// Only what was read is recorded
+	if _, err, _ := fs.ReadFile("/src/index.js"); err != nil {
+		t.Fatal("Expected to find /src/index.js")
+	}
+	if _, err, _ := fs.ReadDirectory("/src"); err != nil {
+		t.Fatal("Expected to find /src")
+	}
+
+	recording, ok := RecordingOf(fs)
+	if !ok {
+		t.Fatal("Expected a recording")
+	}
+	replay, err := ReplayFS(recording)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	index, err, _ := replay.ReadFile("/src/index.js")
+	if err != nil {
+		t.Fatal("Expected to find /src/index.js")
+	}
+	if index != "// src/index.js" {
+		t.Fatalf("Incorrect contents for /src/index.js: %q", index)
+	}
+
+	// Files that were never read aren't available
+	if _, err, _ := replay.ReadFile("/package.json"); err == nil {
+		t.Fatal("Unexpectedly found /package.json")
+	}
+
+	// Directory listings include entries whose contents were never read
+	src, err, _ := replay.ReadDirectory("/src")
+	if err != nil {
+		t.Fatal("Expected to find /src")
+	}
+	if keys := src.SortedKeys(); len(keys) != 2 || keys[0] != "index.js" || keys[1] != "util.js" {
+		t.Fatalf("Incorrect entries for /src: %v", keys)
+	}
+	if entry, _ := src.Get("util.js"); entry == nil || entry.Kind(replay) != FileEntry {
+		t.Fatal("Expected /src/util.js to be a file")
+	}
+}
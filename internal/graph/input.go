@@ -28,20 +28,104 @@ type InputFile struct {
 	AdditionalFiles        []OutputFile
 	UniqueKeyForFileLoader string
 
+	// If non-empty, the contents of this file were never loaded into memory and
+	// "Source.Contents" is empty. This is only done for the "file" loader since
+	// it copies the contents to the output directory without looking at them.
+	// That way very large files don't need to pass through a Go string.
+	ContentsAbsPath string
+	ContentsLen     int
+
 	SideEffects SideEffects
 	Loader      config.Loader
 }
 
+type OutputKind uint8
+
+const (
+	OutputChunk OutputKind = iota
+	OutputAsset
+	OutputSourceMap
+	OutputLegalComments
+	OutputModuleMap
+	OutputCJSWrapper
+)
+
 type OutputFile struct {
 	AbsPath  string
 	Contents []byte
 
+	// These say where this output file came from for error messages. The input
+	// path is the pretty path of the input file for assets, and of the entry
+	// point for the output of an entry point and the files generated alongside
+	// it (e.g. the source map). It's empty for shared chunks.
+	Kind      OutputKind
+	InputPath string
+
 	// If "AbsMetadataFile" is present, this will be filled out with information
 	// about this file in JSON format. This is a partial JSON file that will be
 	// fully assembled later.
 	JSONMetadataChunk string
 
+	// If non-empty, "Contents" is nil and this output file is a copy of the
+	// file at this path instead. The copy is streamed when it's written.
+	CopyFromAbsPath string
+
+	// If non-empty, this output file is named by its content hash and this is
+	// the path it would have had otherwise. This is used for the content manifest.
+	LogicalAbsPath string
+
 	IsExecutable bool
+
+	// If this file contains the JavaScript code for an entry point, this is the
+	// source index of that entry point
+	JSEntryPointSourceIndex ast.Index32
+
+	// This is true if the file name contains a hash of the file's contents, in
+	// which case a given URL for this file will never have different contents
+	IsHashed bool
+
+	// This is true if this file was reused from the previous build without
+	// being re-linked. Its contents are identical to what was written last time.
+	IsReused bool
+
+	// These are the zero-based lines in this file that the banner and footer
+	// start on, or zero if there isn't a banner or footer
+	BannerLine int
+	FooterLine int
+
+	// If this is a CSS file and there is a CSS order report, this says why
+	// each input file is where it is in this file
+	CSSOrder *CSSOrder
+}
+
+type CSSOrder struct {
+	// These are the input files in the order they are evaluated
+	Files []CSSOrderFile
+
+	// These are the ranges of this file that came from each input file
+	Ranges []CSSOrderRange
+}
+
+type CSSOrderFile struct {
+	Path  string
+	Layer []string
+
+	// This is the file whose "@import" decided where this file goes, or empty
+	// for entry points and for files imported from JavaScript
+	ImportedBy string
+
+	// These are the files whose "@import" of this file was dropped because it's
+	// evaluated again later on
+	OverriddenImportsFrom []string
+}
+
+// Lines and columns are 1-based. The start is inclusive and the end is exclusive.
+type CSSOrderRange struct {
+	Path        string
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
 }
 
 type SideEffects struct {
@@ -73,6 +157,11 @@ const (
 	// unused imports to these files since running the plugin is a side effect.
 	// Removing the import would not call the plugin which is observable.
 	NoSideEffects_PureData_FromPlugin
+
+	// This file is one of the built-in browser versions of node's modules, none
+	// of which have side effects. We don't want to warn about unused imports to
+	// these files since the user didn't choose to mark them this way.
+	NoSideEffects_NodePolyfill
 )
 
 type InputFileRepr interface {
@@ -110,6 +199,10 @@ type CSSRepr struct {
 	// A JavaScript stub is automatically generated for a CSS file when it's
 	// imported from a JavaScript file.
 	JSSourceIndex ast.Index32
+
+	// If this file uses the "local-css" loader, this maps each local class name
+	// in the file to the name it's renamed to, which is unique in the bundle
+	LocalNames map[string]string
 }
 
 func (repr *CSSRepr) ImportRecords() *[]ast.ImportRecord {
@@ -133,6 +133,11 @@ type JSReprMeta struct {
 
 	Wrap WrapKind
 
+	// If true, this file is in one of the lazy packages. ESM files in these
+	// packages are only initialized when one of their exports is first used
+	// instead of when they are imported.
+	IsLazy bool
+
 	// If true, the "__export(exports, { ... })" call will be force-included even
 	// if there are no parts that reference "exports". Otherwise this call will
 	// be removed due to the tree shaking pass. This is used when for entry point
@@ -249,8 +249,19 @@ type LocRef struct {
 }
 
 type Comment struct {
-	Loc  logger.Loc
-	Text string
+	Loc             logger.Loc
+	Text            string
+	HasCustomPragma bool
+
+	// This is true if the comment matches the user's "preserve comments" pattern
+	IsPreserved bool
+}
+
+// This is an occurrence of a user-specified pragma in a comment, such as
+// "/* @generated */" or "/* @license-category: MIT */"
+type Pragma struct {
+	Name  string
+	Value string
 }
 
 type PropertyKind int
@@ -295,6 +306,11 @@ type Property struct {
 	IsStatic        bool
 	WasShorthand    bool
 	PreferQuotedKey bool
+
+	// This is set when the property is preceded by a "@__KEEP__" comment (or
+	// when the enclosing class or object is) and it opts this property out of
+	// member tree shaking
+	HasKeepComment bool
 }
 
 type PropertyBinding struct {
@@ -408,6 +424,7 @@ func (*EClass) isExpr()                {}
 func (*EIdentifier) isExpr()           {}
 func (*EImportIdentifier) isExpr()     {}
 func (*EPrivateIdentifier) isExpr()    {}
+func (*EMangledProp) isExpr()          {}
 func (*EJSXElement) isExpr()           {}
 func (*EMissing) isExpr()              {}
 func (*ENumber) isExpr()               {}
@@ -618,6 +635,14 @@ type EPrivateIdentifier struct {
 	Ref Ref
 }
 
+// This represents a property name that will be renamed because it matches the
+// "--mangle-props" regular expression. It can be used where computed
+// properties can be used, such as EIndex and Property. The new name isn't
+// known until all files have been parsed, so it's filled in by the printer.
+type EMangledProp struct {
+	Name string
+}
+
 type EJSXElement struct {
 	TagOrNil   Expr
 	Properties []Property
@@ -1895,6 +1920,11 @@ type AST struct {
 	NestedScopeSlotCounts SlotCounts
 	HasLazyExport         bool
 
+	// This is true if the file contains a "require()" or "import()" call whose
+	// path was expanded using the file system. The AST depends on more than
+	// just the contents of the file in that case, so it must not be cached.
+	HasRequireContext bool
+
 	// This is a list of CommonJS features. When a file uses CommonJS features,
 	// it's not a candidate for "flat bundling" and must be wrapped in its own
 	// closure. Note that this also includes top-level "return" but these aren't
@@ -1938,9 +1968,66 @@ type AST struct {
 	// call "TopLevelSymbolToParts" instead.
 	TopLevelSymbolToPartsFromParser map[Ref][]uint32
 
+	// These are only filled in when member tree shaking is enabled. The linker
+	// combines the property names used by all files in the bundle and then
+	// omits each removable member whose name is never used.
+	PropertyNamesUsed map[string]bool
+	RemovableMembers  []RemovableMember
+
+	// This is set when member tree shaking is enabled and the file uses a
+	// computed property access, a "for-in" loop, or a reflection API. Members
+	// aren't removed from any file in that case.
+	UsesDynamicPropertyAccess bool
+
+	// These are only filled in when "--mangle-props" is enabled. The bundler
+	// combines these from all files to pick the new property names. Mangled
+	// names that are used more often get shorter names, and names that are
+	// used but not mangled (such as quoted names) are never used as new names.
+	MangledPropCounts map[string]uint32
+	ReservedPropNames map[string]bool
+
+	// This is only filled in when there are feature flags
+	FeatureFlagUses []FeatureFlagUse
+
+	// This contains all user-specified custom pragmas found in comments
+	Pragmas []Pragma
+
+	// This is only filled in when enums don't use the classic form
+	TSEnums TSEnumSummary
+
 	SourceMapComment logger.Span
 }
 
+// This is a member of a top-level class or object literal that never escapes
+// from the file it's declared in. It can be omitted from the output if nothing
+// in the bundle ever accesses a property with this name.
+type RemovableMember struct {
+	Property *Property
+	Name     string
+}
+
+// This counts how the TypeScript enums in a file were emitted. The sizes are
+// estimates of the minified code for all enums in the file, both as classic
+// enums and as they were actually emitted.
+type TSEnumSummary struct {
+	Classic      uint32
+	Frozen       uint32
+	Inlined      uint32
+	ClassicBytes int32
+	ActualBytes  int32
+}
+
+// This is a reference to a feature flag from "--feature:". If the reference
+// is the test of an "if" statement or a "?:" expression with a constant value,
+// the sizes of the branches that were kept and removed are recorded too. The
+// sizes are the number of bytes of source code in each branch.
+type FeatureFlagUse struct {
+	Name          string
+	Loc           logger.Loc
+	KeptBytes     int32
+	StrippedBytes int32
+}
+
 // This is a histogram of character frequencies for minification
 type CharFreq [64]int32
 
@@ -2024,6 +2111,35 @@ func (freq *CharFreq) Compile() NameMinifier {
 	return minifier
 }
 
+// This returns a copy of this minifier with the characters in a pseudo-random
+// order determined by the seed. The same seed always results in the same order
+// on all machines. This undoes the ordering by character frequency so it makes
+// gzip compression slightly worse, but it lets people deliberately change the
+// minified names without changing the code.
+func (minifier NameMinifier) Shuffle(seed uint64) NameMinifier {
+	tail := []byte(minifier.tail)
+
+	// Do a Fisher-Yates shuffle using the "splitmix64" generator, which is
+	// specified here instead of using "math/rand" so it can never change
+	for i := len(tail) - 1; i > 0; i-- {
+		seed += 0x9E3779B97F4A7C15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		z ^= z >> 31
+		j := int(z % uint64(i+1))
+		tail[i], tail[j] = tail[j], tail[i]
+	}
+
+	shuffled := NameMinifier{tail: string(tail)}
+	for _, c := range tail {
+		if c < '0' || c > '9' {
+			shuffled.head += string(c)
+		}
+	}
+	return shuffled
+}
+
 func (minifier *NameMinifier) NumberToMinifiedName(i int) string {
 	j := i % 54
 	name := minifier.head[j : j+1]
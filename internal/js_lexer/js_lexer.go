@@ -15,6 +15,7 @@ package js_lexer
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"unicode"
@@ -213,6 +214,10 @@ var StrictModeReservedWords = map[string]bool{
 type json struct {
 	parse         bool
 	allowComments bool
+
+	// JSON5 allows single-quoted strings, more escape sequences, and unescaped
+	// control characters in strings: https://spec.json5.org/#strings
+	allowJSON5 bool
 }
 
 type Lexer struct {
@@ -231,15 +236,21 @@ type Lexer struct {
 	Token                           T
 	HasNewlineBefore                bool
 	HasPureCommentBefore            bool
+	HasKeepCommentBefore            bool
 	PreserveAllCommentsBefore       bool
 	IsLegacyOctalLiteral            bool
 	PrevTokenWasAwaitKeyword        bool
 	CommentsToPreserveBefore        []js_ast.Comment
 	AllOriginalComments             []js_ast.Comment
+	Pragmas                         []js_ast.Pragma
+	customPragmas                   []string
+	preserveComments                *regexp.Regexp
 	codePoint                       rune
 	Identifier                      string
 	JSXFactoryPragmaComment         logger.Span
 	JSXFragmentPragmaComment        logger.Span
+	JSXRuntimePragmaComment         logger.Span
+	JSXImportSourcePragmaComment    logger.Span
 	SourceMappingURL                logger.Span
 	Number                          float64
 	rescanCloseBraceAsTemplateToken bool
@@ -257,17 +268,32 @@ type Lexer struct {
 
 	// The log is disabled during speculative scans that may backtrack
 	IsLogDisabled bool
+
+	// Errors that don't stop parsing are collected here and logged once parsing
+	// is done. They live in the lexer so that they are discarded along with the
+	// rest of the lexer state when a speculative scan backtracks.
+	DeferredErrors []logger.Msg
 }
 
 type LexerPanic struct{}
 
 func NewLexer(log logger.Log, source logger.Source) Lexer {
+	return NewLexerWithPragmas(log, source, nil, nil)
+}
+
+// Comments containing "@name" where "name" is one of these custom pragmas are
+// recorded in "Pragmas". They are also preserved like legal comments when they
+// are at the statement level. So are comments whose text between the comment
+// delimiters matches "preserveComments".
+func NewLexerWithPragmas(log logger.Log, source logger.Source, customPragmas []string, preserveComments *regexp.Regexp) Lexer {
 	lexer := Lexer{
 		log:               log,
 		source:            source,
 		tracker:           logger.MakeLineColumnTracker(&source),
 		prevErrorLoc:      logger.Loc{Start: -1},
 		FnOrArrowStartLoc: logger.Loc{Start: -1},
+		customPragmas:     customPragmas,
+		preserveComments:  preserveComments,
 	}
 	lexer.step()
 	lexer.Next()
@@ -288,7 +314,7 @@ func NewLexerGlobalName(log logger.Log, source logger.Source) Lexer {
 	return lexer
 }
 
-func NewLexerJSON(log logger.Log, source logger.Source, allowComments bool) Lexer {
+func NewLexerJSON(log logger.Log, source logger.Source, allowComments bool, allowJSON5 bool) Lexer {
 	lexer := Lexer{
 		log:               log,
 		source:            source,
@@ -297,7 +323,8 @@ func NewLexerJSON(log logger.Log, source logger.Source, allowComments bool) Lexe
 		FnOrArrowStartLoc: logger.Loc{Start: -1},
 		json: json{
 			parse:         true,
-			allowComments: allowComments,
+			allowComments: allowComments || allowJSON5,
+			allowJSON5:    allowJSON5,
 		},
 	}
 	lexer.step()
@@ -1134,6 +1161,7 @@ func (lexer *Lexer) NextInsideJSXElement() {
 func (lexer *Lexer) Next() {
 	lexer.HasNewlineBefore = lexer.end == 0
 	lexer.HasPureCommentBefore = false
+	lexer.HasKeepCommentBefore = false
 	lexer.PrevTokenWasAwaitKeyword = false
 	lexer.CommentsToPreserveBefore = nil
 
@@ -1356,8 +1384,10 @@ func (lexer *Lexer) Next() {
 				if lexer.codePoint == '>' && lexer.HasNewlineBefore {
 					lexer.step()
 					lexer.LegacyHTMLCommentRange = lexer.Range()
-					lexer.log.Add(logger.Warning, &lexer.tracker, lexer.Range(),
-						"Treating \"-->\" as the start of a legacy HTML single-line comment")
+					if !lexer.IsLogDisabled {
+						lexer.log.Add(logger.Warning, &lexer.tracker, lexer.Range(),
+							"Treating \"-->\" as the start of a legacy HTML single-line comment")
+					}
 				singleLineHTMLCloseComment:
 					for {
 						switch lexer.codePoint {
@@ -1511,8 +1541,10 @@ func (lexer *Lexer) Next() {
 					lexer.step()
 					lexer.step()
 					lexer.LegacyHTMLCommentRange = lexer.Range()
-					lexer.log.Add(logger.Warning, &lexer.tracker, lexer.Range(),
-						"Treating \"<!--\" as the start of a legacy HTML single-line comment")
+					if !lexer.IsLogDisabled {
+						lexer.log.Add(logger.Warning, &lexer.tracker, lexer.Range(),
+							"Treating \"<!--\" as the start of a legacy HTML single-line comment")
+					}
 				singleLineHTMLOpenComment:
 					for {
 						switch lexer.codePoint {
@@ -1601,7 +1633,7 @@ func (lexer *Lexer) Next() {
 					lexer.step()
 
 					// Handle Windows CRLF
-					if lexer.codePoint == '\r' && !lexer.json.parse {
+					if lexer.codePoint == '\r' && (!lexer.json.parse || lexer.json.allowJSON5) {
 						lexer.step()
 						if lexer.codePoint == '\n' {
 							lexer.step()
@@ -1652,7 +1684,7 @@ func (lexer *Lexer) Next() {
 					// Non-ASCII strings need the slow path
 					if lexer.codePoint >= 0x80 {
 						needsSlowPath = true
-					} else if lexer.json.parse && lexer.codePoint < 0x20 {
+					} else if lexer.json.parse && !lexer.json.allowJSON5 && lexer.codePoint < 0x20 {
 						lexer.SyntaxError()
 					}
 				}
@@ -1676,7 +1708,7 @@ func (lexer *Lexer) Next() {
 				lexer.decodedStringLiteralOrNil = copy
 			}
 
-			if quote == '\'' && lexer.json.parse {
+			if quote == '\'' && lexer.json.parse && !lexer.json.allowJSON5 {
 				lexer.addRangeError(lexer.Range(), "JSON strings must use double quotes")
 			}
 
@@ -2163,10 +2195,12 @@ func (lexer *Lexer) ScanRegExp() {
 						for r1.Loc.Start < r2.Loc.Start && lexer.source.Contents[r1.Loc.Start] != byte(lexer.codePoint) {
 							r1.Loc.Start++
 						}
-						lexer.log.AddWithNotes(logger.Error, &lexer.tracker, r2,
-							fmt.Sprintf("Duplicate flag \"%c\" in regular expression", lexer.codePoint),
-							[]logger.MsgData{lexer.tracker.MsgData(r1,
-								fmt.Sprintf("The first \"%c\" was here:", lexer.codePoint))})
+						if !lexer.IsLogDisabled {
+							lexer.log.AddWithNotes(logger.Error, &lexer.tracker, r2,
+								fmt.Sprintf("Duplicate flag \"%c\" in regular expression", lexer.codePoint),
+								[]logger.MsgData{lexer.tracker.MsgData(r1,
+									fmt.Sprintf("The first \"%c\" was here:", lexer.codePoint))})
+						}
 					} else {
 						bits |= bit
 					}
@@ -2342,7 +2376,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 				continue
 
 			case 'v':
-				if lexer.json.parse {
+				if lexer.json.parse && !lexer.json.allowJSON5 {
 					return nil, false, start + i - width2
 				}
 
@@ -2351,7 +2385,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 
 			case '0', '1', '2', '3', '4', '5', '6', '7':
 				octalStart := i - 2
-				if lexer.json.parse {
+				if lexer.json.parse && (!lexer.json.allowJSON5 || c2 != '0' || (i < len(text) && text[i] >= '0' && text[i] <= '9')) {
 					return nil, false, start + i - width2
 				}
 
@@ -2391,7 +2425,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 				lexer.LegacyOctalLoc = logger.Loc{Start: int32(start + i - 2)}
 
 			case 'x':
-				if lexer.json.parse {
+				if lexer.json.parse && !lexer.json.allowJSON5 {
 					return nil, false, start + i - width2
 				}
 
@@ -2486,7 +2520,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 				c = value
 
 			case '\r':
-				if lexer.json.parse {
+				if lexer.json.parse && !lexer.json.allowJSON5 {
 					return nil, false, start + i - width2
 				}
 
@@ -2498,7 +2532,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 				continue
 
 			case '\n', '\u2028', '\u2029':
-				if lexer.json.parse {
+				if lexer.json.parse && !lexer.json.allowJSON5 {
 					return nil, false, start + i - width2
 				}
 
@@ -2506,7 +2540,7 @@ func (lexer *Lexer) tryToDecodeEscapeSequences(start int, text string, reportErr
 				continue
 
 			default:
-				if lexer.json.parse {
+				if lexer.json.parse && !lexer.json.allowJSON5 {
 					switch c2 {
 					case '"', '\\', '/':
 
@@ -2675,6 +2709,7 @@ func scanForPragmaArg(kind pragmaArg, start int, pragma string, text string) (lo
 func (lexer *Lexer) scanCommentText() {
 	text := lexer.source.Contents[lexer.start:lexer.end]
 	hasLegalAnnotation := len(text) > 2 && text[2] == '!'
+	hasCustomPragma := false
 	isMultiLineComment := text[1] == '*'
 
 	// Save the original comment text so we can subtract comments from the
@@ -2696,6 +2731,8 @@ func (lexer *Lexer) scanCommentText() {
 			rest := text[i+1 : endOfCommentText]
 			if hasPrefixWithWordBoundary(rest, "__PURE__") {
 				lexer.HasPureCommentBefore = true
+			} else if hasPrefixWithWordBoundary(rest, "__KEEP__") {
+				lexer.HasKeepCommentBefore = true
 			} else if i == 2 && strings.HasPrefix(rest, " sourceMappingURL=") {
 				if arg, ok := scanForPragmaArg(pragmaNoSpaceFirst, lexer.start+i+1, " sourceMappingURL=", rest); ok {
 					lexer.SourceMappingURL = arg
@@ -2704,8 +2741,13 @@ func (lexer *Lexer) scanCommentText() {
 
 		case '@':
 			rest := text[i+1 : endOfCommentText]
-			if hasPrefixWithWordBoundary(rest, "__PURE__") {
+			if pragma, ok := lexer.scanForCustomPragma(rest); ok {
+				lexer.Pragmas = append(lexer.Pragmas, pragma)
+				hasCustomPragma = true
+			} else if hasPrefixWithWordBoundary(rest, "__PURE__") {
 				lexer.HasPureCommentBefore = true
+			} else if hasPrefixWithWordBoundary(rest, "__KEEP__") {
+				lexer.HasKeepCommentBefore = true
 			} else if hasPrefixWithWordBoundary(rest, "preserve") || hasPrefixWithWordBoundary(rest, "license") {
 				hasLegalAnnotation = true
 			} else if hasPrefixWithWordBoundary(rest, "jsx") {
@@ -2716,6 +2758,14 @@ func (lexer *Lexer) scanCommentText() {
 				if arg, ok := scanForPragmaArg(pragmaSkipSpaceFirst, lexer.start+i+1, "jsxFrag", rest); ok {
 					lexer.JSXFragmentPragmaComment = arg
 				}
+			} else if hasPrefixWithWordBoundary(rest, "jsxRuntime") {
+				if arg, ok := scanForPragmaArg(pragmaSkipSpaceFirst, lexer.start+i+1, "jsxRuntime", rest); ok {
+					lexer.JSXRuntimePragmaComment = arg
+				}
+			} else if hasPrefixWithWordBoundary(rest, "jsxImportSource") {
+				if arg, ok := scanForPragmaArg(pragmaSkipSpaceFirst, lexer.start+i+1, "jsxImportSource", rest); ok {
+					lexer.JSXImportSourcePragmaComment = arg
+				}
 			} else if i == 2 && strings.HasPrefix(rest, " sourceMappingURL=") {
 				if arg, ok := scanForPragmaArg(pragmaNoSpaceFirst, lexer.start+i+1, " sourceMappingURL=", rest); ok {
 					lexer.SourceMappingURL = arg
@@ -2724,18 +2774,46 @@ func (lexer *Lexer) scanCommentText() {
 		}
 	}
 
-	if hasLegalAnnotation || lexer.PreserveAllCommentsBefore {
+	isPreserved := lexer.preserveComments != nil && lexer.preserveComments.MatchString(text[2:endOfCommentText])
+
+	if hasLegalAnnotation || hasCustomPragma || isPreserved || lexer.PreserveAllCommentsBefore {
 		if isMultiLineComment {
 			text = helpers.RemoveMultiLineCommentIndent(lexer.source.Contents[:lexer.start], text)
 		}
 
 		lexer.CommentsToPreserveBefore = append(lexer.CommentsToPreserveBefore, js_ast.Comment{
-			Loc:  logger.Loc{Start: int32(lexer.start)},
-			Text: text,
+			Loc:             logger.Loc{Start: int32(lexer.start)},
+			Text:            text,
+			HasCustomPragma: hasCustomPragma,
+			IsPreserved:     isPreserved,
 		})
 	}
 }
 
+// Custom pragmas may contain "-" (e.g. "@license-category: X") so that's not
+// considered to be a word boundary here. The value is everything after the
+// name up to the end of the line, without an optional leading ":" or "=".
+func (lexer *Lexer) scanForCustomPragma(rest string) (js_ast.Pragma, bool) {
+	for _, name := range lexer.customPragmas {
+		if !strings.HasPrefix(rest, name) {
+			continue
+		}
+		value := rest[len(name):]
+		if c, _ := utf8.DecodeRuneInString(value); value != "" && (IsIdentifierContinue(c) || c == '-') {
+			continue
+		}
+		if newline := strings.IndexAny(value, "\r\n"); newline != -1 {
+			value = value[:newline]
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, ":") || strings.HasPrefix(value, "=") {
+			value = strings.TrimSpace(value[1:])
+		}
+		return js_ast.Pragma{Name: name, Value: value}, true
+	}
+	return js_ast.Pragma{}, false
+}
+
 func ContainsNonBMPCodePoint(text string) bool {
 	for _, c := range text {
 		if c > 0xFFFF {
@@ -0,0 +1,174 @@
+package js_lexer
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// This implements the canonical Unicode normalization forms NFD and NFC from
+// https://unicode.org/reports/tr15/. It's only used to validate that text is
+// already normalized, so it favors simplicity over speed.
+
+// Hangul syllables are composed and decomposed algorithmically
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+func IsNFC(text string) bool {
+	return isNormalized(text, true)
+}
+
+func IsNFD(text string) bool {
+	return isNormalized(text, false)
+}
+
+func isNormalized(text string, compose bool) bool {
+	// Fast path: ASCII text is always normalized
+	i := 0
+	for i < len(text) && text[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(text) {
+		return true
+	}
+
+	codePoints := []rune(text)
+	normalized := decompose(codePoints)
+	if compose {
+		normalized = composeCanonical(normalized)
+	}
+	if len(normalized) != len(codePoints) {
+		return false
+	}
+	for i, c := range normalized {
+		if c != codePoints[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupCombiningClass(c rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool { return combiningClasses[i][0] >= c })
+	if i < len(combiningClasses) && combiningClasses[i][0] == c {
+		return uint8(combiningClasses[i][1])
+	}
+	return 0
+}
+
+func lookupDecomposition(c rune) (rune, rune, bool) {
+	i := sort.Search(len(canonicalDecompositions), func(i int) bool { return canonicalDecompositions[i][0] >= c })
+	if i < len(canonicalDecompositions) && canonicalDecompositions[i][0] == c {
+		entry := canonicalDecompositions[i]
+		return entry[1], entry[2], true
+	}
+	return 0, 0, false
+}
+
+func lookupComposition(first rune, second rune) (rune, bool) {
+	i := sort.Search(len(canonicalCompositions), func(i int) bool {
+		entry := canonicalCompositions[i]
+		return entry[0] > first || (entry[0] == first && entry[1] >= second)
+	})
+	if i < len(canonicalCompositions) && canonicalCompositions[i][0] == first && canonicalCompositions[i][1] == second {
+		return canonicalCompositions[i][2], true
+	}
+	return 0, false
+}
+
+func appendDecomposition(result []rune, c rune) []rune {
+	if c >= hangulSBase && c < hangulSBase+hangulSCount {
+		s := c - hangulSBase
+		result = append(result, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			result = append(result, hangulTBase+t)
+		}
+		return result
+	}
+	if first, second, ok := lookupDecomposition(c); ok {
+		result = appendDecomposition(result, first)
+		if second != 0 {
+			result = appendDecomposition(result, second)
+		}
+		return result
+	}
+	return append(result, c)
+}
+
+// Returns the full canonical decomposition in canonical order
+func decompose(codePoints []rune) []rune {
+	result := make([]rune, 0, len(codePoints))
+	for _, c := range codePoints {
+		result = appendDecomposition(result, c)
+	}
+
+	// Sort each run of non-starters by their combining class. This must be a
+	// stable sort since non-starters with the same class can't be reordered.
+	for i := 0; i < len(result); {
+		if lookupCombiningClass(result[i]) == 0 {
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(result) && lookupCombiningClass(result[end]) != 0 {
+			end++
+		}
+		run := result[i:end]
+		sort.SliceStable(run, func(a, b int) bool { return lookupCombiningClass(run[a]) < lookupCombiningClass(run[b]) })
+		i = end
+	}
+	return result
+}
+
+// Combines each code point with the last starter unless it's blocked
+func composeCanonical(codePoints []rune) []rune {
+	if len(codePoints) == 0 {
+		return codePoints
+	}
+	result := []rune{codePoints[0]}
+	starter := -1
+	if lookupCombiningClass(codePoints[0]) == 0 {
+		starter = 0
+	}
+	lastClass := uint8(0)
+
+	for _, c := range codePoints[1:] {
+		class := lookupCombiningClass(c)
+
+		// The code point is blocked if there's a code point in between with a
+		// combining class that's zero or greater than or equal to its own
+		if starter != -1 && (len(result)-1 == starter || (lastClass != 0 && lastClass < class)) {
+			if composite, ok := composePair(result[starter], c); ok {
+				result[starter] = composite
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(result)
+		}
+		lastClass = class
+		result = append(result, c)
+	}
+	return result
+}
+
+func composePair(first rune, second rune) (rune, bool) {
+	// "LV" and "LVT" Hangul syllables
+	if first >= hangulLBase && first < hangulLBase+hangulLCount && second >= hangulVBase && second < hangulVBase+hangulVCount {
+		return hangulSBase + ((first-hangulLBase)*hangulVCount+(second-hangulVBase))*hangulTCount, true
+	}
+	if first >= hangulSBase && first < hangulSBase+hangulSCount && (first-hangulSBase)%hangulTCount == 0 &&
+		second > hangulTBase && second < hangulTBase+hangulTCount {
+		return first + (second - hangulTBase), true
+	}
+	return lookupComposition(first, second)
+}
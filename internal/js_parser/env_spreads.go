@@ -0,0 +1,193 @@
+package js_parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// If "target" is "process.env" or "import.meta.env", this returns the names of
+// the properties of that object that have a define. Replacing a whole
+// environment object with its defines is only done if there is at least one
+// define for it. The "process.env" object is left alone when targeting node
+// since it actually exists at run-time there.
+func (p *parser) envKeysForTarget(target js_ast.Expr) (prefix []string, keys []string) {
+	if p.isDotDefineMatch(target, []string{"import", "meta", "env"}) {
+		prefix = []string{"import", "meta", "env"}
+	} else if p.options.platform != config.PlatformNode && p.isDotDefineMatch(target, []string{"process", "env"}) {
+		prefix = []string{"process", "env"}
+	} else {
+		return nil, nil
+	}
+
+	for key, dotDefines := range p.options.defines.DotDefines {
+	next:
+		for _, define := range dotDefines {
+			if define.Data.DefineFunc == nil || len(define.Parts) != len(prefix)+1 {
+				continue
+			}
+			for i, part := range prefix {
+				if define.Parts[i] != part {
+					continue next
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// Sort the keys so that the generated object literals are deterministic
+	sort.Strings(keys)
+	return
+}
+
+// The generated property accesses are substituted with the value of the
+// define when they are visited, just like property accesses in the source code
+func cloneEnvTarget(expr js_ast.Expr, loc logger.Loc) js_ast.Expr {
+	switch e := expr.Data.(type) {
+	case *js_ast.EDot:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: cloneEnvTarget(e.Target, loc), Name: e.Name, NameLoc: loc}}
+	case *js_ast.EIdentifier:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: e.Ref}}
+	case *js_ast.EImportMeta:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EImportMeta{}}
+	}
+	panic("Internal error")
+}
+
+// This turns "{ ...process.env }" into an object literal containing every
+// defined environment variable so that the object doesn't have to exist at
+// run-time. This must be done before the properties are visited.
+func (p *parser) expandEnvSpreads(properties []js_ast.Property) []js_ast.Property {
+	var result []js_ast.Property
+
+	for i, property := range properties {
+		var keys []string
+		if property.Kind == js_ast.PropertySpread {
+			_, keys = p.envKeysForTarget(property.ValueOrNil)
+		}
+		if keys == nil {
+			if result != nil {
+				result = append(result, property)
+			}
+			continue
+		}
+		if result == nil {
+			result = append([]js_ast.Property{}, properties[:i]...)
+		}
+
+		loc := property.ValueOrNil.Loc
+		for _, key := range keys {
+			result = append(result, js_ast.Property{
+				Key:        js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(key)}},
+				ValueOrNil: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: cloneEnvTarget(property.ValueOrNil, loc), Name: key, NameLoc: loc}},
+			})
+		}
+	}
+
+	if result == nil {
+		return properties
+	}
+	return result
+}
+
+// This turns "const { API_URL } = process.env" into "const API_URL = ..." with
+// the value of the define, or with "void 0" (and a warning) if there is no
+// define for that environment variable. This must be done before the
+// declarations are visited.
+func (p *parser) expandEnvDestructuring(decls []js_ast.Decl) []js_ast.Decl {
+	var result []js_ast.Decl
+
+	for i, decl := range decls {
+		var prefix []string
+		var keys []string
+		if b, ok := decl.Binding.Data.(*js_ast.BObject); ok && decl.ValueOrNil.Data != nil && isStaticObjectBinding(b) {
+			prefix, keys = p.envKeysForTarget(decl.ValueOrNil)
+		}
+		if keys == nil {
+			if result != nil {
+				result = append(result, decl)
+			}
+			continue
+		}
+		if result == nil {
+			result = append([]js_ast.Decl{}, decls[:i]...)
+		}
+
+		target := decl.ValueOrNil
+		used := make(map[string]bool)
+		for _, property := range decl.Binding.Data.(*js_ast.BObject).Properties {
+			loc := property.Key.Loc
+
+			// A rest binding gets the environment variables that weren't used
+			if property.IsSpread {
+				var properties []js_ast.Property
+				for _, key := range keys {
+					if !used[key] {
+						properties = append(properties, js_ast.Property{
+							Key:        js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(key)}},
+							ValueOrNil: js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: cloneEnvTarget(target, loc), Name: key, NameLoc: loc}},
+						})
+					}
+				}
+				result = append(result, js_ast.Decl{Binding: property.Value, ValueOrNil: js_ast.Expr{Loc: loc, Data: &js_ast.EObject{Properties: properties}}})
+				continue
+			}
+
+			key := js_lexer.UTF16ToString(property.Key.Data.(*js_ast.EString).Value)
+			used[key] = true
+			isDefined := false
+			for _, other := range keys {
+				if other == key {
+					isDefined = true
+					break
+				}
+			}
+
+			var value js_ast.Expr
+			if isDefined {
+				value = js_ast.Expr{Loc: loc, Data: &js_ast.EDot{Target: cloneEnvTarget(target, loc), Name: key, NameLoc: loc}}
+			} else if property.DefaultValueOrNil.Data != nil {
+				value = property.DefaultValueOrNil
+			} else {
+				value = js_ast.Expr{Loc: loc, Data: js_ast.EUndefinedShared}
+				r := js_lexer.RangeOfIdentifier(p.source, loc)
+				if r.Len == 0 {
+					r = p.source.RangeOfString(loc)
+				}
+				p.log.Add(logger.Warning, &p.tracker, r, fmt.Sprintf(
+					"The environment variable %q will be undefined because there is no define for \"%s.%s\"",
+					key, strings.Join(prefix, "."), key))
+			}
+			result = append(result, js_ast.Decl{Binding: property.Value, ValueOrNil: value})
+		}
+	}
+
+	if result == nil {
+		return decls
+	}
+	return result
+}
+
+// Only object patterns with known property names can be split up
+func isStaticObjectBinding(b *js_ast.BObject) bool {
+	if len(b.Properties) == 0 {
+		return false
+	}
+	for _, property := range b.Properties {
+		if property.IsSpread {
+			continue
+		}
+		if _, ok := property.Key.Data.(*js_ast.EString); !ok || property.IsComputed {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package js_parser
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// The end of each branch of an "if" statement or a "?:" expression is
+// remembered while parsing when there are feature flags, since the AST only
+// stores where each node starts. This is used to measure how much code each
+// feature flag kept or removed.
+type branchEnds struct {
+	yes logger.Loc
+	no  logger.Loc
+}
+
+func (p *parser) recordFeatureFlagUse(name string, loc logger.Loc) {
+	p.featureFlagUses = append(p.featureFlagUses, js_ast.FeatureFlagUse{Name: name, Loc: loc})
+}
+
+// The bytes of source code from "start" to "end". The end is the start of the
+// next token, so any trailing whitespace is not included.
+func (p *parser) branchBytes(start logger.Loc, end logger.Loc) int32 {
+	if end.Start <= start.Start || int(end.Start) > len(p.source.Contents) {
+		return 0
+	}
+	return int32(len(strings.TrimRight(p.source.Contents[start.Start:end.Start], " \t\r\n")))
+}
+
+// Feature flags referenced by the test of a branch are credited with the code
+// in the branch that was taken and the code in the branch that was removed.
+// The "uses" are the references that were recorded while visiting the test.
+func (p *parser) recordFeatureFlagBranch(usesStart int, boolean bool, yes logger.Loc, no logger.Loc, ends branchEnds) {
+	yesBytes := p.branchBytes(yes, ends.yes)
+	noBytes := p.branchBytes(no, ends.no)
+	if !boolean {
+		yesBytes, noBytes = noBytes, yesBytes
+	}
+	for i := usesStart; i < len(p.featureFlagUses); i++ {
+		use := &p.featureFlagUses[i]
+		use.KeptBytes += yesBytes
+		use.StrippedBytes += noBytes
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"unicode/utf8"
@@ -49,6 +50,7 @@ type parser struct {
 	topLevelAwaitKeyword       logger.Range
 	fnOrArrowDataParse         fnOrArrowDataParse
 	fnOrArrowDataVisit         fnOrArrowDataVisit
+	hasRequireContext          bool
 	fnOnlyDataVisit            fnOnlyDataVisit
 	allocatedNames             []string
 	latestArrowArgLoc          logger.Loc
@@ -69,10 +71,46 @@ type parser struct {
 	symbolUses                 map[js_ast.Ref]js_ast.SymbolUse
 	declaredSymbols            []js_ast.DeclaredSymbol
 	runtimeImports             map[string]js_ast.Ref
+	jsxRuntimeImports          map[string]js_ast.Ref
+	jsxLegacyImports           map[string]js_ast.Ref
+	firstJSXElementLoc         logger.Loc
 	duplicateCaseChecker       duplicateCaseChecker
 	unrepresentableIdentifiers map[string]bool
+	unnormalizedIdentifiers    map[string]bool
 	legacyOctalLiterals        map[js_ast.E]logger.Range
 
+	// For recovering from syntax errors. The members that the statement being
+	// parsed declares in the scope that it's in are recorded so that they can be
+	// undone if the statement fails to parse.
+	syntaxErrorCount             int
+	cannotRecoverFromSyntaxError bool
+	recoveryScope                *js_ast.Scope
+	recoveryUndoMembers          []recoveryUndoMember
+
+	// For member tree shaking. These are nil when it's disabled.
+	memberTotalUseCounts      map[js_ast.Ref]uint32
+	memberSafeUseCounts       map[js_ast.Ref]uint32
+	memberThisEscapes         map[logger.Loc]bool
+	memberInstances           []memberInstance
+	memberSubclasses          []memberSubclass
+	memberThisUses            memberThisUses
+	propertyNamesUsed         map[string]bool
+	usesDynamicPropertyAccess bool
+
+	// For "--mangle-props". These are nil when it's disabled.
+	mangledPropCounts map[string]uint32
+	reservedPropNames map[string]bool
+
+	// For the feature flag report. The maps are nil when there are no flags.
+	featureFlagUses  []js_ast.FeatureFlagUse
+	ifStmtBranchEnds map[*js_ast.SIf]branchEnds
+	ifExprBranchEnds map[*js_ast.EIf]branchEnds
+
+	// These are for inferring which top-level functions are pure
+	callsForPureInference             map[js_ast.Ref][]*js_ast.ECall
+	declarationCountsForPureInference map[js_ast.Ref]int
+	assignedSymbolsForPureInference   map[js_ast.Ref]bool
+
 	// For strict mode handling
 	hoistedRefForSloppyModeBlockFn map[js_ast.Ref]js_ast.Ref
 
@@ -108,6 +146,12 @@ type parser struct {
 	isExportedInsideNamespace  map[js_ast.Ref]js_ast.Ref
 	localTypeNames             map[string]bool
 
+	// For "--ts-enums". Enums that may be removed once the whole file has been
+	// visited are recorded along with the number of uses that were inlined.
+	tsEnumSummary     js_ast.TSEnumSummary
+	inlinableTSEnums  []inlinableTSEnum
+	inlinedTSEnumUses map[js_ast.Ref]uint32
+
 	// This is the reference to the generated function argument for the namespace,
 	// which is different than the reference to the namespace itself:
 	//
@@ -327,6 +371,26 @@ type Options struct {
 	injectedFiles []config.InjectedFile
 	jsx           config.JSXOptions
 	tsTarget      *config.TSTarget
+	customPragmas []string
+
+	// Comments matching this are kept in place
+	preserveComments *regexp.Regexp
+
+	// Property names matching "mangleProps" but not "reserveProps" are renamed
+	mangleProps  *regexp.Regexp
+	reserveProps *regexp.Regexp
+
+	// Code in these blocks is removed
+	stripIf      [][]string
+	stripBetween []config.StripMarkers
+
+	// Identifiers that can't be printed with these escapes are reported
+	charsetEscapes config.CharsetEscapes
+
+	// This is set by the bundler when "--bundle-dynamic-paths" is enabled. It
+	// returns the paths of all files that match a glob pattern such as
+	// "./locales/*.json" relative to the directory of the file being parsed.
+	expandRequireContext func(pattern string) []string
 
 	// This pointer will always be different for each build but the contents
 	// shouldn't ever behave different semantically. We ignore this field for the
@@ -342,6 +406,7 @@ type Options struct {
 type optionsThatSupportStructuralEquality struct {
 	unsupportedJSFeatures compat.JSFeature
 	originalTargetEnv     string
+	syntaxErrorLimit      int
 
 	// Byte-sized values go here (gathered together here to keep this object compact)
 	ts                      config.TSOptions
@@ -351,25 +416,42 @@ type optionsThatSupportStructuralEquality struct {
 	moduleType              config.ModuleType
 	targetFromAPI           config.TargetFromAPI
 	asciiOnly               bool
+	identifierCharset       config.IdentifierCharset
+	identifierNormalization config.Normalization
 	keepNames               bool
 	mangleSyntax            bool
 	minifyIdentifiers       bool
 	omitRuntimeForTests     bool
 	ignoreDCEAnnotations    bool
+	inferPureFunctions      bool
+	dropConsole             bool
+	dropDebugger            bool
+	recoverSyntaxErrors     bool
 	treeShaking             bool
+	treeShakingMembers      bool
 	unusedImportsTS         config.UnusedImportsTS
 	useDefineForClassFields config.MaybeBool
+	dynamicImportLoader     config.DynamicImportLoader
 }
 
 func OptionsFromConfig(options *config.Options) Options {
 	return Options{
-		injectedFiles: options.InjectedFiles,
-		jsx:           options.JSX,
-		defines:       options.Defines,
-		tsTarget:      options.TSTarget,
+		injectedFiles:        options.InjectedFiles,
+		jsx:                  options.JSX,
+		defines:              options.Defines,
+		tsTarget:             options.TSTarget,
+		customPragmas:        options.CustomPragmas,
+		preserveComments:     options.PreserveComments,
+		mangleProps:          options.MangleProps,
+		reserveProps:         options.ReserveProps,
+		stripIf:              options.StripIf,
+		stripBetween:         options.StripBetween,
+		charsetEscapes:       options.CharsetEscapes,
+		expandRequireContext: options.ExpandRequireContext,
 		optionsThatSupportStructuralEquality: optionsThatSupportStructuralEquality{
 			unsupportedJSFeatures:   options.UnsupportedJSFeatures,
 			originalTargetEnv:       options.OriginalTargetEnv,
+			syntaxErrorLimit:        options.SyntaxErrorLimit,
 			ts:                      options.TS,
 			mode:                    options.Mode,
 			platform:                options.Platform,
@@ -377,14 +459,22 @@ func OptionsFromConfig(options *config.Options) Options {
 			moduleType:              options.ModuleType,
 			targetFromAPI:           options.TargetFromAPI,
 			asciiOnly:               options.ASCIIOnly,
+			identifierCharset:       options.IdentifierCharset,
+			identifierNormalization: options.IdentifierNormalization,
 			keepNames:               options.KeepNames,
 			mangleSyntax:            options.MangleSyntax,
 			minifyIdentifiers:       options.MinifyIdentifiers,
 			omitRuntimeForTests:     options.OmitRuntimeForTests,
 			ignoreDCEAnnotations:    options.IgnoreDCEAnnotations,
+			inferPureFunctions:      options.InferPureFunctions,
+			dropConsole:             options.DropConsole,
+			dropDebugger:            options.DropDebugger,
+			recoverSyntaxErrors:     options.RecoverSyntaxErrors,
 			treeShaking:             options.TreeShaking,
+			treeShakingMembers:      options.TreeShakingMembers,
 			unusedImportsTS:         options.UnusedImportsTS,
 			useDefineForClassFields: options.UseDefineForClassFields,
+			dynamicImportLoader:     options.DynamicImportLoader,
 		},
 	}
 }
@@ -417,8 +507,60 @@ func (a *Options) Equal(b *Options) bool {
 		}
 	}
 
+	// Compare "CustomPragmas"
+	if !stringArraysEqual(a.customPragmas, b.customPragmas) {
+		return false
+	}
+
+	// Compare "PreserveComments"
+	if !regexpsEqual(a.preserveComments, b.preserveComments) {
+		return false
+	}
+
+	// Compare "MangleProps" and "ReserveProps"
+	if !regexpsEqual(a.mangleProps, b.mangleProps) || !regexpsEqual(a.reserveProps, b.reserveProps) {
+		return false
+	}
+
+	// Compare "StripIf"
+	if len(a.stripIf) != len(b.stripIf) {
+		return false
+	}
+	for i, x := range a.stripIf {
+		if !stringArraysEqual(x, b.stripIf[i]) {
+			return false
+		}
+	}
+
+	// Compare "StripBetween"
+	if len(a.stripBetween) != len(b.stripBetween) {
+		return false
+	}
+	for i, x := range a.stripBetween {
+		if x != b.stripBetween[i] {
+			return false
+		}
+	}
+
+	// Compare "CharsetEscapes"
+	if a.charsetEscapes.NonBMP != b.charsetEscapes.NonBMP || len(a.charsetEscapes.Scripts) != len(b.charsetEscapes.Scripts) {
+		return false
+	}
+	for i, x := range a.charsetEscapes.Scripts {
+		if x != b.charsetEscapes.Scripts[i] {
+			return false
+		}
+	}
+
+	// Compare "ExpandRequireContext" (the function itself is different for
+	// every file, but whether or not it's present changes the output)
+	if (a.expandRequireContext == nil) != (b.expandRequireContext == nil) {
+		return false
+	}
+
 	// Compare "JSX"
-	if a.jsx.Parse != b.jsx.Parse || !jsxExprsEqual(a.jsx.Factory, b.jsx.Factory) || !jsxExprsEqual(a.jsx.Fragment, b.jsx.Fragment) {
+	if a.jsx.Parse != b.jsx.Parse || !jsxExprsEqual(a.jsx.Factory, b.jsx.Factory) || !jsxExprsEqual(a.jsx.Fragment, b.jsx.Fragment) ||
+		a.jsx.AutomaticRuntime != b.jsx.AutomaticRuntime || a.jsx.ImportSource != b.jsx.ImportSource {
 		return false
 	}
 
@@ -432,6 +574,17 @@ func (a *Options) Equal(b *Options) bool {
 	return true
 }
 
+// This is the same as "Equal" except that the defines and the statements that
+// are dropped are allowed to be different. The JS cache uses this to reuse the
+// ASTs of files that can't have been affected when those options change.
+func (a *Options) EqualExceptDefinesAndDrop(b *Options) bool {
+	aCopy, bCopy := *a, *b
+	aCopy.defines, bCopy.defines = nil, nil
+	aCopy.dropConsole, bCopy.dropConsole = false, false
+	aCopy.dropDebugger, bCopy.dropDebugger = false, false
+	return aCopy.Equal(&bCopy)
+}
+
 func jsxExprsEqual(a config.JSXExpr, b config.JSXExpr) bool {
 	if !stringArraysEqual(a.Parts, b.Parts) {
 		return false
@@ -460,6 +613,10 @@ func stringArraysEqual(a []string, b []string) bool {
 	return true
 }
 
+func regexpsEqual(a *regexp.Regexp, b *regexp.Regexp) bool {
+	return (a == nil) == (b == nil) && (a == nil || a.String() == b.String())
+}
+
 type tempRef struct {
 	ref        js_ast.Ref
 	valueOrNil js_ast.Expr
@@ -469,6 +626,12 @@ const (
 	locModuleScope = -1
 )
 
+type recoveryUndoMember struct {
+	name      string
+	old       js_ast.ScopeMember
+	hadMember bool
+}
+
 type scopeOrder struct {
 	loc   logger.Loc
 	scope *js_ast.Scope
@@ -1507,6 +1670,10 @@ func (p *parser) declareSymbol(kind js_ast.SymbolKind, loc logger.Loc, name stri
 	}
 
 	// Overwrite this name in the declaring scope
+	if p.currentScope == p.recoveryScope {
+		old, hadMember := p.currentScope.Members[name]
+		p.recoveryUndoMembers = append(p.recoveryUndoMembers, recoveryUndoMember{name: name, old: old, hadMember: hadMember})
+	}
 	p.currentScope.Members[name] = js_ast.ScopeMember{Ref: ref, Loc: loc}
 	return ref
 
@@ -1686,6 +1853,9 @@ func (p *parser) recordUsage(ref js_ast.Ref) {
 		use := p.symbolUses[ref]
 		use.CountEstimate++
 		p.symbolUses[ref] = use
+		if p.memberTotalUseCounts != nil {
+			p.memberTotalUseCounts[ref]++
+		}
 	}
 
 	// The correctness of TypeScript-to-JavaScript conversion relies on accurate
@@ -1762,6 +1932,145 @@ func (p *parser) valueToSubstituteForRequire(loc logger.Loc) js_ast.Expr {
 	return js_ast.Expr{Loc: loc, Data: &js_ast.EIdentifier{Ref: p.requireRef}}
 }
 
+// Returns a glob pattern for the argument of a "require()" or "import()" call
+// that starts with a static relative path but isn't fully static. Each dynamic
+// part of the path is replaced with a "*" wildcard:
+//
+//   require(`./locales/${lang}.json`)  =>  "./locales/*.json"
+//   require("./locales/" + lang)       =>  "./locales/*"
+//
+func requireContextPattern(arg js_ast.Expr) (string, bool) {
+	sb := strings.Builder{}
+	hasWildcard := false
+
+	addText := func(text string) bool {
+		if strings.ContainsRune(text, '*') {
+			return false
+		}
+		sb.WriteString(text)
+		return true
+	}
+	addWildcard := func() {
+		if text := sb.String(); !strings.HasSuffix(text, "*") {
+			sb.WriteByte('*')
+		}
+		hasWildcard = true
+	}
+	addPart := func(part js_ast.Expr) bool {
+		if str, ok := part.Data.(*js_ast.EString); ok {
+			return addText(js_lexer.UTF16ToString(str.Value))
+		}
+		addWildcard()
+		return true
+	}
+
+	var visit func(js_ast.Expr) bool
+	visit = func(expr js_ast.Expr) bool {
+		switch e := expr.Data.(type) {
+		case *js_ast.ETemplate:
+			if e.TagOrNil.Data != nil || !addText(js_lexer.UTF16ToString(e.HeadCooked)) {
+				return false
+			}
+			for _, part := range e.Parts {
+				if !addPart(part.Value) || !addText(js_lexer.UTF16ToString(part.TailCooked)) {
+					return false
+				}
+			}
+			return true
+
+		case *js_ast.EBinary:
+			// Only the left operand is flattened since "+" is left-associative
+			if e.Op == js_ast.BinOpAdd {
+				return visit(e.Left) && addPart(e.Right)
+			}
+		}
+		return addPart(expr)
+	}
+
+	if !visit(arg) || !hasWildcard {
+		return "", false
+	}
+	pattern := sb.String()
+	if !strings.HasPrefix(pattern, "./") && !strings.HasPrefix(pattern, "../") {
+		return "", false
+	}
+	return pattern, true
+}
+
+// This turns a "require()" or "import()" call with a partially-dynamic path
+// into a lookup in a map containing every file that the path could refer to:
+//
+//   __glob({
+//     "./locales/en.json": () => require("./locales/en.json"),
+//     "./locales/fr.json": () => require("./locales/fr.json")
+//   })(`./locales/${lang}.json`)
+//
+// The files are found by the bundler since the parser doesn't have access to
+// the file system. This is only done when "--bundle-dynamic-paths" is enabled.
+func (p *parser) maybeRequireContext(
+	loc logger.Loc, arg js_ast.Expr, kind ast.ImportKind, assertions *[]ast.AssertEntry, handlesImportErrors bool,
+) (js_ast.Expr, bool) {
+	if p.options.expandRequireContext == nil {
+		return js_ast.Expr{}, false
+	}
+	pattern, ok := requireContextPattern(arg)
+	if !ok {
+		return js_ast.Expr{}, false
+	}
+
+	// Ignore these calls if the control flow is provably dead here. We don't
+	// want to spend time scanning the matching files if they will never be used.
+	if p.isControlFlowDead {
+		return js_ast.Expr{Loc: loc, Data: js_ast.ENullShared}, true
+	}
+
+	p.hasRequireContext = true
+	paths := p.options.expandRequireContext(pattern)
+	if len(paths) == 0 {
+		kind := logger.Warning
+		if p.suppressWarningsAboutWeirdCode {
+			kind = logger.Debug
+		}
+		p.log.Add(kind, &p.tracker, logger.Range{Loc: arg.Loc},
+			fmt.Sprintf("No files match the pattern %q", pattern))
+	}
+
+	properties := make([]js_ast.Property, 0, len(paths))
+	for _, path := range paths {
+		importRecordIndex := p.addImportRecord(kind, arg.Loc, path, assertions)
+		p.importRecords[importRecordIndex].HandlesImportErrors = handlesImportErrors
+		p.importRecordsForCurrentPart = append(p.importRecordsForCurrentPart, importRecordIndex)
+
+		var value js_ast.Expr
+		if kind == ast.ImportDynamic {
+			value = js_ast.Expr{Loc: loc, Data: &js_ast.EImportString{ImportRecordIndex: importRecordIndex}}
+		} else {
+			value = js_ast.Expr{Loc: loc, Data: &js_ast.ERequireString{ImportRecordIndex: importRecordIndex}}
+		}
+
+		var fn js_ast.Expr
+		body := js_ast.FnBody{Loc: loc, Stmts: []js_ast.Stmt{{Loc: loc, Data: &js_ast.SReturn{ValueOrNil: value}}}}
+		if p.options.unsupportedJSFeatures.Has(compat.Arrow) {
+			fn = js_ast.Expr{Loc: loc, Data: &js_ast.EFunction{Fn: js_ast.Fn{Body: body}}}
+		} else {
+			fn = js_ast.Expr{Loc: loc, Data: &js_ast.EArrow{Body: body, PreferExpr: true}}
+		}
+
+		properties = append(properties, js_ast.Property{
+			Key:        js_ast.Expr{Loc: arg.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(path)}},
+			ValueOrNil: fn,
+		})
+	}
+
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: p.callRuntime(loc, "__glob", []js_ast.Expr{{Loc: loc, Data: &js_ast.EObject{
+			Properties:   properties,
+			IsSingleLine: len(properties) < 2,
+		}}}),
+		Args: []js_ast.Expr{arg},
+	}}, true
+}
+
 func (p *parser) makePromiseRef() js_ast.Ref {
 	if p.promiseRef == js_ast.InvalidRef {
 		p.promiseRef = p.newSymbol(js_ast.SymbolUnbound, "Promise")
@@ -1958,6 +2267,7 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 	case js_lexer.TStringLiteral:
 		key = p.parseStringLiteral()
 		preferQuotedKey = !p.options.mangleSyntax
+		p.recordReservedPropKey(key)
 
 	case js_lexer.TBigIntegerLiteral:
 		key = js_ast.Expr{Loc: p.lexer.Loc(), Data: &js_ast.EBigInt{Value: p.lexer.Identifier}}
@@ -2129,7 +2439,7 @@ func (p *parser) parseProperty(kind js_ast.PropertyKind, opts propertyOpts, erro
 			}
 		}
 
-		key = js_ast.Expr{Loc: nameRange.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(name)}}
+		key = p.propertyKeyForName(nameRange.Loc, name)
 
 		// Parse a shorthand property
 		if !opts.isClass && kind == js_ast.PropertyNormal && p.lexer.Token != js_lexer.TColon &&
@@ -2418,6 +2728,7 @@ func (p *parser) parsePropertyBinding() js_ast.PropertyBinding {
 	case js_lexer.TStringLiteral:
 		key = p.parseStringLiteral()
 		preferQuotedKey = !p.options.mangleSyntax
+		p.recordReservedPropKey(key)
 
 	case js_lexer.TBigIntegerLiteral:
 		key = js_ast.Expr{Loc: p.lexer.Loc(), Data: &js_ast.EBigInt{Value: p.lexer.Identifier}}
@@ -2437,7 +2748,7 @@ func (p *parser) parsePropertyBinding() js_ast.PropertyBinding {
 			p.lexer.Expect(js_lexer.TIdentifier)
 		}
 		p.lexer.Next()
-		key = js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(name)}}
+		key = p.propertyKeyForName(loc, name)
 
 		if p.lexer.Token != js_lexer.TColon && p.lexer.Token != js_lexer.TOpenParen {
 			ref := p.storeNameInRef(name)
@@ -3395,6 +3706,7 @@ func (p *parser) parsePrefix(level js_ast.L, errors *deferredErrors, flags exprF
 		}}
 
 	case js_lexer.TOpenBrace:
+		hasKeepComment := p.lexer.HasKeepCommentBefore
 		p.lexer.Next()
 		isSingleLine := !p.lexer.HasNewlineBefore
 		properties := []js_ast.Property{}
@@ -3420,7 +3732,9 @@ func (p *parser) parsePrefix(level js_ast.L, errors *deferredErrors, flags exprF
 				}
 			} else {
 				// This property may turn out to be a type in TypeScript, which should be ignored
+				propertyHasKeepComment := hasKeepComment || p.lexer.HasKeepCommentBefore
 				if property, ok := p.parseProperty(js_ast.PropertyNormal, propertyOpts{}, &selfErrors); ok {
+					property.HasKeepComment = propertyHasKeepComment
 					properties = append(properties, property)
 				}
 			}
@@ -3967,9 +4281,15 @@ func (p *parser) parseSuffix(left js_ast.Expr, level js_ast.L, errors *deferredE
 
 			p.allowIn = oldAllowIn
 
+			ends := branchEnds{yes: p.lexer.Loc()}
 			p.lexer.Expect(js_lexer.TColon)
 			no := p.parseExpr(js_ast.LComma)
-			left = js_ast.Expr{Loc: left.Loc, Data: &js_ast.EIf{Test: left, Yes: yes, No: no}}
+			e := &js_ast.EIf{Test: left, Yes: yes, No: no}
+			if p.ifExprBranchEnds != nil {
+				ends.no = p.lexer.Loc()
+				p.ifExprBranchEnds[e] = ends
+			}
+			left = js_ast.Expr{Loc: left.Loc, Data: e}
 
 		case js_lexer.TExclamation:
 			// Skip over TypeScript non-null assertions
@@ -4361,8 +4681,12 @@ func (p *parser) parseSuffix(left js_ast.Expr, level js_ast.L, errors *deferredE
 			left = js_ast.Expr{Loc: left.Loc, Data: &js_ast.EBinary{Op: js_ast.BinOpInstanceof, Left: left, Right: p.parseExpr(js_ast.LCompare)}}
 
 		default:
-			// Handle the TypeScript "as" operator
-			if p.options.ts.Parse && level < js_ast.LCompare && !p.lexer.HasNewlineBefore && p.lexer.IsContextualKeyword("as") {
+			// Handle the TypeScript "as" and "satisfies" operators
+			if p.options.ts.Parse && level < js_ast.LCompare && !p.lexer.HasNewlineBefore &&
+				(p.lexer.IsContextualKeyword("as") || p.lexer.IsContextualKeyword("satisfies")) {
+				if p.lexer.Identifier == "satisfies" {
+					p.checkTSVersion(p.lexer.Range(), "The \"satisfies\" operator", 4, 9)
+				}
 				p.lexer.Next()
 				p.skipTypeScriptType(js_ast.LLowest)
 
@@ -5430,6 +5754,7 @@ func (p *parser) validateDeclaredSymbolName(loc logger.Loc, name string) {
 func (p *parser) parseClassStmt(loc logger.Loc, opts parseStmtOpts) js_ast.Stmt {
 	var name *js_ast.LocRef
 	classKeyword := p.lexer.Range()
+	hasKeepComment := p.lexer.HasKeepCommentBefore
 	if p.lexer.Token == js_lexer.TClass {
 		p.markSyntaxFeature(compat.Class, classKeyword)
 		p.lexer.Next()
@@ -5458,6 +5783,7 @@ func (p *parser) parseClassStmt(loc logger.Loc, opts parseStmtOpts) js_ast.Stmt
 	classOpts := parseClassOpts{
 		allowTSDecorators:   true,
 		isTypeScriptDeclare: opts.isTypeScriptDeclare,
+		hasKeepComment:      hasKeepComment,
 	}
 	if opts.tsDecorators != nil {
 		classOpts.tsDecorators = opts.tsDecorators.values
@@ -5483,6 +5809,7 @@ type parseClassOpts struct {
 	tsDecorators        []js_ast.Expr
 	allowTSDecorators   bool
 	isTypeScriptDeclare bool
+	hasKeepComment      bool
 }
 
 // By the time we call this, the identifier and type parameters have already
@@ -5552,7 +5879,9 @@ func (p *parser) parseClass(classKeyword logger.Range, name *js_ast.LocRef, clas
 		}
 
 		// This property may turn out to be a type in TypeScript, which should be ignored
+		hasKeepComment := classOpts.hasKeepComment || p.lexer.HasKeepCommentBefore
 		if property, ok := p.parseProperty(js_ast.PropertyNormal, opts, nil); ok {
+			property.HasKeepComment = hasKeepComment
 			properties = append(properties, property)
 
 			// Forbid decorators on class constructors
@@ -6214,12 +6543,18 @@ func (p *parser) parseStmt(opts parseStmtOpts) js_ast.Stmt {
 		test := p.parseExpr(js_ast.LLowest)
 		p.lexer.Expect(js_lexer.TCloseParen)
 		yes := p.parseStmt(parseStmtOpts{lexicalDecl: lexicalDeclAllowFnInsideIf})
+		ends := branchEnds{yes: p.lexer.Loc()}
 		var noOrNil js_ast.Stmt
 		if p.lexer.Token == js_lexer.TElse {
 			p.lexer.Next()
 			noOrNil = p.parseStmt(parseStmtOpts{lexicalDecl: lexicalDeclAllowFnInsideIf})
+			ends.no = p.lexer.Loc()
 		}
-		return js_ast.Stmt{Loc: loc, Data: &js_ast.SIf{Test: test, Yes: yes, NoOrNil: noOrNil}}
+		s := &js_ast.SIf{Test: test, Yes: yes, NoOrNil: noOrNil}
+		if p.ifStmtBranchEnds != nil {
+			p.ifStmtBranchEnds[s] = ends
+		}
+		return js_ast.Stmt{Loc: loc, Data: s}
 
 	case js_lexer.TDo:
 		p.lexer.Next()
@@ -6974,6 +7309,195 @@ func (p *parser) forbidLexicalDecl(loc logger.Loc) {
 	p.log.Add(logger.Error, &p.tracker, r, "Cannot use a declaration in a single-statement context")
 }
 
+// Syntax errors abort parsing by panicking. When recovery is enabled, the
+// top-level statement list catches that panic and skips ahead to the next
+// statement so that several independent errors can be reported for the same
+// file in one pass. The file still fails to parse. This is only done at the
+// top level so that parsing nested statements doesn't pay for the deferred
+// call and the copy of the lexer.
+func (p *parser) parseStmtWithRecovery(end js_lexer.T, opts parseStmtOpts) (stmt js_ast.Stmt, ok bool) {
+	oldLexer := p.lexer
+	oldScope := p.currentScope
+	oldChildCount := len(oldScope.Children)
+	oldScopesInOrderCount := len(p.scopesInOrder)
+	oldFnOrArrowDataParse := p.fnOrArrowDataParse
+	oldAllowIn := p.allowIn
+	p.recoveryScope = oldScope
+	p.recoveryUndoMembers = p.recoveryUndoMembers[:0]
+
+	defer func() {
+		p.recoveryScope = nil
+		r := recover()
+		if _, isLexerPanic := r.(js_lexer.LexerPanic); !isLexerPanic {
+			if r != nil {
+				panic(r)
+			}
+			return
+		}
+
+		p.syntaxErrorCount++
+		if p.cannotRecoverFromSyntaxError || (p.options.syntaxErrorLimit > 0 && p.syntaxErrorCount >= p.options.syntaxErrorLimit) {
+			p.cannotRecoverFromSyntaxError = true
+			panic(r)
+		}
+
+		// Rewind to the start of the statement so that brackets can be matched.
+		// Also forget the statement's declarations and scopes so that they don't
+		// cause errors about redeclared symbols in later statements.
+		errorLoc := p.lexer.Loc()
+		p.lexer = oldLexer
+		p.currentScope = oldScope
+		p.fnOrArrowDataParse = oldFnOrArrowDataParse
+		p.allowIn = oldAllowIn
+		for i := len(p.recoveryUndoMembers) - 1; i >= 0; i-- {
+			undo := p.recoveryUndoMembers[i]
+			if undo.hadMember {
+				oldScope.Members[undo.name] = undo.old
+			} else {
+				delete(oldScope.Members, undo.name)
+			}
+		}
+		oldScope.Children = oldScope.Children[:oldChildCount]
+		p.scopesInOrder = p.scopesInOrder[:oldScopesInOrderCount]
+		p.skipToNextStmtAfterSyntaxError(end, errorLoc)
+
+		// Give up if nothing was skipped, since parsing would fail again
+		if p.lexer.Loc() == oldLexer.Loc() {
+			p.cannotRecoverFromSyntaxError = true
+			panic(r)
+		}
+	}()
+
+	return p.parseStmt(opts), true
+}
+
+// This skips over the tokens of a statement that failed to parse. Skipping
+// stops at the first ";" at the statement's nesting level that comes at or
+// after the error, at the "}" that ends the enclosing block, or at a line that
+// looks like it starts a new statement. Any errors from the lexer while
+// skipping are not reported since they are likely a result of the original
+// error, but they do prevent any further recovery.
+func (p *parser) skipToNextStmtAfterSyntaxError(end js_lexer.T, errorLoc logger.Loc) {
+	oldIsLogDisabled := p.lexer.IsLogDisabled
+	p.lexer.IsLogDisabled = true
+
+	defer func() {
+		r := recover()
+		if _, isLexerPanic := r.(js_lexer.LexerPanic); isLexerPanic {
+			p.cannotRecoverFromSyntaxError = true
+		}
+		if r != nil {
+			panic(r)
+		}
+		p.lexer.IsLogDisabled = oldIsLogDisabled
+	}()
+
+	// Each entry is either "{", "(", "[", or a template literal with
+	// substitutions (represented by its head token)
+	var stack []js_lexer.T
+	prevToken := js_lexer.TEndOfFile
+
+	for {
+		loc := p.lexer.Loc()
+		isPastError := loc.Start > errorLoc.Start
+
+		// Look for a line that starts a new statement. Some keywords can only
+		// start a statement, so they also end any unclosed "(" or "[" tokens.
+		if isPastError && p.lexer.HasNewlineBefore {
+			if len(stack) == 0 && (prevToken == js_lexer.TCloseBrace || p.lexer.Token == js_lexer.TClass ||
+				p.lexer.Token == js_lexer.TFunction || p.lexer.Token == js_lexer.TImport || p.lexer.IsContextualKeyword("let")) {
+				return
+			}
+			if isStmtOnlyKeyword(p.lexer.Token) && !stackHasBraces(stack) {
+				return
+			}
+		}
+
+		switch p.lexer.Token {
+		case js_lexer.TEndOfFile:
+			return
+
+		case js_lexer.TSyntaxError:
+			// The lexer can't make progress past this token
+			panic(js_lexer.LexerPanic{})
+
+		case js_lexer.TSemicolon:
+			if len(stack) == 0 && loc.Start >= errorLoc.Start {
+				p.lexer.Next()
+				return
+			}
+
+		case js_lexer.TOpenBrace, js_lexer.TOpenParen, js_lexer.TOpenBracket, js_lexer.TTemplateHead:
+			stack = append(stack, p.lexer.Token)
+
+		case js_lexer.TCloseParen, js_lexer.TCloseBracket, js_lexer.TCloseBrace:
+			// Pop back to the matching opening token, which handles unbalanced
+			// brackets in the statement that failed to parse
+			open := js_lexer.TOpenParen
+			if p.lexer.Token == js_lexer.TCloseBracket {
+				open = js_lexer.TOpenBracket
+			} else if p.lexer.Token == js_lexer.TCloseBrace {
+				open = js_lexer.TOpenBrace
+			}
+			i := len(stack) - 1
+			for i >= 0 && stack[i] != open && (open != js_lexer.TOpenBrace || stack[i] != js_lexer.TTemplateHead) {
+				i--
+			}
+			if i >= 0 {
+				if stack[i] == js_lexer.TTemplateHead {
+					p.lexer.RescanCloseBraceAsTemplateToken()
+					if p.lexer.Token == js_lexer.TTemplateMiddle {
+						i++
+					}
+				}
+				stack = stack[:i]
+			} else if open == js_lexer.TOpenBrace {
+				// Leave the "}" for the enclosing block. Otherwise this is a stray "}"
+				// that must be skipped over.
+				if end == js_lexer.TCloseBrace && loc.Start >= errorLoc.Start {
+					return
+				}
+			}
+
+		case js_lexer.TSlash, js_lexer.TSlashEquals:
+			// Guess whether this is a regular expression or a division operator
+			// from the previous token, since the contents of a regular expression
+			// may not be valid tokens
+			switch prevToken {
+			case js_lexer.TIdentifier, js_lexer.TPrivateIdentifier, js_lexer.TNumericLiteral,
+				js_lexer.TBigIntegerLiteral, js_lexer.TStringLiteral, js_lexer.TNoSubstitutionTemplateLiteral,
+				js_lexer.TTemplateTail, js_lexer.TCloseParen, js_lexer.TCloseBracket, js_lexer.TCloseBrace,
+				js_lexer.TThis, js_lexer.TSuper, js_lexer.TTrue, js_lexer.TFalse, js_lexer.TNull,
+				js_lexer.TPlusPlus, js_lexer.TMinusMinus:
+			default:
+				p.lexer.ScanRegExp()
+			}
+		}
+
+		prevToken = p.lexer.Token
+		p.lexer.Next()
+	}
+}
+
+func isStmtOnlyKeyword(token js_lexer.T) bool {
+	switch token {
+	case js_lexer.TBreak, js_lexer.TConst, js_lexer.TContinue, js_lexer.TDebugger, js_lexer.TDo,
+		js_lexer.TExport, js_lexer.TFor, js_lexer.TIf, js_lexer.TReturn, js_lexer.TSwitch,
+		js_lexer.TThrow, js_lexer.TTry, js_lexer.TVar, js_lexer.TWhile:
+		return true
+	}
+	return false
+}
+
+func stackHasBraces(stack []js_lexer.T) bool {
+	for _, token := range stack {
+		if token == js_lexer.TOpenBrace || token == js_lexer.TTemplateHead {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *parser) parseStmtsUpTo(end js_lexer.T, opts parseStmtOpts) []js_ast.Stmt {
 	stmts := []js_ast.Stmt{}
 	returnWithoutSemicolonStart := int32(-1)
@@ -6988,8 +7512,12 @@ func (p *parser) parseStmtsUpTo(end js_lexer.T, opts parseStmtOpts) []js_ast.Stm
 				stmts = append(stmts, js_ast.Stmt{
 					Loc: comment.Loc,
 					Data: &js_ast.SComment{
-						Text:           comment.Text,
-						IsLegalComment: true,
+						Text: comment.Text,
+
+						// Comments with custom pragmas and comments that the user asked
+						// to preserve are always printed where they are, even if they
+						// are also legal comments
+						IsLegalComment: !comment.HasCustomPragma && !comment.IsPreserved,
 					},
 				})
 			}
@@ -6999,7 +7527,15 @@ func (p *parser) parseStmtsUpTo(end js_lexer.T, opts parseStmtOpts) []js_ast.Stm
 			break
 		}
 
-		stmt := p.parseStmt(opts)
+		var stmt js_ast.Stmt
+		if opts.isModuleScope && p.options.recoverSyntaxErrors {
+			var ok bool
+			if stmt, ok = p.parseStmtWithRecovery(end, opts); !ok {
+				continue
+			}
+		} else {
+			stmt = p.parseStmt(opts)
+		}
 
 		// Skip TypeScript types entirely
 		if p.options.ts.Parse {
@@ -8375,6 +8911,7 @@ func (p *parser) visitBinding(binding js_ast.Binding, opts bindingOpts) {
 
 	case *js_ast.BIdentifier:
 		p.recordDeclaredSymbol(b.Ref)
+		p.recordDeclarationForPureInference(b.Ref)
 		name := p.symbols[b.Ref.InnerIndex].OriginalName
 		p.validateDeclaredSymbolName(binding.Loc, name)
 		if opts.duplicateArgCheck != nil {
@@ -8408,6 +8945,7 @@ func (p *parser) visitBinding(binding js_ast.Binding, opts bindingOpts) {
 		for i, property := range b.Properties {
 			if !property.IsSpread {
 				property.Key = p.visitExpr(property.Key)
+				p.recordPropertyKeyUsedForMemberTreeShaking(property.Key)
 			}
 			p.visitBinding(property.Value, opts)
 			if property.DefaultValueOrNil.Data != nil {
@@ -8534,8 +9072,8 @@ func appendIfBodyPreservingScope(stmts []js_ast.Stmt, body js_ast.Stmt) []js_ast
 	return append(stmts, body)
 }
 
-func (p *parser) mangleIf(stmts []js_ast.Stmt, loc logger.Loc, s *js_ast.SIf) []js_ast.Stmt {
-	// Constant folding using the test expression
+// Constant folding using the test expression
+func (p *parser) foldConstantIf(stmts []js_ast.Stmt, s *js_ast.SIf) ([]js_ast.Stmt, bool) {
 	if boolean, sideEffects, ok := toBooleanWithSideEffects(s.Test.Data); ok {
 		if boolean {
 			// The test is truthy
@@ -8547,7 +9085,7 @@ func (p *parser) mangleIf(stmts []js_ast.Stmt, loc logger.Loc, s *js_ast.SIf) []
 						stmts = append(stmts, js_ast.Stmt{Loc: s.Test.Loc, Data: &js_ast.SExpr{Value: test}})
 					}
 				}
-				return appendIfBodyPreservingScope(stmts, s.Yes)
+				return appendIfBodyPreservingScope(stmts, s.Yes), true
 			} else {
 				// We have to keep the "no" branch
 			}
@@ -8562,14 +9100,21 @@ func (p *parser) mangleIf(stmts []js_ast.Stmt, loc logger.Loc, s *js_ast.SIf) []
 					}
 				}
 				if s.NoOrNil.Data == nil {
-					return stmts
+					return stmts, true
 				}
-				return appendIfBodyPreservingScope(stmts, s.NoOrNil)
+				return appendIfBodyPreservingScope(stmts, s.NoOrNil), true
 			} else {
 				// We have to keep the "yes" branch
 			}
 		}
 	}
+	return nil, false
+}
+
+func (p *parser) mangleIf(stmts []js_ast.Stmt, loc logger.Loc, s *js_ast.SIf) []js_ast.Stmt {
+	if result, ok := p.foldConstantIf(stmts, s); ok {
+		return result
+	}
 
 	if yes, ok := s.Yes.Data.(*js_ast.SExpr); ok {
 		// "yes" is an expression
@@ -8866,7 +9411,12 @@ func (p *parser) keepStmtSymbolName(loc logger.Loc, ref js_ast.Ref, name string)
 
 func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_ast.Stmt {
 	switch s := stmt.Data.(type) {
-	case *js_ast.SDebugger, *js_ast.SEmpty, *js_ast.SComment:
+	case *js_ast.SDebugger:
+		if p.options.dropDebugger {
+			return stmts
+		}
+
+	case *js_ast.SEmpty, *js_ast.SComment:
 		// These don't contain anything to traverse
 
 	case *js_ast.STypeScript:
@@ -9098,6 +9648,7 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		p.popScope()
 
 	case *js_ast.SLocal:
+		s.Decls = p.expandEnvDestructuring(s.Decls)
 		for i := range s.Decls {
 			d := &s.Decls[i]
 			p.visitBinding(d.Binding, bindingOpts{})
@@ -9107,6 +9658,9 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 
 				// Optionally preserve the name
 				if id, ok := d.Binding.Data.(*js_ast.BIdentifier); ok {
+					if !s.IsExport {
+						p.recordInstanceForMemberTreeShaking(id.Ref, d.ValueOrNil)
+					}
 					d.ValueOrNil = p.maybeKeepExprSymbolName(
 						d.ValueOrNil, p.symbols[id.Ref.InnerIndex].OriginalName, wasAnonymousNamedExpr)
 				}
@@ -9172,9 +9726,15 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SExpr:
+		isDroppedConsoleCall := p.isDroppedConsoleCall(s.Value)
 		p.stmtExprValue = s.Value.Data
 		s.Value = p.visitExpr(s.Value)
 
+		// Remove the whole statement instead of leaving "void 0" behind
+		if isDroppedConsoleCall {
+			return stmts
+		}
+
 		// Trim expressions without side effects
 		if p.options.mangleSyntax {
 			s.Value = p.simplifyUnusedExpr(s.Value)
@@ -9269,6 +9829,14 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SIf:
+		// Blocks such as "if (import.meta.vitest) { ... }" can be removed. The
+		// body is still visited as dead code to keep the scopes in order.
+		isStripped := p.isStripIfCondition(s.Test)
+		if isStripped {
+			s.Test = js_ast.Expr{Loc: s.Test.Loc, Data: &js_ast.EBoolean{Value: false}}
+		}
+
+		featureFlagUsesStart := len(p.featureFlagUses)
 		s.Test = p.visitExpr(s.Test)
 
 		if p.options.mangleSyntax {
@@ -9277,6 +9845,10 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 
 		// Fold constants
 		boolean, _, ok := toBooleanWithSideEffects(s.Test.Data)
+		isFeatureFlagTest := ok && len(p.featureFlagUses) > featureFlagUsesStart
+		if isFeatureFlagTest {
+			p.recordFeatureFlagBranch(featureFlagUsesStart, boolean, s.Yes.Loc, s.NoOrNil.Loc, p.ifStmtBranchEnds[s])
+		}
 
 		// Mark the control flow as dead if the branch is never taken
 		if ok && !boolean {
@@ -9308,6 +9880,20 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 			}
 		}
 
+		if isStripped && !shouldKeepStmtInDeadControlFlow(s.Yes) {
+			if s.NoOrNil.Data == nil {
+				return stmts
+			}
+			return appendIfBodyPreservingScope(stmts, s.NoOrNil)
+		}
+
+		// Dead branches of feature flag checks are removed even when not minifying
+		if isFeatureFlagTest && !p.options.mangleSyntax {
+			if result, ok := p.foldConstantIf(stmts, s); ok {
+				return result
+			}
+		}
+
 		if p.options.mangleSyntax {
 			return p.mangleIf(stmts, stmt.Loc, s)
 		}
@@ -9361,6 +9947,7 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		p.visitForLoopInit(s.Init, true)
 		s.Value = p.visitExpr(s.Value)
 		s.Body = p.visitLoopBody(s.Body)
+		p.markDynamicPropertyAccessForMemberTreeShaking()
 
 		// Check for a variable initializer
 		if local, ok := s.Init.Data.(*js_ast.SLocal); ok && local.Kind == js_ast.LocalVar && len(local.Decls) == 1 {
@@ -9462,6 +10049,9 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		}
 
 	case *js_ast.SFunction:
+		if s.Fn.Name != nil {
+			p.recordDeclarationForPureInference(s.Fn.Name.Ref)
+		}
 		p.visitFn(&s.Fn, s.Fn.OpenParenLoc)
 
 		// Handle exporting this function from a namespace
@@ -9538,6 +10128,12 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 		// Update the exported members of this enum as we constant fold each one
 		exportedMembers := p.currentScope.TSNamespace.ExportedMembers
 
+		// Keep track of whether this enum can be a frozen object instead
+		canBeFrozen := p.options.ts.Enums != config.TSEnumsClassic
+		properties := make([]js_ast.Property, 0, len(s.Values))
+		sizes := makeTSEnumSizes(p.symbols[s.Name.Ref.InnerIndex].OriginalName)
+		argUses := 0
+
 		// We normally don't fold numeric constants because they might increase code
 		// size, but it's important to fold numeric constants inside enums since
 		// that's what the TypeScript compiler does.
@@ -9586,6 +10182,19 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 				value.ValueOrNil = js_ast.Expr{Loc: value.Loc, Data: js_ast.EUndefinedShared}
 			}
 
+			if canBeFrozen {
+				// Setting "__proto__" in an object literal changes the prototype instead
+				if valueBytes, ok := tsEnumValueSize(value.ValueOrNil); ok && name != "__proto__" {
+					sizes.add(name, valueBytes, hasStringValue)
+					properties = append(properties, js_ast.Property{
+						Key:        js_ast.Expr{Loc: value.Loc, Data: &js_ast.EString{Value: value.Name}},
+						ValueOrNil: value.ValueOrNil,
+					})
+				} else {
+					canBeFrozen = false
+				}
+			}
+
 			if p.options.mangleSyntax && js_lexer.IsIdentifier(name) {
 				// "Enum.Name = value"
 				assignTarget = js_ast.Assign(
@@ -9607,6 +10216,7 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 				)
 			}
 			p.recordUsage(s.Arg)
+			argUses++
 
 			// String-valued enums do not form a two-way map
 			if hasStringValue {
@@ -9621,12 +10231,38 @@ func (p *parser) visitAndAppendStmt(stmts []js_ast.Stmt, stmt js_ast.Stmt) []js_
 					js_ast.Expr{Loc: value.Loc, Data: &js_ast.EString{Value: value.Name}},
 				))
 				p.recordUsage(s.Arg)
+				argUses++
 			}
 		}
 
 		p.popScope()
 		p.shouldFoldNumericConstants = oldShouldFoldNumericConstants
 
+		// Enums that are merged with other declarations must stay classic enums
+		// since the other declarations add properties to the enum object
+		if canBeFrozen && p.canEmitFrozenTSEnum(s.Name.Ref, exportedMembers, len(s.Values)) {
+			for i := 0; i < argUses; i++ {
+				p.ignoreUsage(s.Arg)
+			}
+			var local *js_ast.SLocal
+			stmts, local = p.generateFrozenObjectForTypeScriptEnum(stmts, stmt.Loc, s.IsExport, s.Name.Loc, s.Name.Ref, properties)
+			p.tsEnumSummary.Frozen++
+			p.tsEnumSummary.ClassicBytes += sizes.classic
+			p.tsEnumSummary.ActualBytes += sizes.frozen
+
+			// Enums that aren't exported might not be needed at all if every use
+			// of the enum is inlined, which is only known after visiting the file
+			if p.options.ts.Enums == config.TSEnumsInline && !s.IsExport {
+				p.inlinableTSEnums = append(p.inlinableTSEnums, inlinableTSEnum{ref: s.Name.Ref, local: local, bytes: sizes.frozen})
+			}
+			return stmts
+		}
+		if p.options.ts.Enums != config.TSEnumsClassic {
+			p.tsEnumSummary.Classic++
+			p.tsEnumSummary.ClassicBytes += sizes.classic
+			p.tsEnumSummary.ActualBytes += sizes.classic
+		}
+
 		// Wrap this enum definition in a closure
 		stmts = p.generateClosureForTypeScriptEnum(
 			stmts, stmt.Loc, s.IsExport, s.Name.Loc, s.Name.Ref, s.Arg, valueExprs, allValuesArePure)
@@ -10008,12 +10644,14 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 
 	if class.ExtendsOrNil.Data != nil {
 		class.ExtendsOrNil = p.visitExpr(class.ExtendsOrNil)
+		p.recordSubclassForMemberTreeShaking(class.ExtendsOrNil, class.BodyLoc)
 	}
 
 	// A scope is needed for private identifiers
 	p.pushScopeForVisitPass(js_ast.ScopeClassBody, class.BodyLoc)
 	defer p.popScope()
 
+	thisUses := p.memberThisUses
 	end := 0
 
 	for i := range class.Properties {
@@ -10161,6 +10799,7 @@ func (p *parser) visitClass(nameScopeLoc logger.Loc, class *js_ast.Class) js_ast
 
 	p.enclosingClassKeyword = oldEnclosingClassKeyword
 	p.popScope()
+	p.recordThisEscapesForMemberTreeShaking(class.BodyLoc, thisUses)
 
 	if shadowRef != js_ast.InvalidRef {
 		if p.symbols[shadowRef.InnerIndex].UseCountEstimate == 0 {
@@ -10351,17 +10990,47 @@ func (p *parser) jsxStringsToMemberExpression(loc logger.Loc, parts []string) js
 }
 
 func (p *parser) checkForUnrepresentableIdentifier(loc logger.Loc, name string) {
-	if p.options.asciiOnly && p.options.unsupportedJSFeatures.Has(compat.UnicodeEscapes) &&
-		js_lexer.ContainsNonBMPCodePoint(name) {
-		if p.unrepresentableIdentifiers == nil {
-			p.unrepresentableIdentifiers = make(map[string]bool)
-		}
-		if !p.unrepresentableIdentifiers[name] {
-			p.unrepresentableIdentifiers[name] = true
-			where, notes := p.prettyPrintTargetEnvironment(compat.UnicodeEscapes)
-			r := js_lexer.RangeOfIdentifier(p.source, loc)
-			p.log.AddWithNotes(logger.Error, &p.tracker, r, fmt.Sprintf("%q cannot be escaped in %s but you "+
-				"can set the charset to \"utf8\" to allow unescaped Unicode characters", name, where), notes)
+	// Non-BMP code points can only be escaped in identifiers using "\u{...}".
+	// This must use the same logic as the printer to decide what's escaped.
+	if p.options.unsupportedJSFeatures.Has(compat.UnicodeEscapes) && js_lexer.ContainsNonBMPCodePoint(name) {
+		for _, c := range name {
+			if c <= 0xFFFF || !p.options.identifierCharset.MustEscape(c, p.options.asciiOnly, p.options.charsetEscapes) {
+				continue
+			}
+			if p.unrepresentableIdentifiers == nil {
+				p.unrepresentableIdentifiers = make(map[string]bool)
+			}
+			if !p.unrepresentableIdentifiers[name] {
+				p.unrepresentableIdentifiers[name] = true
+				setting := "the identifier charset"
+				if p.options.identifierCharset == config.IdentifierCharsetSameAsStrings && !p.options.charsetEscapes.ShouldEscape(c) {
+					setting = "the charset"
+				}
+				where, notes := p.prettyPrintTargetEnvironment(compat.UnicodeEscapes)
+				r := js_lexer.RangeOfIdentifier(p.source, loc)
+				p.log.AddWithNotes(logger.Error, &p.tracker, r, fmt.Sprintf("%q cannot be escaped in %s but you "+
+					"can set %s to \"utf8\" to allow unescaped Unicode characters", name, where, setting), notes)
+			}
+			break
+		}
+	}
+
+	// Identifiers that only differ in their normalization form are different
+	// identifiers in JavaScript, which is easy to get wrong
+	if normalization := p.options.identifierNormalization; normalization != config.NormalizationNone {
+		isNormalized := js_lexer.IsNFC
+		if normalization == config.NormalizationNFD {
+			isNormalized = js_lexer.IsNFD
+		}
+		if !isNormalized(name) {
+			if p.unnormalizedIdentifiers == nil {
+				p.unnormalizedIdentifiers = make(map[string]bool)
+			}
+			if !p.unnormalizedIdentifiers[name] {
+				p.unnormalizedIdentifiers[name] = true
+				r := js_lexer.RangeOfIdentifier(p.source, loc)
+				p.log.Add(logger.Error, &p.tracker, r, fmt.Sprintf("%q is not in Unicode normalization form %s", name, normalization))
+			}
 		}
 	}
 }
@@ -10638,10 +11307,12 @@ func (p *parser) maybeRewritePropertyAccess(
 				switch m := member.Data.(type) {
 				case *js_ast.TSNamespaceMemberEnumNumber:
 					p.ignoreUsageOfIdentifierInDotChain(target)
+					p.recordInlinedTSEnumUse(target)
 					return p.wrapInlinedEnum(js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: m.Value}}, name), true
 
 				case *js_ast.TSNamespaceMemberEnumString:
 					p.ignoreUsageOfIdentifierInDotChain(target)
+					p.recordInlinedTSEnumUse(target)
 					return p.wrapInlinedEnum(js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: m.Value}}, name), true
 
 				case *js_ast.TSNamespaceMemberNamespace:
@@ -11096,7 +11767,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 	switch e := expr.Data.(type) {
 	case *js_ast.ENull, *js_ast.ESuper,
 		*js_ast.EBoolean, *js_ast.EBigInt,
-		*js_ast.ERegExp, *js_ast.EUndefined:
+		*js_ast.ERegExp, *js_ast.EUndefined, *js_ast.EMangledProp:
 
 	case *js_ast.ENewTarget:
 		if !p.fnOnlyDataVisit.isNewTargetAllowed {
@@ -11123,6 +11794,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 	case *js_ast.EThis:
 		isDeleteTarget := e == p.deleteTarget
 		isCallTarget := e == p.callTarget
+		p.recordThisUseForMemberTreeShaking()
 
 		if value, ok := p.valueForThis(expr.Loc, true /* shouldWarn */, in.assignTarget, isDeleteTarget, isCallTarget); ok {
 			return value, exprOut{}
@@ -11176,6 +11848,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 
 		// Handle assigning to a constant
 		if in.assignTarget != js_ast.AssignTargetNone {
+			p.recordAssignmentForPureInference(result.ref)
 			switch p.symbols[result.ref.InnerIndex].Kind {
 			case js_ast.SymbolConst:
 				r := js_lexer.RangeOfIdentifier(p.source, expr.Loc)
@@ -11217,6 +11890,9 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 					// Don't substitute an identifier for a non-identifier if this is an
 					// assignment target, since it'll cause a syntax error
 					if _, ok := new.Data.(*js_ast.EIdentifier); in.assignTarget == js_ast.AssignTargetNone || ok {
+						if data.FeatureFlag != "" {
+							p.recordFeatureFlagUse(data.FeatureFlag, expr.Loc)
+						}
 						p.ignoreUsage(e.Ref)
 						return new, exprOut{}
 					}
@@ -11270,7 +11946,9 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			}
 		}
 
-		if p.options.jsx.Preserve {
+		if p.options.jsx.AutomaticRuntime && !p.options.jsx.Preserve {
+			return p.lowerJSXElementAutomatic(expr.Loc, e), exprOut{}
+		} else if p.options.jsx.Preserve {
 			// If the tag is an identifier, mark it as needing to be upper-case
 			switch tag := e.TagOrNil.Data.(type) {
 			case *js_ast.EIdentifier:
@@ -11445,6 +12123,15 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			e.Right = p.visitExpr(e.Right)
 		}
 
+		// "x instanceof C" doesn't let "C" escape and "'x' in y" uses "x"
+		switch e.Op {
+		case js_ast.BinOpInstanceof:
+			p.recordSafeUseForMemberTreeShaking(e.Right)
+
+		case js_ast.BinOpIn:
+			p.recordPropertyKeyUsedForMemberTreeShaking(e.Left)
+		}
+
 		// Always put constants on the right for equality comparisons to help
 		// reduce the number of cases we have to check during pattern matching. We
 		// can only reorder expressions that do not have any side effects.
@@ -11877,9 +12564,10 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		isTemplateTag := e == p.templateTag
 		isDeleteTarget := e == p.deleteTarget
 
-		// "a['b']" => "a.b"
+		// "a['b']" => "a.b" (but quoted names are never mangled)
 		if p.options.mangleSyntax {
-			if str, ok := e.Index.Data.(*js_ast.EString); ok && js_lexer.IsIdentifierUTF16(str.Value) {
+			if str, ok := e.Index.Data.(*js_ast.EString); ok && js_lexer.IsIdentifierUTF16(str.Value) &&
+				!p.shouldMangleProp(js_lexer.UTF16ToString(str.Value)) {
 				dot := &js_ast.EDot{
 					Target:        e.Target,
 					Name:          js_lexer.UTF16ToString(str.Value),
@@ -11944,6 +12632,10 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			}
 		} else {
 			e.Index = p.visitExpr(e.Index)
+			p.recordIndexAccessForMemberTreeShaking(e.Target, e.Index)
+			if _, ok := e.Index.Data.(*js_ast.EString); ok {
+				p.recordReservedPropKey(e.Index)
+			}
 		}
 
 		// Lower "super[prop]" if necessary
@@ -12140,6 +12832,9 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				if p.isDotDefineMatch(expr, define.Parts) {
 					// Substitute user-specified defines
 					if define.Data.DefineFunc != nil {
+						if define.Data.FeatureFlag != "" {
+							p.recordFeatureFlagUse(define.Data.FeatureFlag, expr.Loc)
+						}
 						return p.valueForDefine(expr.Loc, define.Data.DefineFunc, identifierOpts{
 							assignTarget:   in.assignTarget,
 							isCallTarget:   isCallTarget,
@@ -12178,18 +12873,28 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			hasChainParent: e.OptionalChain == js_ast.OptionalChainContinue,
 		})
 		e.Target = target
+		p.recordPropertyAccessForMemberTreeShaking(e.Target, e.Name)
+
+		// "a.foo_" => "a[mangled foo_]" with "--mangle-props=_$"
+		key := p.propertyKeyForName(e.NameLoc, e.Name)
+		var mangled *js_ast.EIndex
+		if _, ok := key.Data.(*js_ast.EMangledProp); ok {
+			mangled = &js_ast.EIndex{Target: e.Target, Index: key, OptionalChain: e.OptionalChain}
+		}
 
 		// Lower "super.prop" if necessary
 		if e.OptionalChain == js_ast.OptionalChainNone && in.assignTarget == js_ast.AssignTargetNone &&
 			!isCallTarget && p.shouldLowerSuperPropertyAccess(e.Target) {
 			// "super.foo" => "__superGet('foo')"
-			key := js_ast.Expr{Loc: e.NameLoc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(e.Name)}}
 			return p.lowerSuperPropertyGet(expr.Loc, key), exprOut{}
 		}
 
 		// Lower optional chaining if we're the top of the chain
 		containsOptionalChain := e.OptionalChain != js_ast.OptionalChainNone
 		if containsOptionalChain && !in.hasChainParent {
+			if mangled != nil {
+				return p.lowerOptionalChain(js_ast.Expr{Loc: expr.Loc, Data: mangled}, in, out)
+			}
 			return p.lowerOptionalChain(expr, in, out)
 		}
 
@@ -12209,10 +12914,14 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				return value, out
 			}
 		}
+		if mangled != nil {
+			return js_ast.Expr{Loc: expr.Loc, Data: mangled}, out
+		}
 		return js_ast.Expr{Loc: expr.Loc, Data: e}, out
 
 	case *js_ast.EIf:
 		isCallTarget := e == p.callTarget
+		featureFlagUsesStart := len(p.featureFlagUses)
 		e.Test = p.visitExpr(e.Test)
 
 		if p.options.mangleSyntax {
@@ -12224,6 +12933,13 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			e.Yes = p.visitExpr(e.Yes)
 			e.No = p.visitExpr(e.No)
 		} else {
+			// Dead branches of feature flag checks are removed even when not minifying
+			fold := p.options.mangleSyntax
+			if len(p.featureFlagUses) > featureFlagUsesStart {
+				p.recordFeatureFlagBranch(featureFlagUsesStart, boolean, e.Yes.Loc, e.No.Loc, p.ifExprBranchEnds[e])
+				fold = true
+			}
+
 			// Mark the control flow as dead if the branch is never taken
 			if boolean {
 				// "true ? live : dead"
@@ -12233,7 +12949,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				e.No = p.visitExpr(e.No)
 				p.isControlFlowDead = old
 
-				if p.options.mangleSyntax {
+				if fold {
 					// "(a, true) ? b : c" => "a, b"
 					if sideEffects == couldHaveSideEffects {
 						return js_ast.JoinWithComma(p.simplifyUnusedExpr(e.Test), e.Yes), exprOut{}
@@ -12256,7 +12972,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				p.isControlFlowDead = old
 				e.No = p.visitExpr(e.No)
 
-				if p.options.mangleSyntax {
+				if fold {
 					// "(a, false) ? b : c" => "a, c"
 					if sideEffects == couldHaveSideEffects {
 						return js_ast.JoinWithComma(p.simplifyUnusedExpr(e.Test), e.No), exprOut{}
@@ -12338,14 +13054,21 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			}
 			p.markSyntaxFeature(compat.Destructuring, logger.Range{Loc: expr.Loc, Len: 1})
 		}
+		if in.assignTarget == js_ast.AssignTargetNone {
+			e.Properties = p.expandEnvSpreads(e.Properties)
+		}
 		hasSpread := false
 		protoRange := logger.Range{}
+		thisUses := p.memberThisUses
 		for i := range e.Properties {
 			property := &e.Properties[i]
 
 			if property.Kind != js_ast.PropertySpread {
 				key := p.visitExpr(property.Key)
 				e.Properties[i].Key = key
+				if in.assignTarget != js_ast.AssignTargetNone {
+					p.recordPropertyKeyUsedForMemberTreeShaking(key)
+				}
 
 				// Forbid duplicate "__proto__" properties according to the specification
 				if !property.IsComputed && !property.WasShorthand && !property.IsMethod && in.assignTarget == js_ast.AssignTargetNone {
@@ -12437,6 +13160,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				}
 			}
 		}
+		p.recordThisEscapesForMemberTreeShaking(expr.Loc, thisUses)
 
 		if in.assignTarget == js_ast.AssignTargetNone {
 			// "{a, ...{b, c}, d}" => "{a, b, c, d}"
@@ -12613,10 +13337,24 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 				}}
 			}
 
-			// Use a debug log so people can see this if they want to
+			// Bundle every file that a partially-dynamic path could refer to
+			handlesImportErrors := (isAwaitTarget && p.fnOrArrowDataVisit.tryBodyCount != 0) || isThenCatchTarget
+			if value, ok := p.maybeRequireContext(expr.Loc, arg, ast.ImportDynamic, assertions, handlesImportErrors); ok {
+				return value
+			}
+
+			// Warn about fully-dynamic paths when dynamic paths are being bundled
+			// since this is likely a problem. Otherwise use a debug log so people
+			// can see this if they want to.
 			r := js_lexer.RangeOfIdentifier(p.source, expr.Loc)
-			p.log.Add(logger.Debug, &p.tracker, r,
-				"This \"import\" expression will not be bundled because the argument is not a string literal")
+			if p.options.expandRequireContext != nil && !handlesImportErrors && !p.suppressWarningsAboutWeirdCode {
+				p.log.AddWithNotes(logger.Warning, &p.tracker, r,
+					"This \"import\" expression will not be bundled because the argument has no static prefix",
+					[]logger.MsgData{{Text: "Only paths that start with a relative path such as \"./dir/\" can be bundled with \"--bundle-dynamic-paths\"."}})
+			} else {
+				p.log.Add(logger.Debug, &p.tracker, r,
+					"This \"import\" expression will not be bundled because the argument is not a string literal")
+			}
 
 			// We need to convert this into a call to "require()" if ES6 syntax is
 			// not supported in the current output format. The full conversion:
@@ -12634,6 +13372,22 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			// and the linker currently need an import record to handle this case
 			// correctly, and you need a string literal to get an import record.
 			if p.options.unsupportedJSFeatures.Has(compat.DynamicImport) {
+				// Browsers don't have "require()", so "--dynamic-import-loader=script"
+				// loads a script tag instead:
+				//
+				//   __loadScript(foo)
+				//
+				// The printer does this conversion because the linker decides whether
+				// the script is a module. The runtime helper just needs to be marked
+				// as used here.
+				if config.ShouldCallRuntimeLoadScript(p.options.mode, p.options.outputFormat, p.options.dynamicImportLoader) {
+					p.importFromRuntime(arg.Loc, "__loadScript")
+					return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.EImportCall{
+						Expr:                    arg,
+						LeadingInteriorComments: e.LeadingInteriorComments,
+					}}
+				}
+
 				var then js_ast.Expr
 				value := p.callRuntime(arg.Loc, "__toModule", []js_ast.Expr{{Loc: expr.Loc, Data: &js_ast.ECall{
 					Target: p.valueToSubstituteForRequire(expr.Loc),
@@ -12669,6 +13423,19 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 		}), exprOut{}
 
 	case *js_ast.ECall:
+		// Calls removed by "--drop:console" are still visited as dead code to
+		// keep the scopes in order. The arguments are never evaluated.
+		if p.isDroppedConsoleCall(expr) {
+			old := p.isControlFlowDead
+			p.isControlFlowDead = true
+			p.visitExpr(e.Target)
+			for _, arg := range e.Args {
+				p.visitExpr(arg)
+			}
+			p.isControlFlowDead = old
+			return js_ast.Expr{Loc: expr.Loc, Data: js_ast.EUndefinedShared}, exprOut{}
+		}
+
 		p.callTarget = e.Target.Data
 
 		// Track ".then().catch()" chains
@@ -12807,6 +13574,7 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 			if t.CallCanBeUnwrappedIfUnused {
 				e.CanBeUnwrappedIfUnused = true
 			}
+			p.recordCallForPureInference(t, e)
 		case *js_ast.EDot:
 			if t.CallCanBeUnwrappedIfUnused {
 				e.CanBeUnwrappedIfUnused = true
@@ -12875,10 +13643,23 @@ func (p *parser) visitExprInOut(expr js_ast.Expr, in exprIn) (js_ast.Expr, exprO
 								}}
 							}
 
-							// Use a debug log so people can see this if they want to
+							// Bundle every file that a partially-dynamic path could refer to
+							if value, ok := p.maybeRequireContext(expr.Loc, arg, ast.ImportRequire, nil, omitWarnings); ok {
+								return value
+							}
+
+							// Warn about fully-dynamic paths when dynamic paths are being bundled
+							// since this is likely a problem. Otherwise use a debug log so people
+							// can see this if they want to.
 							r := js_lexer.RangeOfIdentifier(p.source, e.Target.Loc)
-							p.log.Add(logger.Debug, &p.tracker, r,
-								"This call to \"require\" will not be bundled because the argument is not a string literal")
+							if p.options.expandRequireContext != nil && !omitWarnings && !p.suppressWarningsAboutWeirdCode {
+								p.log.AddWithNotes(logger.Warning, &p.tracker, r,
+									"This call to \"require\" will not be bundled because the argument has no static prefix",
+									[]logger.MsgData{{Text: "Only paths that start with a relative path such as \"./dir/\" can be bundled with \"--bundle-dynamic-paths\"."}})
+							} else {
+								p.log.Add(logger.Debug, &p.tracker, r,
+									"This call to \"require\" will not be bundled because the argument is not a string literal")
+							}
 
 							// Otherwise just return a clone of the "require()" call
 							return js_ast.Expr{Loc: expr.Loc, Data: &js_ast.ECall{
@@ -14416,6 +15197,31 @@ func newParser(log logger.Log, source logger.Source, lexer js_lexer.Lexer, optio
 		suppressWarningsAboutWeirdCode: helpers.IsInsideNodeModules(source.KeyPath.Text),
 	}
 
+	// Member tree shaking relies on top-level symbols being private to the file,
+	// which is only the case when bundling
+	if options.treeShakingMembers && options.mode == config.ModeBundle {
+		p.memberTotalUseCounts = make(map[js_ast.Ref]uint32)
+		p.memberSafeUseCounts = make(map[js_ast.Ref]uint32)
+		p.memberThisEscapes = make(map[logger.Loc]bool)
+		p.propertyNamesUsed = make(map[string]bool)
+	}
+
+	if options.mangleProps != nil {
+		p.mangledPropCounts = make(map[string]uint32)
+		p.reservedPropNames = make(map[string]bool)
+	}
+
+	if options.defines.HasFeatureFlags {
+		p.ifStmtBranchEnds = make(map[*js_ast.SIf]branchEnds)
+		p.ifExprBranchEnds = make(map[*js_ast.EIf]branchEnds)
+	}
+
+	if options.inferPureFunctions {
+		p.callsForPureInference = make(map[js_ast.Ref][]*js_ast.ECall)
+		p.declarationCountsForPureInference = make(map[js_ast.Ref]int)
+		p.assignedSymbolsForPureInference = make(map[js_ast.Ref]bool)
+	}
+
 	p.findSymbolHelper = func(loc logger.Loc, name string) js_ast.Ref {
 		return p.findSymbol(loc, name).ref
 	}
@@ -14433,6 +15239,7 @@ func newParser(log logger.Log, source logger.Source, lexer js_lexer.Lexer, optio
 
 var defaultJSXFactory = []string{"React", "createElement"}
 var defaultJSXFragment = []string{"React", "Fragment"}
+var defaultJSXImportSource = "react"
 
 func Parse(log logger.Log, source logger.Source, options Options) (result js_ast.AST, ok bool) {
 	ok = true
@@ -14452,6 +15259,9 @@ func Parse(log logger.Log, source logger.Source, options Options) (result js_ast
 	if len(options.jsx.Fragment.Parts) == 0 && options.jsx.Fragment.Constant == nil {
 		options.jsx.Fragment = config.JSXExpr{Parts: defaultJSXFragment}
 	}
+	if options.jsx.ImportSource == "" {
+		options.jsx.ImportSource = defaultJSXImportSource
+	}
 
 	if !options.ts.Parse {
 		// Non-TypeScript files always get the real JavaScript class field behavior
@@ -14482,7 +15292,13 @@ func Parse(log logger.Log, source logger.Source, options Options) (result js_ast
 		options.unsupportedJSFeatures |= options.tsTarget.UnsupportedJSFeatures
 	}
 
-	p := newParser(log, source, js_lexer.NewLexer(log, source), &options)
+	// Code between strip markers is replaced with whitespace so that the
+	// locations of the remaining code don't change
+	if len(options.stripBetween) > 0 {
+		source.Contents = stripBetweenMarkers(log, source, options.stripBetween)
+	}
+
+	p := newParser(log, source, js_lexer.NewLexerWithPragmas(log, source, options.customPragmas, options.preserveComments), &options)
 
 	// Consume a leading hashbang comment
 	hashbang := ""
@@ -14500,6 +15316,16 @@ func Parse(log logger.Log, source logger.Source, options Options) (result js_ast
 		isModuleScope:          true,
 		allowDirectivePrologue: true,
 	})
+
+	for _, msg := range p.lexer.DeferredErrors {
+		p.log.AddMsg(msg)
+	}
+
+	// Syntax errors that were recovered from still mean the file failed to
+	// parse. The AST is incomplete at this point so don't try to visit it.
+	if p.syntaxErrorCount > 0 {
+		panic(js_lexer.LexerPanic{})
+	}
 	p.prepareForVisitPass()
 
 	// Strip off a leading "use strict" directive when not bundling
@@ -14575,7 +15401,7 @@ func Parse(log logger.Log, source logger.Source, options Options) (result js_ast
 				}
 			}
 		}
-		before = p.generateImportStmt(file.Source.KeyPath.Text, exportsNoConflict, file.Source.Index, before, symbols)
+		before = p.generateImportStmt(file.Source.KeyPath.Text, logger.Loc{}, exportsNoConflict, ast.MakeIndex32(file.Source.Index), before, symbols)
 	}
 
 	// Bind symbols in a second pass over the AST. I started off doing this in a
@@ -14631,6 +15457,7 @@ func Parse(log logger.Log, source logger.Source, options Options) (result js_ast
 	p.popScope()
 
 	parts = append(append(before, parts...), after...)
+	p.inferPureFunctions(parts)
 	result = p.toAST(parts, hashbang, directive)
 	result.SourceMapComment = p.lexer.SourceMappingURL
 	return
@@ -14792,6 +15619,22 @@ func (p *parser) prepareForVisitPass() {
 		} else if len(expr.Parts) > 0 || expr.Constant != nil {
 			p.options.jsx.Fragment = expr
 		}
+
+		// Handle "@jsxRuntime" and "@jsxImportSource" pragmas too
+		switch p.lexer.JSXRuntimePragmaComment.Text {
+		case "":
+		case "automatic":
+			p.options.jsx.AutomaticRuntime = !p.options.jsx.Preserve
+		case "classic":
+			p.options.jsx.AutomaticRuntime = false
+		default:
+			p.log.AddWithNotes(logger.Warning, &p.tracker, p.lexer.JSXRuntimePragmaComment.Range,
+				fmt.Sprintf("Invalid JSX runtime: %s", p.lexer.JSXRuntimePragmaComment.Text),
+				[]logger.MsgData{{Text: "The JSX runtime can only be set to either \"classic\" or \"automatic\"."}})
+		}
+		if text := p.lexer.JSXImportSourcePragmaComment.Text; text != "" {
+			p.options.jsx.ImportSource = text
+		}
 	}
 }
 
@@ -14882,10 +15725,20 @@ func (p *parser) computeCharacterFrequency() *js_ast.CharFreq {
 	return charFreq
 }
 
+func sortedKeysOfRefMap(refs map[string]js_ast.Ref) []string {
+	keys := make([]string, 0, len(refs))
+	for key := range refs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (p *parser) generateImportStmt(
 	path string,
+	loc logger.Loc,
 	imports []string,
-	sourceIndex uint32,
+	sourceIndex ast.Index32,
 	parts []js_ast.Part,
 	symbols map[string]js_ast.Ref,
 ) []js_ast.Part {
@@ -14893,8 +15746,8 @@ func (p *parser) generateImportStmt(
 	p.moduleScope.Generated = append(p.moduleScope.Generated, namespaceRef)
 	declaredSymbols := make([]js_ast.DeclaredSymbol, len(imports))
 	clauseItems := make([]js_ast.ClauseItem, len(imports))
-	importRecordIndex := p.addImportRecord(ast.ImportStmt, logger.Loc{}, path, nil)
-	p.importRecords[importRecordIndex].SourceIndex = ast.MakeIndex32(sourceIndex)
+	importRecordIndex := p.addImportRecord(ast.ImportStmt, loc, path, nil)
+	p.importRecords[importRecordIndex].SourceIndex = sourceIndex
 
 	// Create per-import information
 	for i, alias := range imports {
@@ -14923,15 +15776,36 @@ func (p *parser) generateImportStmt(
 }
 
 func (p *parser) toAST(parts []js_ast.Part, hashbang string, directive string) js_ast.AST {
+	// Remove enums that were completely inlined. This must happen before any
+	// runtime imports are generated since the enums may use the runtime.
+	if len(p.inlinableTSEnums) > 0 {
+		parts = p.removeInlinedTSEnums(parts)
+	}
+
 	// Insert an import statement for any runtime imports we generated
 	if len(p.runtimeImports) > 0 && !p.options.omitRuntimeForTests {
 		// Sort the imports for determinism
-		keys := make([]string, 0, len(p.runtimeImports))
-		for key := range p.runtimeImports {
-			keys = append(keys, key)
+		keys := sortedKeysOfRefMap(p.runtimeImports)
+		parts = p.generateImportStmt("<runtime>", logger.Loc{}, keys, ast.MakeIndex32(runtime.SourceIndex), parts, p.runtimeImports)
+	}
+
+	// Insert import statements for the automatic JSX runtime right after the
+	// namespace export part. Unlike runtime imports, these are user-visible
+	// and still need to be resolved, so they go at the top of the file.
+	if len(p.jsxRuntimeImports) > 0 || len(p.jsxLegacyImports) > 0 {
+		var jsxParts []js_ast.Part
+		if len(p.jsxRuntimeImports) > 0 {
+			jsxParts = p.generateImportStmt(p.options.jsx.ImportSource+"/jsx-runtime", p.firstJSXElementLoc,
+				sortedKeysOfRefMap(p.jsxRuntimeImports), ast.Index32{}, jsxParts, p.jsxRuntimeImports)
+		}
+		if len(p.jsxLegacyImports) > 0 {
+			jsxParts = p.generateImportStmt(p.options.jsx.ImportSource, p.firstJSXElementLoc,
+				sortedKeysOfRefMap(p.jsxLegacyImports), ast.Index32{}, jsxParts, p.jsxLegacyImports)
+		}
+		for _, part := range jsxParts {
+			part.Stmts[0].Data.(*js_ast.SImport).IsSingleLine = true
 		}
-		sort.Strings(keys)
-		parts = p.generateImportStmt("<runtime>", keys, runtime.SourceIndex, parts, p.runtimeImports)
+		parts = append(parts[:js_ast.NSExportPartIndex+1], append(jsxParts, parts[js_ast.NSExportPartIndex+1:]...)...)
 	}
 
 	// Handle import paths after the whole file has been visited because we need
@@ -15107,6 +15981,7 @@ func (p *parser) toAST(parts []js_ast.Part, hashbang string, directive string) j
 		ModuleRef:                       p.moduleRef,
 		WrapperRef:                      wrapperRef,
 		Hashbang:                        hashbang,
+		HasRequireContext:               p.hasRequireContext,
 		Directive:                       directive,
 		NamedImports:                    p.namedImports,
 		NamedExports:                    p.namedExports,
@@ -15115,6 +15990,14 @@ func (p *parser) toAST(parts []js_ast.Part, hashbang string, directive string) j
 		ExportStarImportRecords:         p.exportStarImportRecords,
 		ImportRecords:                   p.importRecords,
 		ApproximateLineCount:            int32(p.lexer.ApproximateNewlineCount) + 1,
+		PropertyNamesUsed:               p.propertyNamesUsed,
+		MangledPropCounts:               p.mangledPropCounts,
+		FeatureFlagUses:                 p.featureFlagUses,
+		TSEnums:                         p.tsEnumSummary,
+		ReservedPropNames:               p.reservedPropNames,
+		Pragmas:                         p.lexer.Pragmas,
+		RemovableMembers:                p.findRemovableMembers(parts),
+		UsesDynamicPropertyAccess:       p.usesDynamicPropertyAccess,
 
 		// CommonJS features
 		UsesExportsRef: usesExportsRef,
@@ -1740,6 +1740,9 @@ func (p *parser) captureKeyForObjectRest(originalKey js_ast.Expr) (finalKey js_a
 	case *js_ast.EString:
 		capturedKey = func() js_ast.Expr { return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: k.Value}} }
 
+	case *js_ast.EMangledProp:
+		capturedKey = func() js_ast.Expr { return js_ast.Expr{Loc: loc, Data: &js_ast.EMangledProp{Name: k.Name}} }
+
 	case *js_ast.ENumber:
 		// Emit it as the number plus a string (i.e. call toString() on it).
 		// It's important to do it this way instead of trying to print the
@@ -2436,12 +2439,15 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 						for _, arg := range ctor.Fn.Args {
 							if arg.IsTypeScriptCtorField {
 								if id, ok := arg.Binding.Data.(*js_ast.BIdentifier); ok {
+									target := js_ast.Expr{Loc: arg.Binding.Loc, Data: js_ast.EThisShared}
+									name := p.symbols[id.Ref.InnerIndex].OriginalName
+									var field js_ast.E = &js_ast.EDot{Target: target, Name: name, NameLoc: arg.Binding.Loc}
+									key := p.propertyKeyForName(arg.Binding.Loc, name)
+									if _, ok := key.Data.(*js_ast.EMangledProp); ok {
+										field = &js_ast.EIndex{Target: target, Index: key}
+									}
 									parameterFields = append(parameterFields, js_ast.AssignStmt(
-										js_ast.Expr{Loc: arg.Binding.Loc, Data: &js_ast.EDot{
-											Target:  js_ast.Expr{Loc: arg.Binding.Loc, Data: js_ast.EThisShared},
-											Name:    p.symbols[id.Ref.InnerIndex].OriginalName,
-											NameLoc: arg.Binding.Loc,
-										}},
+										js_ast.Expr{Loc: arg.Binding.Loc, Data: field},
 										js_ast.Expr{Loc: arg.Binding.Loc, Data: &js_ast.EIdentifier{Ref: id.Ref}},
 									))
 								}
@@ -2581,6 +2587,10 @@ func (p *parser) lowerClass(stmt js_ast.Stmt, expr js_ast.Expr, shadowRef js_ast
 		generatedLocalStmt = true
 		name := nameFunc()
 		nameRef := name.Data.(*js_ast.EIdentifier).Ref
+
+		// This name is only used to declare the class, which doesn't let the
+		// class escape as far as member tree shaking is concerned
+		p.recordSafeUseForMemberTreeShaking(name)
 		nameForClassDecorators = js_ast.LocRef{Loc: name.Loc, Ref: nameRef}
 		classExpr := js_ast.EClass{Class: *class}
 		class = &classExpr.Class
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"unicode"
 
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
@@ -68,6 +69,8 @@ func expectPrintedCommon(t *testing.T, contents string, expected string, options
 		js := js_printer.Print(tree, symbols, r, js_printer.Options{
 			UnsupportedFeatures: options.UnsupportedJSFeatures,
 			ASCIIOnly:           options.ASCIIOnly,
+			CharsetEscapes:      options.CharsetEscapes,
+			IdentifierCharset:   options.IdentifierCharset,
 		}).JS
 		test.AssertEqualWithDiff(t, string(js), expected)
 	})
@@ -85,6 +88,13 @@ func expectPrintedMangle(t *testing.T, contents string, expected string) {
 	})
 }
 
+func expectPrintedInferPure(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectPrintedCommon(t, contents, expected, config.Options{
+		InferPureFunctions: true,
+	})
+}
+
 func expectPrintedTarget(t *testing.T, esVersion int, contents string, expected string) {
 	t.Helper()
 	expectPrintedCommon(t, contents, expected, config.Options{
@@ -149,6 +159,17 @@ func expectPrintedJSX(t *testing.T, contents string, expected string) {
 	})
 }
 
+func expectPrintedJSXAutomatic(t *testing.T, importSource string, contents string, expected string) {
+	t.Helper()
+	expectPrintedCommon(t, contents, expected, config.Options{
+		JSX: config.JSXOptions{
+			Parse:            true,
+			AutomaticRuntime: true,
+			ImportSource:     importSource,
+		},
+	})
+}
+
 func expectParseErrorTargetJSX(t *testing.T, esVersion int, contents string, expected string) {
 	t.Helper()
 	expectParseErrorCommon(t, contents, expected, config.Options{
@@ -229,6 +250,44 @@ func TestComments(t *testing.T) {
 	expectPrinted(t, "if(x-->y)z", "if (x-- > y)\n  z;\n")
 }
 
+func TestSyntaxErrorRecovery(t *testing.T) {
+	expectParseErrorRecover := func(t *testing.T, contents string, expected string) {
+		t.Helper()
+		expectParseErrorCommon(t, contents, expected, config.Options{RecoverSyntaxErrors: true})
+	}
+
+	expectParseErrorRecover(t, "let a = ;\nlet b = ;", "<stdin>: ERROR: Unexpected \";\"\n<stdin>: ERROR: Unexpected \";\"\n")
+	expectParseErrorRecover(t, "let a = 1 2; let b = 3 4", "<stdin>: ERROR: Expected \";\" but found \"2\"\n<stdin>: ERROR: Expected \";\" but found \"4\"\n")
+	expectParseErrorRecover(t, "}\nlet a = ;", "<stdin>: ERROR: Unexpected \"}\"\n<stdin>: ERROR: Unexpected \";\"\n")
+
+	// Recovery is opt-in
+	expectParseError(t, "let a = ;\nlet b = ;", "<stdin>: ERROR: Unexpected \";\"\n")
+
+	// Declarations from a statement that failed to parse are forgotten, but
+	// declarations from statements that parsed are still checked
+	expectParseErrorRecover(t, "let a = 1 2\nlet a = 3 4", "<stdin>: ERROR: Expected \";\" but found \"2\"\n<stdin>: ERROR: Expected \";\" but found \"4\"\n")
+	expectParseErrorRecover(t, "class a { x = 1 2 }\nlet a = ;", "<stdin>: ERROR: Expected \";\" but found \"2\"\n<stdin>: ERROR: Unexpected \";\"\n")
+	expectParseErrorRecover(t, "let a\nlet b = ;\nlet a", "<stdin>: ERROR: Unexpected \";\"\n<stdin>: ERROR: The symbol \"a\" has already been declared\n<stdin>: NOTE: The symbol \"a\" was originally declared here:\n")
+
+	// Only top-level statements are recovered, but errors inside blocks
+	// shouldn't affect the code after the block
+	expectParseErrorRecover(t, "function f() {\n  let a = 1 2\n  return a +\n}\nlet b = ;",
+		"<stdin>: ERROR: Expected \";\" but found \"2\"\n<stdin>: ERROR: Unexpected \";\"\n")
+	expectParseErrorRecover(t, "if (a {\n  b()\n}\nif (c) {\n  d(\n}\nconst e = ;",
+		"<stdin>: ERROR: Expected \")\" but found \"{\"\n<stdin>: ERROR: Unexpected \"}\"\n<stdin>: ERROR: Unexpected \";\"\n")
+
+	// Skipping over a statement should handle template literals and regular expressions
+	expectParseErrorRecover(t, "let a = `${b +}${c}`\nlet d = ;", "<stdin>: ERROR: Unexpected \"}\"\n<stdin>: ERROR: Unexpected \";\"\n")
+	expectParseErrorRecover(t, "let a = /[)};]/ +;\nlet b = ;", "<stdin>: ERROR: Unexpected \";\"\n<stdin>: ERROR: Unexpected \";\"\n")
+
+	// Errors from the lexer stop recovery
+	expectParseErrorRecover(t, "let a = \"\nlet b = ;", "<stdin>: ERROR: Unterminated string literal\n")
+
+	// The number of syntax errors per file can be limited
+	expectParseErrorCommon(t, "let a = ;\nlet b = ;\nlet c = ;", "<stdin>: ERROR: Unexpected \";\"\n<stdin>: ERROR: Unexpected \";\"\n",
+		config.Options{RecoverSyntaxErrors: true, SyntaxErrorLimit: 2})
+}
+
 func TestStrictMode(t *testing.T) {
 	useStrict := "<stdin>: NOTE: Strict mode is triggered by the \"use strict\" directive here:\n"
 
@@ -4072,6 +4131,47 @@ func TestPreservedComments(t *testing.T) {
 	expectPrinted(t, "x\u2029    /*!\u2029     * Re-indent test\u2029     */", "x;\n/*!\n * Re-indent test\n */\n")
 }
 
+func TestStripBlocks(t *testing.T) {
+	options := config.Options{
+		StripIf:      [][]string{{"import", "meta", "vitest"}, {"DEV"}},
+		StripBetween: []config.StripMarkers{{Start: "test:start", End: "test:end"}},
+	}
+
+	expectPrintedCommon(t, "if (import.meta.vitest) { test() }\nfoo()", "foo();\n", options)
+	expectPrintedCommon(t, "if (import.meta.vitest) test(); else foo()", "foo();\n", options)
+	expectPrintedCommon(t, "if (import.meta.vitest) {} else { let x = foo() }", "{\n  let x = foo();\n}\n", options)
+	expectPrintedCommon(t, "if (DEV) { var x = 1 }", "if (false) {\n  var x;\n}\n", options)
+	expectPrintedCommon(t, "let DEV; if (DEV) test()", "let DEV;\nif (DEV)\n  test();\n", options)
+	expectPrintedCommon(t, "if (import.meta.vitest.foo) test()", "if (import.meta.vitest.foo)\n  test();\n", options)
+	expectPrintedCommon(t, "if (!import.meta.vitest) foo()", "if (!import.meta.vitest)\n  foo();\n", options)
+
+	expectPrintedCommon(t, "a()\n/* test:start */\ntest()\n/* test:end */\nb()", "a();\nb();\n", options)
+	expectPrintedCommon(t, "a() // test:start\ntest() // test:end\nb()", "a();\nb();\n", options)
+	expectPrintedCommon(t, "a(/* test:start */ 1, /* test:end */ 2)", "a(2);\n", options)
+	expectPrintedCommon(t, "a()\n/* test:starting */\nb()\n/* test:end */", "a();\nb();\n", options)
+
+	expectParseErrorCommon(t, "a()\n/* test:start */\nb()",
+		"<stdin>: WARNING: The code after \"test:start\" was not removed because there is no \"test:end\" comment after it\n", options)
+}
+
+func TestDrop(t *testing.T) {
+	options := config.Options{
+		DropConsole:  true,
+		DropDebugger: true,
+	}
+
+	expectPrintedCommon(t, "console.log(foo()); bar()", "bar();\n", options)
+	expectPrintedCommon(t, "console['warn'](1); debugger; bar()", "bar();\n", options)
+	expectPrintedCommon(t, "if (x) console.log(x)", "if (x)\n  ;\n", options)
+	expectPrintedCommon(t, "let y = console.log(x)", "let y = void 0;\n", options)
+	expectPrintedCommon(t, "console.log(() => { let x = 1 }); foo(() => {})", "foo(() => {\n});\n", options)
+	expectPrintedCommon(t, "let console; console.log(x)", "let console;\nconsole.log(x);\n", options)
+	expectPrintedCommon(t, "console(x); x.console.log(x)", "console(x);\nx.console.log(x);\n", options)
+	expectPrintedCommon(t, "function f() { debugger }", "function f() {\n}\n", options)
+
+	expectPrinted(t, "console.log(x); debugger", "console.log(x);\ndebugger;\n")
+}
+
 func TestUnicodeWhitespace(t *testing.T) {
 	whitespace := []string{
 		"\u0009", // character tabulation
@@ -4384,6 +4484,35 @@ func TestJSXPragmas(t *testing.T) {
 	expectPrintedJSX(t, "/* @jsxFrag a.b.c */\n<></>", "/* @__PURE__ */ React.createElement(a.b.c, null);\n")
 }
 
+func TestJSXAutomatic(t *testing.T) {
+	expectPrintedJSXAutomatic(t, "", "<div/>",
+		"import { jsx as _jsx } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {});\n")
+	expectPrintedJSXAutomatic(t, "", "<div id='x'>text</div>",
+		"import { jsx as _jsx } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {\n  id: \"x\",\n  children: \"text\"\n});\n")
+	expectPrintedJSXAutomatic(t, "", "<div>a{b}</div>",
+		"import { jsxs as _jsxs } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsxs(\"div\", {\n  children: [\"a\", b]\n});\n")
+	expectPrintedJSXAutomatic(t, "", "<div key='k' id='x'/>",
+		"import { jsx as _jsx } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {\n  id: \"x\"\n}, \"k\");\n")
+	expectPrintedJSXAutomatic(t, "", "<div key='k' {...props}/>",
+		"import { jsx as _jsx } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {\n  ...props\n}, \"k\");\n")
+	expectPrintedJSXAutomatic(t, "", "<><a/><b/></>",
+		"import { Fragment as _Fragment, jsx as _jsx, jsxs as _jsxs } from \"react/jsx-runtime\";\n"+
+			"/* @__PURE__ */ _jsxs(_Fragment, {\n  children: [/* @__PURE__ */ _jsx(\"a\", {}), /* @__PURE__ */ _jsx(\"b\", {})]\n});\n")
+	expectPrintedJSXAutomatic(t, "preact", "<div/>",
+		"import { jsx as _jsx } from \"preact/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {});\n")
+
+	// A key after a spread must stay in the props object
+	expectPrintedJSXAutomatic(t, "", "<div {...props} key='k'>a</div>",
+		"import { createElement as _createElement } from \"react\";\n/* @__PURE__ */ _createElement(\"div\", {\n  ...props,\n  key: \"k\"\n}, \"a\");\n")
+
+	// Pragmas
+	expectPrintedJSXAutomatic(t, "", "// @jsxImportSource preact\n<div/>",
+		"import { jsx as _jsx } from \"preact/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {});\n")
+	expectPrintedJSXAutomatic(t, "", "/* @jsxRuntime classic */\n<div/>", "/* @__PURE__ */ React.createElement(\"div\", null);\n")
+	expectPrintedJSX(t, "/* @jsxRuntime automatic */\n<div/>",
+		"import { jsx as _jsx } from \"react/jsx-runtime\";\n/* @__PURE__ */ _jsx(\"div\", {});\n")
+}
+
 func TestPreserveOptionalChainParentheses(t *testing.T) {
 	expectPrinted(t, "a?.b.c", "a?.b.c;\n")
 	expectPrinted(t, "(a?.b).c", "(a?.b).c;\n")
@@ -4869,6 +4998,56 @@ func TestASCIIOnly(t *testing.T) {
 	expectParseErrorTargetASCII(t, 5, "export var 𐀀", es5)
 }
 
+func TestCharsetIdentifiers(t *testing.T) {
+	es5 := compat.UnsupportedJSFeatures(map[compat.Engine][]int{compat.ES: {5}})
+	identifierCharset := func(name string) string {
+		return "<stdin>: ERROR: \"" + name + "\" cannot be escaped in the configured target environment " +
+			"but you can set the identifier charset to \"utf8\" to allow unescaped Unicode characters\n"
+	}
+
+	// These must agree with the printer about which identifiers are escaped
+	nonBMP := config.Options{UnsupportedJSFeatures: es5, CharsetEscapes: config.CharsetEscapes{NonBMP: true}}
+	expectPrintedCommon(t, "var π = '𐀀'", "var π = \"\\uD800\\uDC00\";\n", nonBMP)
+	expectParseErrorCommon(t, "var 𐀀", identifierCharset("𐀀"), nonBMP)
+
+	han := config.Options{UnsupportedJSFeatures: es5, CharsetEscapes: config.CharsetEscapes{Scripts: []*unicode.RangeTable{unicode.Han}}}
+	expectPrintedCommon(t, "var 貓𐀀", "var \\u8C93𐀀;\n", han)
+	expectParseErrorCommon(t, "var 𠀀", identifierCharset("𠀀"), han)
+
+	asciiNonBMP := nonBMP
+	asciiNonBMP.ASCIIOnly = true
+	expectParseErrorCommon(t, "var 𐀀", identifierCharset("𐀀"), asciiNonBMP)
+
+	asciiIdentifiers := config.Options{UnsupportedJSFeatures: es5, IdentifierCharset: config.IdentifierCharsetASCII}
+	expectPrintedCommon(t, "var π = 'π'", "var \\u03C0 = \"π\";\n", asciiIdentifiers)
+	expectParseErrorCommon(t, "var 𐀀", identifierCharset("𐀀"), asciiIdentifiers)
+
+	utf8Identifiers := config.Options{UnsupportedJSFeatures: es5, ASCIIOnly: true, IdentifierCharset: config.IdentifierCharsetUTF8}
+	expectPrintedCommon(t, "var 𐀀 = '𐀀'", "var 𐀀 = \"\\uD800\\uDC00\";\n", utf8Identifiers)
+	expectPrintedCommon(t, "x.𐀀", "x[\"\\uD800\\uDC00\"];\n", utf8Identifiers)
+
+	utf8NonBMPIdentifiers := nonBMP
+	utf8NonBMPIdentifiers.IdentifierCharset = config.IdentifierCharsetUTF8
+	expectPrintedCommon(t, "var 𐀀 = '𐀀'", "var 𐀀 = \"\\uD800\\uDC00\";\n", utf8NonBMPIdentifiers)
+}
+
+func TestIdentifierNormalization(t *testing.T) {
+	nfc := config.Options{IdentifierNormalization: config.NormalizationNFC}
+	expectPrintedCommon(t, "var caf\u00E9", "var caf\u00E9;\n", nfc)
+	expectPrintedCommon(t, "var \uAC00", "var \uAC00;\n", nfc)
+	expectPrintedCommon(t, "var x\u0301 = 'e\u0301'", "var x\u0301 = \"e\u0301\";\n", nfc)
+	expectParseErrorCommon(t, "var cafe\u0301", "<stdin>: ERROR: \"cafe\u0301\" is not in Unicode normalization form NFC\n", nfc)
+	expectParseErrorCommon(t, "var caf\\u0065\\u0301", "<stdin>: ERROR: \"cafe\u0301\" is not in Unicode normalization form NFC\n", nfc)
+	expectParseErrorCommon(t, "var \u1100\u1161", "<stdin>: ERROR: \"\u1100\u1161\" is not in Unicode normalization form NFC\n", nfc)
+	expectParseErrorCommon(t, "cafe\u0301(); cafe\u0301()", "<stdin>: ERROR: \"cafe\u0301\" is not in Unicode normalization form NFC\n", nfc)
+	expectParseErrorCommon(t, "import { cafe\u0301 as x } from 'y'", "<stdin>: ERROR: \"cafe\u0301\" is not in Unicode normalization form NFC\n", nfc)
+
+	nfd := config.Options{IdentifierNormalization: config.NormalizationNFD}
+	expectPrintedCommon(t, "var cafe\u0301", "var cafe\u0301;\n", nfd)
+	expectParseErrorCommon(t, "var caf\u00E9", "<stdin>: ERROR: \"caf\u00E9\" is not in Unicode normalization form NFD\n", nfd)
+	expectParseErrorCommon(t, "var \uAC00", "<stdin>: ERROR: \"\uAC00\" is not in Unicode normalization form NFD\n", nfd)
+}
+
 func TestMangleCatch(t *testing.T) {
 	expectPrintedMangle(t, "try { throw 0 } catch (e) { console.log(0) }", "try {\n  throw 0;\n} catch {\n  console.log(0);\n}\n")
 	expectPrintedMangle(t, "try { throw 0 } catch (e) { console.log(0, e) }", "try {\n  throw 0;\n} catch (e) {\n  console.log(0, e);\n}\n")
@@ -4911,3 +5090,44 @@ func TestAutoPureForMap(t *testing.T) {
 	expectPrinted(t, "new Map([x, []])", "new Map([x, []]);\n")
 	expectPrinted(t, "new Map([[], x])", "new Map([[], x]);\n")
 }
+
+func TestInferPure(t *testing.T) {
+	expectPrintedInferPure(t, "function f(x) { return {x} } f(1); export {}",
+		"function f(x) {\n  return { x };\n}\n/* @__PURE__ */ f(1);\nexport {};\n")
+	expectPrintedInferPure(t, "const f = (x, y = []) => [x, y]; f(1); export {}",
+		"const f = (x, y = []) => [x, y];\n/* @__PURE__ */ f(1);\nexport {};\n")
+	expectPrintedInferPure(t, "const f = function(x) { if (x) return null; const y = x === 1 ? 'a' : 'b'; return y }; f(1); export {}",
+		"const f = function(x) {\n  if (x)\n    return null;\n  const y = x === 1 ? \"a\" : \"b\";\n  return y;\n};\n/* @__PURE__ */ f(1);\nexport {};\n")
+
+	// Calls before the declaration and calls to other pure functions
+	expectPrintedInferPure(t, "g(); function g() { return f() } function f() {} export {}",
+		"/* @__PURE__ */ g();\nfunction g() {\n  return /* @__PURE__ */ f();\n}\nfunction f() {\n}\nexport {};\n")
+
+	// Not pure
+	expectPrintedInferPure(t, "function f() { return x } f(); export {}",
+		"function f() {\n  return x;\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f(x) { return x.y } f(); export {}",
+		"function f(x) {\n  return x.y;\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f(x) { return x + 1 } f(); export {}",
+		"function f(x) {\n  return x + 1;\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f({x}) { return x } f(); export {}",
+		"function f({ x }) {\n  return x;\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f() { return f() } f(); export {}",
+		"function f() {\n  return f();\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "async function f() {} f(); export {}",
+		"async function f() {\n}\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function* f() {} f(); export {}",
+		"function* f() {\n}\nf();\nexport {};\n")
+
+	// Not pure if it may be replaced
+	expectPrintedInferPure(t, "function f() {} f = g; f(); export {}",
+		"function f() {\n}\nf = g;\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f() {} var f; f(); export {}",
+		"function f() {\n}\nvar f;\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "let f = () => {}; f(); export {}",
+		"let f = () => {\n};\nf();\nexport {};\n")
+	expectPrintedInferPure(t, "function f() {} f()",
+		"function f() {\n}\nf();\n")
+	expectPrintedInferPure(t, "function f() {} f(); eval(''); export {}",
+		"function f() {\n}\nf();\neval(\"\");\nexport {};\n")
+}
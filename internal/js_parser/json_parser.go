@@ -2,6 +2,7 @@ package js_parser
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/evanw/esbuild/internal/helpers"
 	"github.com/evanw/esbuild/internal/js_ast"
@@ -23,7 +24,7 @@ func (p *jsonParser) parseMaybeTrailingComma(closeToken js_lexer.T) bool {
 	p.lexer.Expect(js_lexer.TComma)
 
 	if p.lexer.Token == closeToken {
-		if !p.options.AllowTrailingCommas {
+		if !p.options.AllowTrailingCommas && !p.options.AllowJSON5 {
 			p.log.Add(logger.Error, &p.tracker, commaRange, "JSON does not support trailing commas")
 		}
 		return false
@@ -60,10 +61,27 @@ func (p *jsonParser) parseExpr() js_ast.Expr {
 
 	case js_lexer.TMinus:
 		p.lexer.Next()
-		value := p.lexer.Number
-		p.lexer.Expect(js_lexer.TNumericLiteral)
+		value := p.parseUnsignedNumber()
 		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: -value}}
 
+	case js_lexer.TPlus:
+		if !p.options.AllowJSON5 {
+			p.lexer.Unexpected()
+		}
+		p.lexer.Next()
+		value := p.parseUnsignedNumber()
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: value}}
+
+	case js_lexer.TIdentifier:
+		if p.options.AllowJSON5 {
+			if value, ok := json5NumberIdentifiers[p.lexer.Identifier]; ok {
+				p.lexer.Next()
+				return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: value}}
+			}
+		}
+		p.lexer.Unexpected()
+		return js_ast.Expr{}
+
 	case js_lexer.TOpenBracket:
 		p.lexer.Next()
 		isSingleLine := !p.lexer.HasNewlineBefore
@@ -114,10 +132,17 @@ func (p *jsonParser) parseExpr() js_ast.Expr {
 				}
 			}
 
-			keyString := p.lexer.StringLiteral()
+			var keyString []uint16
 			keyRange := p.lexer.Range()
+			if p.options.AllowJSON5 && p.lexer.IsIdentifierOrKeyword() {
+				// JSON5 allows keys to be unquoted identifiers, including reserved words
+				keyString = js_lexer.StringToUTF16(p.lexer.Identifier)
+				p.lexer.Next()
+			} else {
+				keyString = p.lexer.StringLiteral()
+				p.lexer.Expect(js_lexer.TStringLiteral)
+			}
 			key := js_ast.Expr{Loc: keyRange.Loc, Data: &js_ast.EString{Value: keyString}}
-			p.lexer.Expect(js_lexer.TStringLiteral)
 
 			// Warn about duplicate keys
 			if !p.suppressWarningsAboutWeirdCode {
@@ -156,9 +181,32 @@ func (p *jsonParser) parseExpr() js_ast.Expr {
 	}
 }
 
+// JSON5 allows these identifiers as numbers, optionally with a sign
+var json5NumberIdentifiers = map[string]float64{
+	"Infinity": math.Inf(1),
+	"NaN":      math.NaN(),
+}
+
+func (p *jsonParser) parseUnsignedNumber() float64 {
+	if p.options.AllowJSON5 && p.lexer.Token == js_lexer.TIdentifier {
+		if value, ok := json5NumberIdentifiers[p.lexer.Identifier]; ok {
+			p.lexer.Next()
+			return value
+		}
+	}
+	value := p.lexer.Number
+	p.lexer.Expect(js_lexer.TNumericLiteral)
+	return value
+}
+
 type JSONOptions struct {
 	AllowComments       bool
 	AllowTrailingCommas bool
+
+	// This enables all of JSON5 (https://spec.json5.org/), which includes
+	// comments and trailing commas. It also allows unquoted keys, single-quoted
+	// strings, and numbers such as "+1", "0x1F", and "Infinity".
+	AllowJSON5 bool
 }
 
 func ParseJSON(log logger.Log, source logger.Source, options JSONOptions) (result js_ast.Expr, ok bool) {
@@ -177,7 +225,7 @@ func ParseJSON(log logger.Log, source logger.Source, options JSONOptions) (resul
 		source:                         source,
 		tracker:                        logger.MakeLineColumnTracker(&source),
 		options:                        options,
-		lexer:                          js_lexer.NewLexerJSON(log, source, options.AllowComments),
+		lexer:                          js_lexer.NewLexerJSON(log, source, options.AllowComments, options.AllowJSON5),
 		suppressWarningsAboutWeirdCode: helpers.IsInsideNodeModules(source.KeyPath.Text),
 	}
 
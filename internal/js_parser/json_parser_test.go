@@ -11,11 +11,21 @@ import (
 )
 
 func expectParseErrorJSON(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectParseErrorJSONWithOptions(t, contents, expected, JSONOptions{})
+}
+
+func expectParseErrorJSON5(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectParseErrorJSONWithOptions(t, contents, expected, JSONOptions{AllowJSON5: true})
+}
+
+func expectParseErrorJSONWithOptions(t *testing.T, contents string, expected string, options JSONOptions) {
 	t.Helper()
 	t.Run(contents, func(t *testing.T) {
 		t.Helper()
 		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
-		ParseJSON(log, test.SourceForTest(contents), JSONOptions{})
+		ParseJSON(log, test.SourceForTest(contents), options)
 		msgs := log.Done()
 		text := ""
 		for _, msg := range msgs {
@@ -33,12 +43,22 @@ func expectPrintedJSON(t *testing.T, contents string, expected string) {
 	expectPrintedJSONWithWarning(t, contents, "", expected)
 }
 
+func expectPrintedJSON5(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectPrintedJSONWithOptions(t, contents, "", expected, JSONOptions{AllowJSON5: true})
+}
+
 func expectPrintedJSONWithWarning(t *testing.T, contents string, warning string, expected string) {
+	t.Helper()
+	expectPrintedJSONWithOptions(t, contents, warning, expected, JSONOptions{})
+}
+
+func expectPrintedJSONWithOptions(t *testing.T, contents string, warning string, expected string, options JSONOptions) {
 	t.Helper()
 	t.Run(contents, func(t *testing.T) {
 		t.Helper()
 		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
-		expr, ok := ParseJSON(log, test.SourceForTest(contents), JSONOptions{})
+		expr, ok := ParseJSON(log, test.SourceForTest(contents), options)
 		msgs := log.Done()
 		text := ""
 		for _, msg := range msgs {
@@ -180,3 +200,39 @@ func TestJSONComments(t *testing.T) {
 	expectParseErrorJSON(t, "{}/*comment*/", "<stdin>: ERROR: JSON does not support comments\n")
 	expectParseErrorJSON(t, "{}//comment\n", "<stdin>: ERROR: JSON does not support comments\n")
 }
+
+func TestJSON5(t *testing.T) {
+	// Comments and trailing commas
+	expectPrintedJSON5(t, "/*a*/{//b\n\"x\":[1,],}", "({x:[1]})")
+
+	// Unquoted keys
+	expectPrintedJSON5(t, "{x:0,$y:1,_z:2}", "({x:0,$y:1,_z:2})")
+	expectPrintedJSON5(t, "{if:0,null:1,true:2}", "({if:0,null:1,true:2})")
+	expectPrintedJSON5(t, "{\\u0061:0}", "({a:0})")
+	expectParseErrorJSON5(t, "{1:0}", "<stdin>: ERROR: Expected string but found \"1\"\n")
+	expectParseErrorJSON5(t, "{[\"x\"]:0}", "<stdin>: ERROR: Expected string but found \"[\"\n")
+
+	// Strings
+	expectPrintedJSON5(t, "'x\"y'", "'x\"y'")
+	expectPrintedJSON5(t, "'\\''", "\"'\"")
+	expectPrintedJSON5(t, "\"\\x41\\v\\0\\a\"", "\"A\\v\\0a\"")
+	expectPrintedJSON5(t, "\"a\\\nb\\\r\nc\"", "\"abc\"")
+	expectPrintedJSON5(t, "\"\t\"", "\"\t\"")
+	expectParseErrorJSON5(t, "\"\\01\"", "<stdin>: ERROR: Syntax error \"0\"\n")
+	expectParseErrorJSON5(t, "\"\\1\"", "<stdin>: ERROR: Syntax error \"1\"\n")
+
+	// Numbers
+	expectPrintedJSON5(t, "+1", "1")
+	expectPrintedJSON5(t, "0x1F", "31")
+	expectPrintedJSON5(t, "-0x1F", "-31")
+	expectPrintedJSON5(t, ".5", ".5")
+	expectPrintedJSON5(t, "5.", "5")
+	expectPrintedJSON5(t, "Infinity", "Infinity")
+	expectPrintedJSON5(t, "-Infinity", "-Infinity")
+	expectPrintedJSON5(t, "+Infinity", "Infinity")
+	expectPrintedJSON5(t, "NaN", "NaN")
+	expectPrintedJSON5(t, "-NaN", "NaN")
+	expectParseErrorJSON5(t, "undefined", "<stdin>: ERROR: Unexpected \"undefined\"\n")
+	expectParseErrorJSON5(t, "+-1", "<stdin>: ERROR: Expected number but found \"-\"\n")
+	expectParseErrorJSON(t, "+1", "<stdin>: ERROR: Unexpected \"+\"\n")
+}
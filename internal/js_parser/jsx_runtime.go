@@ -0,0 +1,110 @@
+package js_parser
+
+import (
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This returns a reference to a symbol that will be imported from the JSX
+// runtime. The import statements themselves are generated in "toAST" once
+// every JSX element in the file has been visited.
+func (p *parser) importFromJSXRuntime(loc logger.Loc, imports *map[string]js_ast.Ref, name string) js_ast.Expr {
+	if len(p.jsxRuntimeImports) == 0 && len(p.jsxLegacyImports) == 0 {
+		p.firstJSXElementLoc = loc
+	}
+	ref, ok := (*imports)[name]
+	if !ok {
+		ref = p.newSymbol(js_ast.SymbolOther, "_"+name)
+		p.moduleScope.Generated = append(p.moduleScope.Generated, ref)
+		if *imports == nil {
+			*imports = make(map[string]js_ast.Ref)
+		}
+		(*imports)[name] = ref
+	}
+	p.recordUsage(ref)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EImportIdentifier{Ref: ref}}
+}
+
+// This lowers a JSX element the same way TypeScript's "react-jsx" mode does:
+//
+//   <div key="k" id="x">a</div>       => _jsx("div", { id: "x", children: "a" }, "k")
+//   <div>a{b}</div>                   => _jsxs("div", { children: ["a", b] })
+//   <>a</>                            => _jsx(_Fragment, { children: "a" })
+//   <div {...props} key="k">a</div>   => _createElement("div", { ...props, key: "k" }, "a")
+//
+// The last case uses "createElement" from the import source because the
+// "key" prop may override a "key" property from the spread, which means it
+// can't be moved out of the props object without changing the semantics.
+func (p *parser) lowerJSXElementAutomatic(loc logger.Loc, e *js_ast.EJSXElement) js_ast.Expr {
+	keyIndex := -1
+	keyIsAfterSpread := false
+	hasSpread := false
+	for i, property := range e.Properties {
+		if property.Kind == js_ast.PropertySpread {
+			hasSpread = true
+		} else if str, ok := property.Key.Data.(*js_ast.EString); ok && js_lexer.UTF16EqualsString(str.Value, "key") {
+			keyIndex = i
+			keyIsAfterSpread = hasSpread
+		}
+	}
+
+	// Fall back to "createElement" if the key must stay in the props object
+	if keyIsAfterSpread {
+		args := []js_ast.Expr{e.TagOrNil, p.lowerObjectSpread(loc, &js_ast.EObject{Properties: e.Properties})}
+		args = append(args, e.Children...)
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+			Target: p.importFromJSXRuntime(loc, &p.jsxLegacyImports, "createElement"),
+			Args:   args,
+
+			// Enable tree shaking
+			CanBeUnwrappedIfUnused: !p.options.ignoreDCEAnnotations,
+		}}
+	}
+
+	// A missing tag is a fragment
+	tag := e.TagOrNil
+	if tag.Data == nil {
+		tag = p.importFromJSXRuntime(loc, &p.jsxRuntimeImports, "Fragment")
+	}
+
+	// Move the key out of the props object
+	var key js_ast.Expr
+	properties := make([]js_ast.Property, 0, len(e.Properties)+1)
+	for i, property := range e.Properties {
+		if i == keyIndex {
+			key = property.ValueOrNil
+		} else {
+			properties = append(properties, property)
+		}
+	}
+
+	// Children are passed in the "children" prop. Multiple children are passed
+	// as an array, which is signaled to the runtime by calling "jsxs" instead.
+	name := "jsx"
+	if len(e.Children) > 0 {
+		var children js_ast.Expr
+		if len(e.Children) == 1 {
+			children = e.Children[0]
+		} else {
+			children = js_ast.Expr{Loc: e.Children[0].Loc, Data: &js_ast.EArray{Items: e.Children, IsSingleLine: true}}
+			name = "jsxs"
+		}
+		properties = append(properties, js_ast.Property{
+			Key:        js_ast.Expr{Loc: children.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16("children")}},
+			ValueOrNil: children,
+		})
+	}
+
+	args := []js_ast.Expr{tag, p.lowerObjectSpread(loc, &js_ast.EObject{Properties: properties})}
+	if key.Data != nil {
+		args = append(args, key)
+	}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.ECall{
+		Target: p.importFromJSXRuntime(loc, &p.jsxRuntimeImports, name),
+		Args:   args,
+
+		// Enable tree shaking
+		CanBeUnwrappedIfUnused: !p.options.ignoreDCEAnnotations,
+	}}
+}
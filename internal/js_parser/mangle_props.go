@@ -0,0 +1,53 @@
+package js_parser
+
+import (
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/runtime"
+)
+
+// Renaming these would change the behavior of the code since they have special
+// meaning to the JavaScript runtime itself
+var neverMangledProps = map[string]bool{
+	"__proto__":   true,
+	"constructor": true,
+	"prototype":   true,
+}
+
+func (p *parser) shouldMangleProp(name string) bool {
+	if p.options.mangleProps == nil || p.source.Index == runtime.SourceIndex || neverMangledProps[name] {
+		return false
+	}
+	return p.options.mangleProps.MatchString(name) &&
+		(p.options.reserveProps == nil || !p.options.reserveProps.MatchString(name))
+}
+
+// This returns an EMangledProp for the property name if it should be mangled
+// and an EString otherwise. Unquoted property names are passed through here.
+// The counts are used by the bundler to give the most common names the
+// shortest replacements.
+func (p *parser) propertyKeyForName(loc logger.Loc, name string) js_ast.Expr {
+	if p.shouldMangleProp(name) {
+		p.mangledPropCounts[name]++
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EMangledProp{Name: name}}
+	}
+	p.recordReservedProp(name)
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(name)}}
+}
+
+// Names that aren't mangled (including quoted names, which are never mangled)
+// must not be used as the new name for a mangled property
+func (p *parser) recordReservedProp(name string) {
+	if p.reservedPropNames != nil {
+		p.reservedPropNames[name] = true
+	}
+}
+
+func (p *parser) recordReservedPropKey(key js_ast.Expr) {
+	if p.reservedPropNames != nil {
+		if str, ok := key.Data.(*js_ast.EString); ok {
+			p.reservedPropNames[js_lexer.UTF16ToString(str.Value)] = true
+		}
+	}
+}
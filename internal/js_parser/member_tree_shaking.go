@@ -0,0 +1,385 @@
+package js_parser
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Member tree shaking removes methods from top-level classes and properties
+// from top-level object literals when nothing in the bundle ever uses them.
+// The parser's job is to find the members that are candidates for removal and
+// to record the names of all properties that this file uses. The linker then
+// combines this information from all files in the bundle.
+//
+// A class or object is only a candidate if its symbol never escapes from the
+// file. That means it isn't exported and every use of it is one of these:
+//
+//   C.prop
+//   C["prop"]
+//   class D extends C {}
+//   x instanceof C
+//   new C().prop
+//   const c = new C()
+//
+// Instances must not escape either, so the variable "c" above and every use
+// of "this" inside the class body must only be used as the target of a
+// property access. Anything else (passing it to a function, reading
+// "C.prototype" or "c.constructor", etc.) might lead to reflection on the
+// value, so all of its members are kept in that case.
+//
+// Members are also never removed if any file in the bundle uses a computed
+// property access, a "for-in" loop, or a reflection API such as "Object.keys"
+// since those can reach a member without mentioning its name.
+
+// These members are called implicitly by the JavaScript run-time or by the
+// host environment, so they may be used even if their names never appear in
+// the code
+var implicitlyUsedMemberNames = map[string]bool{
+	"constructor":    true,
+	"handleEvent":    true,
+	"next":           true,
+	"return":         true,
+	"then":           true,
+	"throw":          true,
+	"toJSON":         true,
+	"toLocaleString": true,
+	"toString":       true,
+	"valueOf":        true,
+}
+
+// These properties of global objects can enumerate or look up the members of
+// an object without mentioning their names
+var reflectionPropertyNames = map[string]map[string]bool{
+	"Object": {
+		"assign":                    true,
+		"entries":                   true,
+		"getOwnPropertyDescriptor":  true,
+		"getOwnPropertyDescriptors": true,
+		"getOwnPropertyNames":       true,
+		"getPrototypeOf":            true,
+		"keys":                      true,
+		"values":                    true,
+	},
+}
+
+type memberInstance struct {
+	classRef    js_ast.Ref
+	instanceRef js_ast.Ref
+}
+
+type memberSubclass struct {
+	baseRef js_ast.Ref
+	bodyLoc logger.Loc
+}
+
+type memberThisUses struct {
+	total uint32
+	safe  uint32
+}
+
+func (p *parser) recordPropertyAccessForMemberTreeShaking(target js_ast.Expr, name string) {
+	if p.propertyNamesUsed == nil {
+		return
+	}
+	p.propertyNamesUsed[name] = true
+
+	// Check for "Object.keys", "Reflect.ownKeys", etc.
+	if id, ok := target.Data.(*js_ast.EIdentifier); ok {
+		if symbol := &p.symbols[id.Ref.InnerIndex]; symbol.Kind == js_ast.SymbolUnbound {
+			if symbol.OriginalName == "Reflect" || reflectionPropertyNames[symbol.OriginalName][name] {
+				p.usesDynamicPropertyAccess = true
+			}
+		}
+	}
+
+	// Reading "C.prototype" exposes every method on the class, and reading
+	// "c.constructor" or "c.__proto__" exposes the class or its prototype
+	if name == "prototype" || name == "constructor" || name == "__proto__" {
+		return
+	}
+
+	// "new C().prop" doesn't let the instance escape
+	if e, ok := target.Data.(*js_ast.ENew); ok {
+		p.recordSafeUseForMemberTreeShaking(e.Target)
+		return
+	}
+
+	p.recordSafeUseForMemberTreeShaking(target)
+}
+
+func (p *parser) recordIndexAccessForMemberTreeShaking(target js_ast.Expr, index js_ast.Expr) {
+	if p.propertyNamesUsed == nil {
+		return
+	}
+
+	switch i := index.Data.(type) {
+	case *js_ast.EString:
+		p.recordPropertyAccessForMemberTreeShaking(target, js_lexer.UTF16ToString(i.Value))
+		return
+
+	case *js_ast.ENumber:
+		// "a[0]" can only reach members with numeric names
+		if i.Value == math.Trunc(i.Value) && math.Abs(i.Value) < 1e15 {
+			p.recordPropertyAccessForMemberTreeShaking(target, strconv.FormatInt(int64(i.Value), 10))
+			return
+		}
+	}
+
+	// Any member could be reached using a computed property access
+	p.usesDynamicPropertyAccess = true
+}
+
+func (p *parser) markDynamicPropertyAccessForMemberTreeShaking() {
+	if p.propertyNamesUsed != nil {
+		p.usesDynamicPropertyAccess = true
+	}
+}
+
+// This is called for "const c = new C()". The use of "C" is safe as long as
+// all uses of "c" are safe, which is checked once the whole file is visited.
+func (p *parser) recordInstanceForMemberTreeShaking(instanceRef js_ast.Ref, value js_ast.Expr) {
+	if p.memberSafeUseCounts == nil || p.isControlFlowDead {
+		return
+	}
+	if e, ok := value.Data.(*js_ast.ENew); ok {
+		if id, ok := e.Target.Data.(*js_ast.EIdentifier); ok {
+			p.memberSafeUseCounts[id.Ref]++
+			p.memberInstances = append(p.memberInstances, memberInstance{classRef: id.Ref, instanceRef: instanceRef})
+		}
+	}
+}
+
+// This is called for "class D extends C". The use of "C" is safe as long as
+// "D" is also a candidate, which is checked once the whole file is visited.
+func (p *parser) recordSubclassForMemberTreeShaking(extends js_ast.Expr, bodyLoc logger.Loc) {
+	if p.memberSafeUseCounts == nil || p.isControlFlowDead {
+		return
+	}
+	if id, ok := extends.Data.(*js_ast.EIdentifier); ok {
+		p.memberSafeUseCounts[id.Ref]++
+		p.memberSubclasses = append(p.memberSubclasses, memberSubclass{baseRef: id.Ref, bodyLoc: bodyLoc})
+	}
+}
+
+func (p *parser) recordThisUseForMemberTreeShaking() {
+	if p.memberThisEscapes != nil && !p.isControlFlowDead {
+		p.memberThisUses.total++
+	}
+}
+
+// Members of the class or object literal with the body at "loc" are kept if
+// "this" was used for anything other than a property access since "before"
+func (p *parser) recordThisEscapesForMemberTreeShaking(loc logger.Loc, before memberThisUses) {
+	if p.memberThisEscapes != nil && p.memberThisUses.total-before.total != p.memberThisUses.safe-before.safe {
+		p.memberThisEscapes[loc] = true
+	}
+}
+
+func (p *parser) recordPropertyKeyUsedForMemberTreeShaking(key js_ast.Expr) {
+	if p.propertyNamesUsed == nil {
+		return
+	}
+	if name, ok := memberKeyName(key); ok {
+		p.propertyNamesUsed[name] = true
+	}
+}
+
+// Property keys are either strings or, with "--mangle-props", mangled names.
+// Mangled names are tracked using their original name.
+func memberKeyName(key js_ast.Expr) (string, bool) {
+	switch k := key.Data.(type) {
+	case *js_ast.EString:
+		return js_lexer.UTF16ToString(k.Value), true
+	case *js_ast.EMangledProp:
+		return k.Name, true
+	}
+	return "", false
+}
+
+func (p *parser) recordSafeUseForMemberTreeShaking(expr js_ast.Expr) {
+	if p.memberSafeUseCounts == nil || p.isControlFlowDead {
+		return
+	}
+	switch e := expr.Data.(type) {
+	case *js_ast.EIdentifier:
+		p.memberSafeUseCounts[e.Ref]++
+
+	case *js_ast.EThis:
+		p.memberThisUses.safe++
+	}
+}
+
+func (p *parser) followSymbolLinks(ref js_ast.Ref) js_ast.Ref {
+	for {
+		link := p.symbols[ref.InnerIndex].Link
+		if link == js_ast.InvalidRef {
+			return ref
+		}
+		ref = link
+	}
+}
+
+func (p *parser) findRemovableMembers(parts []js_ast.Part) (members []js_ast.RemovableMember) {
+	if p.propertyNamesUsed == nil {
+		return
+	}
+
+	// Symbols may have been merged together after they were used. For example,
+	// references inside a class body use a separate symbol for the class name.
+	totalUses := make(map[js_ast.Ref]uint32)
+	safeUses := make(map[js_ast.Ref]uint32)
+	for ref, count := range p.memberTotalUseCounts {
+		totalUses[p.followSymbolLinks(ref)] += count
+	}
+	for ref, count := range p.memberSafeUseCounts {
+		safeUses[p.followSymbolLinks(ref)] += count
+	}
+	isExported := make(map[js_ast.Ref]bool)
+	for _, export := range p.namedExports {
+		isExported[p.followSymbolLinks(export.Ref)] = true
+	}
+	neverEscapes := func(ref js_ast.Ref) bool {
+		ref = p.followSymbolLinks(ref)
+		return !isExported[ref] && safeUses[ref] == totalUses[ref]
+	}
+
+	// A class escapes if any of its instances do
+	instanceEscapes := make(map[js_ast.Ref]bool)
+	for _, instance := range p.memberInstances {
+		if !neverEscapes(instance.instanceRef) {
+			instanceEscapes[p.followSymbolLinks(instance.classRef)] = true
+		}
+	}
+	classNeverEscapes := func(ref js_ast.Ref, class *js_ast.Class) bool {
+		return neverEscapes(ref) && !instanceEscapes[p.followSymbolLinks(ref)] && !p.memberThisEscapes[class.BodyLoc]
+	}
+
+	// Find all top-level class and object declarations. Note that top-level
+	// "const" and "class" declarations have already been converted to "var"
+	// when bundling, so any assignment to these symbols would be an unsafe use.
+	classes := make(map[js_ast.Ref]*js_ast.Class)
+	var classRefs []js_ast.Ref
+	var objects []*js_ast.EObject
+	for _, part := range parts {
+		for _, stmt := range part.Stmts {
+			switch s := stmt.Data.(type) {
+			case *js_ast.SClass:
+				if !s.IsExport && s.Class.Name != nil && classNeverEscapes(s.Class.Name.Ref, &s.Class) {
+					ref := p.followSymbolLinks(s.Class.Name.Ref)
+					classes[ref] = &s.Class
+					classRefs = append(classRefs, ref)
+				}
+
+			case *js_ast.SLocal:
+				if s.IsExport {
+					continue
+				}
+				for _, decl := range s.Decls {
+					if id, ok := decl.Binding.Data.(*js_ast.BIdentifier); ok {
+						switch e := decl.ValueOrNil.Data.(type) {
+						case *js_ast.EClass:
+							if classNeverEscapes(id.Ref, &e.Class) {
+								ref := p.followSymbolLinks(id.Ref)
+								classes[ref] = &e.Class
+								classRefs = append(classRefs, ref)
+							}
+
+						case *js_ast.EObject:
+							if neverEscapes(id.Ref) && !p.memberThisEscapes[decl.ValueOrNil.Loc] {
+								objects = append(objects, e)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// A base class escapes if any of its subclasses do, since instances of the
+	// subclass inherit the methods of the base class
+	for {
+		isCandidateBody := make(map[logger.Loc]bool)
+		for _, class := range classes {
+			isCandidateBody[class.BodyLoc] = true
+		}
+		removed := false
+		for _, subclass := range p.memberSubclasses {
+			ref := p.followSymbolLinks(subclass.baseRef)
+			if _, ok := classes[ref]; ok && !isCandidateBody[subclass.bodyLoc] {
+				delete(classes, ref)
+				removed = true
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+
+	// Subclasses are only candidates if their base class is also a candidate.
+	// Otherwise the base class may call methods that the subclass overrides.
+	var isCandidateClass func(class *js_ast.Class, depth int) bool
+	isCandidateClass = func(class *js_ast.Class, depth int) bool {
+		if class.ExtendsOrNil.Data == nil {
+			return true
+		}
+		if id, ok := class.ExtendsOrNil.Data.(*js_ast.EIdentifier); ok && depth < len(classes) {
+			if base, ok := classes[p.followSymbolLinks(id.Ref)]; ok {
+				return isCandidateClass(base, depth+1)
+			}
+		}
+		return false
+	}
+
+	for _, ref := range classRefs {
+		if class, ok := classes[ref]; ok && isCandidateClass(class, 0) {
+			members = appendRemovableClassMembers(members, class)
+		}
+	}
+	for _, object := range objects {
+		members = p.appendRemovableObjectMembers(members, object)
+	}
+	return
+}
+
+func appendRemovableClassMembers(members []js_ast.RemovableMember, class *js_ast.Class) []js_ast.RemovableMember {
+	for i := range class.Properties {
+		property := &class.Properties[i]
+
+		// Only methods are removed since fields may have side effects
+		if !property.IsMethod || property.IsComputed || property.HasKeepComment || len(property.TSDecorators) > 0 {
+			continue
+		}
+		if name, ok := memberKeyName(property.Key); ok {
+			if !implicitlyUsedMemberNames[name] {
+				members = append(members, js_ast.RemovableMember{Property: property, Name: name})
+			}
+		}
+	}
+	return members
+}
+
+func (p *parser) appendRemovableObjectMembers(members []js_ast.RemovableMember, object *js_ast.EObject) []js_ast.RemovableMember {
+	// Spread and computed properties may overwrite other properties
+	for _, property := range object.Properties {
+		if property.Kind == js_ast.PropertySpread || property.IsComputed {
+			return members
+		}
+	}
+
+	for i := range object.Properties {
+		property := &object.Properties[i]
+		if property.HasKeepComment || (!property.IsMethod && !p.exprCanBeRemovedIfUnused(property.ValueOrNil)) {
+			continue
+		}
+		if name, ok := memberKeyName(property.Key); ok {
+			if name != "__proto__" && !implicitlyUsedMemberNames[name] {
+				members = append(members, js_ast.RemovableMember{Property: property, Name: name})
+			}
+		}
+	}
+	return members
+}
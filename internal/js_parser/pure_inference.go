@@ -0,0 +1,310 @@
+package js_parser
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Pure inference finds top-level functions that can be called without any
+// side effects and then treats all calls to them as if they had a
+// "/* @__PURE__ */" annotation. This lets unused calls to small factory
+// functions be removed even when the code doesn't have any annotations, which
+// is common in third-party code.
+//
+// This is deliberately very conservative. A function is only inferred to be
+// pure if it's declared exactly once, is never reassigned, and its body only
+// does things that can't have side effects:
+//
+//   function createPoint(x, y) {
+//     return { x, y, kind: "point" };
+//   }
+//
+//   const wrap = (value) => [value];
+//
+// Reading from a global variable is not allowed since it may throw or call a
+// getter. Property accesses are not allowed for the same reason. Calls are
+// only allowed if they are already known to be pure, which includes calls to
+// other functions inferred to be pure.
+//
+// The functions that are inferred to be pure are logged at the "debug" level
+// so that the results of the inference can be audited.
+
+func (p *parser) recordCallForPureInference(id *js_ast.EIdentifier, call *js_ast.ECall) {
+	if p.callsForPureInference != nil && !id.MustKeepDueToWithStmt {
+		p.callsForPureInference[id.Ref] = append(p.callsForPureInference[id.Ref], call)
+	}
+}
+
+func (p *parser) recordDeclarationForPureInference(ref js_ast.Ref) {
+	if p.declarationCountsForPureInference != nil {
+		p.declarationCountsForPureInference[ref]++
+	}
+}
+
+func (p *parser) recordAssignmentForPureInference(ref js_ast.Ref) {
+	if p.assignedSymbolsForPureInference != nil {
+		p.assignedSymbolsForPureInference[ref] = true
+	}
+}
+
+type pureInferenceCandidate struct {
+	ref     js_ast.Ref
+	nameLoc logger.Loc
+	args    []js_ast.Arg
+	body    js_ast.FnBody
+	isPure  bool
+}
+
+func (p *parser) inferPureFunctions(parts []js_ast.Part) {
+	if p.callsForPureInference == nil {
+		return
+	}
+
+	// Top-level symbols are only private to this file if this file is a module.
+	// Otherwise other scripts could reassign the function. Direct eval could
+	// also reassign the function.
+	if (p.options.mode != config.ModeBundle && !p.hasESModuleSyntax) || p.moduleScope.ContainsDirectEval {
+		return
+	}
+
+	// Symbols may have been merged together after they were used
+	declarationCounts := make(map[js_ast.Ref]int)
+	for ref, count := range p.declarationCountsForPureInference {
+		declarationCounts[p.followSymbolLinks(ref)] += count
+	}
+	isAssigned := make(map[js_ast.Ref]bool)
+	for ref := range p.assignedSymbolsForPureInference {
+		isAssigned[p.followSymbolLinks(ref)] = true
+	}
+
+	// Find all top-level function declarations
+	candidates := make(map[js_ast.Ref]*pureInferenceCandidate)
+	var order []*pureInferenceCandidate
+	addCandidate := func(ref js_ast.Ref, nameLoc logger.Loc, args []js_ast.Arg, body js_ast.FnBody) {
+		ref = p.followSymbolLinks(ref)
+		if declarationCounts[ref] == 1 && !isAssigned[ref] {
+			candidate := &pureInferenceCandidate{ref: ref, nameLoc: nameLoc, args: args, body: body}
+			candidates[ref] = candidate
+			order = append(order, candidate)
+		}
+	}
+	for _, part := range parts {
+		for _, stmt := range part.Stmts {
+			switch s := stmt.Data.(type) {
+			case *js_ast.SFunction:
+				if !s.Fn.IsAsync && !s.Fn.IsGenerator && s.Fn.Name != nil {
+					addCandidate(s.Fn.Name.Ref, s.Fn.Name.Loc, s.Fn.Args, s.Fn.Body)
+				}
+
+			case *js_ast.SLocal:
+				if s.Kind != js_ast.LocalConst {
+					continue
+				}
+				for _, decl := range s.Decls {
+					if id, ok := decl.Binding.Data.(*js_ast.BIdentifier); ok {
+						switch e := decl.ValueOrNil.Data.(type) {
+						case *js_ast.EArrow:
+							if !e.IsAsync {
+								addCandidate(id.Ref, decl.Binding.Loc, e.Args, e.Body)
+							}
+
+						case *js_ast.EFunction:
+							if !e.Fn.IsAsync && !e.Fn.IsGenerator {
+								addCandidate(id.Ref, decl.Binding.Loc, e.Fn.Args, e.Fn.Body)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(order) == 0 {
+		return
+	}
+
+	// Iterate until nothing changes since functions may call each other. This
+	// starts by assuming nothing is pure, so recursive functions are never pure.
+	for {
+		changed := false
+		for _, candidate := range order {
+			if !candidate.isPure && p.fnIsPureForInference(candidate.args, candidate.body, candidates) {
+				candidate.isPure = true
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	// Mark all calls to pure functions as pure
+	anyPure := false
+	for ref, calls := range p.callsForPureInference {
+		if candidate, ok := candidates[p.followSymbolLinks(ref)]; ok && candidate.isPure {
+			for _, call := range calls {
+				call.CanBeUnwrappedIfUnused = true
+			}
+			anyPure = true
+		}
+	}
+	for _, candidate := range order {
+		if candidate.isPure {
+			name := p.symbols[candidate.ref.InnerIndex].OriginalName
+			p.log.Add(logger.Debug, &p.tracker, js_lexer.RangeOfIdentifier(p.source, candidate.nameLoc),
+				fmt.Sprintf("Calls to %q will be treated as pure because it has no side effects", name))
+		}
+	}
+
+	// Parts that only contained calls to pure functions can now be removed
+	if anyPure {
+		for i := range parts {
+			part := &parts[i]
+			if !part.CanBeRemovedIfUnused && len(part.Stmts) > 0 && p.stmtsCanBeRemovedIfUnused(part.Stmts) {
+				part.CanBeRemovedIfUnused = true
+			}
+		}
+	}
+}
+
+func (p *parser) fnIsPureForInference(args []js_ast.Arg, body js_ast.FnBody, candidates map[js_ast.Ref]*pureInferenceCandidate) bool {
+	for _, arg := range args {
+		// Destructuring can throw or call getters
+		if _, ok := arg.Binding.Data.(*js_ast.BIdentifier); !ok || len(arg.TSDecorators) > 0 {
+			return false
+		}
+		if arg.DefaultOrNil.Data != nil && !p.exprIsPureForInference(arg.DefaultOrNil, candidates) {
+			return false
+		}
+	}
+	return p.stmtsArePureForInference(body.Stmts, candidates)
+}
+
+func (p *parser) stmtsArePureForInference(stmts []js_ast.Stmt, candidates map[js_ast.Ref]*pureInferenceCandidate) bool {
+	for _, stmt := range stmts {
+		switch s := stmt.Data.(type) {
+		case *js_ast.SEmpty, *js_ast.SDirective, *js_ast.SFunction:
+			// Declaring a nested function doesn't run any code
+
+		case *js_ast.SReturn:
+			if s.ValueOrNil.Data != nil && !p.exprIsPureForInference(s.ValueOrNil, candidates) {
+				return false
+			}
+
+		case *js_ast.SExpr:
+			if !p.exprIsPureForInference(s.Value, candidates) {
+				return false
+			}
+
+		case *js_ast.SLocal:
+			for _, decl := range s.Decls {
+				if _, ok := decl.Binding.Data.(*js_ast.BIdentifier); !ok {
+					return false
+				}
+				if decl.ValueOrNil.Data != nil && !p.exprIsPureForInference(decl.ValueOrNil, candidates) {
+					return false
+				}
+			}
+
+		case *js_ast.SBlock:
+			if !p.stmtsArePureForInference(s.Stmts, candidates) {
+				return false
+			}
+
+		case *js_ast.SIf:
+			if !p.exprIsPureForInference(s.Test, candidates) ||
+				!p.stmtsArePureForInference([]js_ast.Stmt{s.Yes}, candidates) ||
+				(s.NoOrNil.Data != nil && !p.stmtsArePureForInference([]js_ast.Stmt{s.NoOrNil}, candidates)) {
+				return false
+			}
+
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (p *parser) exprIsPureForInference(expr js_ast.Expr, candidates map[js_ast.Ref]*pureInferenceCandidate) bool {
+	switch e := expr.Data.(type) {
+	case *js_ast.ENull, *js_ast.EUndefined, *js_ast.EBoolean, *js_ast.ENumber, *js_ast.EBigInt,
+		*js_ast.EString, *js_ast.EThis, *js_ast.ERegExp, *js_ast.EFunction, *js_ast.EArrow:
+		return true
+
+	case *js_ast.EInlinedEnum:
+		return p.exprIsPureForInference(e.Value, candidates)
+
+	case *js_ast.EIdentifier:
+		// Reading a global variable may throw or call a getter
+		return !e.MustKeepDueToWithStmt && p.symbols[e.Ref.InnerIndex].Kind != js_ast.SymbolUnbound
+
+	case *js_ast.EImportIdentifier:
+		return true
+
+	case *js_ast.EArray:
+		for _, item := range e.Items {
+			if _, ok := item.Data.(*js_ast.EMissing); !ok && !p.exprIsPureForInference(item, candidates) {
+				return false
+			}
+		}
+		return true
+
+	case *js_ast.EObject:
+		for _, property := range e.Properties {
+			if property.Kind == js_ast.PropertySpread || property.IsComputed {
+				return false
+			}
+			if property.ValueOrNil.Data != nil && !p.exprIsPureForInference(property.ValueOrNil, candidates) {
+				return false
+			}
+		}
+		return true
+
+	case *js_ast.EIf:
+		return p.exprIsPureForInference(e.Test, candidates) &&
+			p.exprIsPureForInference(e.Yes, candidates) &&
+			p.exprIsPureForInference(e.No, candidates)
+
+	case *js_ast.EUnary:
+		switch e.Op {
+		case js_ast.UnOpVoid, js_ast.UnOpNot:
+			return p.exprIsPureForInference(e.Value, candidates)
+
+		case js_ast.UnOpTypeof:
+			if _, ok := e.Value.Data.(*js_ast.EIdentifier); ok {
+				return true
+			}
+			return p.exprIsPureForInference(e.Value, candidates)
+		}
+
+	case *js_ast.EBinary:
+		switch e.Op {
+		// These operators never convert their operands, so they can't call
+		// "valueOf" or "toString" methods
+		case js_ast.BinOpComma, js_ast.BinOpLogicalAnd, js_ast.BinOpLogicalOr, js_ast.BinOpNullishCoalescing,
+			js_ast.BinOpStrictEq, js_ast.BinOpStrictNe:
+			return p.exprIsPureForInference(e.Left, candidates) && p.exprIsPureForInference(e.Right, candidates)
+		}
+
+	case *js_ast.ECall:
+		isPure := e.CanBeUnwrappedIfUnused
+		if id, ok := e.Target.Data.(*js_ast.EIdentifier); ok && !isPure {
+			if candidate, ok := candidates[p.followSymbolLinks(id.Ref)]; ok && candidate.isPure {
+				isPure = true
+			}
+		}
+		if isPure {
+			for _, arg := range e.Args {
+				if !p.exprIsPureForInference(arg, candidates) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package js_parser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This is used to remove blocks such as "if (import.meta.vitest) { ... }",
+// which contain code that's only run by a test runner or in development
+func (p *parser) isStripIfCondition(test js_ast.Expr) bool {
+	for _, parts := range p.options.stripIf {
+		if p.isDotDefineMatch(test, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// Calls such as "console.log(x)" and "console['warn'](x)" are removed with
+// "--drop:console", but only if "console" is the global object
+func (p *parser) isDroppedConsoleCall(expr js_ast.Expr) bool {
+	if !p.options.dropConsole {
+		return false
+	}
+	if call, ok := expr.Data.(*js_ast.ECall); ok {
+		switch target := call.Target.Data.(type) {
+		case *js_ast.EDot:
+			return p.isDotDefineMatch(target.Target, []string{"console"})
+		case *js_ast.EIndex:
+			return p.isDotDefineMatch(target.Target, []string{"console"})
+		}
+	}
+	return false
+}
+
+// This replaces the code from each start marker comment to the next end marker
+// comment with spaces. Newlines are kept and the length of the file doesn't
+// change, so the locations of the code that remains stay the same.
+//
+// Comments are found by looking at the text of the file without tokenizing
+// it. The markers must be the only thing in their comments, so it's unlikely
+// that something inside of a string or a regular expression will match.
+func stripBetweenMarkers(log logger.Log, source logger.Source, markers []config.StripMarkers) string {
+	contents := source.Contents
+	var stripped []byte
+	i := 0
+
+	for {
+		startBegin, startEnd, text, ok := nextCommentForStrip(contents, i)
+		if !ok {
+			break
+		}
+		i = startEnd
+
+		for _, marker := range markers {
+			if text != marker.Start {
+				continue
+			}
+
+			// Look for the end marker
+			isMissingEnd := true
+			for {
+				_, endEnd, text, ok := nextCommentForStrip(contents, i)
+				if !ok {
+					break
+				}
+				i = endEnd
+				if text == marker.End {
+					if stripped == nil {
+						stripped = []byte(contents)
+					}
+					for j := startBegin; j < endEnd; j++ {
+						if c := stripped[j]; c != '\n' && c != '\r' {
+							stripped[j] = ' '
+						}
+					}
+					isMissingEnd = false
+					break
+				}
+			}
+
+			// Don't remove anything if the block is never ended
+			if isMissingEnd {
+				tracker := logger.MakeLineColumnTracker(&source)
+				r := logger.Range{Loc: logger.Loc{Start: int32(startBegin)}, Len: int32(startEnd - startBegin)}
+				log.Add(logger.Warning, &tracker, r,
+					fmt.Sprintf("The code after %q was not removed because there is no %q comment after it", marker.Start, marker.End))
+				i = startEnd
+			}
+			break
+		}
+	}
+
+	if stripped == nil {
+		return contents
+	}
+	return string(stripped)
+}
+
+// This returns the range of the next "//" or "/* */" comment starting at or
+// after "i" and the text inside it without any surrounding whitespace
+func nextCommentForStrip(contents string, i int) (int, int, string, bool) {
+	for {
+		slash := strings.IndexByte(contents[i:], '/')
+		if slash == -1 || i+slash+1 >= len(contents) {
+			return 0, 0, "", false
+		}
+		begin := i + slash
+
+		switch contents[begin+1] {
+		case '/':
+			end := begin + 2
+			for end < len(contents) && contents[end] != '\n' && contents[end] != '\r' {
+				end++
+			}
+			return begin, end, strings.TrimSpace(contents[begin+2 : end]), true
+
+		case '*':
+			commentEnd := strings.Index(contents[begin+2:], "*/")
+			if commentEnd == -1 {
+				return 0, 0, "", false
+			}
+			end := begin + 2 + commentEnd + 2
+			return begin, end, strings.TrimSpace(contents[begin+2 : end-2]), true
+		}
+
+		i = begin + 1
+	}
+}
@@ -0,0 +1,873 @@
+package js_parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This parses TOML 1.0. The document is built up as a tree of tables first
+// since later table headers can add keys to tables that were created earlier.
+// Dates and times don't have a JSON equivalent, so they are strings with the
+// same text as the original value.
+type tomlParser struct {
+	log     logger.Log
+	source  logger.Source
+	tracker logger.LineColumnTracker
+	text    string
+	pos     int
+}
+
+type tomlPanic struct{}
+
+type tomlTableKind uint8
+
+const (
+	// Created as the parent of a table in a table header (e.g. "a" in "[a.b]")
+	tomlTableImplicit tomlTableKind = iota
+
+	// Created by a table header or as an entry in an array of tables
+	tomlTableHeader
+
+	// Created by a dotted key (e.g. "a" in "a.b = 1")
+	tomlTableDotted
+
+	// Inline tables are complete and can't be extended
+	tomlTableInline
+)
+
+type tomlTable struct {
+	loc    logger.Loc
+	kind   tomlTableKind
+	keys   []string
+	values map[string]*tomlValue
+}
+
+type tomlValue struct {
+	keyRange logger.Range
+
+	// Exactly one of these is set
+	expr   js_ast.Expr
+	table  *tomlTable
+	tables []*tomlTable // This is an array of tables
+}
+
+func newTOMLTable(loc logger.Loc, kind tomlTableKind) *tomlTable {
+	return &tomlTable{loc: loc, kind: kind, values: make(map[string]*tomlValue)}
+}
+
+func ParseTOML(log logger.Log, source logger.Source) (result js_ast.Expr, ok bool) {
+	ok = true
+	defer func() {
+		r := recover()
+		if _, isTOMLPanic := r.(tomlPanic); isTOMLPanic {
+			ok = false
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	p := &tomlParser{
+		log:     log,
+		source:  source,
+		tracker: logger.MakeLineColumnTracker(&source),
+		text:    source.Contents,
+	}
+
+	root := newTOMLTable(logger.Loc{}, tomlTableHeader)
+	p.parseDocument(root)
+	result = tomlTableToExpr(root)
+	return
+}
+
+func (p *tomlParser) fail(start int, end int, text string) {
+	p.log.Add(logger.Error, &p.tracker, logger.Range{Loc: logger.Loc{Start: int32(start)}, Len: int32(end - start)}, text)
+	panic(tomlPanic{})
+}
+
+func (p *tomlParser) failWithNote(r logger.Range, text string, noteRange logger.Range, note string) {
+	p.log.AddWithNotes(logger.Error, &p.tracker, r, text, []logger.MsgData{p.tracker.MsgData(noteRange, note)})
+	panic(tomlPanic{})
+}
+
+func (p *tomlParser) expected(what string) {
+	if p.pos >= len(p.text) {
+		p.fail(p.pos, p.pos, fmt.Sprintf("Expected %s but found end of file", what))
+	}
+	if c := p.text[p.pos]; c == '\n' || c == '\r' {
+		p.fail(p.pos, p.pos, fmt.Sprintf("Expected %s but found end of line", what))
+	}
+	end := p.pos + 1
+	for end < len(p.text) && isTOMLBareKeyChar(p.text[end]) && isTOMLBareKeyChar(p.text[p.pos]) {
+		end++
+	}
+	if end == p.pos+1 {
+		_, width := utf8.DecodeRuneInString(p.text[p.pos:])
+		end = p.pos + width
+	}
+	p.fail(p.pos, end, fmt.Sprintf("Expected %s but found %q", what, p.text[p.pos:end]))
+}
+
+func (p *tomlParser) peek(offset int) byte {
+	if i := p.pos + offset; i < len(p.text) {
+		return p.text[i]
+	}
+	return 0
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+func (p *tomlParser) skipWhitespace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if p.peek(0) == '#' {
+		for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+			if c := p.text[p.pos]; c == '\r' && p.peek(1) == '\n' {
+				break
+			} else if c < 0x20 && c != '\t' || c == 0x7F {
+				p.fail(p.pos, p.pos+1, "Control characters are not allowed in comments")
+			}
+			p.pos++
+		}
+	}
+}
+
+// This skips whitespace, comments, and line breaks
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipWhitespace()
+		p.skipComment()
+		if p.peek(0) == '\n' {
+			p.pos++
+		} else if p.peek(0) == '\r' && p.peek(1) == '\n' {
+			p.pos += 2
+		} else {
+			return
+		}
+	}
+}
+
+// Each key-value pair and table header must be followed by the end of the line
+func (p *tomlParser) expectEndOfLine() {
+	p.skipWhitespace()
+	p.skipComment()
+	if p.pos < len(p.text) {
+		if p.peek(0) == '\n' {
+			p.pos++
+		} else if p.peek(0) == '\r' && p.peek(1) == '\n' {
+			p.pos += 2
+		} else {
+			p.expected("end of line")
+		}
+	}
+}
+
+func (p *tomlParser) parseDocument(root *tomlTable) {
+	current := root
+
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.text) {
+			return
+		}
+
+		if p.peek(0) == '[' {
+			current = p.parseTableHeader(root)
+		} else {
+			p.parseKeyValue(current)
+		}
+		p.expectEndOfLine()
+	}
+}
+
+type tomlKey struct {
+	text string
+	r    logger.Range
+}
+
+func (p *tomlParser) parseKey() []tomlKey {
+	var keys []tomlKey
+	for {
+		p.skipWhitespace()
+		start := p.pos
+		var text string
+		if strings.HasPrefix(p.text[p.pos:], "\"\"\"") || strings.HasPrefix(p.text[p.pos:], "'''") {
+			p.fail(p.pos, p.pos+3, "Multi-line strings cannot be used as keys")
+		}
+		switch c := p.peek(0); {
+		case c == '"':
+			text = p.parseBasicString()
+		case c == '\'':
+			text = p.parseLiteralString()
+		case isTOMLBareKeyChar(c):
+			for p.pos < len(p.text) && isTOMLBareKeyChar(p.text[p.pos]) {
+				p.pos++
+			}
+			text = p.text[start:p.pos]
+		default:
+			p.expected("key")
+		}
+		keys = append(keys, tomlKey{text: text, r: logger.Range{Loc: logger.Loc{Start: int32(start)}, Len: int32(p.pos - start)}})
+
+		p.skipWhitespace()
+		if p.peek(0) != '.' {
+			return keys
+		}
+		p.pos++
+	}
+}
+
+func joinTOMLKeys(keys []tomlKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.text
+	}
+	return strings.Join(parts, ".")
+}
+
+// This handles "[a.b]" and "[[a.b]]" and returns the table that the
+// following key-value pairs are added to
+func (p *tomlParser) parseTableHeader(root *tomlTable) *tomlTable {
+	start := p.pos
+	isArray := p.peek(1) == '['
+	if isArray {
+		p.pos += 2
+	} else {
+		p.pos++
+	}
+	keys := p.parseKey()
+	if isArray {
+		if p.peek(0) != ']' || p.peek(1) != ']' {
+			p.expected("\"]]\"")
+		}
+		p.pos += 2
+	} else {
+		if p.peek(0) != ']' {
+			p.expected("\"]\"")
+		}
+		p.pos++
+	}
+	loc := logger.Loc{Start: int32(start)}
+
+	// Find the parent table, creating any missing tables along the way
+	table := root
+	for i, key := range keys[:len(keys)-1] {
+		value, ok := table.values[key.text]
+		if !ok {
+			child := newTOMLTable(loc, tomlTableImplicit)
+			table.set(key, &tomlValue{keyRange: key.r, table: child})
+			table = child
+			continue
+		}
+		table = p.tableForHeader(keys[:i+1], key, value)
+	}
+
+	last := keys[len(keys)-1]
+	value, ok := table.values[last.text]
+
+	if isArray {
+		child := newTOMLTable(loc, tomlTableHeader)
+		if !ok {
+			table.set(last, &tomlValue{keyRange: last.r, tables: []*tomlTable{child}})
+		} else if value.tables != nil {
+			value.tables = append(value.tables, child)
+		} else {
+			p.failWithNote(last.r, fmt.Sprintf("Cannot use %q as an array of tables because it has already been defined", joinTOMLKeys(keys)),
+				value.keyRange, fmt.Sprintf("The key %q was originally defined here:", joinTOMLKeys(keys)))
+		}
+		return child
+	}
+
+	if !ok {
+		child := newTOMLTable(loc, tomlTableHeader)
+		table.set(last, &tomlValue{keyRange: last.r, table: child})
+		return child
+	}
+
+	// Tables that were only created implicitly can still be defined once
+	if value.table != nil && value.table.kind == tomlTableImplicit {
+		value.table.kind = tomlTableHeader
+		value.table.loc = loc
+		return value.table
+	}
+	p.failWithNote(last.r, fmt.Sprintf("Cannot define the table %q more than once", joinTOMLKeys(keys)),
+		value.keyRange, fmt.Sprintf("The key %q was originally defined here:", joinTOMLKeys(keys)))
+	return nil
+}
+
+// Table headers can add tables to any table except inline ones. Adding to an
+// array of tables adds to the last table in the array.
+func (p *tomlParser) tableForHeader(path []tomlKey, key tomlKey, value *tomlValue) *tomlTable {
+	if value.tables != nil {
+		return value.tables[len(value.tables)-1]
+	}
+	if value.table != nil && value.table.kind != tomlTableInline {
+		return value.table
+	}
+	p.failWithNote(key.r, fmt.Sprintf("Cannot add to %q because it is not a table", joinTOMLKeys(path)),
+		value.keyRange, fmt.Sprintf("The key %q was originally defined here:", joinTOMLKeys(path)))
+	return nil
+}
+
+func (p *tomlParser) parseKeyValue(table *tomlTable) {
+	keys := p.parseKey()
+	if p.peek(0) != '=' {
+		p.expected("\"=\"")
+	}
+	p.pos++
+	p.skipWhitespace()
+	value := p.parseValue()
+	p.setDottedKey(table, keys, value)
+}
+
+// Dotted keys create tables, but they can only add to tables that were also
+// created by dotted keys in the same table
+func (p *tomlParser) setDottedKey(table *tomlTable, keys []tomlKey, value *tomlValue) {
+	for i, key := range keys[:len(keys)-1] {
+		existing, ok := table.values[key.text]
+		if !ok {
+			child := newTOMLTable(key.r.Loc, tomlTableDotted)
+			table.set(key, &tomlValue{keyRange: key.r, table: child})
+			table = child
+			continue
+		}
+		if existing.table == nil || existing.table.kind != tomlTableDotted {
+			path := joinTOMLKeys(keys[:i+1])
+			p.failWithNote(key.r, fmt.Sprintf("Cannot add to %q because it has already been defined", path),
+				existing.keyRange, fmt.Sprintf("The key %q was originally defined here:", path))
+		}
+		table = existing.table
+	}
+
+	last := keys[len(keys)-1]
+	if existing, ok := table.values[last.text]; ok {
+		p.failWithNote(last.r, fmt.Sprintf("Duplicate key %q", joinTOMLKeys(keys)),
+			existing.keyRange, fmt.Sprintf("The original key %q is here:", joinTOMLKeys(keys)))
+	}
+	value.keyRange = last.r
+	table.set(last, value)
+}
+
+func (t *tomlTable) set(key tomlKey, value *tomlValue) {
+	t.keys = append(t.keys, key.text)
+	t.values[key.text] = value
+}
+
+func (p *tomlParser) parseValue() *tomlValue {
+	start := p.pos
+	loc := logger.Loc{Start: int32(start)}
+
+	switch p.peek(0) {
+	case '"':
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(p.parseBasicString())}}}
+
+	case '\'':
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(p.parseLiteralString())}}}
+
+	case '[':
+		return &tomlValue{expr: p.parseArray()}
+
+	case '{':
+		return &tomlValue{table: p.parseInlineTable()}
+	}
+
+	// Everything else is a bare token
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		if !isTOMLBareKeyChar(c) && c != '+' && c != '.' && c != ':' {
+			break
+		}
+		p.pos++
+	}
+
+	// Dates can be separated from times with a space
+	if p.pos-start == 10 && p.peek(0) == ' ' && p.peek(1) >= '0' && p.peek(1) <= '9' && p.peek(3) == ':' && isTOMLDate(p.text[start:p.pos]) {
+		p.pos++
+		for p.pos < len(p.text) {
+			c := p.text[p.pos]
+			if !isTOMLBareKeyChar(c) && c != '+' && c != '.' && c != ':' {
+				break
+			}
+			p.pos++
+		}
+	}
+
+	text := p.text[start:p.pos]
+	if text == "" {
+		p.expected("value")
+	}
+	switch text {
+	case "true":
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: true}}}
+	case "false":
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: false}}}
+	case "inf", "+inf":
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.Inf(1)}}}
+	case "-inf":
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.Inf(-1)}}}
+	case "nan", "+nan", "-nan":
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.NaN()}}}
+	}
+	if isTOMLDateTime(text) {
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(text)}}}
+	}
+	if value, ok := parseTOMLNumber(text); ok {
+		return &tomlValue{expr: js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: value}}}
+	}
+	p.fail(start, p.pos, fmt.Sprintf("Invalid value %q", text))
+	return nil
+}
+
+func (p *tomlParser) parseArray() js_ast.Expr {
+	start := p.pos
+	items := []js_ast.Expr{}
+	p.pos++
+
+	for {
+		p.skipBlank()
+		if p.peek(0) == ']' {
+			break
+		}
+		item := p.parseValue()
+		if item.table != nil {
+			items = append(items, tomlTableToExpr(item.table))
+		} else {
+			items = append(items, item.expr)
+		}
+		p.skipBlank()
+		if p.peek(0) != ',' {
+			if p.peek(0) != ']' {
+				p.expected("\",\" or \"]\"")
+			}
+			break
+		}
+		p.pos++
+	}
+
+	p.pos++
+	return js_ast.Expr{Loc: logger.Loc{Start: int32(start)}, Data: &js_ast.EArray{
+		Items:        items,
+		IsSingleLine: strings.IndexByte(p.text[start:p.pos], '\n') == -1,
+	}}
+}
+
+// Inline tables must be on a single line and can't have trailing commas
+func (p *tomlParser) parseInlineTable() *tomlTable {
+	table := newTOMLTable(logger.Loc{Start: int32(p.pos)}, tomlTableInline)
+	p.pos++
+	p.skipWhitespace()
+
+	if p.peek(0) != '}' {
+		for {
+			keys := p.parseKey()
+			if p.peek(0) != '=' {
+				p.expected("\"=\"")
+			}
+			p.pos++
+			p.skipWhitespace()
+			value := p.parseValue()
+			p.setDottedKey(table, keys, value)
+			p.skipWhitespace()
+			if p.peek(0) != ',' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek(0) != '}' {
+			p.expected("\",\" or \"}\"")
+		}
+	}
+
+	p.pos++
+
+	// Tables created by dotted keys in an inline table can't be extended either
+	var freeze func(*tomlTable)
+	freeze = func(t *tomlTable) {
+		t.kind = tomlTableInline
+		for _, value := range t.values {
+			if value.table != nil {
+				freeze(value.table)
+			}
+		}
+	}
+	freeze(table)
+	return table
+}
+
+func tomlTableToExpr(table *tomlTable) js_ast.Expr {
+	properties := make([]js_ast.Property, 0, len(table.keys))
+	for _, key := range table.keys {
+		value := table.values[key]
+		var expr js_ast.Expr
+		switch {
+		case value.table != nil:
+			expr = tomlTableToExpr(value.table)
+		case value.tables != nil:
+			items := make([]js_ast.Expr, len(value.tables))
+			for i, t := range value.tables {
+				items[i] = tomlTableToExpr(t)
+			}
+			expr = js_ast.Expr{Loc: value.keyRange.Loc, Data: &js_ast.EArray{Items: items}}
+		default:
+			expr = value.expr
+		}
+		properties = append(properties, js_ast.Property{
+			Kind:       js_ast.PropertyNormal,
+			Key:        js_ast.Expr{Loc: value.keyRange.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(key)}},
+			ValueOrNil: expr,
+		})
+	}
+	return js_ast.Expr{Loc: table.loc, Data: &js_ast.EObject{Properties: properties}}
+}
+
+func isTOMLDate(text string) bool {
+	_, err := time.Parse("2006-01-02", text)
+	return err == nil
+}
+
+func isTOMLTime(text string) bool {
+	if len(text) < 8 {
+		return false
+	}
+	if _, err := time.Parse("15:04:05", text[:8]); err != nil {
+		return false
+	}
+	if rest := text[8:]; rest != "" {
+		if rest[0] != '.' || len(rest) == 1 {
+			return false
+		}
+		for _, c := range rest[1:] {
+			if c < '0' || c > '9' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// This matches offset date-times, local date-times, local dates, and local
+// times. The separator between the date and the time can be "T" or a space.
+func isTOMLDateTime(text string) bool {
+	if len(text) < 10 || !isTOMLDate(text[:10]) {
+		return isTOMLTime(text)
+	}
+	if len(text) == 10 {
+		return true
+	}
+	if c := text[10]; c != 'T' && c != 't' && c != ' ' {
+		return false
+	}
+	rest := text[11:]
+
+	// Strip the time zone offset
+	if n := len(rest); n > 0 && (rest[n-1] == 'Z' || rest[n-1] == 'z') {
+		rest = rest[:n-1]
+	} else if n > 6 && (rest[n-6] == '+' || rest[n-6] == '-') {
+		offset := rest[n-5:]
+		if _, err := time.Parse("15:04", offset); err != nil {
+			return false
+		}
+		rest = rest[:n-6]
+	}
+	return isTOMLTime(rest)
+}
+
+func parseTOMLNumber(text string) (float64, bool) {
+	// Underscores must be between two digits
+	checkUnderscores := func(digits string, isDigit func(byte) bool) (string, bool) {
+		if digits == "" {
+			return "", false
+		}
+		sb := strings.Builder{}
+		for i := 0; i < len(digits); i++ {
+			c := digits[i]
+			if c == '_' {
+				if i == 0 || i+1 == len(digits) || !isDigit(digits[i-1]) || !isDigit(digits[i+1]) {
+					return "", false
+				}
+				continue
+			}
+			if !isDigit(c) {
+				return "", false
+			}
+			sb.WriteByte(c)
+		}
+		return sb.String(), true
+	}
+	isDecimal := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	// Prefixed integers can't have a sign
+	if len(text) > 2 && text[0] == '0' {
+		var base int
+		var isDigit func(byte) bool
+		switch text[1] {
+		case 'x':
+			base = 16
+			isDigit = func(c byte) bool { return isDecimal(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') }
+		case 'o':
+			base = 8
+			isDigit = func(c byte) bool { return c >= '0' && c <= '7' }
+		case 'b':
+			base = 2
+			isDigit = func(c byte) bool { return c == '0' || c == '1' }
+		}
+		if base != 0 {
+			digits, ok := checkUnderscores(text[2:], isDigit)
+			if !ok {
+				return 0, false
+			}
+			value, err := strconv.ParseUint(digits, base, 64)
+			return float64(value), err == nil
+		}
+	}
+
+	// Split into "[+-]int[.frac][e[+-]exp]"
+	rest := text
+	sign := ""
+	if rest != "" && (rest[0] == '+' || rest[0] == '-') {
+		sign = rest[:1]
+		rest = rest[1:]
+	}
+	intPart := rest
+	fracPart := ""
+	expPart := ""
+	hasFrac := false
+	hasExp := false
+	if i := strings.IndexAny(intPart, "eE"); i != -1 {
+		expPart = intPart[i+1:]
+		intPart = intPart[:i]
+		hasExp = true
+	}
+	if i := strings.IndexByte(intPart, '.'); i != -1 {
+		fracPart = intPart[i+1:]
+		intPart = intPart[:i]
+		hasFrac = true
+	}
+
+	intDigits, ok := checkUnderscores(intPart, isDecimal)
+	if !ok || (len(intDigits) > 1 && intDigits[0] == '0') {
+		return 0, false
+	}
+	normalized := sign + intDigits
+	if hasFrac {
+		fracDigits, ok := checkUnderscores(fracPart, isDecimal)
+		if !ok {
+			return 0, false
+		}
+		normalized += "." + fracDigits
+	}
+	if hasExp {
+		expSign := ""
+		if expPart != "" && (expPart[0] == '+' || expPart[0] == '-') {
+			expSign = expPart[:1]
+			expPart = expPart[1:]
+		}
+		expDigits, ok := checkUnderscores(expPart, isDecimal)
+		if !ok {
+			return 0, false
+		}
+		normalized += "e" + expSign + expDigits
+	}
+
+	value, err := strconv.ParseFloat(normalized, 64)
+	return value, err == nil
+}
+
+func (p *tomlParser) checkStringChar(c byte, allowNewline bool) {
+	if (c < 0x20 && c != '\t' && !(allowNewline && (c == '\n' || c == '\r'))) || c == 0x7F {
+		p.fail(p.pos, p.pos+1, fmt.Sprintf("Syntax error \"\\x%02X\"", c))
+	}
+	if c == '\r' && p.peek(1) != '\n' {
+		p.fail(p.pos, p.pos+1, "Syntax error \"\\x0D\"")
+	}
+}
+
+// This handles both single-line and multi-line basic strings
+func (p *tomlParser) parseBasicString() string {
+	start := p.pos
+	isMultiLine := strings.HasPrefix(p.text[p.pos:], "\"\"\"")
+	sb := strings.Builder{}
+
+	if isMultiLine {
+		p.pos += 3
+		// A line break right after the opening delimiter is trimmed
+		if p.peek(0) == '\n' {
+			p.pos++
+		} else if p.peek(0) == '\r' && p.peek(1) == '\n' {
+			p.pos += 2
+		}
+	} else {
+		p.pos++
+	}
+
+	for {
+		if p.pos >= len(p.text) {
+			p.fail(start, start+1, "Unterminated string literal")
+		}
+		c := p.text[p.pos]
+
+		switch {
+		case c == '"':
+			if !isMultiLine {
+				p.pos++
+				return sb.String()
+			}
+
+			// Up to two quotes are allowed right before the closing delimiter
+			n := 0
+			for p.pos+n < len(p.text) && p.text[p.pos+n] == '"' {
+				n++
+			}
+			if n >= 3 {
+				if n > 5 {
+					p.fail(p.pos+5, p.pos+n, "Unexpected \"\\\"\"")
+				}
+				sb.WriteString(strings.Repeat("\"", n-3))
+				p.pos += n
+				return sb.String()
+			}
+			sb.WriteString(strings.Repeat("\"", n))
+			p.pos += n
+
+		case c == '\\':
+			p.parseTOMLEscape(&sb, isMultiLine)
+
+		case (c == '\n' || c == '\r') && !isMultiLine:
+			p.fail(start, start+1, "Unterminated string literal")
+
+		default:
+			p.checkStringChar(c, isMultiLine)
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseTOMLEscape(sb *strings.Builder, isMultiLine bool) {
+	start := p.pos
+	p.pos++
+	if p.pos >= len(p.text) {
+		p.fail(start, start+1, "Unterminated string literal")
+	}
+	c := p.text[p.pos]
+	p.pos++
+
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+
+	case 'u', 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
+		}
+		if p.pos+digits > len(p.text) {
+			p.fail(start, len(p.text), "Invalid escape sequence")
+		}
+		value, err := strconv.ParseUint(p.text[p.pos:p.pos+digits], 16, 32)
+		if err != nil || value > utf8.MaxRune || (value >= 0xD800 && value <= 0xDFFF) {
+			p.fail(start, p.pos+digits, "Invalid escape sequence")
+		}
+		sb.WriteRune(rune(value))
+		p.pos += digits
+
+	default:
+		// In multi-line strings, a backslash at the end of a line removes the
+		// line break and all whitespace up to the next non-whitespace character
+		if isMultiLine && (c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+			i := p.pos - 1
+			for i < len(p.text) && (p.text[i] == ' ' || p.text[i] == '\t') {
+				i++
+			}
+			if i < len(p.text) && (p.text[i] == '\n' || p.text[i] == '\r') {
+				for i < len(p.text) && (p.text[i] == ' ' || p.text[i] == '\t' || p.text[i] == '\n' || p.text[i] == '\r') {
+					i++
+				}
+				p.pos = i
+				return
+			}
+		}
+		p.fail(start, p.pos, "Invalid escape sequence")
+	}
+}
+
+// This handles both single-line and multi-line literal strings, which don't
+// have escape sequences
+func (p *tomlParser) parseLiteralString() string {
+	start := p.pos
+	isMultiLine := strings.HasPrefix(p.text[p.pos:], "'''")
+	sb := strings.Builder{}
+
+	if isMultiLine {
+		p.pos += 3
+		if p.peek(0) == '\n' {
+			p.pos++
+		} else if p.peek(0) == '\r' && p.peek(1) == '\n' {
+			p.pos += 2
+		}
+	} else {
+		p.pos++
+	}
+
+	for {
+		if p.pos >= len(p.text) {
+			p.fail(start, start+1, "Unterminated string literal")
+		}
+		c := p.text[p.pos]
+
+		switch {
+		case c == '\'':
+			if !isMultiLine {
+				p.pos++
+				return sb.String()
+			}
+			n := 0
+			for p.pos+n < len(p.text) && p.text[p.pos+n] == '\'' {
+				n++
+			}
+			if n >= 3 {
+				if n > 5 {
+					p.fail(p.pos+5, p.pos+n, "Unexpected \"'\"")
+				}
+				sb.WriteString(strings.Repeat("'", n-3))
+				p.pos += n
+				return sb.String()
+			}
+			sb.WriteString(strings.Repeat("'", n))
+			p.pos += n
+
+		case (c == '\n' || c == '\r') && !isMultiLine:
+			p.fail(start, start+1, "Unterminated string literal")
+
+		default:
+			p.checkStringChar(c, isMultiLine)
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
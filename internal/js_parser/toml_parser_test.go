@@ -0,0 +1,170 @@
+package js_parser
+
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func expectParseErrorTOML(t *testing.T, contents string, expected string) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		ParseTOML(log, test.SourceForTest(contents))
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqualWithDiff(t, text, expected)
+	})
+}
+
+func expectPrintedTOML(t *testing.T, contents string, expected string) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		expr, ok := ParseTOML(log, test.SourceForTest(contents))
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqualWithDiff(t, text, "")
+		if !ok {
+			t.Fatal("Parse error")
+		}
+
+		// Insert this expression into a statement
+		tree := js_ast.AST{
+			Parts: []js_ast.Part{{Stmts: []js_ast.Stmt{{Data: &js_ast.SExpr{Value: expr}}}}},
+		}
+
+		js := js_printer.Print(tree, js_ast.SymbolMap{}, nil, js_printer.Options{
+			RemoveWhitespace: true,
+		}).JS
+
+		// Remove the trailing semicolon
+		if n := len(js); n > 1 && js[n-1] == ';' {
+			js = js[:n-1]
+		}
+
+		test.AssertEqualWithDiff(t, string(js), expected)
+	})
+}
+
+func TestTOMLKeyValue(t *testing.T) {
+	expectPrintedTOML(t, "", "({})")
+	expectPrintedTOML(t, "# comment\n\n", "({})")
+	expectPrintedTOML(t, "a = 1\nb = 2", "({a:1,b:2})")
+	expectPrintedTOML(t, "a = 1 # comment\r\nb = 2\r\n", "({a:1,b:2})")
+	expectPrintedTOML(t, "bare_key-1 = 1", "({\"bare_key-1\":1})")
+	expectPrintedTOML(t, "\"quoted key\" = 1\n'literal key' = 2", "({\"quoted key\":1,\"literal key\":2})")
+	expectPrintedTOML(t, "\"\" = 1", "({\"\":1})")
+	expectPrintedTOML(t, "1234 = 1", "({\"1234\":1})")
+	expectPrintedTOML(t, "a.b.c = 1\na . d = 2", "({a:{b:{c:1},d:2}})")
+	expectPrintedTOML(t, "3.14 = 1", "({\"3\":{\"14\":1}})")
+	expectParseErrorTOML(t, "a = 1\na = 2", "<stdin>: ERROR: Duplicate key \"a\"\n<stdin>: NOTE: The original key \"a\" is here:\n")
+	expectParseErrorTOML(t, "a.b = 1\na.b = 2", "<stdin>: ERROR: Duplicate key \"a.b\"\n<stdin>: NOTE: The original key \"a.b\" is here:\n")
+	expectParseErrorTOML(t, "a = 1\na.b = 2", "<stdin>: ERROR: Cannot add to \"a\" because it has already been defined\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "a = 1 b = 2", "<stdin>: ERROR: Expected end of line but found \"b\"\n")
+	expectParseErrorTOML(t, "a", "<stdin>: ERROR: Expected \"=\" but found end of file\n")
+	expectParseErrorTOML(t, "a =", "<stdin>: ERROR: Expected value but found end of file\n")
+	expectParseErrorTOML(t, "a =\nb = 1", "<stdin>: ERROR: Expected value but found end of line\n")
+	expectParseErrorTOML(t, "= 1", "<stdin>: ERROR: Expected key but found \"=\"\n")
+	expectParseErrorTOML(t, "\"\"\"a\"\"\" = 1", "<stdin>: ERROR: Multi-line strings cannot be used as keys\n")
+}
+
+func TestTOMLString(t *testing.T) {
+	expectPrintedTOML(t, "a = \"x\\ty\\n\\\"\\\\\"", "({a:'x\ty\\n\"\\\\'})")
+	expectPrintedTOML(t, "a = \"\\u00e9\\U0001F600\"", "({a:\"é😀\"})")
+	expectPrintedTOML(t, "a = 'C:\\path'", "({a:\"C:\\\\path\"})")
+	expectPrintedTOML(t, "a = \"\"\"\nx\ny\"\"\"", "({a:\"x\\ny\"})")
+	expectPrintedTOML(t, "a = \"\"\"x \\\n    y\"\"\"", "({a:\"x y\"})")
+	expectPrintedTOML(t, "a = \"\"\"\"x\"\"\"\"\"", "({a:'\"x\"\"'})")
+	expectPrintedTOML(t, "a = '''\nx\\n'y'\n'''", "({a:\"x\\\\n'y'\\n\"})")
+	expectParseErrorTOML(t, "a = \"x", "<stdin>: ERROR: Unterminated string literal\n")
+	expectParseErrorTOML(t, "a = \"x\ny\"", "<stdin>: ERROR: Unterminated string literal\n")
+	expectParseErrorTOML(t, "a = \"\\x\"", "<stdin>: ERROR: Invalid escape sequence\n")
+	expectParseErrorTOML(t, "a = \"\\uD800\"", "<stdin>: ERROR: Invalid escape sequence\n")
+	expectParseErrorTOML(t, "a = \"\x01\"", "<stdin>: ERROR: Syntax error \"\\x01\"\n")
+}
+
+func TestTOMLNumber(t *testing.T) {
+	expectPrintedTOML(t, "a = 42", "({a:42})")
+	expectPrintedTOML(t, "a = +17", "({a:17})")
+	expectPrintedTOML(t, "a = -17", "({a:-17})")
+	expectPrintedTOML(t, "a = 1_000", "({a:1e3})")
+	expectPrintedTOML(t, "a = 0xDEAD_beef", "({a:3735928559})")
+	expectPrintedTOML(t, "a = 0o755", "({a:493})")
+	expectPrintedTOML(t, "a = 0b1101", "({a:13})")
+	expectPrintedTOML(t, "a = 3.1415", "({a:3.1415})")
+	expectPrintedTOML(t, "a = -0.01", "({a:-.01})")
+	expectPrintedTOML(t, "a = 5e+22", "({a:5e22})")
+	expectPrintedTOML(t, "a = 6.626e-34", "({a:6626e-37})")
+	expectPrintedTOML(t, "a = inf\nb = -inf\nc = nan", "({a:Infinity,b:-Infinity,c:NaN})")
+	expectPrintedTOML(t, "a = true\nb = false", "({a:true,b:false})")
+	expectParseErrorTOML(t, "a = 01", "<stdin>: ERROR: Invalid value \"01\"\n")
+	expectParseErrorTOML(t, "a = 1__0", "<stdin>: ERROR: Invalid value \"1__0\"\n")
+	expectParseErrorTOML(t, "a = _1", "<stdin>: ERROR: Invalid value \"_1\"\n")
+	expectParseErrorTOML(t, "a = .5", "<stdin>: ERROR: Invalid value \".5\"\n")
+	expectParseErrorTOML(t, "a = 5.", "<stdin>: ERROR: Invalid value \"5.\"\n")
+	expectParseErrorTOML(t, "a = +0x1", "<stdin>: ERROR: Invalid value \"+0x1\"\n")
+	expectParseErrorTOML(t, "a = True", "<stdin>: ERROR: Invalid value \"True\"\n")
+}
+
+func TestTOMLDateTime(t *testing.T) {
+	expectPrintedTOML(t, "a = 1979-05-27T07:32:00Z", "({a:\"1979-05-27T07:32:00Z\"})")
+	expectPrintedTOML(t, "a = 1979-05-27T00:32:00.999999-07:00", "({a:\"1979-05-27T00:32:00.999999-07:00\"})")
+	expectPrintedTOML(t, "a = 1979-05-27 07:32:00", "({a:\"1979-05-27 07:32:00\"})")
+	expectPrintedTOML(t, "a = 1979-05-27", "({a:\"1979-05-27\"})")
+	expectPrintedTOML(t, "a = 07:32:00.5", "({a:\"07:32:00.5\"})")
+	expectParseErrorTOML(t, "a = 1979-13-27", "<stdin>: ERROR: Invalid value \"1979-13-27\"\n")
+	expectParseErrorTOML(t, "a = 1979-05-27T25:00:00", "<stdin>: ERROR: Invalid value \"1979-05-27T25:00:00\"\n")
+}
+
+func TestTOMLArray(t *testing.T) {
+	expectPrintedTOML(t, "a = []", "({a:[]})")
+	expectPrintedTOML(t, "a = [1, \"two\", [3], {x = 4}]", "({a:[1,\"two\",[3],{x:4}]})")
+	expectPrintedTOML(t, "a = [\n  1, # one\n  2,\n]", "({a:[1,2]})")
+	expectParseErrorTOML(t, "a = [1 2]", "<stdin>: ERROR: Expected \",\" or \"]\" but found \"2\"\n")
+	expectParseErrorTOML(t, "a = [1,", "<stdin>: ERROR: Expected value but found end of file\n")
+}
+
+func TestTOMLInlineTable(t *testing.T) {
+	expectPrintedTOML(t, "a = {}", "({a:{}})")
+	expectPrintedTOML(t, "a = {x = 1, y.z = 2}", "({a:{x:1,y:{z:2}}})")
+	expectParseErrorTOML(t, "a = {x = 1,}", "<stdin>: ERROR: Expected key but found \"}\"\n")
+	expectParseErrorTOML(t, "a = {x = 1\n}", "<stdin>: ERROR: Expected \",\" or \"}\" but found end of line\n")
+	expectParseErrorTOML(t, "a = {x = 1}\na.y = 2", "<stdin>: ERROR: Cannot add to \"a\" because it has already been defined\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "a = {x = 1}\n[a.b]", "<stdin>: ERROR: Cannot add to \"a\" because it is not a table\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+}
+
+func TestTOMLTable(t *testing.T) {
+	expectPrintedTOML(t, "[a]\nx = 1\n[b]\ny = 2", "({a:{x:1},b:{y:2}})")
+	expectPrintedTOML(t, "x = 0\n[a.b.c]\ny = 1", "({x:0,a:{b:{c:{y:1}}}})")
+	expectPrintedTOML(t, "[ a . \"b\" ]\nx = 1", "({a:{b:{x:1}}})")
+	expectPrintedTOML(t, "[a.b]\nx = 1\n[a]\ny = 2", "({a:{b:{x:1},y:2}})")
+	expectPrintedTOML(t, "[a]\nb.c = 1\n[a.b.d]\ne = 2", "({a:{b:{c:1,d:{e:2}}}})")
+	expectParseErrorTOML(t, "[a]\n[a]", "<stdin>: ERROR: Cannot define the table \"a\" more than once\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "[a]\nb.c = 1\n[a.b]", "<stdin>: ERROR: Cannot define the table \"a.b\" more than once\n<stdin>: NOTE: The key \"a.b\" was originally defined here:\n")
+	expectParseErrorTOML(t, "[a.b]\n[a]\nb.c = 1", "<stdin>: ERROR: Cannot add to \"b\" because it has already been defined\n<stdin>: NOTE: The key \"b\" was originally defined here:\n")
+	expectParseErrorTOML(t, "a = 1\n[a]", "<stdin>: ERROR: Cannot define the table \"a\" more than once\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "[a", "<stdin>: ERROR: Expected \"]\" but found end of file\n")
+	expectParseErrorTOML(t, "[]", "<stdin>: ERROR: Expected key but found \"]\"\n")
+}
+
+func TestTOMLArrayOfTables(t *testing.T) {
+	expectPrintedTOML(t, "[[a]]\nx = 1\n[[a]]\nx = 2", "({a:[{x:1},{x:2}]})")
+	expectPrintedTOML(t, "[[a]]\n[[a]]", "({a:[{},{}]})")
+	expectPrintedTOML(t, "[[a]]\nx = 1\n[a.b]\ny = 2\n[[a]]\nx = 3", "({a:[{x:1,b:{y:2}},{x:3}]})")
+	expectPrintedTOML(t, "[[a]]\n[[a.b]]\nx = 1\n[[a.b]]\nx = 2", "({a:[{b:[{x:1},{x:2}]}]})")
+	expectParseErrorTOML(t, "a = []\n[[a]]", "<stdin>: ERROR: Cannot use \"a\" as an array of tables because it has already been defined\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "[[a]]\n[a]", "<stdin>: ERROR: Cannot define the table \"a\" more than once\n<stdin>: NOTE: The key \"a\" was originally defined here:\n")
+	expectParseErrorTOML(t, "[[a]", "<stdin>: ERROR: Expected \"]]\" but found \"]\"\n")
+}
@@ -6,9 +6,11 @@ package js_parser
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/evanw/esbuild/internal/compat"
+	"github.com/evanw/esbuild/internal/config"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_lexer"
 	"github.com/evanw/esbuild/internal/logger"
@@ -279,8 +281,17 @@ func (p *parser) skipTypeScriptTypeWithOpts(level js_ast.L, opts skipTypeOpts) {
 			kind := tsTypeIdentifierMap[p.lexer.Identifier]
 
 			if kind == tsTypeIdentifierPrefix {
+				isInfer := p.lexer.Identifier == "infer"
 				p.lexer.Next()
 				p.skipTypeScriptType(js_ast.LPrefix)
+
+				// "type Foo<T> = T extends [infer U extends string] ? U : never" added in TypeScript 4.7
+				if isInfer && p.lexer.Token == js_lexer.TExtends {
+					r := p.lexer.Range()
+					if p.trySkipTypeScriptConstraintOfInferTypeWithBacktracking(level) {
+						p.checkTSVersion(r, "A constraint on an \"infer\" type", 4, 7)
+					}
+				}
 				break
 			}
 
@@ -585,7 +596,33 @@ func (p *parser) skipTypeScriptTypeParameters() {
 		p.lexer.Next()
 
 		for {
-			p.lexer.Expect(js_lexer.TIdentifier)
+			// "class Foo<in T> {}" and "class Foo<out T> {}" added in TypeScript 4.7
+			// "function foo<const T>() {}" added in TypeScript 5.0
+			hasName := false
+			for !hasName {
+				if p.lexer.Token == js_lexer.TConst {
+					p.checkTSVersion(p.lexer.Range(), "A \"const\" modifier on a type parameter", 5, 0)
+					p.lexer.Next()
+				} else if p.lexer.Token == js_lexer.TIn {
+					p.checkTSVersion(p.lexer.Range(), "A variance annotation on a type parameter", 4, 7)
+					p.lexer.Next()
+				} else if p.lexer.IsContextualKeyword("out") {
+					r := p.lexer.Range()
+					p.lexer.Next()
+
+					// "class Foo<out> {}" is a type parameter named "out"
+					if p.lexer.Token != js_lexer.TIdentifier {
+						hasName = true
+						break
+					}
+					p.checkTSVersion(r, "A variance annotation on a type parameter", 4, 7)
+				} else {
+					break
+				}
+			}
+			if !hasName {
+				p.lexer.Expect(js_lexer.TIdentifier)
+			}
 
 			// "class Foo<T extends number> {}"
 			if p.lexer.Token == js_lexer.TExtends {
@@ -687,6 +724,60 @@ func (p *parser) trySkipTypeScriptTypeParametersThenOpenParenWithBacktracking()
 	return true
 }
 
+func (p *parser) trySkipTypeScriptConstraintOfInferTypeWithBacktracking(level js_ast.L) bool {
+	oldLexer := p.lexer
+	p.lexer.IsLogDisabled = true
+
+	// Implement backtracking by restoring the lexer's memory to its original state
+	defer func() {
+		r := recover()
+		if _, isLexerPanic := r.(js_lexer.LexerPanic); isLexerPanic {
+			p.lexer = oldLexer
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	p.lexer.Expect(js_lexer.TExtends)
+	p.skipTypeScriptType(js_ast.LConditional)
+
+	// "T extends [infer U extends string ? 1 : 2]" is a conditional type unless
+	// conditional types aren't allowed here
+	if level < js_ast.LConditional && p.lexer.Token == js_lexer.TQuestion {
+		p.lexer.Unexpected()
+	}
+
+	// Restore the log disabled flag. Note that we can't just set it back to false
+	// because it may have been true to start with.
+	p.lexer.IsLogDisabled = oldLexer.IsLogDisabled
+	return true
+}
+
+// Newer TypeScript syntax is always parsed, but it's an error unless the user
+// has said that they are using a version of TypeScript that supports it. This
+// is deferred instead of logged right away in case we're going to backtrack.
+func (p *parser) checkTSVersion(r logger.Range, what string, major uint8, minor uint8) {
+	if p.options.ts.Version.IsAtLeast(major, minor) {
+		return
+	}
+	version := fmt.Sprintf("%d.%d", major, minor)
+	var how string
+	switch logger.API {
+	case logger.CLIAPI:
+		how = fmt.Sprintf("You can use \"--ts-version=%s\" to enable it.", version)
+	case logger.JSAPI:
+		how = fmt.Sprintf("You can use \"tsVersion: '%s'\" to enable it.", version)
+	case logger.GoAPI:
+		how = fmt.Sprintf("You can use 'TSVersion: \"%s\"' to enable it.", version)
+	}
+	p.lexer.DeferredErrors = append(p.lexer.DeferredErrors, logger.Msg{
+		Kind: logger.Error,
+		Data: p.tracker.MsgData(r, fmt.Sprintf("%s requires TypeScript %s or newer", what, version)),
+		Notes: []logger.MsgData{{Text: fmt.Sprintf(
+			"This syntax is only allowed when esbuild is configured for TypeScript %s or newer. %s", version, how)}},
+	})
+}
+
 func (p *parser) trySkipTypeScriptArrowReturnTypeWithBacktracking() bool {
 	oldLexer := p.lexer
 	p.lexer.IsLogDisabled = true
@@ -1587,6 +1678,161 @@ func (p *parser) generateClosureForTypeScriptEnum(
 	return stmts
 }
 
+// Frozen enums are plain objects, which are smaller than the closure above but
+// don't map numeric values back to their names:
+//
+//   var x = /* @__PURE__ */ __freeze({
+//     y: 1
+//   });
+//
+// This is only done for top-level enums where every value is a constant, so
+// uses of the enum inside its own initializers have all been inlined.
+func (p *parser) generateFrozenObjectForTypeScriptEnum(
+	stmts []js_ast.Stmt, stmtLoc logger.Loc, isExport bool, nameLoc logger.Loc,
+	nameRef js_ast.Ref, properties []js_ast.Property,
+) ([]js_ast.Stmt, *js_ast.SLocal) {
+	value := p.callRuntime(stmtLoc, "__freeze", []js_ast.Expr{{Loc: stmtLoc, Data: &js_ast.EObject{Properties: properties}}})
+	value.Data.(*js_ast.ECall).CanBeUnwrappedIfUnused = true
+	local := &js_ast.SLocal{
+		Kind: js_ast.LocalVar,
+		Decls: []js_ast.Decl{{
+			Binding:    js_ast.Binding{Loc: nameLoc, Data: &js_ast.BIdentifier{Ref: nameRef}},
+			ValueOrNil: value,
+		}},
+		IsExport: isExport,
+	}
+	p.emittedNamespaceVars[nameRef] = true
+	return append(stmts, js_ast.Stmt{Loc: stmtLoc, Data: local}), local
+}
+
+func (p *parser) canEmitFrozenTSEnum(nameRef js_ast.Ref, exportedMembers js_ast.TSNamespaceMembers, valueCount int) bool {
+	if p.currentScope != p.moduleScope || len(exportedMembers) != valueCount {
+		return false
+	}
+	for _, member := range exportedMembers {
+		if !member.IsEnumValue {
+			return false
+		}
+	}
+
+	// Follow the link chain in case symbols were merged
+	symbol := p.symbols[nameRef.InnerIndex]
+	for symbol.Link != js_ast.InvalidRef {
+		nameRef = symbol.Link
+		symbol = p.symbols[nameRef.InnerIndex]
+	}
+	return !p.emittedNamespaceVars[nameRef]
+}
+
+type inlinableTSEnum struct {
+	local *js_ast.SLocal
+	ref   js_ast.Ref
+	bytes int32
+}
+
+func (p *parser) recordInlinedTSEnumUse(target js_ast.Expr) {
+	if id, ok := target.Data.(*js_ast.EIdentifier); ok && p.options.ts.Enums == config.TSEnumsInline {
+		if p.inlinedTSEnumUses == nil {
+			p.inlinedTSEnumUses = make(map[js_ast.Ref]uint32)
+		}
+		p.inlinedTSEnumUses[id.Ref]++
+	}
+}
+
+// An enum can be removed if every use of it was inlined, including uses in
+// dead code, and it isn't exported with an export clause
+func (p *parser) removeInlinedTSEnums(parts []js_ast.Part) []js_ast.Part {
+	if p.moduleScope.ContainsDirectEval {
+		return parts
+	}
+	exported := make(map[js_ast.Ref]bool)
+	for _, export := range p.namedExports {
+		exported[export.Ref] = true
+	}
+	removed := make(map[*js_ast.SLocal]bool)
+	for _, enum := range p.inlinableTSEnums {
+		if p.tsUseCounts[enum.ref.InnerIndex] == p.inlinedTSEnumUses[enum.ref] && !exported[enum.ref] {
+			removed[enum.local] = true
+			p.tsEnumSummary.Frozen--
+			p.tsEnumSummary.Inlined++
+			p.tsEnumSummary.ActualBytes -= enum.bytes
+		}
+	}
+	if len(removed) == 0 {
+		return parts
+	}
+
+	// Roll back the uses of the runtime helper from the removed enums
+	freezeRef := p.runtimeImports["__freeze"]
+	for i := range parts {
+		part := &parts[i]
+		stmts := part.Stmts[:0]
+		for _, stmt := range part.Stmts {
+			if local, ok := stmt.Data.(*js_ast.SLocal); ok && removed[local] {
+				if use, ok := part.SymbolUses[freezeRef]; ok {
+					p.symbols[freezeRef.InnerIndex].UseCountEstimate--
+					if use.CountEstimate--; use.CountEstimate == 0 {
+						delete(part.SymbolUses, freezeRef)
+					} else {
+						part.SymbolUses[freezeRef] = use
+					}
+				}
+				continue
+			}
+			stmts = append(stmts, stmt)
+		}
+		part.Stmts = stmts
+	}
+	if p.symbols[freezeRef.InnerIndex].UseCountEstimate == 0 {
+		delete(p.runtimeImports, "__freeze")
+	}
+	return parts
+}
+
+// These estimate the size of an enum when minified, both as a classic enum and
+// as a frozen enum. They are only used to report the approximate savings.
+type tsEnumSizes struct {
+	classic int32
+	frozen  int32
+}
+
+func makeTSEnumSizes(name string) tsEnumSizes {
+	// "var x=(e=>(" + "e))(x||{});" versus "var x=__freeze({" + "});"
+	return tsEnumSizes{
+		classic: 20 + 2*int32(len(name)),
+		frozen:  18 + int32(len(name)),
+	}
+}
+
+func (sizes *tsEnumSizes) add(name string, valueBytes int32, hasStringValue bool) {
+	nameBytes := int32(len(name))
+	if !js_lexer.IsIdentifier(name) {
+		nameBytes += 2
+	}
+	if hasStringValue {
+		// "e.y=v,"
+		sizes.classic += 4 + nameBytes + valueBytes
+	} else {
+		// "e[e.y=v]="y","
+		sizes.classic += 10 + 2*nameBytes + valueBytes
+	}
+
+	// "y:v,"
+	sizes.frozen += 2 + nameBytes + valueBytes
+}
+
+func tsEnumValueSize(value js_ast.Expr) (int32, bool) {
+	switch e := value.Data.(type) {
+	case *js_ast.ENumber:
+		return int32(len(strconv.FormatFloat(e.Value, 'g', -1, 64))), true
+	case *js_ast.EString:
+		return int32(len(e.Value)) + 2, true
+	case *js_ast.EUndefined:
+		return int32(len("void 0")), true
+	}
+	return 0, false
+}
+
 func (p *parser) wrapInlinedEnum(value js_ast.Expr, comment string) js_ast.Expr {
 	if p.shouldFoldNumericConstants || p.options.mangleSyntax || strings.Contains(comment, "*/") {
 		// Don't wrap with a comment
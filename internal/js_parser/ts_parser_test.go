@@ -49,6 +49,36 @@ func expectPrintedTargetTS(t *testing.T, esVersion int, contents string, expecte
 	})
 }
 
+func expectParseErrorVersionTS(t *testing.T, major uint8, minor uint8, contents string, expected string) {
+	t.Helper()
+	expectParseErrorCommon(t, contents, expected, config.Options{
+		TS: config.TSOptions{
+			Parse:   true,
+			Version: config.TSVersion{Major: major, Minor: minor},
+		},
+	})
+}
+
+func expectPrintedVersionTS(t *testing.T, major uint8, minor uint8, contents string, expected string) {
+	t.Helper()
+	expectPrintedCommon(t, contents, expected, config.Options{
+		TS: config.TSOptions{
+			Parse:   true,
+			Version: config.TSVersion{Major: major, Minor: minor},
+		},
+	})
+}
+
+func expectPrintedEnumsTS(t *testing.T, enums config.TSEnums, contents string, expected string) {
+	t.Helper()
+	expectPrintedCommon(t, contents, expected, config.Options{
+		TS: config.TSOptions{
+			Parse: true,
+			Enums: enums,
+		},
+	})
+}
+
 func expectParseErrorTSNoAmbiguousLessThan(t *testing.T, contents string, expected string) {
 	t.Helper()
 	expectParseErrorCommon(t, contents, expected, config.Options{
@@ -295,6 +325,51 @@ func TestTSAsCast(t *testing.T) {
 	expectParseErrorTS(t, "(x = y as any(z));", "<stdin>: ERROR: Expected \")\" but found \"(\"\n")
 }
 
+func TestTSSatisfies(t *testing.T) {
+	expectPrintedVersionTS(t, 4, 9, "x satisfies any", "x;\n")
+	expectPrintedVersionTS(t, 4, 9, "x = y satisfies Z", "x = y;\n")
+	expectPrintedVersionTS(t, 4, 9, "(x satisfies any) + 1", "x + 1;\n")
+	expectPrintedVersionTS(t, 4, 9, "x satisfies Y as Z", "x;\n")
+	expectPrintedVersionTS(t, 4, 9, "x satisfies any\n(y);", "x;\ny;\n")
+	expectPrintedVersionTS(t, 4, 9, "let satisfies = 1; satisfies satisfies any", "let satisfies = 1;\nsatisfies;\n")
+	expectParseErrorVersionTS(t, 4, 9, "x = y satisfies any(z);", "<stdin>: ERROR: Expected \";\" but found \"(\"\n")
+	expectParseErrorVersionTS(t, 4, 9, "x satisfies any = y;", "<stdin>: ERROR: Expected \";\" but found \"=\"\n")
+
+	expectParseErrorTS(t, "x satisfies any", "<stdin>: ERROR: The \"satisfies\" operator requires TypeScript 4.9 or newer\n"+
+		"NOTE: This syntax is only allowed when esbuild is configured for TypeScript 4.9 or newer. You can use 'TSVersion: \"4.9\"' to enable it.\n")
+	expectParseErrorVersionTS(t, 4, 8, "x satisfies any", "<stdin>: ERROR: The \"satisfies\" operator requires TypeScript 4.9 or newer\n"+
+		"NOTE: This syntax is only allowed when esbuild is configured for TypeScript 4.9 or newer. You can use 'TSVersion: \"4.9\"' to enable it.\n")
+}
+
+func TestTSTypeParameterModifiers(t *testing.T) {
+	expectPrintedVersionTS(t, 4, 7, "class Foo<in T> {}", "class Foo {\n}\n")
+	expectPrintedVersionTS(t, 4, 7, "class Foo<out T> {}", "class Foo {\n}\n")
+	expectPrintedVersionTS(t, 4, 7, "class Foo<in out T, out> {}", "class Foo {\n}\n")
+	expectPrintedVersionTS(t, 4, 7, "interface Foo<in T, out U> {}", "")
+	expectPrintedVersionTS(t, 4, 7, "type Foo<out T> = T", "")
+	expectPrintedTS(t, "class Foo<out> {}", "class Foo {\n}\n")
+	expectPrintedTS(t, "class Foo<out extends number = 1> {}", "class Foo {\n}\n")
+	expectPrintedTS(t, "class Foo<T, out> {}", "class Foo {\n}\n")
+	expectParseErrorTS(t, "class Foo<in T> {}", "<stdin>: ERROR: A variance annotation on a type parameter requires TypeScript 4.7 or newer\n"+
+		"NOTE: This syntax is only allowed when esbuild is configured for TypeScript 4.7 or newer. You can use 'TSVersion: \"4.7\"' to enable it.\n")
+
+	expectPrintedVersionTS(t, 5, 0, "function foo<const T>(x: T) {}", "function foo(x) {\n}\n")
+	expectPrintedVersionTS(t, 5, 0, "let foo = <const T,>(x: T) => x", "let foo = (x) => x;\n")
+	expectPrintedVersionTS(t, 5, 0, "class Foo { foo<const T extends readonly unknown[]>(x: T) {} }", "class Foo {\n  foo(x) {\n  }\n}\n")
+	expectParseErrorVersionTS(t, 4, 9, "let foo = <const T,>(x: T) => x", "<stdin>: ERROR: A \"const\" modifier on a type parameter requires TypeScript 5.0 or newer\n"+
+		"NOTE: This syntax is only allowed when esbuild is configured for TypeScript 5.0 or newer. You can use 'TSVersion: \"5.0\"' to enable it.\n")
+}
+
+func TestTSInferConstraint(t *testing.T) {
+	expectPrintedVersionTS(t, 4, 7, "type Foo<T> = T extends [infer U extends string] ? U : never", "")
+	expectPrintedVersionTS(t, 4, 7, "type Foo<T> = T extends infer U extends string ? U : never", "")
+	expectPrintedVersionTS(t, 4, 7, "type Foo<T> = T extends { a: infer U extends number } ? U : never", "")
+	expectPrintedTS(t, "type Foo<T> = T extends [infer U extends string ? 1 : 2] ? U : never", "")
+	expectParseErrorTS(t, "type Foo<T> = T extends [infer U extends string] ? U : never",
+		"<stdin>: ERROR: A constraint on an \"infer\" type requires TypeScript 4.7 or newer\n"+
+			"NOTE: This syntax is only allowed when esbuild is configured for TypeScript 4.7 or newer. You can use 'TSVersion: \"4.7\"' to enable it.\n")
+}
+
 func TestTSClass(t *testing.T) {
 	expectPrintedTS(t, "export default class Foo {}", "export default class Foo {\n}\n")
 	expectPrintedTS(t, "export default class Foo extends Bar<T> {}", "export default class Foo extends Bar {\n}\n")
@@ -1261,6 +1336,37 @@ func TestTSEnumConstantFolding(t *testing.T) {
 `)
 }
 
+func TestTSEnumFrozenAndInline(t *testing.T) {
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "enum Foo { A, B = 'b', C = A + 2, D = B, E }",
+		"var Foo = /* @__PURE__ */ __freeze({\n  A: 0,\n  B: \"b\",\n  C: 2,\n  D: \"b\",\n  E: void 0\n});\n")
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "export enum Foo { A } console.log(Foo.A, Foo[0])",
+		"export var Foo = /* @__PURE__ */ __freeze({\n  A: 0\n});\nconsole.log(0 /* A */, Foo[0]);\n")
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "enum Foo { '__proto__' = 1 }",
+		"var Foo = /* @__PURE__ */ ((Foo) => {\n  Foo[Foo[\"__proto__\"] = 1] = \"__proto__\";\n  return Foo;\n})(Foo || {});\n")
+
+	// Values that aren't constants and merged declarations need classic enums
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "enum Foo { A = foo() }",
+		"var Foo = ((Foo) => {\n  Foo[Foo[\"A\"] = foo()] = \"A\";\n  return Foo;\n})(Foo || {});\n")
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "enum Foo { A } enum Foo { B = 1 }",
+		"var Foo = /* @__PURE__ */ ((Foo) => {\n  Foo[Foo[\"A\"] = 0] = \"A\";\n  return Foo;\n})(Foo || {});\n"+
+			"var Foo = /* @__PURE__ */ ((Foo) => {\n  Foo[Foo[\"B\"] = 1] = \"B\";\n  return Foo;\n})(Foo || {});\n")
+	expectPrintedEnumsTS(t, config.TSEnumsFrozen, "enum Foo { A } namespace Foo { export let b }",
+		"var Foo = /* @__PURE__ */ ((Foo) => {\n  Foo[Foo[\"A\"] = 0] = \"A\";\n  return Foo;\n})(Foo || {});\n"+
+			"((Foo) => {\n})(Foo || (Foo = {}));\n")
+
+	// Enums are only removed if every use was inlined
+	expectPrintedEnumsTS(t, config.TSEnumsInline, "enum Foo { A, B } console.log(Foo.A, Foo.B)",
+		"console.log(0 /* A */, 1 /* B */);\n")
+	expectPrintedEnumsTS(t, config.TSEnumsInline, "enum Foo { A, B } console.log(Foo.A, Foo)",
+		"var Foo = /* @__PURE__ */ __freeze({\n  A: 0,\n  B: 1\n});\nconsole.log(0 /* A */, Foo);\n")
+	expectPrintedEnumsTS(t, config.TSEnumsInline, "enum Foo { A } export { Foo }",
+		"var Foo = /* @__PURE__ */ __freeze({\n  A: 0\n});\nexport { Foo };\n")
+	expectPrintedEnumsTS(t, config.TSEnumsInline, "export enum Foo { A }",
+		"export var Foo = /* @__PURE__ */ __freeze({\n  A: 0\n});\n")
+	expectPrintedEnumsTS(t, config.TSEnumsInline, "enum Foo { A } function f() { return; Foo }",
+		"var Foo = /* @__PURE__ */ __freeze({\n  A: 0\n});\nfunction f() {\n  return;\n  Foo;\n}\n")
+}
+
 func TestTSFunction(t *testing.T) {
 	expectPrintedTS(t, "function foo(): void; function foo(): void {}", "function foo() {\n}\n")
 
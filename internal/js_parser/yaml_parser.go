@@ -0,0 +1,993 @@
+package js_parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/evanw/esbuild/internal/helpers"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_lexer"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This parses the subset of YAML 1.2 that's used for configuration files:
+// block and flow collections, all scalar styles, comments, anchors and
+// aliases, and "<<" merge keys. Tags, complex mapping keys, and files with
+// multiple documents are not supported. Plain scalars are resolved using the
+// YAML 1.2 core schema, so "yes" and "no" are strings instead of booleans.
+type yamlParser struct {
+	log                            logger.Log
+	source                         logger.Source
+	tracker                        logger.LineColumnTracker
+	text                           string
+	pos                            int
+	flowLevel                      int
+	anchors                        map[string]js_ast.Expr
+	suppressWarningsAboutWeirdCode bool
+}
+
+type yamlPanic struct{}
+
+func ParseYAML(log logger.Log, source logger.Source) (result js_ast.Expr, ok bool) {
+	ok = true
+	defer func() {
+		r := recover()
+		if _, isYAMLPanic := r.(yamlPanic); isYAMLPanic {
+			ok = false
+		} else if r != nil {
+			panic(r)
+		}
+	}()
+
+	p := &yamlParser{
+		log:                            log,
+		source:                         source,
+		tracker:                        logger.MakeLineColumnTracker(&source),
+		text:                           source.Contents,
+		anchors:                        make(map[string]js_ast.Expr),
+		suppressWarningsAboutWeirdCode: helpers.IsInsideNodeModules(source.KeyPath.Text),
+	}
+
+	result = p.parseDocument()
+	return
+}
+
+func (p *yamlParser) fail(start int, end int, text string) {
+	p.log.Add(logger.Error, &p.tracker, logger.Range{Loc: logger.Loc{Start: int32(start)}, Len: int32(end - start)}, text)
+	panic(yamlPanic{})
+}
+
+func (p *yamlParser) unexpected() {
+	if p.pos >= len(p.text) {
+		p.fail(p.pos, p.pos, "Unexpected end of file")
+	}
+	end := p.tokenEnd(p.pos)
+	p.fail(p.pos, end, fmt.Sprintf("Unexpected %q", p.text[p.pos:end]))
+}
+
+func (p *yamlParser) expected(what string) {
+	if p.pos >= len(p.text) {
+		p.fail(p.pos, p.pos, fmt.Sprintf("Expected %s but found end of file", what))
+	}
+	_, width := utf8.DecodeRuneInString(p.text[p.pos:])
+	p.fail(p.pos, p.pos+width, fmt.Sprintf("Expected %s but found %q", what, p.text[p.pos:p.pos+width]))
+}
+
+func (p *yamlParser) peek(offset int) byte {
+	if i := p.pos + offset; i < len(p.text) {
+		return p.text[i]
+	}
+	return 0
+}
+
+func (p *yamlParser) tokenEnd(pos int) int {
+	end := pos + 1
+	for end < len(p.text) && !isYAMLSpaceOrEnd(p.text[end]) {
+		end++
+	}
+	return end
+}
+
+func (p *yamlParser) column(pos int) int {
+	return pos - (strings.LastIndexByte(p.text[:pos], '\n') + 1)
+}
+
+func (p *yamlParser) atLineEnd() bool {
+	if p.pos >= len(p.text) {
+		return true
+	}
+	c := p.text[p.pos]
+	return c == '\n' || c == '\r' || c == '#'
+}
+
+func (p *yamlParser) isDocumentMarker(pos int) bool {
+	if pos+3 > len(p.text) || p.column(pos) != 0 {
+		return false
+	}
+	marker := p.text[pos : pos+3]
+	return (marker == "---" || marker == "...") && (pos+3 == len(p.text) || isYAMLSpaceOrEnd(p.text[pos+3]))
+}
+
+func isYAMLSpaceOrEnd(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isYAMLFlowIndicator(c byte) bool {
+	return c == ',' || c == '[' || c == ']' || c == '{' || c == '}'
+}
+
+func (p *yamlParser) skipInlineSpace() {
+	for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// This skips whitespace, comments, and line breaks until the next token
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+
+		case '#':
+			for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+				p.pos++
+			}
+
+		default:
+			// Indentation is significant outside of flow collections, so tabs can't
+			// be used for it since they don't have a well-defined width
+			if p.flowLevel == 0 {
+				lineStart := p.pos - p.column(p.pos)
+				if indent := p.text[lineStart:p.pos]; strings.IndexByte(indent, '\t') != -1 && strings.Trim(indent, " \t") == "" {
+					p.fail(lineStart, p.pos, "Tabs are not allowed in YAML indentation")
+				}
+			}
+			return
+		}
+	}
+}
+
+func (p *yamlParser) parseDocument() js_ast.Expr {
+	p.skipBlank()
+
+	// Directives such as "%YAML 1.2" don't change how the document is parsed
+	for p.pos < len(p.text) && p.text[p.pos] == '%' && p.column(p.pos) == 0 {
+		for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+			p.pos++
+		}
+		p.skipBlank()
+	}
+
+	if p.isDocumentMarker(p.pos) && p.text[p.pos] == '-' {
+		p.pos += 3
+		p.skipBlank()
+	}
+
+	value := js_ast.Expr{Loc: logger.Loc{Start: int32(p.pos)}, Data: js_ast.ENullShared}
+	if p.pos < len(p.text) && !p.isDocumentMarker(p.pos) {
+		value = p.parseNode(-1, true)
+		p.skipBlank()
+	}
+
+	if p.isDocumentMarker(p.pos) && p.text[p.pos] == '.' {
+		p.pos += 3
+		p.skipBlank()
+	}
+	if p.isDocumentMarker(p.pos) {
+		p.fail(p.pos, p.pos+3, "Multiple documents in one YAML file are not supported")
+	}
+	if p.pos < len(p.text) {
+		p.unexpected()
+	}
+	return value
+}
+
+// This parses the node at the current position. Block collections can only
+// start here if "allowBlock" is true, which isn't the case after a mapping
+// key on the same line or inside a flow collection.
+func (p *yamlParser) parseNode(parentIndent int, allowBlock bool) js_ast.Expr {
+	start := p.pos
+	loc := logger.Loc{Start: int32(start)}
+	isBlock := p.flowLevel == 0
+
+	switch c := p.peek(0); {
+	case c == '&':
+		name := p.parseAnchorName()
+		p.skipInlineSpace()
+		value := js_ast.Expr{Loc: logger.Loc{Start: int32(p.pos)}, Data: js_ast.ENullShared}
+		if isBlock && p.atLineEnd() {
+			// The anchored node may start on the next line
+			p.skipBlank()
+			if p.pos < len(p.text) && !p.isDocumentMarker(p.pos) && p.column(p.pos) > parentIndent {
+				value = p.parseNode(parentIndent, true)
+			}
+		} else if !isBlock && (p.peek(0) == ',' || p.peek(0) == ']' || p.peek(0) == '}') {
+			// An anchor with no value in a flow collection is null
+		} else {
+			value = p.parseNode(parentIndent, allowBlock)
+		}
+		p.anchors[name] = value
+		return value
+
+	case c == '*':
+		name := p.parseAnchorName()
+		value, ok := p.anchors[name]
+		if !ok {
+			p.fail(start, p.pos, fmt.Sprintf("The anchor %q has not been defined", name))
+		}
+		p.expectEndOfScalar()
+		return cloneYAMLValue(value, loc)
+
+	case c == '!':
+		p.fail(start, p.tokenEnd(start), "YAML tags are not supported")
+
+	case c == '?' && isYAMLSpaceOrEnd(p.peek(1)):
+		p.fail(start, start+1, "Complex mapping keys are not supported")
+
+	case isBlock && (c == '|' || c == '>'):
+		return p.parseBlockScalar(parentIndent)
+
+	case c == '-' && (p.pos+1 == len(p.text) || isYAMLSpaceOrEnd(p.peek(1))):
+		if !allowBlock {
+			p.fail(start, start+1, "Block sequences are not allowed here")
+		}
+		return p.parseBlockSequence(p.column(start))
+
+	case c == '[':
+		value := p.parseFlowSequence(parentIndent)
+		p.expectEndOfScalar()
+		return value
+
+	case c == '{':
+		value := p.parseFlowMapping(parentIndent)
+		p.expectEndOfScalar()
+		return value
+	}
+
+	if allowBlock && p.isMappingKey() {
+		return p.parseBlockMapping(p.column(start))
+	}
+	value := p.parseScalar(parentIndent)
+	p.expectEndOfScalar()
+	return value
+}
+
+// Outside of flow collections, nothing but a comment can follow a scalar or
+// a flow collection on the same line
+func (p *yamlParser) expectEndOfScalar() {
+	if p.flowLevel == 0 {
+		p.skipInlineSpace()
+		if p.peek(0) == ':' {
+			p.fail(p.pos, p.pos+1, "Mapping values are not allowed here")
+		}
+		if !p.atLineEnd() {
+			p.unexpected()
+		}
+	}
+}
+
+func (p *yamlParser) parseAnchorName() string {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.text) && !isYAMLSpaceOrEnd(p.text[p.pos]) && !isYAMLFlowIndicator(p.text[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start+1 {
+		p.fail(start, start+1, "Expected an anchor name")
+	}
+	return p.text[start+1 : p.pos]
+}
+
+// Each alias gets its own copy of the anchored value so that the values
+// don't share any AST nodes
+func cloneYAMLValue(value js_ast.Expr, loc logger.Loc) js_ast.Expr {
+	switch e := value.Data.(type) {
+	case *js_ast.EArray:
+		items := make([]js_ast.Expr, len(e.Items))
+		for i, item := range e.Items {
+			items[i] = cloneYAMLValue(item, item.Loc)
+		}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EArray{Items: items, IsSingleLine: e.IsSingleLine}}
+
+	case *js_ast.EObject:
+		properties := make([]js_ast.Property, len(e.Properties))
+		for i, property := range e.Properties {
+			property.ValueOrNil = cloneYAMLValue(property.ValueOrNil, property.ValueOrNil.Loc)
+			properties[i] = property
+		}
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EObject{Properties: properties, IsSingleLine: e.IsSingleLine}}
+
+	case *js_ast.EString:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: e.Value}}
+
+	case *js_ast.ENumber:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: e.Value}}
+
+	case *js_ast.EBoolean:
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: e.Value}}
+
+	default:
+		return js_ast.Expr{Loc: loc, Data: value.Data}
+	}
+}
+
+// This parses the value after the ":" of a mapping key or the "-" of a
+// sequence entry. The value may be on the same line or on the next lines.
+func (p *yamlParser) parseValue(indent int, isMappingValue bool) js_ast.Expr {
+	loc := logger.Loc{Start: int32(p.pos)}
+	p.skipInlineSpace()
+	if !p.atLineEnd() {
+		// Only sequence entries can start a block collection on the same line
+		return p.parseNode(indent, !isMappingValue)
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.text) && !p.isDocumentMarker(p.pos) {
+		// The entries of a sequence that's the value of a mapping key are allowed
+		// to have the same indentation as the key
+		if column := p.column(p.pos); column > indent || (column == indent && isMappingValue &&
+			p.text[p.pos] == '-' && (p.pos+1 == len(p.text) || isYAMLSpaceOrEnd(p.text[p.pos+1]))) {
+			return p.parseNode(indent, true)
+		}
+	}
+	return js_ast.Expr{Loc: loc, Data: js_ast.ENullShared}
+}
+
+func (p *yamlParser) parseBlockSequence(indent int) js_ast.Expr {
+	loc := logger.Loc{Start: int32(p.pos)}
+	items := []js_ast.Expr{}
+
+	for {
+		p.pos++
+		items = append(items, p.parseValue(indent, false))
+
+		p.skipBlank()
+		if p.pos >= len(p.text) || p.isDocumentMarker(p.pos) {
+			break
+		}
+		column := p.column(p.pos)
+		if column < indent {
+			break
+		}
+		if column > indent {
+			p.fail(p.pos, p.tokenEnd(p.pos), "Unexpected indentation")
+		}
+		if p.text[p.pos] != '-' || (p.pos+1 < len(p.text) && !isYAMLSpaceOrEnd(p.text[p.pos+1])) {
+			// This is the next key of a mapping that contains this sequence
+			break
+		}
+	}
+
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EArray{Items: items}}
+}
+
+func (p *yamlParser) parseBlockMapping(indent int) js_ast.Expr {
+	loc := logger.Loc{Start: int32(p.pos)}
+	mapping := yamlMapping{keys: make(map[string]logger.Range)}
+
+	for {
+		if !p.isMappingKey() {
+			p.expected("mapping key")
+		}
+		key, keyRange, isPlain := p.parseMappingKey()
+		p.skipInlineSpace()
+		p.pos++ // Skip over the ":"
+		value := p.parseValue(indent, true)
+		p.addToMapping(&mapping, key, keyRange, isPlain, value)
+
+		p.skipBlank()
+		if p.pos >= len(p.text) || p.isDocumentMarker(p.pos) {
+			break
+		}
+		column := p.column(p.pos)
+		if column < indent {
+			break
+		}
+		if column > indent {
+			p.fail(p.pos, p.tokenEnd(p.pos), "Unexpected indentation")
+		}
+	}
+
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EObject{Properties: mapping.done()}}
+}
+
+// This checks whether the current line starts with an implicit mapping key
+// such as "key:" without consuming anything
+func (p *yamlParser) isMappingKey() bool {
+	start := p.pos
+	var end int
+	if c := p.peek(0); c == '"' || c == '\'' {
+		p.parseQuotedText()
+		end = p.pos
+		p.pos = start
+	} else {
+		end = p.scanPlainLine(start)
+		if end == start {
+			return false
+		}
+	}
+	for end < len(p.text) && (p.text[end] == ' ' || p.text[end] == '\t') {
+		end++
+	}
+	return end < len(p.text) && p.text[end] == ':' && (end+1 == len(p.text) || isYAMLSpaceOrEnd(p.text[end+1]) ||
+		(p.flowLevel > 0 && isYAMLFlowIndicator(p.text[end+1])))
+}
+
+func (p *yamlParser) parseMappingKey() (key string, keyRange logger.Range, isPlain bool) {
+	start := p.pos
+	if c := p.peek(0); c == '"' || c == '\'' {
+		key = p.parseQuotedText()
+	} else {
+		p.pos = p.scanPlainLine(start)
+		key = p.text[start:p.pos]
+		isPlain = true
+	}
+	keyRange = logger.Range{Loc: logger.Loc{Start: int32(start)}, Len: int32(p.pos - start)}
+	return
+}
+
+type yamlMapping struct {
+	keys       map[string]logger.Range
+	properties []js_ast.Property
+	merged     []js_ast.Property
+}
+
+func (p *yamlParser) addToMapping(mapping *yamlMapping, key string, keyRange logger.Range, isPlain bool, value js_ast.Expr) {
+	// Merge keys include the entries of other mappings in this one
+	if key == "<<" && isPlain {
+		switch e := value.Data.(type) {
+		case *js_ast.EObject:
+			mapping.merged = append(mapping.merged, e.Properties...)
+			return
+
+		case *js_ast.EArray:
+			for _, item := range e.Items {
+				if object, ok := item.Data.(*js_ast.EObject); ok {
+					mapping.merged = append(mapping.merged, object.Properties...)
+				} else {
+					p.fail(int(item.Loc.Start), int(item.Loc.Start), "Expected a mapping to merge")
+				}
+			}
+			return
+		}
+		p.fail(int(keyRange.Loc.Start), int(keyRange.End()), "The value of a \"<<\" merge key must be a mapping or a sequence of mappings")
+	}
+
+	// Warn about duplicate keys
+	if !p.suppressWarningsAboutWeirdCode {
+		if prevRange, ok := mapping.keys[key]; ok {
+			p.log.AddWithNotes(logger.Warning, &p.tracker, keyRange, fmt.Sprintf("Duplicate key %q in mapping", key),
+				[]logger.MsgData{p.tracker.MsgData(prevRange, fmt.Sprintf("The original key %q is here:", key))})
+		}
+	}
+	mapping.keys[key] = keyRange
+
+	mapping.properties = append(mapping.properties, js_ast.Property{
+		Kind:       js_ast.PropertyNormal,
+		Key:        js_ast.Expr{Loc: keyRange.Loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(key)}},
+		ValueOrNil: value,
+	})
+}
+
+// Merged entries come first so that they can be overridden. Earlier merged
+// mappings take precedence over later ones.
+func (mapping *yamlMapping) done() []js_ast.Property {
+	if len(mapping.merged) == 0 {
+		return mapping.properties
+	}
+	properties := make([]js_ast.Property, 0, len(mapping.merged)+len(mapping.properties))
+	seen := make(map[string]bool)
+	for _, property := range mapping.merged {
+		key := js_lexer.UTF16ToString(property.Key.Data.(*js_ast.EString).Value)
+		if _, ok := mapping.keys[key]; !ok && !seen[key] {
+			seen[key] = true
+			properties = append(properties, property)
+		}
+	}
+	return append(properties, mapping.properties...)
+}
+
+func (p *yamlParser) parseFlowSequence(parentIndent int) js_ast.Expr {
+	start := p.pos
+	items := []js_ast.Expr{}
+	p.pos++
+	p.flowLevel++
+
+	for {
+		p.skipBlank()
+		if p.peek(0) == ']' {
+			break
+		}
+		if p.pos >= len(p.text) {
+			p.expected("\"]\"")
+		}
+		items = append(items, p.parseNode(parentIndent, false))
+		p.skipBlank()
+		if p.peek(0) != ',' {
+			if p.peek(0) != ']' {
+				p.expected("\",\" or \"]\"")
+			}
+			break
+		}
+		p.pos++
+	}
+
+	p.pos++
+	p.flowLevel--
+	return js_ast.Expr{Loc: logger.Loc{Start: int32(start)}, Data: &js_ast.EArray{
+		Items:        items,
+		IsSingleLine: strings.IndexByte(p.text[start:p.pos], '\n') == -1,
+	}}
+}
+
+func (p *yamlParser) parseFlowMapping(parentIndent int) js_ast.Expr {
+	start := p.pos
+	mapping := yamlMapping{keys: make(map[string]logger.Range)}
+	p.pos++
+	p.flowLevel++
+
+	for {
+		p.skipBlank()
+		if p.peek(0) == '}' {
+			break
+		}
+		switch c := p.peek(0); {
+		case p.pos >= len(p.text):
+			p.expected("\"}\"")
+		case c == '[' || c == '{' || (c == '?' && isYAMLSpaceOrEnd(p.peek(1))):
+			p.fail(p.pos, p.pos+1, "Complex mapping keys are not supported")
+		case c != '"' && c != '\'' && p.scanPlainLine(p.pos) == p.pos:
+			p.expected("mapping key")
+		}
+
+		// A key without a value has a null value
+		key, keyRange, isPlain := p.parseMappingKey()
+		p.skipBlank()
+		value := js_ast.Expr{Loc: logger.Loc{Start: int32(p.pos)}, Data: js_ast.ENullShared}
+		if p.peek(0) == ':' {
+			p.pos++
+			p.skipBlank()
+			if c := p.peek(0); c != ',' && c != '}' {
+				value = p.parseNode(parentIndent, false)
+				p.skipBlank()
+			}
+		}
+		p.addToMapping(&mapping, key, keyRange, isPlain, value)
+
+		if p.peek(0) != ',' {
+			if p.peek(0) != '}' {
+				p.expected("\",\" or \"}\"")
+			}
+			break
+		}
+		p.pos++
+	}
+
+	p.pos++
+	p.flowLevel--
+	return js_ast.Expr{Loc: logger.Loc{Start: int32(start)}, Data: &js_ast.EObject{
+		Properties:   mapping.done(),
+		IsSingleLine: strings.IndexByte(p.text[start:p.pos], '\n') == -1,
+	}}
+}
+
+func (p *yamlParser) parseScalar(parentIndent int) js_ast.Expr {
+	loc := logger.Loc{Start: int32(p.pos)}
+	switch c := p.peek(0); c {
+	case '"', '\'':
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(p.parseQuotedText())}}
+
+	case ',', ']', '}', '@', '`':
+		p.unexpected()
+	}
+
+	end := p.scanPlainLine(p.pos)
+	if end == p.pos {
+		p.unexpected()
+	}
+	return resolveYAMLPlainScalar(loc, p.parsePlainText(parentIndent, end))
+}
+
+// This returns the end of the plain scalar that starts at "pos", not
+// including any trailing whitespace or anything after the end of the line
+func (p *yamlParser) scanPlainLine(pos int) int {
+	end := pos
+	for i := pos; i < len(p.text); i++ {
+		c := p.text[i]
+		if c == '\n' || c == '\r' {
+			break
+		}
+		if c == ':' && (i+1 == len(p.text) || isYAMLSpaceOrEnd(p.text[i+1]) || (p.flowLevel > 0 && isYAMLFlowIndicator(p.text[i+1]))) {
+			break
+		}
+		if c == '#' && i > pos && (p.text[i-1] == ' ' || p.text[i-1] == '\t') {
+			break
+		}
+		if p.flowLevel > 0 && isYAMLFlowIndicator(c) {
+			break
+		}
+		if c != ' ' && c != '\t' {
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// Plain scalars can continue on the following lines if they are indented
+// more than the parent node. Each line break becomes a space unless it's
+// followed by empty lines, which become line breaks instead.
+func (p *yamlParser) parsePlainText(parentIndent int, end int) string {
+	sb := strings.Builder{}
+	sb.WriteString(p.text[p.pos:end])
+	p.pos = end
+
+	for {
+		i := p.pos
+		for i < len(p.text) && (p.text[i] == ' ' || p.text[i] == '\t') {
+			i++
+		}
+		if i == len(p.text) || (p.text[i] != '\n' && p.text[i] != '\r') {
+			break
+		}
+
+		// Find the next line with content
+		lineBreaks := 0
+		for i < len(p.text) {
+			if c := p.text[i]; c == '\n' {
+				lineBreaks++
+			} else if c != ' ' && c != '\t' && c != '\r' {
+				break
+			}
+			i++
+		}
+		if i == len(p.text) || p.text[i] == '#' || p.column(i) <= parentIndent || p.isDocumentMarker(i) {
+			break
+		}
+		if p.flowLevel > 0 && isYAMLFlowIndicator(p.text[i]) {
+			break
+		}
+		lineEnd := p.scanPlainLine(i)
+		if lineEnd == i {
+			break
+		}
+
+		// The following lines can't contain mapping keys
+		if p.flowLevel == 0 {
+			if j := lineEnd; j < len(p.text) && p.text[j] == ':' {
+				p.fail(j, j+1, "Mapping values are not allowed here")
+			}
+		}
+
+		if lineBreaks == 1 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(strings.Repeat("\n", lineBreaks-1))
+		}
+		sb.WriteString(p.text[i:lineEnd])
+		p.pos = lineEnd
+	}
+
+	return sb.String()
+}
+
+func resolveYAMLPlainScalar(loc logger.Loc, text string) js_ast.Expr {
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return js_ast.Expr{Loc: loc, Data: js_ast.ENullShared}
+	case "true", "True", "TRUE":
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: true}}
+	case "false", "False", "FALSE":
+		return js_ast.Expr{Loc: loc, Data: &js_ast.EBoolean{Value: false}}
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.Inf(1)}}
+	case "-.inf", "-.Inf", "-.INF":
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.Inf(-1)}}
+	case ".nan", ".NaN", ".NAN":
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: math.NaN()}}
+	}
+	if value, ok := parseYAMLNumber(text); ok {
+		return js_ast.Expr{Loc: loc, Data: &js_ast.ENumber{Value: value}}
+	}
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(text)}}
+}
+
+func parseYAMLNumber(text string) (float64, bool) {
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") {
+		base := 16
+		if text[1] == 'o' {
+			base = 8
+		}
+		value, err := strconv.ParseUint(text[2:], base, 64)
+		return float64(value), err == nil
+	}
+
+	// This is "[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?"
+	i := 0
+	if i < len(text) && (text[i] == '-' || text[i] == '+') {
+		i++
+	}
+	digits := 0
+	for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+		i++
+		digits++
+	}
+	if i < len(text) && text[i] == '.' {
+		i++
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0, false
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		i++
+		if i < len(text) && (text[i] == '-' || text[i] == '+') {
+			i++
+		}
+		exponentDigits := 0
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+			exponentDigits++
+		}
+		if exponentDigits == 0 {
+			return 0, false
+		}
+	}
+	if i != len(text) {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	return value, err == nil
+}
+
+func (p *yamlParser) parseQuotedText() string {
+	start := p.pos
+	quote := p.text[p.pos]
+	sb := strings.Builder{}
+	p.pos++
+
+	for {
+		if p.pos >= len(p.text) {
+			p.fail(start, start+1, "Unterminated string literal")
+		}
+
+		switch c := p.text[p.pos]; {
+		case c == quote:
+			// Single-quoted strings escape quotes by doubling them
+			if quote == '\'' && p.peek(1) == '\'' {
+				sb.WriteByte('\'')
+				p.pos += 2
+				continue
+			}
+			p.pos++
+			return sb.String()
+
+		case c == '\\' && quote == '"':
+			p.parseEscape(&sb)
+
+		case c == '\n' || c == '\r':
+			// Line breaks are folded the same way as in plain scalars
+			text := strings.TrimRight(sb.String(), " \t")
+			sb.Reset()
+			sb.WriteString(text)
+			lineBreaks := 0
+			for p.pos < len(p.text) {
+				if c := p.text[p.pos]; c == '\n' {
+					lineBreaks++
+				} else if c != ' ' && c != '\t' && c != '\r' {
+					break
+				}
+				p.pos++
+			}
+			if lineBreaks == 1 {
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteString(strings.Repeat("\n", lineBreaks-1))
+			}
+
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+var yamlEscapes = map[byte]string{
+	'0':  "\x00",
+	'a':  "\a",
+	'b':  "\b",
+	't':  "\t",
+	'\t': "\t",
+	'n':  "\n",
+	'v':  "\v",
+	'f':  "\f",
+	'r':  "\r",
+	'e':  "\x1b",
+	' ':  " ",
+	'"':  "\"",
+	'/':  "/",
+	'\\': "\\",
+	'N':  "\u0085",
+	'_':  "\u00A0",
+	'L':  "\u2028",
+	'P':  "\u2029",
+}
+
+func (p *yamlParser) parseEscape(sb *strings.Builder) {
+	start := p.pos
+	p.pos += 2
+	if start+1 >= len(p.text) {
+		p.fail(start, start+1, "Invalid escape sequence")
+	}
+	c := p.text[start+1]
+
+	// An escaped line break is removed along with the indentation after it
+	if c == '\n' || c == '\r' {
+		for p.pos < len(p.text) && (p.text[p.pos] == ' ' || p.text[p.pos] == '\t' || (c == '\r' && p.text[p.pos] == '\n')) {
+			p.pos++
+		}
+		return
+	}
+
+	if text, ok := yamlEscapes[c]; ok {
+		sb.WriteString(text)
+		return
+	}
+
+	digits := 0
+	switch c {
+	case 'x':
+		digits = 2
+	case 'u':
+		digits = 4
+	case 'U':
+		digits = 8
+	default:
+		p.fail(start, p.pos, "Invalid escape sequence")
+	}
+	if p.pos+digits > len(p.text) {
+		p.fail(start, len(p.text), "Invalid escape sequence")
+	}
+	value, err := strconv.ParseUint(p.text[p.pos:p.pos+digits], 16, 32)
+	if err != nil || value > utf8.MaxRune {
+		p.fail(start, p.pos+digits, "Invalid escape sequence")
+	}
+	sb.WriteRune(rune(value))
+	p.pos += digits
+}
+
+// This parses a literal ("|") or folded (">") block scalar. The header may
+// have a chomping indicator ("-" or "+") and an indentation indicator.
+func (p *yamlParser) parseBlockScalar(parentIndent int) js_ast.Expr {
+	loc := logger.Loc{Start: int32(p.pos)}
+	isFolded := p.text[p.pos] == '>'
+	chomping := byte(0)
+	explicitIndent := 0
+	p.pos++
+	for i := 0; i < 2; i++ {
+		if c := p.peek(0); (c == '-' || c == '+') && chomping == 0 {
+			chomping = c
+			p.pos++
+		} else if c >= '1' && c <= '9' && explicitIndent == 0 {
+			explicitIndent = int(c - '0')
+			p.pos++
+		}
+	}
+	p.skipInlineSpace()
+	if !p.atLineEnd() {
+		p.unexpected()
+	}
+	for p.pos < len(p.text) && p.text[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos < len(p.text) {
+		p.pos++
+	}
+
+	// The indentation of the content is either explicit or is the indentation
+	// of the first line that isn't empty
+	contentIndent := -1
+	if explicitIndent != 0 {
+		contentIndent = explicitIndent
+		if parentIndent > 0 {
+			contentIndent += parentIndent
+		}
+	}
+	var lines []string
+	for p.pos < len(p.text) {
+		lineEnd := strings.IndexByte(p.text[p.pos:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(p.text)
+		} else {
+			lineEnd += p.pos
+		}
+		line := strings.TrimSuffix(p.text[p.pos:lineEnd], "\r")
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		isEmpty := spaces == len(line)
+
+		if !isEmpty {
+			if contentIndent == -1 {
+				if spaces <= parentIndent {
+					break
+				}
+				contentIndent = spaces
+			}
+			if spaces < contentIndent || (spaces == 0 && p.isDocumentMarker(p.pos)) {
+				break
+			}
+		}
+		if contentIndent != -1 && len(line) > contentIndent {
+			lines = append(lines, line[contentIndent:])
+		} else {
+			lines = append(lines, "")
+		}
+
+		p.pos = lineEnd
+		if p.pos < len(p.text) {
+			p.pos++
+		}
+	}
+
+	// Trailing empty lines are handled by the chomping indicator
+	n := len(lines)
+	for n > 0 && lines[n-1] == "" {
+		n--
+	}
+	trailingLines := len(lines) - n
+	var text string
+	if isFolded {
+		text = foldYAMLLines(lines[:n])
+	} else {
+		text = strings.Join(lines[:n], "\n")
+	}
+	switch {
+	case chomping == '+':
+		if n > 0 {
+			text += "\n"
+		}
+		text += strings.Repeat("\n", trailingLines)
+	case chomping != '-' && n > 0:
+		text += "\n"
+	}
+
+	return js_ast.Expr{Loc: loc, Data: &js_ast.EString{Value: js_lexer.StringToUTF16(text)}}
+}
+
+// In folded block scalars, a line break between two lines becomes a space.
+// Empty lines become line breaks, and line breaks next to lines that are
+// indented more than the rest of the content are kept as-is.
+func foldYAMLLines(lines []string) string {
+	sb := strings.Builder{}
+	emptyLines := 0
+	isFirst := true
+	prevIsMoreIndented := false
+
+	for _, line := range lines {
+		if line == "" {
+			emptyLines++
+			continue
+		}
+		isMoreIndented := line[0] == ' ' || line[0] == '\t'
+		if isFirst {
+			sb.WriteString(strings.Repeat("\n", emptyLines))
+		} else if isMoreIndented || prevIsMoreIndented {
+			sb.WriteString(strings.Repeat("\n", emptyLines+1))
+		} else if emptyLines > 0 {
+			sb.WriteString(strings.Repeat("\n", emptyLines))
+		} else {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(line)
+		emptyLines = 0
+		isFirst = false
+		prevIsMoreIndented = isMoreIndented
+	}
+
+	return sb.String()
+}
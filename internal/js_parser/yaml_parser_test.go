@@ -0,0 +1,194 @@
+package js_parser
+
+import (
+	"testing"
+
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_printer"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func expectParseErrorYAML(t *testing.T, contents string, expected string) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		ParseYAML(log, test.SourceForTest(contents))
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqualWithDiff(t, text, expected)
+	})
+}
+
+func expectPrintedYAML(t *testing.T, contents string, expected string) {
+	t.Helper()
+	expectPrintedYAMLWithWarning(t, contents, "", expected)
+}
+
+func expectPrintedYAMLWithWarning(t *testing.T, contents string, warning string, expected string) {
+	t.Helper()
+	t.Run(contents, func(t *testing.T) {
+		t.Helper()
+		log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+		expr, ok := ParseYAML(log, test.SourceForTest(contents))
+		msgs := log.Done()
+		text := ""
+		for _, msg := range msgs {
+			text += msg.String(logger.OutputOptions{}, logger.TerminalInfo{})
+		}
+		test.AssertEqualWithDiff(t, text, warning)
+		if !ok {
+			t.Fatal("Parse error")
+		}
+
+		// Insert this expression into a statement
+		tree := js_ast.AST{
+			Parts: []js_ast.Part{{Stmts: []js_ast.Stmt{{Data: &js_ast.SExpr{Value: expr}}}}},
+		}
+
+		js := js_printer.Print(tree, js_ast.SymbolMap{}, nil, js_printer.Options{
+			RemoveWhitespace: true,
+		}).JS
+
+		// Remove the trailing semicolon
+		if n := len(js); n > 1 && js[n-1] == ';' {
+			js = js[:n-1]
+		}
+
+		test.AssertEqualWithDiff(t, string(js), expected)
+	})
+}
+
+func TestYAMLScalar(t *testing.T) {
+	expectPrintedYAML(t, "", "null")
+	expectPrintedYAML(t, "~", "null")
+	expectPrintedYAML(t, "null", "null")
+	expectPrintedYAML(t, "NULL", "null")
+	expectPrintedYAML(t, "true", "true")
+	expectPrintedYAML(t, "False", "false")
+	expectPrintedYAML(t, "yes", "\"yes\"")
+	expectPrintedYAML(t, "no", "\"no\"")
+
+	expectPrintedYAML(t, "123", "123")
+	expectPrintedYAML(t, "-1.5", "-1.5")
+	expectPrintedYAML(t, "+12e3", "12e3")
+	expectPrintedYAML(t, ".5", ".5")
+	expectPrintedYAML(t, "0x1F", "31")
+	expectPrintedYAML(t, "0o17", "15")
+	expectPrintedYAML(t, ".inf", "Infinity")
+	expectPrintedYAML(t, "-.Inf", "-Infinity")
+	expectPrintedYAML(t, ".nan", "NaN")
+	expectPrintedYAML(t, "1.2.3", "\"1.2.3\"")
+	expectPrintedYAML(t, "1e", "\"1e\"")
+	expectPrintedYAML(t, "0x", "\"0x\"")
+
+	expectPrintedYAML(t, "hello world", "\"hello world\"")
+	expectPrintedYAML(t, "a:b", "\"a:b\"")
+	expectPrintedYAML(t, "a#b # comment", "\"a#b\"")
+	expectPrintedYAML(t, "http://example.com", "\"http://example.com\"")
+	expectPrintedYAML(t, "one\n  two\n\n  three", "\"one two\\nthree\"")
+	expectParseErrorYAML(t, "@x", "<stdin>: ERROR: Unexpected \"@x\"\n")
+	expectParseErrorYAML(t, "a\nb: c", "<stdin>: ERROR: Mapping values are not allowed here\n")
+}
+
+func TestYAMLQuoted(t *testing.T) {
+	expectPrintedYAML(t, "'it''s'", "\"it's\"")
+	expectPrintedYAML(t, "'\\n'", "\"\\\\n\"")
+	expectPrintedYAML(t, "\"a\\tb\\n\"", "\"a\tb\\n\"")
+	expectPrintedYAML(t, "\"\\x41\\u00e9\\U0001F600\"", "\"A\u00e9\U0001F600\"")
+	expectPrintedYAML(t, "\"\\L\"", "\"\\u2028\"")
+	expectPrintedYAML(t, "\"a\n  b\n\n  c\"", "\"a b\\nc\"")
+	expectPrintedYAML(t, "\"a\\\n  b\"", "\"ab\"")
+	expectPrintedYAML(t, "'true'", "\"true\"")
+	expectPrintedYAML(t, "\"123\"", "\"123\"")
+	expectParseErrorYAML(t, "\"abc", "<stdin>: ERROR: Unterminated string literal\n")
+	expectParseErrorYAML(t, "\"\\q\"", "<stdin>: ERROR: Invalid escape sequence\n")
+	expectParseErrorYAML(t, "\"\\u12\"", "<stdin>: ERROR: Invalid escape sequence\n")
+	expectParseErrorYAML(t, "'a' b", "<stdin>: ERROR: Unexpected \"b\"\n")
+}
+
+func TestYAMLBlockScalar(t *testing.T) {
+	expectPrintedYAML(t, "|\n  a\n  b\n", "\"a\\nb\\n\"")
+	expectPrintedYAML(t, "|-\n  a\n  b\n\n", "\"a\\nb\"")
+	expectPrintedYAML(t, "|+\n  a\n  b\n\n", "\"a\\nb\\n\\n\"")
+	expectPrintedYAML(t, "|\n  a\n    b\n", "\"a\\n  b\\n\"")
+	expectPrintedYAML(t, "|2\n    a\n  b\n", "\"  a\\nb\\n\"")
+	expectPrintedYAML(t, ">\n  a\n  b\n\n  c\n", "\"a b\\nc\\n\"")
+	expectPrintedYAML(t, ">\n  a\n    b\n  c\n", "\"a\\n  b\\nc\\n\"")
+	expectPrintedYAML(t, "x: |\n  a\ny: 1", "({x:\"a\\n\",y:1})")
+	expectPrintedYAML(t, "x: >-\n  a\n  b\n", "({x:\"a b\"})")
+	expectParseErrorYAML(t, "| x\n  a", "<stdin>: ERROR: Unexpected \"x\"\n")
+}
+
+func TestYAMLBlockMapping(t *testing.T) {
+	expectPrintedYAML(t, "a: 1", "({a:1})")
+	expectPrintedYAML(t, "a: 1\nb: two\n", "({a:1,b:\"two\"})")
+	expectPrintedYAML(t, "a:\nb:", "({a:null,b:null})")
+	expectPrintedYAML(t, "a:\n  b:\n    c: 1\n  d: 2\ne: 3", "({a:{b:{c:1},d:2},e:3})")
+	expectPrintedYAML(t, "\"a b\": 1\n'c': 2", "({\"a b\":1,c:2})")
+	expectPrintedYAML(t, "a b: 1", "({\"a b\":1})")
+	expectPrintedYAML(t, "1: one", "({\"1\":\"one\"})")
+	expectPrintedYAML(t, "# comment\na: 1 # comment\n\n# comment\nb: 2", "({a:1,b:2})")
+	expectParseErrorYAML(t, "a: b: c", "<stdin>: ERROR: Mapping values are not allowed here\n")
+	expectPrintedYAMLWithWarning(t, "a: 1\na: 2",
+		"<stdin>: WARNING: Duplicate key \"a\" in mapping\n<stdin>: NOTE: The original key \"a\" is here:\n",
+		"({a:1,a:2})")
+	expectParseErrorYAML(t, "a: 'x'\n  b: 2", "<stdin>: ERROR: Unexpected indentation\n")
+	expectParseErrorYAML(t, "a:\n\tb: 1", "<stdin>: ERROR: Tabs are not allowed in YAML indentation\n")
+	expectParseErrorYAML(t, "a: - b", "<stdin>: ERROR: Block sequences are not allowed here\n")
+	expectParseErrorYAML(t, "? a\n: b", "<stdin>: ERROR: Complex mapping keys are not supported\n")
+}
+
+func TestYAMLBlockSequence(t *testing.T) {
+	expectPrintedYAML(t, "- 1\n- two\n-\n- true", "[1,\"two\",null,true]")
+	expectPrintedYAML(t, "- - 1\n  - 2\n- 3", "[[1,2],3]")
+	expectPrintedYAML(t, "- a: 1\n  b: 2\n- c: 3", "[{a:1,b:2},{c:3}]")
+	expectPrintedYAML(t, "a:\n- 1\n- 2\nb: 3", "({a:[1,2],b:3})")
+	expectPrintedYAML(t, "a:\n  - 1\n  - 2", "({a:[1,2]})")
+	expectPrintedYAML(t, "-1", "-1")
+	expectPrintedYAML(t, "-x", "\"-x\"")
+	expectPrintedYAML(t, "- 1\n  - 2", "[\"1 - 2\"]")
+	expectParseErrorYAML(t, "- [1]\n  - 2", "<stdin>: ERROR: Unexpected indentation\n")
+}
+
+func TestYAMLFlowCollection(t *testing.T) {
+	expectPrintedYAML(t, "[]", "[]")
+	expectPrintedYAML(t, "{}", "({})")
+	expectPrintedYAML(t, "[1, two, 'three', [4]]", "[1,\"two\",\"three\",[4]]")
+	expectPrintedYAML(t, "[1, 2,]", "[1,2]")
+	expectPrintedYAML(t, "{a: 1, b: [x, y], c}", "({a:1,b:[\"x\",\"y\"],c:null})")
+	expectPrintedYAML(t, "{\"a\":1,b: {c: d}}", "({a:1,b:{c:\"d\"}})")
+	expectPrintedYAML(t, "x: [\n  1,\n  2\n]", "({x:[1,2]})")
+	expectPrintedYAML(t, "x: {a: 1} # comment", "({x:{a:1}})")
+	expectParseErrorYAML(t, "[1, 2", "<stdin>: ERROR: Expected \",\" or \"]\" but found end of file\n")
+	expectParseErrorYAML(t, "[a: 1]", "<stdin>: ERROR: Expected \",\" or \"]\" but found \":\"\n")
+	expectParseErrorYAML(t, "{a: 1", "<stdin>: ERROR: Expected \",\" or \"}\" but found end of file\n")
+	expectParseErrorYAML(t, "{[a]: 1}", "<stdin>: ERROR: Complex mapping keys are not supported\n")
+	expectParseErrorYAML(t, "[1] x", "<stdin>: ERROR: Unexpected \"x\"\n")
+}
+
+func TestYAMLAnchorsAndAliases(t *testing.T) {
+	expectPrintedYAML(t, "a: &x 1\nb: *x", "({a:1,b:1})")
+	expectPrintedYAML(t, "a: &x\n  b: 1\nc: *x", "({a:{b:1},c:{b:1}})")
+	expectPrintedYAML(t, "- &x [1, 2]\n- *x", "[[1,2],[1,2]]")
+	expectPrintedYAML(t, "base: &base\n  a: 1\n  b: 2\nx:\n  <<: *base\n  b: 3", "({base:{a:1,b:2},x:{a:1,b:3}})")
+	expectPrintedYAML(t, "a: &a {x: 1}\nb: &b {x: 2, y: 2}\nc:\n  <<: [*a, *b]", "({a:{x:1},b:{x:2,y:2},c:{x:1,y:2}})")
+	expectPrintedYAML(t, "'<<': 1", "({\"<<\":1})")
+	expectParseErrorYAML(t, "a: *x", "<stdin>: ERROR: The anchor \"x\" has not been defined\n")
+	expectParseErrorYAML(t, "a: &\n", "<stdin>: ERROR: Expected an anchor name\n")
+	expectParseErrorYAML(t, "<<: 1", "<stdin>: ERROR: The value of a \"<<\" merge key must be a mapping or a sequence of mappings\n")
+}
+
+func TestYAMLDocument(t *testing.T) {
+	expectPrintedYAML(t, "---\na: 1", "({a:1})")
+	expectPrintedYAML(t, "%YAML 1.2\n---\na: 1\n...\n", "({a:1})")
+	expectPrintedYAML(t, "--- 1", "1")
+	expectPrintedYAML(t, "---\n", "null")
+	expectParseErrorYAML(t, "a: 1\n---\nb: 2", "<stdin>: ERROR: Multiple documents in one YAML file are not supported\n")
+	expectParseErrorYAML(t, "!!str 1", "<stdin>: ERROR: YAML tags are not supported\n")
+	expectParseErrorYAML(t, "a: !foo 1", "<stdin>: ERROR: YAML tags are not supported\n")
+}
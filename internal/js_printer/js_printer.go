@@ -289,7 +289,7 @@ func (p *printer) printUnquotedUTF16(text []uint16, quote rune) {
 						i++
 
 						// Escape this character if UTF-8 isn't allowed
-						if p.options.ASCIIOnly {
+						if p.mustEscape(r) {
 							if !p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) {
 								js = append(js, fmt.Sprintf("\\u{%X}", r)...)
 							} else {
@@ -312,11 +312,11 @@ func (p *printer) printUnquotedUTF16(text []uint16, quote rune) {
 				js = append(js, '\\', 'u', hexChars[c>>12], hexChars[(c>>8)&15], hexChars[(c>>4)&15], hexChars[c&15])
 
 			// Is this an unpaired low surrogate or four-digit hex escape?
-			case (c >= firstLowSurrogate && c <= lastLowSurrogate) || (p.options.ASCIIOnly && c > 0xFF):
+			case (c >= firstLowSurrogate && c <= lastLowSurrogate) || (c > 0xFF && p.mustEscape(rune(c))):
 				js = append(js, '\\', 'u', hexChars[c>>12], hexChars[(c>>8)&15], hexChars[(c>>4)&15], hexChars[c&15])
 
 			// Can this be a two-digit hex escape?
-			case p.options.ASCIIOnly:
+			case p.mustEscape(rune(c)):
 				js = append(js, '\\', 'x', hexChars[c>>4], hexChars[c&15])
 
 			// Otherwise, just encode to UTF-8
@@ -338,14 +338,14 @@ func (p *printer) canPrintTextAsJSXAttribute(text []uint16) (quote string, ok bo
 	single := true
 	double := true
 
-	for _, c := range text {
+	for i, c := range text {
 		// Use JS strings for control characters
 		if c < firstASCII {
 			return "", false
 		}
 
 		// Use JS strings if we need to escape non-ASCII characters
-		if p.options.ASCIIOnly && c > lastASCII {
+		if c > lastASCII && p.mustEscapeUTF16(text, i) {
 			return "", false
 		}
 
@@ -383,14 +383,14 @@ func (p *printer) canPrintTextAsJSXAttribute(text []uint16) (quote string, ok bo
 // character escape sequences, so JSX implementations may not be able to
 // consume them. See https://facebook.github.io/jsx/ for the specification.
 func (p *printer) canPrintTextAsJSXChild(text []uint16) bool {
-	for _, c := range text {
+	for i, c := range text {
 		// Use JS strings for control characters
 		if c < firstASCII {
 			return false
 		}
 
 		// Use JS strings if we need to escape non-ASCII characters
-		if p.options.ASCIIOnly && c > lastASCII {
+		if c > lastASCII && p.mustEscapeUTF16(text, i) {
 			return false
 		}
 
@@ -435,6 +435,7 @@ type printer struct {
 	renamer                renamer.Renamer
 	importRecords          []ast.ImportRecord
 	options                Options
+	sourceIndex            uint32
 	extractedLegalComments map[string]bool
 	needsSemicolon         bool
 	js                     []byte
@@ -473,8 +474,12 @@ func (p *printer) addSourceMapping(loc logger.Loc) {
 
 func (p *printer) printIndent() {
 	if !p.options.RemoveWhitespace {
+		indentUnit := p.options.IndentUnit
+		if indentUnit == "" {
+			indentUnit = "  "
+		}
 		for i := 0; i < p.options.Indent; i++ {
-			p.print("  ")
+			p.print(indentUnit)
 		}
 	}
 }
@@ -517,23 +522,76 @@ func CanEscapeIdentifier(name string, unsupportedJSFeatures compat.JSFeature, as
 }
 
 func (p *printer) canPrintIdentifier(name string) bool {
-	return js_lexer.IsIdentifierES5AndESNext(name) && (!p.options.ASCIIOnly ||
-		!p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) ||
-		!js_lexer.ContainsNonBMPCodePoint(name))
+	return js_lexer.IsIdentifierES5AndESNext(name) && (!p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) ||
+		!js_lexer.ContainsNonBMPCodePoint(name) || !p.identifierNeedsNonBMPEscape(name))
 }
 
 func (p *printer) canPrintIdentifierUTF16(name []uint16) bool {
-	return js_lexer.IsIdentifierES5AndESNextUTF16(name) && (!p.options.ASCIIOnly ||
-		!p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) ||
-		!js_lexer.ContainsNonBMPCodePointUTF16(name))
+	return js_lexer.IsIdentifierES5AndESNextUTF16(name) && (!p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) ||
+		!js_lexer.ContainsNonBMPCodePointUTF16(name) || !p.identifierNeedsNonBMPEscape(js_lexer.UTF16ToString(name)))
+}
+
+// Returns true if this code point must be escaped inside a string
+func (p *printer) mustEscape(c rune) bool {
+	return (p.options.ASCIIOnly && c > lastASCII) || p.options.CharsetEscapes.ShouldEscape(c)
+}
+
+// This is the same as "mustEscape" but for the UTF-16 code unit at index "i".
+// Both halves of a surrogate pair are checked using the whole code point since
+// it may belong to one of the scripts to escape. Unpaired surrogates can't be
+// encoded as UTF-8 and must always be escaped.
+func (p *printer) mustEscapeUTF16(text []uint16, i int) bool {
+	c := text[i]
+	switch {
+	case c >= firstHighSurrogate && c <= lastHighSurrogate:
+		if i+1 < len(text) {
+			if c2 := text[i+1]; c2 >= firstLowSurrogate && c2 <= lastLowSurrogate {
+				return p.mustEscape((rune(c) << 10) + rune(c2) + (0x10000 - (firstHighSurrogate << 10) - firstLowSurrogate))
+			}
+		}
+		return true
+
+	case c >= firstLowSurrogate && c <= lastLowSurrogate:
+		if i > 0 {
+			if c1 := text[i-1]; c1 >= firstHighSurrogate && c1 <= lastHighSurrogate {
+				return p.mustEscape((rune(c1) << 10) + rune(c) + (0x10000 - (firstHighSurrogate << 10) - firstLowSurrogate))
+			}
+		}
+		return true
+	}
+	return p.mustEscape(rune(c))
+}
+
+// Returns true if this code point must be escaped inside an identifier
+func (p *printer) mustEscapeInIdentifier(c rune) bool {
+	return p.options.IdentifierCharset.MustEscape(c, p.options.ASCIIOnly, p.options.CharsetEscapes)
+}
+
+// Non-BMP code points can only be escaped in identifiers using "\u{...}"
+func (p *printer) identifierNeedsNonBMPEscape(name string) bool {
+	for _, c := range name {
+		if c > 0xFFFF && p.mustEscapeInIdentifier(c) {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *printer) printIdentifier(name string) {
-	if p.options.ASCIIOnly {
-		p.js = QuoteIdentifier(p.js, name, p.options.UnsupportedFeatures)
-	} else {
+	if !p.options.ASCIIOnly && p.options.CharsetEscapes.IsEmpty() && p.options.IdentifierCharset != config.IdentifierCharsetASCII {
 		p.print(name)
+		return
+	}
+
+	start := 0
+	for i, c := range name {
+		if p.mustEscapeInIdentifier(c) {
+			p.js = append(p.js, name[start:i]...)
+			p.printEscapedIdentifierCodePoint(c)
+			start = i + utf8.RuneLen(c)
+		}
 	}
+	p.js = append(p.js, name[start:]...)
 }
 
 // This is the same as "printIdentifier(StringToUTF16(bytes))" without any
@@ -552,14 +610,8 @@ func (p *printer) printIdentifierUTF16(name []uint16) {
 			}
 		}
 
-		if p.options.ASCIIOnly && c > lastASCII {
-			if c <= 0xFFFF {
-				p.js = append(p.js, '\\', 'u', hexChars[c>>12], hexChars[(c>>8)&15], hexChars[(c>>4)&15], hexChars[c&15])
-			} else if !p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) {
-				p.js = append(p.js, fmt.Sprintf("\\u{%X}", c)...)
-			} else {
-				panic("Internal error: Cannot encode identifier: Unicode escapes are unsupported")
-			}
+		if p.mustEscapeInIdentifier(c) {
+			p.printEscapedIdentifierCodePoint(c)
 			continue
 		}
 
@@ -568,6 +620,16 @@ func (p *printer) printIdentifierUTF16(name []uint16) {
 	}
 }
 
+func (p *printer) printEscapedIdentifierCodePoint(c rune) {
+	if c <= 0xFFFF {
+		p.js = append(p.js, '\\', 'u', hexChars[c>>12], hexChars[(c>>8)&15], hexChars[(c>>4)&15], hexChars[c&15])
+	} else if !p.options.UnsupportedFeatures.Has(compat.UnicodeEscapes) {
+		p.js = append(p.js, fmt.Sprintf("\\u{%X}", c)...)
+	} else {
+		panic("Internal error: Cannot encode identifier: Unicode escapes are unsupported")
+	}
+}
+
 func (p *printer) printBinding(binding js_ast.Binding) {
 	p.addSourceMapping(binding.Loc)
 
@@ -673,6 +735,8 @@ func (p *printer) printBinding(binding js_ast.Binding) {
 							}
 							continue
 						}
+					} else if mangled, ok := property.Key.Data.(*js_ast.EMangledProp); ok {
+						p.printMangledPropKey(property.Key.Loc, mangled.Name)
 					} else {
 						p.printExpr(property.Key, js_ast.LLowest, 0)
 					}
@@ -803,6 +867,21 @@ func (p *printer) printFn(fn js_ast.Fn) {
 	p.printBlock(fn.Body.Loc, fn.Body.Stmts)
 }
 
+func (p *printer) withoutRemovedMembers(properties []js_ast.Property) []js_ast.Property {
+	for i := range properties {
+		if p.options.RemovedMembers[&properties[i]] {
+			kept := append([]js_ast.Property{}, properties[:i]...)
+			for j := i + 1; j < len(properties); j++ {
+				if !p.options.RemovedMembers[&properties[j]] {
+					kept = append(kept, properties[j])
+				}
+			}
+			return kept
+		}
+	}
+	return properties
+}
+
 func (p *printer) printClass(class js_ast.Class) {
 	if class.ExtendsOrNil.Data != nil {
 		p.print(" extends")
@@ -816,7 +895,10 @@ func (p *printer) printClass(class js_ast.Class) {
 	p.printNewline()
 	p.options.Indent++
 
-	for _, item := range class.Properties {
+	for i, item := range class.Properties {
+		if p.options.RemovedMembers[&class.Properties[i]] {
+			continue
+		}
 		p.printSemicolonIfNeeded()
 		p.printIndent()
 
@@ -908,6 +990,9 @@ func (p *printer) printProperty(item js_ast.Property) {
 	case *js_ast.EPrivateIdentifier:
 		p.printSymbol(key.Ref)
 
+	case *js_ast.EMangledProp:
+		p.printMangledPropKey(item.Key.Loc, key.Name)
+
 	case *js_ast.EString:
 		p.addSourceMapping(item.Key.Loc)
 		if !item.PreferQuotedKey && p.canPrintIdentifierUTF16(key.Value) {
@@ -918,7 +1003,8 @@ func (p *printer) printProperty(item js_ast.Property) {
 			if !p.options.UnsupportedFeatures.Has(compat.ObjectExtensions) && item.ValueOrNil.Data != nil {
 				switch e := item.ValueOrNil.Data.(type) {
 				case *js_ast.EIdentifier:
-					if js_lexer.UTF16EqualsString(key.Value, p.renamer.NameForSymbol(e.Ref)) {
+					if js_lexer.UTF16EqualsString(key.Value, p.renamer.NameForSymbol(e.Ref)) &&
+						p.lazyInitRefForSymbol(js_ast.FollowSymbols(p.symbols, e.Ref)) == js_ast.InvalidRef {
 						if item.InitializerOrNil.Data != nil {
 							p.printSpace()
 							p.print("=")
@@ -932,7 +1018,8 @@ func (p *printer) printProperty(item js_ast.Property) {
 					// Make sure we're not using a property access instead of an identifier
 					ref := js_ast.FollowSymbols(p.symbols, e.Ref)
 					symbol := p.symbols.Get(ref)
-					if symbol.NamespaceAlias == nil && js_lexer.UTF16EqualsString(key.Value, p.renamer.NameForSymbol(e.Ref)) {
+					if symbol.NamespaceAlias == nil && p.lazyInitRefForSymbol(ref) == js_ast.InvalidRef &&
+						js_lexer.UTF16EqualsString(key.Value, p.renamer.NameForSymbol(e.Ref)) {
 						if item.InitializerOrNil.Data != nil {
 							p.printSpace()
 							p.print("=")
@@ -978,6 +1065,35 @@ func (p *printer) printProperty(item js_ast.Property) {
 	}
 }
 
+func (p *printer) mangledPropName(name string) string {
+	if newName, ok := p.options.MangledProps[name]; ok {
+		return newName
+	}
+	return name
+}
+
+func (p *printer) printMangledPropKey(loc logger.Loc, name string) {
+	p.addSourceMapping(loc)
+	if name := p.mangledPropName(name); p.canPrintIdentifier(name) {
+		p.printSpaceBeforeIdentifier()
+		p.printIdentifier(name)
+	} else {
+		p.printQuotedUTF8(name, false /* allowBacktick */)
+	}
+}
+
+// Returns the "init_*" wrapper function for the file containing this symbol
+// if that file must be initialized when the symbol is used. This is never
+// needed for symbols in the file being printed.
+func (p *printer) lazyInitRefForSymbol(ref js_ast.Ref) js_ast.Ref {
+	if p.options.RequireOrImportMetaForSource != nil && ref.SourceIndex != p.sourceIndex {
+		if meta := p.options.RequireOrImportMetaForSource(ref.SourceIndex); meta.IsLazy && ref != meta.WrapperRef {
+			return meta.WrapperRef
+		}
+	}
+	return js_ast.InvalidRef
+}
+
 func (p *printer) printQuotedUTF16(data []uint16, allowBacktick bool) {
 	if p.options.UnsupportedFeatures.Has(compat.TemplateLiteral) {
 		allowBacktick = false
@@ -1024,6 +1140,12 @@ func (p *printer) printQuotedUTF16(data []uint16, allowBacktick bool) {
 	p.print(c)
 }
 
+func (p *printer) printLoadScriptModuleType() {
+	p.print(",")
+	p.printSpace()
+	p.printQuotedUTF8("module", false /* allowBacktick */)
+}
+
 func (p *printer) printRequireOrImportExpr(
 	importRecordIndex uint32,
 	leadingInteriorComments []js_ast.Comment,
@@ -1047,6 +1169,13 @@ func (p *printer) printRequireOrImportExpr(
 				defer p.print(")")
 			}
 
+			// External globals are read from the global variable directly
+			if record.IsExternalGlobal {
+				p.addSourceMapping(record.Range.Loc)
+				p.printExternalGlobal(record.Path.Text)
+				return
+			}
+
 			// Potentially substitute our own "__require" stub for "require"
 			if record.CallRuntimeRequire {
 				p.printSymbol(p.options.RuntimeRequireRef)
@@ -1062,11 +1191,37 @@ func (p *printer) printRequireOrImportExpr(
 			return
 		}
 
+		// External "import()" of a global variable
+		if record.IsExternalGlobal {
+			p.printSpaceBeforeIdentifier()
+			p.print("Promise.resolve()")
+			p.printDotThenPrefix()
+			defer p.printDotThenSuffix()
+			if record.WrapWithToModule {
+				p.printSymbol(p.options.ToModuleRef)
+				p.print("(")
+				defer p.print(")")
+			}
+			p.addSourceMapping(record.Range.Loc)
+			p.printExternalGlobal(record.Path.Text)
+			return
+		}
+
 		// External "import()"
-		if !p.options.UnsupportedFeatures.Has(compat.DynamicImport) {
+		useImportCall := !p.options.UnsupportedFeatures.Has(compat.DynamicImport) && !record.CallRuntimeLoadScript && !record.CallRequireInsteadOfImport
+		if useImportCall {
 			p.printSpaceBeforeIdentifier()
 			p.print("import(")
 			defer p.print(")")
+		} else if record.CallRuntimeLoadScript {
+			p.printSymbol(p.options.LoadScriptRef)
+			p.print("(")
+			defer p.print(")")
+
+			// Module scripts must be loaded with the "module" script type
+			if p.options.LoadScriptAsModule {
+				defer p.printLoadScriptModuleType()
+			}
 		} else {
 			p.printSpaceBeforeIdentifier()
 			p.print("Promise.resolve()")
@@ -1101,7 +1256,7 @@ func (p *printer) printRequireOrImportExpr(
 		}
 		p.addSourceMapping(record.Range.Loc)
 		p.printQuotedUTF8(record.Path.Text, true /* allowBacktick */)
-		if !p.options.UnsupportedFeatures.Has(compat.DynamicImport) {
+		if useImportCall {
 			p.printImportCallAssertions(record.Assertions)
 		}
 		if len(leadingInteriorComments) > 0 {
@@ -1164,6 +1319,23 @@ func (p *printer) printRequireOrImportExpr(
 	}
 }
 
+// This prints a global name such as "window.React" that was parsed into parts
+func (p *printer) printExternalGlobal(path string) {
+	parts := p.options.ExternalGlobals[path]
+	p.printSpaceBeforeIdentifier()
+	p.printIdentifier(parts[0])
+	for _, part := range parts[1:] {
+		if p.canPrintIdentifier(part) {
+			p.print(".")
+			p.printIdentifier(part)
+		} else {
+			p.print("[")
+			p.printQuotedUTF8(part, false /* allowBacktick */)
+			p.print("]")
+		}
+	}
+}
+
 func (p *printer) printDotThenPrefix() js_ast.L {
 	if p.options.UnsupportedFeatures.Has(compat.Arrow) {
 		p.print(".then(function()")
@@ -1352,7 +1524,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 	case *js_ast.ENew:
 		wrap := level >= js_ast.LCall
 
-		hasPureComment := !p.options.RemoveWhitespace && e.CanBeUnwrappedIfUnused
+		hasPureComment := e.CanBeUnwrappedIfUnused && p.shouldPrintComments(p.options.Annotations)
 		if hasPureComment && level >= js_ast.LPostfix {
 			wrap = true
 		}
@@ -1362,7 +1534,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 		}
 
 		if hasPureComment {
-			p.print("/* @__PURE__ */ ")
+			p.printPureComment()
 		}
 
 		p.printSpaceBeforeIdentifier()
@@ -1396,7 +1568,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 			wrap = true
 		}
 
-		hasPureComment := !p.options.RemoveWhitespace && e.CanBeUnwrappedIfUnused
+		hasPureComment := e.CanBeUnwrappedIfUnused && p.shouldPrintComments(p.options.Annotations)
 		if hasPureComment && level >= js_ast.LPostfix {
 			wrap = true
 		}
@@ -1407,7 +1579,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 
 		if hasPureComment {
 			wasStmtStart := p.stmtStart == len(p.js)
-			p.print("/* @__PURE__ */ ")
+			p.printPureComment()
 			if wasStmtStart {
 				p.stmtStart = len(p.js)
 			}
@@ -1461,22 +1633,28 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 
 	case *js_ast.EImportString:
 		var leadingInteriorComments []js_ast.Comment
-		if !p.options.RemoveWhitespace {
+		if p.shouldPrintComments(p.options.MagicComments) {
 			leadingInteriorComments = e.LeadingInteriorComments
 		}
 		p.printRequireOrImportExpr(e.ImportRecordIndex, leadingInteriorComments, level, flags)
 
 	case *js_ast.EImportCall:
 		var leadingInteriorComments []js_ast.Comment
-		if !p.options.RemoveWhitespace {
+		if p.shouldPrintComments(p.options.MagicComments) {
 			leadingInteriorComments = e.LeadingInteriorComments
 		}
 		wrap := level >= js_ast.LNew || (flags&forbidCall) != 0
 		if wrap {
 			p.print("(")
 		}
-		p.printSpaceBeforeIdentifier()
-		p.print("import(")
+		if p.options.CallRuntimeLoadScript {
+			// The parser left this for us to convert into "__loadScript(foo)"
+			p.printSymbol(p.options.LoadScriptRef)
+			p.print("(")
+		} else {
+			p.printSpaceBeforeIdentifier()
+			p.print("import(")
+		}
 		if len(leadingInteriorComments) > 0 {
 			p.printNewline()
 			p.options.Indent++
@@ -1486,7 +1664,11 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 			p.printIndent()
 		}
 		p.printExpr(e.Expr, js_ast.LComma, 0)
-		if e.OptionsOrNil.Data != nil {
+		if p.options.CallRuntimeLoadScript {
+			if p.options.LoadScriptAsModule {
+				p.printLoadScriptModuleType()
+			}
+		} else if e.OptionsOrNil.Data != nil {
 			p.print(",")
 			p.printSpace()
 			p.printExpr(e.OptionsOrNil, js_ast.LComma, 0)
@@ -1558,6 +1740,16 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 				p.print(".")
 			}
 			p.printSymbol(private.Ref)
+		} else if mangled, ok := e.Index.Data.(*js_ast.EMangledProp); ok && p.canPrintIdentifier(p.mangledPropName(mangled.Name)) {
+			if e.OptionalChain != js_ast.OptionalChainStart {
+				if p.prevNumEnd == len(p.js) {
+					// "1.toString" is a syntax error, so print "1 .toString" instead
+					p.print(" ")
+				}
+				p.print(".")
+			}
+			p.addSourceMapping(e.Index.Loc)
+			p.printIdentifier(p.mangledPropName(mangled.Name))
 		} else {
 			p.print("[")
 			p.printExpr(e.Index, js_ast.LLowest, 0)
@@ -1699,12 +1891,16 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 			p.print("(")
 		}
 		p.print("{")
-		if len(e.Properties) != 0 {
+		properties := e.Properties
+		if p.options.RemovedMembers != nil {
+			properties = p.withoutRemovedMembers(properties)
+		}
+		if len(properties) != 0 {
 			if !e.IsSingleLine {
 				p.options.Indent++
 			}
 
-			for i, item := range e.Properties {
+			for i, item := range properties {
 				if i != 0 {
 					p.print(",")
 				}
@@ -1721,7 +1917,7 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 				p.options.Indent--
 				p.printNewline()
 				p.printIndent()
-			} else if len(e.Properties) > 0 {
+			} else if len(properties) > 0 {
 				p.printSpace()
 			}
 		}
@@ -1765,6 +1961,9 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 
 		p.printQuotedUTF16(e.Value, true /* allowBacktick */)
 
+	case *js_ast.EMangledProp:
+		p.printQuotedUTF8(p.mangledPropName(e.Name), true /* allowBacktick */)
+
 	case *js_ast.ETemplate:
 		// Convert no-substitution template literals into strings if it's smaller
 		if p.options.MangleSyntax && e.TagOrNil.Data == nil && len(e.Parts) == 0 {
@@ -1888,6 +2087,17 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 		wrap := len(p.js) == p.forOfInitStart && (name == "let" ||
 			(wasFollowedByOf && (flags&isInsideForAwait) == 0 && name == "async"))
 
+		// "(init_foo(), foo_exports)"
+		if lazyInitRef := p.lazyInitRefForSymbol(js_ast.FollowSymbols(p.symbols, e.Ref)); lazyInitRef != js_ast.InvalidRef {
+			p.print("(")
+			p.printSymbol(lazyInitRef)
+			p.print("(),")
+			p.printSpace()
+			p.printIdentifier(name)
+			p.print(")")
+			break
+		}
+
 		if wrap {
 			p.print("(")
 		}
@@ -1904,6 +2114,15 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 		ref := js_ast.FollowSymbols(p.symbols, e.Ref)
 		symbol := p.symbols.Get(ref)
 
+		// "(init_foo(), foo)"
+		lazyInitRef := p.lazyInitRefForSymbol(ref)
+		if lazyInitRef != js_ast.InvalidRef {
+			p.print("(")
+			p.printSymbol(lazyInitRef)
+			p.print("(),")
+			p.printSpace()
+		}
+
 		if symbol.ImportItemStatus == js_ast.ImportItemMissing {
 			p.printUndefined(level)
 		} else if symbol.NamespaceAlias != nil {
@@ -1932,6 +2151,10 @@ func (p *printer) printExpr(expr js_ast.Expr, level js_ast.L, flags printExprFla
 			p.printSymbol(e.Ref)
 		}
 
+		if lazyInitRef != js_ast.InvalidRef {
+			p.print(")")
+		}
+
 	case *js_ast.EAwait:
 		wrap := level >= js_ast.LPrefix
 
@@ -2439,6 +2662,26 @@ func (p *printer) printIf(s *js_ast.SIf) {
 	}
 }
 
+// Comments meant for other tools are omitted when minifying by default
+func (p *printer) shouldPrintComments(mode config.Annotations) bool {
+	switch mode {
+	case config.AnnotationsAlways:
+		return true
+	case config.AnnotationsNever:
+		return false
+	default:
+		return !p.options.RemoveWhitespace
+	}
+}
+
+func (p *printer) printPureComment() {
+	if p.options.RemoveWhitespace {
+		p.print("/* @__PURE__ */")
+	} else {
+		p.print("/* @__PURE__ */ ")
+	}
+}
+
 func (p *printer) printIndentedComment(text string) {
 	// Avoid generating a comment containing the character sequence "</script"
 	text = helpers.EscapeClosingTag(text, "/script")
@@ -3098,14 +3341,35 @@ type Options struct {
 	RemoveWhitespace             bool
 	MangleSyntax                 bool
 	ASCIIOnly                    bool
+	CharsetEscapes               config.CharsetEscapes
+	IdentifierCharset            config.IdentifierCharset
 	LegalComments                config.LegalComments
+	Annotations                  config.Annotations
+	MagicComments                config.Annotations
 	AddSourceMappings            bool
 	Indent                       int
+	IndentUnit                   string // Defaults to two spaces if empty
 	ToModuleRef                  js_ast.Ref
 	RuntimeRequireRef            js_ast.Ref
+	LoadScriptRef                js_ast.Ref
 	UnsupportedFeatures          compat.JSFeature
+	ExternalGlobals              map[string][]string
 	RequireOrImportMetaForSource func(uint32) RequireOrImportMeta
 
+	// These are decided by the linker for "--dynamic-import-loader=script". The
+	// first one means "import()" expressions without an import record become
+	// calls to "__loadScript()". The second one means "__loadScript()" is also
+	// passed the "module" script type.
+	CallRuntimeLoadScript bool
+	LoadScriptAsModule    bool
+
+	// Class and object members in this set are omitted by member tree shaking
+	RemovedMembers map[*js_ast.Property]bool
+
+	// This maps the original names of properties matching "--mangle-props" to
+	// their new names. It's filled in by the bundler after all files are parsed.
+	MangledProps map[string]string
+
 	// If we're writing out a source map, this table of line start indices lets
 	// us do binary search on to figure out what line a given AST node came from
 	LineOffsetTables []sourcemap.LineOffsetTable
@@ -3122,6 +3386,10 @@ type RequireOrImportMeta struct {
 	WrapperRef     js_ast.Ref
 	ExportsRef     js_ast.Ref
 	IsWrapperAsync bool
+
+	// If true, the "init_*" wrapper function isn't called when this file is
+	// imported. Instead, it's called wherever one of its exports is used.
+	IsLazy bool
 }
 
 type PrintResult struct {
@@ -3141,6 +3409,7 @@ func Print(tree js_ast.AST, symbols js_ast.SymbolMap, r renamer.Renamer, options
 		renamer:            r,
 		importRecords:      tree.ImportRecords,
 		options:            options,
+		sourceIndex:        tree.ModuleRef.SourceIndex,
 		stmtStart:          -1,
 		exportDefaultStart: -1,
 		arrowExprStart:     -1,
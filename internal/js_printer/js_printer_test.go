@@ -2,6 +2,7 @@ package js_printer
 
 import (
 	"testing"
+	"unicode"
 
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
@@ -39,6 +40,10 @@ func expectPrintedCommon(t *testing.T, name string, contents string, expected st
 		r := renamer.NewNoOpRenamer(symbols)
 		js := Print(tree, symbols, r, Options{
 			ASCIIOnly:           options.ASCIIOnly,
+			Annotations:         options.Annotations,
+			MagicComments:       options.MagicComments,
+			CharsetEscapes:      options.CharsetEscapes,
+			IdentifierCharset:   options.IdentifierCharset,
 			MangleSyntax:        options.MangleSyntax,
 			RemoveWhitespace:    options.RemoveWhitespace,
 			UnsupportedFeatures: options.UnsupportedJSFeatures,
@@ -535,6 +540,22 @@ func TestPureComment(t *testing.T) {
 	expectPrinted(t,
 		"/*@__PURE__*/new (function() {})()",
 		"/* @__PURE__ */ new function() {\n}();\n")
+
+	expectPrintedMinify(t, "/*@__PURE__*/foo()", "foo();")
+	expectPrintedCommon(t, "/*@__PURE__*/foo() [always]", "/*@__PURE__*/foo()", "/* @__PURE__ */foo();", config.Options{
+		RemoveWhitespace: true,
+		Annotations:      config.AnnotationsAlways,
+	})
+	expectPrintedCommon(t, "/*@__PURE__*/new Foo [always]", "/*@__PURE__*/new Foo", "/* @__PURE__ */new Foo;", config.Options{
+		RemoveWhitespace: true,
+		Annotations:      config.AnnotationsAlways,
+	})
+	expectPrintedCommon(t, "x = /*@__PURE__*/foo() [never]", "x = /*@__PURE__*/foo()", "x = foo();\n", config.Options{
+		Annotations: config.AnnotationsNever,
+	})
+	expectPrintedCommon(t, "x = /*@__PURE__*/new Foo() [never]", "x = /*@__PURE__*/new Foo()", "x = new Foo();\n", config.Options{
+		Annotations: config.AnnotationsNever,
+	})
 }
 
 func TestGenerator(t *testing.T) {
@@ -644,6 +665,16 @@ func TestImport(t *testing.T) {
 	expectPrinted(t, "import(/* comment 1 */ /* comment 2 */ 'path');", "import(\n  /* comment 1 */\n  /* comment 2 */\n  \"path\"\n);\n")
 	expectPrinted(t, "import(\n    /* multi\n     * line\n     * comment */ 'path');", "import(\n  /* multi\n   * line\n   * comment */\n  \"path\"\n);\n")
 	expectPrinted(t, "import(/* comment 1 */ 'path' /* comment 2 */);", "import(\n  /* comment 1 */\n  \"path\"\n);\n")
+	expectPrintedMinify(t, "import(/* webpackChunkName: 'x' */ 'path');", "import(\"path\");")
+	expectPrintedCommon(t, "import(/* webpackChunkName: 'x' */ 'path'); [always]", "import(/* webpackChunkName: 'x' */ 'path');",
+		"import(/* webpackChunkName: 'x' */\"path\");", config.Options{
+			RemoveWhitespace: true,
+			MagicComments:    config.AnnotationsAlways,
+		})
+	expectPrintedCommon(t, "import(/* webpackChunkName: 'x' */ 'path'); [never]", "import(/* webpackChunkName: 'x' */ 'path');",
+		"import(\"path\");\n", config.Options{
+			MagicComments: config.AnnotationsNever,
+		})
 }
 
 func TestExportDefault(t *testing.T) {
@@ -848,6 +879,57 @@ func TestASCIIOnly(t *testing.T) {
 	expectPrintedMinifyASCII(t, "(class 𐀀 extends π {})", "(class \\u{10000} extends \\u03C0{});")
 }
 
+func TestCharsetEscapes(t *testing.T) {
+	nonBMP := config.Options{CharsetEscapes: config.CharsetEscapes{NonBMP: true}}
+	expectPrintedCommon(t, "non-bmp string", "let π = '🐈π'", "let π = \"\\u{1F408}π\";\n", nonBMP)
+	expectPrintedCommon(t, "non-bmp identifier", "let 𐀀π", "let \\u{10000}π;\n", nonBMP)
+	expectPrintedCommon(t, "non-bmp template", "`🐈${x}π`", "`\\u{1F408}${x}π`;\n", nonBMP)
+
+	nonBMPES5 := nonBMP
+	nonBMPES5.UnsupportedJSFeatures = compat.UnsupportedJSFeatures(map[compat.Engine][]int{compat.ES: {5}})
+	expectPrintedCommon(t, "non-bmp es5 string", "'🐈π'", "\"\\uD83D\\uDC08π\";\n", nonBMPES5)
+	expectPrintedCommon(t, "non-bmp es5 property", "x.𐀀", "x[\"\\uD800\\uDC00\"];\n", nonBMPES5)
+	expectPrintedCommon(t, "non-bmp es5 bmp property", "x.π", "x.π;\n", nonBMPES5)
+	expectPrintedCommon(t, "non-bmp es5 bmp identifier", "var π", "var π;\n", nonBMPES5)
+
+	han := config.Options{CharsetEscapes: config.CharsetEscapes{Scripts: []*unicode.RangeTable{unicode.Han}}}
+	expectPrintedCommon(t, "han string", "let 貓 = '貓π'", "let \\u8C93 = \"\\u8C93π\";\n", han)
+	expectPrintedCommon(t, "han identifier", "let π貓", "let π\\u8C93;\n", han)
+	expectPrintedCommon(t, "han non-bmp string", "'𠀀🐈'", "\"\\u{20000}🐈\";\n", han)
+
+	hanES5 := han
+	hanES5.UnsupportedJSFeatures = nonBMPES5.UnsupportedJSFeatures
+	expectPrintedCommon(t, "han es5 identifier", "var 貓𐀀", "var \\u8C93𐀀;\n", hanES5)
+
+	// Surrogate pairs in JSX text are checked using the whole code point
+	hanJSX := han
+	hanJSX.JSX = config.JSXOptions{Parse: true, Preserve: true}
+	expectPrintedCommon(t, "han jsx attribute", "<a b='貓' />", "<a b={\"\\u8C93\"} />;\n", hanJSX)
+	expectPrintedCommon(t, "han non-bmp jsx attribute", "<a b='𠀀' />", "<a b={\"\\u{20000}\"} />;\n", hanJSX)
+	expectPrintedCommon(t, "han non-bmp jsx child", "<a>𠀀</a>", "<a>{\"\\u{20000}\"}</a>;\n", hanJSX)
+	expectPrintedCommon(t, "other non-bmp jsx attribute", "<a b='🐈' />", "<a b=\"🐈\" />;\n", hanJSX)
+	expectPrintedCommon(t, "other non-bmp jsx child", "<a>🐈</a>", "<a>🐈</a>;\n", hanJSX)
+
+	utf8Identifiers := config.Options{ASCIIOnly: true, IdentifierCharset: config.IdentifierCharsetUTF8}
+	expectPrintedCommon(t, "utf8 identifiers", "let π = 'π'", "let π = \"\\u03C0\";\n", utf8Identifiers)
+	expectPrintedCommon(t, "utf8 identifiers property", "x.π = { π }", "x.π = { π };\n", utf8Identifiers)
+
+	utf8IdentifiersES5 := utf8Identifiers
+	utf8IdentifiersES5.UnsupportedJSFeatures = nonBMPES5.UnsupportedJSFeatures
+	expectPrintedCommon(t, "utf8 identifiers es5", "var 𐀀 = '𐀀'", "var 𐀀 = \"\\uD800\\uDC00\";\n", utf8IdentifiersES5)
+
+	utf8IdentifiersNonBMPES5 := nonBMPES5
+	utf8IdentifiersNonBMPES5.IdentifierCharset = config.IdentifierCharsetUTF8
+	expectPrintedCommon(t, "utf8 identifiers non-bmp es5", "var 𐀀 = '𐀀'", "var 𐀀 = \"\\uD800\\uDC00\";\n", utf8IdentifiersNonBMPES5)
+
+	asciiIdentifiers := config.Options{IdentifierCharset: config.IdentifierCharsetASCII}
+	expectPrintedCommon(t, "ascii identifiers", "let π = 'π'", "let \\u03C0 = \"π\";\n", asciiIdentifiers)
+
+	asciiIdentifiersES5 := asciiIdentifiers
+	asciiIdentifiersES5.UnsupportedJSFeatures = nonBMPES5.UnsupportedJSFeatures
+	expectPrintedCommon(t, "ascii identifiers es5", "var π = '𐀀'", "var \\u03C0 = \"𐀀\";\n", asciiIdentifiersES5)
+}
+
 func TestJSX(t *testing.T) {
 	expectPrintedJSX(t, "<a/>", "<a />;\n")
 	expectPrintedJSX(t, "<A/>", "<A />;\n")
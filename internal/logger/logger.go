@@ -735,6 +735,7 @@ type SummaryTableEntry struct {
 	Size        string
 	Bytes       int
 	IsSourceMap bool
+	IsUnchanged bool
 }
 
 // This type is just so we can use Go's native sort function
@@ -794,6 +795,12 @@ func PrintSummary(useColor UseColor, table SummaryTable, start *time.Time) {
 				maxLength = 5
 			}
 			length := len(table)
+			unchangedCount := 0
+			for _, entry := range table {
+				if entry.IsUnchanged {
+					unchangedCount++
+				}
+			}
 			sort.Sort(table)
 			if length > maxLength {
 				table = table[:maxLength]
@@ -866,7 +873,9 @@ func PrintSummary(useColor UseColor, table SummaryTable, start *time.Time) {
 				// Put a warning next to the size if it's above a certain threshold
 				sizeColor := colors.Cyan
 				sizeWarning := ""
-				if !entry.IsSourceMap && entry.Bytes >= sizeWarningThreshold {
+				if entry.IsUnchanged {
+					sizeColor = colors.Dim
+				} else if !entry.IsSourceMap && entry.Bytes >= sizeWarningThreshold {
 					sizeColor = colors.Yellow
 
 					// Emoji don't work in Windows Command Prompt
@@ -899,6 +908,11 @@ func PrintSummary(useColor UseColor, table SummaryTable, start *time.Time) {
 				}
 				sb.WriteString(fmt.Sprintf("%s%s...and %d more output file%s...%s\n", margin, colors.Dim, length-maxLength, plural, colors.Reset))
 			}
+
+			// Say how many files were left alone because they didn't change
+			if unchangedCount > 0 {
+				sb.WriteString(fmt.Sprintf("\n%s%s%d changed, %d unchanged%s\n", margin, colors.Dim, length-unchangedCount, unchangedCount, colors.Reset))
+			}
 		}
 		sb.WriteByte('\n')
 
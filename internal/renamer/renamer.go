@@ -92,6 +92,12 @@ type MinifyRenamer struct {
 	reservedNames        map[string]uint32
 	slots                [3][]symbolSlot
 	topLevelSymbolToSlot map[js_ast.Ref]uint32
+
+	// These are only used with a name cache. Names from the cache are assigned
+	// before names are assigned by frequency, so the cached names must not be
+	// generated again.
+	cachedNames     map[string]bool
+	topLevelSlotKey map[uint32]string
 }
 
 func NewMinifyRenamer(symbols js_ast.SymbolMap, firstTopLevelSlots js_ast.SlotCounts, reservedNames map[string]uint32) *MinifyRenamer {
@@ -256,6 +262,64 @@ func (r *MinifyRenamer) AllocateTopLevelSymbolSlots(topLevelSymbols DeferredTopL
 	}
 }
 
+// A name cache gives top-level symbols the same names that they had in a
+// previous build. Top-level symbols are identified by a key that's stable
+// across builds (such as the path of the file and the original name). Nested
+// symbols aren't cached because their slots are shared between files.
+//
+// This must be called before "AssignNamesByFrequency". Cached names that
+// can't be used anymore (because they are now reserved, for example) are
+// ignored and the symbol gets a new name instead.
+func (r *MinifyRenamer) AssignCachedNames(keyForSymbol func(ref js_ast.Ref) string, cachedName func(key string) (string, bool)) {
+	r.cachedNames = make(map[string]bool)
+	r.topLevelSlotKey = make(map[uint32]string)
+	slots := r.slots[js_ast.SlotDefault]
+	seenKeys := make(map[string]bool)
+
+	// Iterate in slot order for determinism
+	refs := make([]js_ast.Ref, len(slots))
+	hasRef := make([]bool, len(slots))
+	for ref, i := range r.topLevelSymbolToSlot {
+		if r.symbols.Get(ref).SlotNamespace() == js_ast.SlotDefault {
+			refs[i] = ref
+			hasRef[i] = true
+		}
+	}
+
+	for i, ref := range refs {
+		if !hasRef[i] {
+			continue
+		}
+		key := keyForSymbol(ref)
+		if seenKeys[key] {
+			// Only the first symbol with a given key can use the cache
+			continue
+		}
+		seenKeys[key] = true
+		r.topLevelSlotKey[uint32(i)] = key
+
+		name, ok := cachedName(key)
+		if !ok || r.cachedNames[name] || r.reservedNames[name] != 0 || !js_lexer.IsIdentifier(name) {
+			continue
+		}
+		if slots[i].needsCapitalForJSX != 0 && (name[0] >= 'a' && name[0] <= 'z') {
+			continue
+		}
+		slots[i].name = name
+		r.cachedNames[name] = true
+	}
+}
+
+// This returns the names that were given to top-level symbols by key, for
+// writing out an updated name cache
+func (r *MinifyRenamer) TopLevelNamesByKey() map[string]string {
+	names := make(map[string]string, len(r.topLevelSlotKey))
+	for i, key := range r.topLevelSlotKey {
+		names[key] = r.slots[js_ast.SlotDefault][i].name
+	}
+	return names
+}
+
 func (r *MinifyRenamer) AssignNamesByFrequency(minifier *js_ast.NameMinifier) {
 	for ns, slots := range r.slots {
 		// Sort symbols by count
@@ -269,6 +333,10 @@ func (r *MinifyRenamer) AssignNamesByFrequency(minifier *js_ast.NameMinifier) {
 		nextName := 0
 		for _, data := range sorted {
 			slot := &slots[data.slot]
+			if slot.name != "" {
+				// This slot already has a name from the name cache
+				continue
+			}
 			name := minifier.NumberToMinifiedName(nextName)
 			nextName++
 
@@ -279,14 +347,14 @@ func (r *MinifyRenamer) AssignNamesByFrequency(minifier *js_ast.NameMinifier) {
 			// with a "#" character.
 			switch js_ast.SlotNamespace(ns) {
 			case js_ast.SlotDefault:
-				for r.reservedNames[name] != 0 {
+				for r.reservedNames[name] != 0 || r.cachedNames[name] {
 					name = minifier.NumberToMinifiedName(nextName)
 					nextName++
 				}
 
 				// Make sure names of symbols used in JSX elements start with a capital letter
 				if slot.needsCapitalForJSX != 0 {
-					for name[0] >= 'a' && name[0] <= 'z' {
+					for (name[0] >= 'a' && name[0] <= 'z') || r.cachedNames[name] {
 						name = minifier.NumberToMinifiedName(nextName)
 						nextName++
 					}
@@ -0,0 +1,446 @@
+package resolver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/fs"
+)
+
+// Deno imports packages with "npm:" and "jsr:" specifiers instead of bare
+// package paths. These are translated into regular package paths so that
+// they resolve against "node_modules" directories like any other package:
+//
+//   npm:react@18/jsx-runtime  =>  react/jsx-runtime
+//   npm:@types/node@^20       =>  @types/node
+//   jsr:@std/path@1/posix     =>  @jsr/std__path/posix
+//
+// The "@jsr/<scope>__<name>" naming comes from JSR's npm compatibility
+// registry, which is where "npx jsr add" installs JSR packages. The version
+// is ignored since "node_modules" only contains one copy of each package.
+//
+// If Deno's cache directory is configured, "npm:" specifiers with an exact
+// version are first looked up in Deno's global npm cache inside it. That
+// way code that Deno has already run can be bundled without installing
+// anything into "node_modules". Packages in the cache don't have their own
+// "node_modules" directories, so their dependencies are also looked up in
+// the cache using the version ranges in their "package.json" files.
+
+type denoSpecifier struct {
+	packageName string // e.g. "react" or "@jsr/std__path"
+	version     string // e.g. "18" or "" if there is no version
+	subpath     string // e.g. "/jsx-runtime" or "" if there is no subpath
+	isNPM       bool
+}
+
+func (spec denoSpecifier) packagePath() string {
+	return spec.packageName + spec.subpath
+}
+
+func parseDenoSpecifier(importPath string) (spec denoSpecifier, ok bool) {
+	var rest string
+	if strings.HasPrefix(importPath, "npm:") {
+		rest = importPath[len("npm:"):]
+		spec.isNPM = true
+	} else if strings.HasPrefix(importPath, "jsr:") {
+		rest = importPath[len("jsr:"):]
+	} else {
+		return
+	}
+
+	// Deno also accepts "npm:/react" and "jsr:/@std/path"
+	rest = strings.TrimPrefix(rest, "/")
+
+	// The package name ends at the version or the subpath
+	nameStart := 0
+	if strings.HasPrefix(rest, "@") {
+		slash := strings.IndexByte(rest, '/')
+		if slash == -1 {
+			return
+		}
+		nameStart = slash + 1
+	}
+	nameEnd := len(rest)
+	if end := strings.IndexAny(rest[nameStart:], "@/"); end != -1 {
+		nameEnd = nameStart + end
+	}
+	if nameEnd == nameStart {
+		return
+	}
+	spec.packageName = rest[:nameEnd]
+	rest = rest[nameEnd:]
+
+	if strings.HasPrefix(rest, "@") {
+		versionEnd := len(rest)
+		if slash := strings.IndexByte(rest, '/'); slash != -1 {
+			versionEnd = slash
+		}
+		spec.version = rest[1:versionEnd]
+		rest = rest[versionEnd:]
+	}
+	spec.subpath = rest
+
+	// All JSR packages are scoped, and their npm names are "@jsr/scope__name"
+	if !spec.isNPM {
+		if nameStart == 0 {
+			return
+		}
+		spec.packageName = fmt.Sprintf("@jsr/%s__%s", spec.packageName[1:nameStart-1], spec.packageName[nameStart:])
+	}
+
+	ok = true
+	return
+}
+
+// Deno's global npm cache stores each version of each package in its own
+// directory: "<DENO_DIR>/npm/registry.npmjs.org/<name>/<version>". Only
+// exact versions can be found this way since matching a version range would
+// mean picking between the versions in the cache.
+func (r resolverQuery) loadDenoCachePackage(spec denoSpecifier) (PathPair, bool, bool) {
+	if r.options.AbsDenoDir == "" || !spec.isNPM || !isExactVersion(spec.version) {
+		return PathPair{}, false, false
+	}
+
+	absPkgPath := r.fs.Join(r.denoCacheDir(), spec.packageName, spec.version)
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Checking for a package in the Deno cache directory %q", absPkgPath))
+	}
+	if r.dirInfoCached(absPkgPath) == nil {
+		return PathPair{}, false, false
+	}
+
+	absPath := r.fs.Join(absPkgPath, spec.subpath)
+	absolute, ok, _, hasExportsMap := r.loadPackageDir(absPkgPath, absPath, spec.packageName, "."+spec.subpath, true)
+	return absolute, ok, hasExportsMap
+}
+
+func (r resolverQuery) denoCacheDir() string {
+	return r.fs.Join(r.options.AbsDenoDir, "npm", "registry.npmjs.org")
+}
+
+// A bare import inside a package in Deno's npm cache resolves to another
+// package in the cache. The version is the highest cached version that
+// matches the range for that dependency in the importing package's
+// "package.json" file, or the highest cached version if there is no range.
+func (r resolverQuery) loadDenoCacheDependency(sourceDir string, packageName string, packageSubpath string) (PathPair, bool, *fs.DifferentCase, bool) {
+	if r.options.AbsDenoDir == "" {
+		return PathPair{}, false, nil, false
+	}
+
+	// Find the directory of the importing package
+	absCacheDir := r.denoCacheDir()
+	relDir, ok := r.fs.Rel(absCacheDir, sourceDir)
+	if !ok {
+		return PathPair{}, false, nil, false
+	}
+	parts := strings.Split(strings.ReplaceAll(relDir, "\\", "/"), "/")
+	partCount := 2
+	if strings.HasPrefix(parts[0], "@") {
+		partCount = 3
+	}
+	if len(parts) < partCount || parts[0] == ".." || parts[0] == "." {
+		return PathPair{}, false, nil, false
+	}
+	importerDir := r.fs.Join(append([]string{absCacheDir}, parts[:partCount]...)...)
+
+	version, ok := r.findDenoCacheVersion(r.fs.Join(absCacheDir, packageName), r.denoDependencyRange(importerDir, packageName))
+	if !ok {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote(fmt.Sprintf("Could not find a matching version of %q in the Deno cache directory", packageName))
+		}
+		return PathPair{}, false, nil, false
+	}
+
+	absPkgPath := r.fs.Join(absCacheDir, packageName, version)
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Checking for a dependency in the Deno cache directory %q", absPkgPath))
+	}
+	absPath := r.fs.Join(absPkgPath, packageSubpath)
+	return r.loadPackageDir(absPkgPath, absPath, packageName, packageSubpath, true)
+}
+
+// Returns the version range for the dependency from the "package.json" file
+// in the importing package's directory, or "" if there isn't one that can be
+// understood (e.g. "latest" or a git URL)
+func (r resolverQuery) denoDependencyRange(importerDir string, packageName string) string {
+	json, ok := r.parseJSONFile(r.fs.Join(importerDir, "package.json"))
+	if !ok {
+		return ""
+	}
+	for _, field := range []string{"dependencies", "peerDependencies", "optionalDependencies"} {
+		if deps, _, ok := getProperty(json, field); ok {
+			if rangeJSON, _, ok := getProperty(deps, packageName); ok {
+				if versionRange, ok := getString(rangeJSON); ok && isSemverRange(versionRange) {
+					return versionRange
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func (r resolverQuery) findDenoCacheVersion(absPkgDir string, versionRange string) (string, bool) {
+	entries, err, _ := r.fs.ReadDirectory(absPkgDir)
+	if err != nil {
+		return "", false
+	}
+
+	var best string
+	var bestVersion semverVersion
+	for _, base := range entries.SortedKeys() {
+		if entry, _ := entries.Get(base); entry == nil || entry.Kind(r.fs) != fs.DirEntry {
+			continue
+		}
+		version, ok := parseSemverVersion(base)
+		if !ok || (versionRange != "" && !semverRangeMatches(versionRange, version)) {
+			continue
+		}
+		if best == "" || compareSemverVersions(version, bestVersion) > 0 {
+			best = base
+			bestVersion = version
+		}
+	}
+	return best, best != ""
+}
+
+// This is a subset of npm's version ranges that covers what packages use in
+// practice: "||", hyphen ranges, the "<", "<=", ">", ">=", "=", "^", and "~"
+// operators, and partial versions with "x" or "*" wildcards. Prerelease
+// versions only match a comparator with the same major, minor, and patch.
+
+type semverVersion struct {
+	parts      [3]int
+	prerelease string
+}
+
+func parseSemverVersion(text string) (version semverVersion, ok bool) {
+	parts, count, prerelease, ok := parsePartialSemverVersion(text)
+	if !ok || count != 3 {
+		return semverVersion{}, false
+	}
+	return semverVersion{parts: parts, prerelease: prerelease}, true
+}
+
+// A partial version such as "1.2" or "1.x" has fewer than three parts
+func parsePartialSemverVersion(text string) (parts [3]int, count int, prerelease string, ok bool) {
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "v"), "=")
+	if plus := strings.IndexByte(text, '+'); plus != -1 {
+		text = text[:plus]
+	}
+	if dash := strings.IndexByte(text, '-'); dash != -1 {
+		text, prerelease = text[:dash], text[dash+1:]
+	}
+	if text == "" {
+		ok = true
+		return
+	}
+	fields := strings.Split(text, ".")
+	if len(fields) > 3 {
+		return
+	}
+	for _, field := range fields {
+		if field == "x" || field == "X" || field == "*" {
+			break
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return
+		}
+		parts[count] = n
+		count++
+	}
+	ok = true
+	return
+}
+
+// Prerelease identifiers are compared as strings, which is only approximate
+func compareSemverVersions(a semverVersion, b semverVersion) int {
+	for i := range a.parts {
+		if a.parts[i] != b.parts[i] {
+			if a.parts[i] < b.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case a.prerelease == b.prerelease:
+		return 0
+	case a.prerelease == "":
+		return 1
+	case b.prerelease == "":
+		return -1
+	case a.prerelease < b.prerelease:
+		return -1
+	default:
+		return 1
+	}
+}
+
+type semverComparator struct {
+	op      string // One of "<", "<=", ">", ">=", or "="
+	version semverVersion
+}
+
+func (c semverComparator) matches(version semverVersion) bool {
+	order := compareSemverVersions(version, c.version)
+	switch c.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	default:
+		return order == 0
+	}
+}
+
+func semverRangeMatches(versionRange string, version semverVersion) bool {
+	for _, set := range strings.Split(versionRange, "||") {
+		comparators, ok := parseSemverComparatorSet(set)
+		if !ok {
+			continue
+		}
+		matches := true
+		allowsPrerelease := version.prerelease == ""
+		for _, c := range comparators {
+			if !c.matches(version) {
+				matches = false
+				break
+			}
+			if c.version.prerelease != "" && c.version.parts == version.parts {
+				allowsPrerelease = true
+			}
+		}
+		if matches && allowsPrerelease {
+			return true
+		}
+	}
+	return false
+}
+
+func isSemverRange(versionRange string) bool {
+	for _, set := range strings.Split(versionRange, "||") {
+		if _, ok := parseSemverComparatorSet(set); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseSemverComparatorSet(set string) (comparators []semverComparator, ok bool) {
+	fields := strings.Fields(set)
+
+	// "1.2.3 - 2.3" means ">=1.2.3 <2.4.0"
+	if len(fields) == 3 && fields[1] == "-" {
+		lower, ok1 := semverComparatorsForOperator(">=", fields[0])
+		upper, ok2 := semverComparatorsForOperator("<=", fields[2])
+		return append(lower, upper...), ok1 && ok2
+	}
+
+	for _, field := range fields {
+		op := ""
+		for _, prefix := range []string{"<=", ">=", "<", ">", "=", "^", "~"} {
+			if strings.HasPrefix(field, prefix) {
+				op = prefix
+				break
+			}
+		}
+		more, ok := semverComparatorsForOperator(op, field[len(op):])
+		if !ok {
+			return nil, false
+		}
+		comparators = append(comparators, more...)
+	}
+	return comparators, true
+}
+
+func semverComparatorsForOperator(op string, text string) ([]semverComparator, bool) {
+	parts, count, prerelease, ok := parsePartialSemverVersion(text)
+	if !ok {
+		return nil, false
+	}
+	lower := semverVersion{parts: parts, prerelease: prerelease}
+
+	// Returns the first version after all versions with the same leading parts
+	bump := func(index int) semverVersion {
+		var upper semverVersion
+		copy(upper.parts[:index], parts[:index])
+		upper.parts[index] = parts[index] + 1
+		return upper
+	}
+
+	// A wildcard matches everything except where it's impossible to satisfy
+	if count == 0 {
+		switch op {
+		case "<", ">":
+			return []semverComparator{{op: "<", version: semverVersion{}}}, true
+		default:
+			return nil, true
+		}
+	}
+
+	switch op {
+	case "^":
+		// Bump the first non-zero part, or the last part if they are all zero
+		index := count - 1
+		for i := 0; i < count; i++ {
+			if parts[i] != 0 {
+				index = i
+				break
+			}
+		}
+		return []semverComparator{{op: ">=", version: lower}, {op: "<", version: bump(index)}}, true
+
+	case "~":
+		index := 0
+		if count > 1 {
+			index = 1
+		}
+		return []semverComparator{{op: ">=", version: lower}, {op: "<", version: bump(index)}}, true
+
+	case ">=", "<":
+		return []semverComparator{{op: op, version: lower}}, true
+
+	case ">":
+		if count < 3 {
+			return []semverComparator{{op: ">=", version: bump(count - 1)}}, true
+		}
+		return []semverComparator{{op: op, version: lower}}, true
+
+	case "<=":
+		if count < 3 {
+			return []semverComparator{{op: "<", version: bump(count - 1)}}, true
+		}
+		return []semverComparator{{op: op, version: lower}}, true
+
+	default:
+		if count < 3 {
+			return []semverComparator{{op: ">=", version: lower}, {op: "<", version: bump(count - 1)}}, true
+		}
+		return []semverComparator{{op: "=", version: lower}}, true
+	}
+}
+
+func isExactVersion(version string) bool {
+	// Ignore prerelease and build metadata such as "1.0.0-beta.1+abc"
+	if end := strings.IndexAny(version, "-+"); end != -1 {
+		version = version[:end]
+	}
+
+	// "18" and "18.2" are ranges, not exact versions
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
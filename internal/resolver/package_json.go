@@ -8,7 +8,9 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/helpers"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_lexer"
 	"github.com/evanw/esbuild/internal/js_parser"
@@ -280,6 +282,13 @@ func (r resolverQuery) parsePackageJSON(inputPath string) *packageJSON {
 			}
 		}
 	}
+	for _, field := range r.styleMainFields {
+		if mainJSON, mainRange, ok := getProperty(json, field); ok {
+			if main, ok := getString(mainJSON); ok && main != "" {
+				packageJSON.mainFields[field] = mainField{mainRange, main}
+			}
+		}
+	}
 	for _, field := range mainFieldsForFailure {
 		if _, ok := packageJSON.mainFields[field]; !ok {
 			if mainJSON, mainRange, ok := getProperty(json, field); ok {
@@ -329,6 +338,21 @@ func (r resolverQuery) parsePackageJSON(inputPath string) *packageJSON {
 		}
 	}
 
+	// Warn about dependencies that need a newer version of node than the target
+	if r.options.TargetNodeVersion != nil && helpers.IsInsideNodeModules(packageJSONPath) {
+		if enginesJSON, _, ok := getProperty(json, "engines"); ok {
+			if nodeJSON, _, ok := getProperty(enginesJSON, "node"); ok {
+				if nodeRange, ok := getString(nodeJSON); ok {
+					if min, ok := compat.MinVersionOfRange(nodeRange); ok && compat.CompareVersionArrays(min, r.options.TargetNodeVersion) > 0 {
+						r.log.Add(logger.Warning, &tracker, jsonSource.RangeOfString(nodeJSON.Loc),
+							fmt.Sprintf("This package requires node %s, which is newer than the target of node %s",
+								nodeRange, compat.VersionToString(r.options.TargetNodeVersion)))
+					}
+				}
+			}
+		}
+	}
+
 	// Read the "sideEffects" property
 	if sideEffectsJSON, sideEffectsLoc, ok := getProperty(json, "sideEffects"); ok {
 		switch data := sideEffectsJSON.Data.(type) {
@@ -19,6 +19,7 @@ import (
 	"github.com/evanw/esbuild/internal/js_lexer"
 	"github.com/evanw/esbuild/internal/js_printer"
 	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/runtime"
 )
 
 var defaultMainFields = map[config.Platform][]string{
@@ -101,10 +102,20 @@ type ResolveResult struct {
 	// If this was resolved by a plugin, the plugin gets to store its data here
 	PluginData interface{}
 
+	// If this was resolved by a plugin, these are the name of the plugin and
+	// the import path that the plugin was given. They are reported in the
+	// metafile for files that aren't in the "file" namespace.
+	PluginName      string
+	PluginSpecifier string
+
 	// If not empty, these should override the default values
 	JSXFactory  []string // Default if empty: "React.createElement"
 	JSXFragment []string // Default if empty: "React.Fragment"
 
+	// These are from "jsx": "react-jsx" and "jsxImportSource" in "tsconfig.json"
+	JSXAutomaticRuntime bool
+	JSXImportSource     string // Default if empty: "react"
+
 	DifferentCase *fs.DifferentCase
 
 	// If present, any ES6 imports to this file can be considered to have no side
@@ -170,6 +181,13 @@ type resolver struct {
 	esmConditionsDefault map[string]bool
 	esmConditionsImport  map[string]bool
 	esmConditionsRequire map[string]bool
+	esmConditionsStyle   map[string]bool
+
+	// Packages that contain both JavaScript and CSS point to their CSS using
+	// the "style" field in package.json. Some also point to their Sass source
+	// code using the "sass" field. These fields are checked before the main
+	// fields when resolving a package path from a CSS "@import".
+	styleMainFields []string
 
 	// A special filtered import order for CSS "@import" imports.
 	//
@@ -208,12 +226,16 @@ type resolver struct {
 	// This cache maps a directory path to information about that directory and
 	// all parent directories
 	dirCache map[string]*dirInfo
+
+	// This maps package names to the absolute paths of workspace packages
+	workspaces map[string]string
 }
 
 type resolverQuery struct {
 	*resolver
 	debugMeta *DebugMeta
 	debugLogs *debugLogs
+	denoSpec  *denoSpecifier
 	kind      ast.ImportKind
 }
 
@@ -221,7 +243,7 @@ func NewResolver(fs fs.FS, log logger.Log, caches *cache.CacheSet, options confi
 	// Filter out non-CSS extensions for CSS "@import" imports
 	atImportExtensionOrder := make([]string, 0, len(options.ExtensionOrder))
 	for _, ext := range options.ExtensionOrder {
-		if loader, ok := options.ExtensionToLoader[ext]; ok && loader != config.LoaderCSS {
+		if loader, ok := options.ExtensionToLoader[ext]; ok && !loader.IsCSS() {
 			continue
 		}
 		atImportExtensionOrder = append(atImportExtensionOrder, ext)
@@ -231,6 +253,7 @@ func NewResolver(fs fs.FS, log logger.Log, caches *cache.CacheSet, options confi
 	esmConditionsDefault := map[string]bool{"default": true}
 	esmConditionsImport := map[string]bool{"import": true}
 	esmConditionsRequire := map[string]bool{"require": true}
+	esmConditionsStyle := map[string]bool{"style": true}
 	for _, condition := range options.Conditions {
 		esmConditionsDefault[condition] = true
 	}
@@ -243,9 +266,21 @@ func NewResolver(fs fs.FS, log logger.Log, caches *cache.CacheSet, options confi
 	for key := range esmConditionsDefault {
 		esmConditionsImport[key] = true
 		esmConditionsRequire[key] = true
+		esmConditionsStyle[key] = true
 	}
 
-	return &resolver{
+	// Only resolve CSS imports to Sass files if they can be loaded. Otherwise
+	// a package with both Sass and CSS files could resolve to a Sass file that
+	// fails to build instead of to the CSS file.
+	styleMainFields := []string{"style"}
+	_, hasSCSS := options.ExtensionToLoader[".scss"]
+	_, hasSass := options.ExtensionToLoader[".sass"]
+	if hasSCSS || hasSass || esmConditionsDefault["sass"] {
+		esmConditionsStyle["sass"] = true
+		styleMainFields = append(styleMainFields, "sass")
+	}
+
+	rr := &resolver{
 		fs:                     fs,
 		log:                    log,
 		options:                options,
@@ -255,7 +290,31 @@ func NewResolver(fs fs.FS, log logger.Log, caches *cache.CacheSet, options confi
 		esmConditionsDefault:   esmConditionsDefault,
 		esmConditionsImport:    esmConditionsImport,
 		esmConditionsRequire:   esmConditionsRequire,
+		esmConditionsStyle:     esmConditionsStyle,
+		styleMainFields:        styleMainFields,
+	}
+
+	// Explicitly-configured workspaces override detected ones
+	if options.DetectWorkspaces {
+		r := resolverQuery{resolver: rr}
+		if log.Level <= logger.LevelDebug {
+			r.debugLogs = &debugLogs{what: "Detecting workspace packages"}
+		}
+		rr.workspaces = r.detectWorkspaces()
+		if r.debugLogs != nil {
+			log.AddWithNotes(logger.Debug, nil, logger.Range{}, r.debugLogs.what, r.debugLogs.notes)
+		}
+	}
+	if len(options.Workspaces) > 0 {
+		if rr.workspaces == nil {
+			rr.workspaces = make(map[string]string)
+		}
+		for name, absDir := range options.Workspaces {
+			rr.workspaces[name] = absDir
+		}
 	}
+
+	return rr
 }
 
 func (rr *resolver) Resolve(sourceDir string, importPath string, kind ast.ImportKind) (*ResolveResult, DebugMeta) {
@@ -271,6 +330,23 @@ func (rr *resolver) Resolve(sourceDir string, importPath string, kind ast.Import
 			importPath, sourceDir, kind.StringForMetafile())}
 	}
 
+	// Substitute aliases first so that the new path goes through everything
+	// below, including the checks for external paths
+	if aliasedPath, ok := r.checkPackageAlias(importPath); ok {
+		importPath = aliasedPath
+	}
+
+	// Deno-style "npm:" and "jsr:" specifiers are package paths in disguise.
+	// They are normalized before the checks for external paths so that those
+	// checks apply to them too (e.g. "--external:react" and "npm:react@18").
+	if spec, ok := parseDenoSpecifier(importPath); ok {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote(fmt.Sprintf("Resolving %q as the package path %q", importPath, spec.packagePath()))
+		}
+		r.denoSpec = &spec
+		importPath = spec.packagePath()
+	}
+
 	// Certain types of URLs default to being external for convenience
 	if r.isExternalPattern(importPath) ||
 
@@ -364,6 +440,21 @@ func (rr *resolver) Resolve(sourceDir string, importPath string, kind ast.Import
 		}, debugMeta
 	}
 
+	// "import EventEmitter from 'events'"
+	// "import { Buffer } from 'node:buffer'"
+	if r.options.NodePolyfills && r.options.Platform == config.PlatformBrowser {
+		name := strings.TrimPrefix(importPath, "node:")
+		if _, ok := runtime.NodePolyfills[name]; ok {
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Using the browser polyfill for the node module %q", name))
+			}
+			r.flushDebugLogs(flushDueToSuccess)
+			return &ResolveResult{
+				PathPair: PathPair{Primary: logger.Path{Text: name, Namespace: runtime.NodePolyfillNamespace}},
+			}, debugMeta
+		}
+	}
+
 	// Fail now if there is no directory to resolve in. This can happen for
 	// virtual modules (e.g. stdin) if a resolve directory is not specified.
 	if sourceDir == "" {
@@ -559,6 +650,8 @@ func (r resolverQuery) finalizeResolve(result *ResolveResult) {
 					} else {
 						result.JSXFactory = dirInfo.enclosingTSConfigJSON.JSXFactory
 						result.JSXFragment = dirInfo.enclosingTSConfigJSON.JSXFragmentFactory
+						result.JSXAutomaticRuntime = dirInfo.enclosingTSConfigJSON.JSXAutomaticRuntime
+						result.JSXImportSource = dirInfo.enclosingTSConfigJSON.JSXImportSource
 						result.UseDefineForClassFieldsTS = dirInfo.enclosingTSConfigJSON.UseDefineForClassFields
 						result.UnusedImportsTS = config.UnusedImportsFromTsconfigValues(
 							dirInfo.enclosingTSConfigJSON.PreserveImportsNotUsedAsValues,
@@ -613,6 +706,30 @@ func (r resolverQuery) finalizeResolve(result *ResolveResult) {
 	}
 }
 
+// An alias for a package also applies to paths inside that package. For
+// example, an alias from "react" to "preact/compat" means "react/jsx-runtime"
+// becomes "preact/compat/jsx-runtime".
+func (r resolverQuery) checkPackageAlias(importPath string) (string, bool) {
+	if len(r.options.PackageAliases) == 0 || !IsPackagePath(importPath) {
+		return "", false
+	}
+	name := importPath
+	for {
+		if aliasedPath, ok := r.options.PackageAliases[name]; ok {
+			aliasedPath += importPath[len(name):]
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Substituting %q for %q because of the alias for %q", aliasedPath, importPath, name))
+			}
+			return aliasedPath, true
+		}
+		slash := strings.LastIndexByte(name, '/')
+		if slash == -1 {
+			return "", false
+		}
+		name = name[:slash]
+	}
+}
+
 func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath string) *ResolveResult {
 	// This implements the module resolution algorithm from node.js, which is
 	// described here: https://nodejs.org/api/modules.html#modules_all_together
@@ -651,7 +768,7 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 		}
 
 		// Run node's resolution rules (e.g. adding ".js")
-		if absolute, ok, diffCase := r.loadAsFileOrDirectory(importPath); ok {
+		if absolute, ok, diffCase := r.loadAsDirectoryImport(importPath, importPath); ok {
 			return &ResolveResult{PathPair: absolute, DifferentCase: diffCase}
 		} else {
 			return nil
@@ -661,7 +778,7 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 	// Check both relative and package paths for CSS URL tokens, with relative
 	// paths taking precedence over package paths to match Webpack behavior.
 	isPackagePath := IsPackagePath(importPath)
-	checkRelative := !isPackagePath || r.kind == ast.ImportURL || r.kind == ast.ImportAt
+	checkRelative := !isPackagePath || r.kind == ast.ImportURL || r.kind == ast.ImportAt || r.kind == ast.ImportComposesFrom
 	checkPackage := isPackagePath
 
 	if checkRelative {
@@ -690,7 +807,7 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 		}
 
 		if checkRelative {
-			if absolute, ok, diffCase := r.loadAsFileOrDirectory(absPath); ok {
+			if absolute, ok, diffCase := r.loadAsDirectoryImport(importPath, absPath); ok {
 				checkPackage = false
 				result = ResolveResult{PathPair: absolute, DifferentCase: diffCase}
 			} else if !checkPackage {
@@ -700,7 +817,21 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 	}
 
 	if checkPackage {
-		// Check for external packages first
+		// Check for external packages first. CSS "url()" tokens are excluded
+		// since those are almost always meant to be files, not packages.
+		if r.options.ExternalPackages && r.kind != ast.ImportEntryPoint && r.kind != ast.ImportURL && !strings.HasPrefix(importPath, "#") {
+			// Path mappings in "tsconfig.json" still apply since they refer to
+			// files in the project instead of to packages
+			if dirInfo := r.dirInfoCached(sourceDir); dirInfo != nil && dirInfo.enclosingTSConfigJSON != nil && dirInfo.enclosingTSConfigJSON.Paths != nil {
+				if absolute, ok, diffCase := r.matchTSConfigPaths(dirInfo.enclosingTSConfigJSON, importPath); ok {
+					return &ResolveResult{PathPair: absolute, DifferentCase: diffCase}
+				}
+			}
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("The path %q was marked as external because all packages are external", importPath))
+			}
+			return &ResolveResult{PathPair: PathPair{Primary: logger.Path{Text: importPath}}, IsExternal: true}
+		}
 		if r.options.ExternalModules.NodeModules != nil {
 			query := importPath
 			for {
@@ -721,6 +852,16 @@ func (r resolverQuery) resolveWithoutSymlinks(sourceDir string, importPath strin
 			}
 		}
 
+		// Deno's npm cache takes precedence over "node_modules" directories
+		if r.denoSpec != nil {
+			if absolute, ok, hasExportsMap := r.loadDenoCachePackage(*r.denoSpec); ok || hasExportsMap {
+				if !ok {
+					return nil
+				}
+				return &ResolveResult{PathPair: absolute}
+			}
+		}
+
 		sourceDirInfo := r.dirInfoCached(sourceDir)
 		if sourceDirInfo == nil {
 			// Bail if the directory is missing for some reason
@@ -1260,7 +1401,7 @@ func getBool(json js_ast.Expr) (bool, bool) {
 func (r resolverQuery) loadAsFileOrDirectory(path string) (PathPair, bool, *fs.DifferentCase) {
 	// Use a special import order for CSS "@import" imports
 	extensionOrder := r.options.ExtensionOrder
-	if r.kind == ast.ImportAt || r.kind == ast.ImportAtConditional {
+	if r.kind.IsCSSImport() {
 		extensionOrder = r.atImportExtensionOrder
 	}
 
@@ -1294,6 +1435,83 @@ func (r resolverQuery) loadAsFileOrDirectory(path string) (PathPair, bool, *fs.D
 	return PathPair{}, false, nil
 }
 
+// Relative and absolute import paths can refer to a directory instead of to a
+// file (e.g. "./lib" for "./lib/index.js"). These directory imports are
+// resolved like in CommonJS by default, but other runtimes have other rules,
+// so this is configurable. Package paths are always resolved the same way.
+func (r resolverQuery) loadAsDirectoryImport(importPath string, absPath string) (PathPair, bool, *fs.DifferentCase) {
+	if r.options.DirectoryImports == config.DirectoryImportsCommonJS && r.options.IndexExtensions == nil {
+		return r.loadAsFileOrDirectory(absPath)
+	}
+
+	// Use a special import order for CSS "@import" imports
+	extensionOrder := r.options.ExtensionOrder
+	indexExtensionOrder := r.options.IndexExtensions
+	if r.kind.IsCSSImport() {
+		extensionOrder = r.atImportExtensionOrder
+		indexExtensionOrder = nil
+	}
+	if indexExtensionOrder == nil {
+		indexExtensionOrder = extensionOrder
+	}
+
+	// Is this a file?
+	absolute, ok, diffCase := r.loadAsFile(absPath, extensionOrder)
+	if ok {
+		return PathPair{Primary: logger.Path{Text: absolute, Namespace: "file"}}, true, diffCase
+	}
+
+	// Is this a directory?
+	if r.debugLogs != nil {
+		r.debugLogs.addNote(fmt.Sprintf("Attempting to load %q as a directory", absPath))
+		r.debugLogs.increaseIndent()
+		defer r.debugLogs.decreaseIndent()
+	}
+	dirInfo := r.dirInfoCached(absPath)
+	if dirInfo == nil {
+		return PathPair{}, false, nil
+	}
+
+	// Node only supports directory imports with "require()", not with "import"
+	if r.options.DirectoryImports == config.DirectoryImportsNodeESM && (r.kind == ast.ImportStmt || r.kind == ast.ImportDynamic) {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote("Directory imports are not supported in node's ECMAScript module mode")
+		}
+		if r.debugMeta != nil {
+			r.debugMeta.notes = []logger.MsgData{{Text: fmt.Sprintf(
+				"Importing the directory %q is not supported in node's ECMAScript module mode",
+				r.PrettyPath(logger.Path{Text: absPath, Namespace: "file"}))}}
+
+			// Provide an inline suggestion message with the "index" file
+			if absolute, ok, _ := r.loadAsIndex(dirInfo, absPath, indexExtensionOrder); ok {
+				actualImportPath := strings.TrimSuffix(importPath, "/") + "/" + r.fs.Base(absolute.Primary.Text)
+				r.debugMeta.suggestionText = string(js_printer.QuoteForJSON(actualImportPath, false))
+				r.debugMeta.suggestionMessage = fmt.Sprintf("Import from %q to get the file %q:",
+					actualImportPath, r.PrettyPath(absolute.Primary))
+			}
+		}
+		return PathPair{}, false, nil
+	}
+
+	// Try using the main field(s) from "package.json"
+	if absolute, ok, diffCase := r.loadAsMainField(dirInfo, absPath, extensionOrder); ok {
+		return absolute, true, diffCase
+	}
+
+	// Look for an "index" file with known extensions
+	if r.options.DirectoryImports == config.DirectoryImportsNoIndex {
+		if r.debugLogs != nil {
+			r.debugLogs.addNote("Not looking for an \"index\" file because this is disabled")
+		}
+		return PathPair{}, false, nil
+	}
+	if absolute, ok, diffCase := r.loadAsIndexWithBrowserRemapping(dirInfo, absPath, indexExtensionOrder); ok {
+		return absolute, true, diffCase
+	}
+
+	return PathPair{}, false, nil
+}
+
 func (r resolverQuery) loadAsMainField(dirInfo *dirInfo, path string, extensionOrder []string) (PathPair, bool, *fs.DifferentCase) {
 	if dirInfo.packageJSON == nil {
 		return PathPair{}, false, nil
@@ -1310,6 +1528,11 @@ func (r resolverQuery) loadAsMainField(dirInfo *dirInfo, path string, extensionO
 		autoMain = true
 	}
 
+	// Prefer the CSS entry point of the package when importing from CSS
+	if r.kind.IsCSSImport() {
+		mainFieldKeys = append(append([]string{}, r.styleMainFields...), mainFieldKeys...)
+	}
+
 	loadMainField := func(fieldRelPath string, field string) (PathPair, bool, *fs.DifferentCase) {
 		if r.debugLogs != nil {
 			r.debugLogs.addNote(fmt.Sprintf("Found main field %q with path %q", field, fieldRelPath))
@@ -1612,6 +1835,8 @@ func (r resolverQuery) loadNodeModules(importPath string, dirInfo *dirInfo, forb
 			conditions = r.esmConditionsImport
 		case ast.ImportRequire, ast.ImportRequireResolve:
 			conditions = r.esmConditionsRequire
+		case ast.ImportAt, ast.ImportAtConditional, ast.ImportComposesFrom:
+			conditions = r.esmConditionsStyle
 		}
 
 		resolvedPath, status, debug := r.esmPackageImportsResolve(importPath, packageJSON.importsMap.root, conditions)
@@ -1644,6 +1869,34 @@ func (r resolverQuery) loadNodeModules(importPath string, dirInfo *dirInfo, forb
 		r.debugLogs.addNote(fmt.Sprintf("Parsed package name %q and package subpath %q", esmPackageName, esmPackageSubpath))
 	}
 
+	// Then check for workspace packages, which take precedence over packages in
+	// "node_modules" directories since they may be stale published copies. If
+	// the path can't be resolved in the workspace package, it may still be
+	// resolved normally (e.g. a file that is only present in the published copy)
+	// unless the workspace package's "exports" map rejected it.
+	if esmOK {
+		if absPkgPath, ok := r.workspaces[esmPackageName]; ok {
+			absPath := r.fs.Join(absPkgPath, esmPackageSubpath)
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Found workspace package %q in the directory %q", esmPackageName, absPkgPath))
+			}
+			if absolute, ok, diffCase, hasExportsMap := r.loadPackageDir(absPkgPath, absPath, esmPackageName, esmPackageSubpath, esmOK); ok || hasExportsMap {
+				return absolute, ok, diffCase
+			}
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Could not resolve %q in the workspace package, so checking \"node_modules\" directories instead", importPath))
+			}
+		}
+	}
+
+	// Then check for dependencies of packages in Deno's npm cache, since those
+	// packages don't have their own "node_modules" directories
+	if esmOK {
+		if absolute, ok, diffCase, hasExportsMap := r.loadDenoCacheDependency(dirInfo.absPath, esmPackageName, esmPackageSubpath); ok || hasExportsMap {
+			return absolute, ok, diffCase
+		}
+	}
+
 	// Then check for the package in any enclosing "node_modules" directories
 	for {
 		// Skip directories that are themselves called "node_modules", since we
@@ -1654,57 +1907,10 @@ func (r resolverQuery) loadNodeModules(importPath string, dirInfo *dirInfo, forb
 				r.debugLogs.addNote(fmt.Sprintf("Checking for a package in the directory %q", absPath))
 			}
 
-			// Check the package's package.json file
-			if esmOK {
-				absPkgPath := r.fs.Join(dirInfo.absPath, "node_modules", esmPackageName)
-				if pkgDirInfo := r.dirInfoCached(absPkgPath); pkgDirInfo != nil {
-					// Check the "exports" map
-					if packageJSON := pkgDirInfo.packageJSON; packageJSON != nil && packageJSON.exportsMap != nil {
-						if r.debugLogs != nil {
-							r.debugLogs.addNote(fmt.Sprintf("Looking for %q in \"exports\" map in %q", esmPackageSubpath, packageJSON.source.KeyPath.Text))
-							r.debugLogs.increaseIndent()
-							defer r.debugLogs.decreaseIndent()
-						}
-
-						// The condition set is determined by the kind of import
-						conditions := r.esmConditionsDefault
-						switch r.kind {
-						case ast.ImportStmt, ast.ImportDynamic:
-							conditions = r.esmConditionsImport
-						case ast.ImportRequire, ast.ImportRequireResolve:
-							conditions = r.esmConditionsRequire
-						}
-
-						// Resolve against the path "/", then join it with the absolute
-						// directory path. This is done because ESM package resolution uses
-						// URLs while our path resolution uses file system paths. We don't
-						// want problems due to Windows paths, which are very unlike URL
-						// paths. We also want to avoid any "%" characters in the absolute
-						// directory path accidentally being interpreted as URL escapes.
-						resolvedPath, status, debug := r.esmPackageExportsResolve("/", esmPackageSubpath, packageJSON.exportsMap.root, conditions)
-						resolvedPath, status, debug = r.esmHandlePostConditions(resolvedPath, status, debug)
-
-						return r.finalizeImportsExportsResult(
-							absPkgPath, conditions, *packageJSON.exportsMap, packageJSON,
-							resolvedPath, status, debug,
-							esmPackageName, esmPackageSubpath, absPath,
-						)
-					}
-
-					// Check the "browser" map
-					if remapped, ok := r.checkBrowserMap(pkgDirInfo, absPath, absolutePathKind); ok {
-						if remapped == nil {
-							return PathPair{Primary: logger.Path{Text: absPath, Namespace: "file", Flags: logger.PathDisabled}}, true, nil
-						}
-						if remappedResult, ok, diffCase := r.resolveWithoutRemapping(pkgDirInfo.enclosingBrowserScope, *remapped); ok {
-							return remappedResult, true, diffCase
-						}
-					}
-				}
-			}
-
-			if absolute, ok, diffCase := r.loadAsFileOrDirectory(absPath); ok {
-				return absolute, true, diffCase
+			absPkgPath := r.fs.Join(dirInfo.absPath, "node_modules", esmPackageName)
+			// Stop searching if the package has an "exports" map, even on failure
+			if absolute, ok, diffCase, hasExportsMap := r.loadPackageDir(absPkgPath, absPath, esmPackageName, esmPackageSubpath, esmOK); ok || hasExportsMap {
+				return absolute, ok, diffCase
 			}
 		}
 
@@ -1732,6 +1938,72 @@ func (r resolverQuery) loadNodeModules(importPath string, dirInfo *dirInfo, forb
 	return PathPair{}, false, nil
 }
 
+// This loads the path "absPath" from inside the package directory "absPkgPath"
+// while respecting the "exports" and "browser" fields in that package's
+// "package.json" file. The last return value is true if the "exports" field
+// was used, in which case the result is final even if resolution failed.
+func (r resolverQuery) loadPackageDir(
+	absPkgPath string,
+	absPath string,
+	esmPackageName string,
+	esmPackageSubpath string,
+	esmOK bool,
+) (PathPair, bool, *fs.DifferentCase, bool) {
+	// Check the package's package.json file
+	if esmOK {
+		if pkgDirInfo := r.dirInfoCached(absPkgPath); pkgDirInfo != nil {
+			// Check the "exports" map
+			if packageJSON := pkgDirInfo.packageJSON; packageJSON != nil && packageJSON.exportsMap != nil {
+				if r.debugLogs != nil {
+					r.debugLogs.addNote(fmt.Sprintf("Looking for %q in \"exports\" map in %q", esmPackageSubpath, packageJSON.source.KeyPath.Text))
+					r.debugLogs.increaseIndent()
+					defer r.debugLogs.decreaseIndent()
+				}
+
+				// The condition set is determined by the kind of import
+				conditions := r.esmConditionsDefault
+				switch r.kind {
+				case ast.ImportStmt, ast.ImportDynamic:
+					conditions = r.esmConditionsImport
+				case ast.ImportRequire, ast.ImportRequireResolve:
+					conditions = r.esmConditionsRequire
+				case ast.ImportAt, ast.ImportAtConditional, ast.ImportComposesFrom:
+					conditions = r.esmConditionsStyle
+				}
+
+				// Resolve against the path "/", then join it with the absolute
+				// directory path. This is done because ESM package resolution uses
+				// URLs while our path resolution uses file system paths. We don't
+				// want problems due to Windows paths, which are very unlike URL
+				// paths. We also want to avoid any "%" characters in the absolute
+				// directory path accidentally being interpreted as URL escapes.
+				resolvedPath, status, debug := r.esmPackageExportsResolve("/", esmPackageSubpath, packageJSON.exportsMap.root, conditions)
+				resolvedPath, status, debug = r.esmHandlePostConditions(resolvedPath, status, debug)
+
+				absolute, ok, diffCase := r.finalizeImportsExportsResult(
+					absPkgPath, conditions, *packageJSON.exportsMap, packageJSON,
+					resolvedPath, status, debug,
+					esmPackageName, esmPackageSubpath, absPath,
+				)
+				return absolute, ok, diffCase, true
+			}
+
+			// Check the "browser" map
+			if remapped, ok := r.checkBrowserMap(pkgDirInfo, absPath, absolutePathKind); ok {
+				if remapped == nil {
+					return PathPair{Primary: logger.Path{Text: absPath, Namespace: "file", Flags: logger.PathDisabled}}, true, nil, false
+				}
+				if remappedResult, ok, diffCase := r.resolveWithoutRemapping(pkgDirInfo.enclosingBrowserScope, *remapped); ok {
+					return remappedResult, true, diffCase, false
+				}
+			}
+		}
+	}
+
+	absolute, ok, diffCase := r.loadAsFileOrDirectory(absPath)
+	return absolute, ok, diffCase, false
+}
+
 func (r resolverQuery) finalizeImportsExportsResult(
 	absDirPath string,
 	conditions map[string]bool,
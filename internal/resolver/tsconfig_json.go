@@ -37,6 +37,8 @@ type TSConfigJSON struct {
 
 	JSXFactory                     []string
 	JSXFragmentFactory             []string
+	JSXImportSource                string
+	JSXAutomaticRuntime            bool
 	TSTarget                       *config.TSTarget
 	UseDefineForClassFields        config.MaybeBool
 	PreserveImportsNotUsedAsValues bool
@@ -54,12 +56,14 @@ func ParseTSConfigJSON(
 	// TypeScript compiler.
 	//
 	// Attempt to parse it anyway by modifying the JSON parser, but just for
-	// these particular files. This is likely not a completely accurate
-	// emulation of what the TypeScript compiler does (e.g. string escape
-	// behavior may also be different).
+	// these particular files. The TypeScript compiler parses these files as
+	// JavaScript object literals, so use JSON5 which is the closest match. It
+	// allows comments (https://github.com/microsoft/TypeScript/issues/4987),
+	// trailing commas, single-quoted strings, and unquoted keys. This is likely
+	// not a completely accurate emulation of what the TypeScript compiler does
+	// (e.g. string escape behavior may also be different).
 	json, ok := jsonCache.Parse(log, source, js_parser.JSONOptions{
-		AllowComments:       true, // https://github.com/microsoft/TypeScript/issues/4987
-		AllowTrailingCommas: true,
+		AllowJSON5: true,
 	})
 	if !ok {
 		return nil
@@ -103,6 +107,22 @@ func ParseTSConfigJSON(
 			}
 		}
 
+		// Parse "jsx"
+		if valueJSON, _, ok := getProperty(compilerOptionsJSON, "jsx"); ok {
+			if value, ok := getString(valueJSON); ok {
+				// Only "react-jsx" changes how esbuild compiles JSX. The other values
+				// are either the default or are configured using esbuild's own options.
+				result.JSXAutomaticRuntime = strings.ToLower(value) == "react-jsx"
+			}
+		}
+
+		// Parse "jsxImportSource"
+		if valueJSON, _, ok := getProperty(compilerOptionsJSON, "jsxImportSource"); ok {
+			if value, ok := getString(valueJSON); ok {
+				result.JSXImportSource = value
+			}
+		}
+
 		// Parse "useDefineForClassFields"
 		if valueJSON, _, ok := getProperty(compilerOptionsJSON, "useDefineForClassFields"); ok {
 			if value, ok := getBool(valueJSON); ok {
@@ -0,0 +1,218 @@
+package resolver
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/js_ast"
+	"github.com/evanw/esbuild/internal/js_parser"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Workspaces let packages in a monorepo import each other by package name
+// without publishing them or symlinking them into a "node_modules" directory.
+// The resolved paths point into the workspace's source directory, so imports
+// from inside the workspace package consider its own nested "node_modules"
+// directory first just like they would if they were symlinked.
+//
+// Mappings can be configured explicitly or detected from the workspace root.
+// The root is the closest directory at or above the current working directory
+// that has either a "package.json" file with a "workspaces" field (npm and
+// Yarn) or a "pnpm-workspace.yaml" file (pnpm).
+
+func (r resolverQuery) detectWorkspaces() map[string]string {
+	for dir := r.fs.Cwd(); ; {
+		var patterns []string
+		var found bool
+
+		if patterns, found = r.workspacePatternsFromPackageJSON(dir); !found {
+			patterns, found = r.workspacePatternsFromPNPM(dir)
+		}
+
+		if found {
+			if r.debugLogs != nil {
+				r.debugLogs.addNote(fmt.Sprintf("Using %q as the workspace root", dir))
+			}
+			return r.expandWorkspacePatterns(dir, patterns)
+		}
+
+		parent := r.fs.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	r.log.Add(logger.Warning, nil, logger.Range{},
+		"Could not find a workspace root (a \"package.json\" file with a \"workspaces\" field or a \"pnpm-workspace.yaml\" file)")
+	return nil
+}
+
+func (r resolverQuery) parseJSONFile(absPath string) (js_ast.Expr, bool) {
+	contents, err, _ := r.caches.FSCache.ReadFile(r.fs, absPath)
+	if err != nil {
+		return js_ast.Expr{}, false
+	}
+	keyPath := logger.Path{Text: absPath, Namespace: "file"}
+	source := logger.Source{
+		KeyPath:    keyPath,
+		PrettyPath: r.PrettyPath(keyPath),
+		Contents:   contents,
+	}
+	return r.caches.JSONCache.Parse(r.log, source, js_parser.JSONOptions{})
+}
+
+func (r resolverQuery) workspacePatternsFromPackageJSON(dir string) (patterns []string, found bool) {
+	json, ok := r.parseJSONFile(r.fs.Join(dir, "package.json"))
+	if !ok {
+		return
+	}
+	value, _, ok := getProperty(json, "workspaces")
+	if !ok {
+		return
+	}
+
+	// Yarn also allows "workspaces": { "packages": [...] }
+	if _, ok := value.Data.(*js_ast.EObject); ok {
+		if value, _, ok = getProperty(value, "packages"); !ok {
+			return
+		}
+	}
+
+	if array, ok := value.Data.(*js_ast.EArray); ok {
+		for _, item := range array.Items {
+			if str, ok := getString(item); ok {
+				patterns = append(patterns, str)
+			}
+		}
+		found = true
+	}
+	return
+}
+
+// This only understands the "packages" list in "pnpm-workspace.yaml", which is
+// all that the file is documented to contain. It is not a general YAML parser.
+func (r resolverQuery) workspacePatternsFromPNPM(dir string) (patterns []string, found bool) {
+	contents, err, _ := r.caches.FSCache.ReadFile(r.fs, r.fs.Join(dir, "pnpm-workspace.yaml"))
+	if err != nil {
+		return
+	}
+	found = true
+	inPackages := false
+
+	for _, line := range strings.Split(contents, "\n") {
+		if hash := strings.IndexByte(line, '#'); hash != -1 {
+			line = line[:hash]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		// A non-indented line starts a new top-level key
+		if line[0] != ' ' && line[0] != '\t' && line[0] != '-' {
+			inPackages = trimmed == "packages:"
+			continue
+		}
+
+		if inPackages && strings.HasPrefix(trimmed, "-") {
+			item := strings.TrimSpace(trimmed[1:])
+			if len(item) >= 2 && (item[0] == '\'' || item[0] == '"') && item[len(item)-1] == item[0] {
+				item = item[1 : len(item)-1]
+			}
+			if item != "" {
+				patterns = append(patterns, item)
+			}
+		}
+	}
+	return
+}
+
+func (r resolverQuery) expandWorkspacePatterns(rootDir string, patterns []string) map[string]string {
+	included := make(map[string]bool)
+	var order []string
+
+	for _, pattern := range patterns {
+		isNegated := strings.HasPrefix(pattern, "!")
+		if isNegated {
+			pattern = pattern[1:]
+		}
+		pattern = strings.TrimPrefix(strings.TrimSuffix(strings.ReplaceAll(pattern, "\\", "/"), "/"), "./")
+
+		for _, dir := range r.expandWorkspaceGlob(rootDir, strings.Split(pattern, "/")) {
+			if isNegated {
+				delete(included, dir)
+			} else if !included[dir] {
+				included[dir] = true
+				order = append(order, dir)
+			}
+		}
+	}
+
+	workspaces := make(map[string]string)
+	for _, dir := range order {
+		if !included[dir] {
+			continue
+		}
+		json, ok := r.parseJSONFile(r.fs.Join(dir, "package.json"))
+		if !ok {
+			continue
+		}
+		if nameJSON, _, ok := getProperty(json, "name"); ok {
+			if name, ok := getString(nameJSON); ok && name != "" {
+				if r.debugLogs != nil {
+					r.debugLogs.addNote(fmt.Sprintf("Found workspace package %q in %q", name, dir))
+				}
+				workspaces[name] = dir
+			}
+		}
+	}
+	return workspaces
+}
+
+func (r resolverQuery) expandWorkspaceGlob(dir string, segments []string) []string {
+	if len(segments) == 0 || (len(segments) == 1 && segments[0] == "") {
+		return []string{dir}
+	}
+	segment, rest := segments[0], segments[1:]
+
+	// Literal path segments don't need a directory listing
+	if !strings.ContainsAny(segment, "*?[") {
+		return r.expandWorkspaceGlob(r.fs.Join(dir, segment), rest)
+	}
+
+	entries, err, _ := r.fs.ReadDirectory(dir)
+	if err != nil {
+		return nil
+	}
+
+	var results []string
+	if segment == "**" {
+		// "**" matches zero or more directories
+		results = append(results, r.expandWorkspaceGlob(dir, rest)...)
+	}
+	for _, base := range entries.SortedKeys() {
+		if base == "node_modules" || strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry, _ := entries.Get(base); entry == nil || entry.Kind(r.fs) != fs.DirEntry {
+			continue
+		}
+		child := r.fs.Join(dir, base)
+		if segment == "**" {
+			results = append(results, r.expandWorkspaceGlob(child, segments)...)
+		} else if ok, _ := path.Match(segment, base); ok {
+			results = append(results, r.expandWorkspaceGlob(child, rest)...)
+		}
+	}
+	return results
+}
+
+// Returns true if this is a bare package name without a subpath, such as
+// "foo" or "@scope/foo"
+func IsPackageName(name string) bool {
+	_, subpath, ok := esmParsePackageName(name)
+	return ok && subpath == "."
+}
@@ -0,0 +1,836 @@
+package runtime
+
+// These are small browser implementations of some of node's built-in modules.
+// They are used instead of the built-in module when "--node-polyfills" is
+// enabled and the platform is "browser". Each one is a CommonJS module so that
+// "require()" returns the same value that it does in node. The code is written
+// in ES5 so that it works with every target.
+//
+// The "process" and "Buffer" globals are also replaced with imports of these
+// modules. None of the polyfills may use either global themselves since that
+// would cause an import cycle.
+
+const NodePolyfillNamespace = "node-polyfill"
+
+var NodePolyfills = map[string]string{
+	"buffer":  bufferPolyfill,
+	"events":  eventsPolyfill,
+	"path":    pathPolyfill,
+	"process": processPolyfill,
+}
+
+// Each of these is injected into every file, and replaces the global variable
+// with the same name as the export
+var NodePolyfillGlobals = map[string]string{
+	"buffer-global":  "import { Buffer } from 'buffer'\nexport { Buffer }\n",
+	"process-global": "import process from 'process'\nexport { process }\n",
+}
+
+const eventsPolyfill = `
+function EventEmitter() {
+  if (!this._events || this._events === Object.getPrototypeOf(this)._events) this._events = Object.create(null)
+  this._maxListeners = this._maxListeners || undefined
+}
+
+var proto = EventEmitter.prototype
+proto._events = undefined
+proto._maxListeners = undefined
+EventEmitter.EventEmitter = EventEmitter
+EventEmitter.defaultMaxListeners = 10
+
+function eventsOf(emitter) {
+  if (!emitter._events) emitter._events = Object.create(null)
+  return emitter._events
+}
+
+function checkListener(listener) {
+  if (typeof listener !== 'function') throw new TypeError('The "listener" argument must be of type function')
+}
+
+function addListener(emitter, type, listener, prepend) {
+  checkListener(listener)
+  var events = eventsOf(emitter)
+  if (events.newListener) emitter.emit('newListener', type, listener.listener ? listener.listener : listener)
+  var list = events[type] || (events[type] = [])
+  if (prepend) list.unshift(listener)
+  else list.push(listener)
+  return emitter
+}
+
+function onceWrapper(emitter, type, listener) {
+  function wrapper() {
+    emitter.removeListener(type, wrapper)
+    return listener.apply(emitter, arguments)
+  }
+  wrapper.listener = listener
+  return wrapper
+}
+
+proto.setMaxListeners = function (n) {
+  this._maxListeners = n
+  return this
+}
+
+proto.getMaxListeners = function () {
+  return this._maxListeners === undefined ? EventEmitter.defaultMaxListeners : this._maxListeners
+}
+
+proto.on = proto.addListener = function (type, listener) {
+  return addListener(this, type, listener, false)
+}
+
+proto.prependListener = function (type, listener) {
+  return addListener(this, type, listener, true)
+}
+
+proto.once = function (type, listener) {
+  checkListener(listener)
+  return addListener(this, type, onceWrapper(this, type, listener), false)
+}
+
+proto.prependOnceListener = function (type, listener) {
+  checkListener(listener)
+  return addListener(this, type, onceWrapper(this, type, listener), true)
+}
+
+proto.off = proto.removeListener = function (type, listener) {
+  checkListener(listener)
+  var events = eventsOf(this)
+  var list = events[type]
+  if (!list) return this
+  for (var i = list.length - 1; i >= 0; i--) {
+    if (list[i] === listener || list[i].listener === listener) {
+      list.splice(i, 1)
+      if (!list.length) delete events[type]
+      if (events.removeListener) this.emit('removeListener', type, listener)
+      break
+    }
+  }
+  return this
+}
+
+proto.removeAllListeners = function (type) {
+  if (type === undefined) this._events = Object.create(null)
+  else delete eventsOf(this)[type]
+  return this
+}
+
+proto.emit = function (type) {
+  var list = eventsOf(this)[type]
+  if (!list) {
+    if (type === 'error') {
+      var err = arguments[1]
+      if (err instanceof Error) throw err
+      var wrapped = new Error('Unhandled error.' + (err === undefined ? '' : ' (' + err + ')'))
+      wrapped.context = err
+      throw wrapped
+    }
+    return false
+  }
+  var args = Array.prototype.slice.call(arguments, 1)
+  list = list.slice()
+  for (var i = 0; i < list.length; i++) list[i].apply(this, args)
+  return true
+}
+
+proto.listeners = function (type) {
+  var list = eventsOf(this)[type]
+  return list ? list.map(function (listener) { return listener.listener || listener }) : []
+}
+
+proto.rawListeners = function (type) {
+  var list = eventsOf(this)[type]
+  return list ? list.slice() : []
+}
+
+proto.listenerCount = function (type) {
+  var list = eventsOf(this)[type]
+  return list ? list.length : 0
+}
+
+proto.eventNames = function () {
+  var events = eventsOf(this)
+  return typeof Reflect !== 'undefined' ? Reflect.ownKeys(events) : Object.keys(events)
+}
+
+EventEmitter.listenerCount = function (emitter, type) {
+  return emitter.listenerCount(type)
+}
+
+EventEmitter.once = function (emitter, type) {
+  return new Promise(function (resolve, reject) {
+    function onError(err) {
+      emitter.removeListener(type, onEvent)
+      reject(err)
+    }
+    function onEvent() {
+      if (type !== 'error') emitter.removeListener('error', onError)
+      resolve(Array.prototype.slice.call(arguments))
+    }
+    emitter.once(type, onEvent)
+    if (type !== 'error') emitter.once('error', onError)
+  })
+}
+
+module.exports = EventEmitter
+`
+
+const processPolyfill = `
+var start = now()
+
+function now() {
+  return typeof performance !== 'undefined' && performance.now ? performance.now() : Date.now()
+}
+
+function queue(callback) {
+  if (typeof queueMicrotask === 'function') queueMicrotask(callback)
+  else Promise.resolve().then(callback)
+}
+
+function noop() {
+  return process
+}
+
+var process = module.exports = {
+  title: 'browser',
+  browser: true,
+  env: {},
+  argv: [],
+  execArgv: [],
+  version: '',
+  versions: {},
+  platform: 'browser',
+  release: { name: 'browser' },
+  pid: 1,
+  ppid: 0,
+  exitCode: undefined,
+  cwd: function () {
+    return '/'
+  },
+  chdir: function () {
+    throw new Error('process.chdir is not supported')
+  },
+  umask: function () {
+    return 0
+  },
+  nextTick: function (callback) {
+    var args = Array.prototype.slice.call(arguments, 1)
+    queue(function () {
+      callback.apply(null, args)
+    })
+  },
+  hrtime: function (previous) {
+    var time = now() / 1000
+    var seconds = Math.floor(time)
+    var nanoseconds = Math.floor((time - seconds) * 1e9)
+    if (previous) {
+      seconds -= previous[0]
+      nanoseconds -= previous[1]
+      if (nanoseconds < 0) {
+        seconds--
+        nanoseconds += 1e9
+      }
+    }
+    return [seconds, nanoseconds]
+  },
+  uptime: function () {
+    return (now() - start) / 1000
+  },
+  memoryUsage: function () {
+    return { rss: 0, heapTotal: 0, heapUsed: 0, external: 0, arrayBuffers: 0 }
+  },
+  emitWarning: function (warning) {
+    console.warn(warning)
+  },
+  binding: function () {
+    throw new Error('process.binding is not supported')
+  },
+  on: noop,
+  once: noop,
+  off: noop,
+  addListener: noop,
+  removeListener: noop,
+  removeAllListeners: noop,
+  prependListener: noop,
+  prependOnceListener: noop,
+  emit: function () {
+    return false
+  },
+  listeners: function () {
+    return []
+  }
+}
+`
+
+const pathPolyfill = `
+function assertPath(path) {
+  if (typeof path !== 'string') throw new TypeError('The "path" argument must be of type string. Received ' + typeof path)
+}
+
+function normalizeString(path, allowAboveRoot) {
+  var parts = path.split('/')
+  var result = []
+  for (var i = 0; i < parts.length; i++) {
+    var part = parts[i]
+    if (!part || part === '.') continue
+    if (part !== '..') result.push(part)
+    else if (result.length && result[result.length - 1] !== '..') result.pop()
+    else if (allowAboveRoot) result.push('..')
+  }
+  return result.join('/')
+}
+
+function resolve() {
+  var resolved = ''
+  var isAbsolute = false
+  for (var i = arguments.length - 1; i >= -1 && !isAbsolute; i--) {
+    var path = i >= 0 ? arguments[i] : '/'
+    assertPath(path)
+    if (!path) continue
+    resolved = path + '/' + resolved
+    isAbsolute = path.charAt(0) === '/'
+  }
+  resolved = normalizeString(resolved, !isAbsolute)
+  return (isAbsolute ? '/' : '') + resolved || '.'
+}
+
+function normalize(path) {
+  assertPath(path)
+  if (!path) return '.'
+  var isAbsolute = path.charAt(0) === '/'
+  var hasTrailingSlash = path.charAt(path.length - 1) === '/'
+  path = normalizeString(path, !isAbsolute)
+  if (!path && !isAbsolute) path = '.'
+  if (path && hasTrailingSlash) path += '/'
+  return (isAbsolute ? '/' : '') + path
+}
+
+function isAbsolute(path) {
+  assertPath(path)
+  return path.charAt(0) === '/'
+}
+
+function join() {
+  var parts = []
+  for (var i = 0; i < arguments.length; i++) {
+    assertPath(arguments[i])
+    if (arguments[i]) parts.push(arguments[i])
+  }
+  return parts.length ? normalize(parts.join('/')) : '.'
+}
+
+function relative(from, to) {
+  assertPath(from)
+  assertPath(to)
+  from = resolve(from)
+  to = resolve(to)
+  if (from === to) return ''
+  var fromParts = from.split('/').filter(Boolean)
+  var toParts = to.split('/').filter(Boolean)
+  var i = 0
+  while (i < fromParts.length && i < toParts.length && fromParts[i] === toParts[i]) i++
+  var result = []
+  for (var j = i; j < fromParts.length; j++) result.push('..')
+  return result.concat(toParts.slice(i)).join('/')
+}
+
+function dirname(path) {
+  assertPath(path)
+  if (!path) return '.'
+  var end = path.length
+  while (end > 1 && path.charAt(end - 1) === '/') end--
+  var slash = path.lastIndexOf('/', end - 1)
+  if (slash === -1) return '.'
+  while (slash > 0 && path.charAt(slash - 1) === '/') slash--
+  return slash === 0 ? '/' : path.slice(0, slash)
+}
+
+function basename(path, ext) {
+  assertPath(path)
+  var end = path.length
+  while (end > 0 && path.charAt(end - 1) === '/') end--
+  var base = path.slice(path.lastIndexOf('/', end - 1) + 1, end)
+  if (ext !== undefined && ext && base !== ext && base.slice(-ext.length) === ext) base = base.slice(0, -ext.length)
+  return base
+}
+
+function extname(path) {
+  var base = basename(path)
+  var dot = base.lastIndexOf('.')
+  return dot <= 0 || base === '..' ? '' : base.slice(dot)
+}
+
+function parse(path) {
+  assertPath(path)
+  var root = path.charAt(0) === '/' ? '/' : ''
+  var base = basename(path)
+  var ext = extname(path)
+  var dir = path.replace(/\/+$/, '').indexOf('/') === -1 ? root : dirname(path)
+  return { root: root, dir: dir, base: base, ext: ext, name: ext ? base.slice(0, -ext.length) : base }
+}
+
+function format(object) {
+  var dir = object.dir || object.root
+  var base = object.base || (object.name || '') + (object.ext || '')
+  if (!dir) return base
+  return dir === object.root ? dir + base : dir + '/' + base
+}
+
+module.exports = {
+  sep: '/',
+  delimiter: ':',
+  resolve: resolve,
+  normalize: normalize,
+  isAbsolute: isAbsolute,
+  join: join,
+  relative: relative,
+  dirname: dirname,
+  basename: basename,
+  extname: extname,
+  parse: parse,
+  format: format,
+  toNamespacedPath: function (path) {
+    return path
+  }
+}
+module.exports.posix = module.exports
+`
+
+const bufferPolyfill = `
+var K_MAX_LENGTH = 0x7fffffff
+
+function createBuffer(length) {
+  if (length > K_MAX_LENGTH) throw new RangeError('The value "' + length + '" is invalid for option "size"')
+  var buf = new Uint8Array(length)
+  Object.setPrototypeOf(buf, Buffer.prototype)
+  return buf
+}
+
+function Buffer(value, encodingOrOffset, length) {
+  if (typeof value === 'number') return alloc(value)
+  return from(value, encodingOrOffset, length)
+}
+
+Object.setPrototypeOf(Buffer.prototype, Uint8Array.prototype)
+Object.setPrototypeOf(Buffer, Uint8Array)
+var proto = Buffer.prototype
+
+function normalizeEncoding(encoding) {
+  var lower = String(encoding || 'utf8').toLowerCase()
+  switch (lower) {
+    case 'utf8': case 'utf-8': return 'utf8'
+    case 'hex': case 'base64': case 'base64url': case 'ascii': return lower
+    case 'latin1': case 'binary': return 'latin1'
+    case 'ucs2': case 'ucs-2': case 'utf16le': case 'utf-16le': return 'utf16le'
+  }
+  throw new TypeError('Unknown encoding: ' + encoding)
+}
+
+function bytesFromString(string, encoding) {
+  var bytes, i
+  switch (normalizeEncoding(encoding)) {
+    case 'utf8':
+      return new TextEncoder().encode(string)
+    case 'hex':
+      bytes = []
+      for (i = 0; i + 1 < string.length; i += 2) {
+        var byte = parseInt(string.slice(i, i + 2), 16)
+        if (isNaN(byte)) break
+        bytes.push(byte)
+      }
+      return bytes
+    case 'base64':
+    case 'base64url':
+      string = string.replace(/-/g, '+').replace(/_/g, '/').replace(/[^A-Za-z0-9+/]/g, '')
+      while (string.length % 4) string += '='
+      return bytesFromString(atob(string), 'latin1')
+    case 'latin1':
+    case 'ascii':
+      bytes = []
+      for (i = 0; i < string.length; i++) bytes.push(string.charCodeAt(i) & 255)
+      return bytes
+    case 'utf16le':
+      bytes = []
+      for (i = 0; i < string.length; i++) {
+        var code = string.charCodeAt(i)
+        bytes.push(code & 255, code >> 8)
+      }
+      return bytes
+  }
+}
+
+function latin1FromBytes(bytes) {
+  var result = ''
+  for (var i = 0; i < bytes.length; i += 4096) {
+    result += String.fromCharCode.apply(null, Array.prototype.slice.call(bytes, i, i + 4096))
+  }
+  return result
+}
+
+function stringFromBytes(bytes, encoding) {
+  var result = '', i
+  switch (normalizeEncoding(encoding)) {
+    case 'utf8':
+      return new TextDecoder().decode(bytes)
+    case 'hex':
+      for (i = 0; i < bytes.length; i++) result += (bytes[i] < 16 ? '0' : '') + bytes[i].toString(16)
+      return result
+    case 'base64':
+      return btoa(latin1FromBytes(bytes))
+    case 'base64url':
+      return btoa(latin1FromBytes(bytes)).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+    case 'latin1':
+      return latin1FromBytes(bytes)
+    case 'ascii':
+      for (i = 0; i < bytes.length; i++) result += String.fromCharCode(bytes[i] & 127)
+      return result
+    case 'utf16le':
+      for (i = 0; i + 1 < bytes.length; i += 2) result += String.fromCharCode(bytes[i] | (bytes[i + 1] << 8))
+      return result
+  }
+}
+
+function fromBytes(bytes) {
+  var buf = createBuffer(bytes.length)
+  for (var i = 0; i < bytes.length; i++) buf[i] = bytes[i]
+  return buf
+}
+
+function from(value, encodingOrOffset, length) {
+  if (typeof value === 'string') return fromBytes(bytesFromString(value, encodingOrOffset))
+  if (value instanceof ArrayBuffer || (typeof SharedArrayBuffer !== 'undefined' && value instanceof SharedArrayBuffer)) {
+    var offset = encodingOrOffset >>> 0
+    var buf = new Uint8Array(value, offset, length === undefined ? value.byteLength - offset : length >>> 0)
+    Object.setPrototypeOf(buf, Buffer.prototype)
+    return buf
+  }
+  if (value && typeof value === 'object') {
+    if (value.type === 'Buffer' && Array.isArray(value.data)) return fromBytes(value.data)
+    if (typeof value.length === 'number') return fromBytes(value)
+  }
+  throw new TypeError('The first argument must be of type string, Buffer, ArrayBuffer, Array, or Array-like Object')
+}
+
+function alloc(size, fill, encoding) {
+  var buf = createBuffer(size)
+  if (fill !== undefined && fill !== 0) buf.fill(fill, 0, size, encoding)
+  return buf
+}
+
+Buffer.from = from
+Buffer.of = function () {
+  return fromBytes(arguments)
+}
+Buffer.alloc = alloc
+Buffer.allocUnsafe = Buffer.allocUnsafeSlow = function (size) {
+  return createBuffer(size)
+}
+Buffer.poolSize = 8192
+
+Buffer.isBuffer = function (value) {
+  return value instanceof Buffer
+}
+
+Buffer.isEncoding = function (encoding) {
+  try {
+    return typeof encoding === 'string' && !!normalizeEncoding(encoding)
+  } catch (e) {
+    return false
+  }
+}
+
+Buffer.byteLength = function (value, encoding) {
+  if (typeof value === 'string') return bytesFromString(value, encoding).length
+  return value.byteLength
+}
+
+Buffer.concat = function (list, totalLength) {
+  var i
+  if (totalLength === undefined) {
+    totalLength = 0
+    for (i = 0; i < list.length; i++) totalLength += list[i].length
+  }
+  var buf = createBuffer(totalLength)
+  var offset = 0
+  for (i = 0; i < list.length && offset < totalLength; i++) {
+    var item = list[i]
+    if (offset + item.length > totalLength) item = item.subarray(0, totalLength - offset)
+    buf.set(item, offset)
+    offset += item.length
+  }
+  return buf
+}
+
+Buffer.compare = function (a, b) {
+  var length = Math.min(a.length, b.length)
+  for (var i = 0; i < length; i++) {
+    if (a[i] !== b[i]) return a[i] < b[i] ? -1 : 1
+  }
+  return a.length < b.length ? -1 : a.length > b.length ? 1 : 0
+}
+
+proto._isBuffer = true
+
+proto.toString = function (encoding, start, end) {
+  start = start === undefined ? 0 : Math.max(start, 0)
+  end = end === undefined ? this.length : Math.min(end, this.length)
+  if (end <= start) return ''
+  return stringFromBytes(this.subarray(start, end), encoding)
+}
+
+proto.toLocaleString = proto.toString
+
+proto.toJSON = function () {
+  return { type: 'Buffer', data: Array.prototype.slice.call(this) }
+}
+
+proto.equals = function (other) {
+  return Buffer.compare(this, other) === 0
+}
+
+proto.compare = function (other) {
+  return Buffer.compare(this, other)
+}
+
+proto.slice = function (start, end) {
+  return this.subarray(start, end)
+}
+
+proto.copy = function (target, targetStart, sourceStart, sourceEnd) {
+  targetStart = targetStart || 0
+  sourceStart = sourceStart || 0
+  sourceEnd = sourceEnd === undefined ? this.length : sourceEnd
+  var source = this.subarray(sourceStart, Math.min(sourceEnd, sourceStart + target.length - targetStart))
+  target.set(source, targetStart)
+  return source.length
+}
+
+proto.write = function (string, offset, length, encoding) {
+  if (typeof offset === 'string') encoding = offset, offset = 0, length = undefined
+  else if (typeof length === 'string') encoding = length, length = undefined
+  offset = offset || 0
+  var bytes = bytesFromString(string, encoding)
+  var count = Math.min(bytes.length, this.length - offset, length === undefined ? Infinity : length)
+  for (var i = 0; i < count; i++) this[offset + i] = bytes[i]
+  return count
+}
+
+var fillBytes = Uint8Array.prototype.fill
+proto.fill = function (value, start, end, encoding) {
+  if (typeof start === 'string') encoding = start, start = 0, end = this.length
+  else if (typeof end === 'string') encoding = end, end = this.length
+  start = start === undefined ? 0 : start
+  end = end === undefined ? this.length : end
+  if (typeof value === 'number') return fillBytes.call(this, value & 255, start, end)
+  var bytes = typeof value === 'string' ? bytesFromString(value, encoding) : value
+  if (!bytes.length) return fillBytes.call(this, 0, start, end)
+  for (var i = start; i < end; i++) this[i] = bytes[(i - start) % bytes.length]
+  return this
+}
+
+var indexOfByte = Uint8Array.prototype.indexOf
+proto.indexOf = function (value, byteOffset, encoding) {
+  if (typeof value === 'number') return indexOfByte.call(this, value & 255, byteOffset)
+  var bytes = typeof value === 'string' ? bytesFromString(value, encoding) : value
+  var i = byteOffset === undefined ? 0 : byteOffset < 0 ? Math.max(this.length + byteOffset, 0) : byteOffset
+  for (; i + bytes.length <= this.length; i++) {
+    for (var j = 0; j < bytes.length && this[i + j] === bytes[j]; j++);
+    if (j === bytes.length) return i
+  }
+  return -1
+}
+
+proto.includes = function (value, byteOffset, encoding) {
+  return this.indexOf(value, byteOffset, encoding) !== -1
+}
+
+proto.lastIndexOf = function (value, byteOffset, encoding) {
+  var bytes = typeof value === 'number' ? [value & 255] : typeof value === 'string' ? bytesFromString(value, encoding) : value
+  var i = byteOffset === undefined ? this.length - bytes.length : byteOffset < 0 ? this.length + byteOffset : byteOffset
+  for (i = Math.min(i, this.length - bytes.length); i >= 0; i--) {
+    for (var j = 0; j < bytes.length && this[i + j] === bytes[j]; j++);
+    if (j === bytes.length) return i
+  }
+  return -1
+}
+
+function swap(buf, size) {
+  if (buf.length % size) throw new RangeError('Buffer size must be a multiple of ' + size * 8 + '-bits')
+  for (var i = 0; i < buf.length; i += size) {
+    for (var a = i, b = i + size - 1; a < b; a++, b--) {
+      var byte = buf[a]
+      buf[a] = buf[b]
+      buf[b] = byte
+    }
+  }
+  return buf
+}
+
+proto.swap16 = function () {
+  return swap(this, 2)
+}
+
+proto.swap32 = function () {
+  return swap(this, 4)
+}
+
+proto.swap64 = function () {
+  return swap(this, 8)
+}
+
+// The accessors below throw the same errors as node when the offset or the
+// value is out of range instead of silently reading or writing garbage
+function checkRange(name, value, min, max) {
+  if (value < min || value > max) {
+    throw new RangeError('The value of "' + name + '" is out of range. It must be >= ' + min + ' and <= ' + max + '. Received ' + value)
+  }
+}
+
+function checkOffset(buf, offset, size) {
+  if (offset === undefined) offset = 0
+  if (typeof offset !== 'number') throw new TypeError('The "offset" argument must be of type number. Received ' + typeof offset)
+  if (offset !== Math.floor(offset)) throw new RangeError('The value of "offset" is out of range. It must be an integer. Received ' + offset)
+  if (buf.length < size) throw new RangeError('Attempt to access memory outside buffer bounds')
+  checkRange('offset', offset, 0, buf.length - size)
+  return offset
+}
+
+function checkByteLength(byteLength) {
+  if (typeof byteLength !== 'number') throw new TypeError('The "byteLength" argument must be of type number. Received ' + typeof byteLength)
+  checkRange('byteLength', byteLength, 1, 6)
+  return byteLength
+}
+
+function readUInt(buf, offset, byteLength, littleEndian) {
+  offset = checkOffset(buf, offset, byteLength)
+  var value = 0
+  for (var i = 0; i < byteLength; i++) value = value * 256 + buf[offset + (littleEndian ? byteLength - 1 - i : i)]
+  return value
+}
+
+function readInt(buf, offset, byteLength, littleEndian) {
+  var value = readUInt(buf, offset, byteLength, littleEndian)
+  var limit = Math.pow(2, byteLength * 8 - 1)
+  return value >= limit ? value - limit * 2 : value
+}
+
+function writeUInt(buf, value, offset, byteLength, littleEndian) {
+  value = +value
+  checkRange('value', value, 0, Math.pow(2, byteLength * 8) - 1)
+  offset = checkOffset(buf, offset, byteLength)
+  for (var i = 0; i < byteLength; i++) {
+    buf[offset + (littleEndian ? i : byteLength - 1 - i)] = value % 256
+    value = Math.floor(value / 256)
+  }
+  return offset + byteLength
+}
+
+function writeInt(buf, value, offset, byteLength, littleEndian) {
+  value = +value
+  var limit = Math.pow(2, byteLength * 8 - 1)
+  checkRange('value', value, -limit, limit - 1)
+  offset = checkOffset(buf, offset, byteLength)
+  if (value < 0) value += limit * 2
+  for (var i = 0; i < byteLength; i++) {
+    buf[offset + (littleEndian ? i : byteLength - 1 - i)] = value % 256
+    value = Math.floor(value / 256)
+  }
+  return offset + byteLength
+}
+
+function readFloat(buf, offset, size, littleEndian) {
+  offset = checkOffset(buf, offset, size)
+  var view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength)
+  return size === 4 ? view.getFloat32(offset, littleEndian) : view.getFloat64(offset, littleEndian)
+}
+
+function writeFloat(buf, value, offset, size, littleEndian) {
+  offset = checkOffset(buf, offset, size)
+  var view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength)
+  if (size === 4) view.setFloat32(offset, +value, littleEndian)
+  else view.setFloat64(offset, +value, littleEndian)
+  return offset + size
+}
+
+proto.readUIntLE = function (offset, byteLength) { return readUInt(this, offset, checkByteLength(byteLength), true) }
+proto.readUIntBE = function (offset, byteLength) { return readUInt(this, offset, checkByteLength(byteLength), false) }
+proto.readUInt8 = function (offset) { return readUInt(this, offset, 1, false) }
+proto.readUInt16LE = function (offset) { return readUInt(this, offset, 2, true) }
+proto.readUInt16BE = function (offset) { return readUInt(this, offset, 2, false) }
+proto.readUInt32LE = function (offset) { return readUInt(this, offset, 4, true) }
+proto.readUInt32BE = function (offset) { return readUInt(this, offset, 4, false) }
+
+proto.readIntLE = function (offset, byteLength) { return readInt(this, offset, checkByteLength(byteLength), true) }
+proto.readIntBE = function (offset, byteLength) { return readInt(this, offset, checkByteLength(byteLength), false) }
+proto.readInt8 = function (offset) { return readInt(this, offset, 1, false) }
+proto.readInt16LE = function (offset) { return readInt(this, offset, 2, true) }
+proto.readInt16BE = function (offset) { return readInt(this, offset, 2, false) }
+proto.readInt32LE = function (offset) { return readInt(this, offset, 4, true) }
+proto.readInt32BE = function (offset) { return readInt(this, offset, 4, false) }
+
+proto.readFloatLE = function (offset) { return readFloat(this, offset, 4, true) }
+proto.readFloatBE = function (offset) { return readFloat(this, offset, 4, false) }
+proto.readDoubleLE = function (offset) { return readFloat(this, offset, 8, true) }
+proto.readDoubleBE = function (offset) { return readFloat(this, offset, 8, false) }
+
+proto.writeUIntLE = function (value, offset, byteLength) { return writeUInt(this, value, offset, checkByteLength(byteLength), true) }
+proto.writeUIntBE = function (value, offset, byteLength) { return writeUInt(this, value, offset, checkByteLength(byteLength), false) }
+proto.writeUInt8 = function (value, offset) { return writeUInt(this, value, offset, 1, false) }
+proto.writeUInt16LE = function (value, offset) { return writeUInt(this, value, offset, 2, true) }
+proto.writeUInt16BE = function (value, offset) { return writeUInt(this, value, offset, 2, false) }
+proto.writeUInt32LE = function (value, offset) { return writeUInt(this, value, offset, 4, true) }
+proto.writeUInt32BE = function (value, offset) { return writeUInt(this, value, offset, 4, false) }
+
+proto.writeIntLE = function (value, offset, byteLength) { return writeInt(this, value, offset, checkByteLength(byteLength), true) }
+proto.writeIntBE = function (value, offset, byteLength) { return writeInt(this, value, offset, checkByteLength(byteLength), false) }
+proto.writeInt8 = function (value, offset) { return writeInt(this, value, offset, 1, false) }
+proto.writeInt16LE = function (value, offset) { return writeInt(this, value, offset, 2, true) }
+proto.writeInt16BE = function (value, offset) { return writeInt(this, value, offset, 2, false) }
+proto.writeInt32LE = function (value, offset) { return writeInt(this, value, offset, 4, true) }
+proto.writeInt32BE = function (value, offset) { return writeInt(this, value, offset, 4, false) }
+
+proto.writeFloatLE = function (value, offset) { return writeFloat(this, value, offset, 4, true) }
+proto.writeFloatBE = function (value, offset) { return writeFloat(this, value, offset, 4, false) }
+proto.writeDoubleLE = function (value, offset) { return writeFloat(this, value, offset, 8, true) }
+proto.writeDoubleBE = function (value, offset) { return writeFloat(this, value, offset, 8, false) }
+
+// 64-bit integers need "BigInt", which older browsers don't have
+if (typeof BigInt === 'function' && DataView.prototype.getBigUint64) {
+  var readBigInt = function (buf, offset, signed, littleEndian) {
+    offset = checkOffset(buf, offset, 8)
+    var view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength)
+    return signed ? view.getBigInt64(offset, littleEndian) : view.getBigUint64(offset, littleEndian)
+  }
+  var writeBigInt = function (buf, value, offset, signed, littleEndian) {
+    if (typeof value !== 'bigint') throw new TypeError('The "value" argument must be of type bigint. Received ' + typeof value)
+    var limit = BigInt(signed ? '0x8000000000000000' : '0x10000000000000000')
+    checkRange('value', value, signed ? -limit : BigInt(0), limit - BigInt(1))
+    offset = checkOffset(buf, offset, 8)
+    var view = new DataView(buf.buffer, buf.byteOffset, buf.byteLength)
+    if (signed) view.setBigInt64(offset, value, littleEndian)
+    else view.setBigUint64(offset, value, littleEndian)
+    return offset + 8
+  }
+  proto.readBigUInt64LE = function (offset) { return readBigInt(this, offset, false, true) }
+  proto.readBigUInt64BE = function (offset) { return readBigInt(this, offset, false, false) }
+  proto.readBigInt64LE = function (offset) { return readBigInt(this, offset, true, true) }
+  proto.readBigInt64BE = function (offset) { return readBigInt(this, offset, true, false) }
+  proto.writeBigUInt64LE = function (value, offset) { return writeBigInt(this, value, offset, false, true) }
+  proto.writeBigUInt64BE = function (value, offset) { return writeBigInt(this, value, offset, false, false) }
+  proto.writeBigInt64LE = function (value, offset) { return writeBigInt(this, value, offset, true, true) }
+  proto.writeBigInt64BE = function (value, offset) { return writeBigInt(this, value, offset, true, false) }
+}
+
+// Node also has lowercase "Uint" aliases for the unsigned accessors
+Object.keys(proto).forEach(function (name) {
+  if (name.indexOf('UInt') !== -1) proto[name.replace('UInt', 'Uint')] = proto[name]
+})
+
+module.exports = {
+  Buffer: Buffer,
+  SlowBuffer: Buffer,
+  INSPECT_MAX_BYTES: 50,
+  kMaxLength: K_MAX_LENGTH,
+  constants: { MAX_LENGTH: K_MAX_LENGTH, MAX_STRING_LENGTH: 0x1fffffe8 }
+}
+`
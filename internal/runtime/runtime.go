@@ -19,7 +19,7 @@ func CanUseES6(unsupportedFeatures compat.JSFeature) bool {
 	return !unsupportedFeatures.Has(compat.Let) && !unsupportedFeatures.Has(compat.ForOf)
 }
 
-func code(isES6 bool) string {
+func code(isES6 bool, hasLoadScript bool) string {
 	// Note: These helper functions used to be named similar things to the helper
 	// functions from the TypeScript compiler. However, people sometimes use these
 	// two projects in combination and TypeScript's implementation of these helpers
@@ -66,7 +66,7 @@ func code(isES6 bool) string {
 	// transforming destructuring to ES5 isn't even supported so it's ok.
 	text := `
 		var __create = Object.create
-		var __freeze = Object.freeze
+		export var __freeze = Object.freeze
 		var __defProp = Object.defineProperty
 		var __defProps = Object.defineProperties
 		var __getOwnPropDesc = Object.getOwnPropertyDescriptor // Note: can return "undefined" due to a Safari bug
@@ -134,6 +134,43 @@ func code(isES6 bool) string {
 				if (typeof require !== 'undefined') return require.apply(this, arguments)
 				throw new Error('Dynamic require of "' + x + '" is not supported')
 			})
+	`
+
+	// This is used for "import()" expressions of external files in browsers
+	// that don't support "import()". It's only present with the "script"
+	// dynamic import loader so that it doesn't change the runtime for other
+	// builds.
+	if hasLoadScript {
+		text += `
+			// Scripts loaded this way can't export anything, so this resolves to an
+			// empty module once the script has run. Relative paths are resolved
+			// against the URL of the current script if it's known, which is only
+			// the case for non-module scripts. The URL is read inside the call
+			// instead of being passed as an argument because arguments with side
+			// effects would keep this from being removed when it's unused.
+			export var __loadScript = /* @__PURE__ */ (() => {
+				var base = typeof document !== 'undefined' && document.currentScript && document.currentScript.src
+				return (src, type) => new Promise((resolve, reject) => {
+					var script = document.createElement('script')
+					if (type) script.type = type
+					script.onload = () => resolve(__markAsModule({}))
+					script.onerror = () => reject(new Error('Failed to load script "' + src + '"'))
+					script.src = base ? new URL(src, base).href : src
+					document.head.appendChild(script)
+				})
+			})()
+		`
+	}
+
+	text += `
+		// This is used for "require()" and "import()" calls with a partially-dynamic
+		// path when "--bundle-dynamic-paths" is enabled. The map has a function for
+		// each bundled file that the path could refer to.
+		export var __glob = map => path => {
+			var fn = map[path]
+			if (fn) return fn()
+			throw new Error('Module not found in bundle: ' + path)
+		}
 
 		// For object rest patterns
 		export var __restKey = key => typeof key === 'symbol' ? key : key + ''
@@ -343,21 +380,22 @@ func code(isES6 bool) string {
 	return text
 }
 
-var ES6Source = logger.Source{
-	Index:          SourceIndex,
-	KeyPath:        logger.Path{Text: "<runtime>"},
-	PrettyPath:     "<runtime>",
-	IdentifierName: "runtime",
-	Contents:       code(true /* isES6 */),
+func makeSource(isES6 bool, hasLoadScript bool) logger.Source {
+	return logger.Source{
+		Index:          SourceIndex,
+		KeyPath:        logger.Path{Text: "<runtime>"},
+		PrettyPath:     "<runtime>",
+		IdentifierName: "runtime",
+		Contents:       code(isES6, hasLoadScript),
+	}
 }
 
-var ES5Source = logger.Source{
-	Index:          SourceIndex,
-	KeyPath:        logger.Path{Text: "<runtime>"},
-	PrettyPath:     "<runtime>",
-	IdentifierName: "runtime",
-	Contents:       code(false /* isES6 */),
-}
+var ES6Source = makeSource(true /* isES6 */, false /* hasLoadScript */)
+var ES5Source = makeSource(false /* isES6 */, false /* hasLoadScript */)
+
+// These are used with "--dynamic-import-loader=script"
+var ES6SourceWithLoadScript = makeSource(true /* isES6 */, true /* hasLoadScript */)
+var ES5SourceWithLoadScript = makeSource(false /* isES6 */, true /* hasLoadScript */)
 
 // The TypeScript decorator transform behaves similar to the official
 // TypeScript compiler.
@@ -50,6 +50,9 @@ let mustBeStringOrObject = (value: string | Object | undefined): string | null =
 let mustBeStringOrArray = (value: string | string[] | undefined): string | null =>
   typeof value === 'string' || Array.isArray(value) ? null : 'a string or an array';
 
+let mustBeStringOrInteger = (value: string | number | undefined): string | null =>
+  typeof value === 'string' || typeof value === 'number' && value === (value | 0) ? null : 'a string or an integer';
+
 let mustBeStringOrUint8Array = (value: string | Uint8Array | undefined): string | null =>
   typeof value === 'string' || value instanceof Uint8Array ? null : 'a string or a Uint8Array';
 
@@ -83,8 +86,44 @@ export function validateInitializeOptions(options: types.InitializeOptions): typ
   };
 }
 
+function validateUpdateOptions(options: types.UpdateOptions): Omit<protocol.RebuildUpdateRequest, 'command' | 'rebuildID'> {
+  let keys: OptionKeys = Object.create(null);
+  let logLevel = getFlag(options, keys, 'logLevel', mustBeString);
+  let define = getFlag(options, keys, 'define', mustBeObject);
+  let banner = getFlag(options, keys, 'banner', mustBeObject);
+  let drop = getFlag(options, keys, 'drop', mustBeArray);
+  checkForInvalidFlags(options, keys, 'in update() call');
+  let result: Omit<protocol.RebuildUpdateRequest, 'command' | 'rebuildID'> = {};
+  if (logLevel !== void 0) result.logLevel = logLevel;
+  if (define) {
+    result.define = {};
+    for (let key in define) {
+      if (typeof define[key] !== 'string') throw new Error(`Expected the value of define ${JSON.stringify(key)} to be a string`);
+      result.define[key] = define[key];
+    }
+  }
+  if (banner) {
+    result.banner = {};
+    for (let type in banner) result.banner[type] = banner[type] + '';
+  }
+  if (drop) result.drop = drop.map(what => what + '');
+  return result;
+}
+
 type CommonOptions = types.BuildOptions | types.TransformOptions;
 
+// Go's regular expressions take flags inline instead of after the pattern.
+// The "g" and "u" flags don't change which strings match since Go always
+// matches whole code points.
+function regExpWithInlineFlags(regExp: RegExp, name: string): string {
+  let inlineFlags = '';
+  for (let flag of regExp.flags) {
+    if (flag === 'i' || flag === 'm' || flag === 's') inlineFlags += flag;
+    else if (flag !== 'g' && flag !== 'u') throw new Error(`The regular expression flag "${flag}" is not supported in "${name}"`);
+  }
+  return inlineFlags ? `(?${inlineFlags})${regExp.source}` : regExp.source;
+}
+
 function pushLogFlags(flags: string[], options: CommonOptions, keys: OptionKeys, isTTY: boolean, logLevelDefault: types.LogLevel): void {
   let color = getFlag(options, keys, 'color', mustBeBoolean);
   let logLevel = getFlag(options, keys, 'logLevel', mustBeString);
@@ -98,46 +137,93 @@ function pushLogFlags(flags: string[], options: CommonOptions, keys: OptionKeys,
 
 function pushCommonFlags(flags: string[], options: CommonOptions, keys: OptionKeys): void {
   let legalComments = getFlag(options, keys, 'legalComments', mustBeString);
+  let annotations = getFlag(options, keys, 'annotations', mustBeString);
+  let magicComments = getFlag(options, keys, 'magicComments', mustBeString);
+  let lineEnding = getFlag(options, keys, 'lineEnding', mustBeString);
+  let indent = getFlag(options, keys, 'indent', mustBeStringOrInteger);
   let sourceRoot = getFlag(options, keys, 'sourceRoot', mustBeString);
   let sourcesContent = getFlag(options, keys, 'sourcesContent', mustBeBoolean);
+  let sourcemapBanners = getFlag(options, keys, 'sourcemapBanners', mustBeBoolean);
   let target = getFlag(options, keys, 'target', mustBeStringOrArray);
   let format = getFlag(options, keys, 'format', mustBeString);
   let globalName = getFlag(options, keys, 'globalName', mustBeString);
+  let externalHelpers = getFlag(options, keys, 'externalHelpers', mustBeString);
   let minify = getFlag(options, keys, 'minify', mustBeBoolean);
   let minifySyntax = getFlag(options, keys, 'minifySyntax', mustBeBoolean);
   let minifyWhitespace = getFlag(options, keys, 'minifyWhitespace', mustBeBoolean);
   let minifyIdentifiers = getFlag(options, keys, 'minifyIdentifiers', mustBeBoolean);
+  let minifySeed = getFlag(options, keys, 'minifySeed', mustBeString);
   let charset = getFlag(options, keys, 'charset', mustBeString);
+  let charsetEscape = getFlag(options, keys, 'charsetEscape', mustBeArray);
+  let identifierCharset = getFlag(options, keys, 'identifierCharset', mustBeString);
+  let identifierNormalization = getFlag(options, keys, 'identifierNormalization', mustBeString);
   let treeShaking = getFlag(options, keys, 'treeShaking', mustBeBoolean);
   let ignoreAnnotations = getFlag(options, keys, 'ignoreAnnotations', mustBeBoolean);
+  let inferPure = getFlag(options, keys, 'inferPure', mustBeBoolean);
+  let recoverSyntaxErrors = getFlag(options, keys, 'recoverSyntaxErrors', mustBeBoolean);
   let jsx = getFlag(options, keys, 'jsx', mustBeString);
   let jsxFactory = getFlag(options, keys, 'jsxFactory', mustBeString);
   let jsxFragment = getFlag(options, keys, 'jsxFragment', mustBeString);
+  let jsxImportSource = getFlag(options, keys, 'jsxImportSource', mustBeString);
+  let tsVersion = getFlag(options, keys, 'tsVersion', mustBeString);
+  let tsEnums = getFlag(options, keys, 'tsEnums', mustBeString);
   let define = getFlag(options, keys, 'define', mustBeObject);
+  let featureFlags = getFlag(options, keys, 'featureFlags', mustBeObject);
+  let supported = getFlag(options, keys, 'supported', mustBeObject);
   let pure = getFlag(options, keys, 'pure', mustBeArray);
+  let drop = getFlag(options, keys, 'drop', mustBeArray);
   let keepNames = getFlag(options, keys, 'keepNames', mustBeBoolean);
+  let pragmas = getFlag(options, keys, 'pragmas', mustBeArray);
+  let preserveComments = getFlag(options, keys, 'preserveComments', mustBeRegExp);
+  let mangleProps = getFlag(options, keys, 'mangleProps', mustBeRegExp);
+  let reserveProps = getFlag(options, keys, 'reserveProps', mustBeRegExp);
+  let stripIf = getFlag(options, keys, 'stripIf', mustBeArray);
+  let stripBetween = getFlag(options, keys, 'stripBetween', mustBeObject);
 
   if (legalComments) flags.push(`--legal-comments=${legalComments}`);
+  if (annotations) flags.push(`--annotations=${annotations}`);
+  if (magicComments) flags.push(`--magic-comments=${magicComments}`);
+  if (lineEnding) flags.push(`--line-ending=${lineEnding}`);
+  if (indent !== void 0) flags.push(`--indent=${indent}`);
   if (sourceRoot !== void 0) flags.push(`--source-root=${sourceRoot}`);
   if (sourcesContent !== void 0) flags.push(`--sources-content=${sourcesContent}`);
+  if (sourcemapBanners) flags.push(`--sourcemap-banners`);
   if (target) {
     if (Array.isArray(target)) flags.push(`--target=${Array.from(target).map(validateTarget).join(',')}`)
     else flags.push(`--target=${validateTarget(target)}`)
   }
   if (format) flags.push(`--format=${format}`);
   if (globalName) flags.push(`--global-name=${globalName}`);
+  if (externalHelpers) flags.push(`--external-helpers=${externalHelpers}`);
 
   if (minify) flags.push('--minify');
   if (minifySyntax) flags.push('--minify-syntax');
   if (minifyWhitespace) flags.push('--minify-whitespace');
   if (minifyIdentifiers) flags.push('--minify-identifiers');
+  if (minifySeed !== void 0) flags.push(`--minify-seed=${minifySeed}`);
   if (charset) flags.push(`--charset=${charset}`);
+  if (charsetEscape) {
+    let values: string[] = [];
+    for (let value of charsetEscape) {
+      value += '';
+      if (value.indexOf(',') >= 0) throw new Error(`Invalid charset escape: ${value}`);
+      values.push(value);
+    }
+    flags.push(`--charset-escape=${values.join(',')}`);
+  }
+  if (identifierCharset) flags.push(`--charset-identifiers=${identifierCharset}`);
+  if (identifierNormalization) flags.push(`--charset-normalization=${identifierNormalization}`);
   if (treeShaking !== void 0) flags.push(`--tree-shaking=${treeShaking}`);
   if (ignoreAnnotations) flags.push(`--ignore-annotations`);
+  if (inferPure) flags.push(`--infer-pure`);
+  if (recoverSyntaxErrors) flags.push(`--recover-syntax-errors`);
 
   if (jsx) flags.push(`--jsx=${jsx}`);
   if (jsxFactory) flags.push(`--jsx-factory=${jsxFactory}`);
   if (jsxFragment) flags.push(`--jsx-fragment=${jsxFragment}`);
+  if (jsxImportSource) flags.push(`--jsx-import-source=${jsxImportSource}`);
+  if (tsVersion) flags.push(`--ts-version=${tsVersion}`);
+  if (tsEnums) flags.push(`--ts-enums=${tsEnums}`);
 
   if (define) {
     for (let key in define) {
@@ -145,8 +231,36 @@ function pushCommonFlags(flags: string[], options: CommonOptions, keys: OptionKe
       flags.push(`--define:${key}=${define[key]}`);
     }
   }
+  if (featureFlags) {
+    for (let key in featureFlags) {
+      if (key.indexOf('=') >= 0) throw new Error(`Invalid feature flag: ${key}`);
+      let value = featureFlags[key];
+      if (typeof value !== 'boolean') throw new Error(`Expected the value of feature flag ${JSON.stringify(key)} to be a boolean`);
+      flags.push(`--feature:${key}=${value}`);
+    }
+  }
+  if (supported) {
+    for (let key in supported) {
+      if (key.indexOf('=') >= 0) throw new Error(`Invalid supported feature: ${key}`);
+      let value = supported[key];
+      if (typeof value !== 'boolean') throw new Error(`Expected the value of supported feature ${JSON.stringify(key)} to be a boolean`);
+      flags.push(`--supported:${key}=${value}`);
+    }
+  }
   if (pure) for (let fn of pure) flags.push(`--pure:${fn}`);
+  if (drop) for (let what of drop) flags.push(`--drop:${what}`);
   if (keepNames) flags.push(`--keep-names`);
+  if (pragmas) for (let name of pragmas) flags.push(`--pragma:${name}`);
+  if (preserveComments) flags.push(`--preserve-comments=${regExpWithInlineFlags(preserveComments, 'preserveComments')}`);
+  if (mangleProps) flags.push(`--mangle-props=${mangleProps.source}`);
+  if (reserveProps) flags.push(`--reserve-props=${reserveProps.source}`);
+  if (stripIf) for (let name of stripIf) flags.push(`--strip-if:${name}`);
+  if (stripBetween) {
+    for (let start in stripBetween) {
+      if (start.indexOf('=') >= 0) throw new Error(`Invalid strip marker: ${start}`);
+      flags.push(`--strip-between:${start}=${stripBetween[start]}`);
+    }
+  }
 }
 
 function flagsForBuildOptions(
@@ -179,18 +293,62 @@ function flagsForBuildOptions(
   let bundle = getFlag(options, keys, 'bundle', mustBeBoolean);
   let watch = getFlag(options, keys, 'watch', mustBeBooleanOrObject);
   let splitting = getFlag(options, keys, 'splitting', mustBeBoolean);
+  let splittingPreset = getFlag(options, keys, 'splittingPreset', mustBeString);
+  let formats = getFlag(options, keys, 'formats', mustBeArray);
+  let dualPackage = getFlag(options, keys, 'dualPackage', mustBeBoolean);
+  let unusedExports = getFlag(options, keys, 'unusedExports', mustBeString);
+  let dynamicImportLoader = getFlag(options, keys, 'dynamicImportLoader', mustBeString);
   let preserveSymlinks = getFlag(options, keys, 'preserveSymlinks', mustBeBoolean);
+  let nodePolyfills = getFlag(options, keys, 'nodePolyfills', mustBeBoolean);
   let metafile = getFlag(options, keys, 'metafile', mustBeBoolean);
+  let accessList = getFlag(options, keys, 'accessList', mustBeBoolean);
+  let moduleMap = getFlag(options, keys, 'moduleMap', mustBeBoolean);
+  let cjsWrapper = getFlag(options, keys, 'cjsWrapper', mustBeBoolean);
   let outfile = getFlag(options, keys, 'outfile', mustBeString);
   let outdir = getFlag(options, keys, 'outdir', mustBeString);
   let outbase = getFlag(options, keys, 'outbase', mustBeString);
+  let precacheManifest = getFlag(options, keys, 'precacheManifest', mustBeString);
+  let contentManifest = getFlag(options, keys, 'contentManifest', mustBeString);
+  let sharedChunkDir = getFlag(options, keys, 'sharedChunkDir', mustBeString);
+  let serviceWorker = getFlag(options, keys, 'serviceWorker', mustBeString);
+  let cspReport = getFlag(options, keys, 'cspReport', mustBeString);
+  let featureReport = getFlag(options, keys, 'featureReport', mustBeString);
+  let cssOrderReport = getFlag(options, keys, 'cssOrderReport', mustBeString);
+  let nameCache = getFlag(options, keys, 'nameCache', mustBeString);
+  let mangleCache = getFlag(options, keys, 'mangleCache', mustBeString);
+  let record = getFlag(options, keys, 'record', mustBeString);
   let platform = getFlag(options, keys, 'platform', mustBeString);
   let tsconfig = getFlag(options, keys, 'tsconfig', mustBeString);
+  let typeCheck = getFlag(options, keys, 'typeCheck', mustBeString);
   let resolveExtensions = getFlag(options, keys, 'resolveExtensions', mustBeArray);
   let nodePathsInput = getFlag(options, keys, 'nodePaths', mustBeArray);
+  let denoDir = getFlag(options, keys, 'denoDir', mustBeString);
+  let workspaces = getFlag(options, keys, 'workspaces', mustBeObject);
+  let detectWorkspaces = getFlag(options, keys, 'detectWorkspaces', mustBeBoolean);
+  let alias = getFlag(options, keys, 'alias', mustBeObject);
+  let formatAnnotations = getFlag(options, keys, 'formatAnnotations', mustBeObject);
+  let strictCase = getFlag(options, keys, 'strictCase', mustBeBoolean);
+  let bundleDynamicPaths = getFlag(options, keys, 'bundleDynamicPaths', mustBeBoolean);
+  let rewriteImports = getFlag(options, keys, 'rewriteImports', mustBeBoolean);
+  let preserveModules = getFlag(options, keys, 'preserveModules', mustBeBoolean);
+  let inferTarget = getFlag(options, keys, 'inferTarget', mustBeBoolean);
+  let directoryImports = getFlag(options, keys, 'directoryImports', mustBeString);
+  let indexExtensions = getFlag(options, keys, 'indexExtensions', mustBeArray);
+  let treeShakeMembers = getFlag(options, keys, 'treeShakeMembers', mustBeBoolean);
   let mainFields = getFlag(options, keys, 'mainFields', mustBeArray);
   let conditions = getFlag(options, keys, 'conditions', mustBeArray);
   let external = getFlag(options, keys, 'external', mustBeArray);
+  let packages = getFlag(options, keys, 'packages', mustBeString);
+  let externalRewrite = getFlag(options, keys, 'externalRewrite', mustBeObject);
+  let globals = getFlag(options, keys, 'globals', mustBeObject);
+  let isolatePackages = getFlag(options, keys, 'isolatePackages', mustBeArray);
+  let isolateChunks = getFlag(options, keys, 'isolateChunks', mustBeArray);
+  let chunks = getFlag(options, keys, 'chunks', mustBeObject);
+  let chunkMinSize = getFlag(options, keys, 'chunkMinSize', mustBeInteger);
+  let chunkMaxSize = getFlag(options, keys, 'chunkMaxSize', mustBeInteger);
+  let lazyPackages = getFlag(options, keys, 'lazyPackages', mustBeArray);
+  let cssLayers = getFlag(options, keys, 'cssLayers', mustBeObject);
+  let budgets = getFlag(options, keys, 'budgets', mustBeObject);
   let loader = getFlag(options, keys, 'loader', mustBeObject);
   let outExtension = getFlag(options, keys, 'outExtension', mustBeObject);
   let publicPath = getFlag(options, keys, 'publicPath', mustBeString);
@@ -198,6 +356,7 @@ function flagsForBuildOptions(
   let chunkNames = getFlag(options, keys, 'chunkNames', mustBeString);
   let assetNames = getFlag(options, keys, 'assetNames', mustBeString);
   let inject = getFlag(options, keys, 'inject', mustBeArray);
+  let injectCSSLink = getFlag(options, keys, 'injectCSSLink', mustBeBoolean);
   let banner = getFlag(options, keys, 'banner', mustBeObject);
   let footer = getFlag(options, keys, 'footer', mustBeObject);
   let entryPoints = getFlag(options, keys, 'entryPoints', mustBeArrayOrRecord);
@@ -205,13 +364,18 @@ function flagsForBuildOptions(
   let stdin = getFlag(options, keys, 'stdin', mustBeObject);
   let write = getFlag(options, keys, 'write', mustBeBoolean) ?? writeDefault; // Default to true if not specified
   let allowOverwrite = getFlag(options, keys, 'allowOverwrite', mustBeBoolean);
+  let onConflict = getFlag(options, keys, 'onConflict', mustBeString);
+  let skipUnchanged = getFlag(options, keys, 'skipUnchanged', mustBeBoolean);
   let incremental = getFlag(options, keys, 'incremental', mustBeBoolean) === true;
   keys.plugins = true; // "plugins" has already been read earlier
+  keys.pluginTimeout = true; // "pluginTimeout" is only used by plugins
   checkForInvalidFlags(options, keys, `in ${callName}() call`);
 
   if (sourcemap) flags.push(`--sourcemap${sourcemap === true ? '' : `=${sourcemap}`}`);
   if (bundle) flags.push('--bundle');
   if (allowOverwrite) flags.push('--allow-overwrite');
+  if (onConflict) flags.push(`--on-conflict=${onConflict}`);
+  if (skipUnchanged) flags.push('--skip-unchanged');
   if (watch) {
     flags.push('--watch');
     if (typeof watch === 'boolean') {
@@ -224,13 +388,60 @@ function flagsForBuildOptions(
     }
   }
   if (splitting) flags.push('--splitting');
+  if (splittingPreset) flags.push(`--splitting-preset=${splittingPreset}`);
+  if (formats) {
+    let values: string[] = [];
+    for (let value of formats) {
+      value += '';
+      if (value.indexOf(',') >= 0) throw new Error(`Invalid format: ${value}`);
+      values.push(value);
+    }
+    flags.push(`--format=${values.join(',')}`);
+  }
+  if (dualPackage) flags.push('--dual-package');
+  if (unusedExports) flags.push(`--unused-exports=${unusedExports}`);
+  if (dynamicImportLoader) flags.push(`--dynamic-import-loader=${dynamicImportLoader}`);
   if (preserveSymlinks) flags.push('--preserve-symlinks');
+  if (nodePolyfills) flags.push('--node-polyfills');
+  if (detectWorkspaces) flags.push('--detect-workspaces');
+  if (strictCase) flags.push('--strict-case');
+  if (bundleDynamicPaths) flags.push('--bundle-dynamic-paths');
+  if (rewriteImports) flags.push('--rewrite-imports');
+  if (preserveModules) flags.push('--preserve-modules');
+  if (inferTarget) flags.push('--infer-target');
+  if (budgets) {
+    let budgetKeys: OptionKeys = Object.create(null);
+    let bytes = getFlag(budgets, budgetKeys, 'bytes', mustBeInteger);
+    let functions = getFlag(budgets, budgetKeys, 'functions', mustBeInteger);
+    let statements = getFlag(budgets, budgetKeys, 'statements', mustBeInteger);
+    checkForInvalidFlags(budgets, budgetKeys, `on "budgets" in ${callName}() call`);
+    if (bytes !== void 0) flags.push(`--budget:bytes=${bytes}`);
+    if (functions !== void 0) flags.push(`--budget:functions=${functions}`);
+    if (statements !== void 0) flags.push(`--budget:statements=${statements}`);
+  }
+  if (directoryImports) flags.push(`--directory-imports=${directoryImports}`);
+  if (treeShakeMembers) flags.push('--tree-shake-members');
   if (metafile) flags.push(`--metafile`);
+  if (accessList) flags.push('--access-list');
+  if (moduleMap) flags.push('--module-map');
+  if (cjsWrapper) flags.push('--cjs-wrapper');
   if (outfile) flags.push(`--outfile=${outfile}`);
   if (outdir) flags.push(`--outdir=${outdir}`);
   if (outbase) flags.push(`--outbase=${outbase}`);
+  if (precacheManifest) flags.push(`--precache-manifest=${precacheManifest}`);
+  if (contentManifest) flags.push(`--content-manifest=${contentManifest}`);
+  if (sharedChunkDir) flags.push(`--shared-chunk-dir=${sharedChunkDir}`);
+  if (serviceWorker) flags.push(`--service-worker=${serviceWorker}`);
+  if (cspReport) flags.push(`--csp-report=${cspReport}`);
+  if (featureReport) flags.push(`--feature-report=${featureReport}`);
+  if (cssOrderReport) flags.push(`--css-order-report=${cssOrderReport}`);
+  if (nameCache) flags.push(`--name-cache=${nameCache}`);
+  if (mangleCache) flags.push(`--mangle-cache=${mangleCache}`);
+  if (record) flags.push(`--record=${record}`);
   if (platform) flags.push(`--platform=${platform}`);
   if (tsconfig) flags.push(`--tsconfig=${tsconfig}`);
+  if (typeCheck) flags.push(`--type-check=${typeCheck}`);
+  if (denoDir) flags.push(`--deno-dir=${denoDir}`);
   if (resolveExtensions) {
     let values: string[] = [];
     for (let value of resolveExtensions) {
@@ -240,9 +451,20 @@ function flagsForBuildOptions(
     }
     flags.push(`--resolve-extensions=${values.join(',')}`);
   }
+  if (indexExtensions) {
+    let values: string[] = [];
+    for (let value of indexExtensions) {
+      value += '';
+      if (value.indexOf(',') >= 0) throw new Error(`Invalid index extension: ${value}`);
+      values.push(value);
+    }
+    flags.push(`--index-extensions=${values.join(',')}`);
+  }
   if (publicPath) flags.push(`--public-path=${publicPath}`);
   if (entryNames) flags.push(`--entry-names=${entryNames}`);
   if (chunkNames) flags.push(`--chunk-names=${chunkNames}`);
+  if (chunkMinSize) flags.push(`--chunk-min-size=${chunkMinSize}`);
+  if (chunkMaxSize) flags.push(`--chunk-max-size=${chunkMaxSize}`);
   if (assetNames) flags.push(`--asset-names=${assetNames}`);
   if (mainFields) {
     let values: string[] = [];
@@ -263,6 +485,29 @@ function flagsForBuildOptions(
     flags.push(`--conditions=${values.join(',')}`);
   }
   if (external) for (let name of external) flags.push(`--external:${name}`);
+  if (packages) flags.push(`--packages=${packages}`);
+  if (externalRewrite) {
+    for (let path in externalRewrite) {
+      if (path.indexOf('=') >= 0) throw new Error(`Invalid external path: ${path}`);
+      flags.push(`--external-rewrite:${path}=${externalRewrite[path]}`);
+    }
+  }
+  if (globals) {
+    for (let path in globals) {
+      if (path.indexOf('=') >= 0) throw new Error(`Invalid external path: ${path}`);
+      flags.push(`--external:${path}=${globals[path]}`);
+    }
+  }
+  if (isolatePackages) for (let name of isolatePackages) flags.push(`--isolate-package:${name}`);
+  if (isolateChunks) for (let rule of isolateChunks) flags.push(`--isolate-chunk:${rule}`);
+  if (chunks) {
+    for (let name in chunks) {
+      if (name.indexOf('=') >= 0) throw new Error(`Invalid chunk name: ${name}`);
+      let paths = chunks[name];
+      for (let path of Array.isArray(paths) ? paths : [paths]) flags.push(`--chunk:${name}=${path}`);
+    }
+  }
+  if (lazyPackages) for (let name of lazyPackages) flags.push(`--lazy-package:${name}`);
   if (banner) {
     for (let type in banner) {
       if (type.indexOf('=') >= 0) throw new Error(`Invalid banner file type: ${type}`);
@@ -276,6 +521,31 @@ function flagsForBuildOptions(
     }
   }
   if (inject) for (let path of inject) flags.push(`--inject:${path}`);
+  if (injectCSSLink) flags.push('--inject-css-link');
+  if (cssLayers) {
+    for (let name in cssLayers) {
+      if (name.indexOf('=') >= 0) throw new Error(`Invalid CSS layer package name: ${name}`);
+      flags.push(`--css-layer:${name}=${cssLayers[name]}`);
+    }
+  }
+  if (workspaces) {
+    for (let name in workspaces) {
+      if (name.indexOf('=') >= 0) throw new Error(`Invalid workspace package name: ${name}`);
+      flags.push(`--workspace:${name}=${workspaces[name]}`);
+    }
+  }
+  if (alias) {
+    for (let old in alias) {
+      if (old.indexOf('=') >= 0) throw new Error(`Invalid package name in alias: ${old}`);
+      flags.push(`--alias:${old}=${alias[old]}`);
+    }
+  }
+  if (formatAnnotations) {
+    for (let format in formatAnnotations) {
+      if (format.indexOf('=') >= 0) throw new Error(`Invalid format in annotations: ${format}`);
+      flags.push(`--annotations:${format}=${formatAnnotations[format as types.Format]}`);
+    }
+  }
   if (loader) {
     for (let ext in loader) {
       if (ext.indexOf('=') >= 0) throw new Error(`Invalid loader extension: ${ext}`);
@@ -431,8 +701,8 @@ export interface StreamService {
 // for both sync and async code. There is an exception for plugin code because
 // that can't work in sync code anyway.
 export function createChannel(streamIn: StreamIn): StreamOut {
-  type PluginCallback = (request: protocol.OnStartRequest | protocol.OnResolveRequest | protocol.OnLoadRequest) =>
-    Promise<protocol.OnStartResponse | protocol.OnResolveResponse | protocol.OnLoadResponse>;
+  type PluginCallback = (request: protocol.OnStartRequest | protocol.OnResolveRequest | protocol.OnLoadRequest | protocol.OnTransformRequest | protocol.OnSourceMapRequest) =>
+    Promise<protocol.OnStartResponse | protocol.OnResolveResponse | protocol.OnLoadResponse | protocol.OnTransformResponse | protocol.OnSourceMapResponse>;
 
   type WatchCallback = (error: Error | null, response: any) => void;
 
@@ -526,6 +796,8 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     | protocol.OnStartRequest
     | protocol.OnResolveRequest
     | protocol.OnLoadRequest
+    | protocol.OnTransformRequest
+    | protocol.OnSourceMapRequest
     | protocol.OnRequestRequest
     | protocol.OnWaitRequest
     | protocol.OnWatchRebuildRequest
@@ -560,6 +832,20 @@ export function createChannel(streamIn: StreamIn): StreamOut {
           break;
         }
 
+        case 'on-transform': {
+          let callback = pluginCallbacks.get(request.key);
+          if (!callback) sendResponse(id, {});
+          else sendResponse(id, await callback!(request) as any);
+          break;
+        }
+
+        case 'on-source-map': {
+          let callback = pluginCallbacks.get(request.key);
+          if (!callback) sendResponse(id, {});
+          else sendResponse(id, await callback!(request) as any);
+          break;
+        }
+
         case 'serve-request': {
           let callbacks = serveCallbacks.get(request.serveID);
           if (callbacks && callbacks.onRequest) callbacks.onRequest(request.args);
@@ -638,6 +924,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
   > => {
     let onStartCallbacks: {
       name: string,
+      optional: boolean,
       note: () => types.Note | undefined,
       callback: () => (types.OnStartResult | null | void | Promise<types.OnStartResult | null | void>),
     }[] = [];
@@ -651,6 +938,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     let onResolveCallbacks: {
       [id: number]: {
         name: string,
+        optional: boolean,
         note: () => types.Note | undefined,
         callback: (args: types.OnResolveArgs) =>
           (types.OnResolveResult | null | undefined | Promise<types.OnResolveResult | null | undefined>),
@@ -660,14 +948,61 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     let onLoadCallbacks: {
       [id: number]: {
         name: string,
+        optional: boolean,
         note: () => types.Note | undefined,
         callback: (args: types.OnLoadArgs) =>
           (types.OnLoadResult | null | undefined | Promise<types.OnLoadResult | null | undefined>),
       },
     } = {};
 
+    let onTransformCallbacks: {
+      [id: number]: {
+        name: string,
+        optional: boolean,
+        note: () => types.Note | undefined,
+        callback: (args: types.OnTransformArgs) =>
+          (types.OnTransformResult | null | undefined | Promise<types.OnTransformResult | null | undefined>),
+      },
+    } = {};
+
+    let onSourceMapCallbacks: {
+      [id: number]: {
+        name: string,
+        optional: boolean,
+        note: () => types.Note | undefined,
+        callback: (args: types.OnSourceMapArgs) =>
+          (types.OnSourceMapResult | null | undefined | Promise<types.OnSourceMapResult | null | undefined>),
+      },
+    } = {};
+
     let nextCallbackID = 0;
     let i = 0;
+
+    // Callbacks that don't finish within "pluginTimeout" are abandoned so that
+    // one stuck plugin can't hang the whole build. Note that this can't stop
+    // code that never yields to the event loop.
+    let pluginTimeout = getFlag(initialOptions, {}, 'pluginTimeout', mustBeInteger) || 0;
+    let withTimeout = <T>(hook: string, subject: string, result: T | Promise<T>): Promise<T> => {
+      if (!pluginTimeout) return Promise.resolve(result);
+      let timer: any;
+      let timeout = new Promise<T>((_, reject) => {
+        timer = setTimeout(() => {
+          let what = subject ? ` for ${JSON.stringify(subject)}` : '';
+          reject({ message: `The ${JSON.stringify(hook)} callback${what} timed out after ${pluginTimeout}ms` });
+        }, pluginTimeout);
+      });
+      return Promise.race([result, timeout]).then(
+        value => { clearTimeout(timer); return value },
+        error => { clearTimeout(timer); throw error },
+      );
+    };
+
+    // Failures of optional plugins are reported as warnings instead of errors
+    let optionalFailure = (message: types.Message, name: string): types.Message => {
+      message.notes.push({ text: `The plugin ${JSON.stringify(name)} is optional, so this error does not fail the build`, location: null });
+      return message;
+    };
+
     let requestPlugins: protocol.BuildPlugin[] = [];
 
     // Clone the plugin array to guard against mutation during iteration
@@ -680,6 +1015,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
       if (typeof name !== 'string' || name === '') throw new Error(`Plugin at index ${i} is missing a name`);
       try {
         let setup = getFlag(item, keys, 'setup', mustBeFunction);
+        let optional = getFlag(item, keys, 'optional', mustBeBoolean) === true;
         if (typeof setup !== 'function') throw new Error(`Plugin is missing a setup function`);
         checkForInvalidFlags(item, keys, `on plugin ${JSON.stringify(name)}`);
 
@@ -687,6 +1023,8 @@ export function createChannel(streamIn: StreamIn): StreamOut {
           name,
           onResolve: [],
           onLoad: [],
+          onTransform: [],
+          onSourceMap: [],
         };
         i++;
 
@@ -696,7 +1034,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
           onStart(callback) {
             let registeredText = `This error came from the "onStart" callback registered here:`
             let registeredNote = extractCallerV8(new Error(registeredText), streamIn, 'onStart');
-            onStartCallbacks.push({ name: name!, callback, note: registeredNote });
+            onStartCallbacks.push({ name: name!, callback, note: registeredNote, optional });
           },
 
           onEnd(callback) {
@@ -714,7 +1052,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
             checkForInvalidFlags(options, keys, `in onResolve() call for plugin ${JSON.stringify(name)}`);
             if (filter == null) throw new Error(`onResolve() call is missing a filter`);
             let id = nextCallbackID++;
-            onResolveCallbacks[id] = { name: name!, callback, note: registeredNote };
+            onResolveCallbacks[id] = { name: name!, callback, note: registeredNote, optional };
             plugin.onResolve.push({ id, filter: filter.source, namespace: namespace || '' });
           },
 
@@ -727,10 +1065,35 @@ export function createChannel(streamIn: StreamIn): StreamOut {
             checkForInvalidFlags(options, keys, `in onLoad() call for plugin ${JSON.stringify(name)}`);
             if (filter == null) throw new Error(`onLoad() call is missing a filter`);
             let id = nextCallbackID++;
-            onLoadCallbacks[id] = { name: name!, callback, note: registeredNote };
+            onLoadCallbacks[id] = { name: name!, callback, note: registeredNote, optional };
             plugin.onLoad.push({ id, filter: filter.source, namespace: namespace || '' });
           },
 
+          onTransform(options, callback) {
+            let registeredText = `This error came from the "onTransform" callback registered here:`
+            let registeredNote = extractCallerV8(new Error(registeredText), streamIn, 'onTransform');
+            let keys: OptionKeys = {};
+            let filter = getFlag(options, keys, 'filter', mustBeRegExp);
+            let namespace = getFlag(options, keys, 'namespace', mustBeString);
+            checkForInvalidFlags(options, keys, `in onTransform() call for plugin ${JSON.stringify(name)}`);
+            if (filter == null) throw new Error(`onTransform() call is missing a filter`);
+            let id = nextCallbackID++;
+            onTransformCallbacks[id] = { name: name!, callback, note: registeredNote, optional };
+            plugin.onTransform.push({ id, filter: filter.source, namespace: namespace || '' });
+          },
+
+          onSourceMap(options, callback) {
+            let registeredText = `This error came from the "onSourceMap" callback registered here:`
+            let registeredNote = extractCallerV8(new Error(registeredText), streamIn, 'onSourceMap');
+            let keys: OptionKeys = {};
+            let filter = getFlag(options, keys, 'filter', mustBeRegExp);
+            checkForInvalidFlags(options, keys, `in onSourceMap() call for plugin ${JSON.stringify(name)}`);
+            if (filter == null) throw new Error(`onSourceMap() call is missing a filter`);
+            let id = nextCallbackID++;
+            onSourceMapCallbacks[id] = { name: name!, callback, note: registeredNote, optional };
+            plugin.onSourceMap.push({ id, filter: filter.source });
+          },
+
           esbuild: streamIn.esbuild,
         });
 
@@ -751,9 +1114,9 @@ export function createChannel(streamIn: StreamIn): StreamOut {
       switch (request.command) {
         case 'start': {
           let response: protocol.OnStartResponse = { errors: [], warnings: [] };
-          await Promise.all(onStartCallbacks.map(async ({ name, callback, note }) => {
+          await Promise.all(onStartCallbacks.map(async ({ name, callback, note, optional }) => {
             try {
-              let result = await callback();
+              let result = await withTimeout('onStart', '', callback());
 
               if (result != null) {
                 if (typeof result !== 'object') throw new Error(`Expected onStart() callback in plugin ${JSON.stringify(name)} to return an object`);
@@ -766,25 +1129,28 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 if (warnings != null) response.warnings!.push(...sanitizeMessages(warnings, 'warnings', stash, name));
               }
             } catch (e) {
-              response.errors!.push(extractErrorMessageV8(e, streamIn, stash, note && note(), name));
+              let message = extractErrorMessageV8(e, streamIn, stash, note && note(), name);
+              if (optional) response.warnings!.push(optionalFailure(message, name));
+              else response.errors!.push(message);
             }
           }))
           return response;
         }
 
         case 'resolve': {
-          let response: protocol.OnResolveResponse = {}, name = '', callback, note;
+          let response: protocol.OnResolveResponse = {}, name = '', callback, note, optional;
+          let skipped: types.PartialMessage[] = [];
           for (let id of request.ids) {
             try {
-              ({ name, callback, note } = onResolveCallbacks[id]);
-              let result = await callback({
+              ({ name, callback, note, optional } = onResolveCallbacks[id]);
+              let result = await withTimeout('onResolve', request.path, callback({
                 path: request.path,
                 importer: request.importer,
                 namespace: request.namespace,
                 resolveDir: request.resolveDir,
                 kind: request.kind,
                 pluginData: stash.load(request.pluginData),
-              });
+              }));
 
               if (result != null) {
                 if (typeof result !== 'object') throw new Error(`Expected onResolve() callback in plugin ${JSON.stringify(name)} to return an object`);
@@ -815,22 +1181,26 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 break;
               }
             } catch (e) {
-              return { id, errors: [extractErrorMessageV8(e, streamIn, stash, note && note(), name)] };
+              let message = extractErrorMessageV8(e, streamIn, stash, note && note(), name);
+              if (!optional) return { id, errors: [message], warnings: skipped };
+              skipped.push(optionalFailure(message, name));
             }
           }
+          if (skipped.length > 0) response.warnings = skipped.concat(response.warnings || []);
           return response;
         }
 
         case 'load': {
-          let response: protocol.OnLoadResponse = {}, name = '', callback, note;
+          let response: protocol.OnLoadResponse = {}, name = '', callback, note, optional;
+          let skipped: types.PartialMessage[] = [];
           for (let id of request.ids) {
             try {
-              ({ name, callback, note } = onLoadCallbacks[id]);
-              let result = await callback({
+              ({ name, callback, note, optional } = onLoadCallbacks[id]);
+              let result = await withTimeout('onLoad', request.path, callback({
                 path: request.path,
                 namespace: request.namespace,
                 pluginData: stash.load(request.pluginData),
-              });
+              }));
 
               if (result != null) {
                 if (typeof result !== 'object') throw new Error(`Expected onLoad() callback in plugin ${JSON.stringify(name)} to return an object`);
@@ -860,9 +1230,130 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                 break;
               }
             } catch (e) {
-              return { id, errors: [extractErrorMessageV8(e, streamIn, stash, note && note(), name)] };
+              let message = extractErrorMessageV8(e, streamIn, stash, note && note(), name);
+              if (!optional) return { id, errors: [message], warnings: skipped };
+              skipped.push(optionalFailure(message, name));
+            }
+          }
+          if (skipped.length > 0) response.warnings = skipped.concat(response.warnings || []);
+          return response;
+        }
+
+        case 'on-transform': {
+          // Unlike "onLoad", every matching callback is run in order. Each one
+          // is given the contents and source map returned by the previous one.
+          let response: protocol.OnTransformResponse = {}, name = '', callback, note, optional;
+          let contents = protocol.decodeUTF8(request.contents);
+          let sourceMap = request.sourceMap;
+          let errors: types.PartialMessage[] = [];
+          let warnings: types.PartialMessage[] = [];
+          let watchFiles: string[] = [];
+          let watchDirs: string[] = [];
+          for (let id of request.ids) {
+            try {
+              ({ name, callback, note, optional } = onTransformCallbacks[id]);
+              let result = await withTimeout('onTransform', request.path, callback({
+                path: request.path,
+                namespace: request.namespace,
+                pluginData: stash.load(request.pluginData),
+                loader: request.loader as types.Loader,
+                contents,
+                sourceMap,
+              }));
+
+              if (result != null) {
+                if (typeof result !== 'object') throw new Error(`Expected onTransform() callback in plugin ${JSON.stringify(name)} to return an object`);
+                let keys: OptionKeys = {};
+                let pluginName = getFlag(result, keys, 'pluginName', mustBeString);
+                let newContents = getFlag(result, keys, 'contents', mustBeString);
+                let newSourceMap = getFlag(result, keys, 'sourceMap', mustBeString);
+                let resultErrors = getFlag(result, keys, 'errors', mustBeArray);
+                let resultWarnings = getFlag(result, keys, 'warnings', mustBeArray);
+                let resultWatchFiles = getFlag(result, keys, 'watchFiles', mustBeArray);
+                let resultWatchDirs = getFlag(result, keys, 'watchDirs', mustBeArray);
+                checkForInvalidFlags(result, keys, `from onTransform() callback in plugin ${JSON.stringify(name)}`);
+
+                let messageName = pluginName != null ? pluginName : name;
+                if (newContents != null) {
+                  contents = newContents;
+                  sourceMap = newSourceMap;
+                  response.contents = protocol.encodeUTF8(contents);
+                  if (sourceMap != null) response.sourceMap = sourceMap;
+                  else delete response.sourceMap;
+                }
+                if (resultErrors != null) errors.push(...sanitizeMessages(resultErrors, 'errors', stash, messageName));
+                if (resultWarnings != null) warnings.push(...sanitizeMessages(resultWarnings, 'warnings', stash, messageName));
+                if (resultWatchFiles != null) watchFiles.push(...sanitizeStringArray(resultWatchFiles, 'watchFiles'));
+                if (resultWatchDirs != null) watchDirs.push(...sanitizeStringArray(resultWatchDirs, 'watchDirs'));
+
+                // Stop at the first error since later callbacks may depend on this one
+                if (resultErrors != null && resultErrors.length > 0) {
+                  response.id = id;
+                  if (pluginName != null) response.pluginName = pluginName;
+                  break;
+                }
+              }
+            } catch (e) {
+              let message = extractErrorMessageV8(e, streamIn, stash, note && note(), name);
+              if (!optional) return { id, errors: [message], warnings };
+              warnings.push(optionalFailure(message, name));
             }
           }
+          if (errors.length > 0) response.errors = errors;
+          if (warnings.length > 0) response.warnings = warnings;
+          if (watchFiles.length > 0) response.watchFiles = watchFiles;
+          if (watchDirs.length > 0) response.watchDirs = watchDirs;
+          return response;
+        }
+
+        case 'on-source-map': {
+          // Every matching callback is run in order. Each one is given the
+          // source map returned by the previous one.
+          let response: protocol.OnSourceMapResponse = {}, name = '', callback, note, optional;
+          let sourceMap: types.SourceMapObject = JSON.parse(request.sourceMap);
+          let errors: types.PartialMessage[] = [];
+          let warnings: types.PartialMessage[] = [];
+          for (let id of request.ids) {
+            try {
+              ({ name, callback, note, optional } = onSourceMapCallbacks[id]);
+              let result = await withTimeout('onSourceMap', request.path, callback({
+                path: request.path,
+                sourceMapPath: request.sourceMapPath,
+                sourceMap,
+              }));
+
+              if (result != null) {
+                if (typeof result !== 'object') throw new Error(`Expected onSourceMap() callback in plugin ${JSON.stringify(name)} to return an object`);
+                let keys: OptionKeys = {};
+                let pluginName = getFlag(result, keys, 'pluginName', mustBeString);
+                let newSourceMap = getFlag(result, keys, 'sourceMap', mustBeObject);
+                let resultErrors = getFlag(result, keys, 'errors', mustBeArray);
+                let resultWarnings = getFlag(result, keys, 'warnings', mustBeArray);
+                checkForInvalidFlags(result, keys, `from onSourceMap() callback in plugin ${JSON.stringify(name)}`);
+
+                let messageName = pluginName != null ? pluginName : name;
+                if (newSourceMap != null) {
+                  sourceMap = newSourceMap;
+                  response.sourceMap = JSON.stringify(sourceMap);
+                }
+                if (resultErrors != null) errors.push(...sanitizeMessages(resultErrors, 'errors', stash, messageName));
+                if (resultWarnings != null) warnings.push(...sanitizeMessages(resultWarnings, 'warnings', stash, messageName));
+
+                // Stop at the first error since later callbacks may depend on this one
+                if (resultErrors != null && resultErrors.length > 0) {
+                  response.id = id;
+                  if (pluginName != null) response.pluginName = pluginName;
+                  break;
+                }
+              }
+            } catch (e) {
+              let message = extractErrorMessageV8(e, streamIn, stash, note && note(), name);
+              if (!optional) return { id, errors: [message], warnings };
+              warnings.push(optionalFailure(message, name));
+            }
+          }
+          if (errors.length > 0) response.errors = errors;
+          if (warnings.length > 0) response.warnings = warnings;
           return response;
         }
 
@@ -901,15 +1392,24 @@ export function createChannel(streamIn: StreamIn): StreamOut {
 
   interface ServeData {
     wait: Promise<void>
-    stop: () => void
+    stop: (graceful: boolean | undefined) => void
   }
 
-  let buildServeData = (refs: Refs | null, options: types.ServeOptions, request: protocol.BuildRequest): ServeData => {
+  let buildServeData = (
+    refs: Refs | null,
+    options: types.ServeOptions,
+    request: protocol.BuildRequest,
+    isTTY: boolean,
+    defaultWD: string,
+  ): ServeData => {
     let keys: OptionKeys = {};
     let port = getFlag(options, keys, 'port', mustBeInteger);
     let host = getFlag(options, keys, 'host', mustBeString);
     let servedir = getFlag(options, keys, 'servedir', mustBeString);
     let onRequest = getFlag(options, keys, 'onRequest', mustBeFunction);
+    let projects = getFlag(options, keys, 'projects', mustBeObject);
+    let throttle = getFlag(options, keys, 'throttle', mustBeArray);
+    let proxy = getFlag(options, keys, 'proxy', mustBeString);
     let serveID = nextServeID++;
     let onWait: ServeCallbacks['onWait'];
     let wait = new Promise<void>((resolve, reject) => {
@@ -924,14 +1424,39 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     if (port !== void 0) request.serve.port = port;
     if (host !== void 0) request.serve.host = host;
     if (servedir !== void 0) request.serve.servedir = servedir;
+    if (proxy !== void 0) request.serve.proxy = proxy;
+    if (throttle !== void 0) {
+      request.serve.throttle = [];
+      for (let rule of throttle) {
+        let ruleKeys: OptionKeys = {};
+        let pattern = getFlag(rule, ruleKeys, 'pattern', mustBeString);
+        let latency = getFlag(rule, ruleKeys, 'latency', mustBeInteger);
+        let bytesPerSecond = getFlag(rule, ruleKeys, 'bytesPerSecond', mustBeInteger);
+        checkForInvalidFlags(rule, ruleKeys, `in serve() throttle rule`);
+        if (pattern === void 0) throw new Error(`Missing "pattern" in serve() throttle rule`);
+        request.serve.throttle.push({ pattern, latency: latency || 0, bytesPerSecond: bytesPerSecond || 0 });
+      }
+    }
+    if (projects !== void 0) {
+      request.serve.projects = [];
+      for (let prefix in projects) {
+        let projectOptions = projects[prefix];
+        if (projectOptions.plugins) throw new Error(`Cannot use plugins in serve project ${JSON.stringify(prefix)}`);
+        let { entries, flags, absWorkingDir, nodePaths } = flagsForBuildOptions(
+          'serve', projectOptions, isTTY, buildLogLevelDefault, false);
+        request.serve.projects.push({ prefix, entries, flags, absWorkingDir: absWorkingDir || defaultWD, nodePaths });
+      }
+    }
     serveCallbacks.set(serveID, {
       onRequest,
       onWait: onWait!,
     });
     return {
       wait,
-      stop() {
-        sendRequest<protocol.ServeStopRequest, null>(refs, { command: 'serve-stop', serveID }, () => {
+      stop(graceful) {
+        let request: protocol.ServeStopRequest = { command: 'serve-stop', serveID };
+        if (graceful !== void 0) request.graceful = graceful;
+        sendRequest<protocol.ServeStopRequest, null>(refs, request, () => {
           // We don't care about the result
         });
       },
@@ -1079,7 +1604,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
       nodePaths,
     };
     if (requestPlugins) request.plugins = requestPlugins;
-    let serve = serveOptions && buildServeData(refs, serveOptions, request);
+    let serve = serveOptions && buildServeData(refs, serveOptions, request, isTTY, defaultWD);
 
     // Factor out response handling so it can be reused for rebuilds
     let rebuild: types.BuildResult['rebuild'] | undefined;
@@ -1087,6 +1612,9 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     let copyResponseToResult = (response: protocol.BuildResponse, result: types.BuildResult) => {
       if (response.outputFiles) result.outputFiles = response!.outputFiles.map(convertOutputFiles);
       if (response.metafile) result.metafile = JSON.parse(response!.metafile);
+      if (response.nameCache) result.nameCache = JSON.parse(response!.nameCache);
+      if (response.mangleCache) result.mangleCache = JSON.parse(response!.mangleCache);
+      if (response.watchChanges) result.watchChanges = response!.watchChanges;
       if (response.writeToStdout !== void 0) console.log(protocol.decodeUTF8(response!.writeToStdout).replace(/\n$/, ''));
     };
     let buildResponseToResult = (
@@ -1121,6 +1649,15 @@ export function createChannel(streamIn: StreamIn): StreamOut {
                   });
                 });
             });
+            rebuild!.update = (options: types.UpdateOptions) => new Promise<void>((resolve, reject) => {
+              if (isDisposed || isClosed) throw new Error('Cannot update');
+              let request: protocol.RebuildUpdateRequest = { command: 'rebuild-update', rebuildID: response!.rebuildID!, ...validateUpdateOptions(options) };
+              sendRequest<protocol.RebuildUpdateRequest, { error?: string }>(refs, request, (error2, response2) => {
+                let error = error2 || response2!.error;
+                if (error) reject(new Error(error));
+                else resolve();
+              });
+            });
             refs.ref()
             rebuild!.dispose = () => {
               if (isDisposed) return;
@@ -1195,10 +1732,13 @@ export function createChannel(streamIn: StreamIn): StreamOut {
           port: serveResponse.port,
           host: serveResponse.host,
           wait: serve.wait,
-          stop() {
+          stop(options = {}) {
+            let keys: OptionKeys = {};
+            let graceful = getFlag(options, keys, 'graceful', mustBeBoolean);
+            checkForInvalidFlags(options, keys, `in stop() call`);
             if (isStopped) return
             isStopped = true
-            serve!.stop();
+            serve!.stop(graceful);
             refs.unref() // Do this after the callback so "stop" can extend the lifetime
           },
         };
@@ -1250,7 +1790,13 @@ export function createChannel(streamIn: StreamIn): StreamOut {
           let errors = replaceDetailsInMessages(response!.errors, details);
           let warnings = replaceDetailsInMessages(response!.warnings, details);
           let outstanding = 1;
-          let next = () => --outstanding === 0 && callback(null, { warnings, code: response!.code, map: response!.map });
+          let next = () => --outstanding === 0 && callback(null, {
+            warnings,
+            code: response!.code,
+            map: response!.map,
+            bannerLine: response!.bannerLine,
+            footerLine: response!.footerLine,
+          });
           if (errors.length > 0) return callback(failureErrorWithLog('Transform failed', errors, warnings), null);
 
           // Read the JavaScript file from the file system
@@ -1326,6 +1872,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     let keys: OptionKeys = {};
     let color = getFlag(options, keys, 'color', mustBeBoolean);
     let verbose = getFlag(options, keys, 'verbose', mustBeBoolean);
+    let filter = getFlag(options, keys, 'filter', mustBeString);
     checkForInvalidFlags(options, keys, `in ${callName}() call`);
     let request: protocol.AnalyzeMetafileRequest = {
       command: 'analyze-metafile',
@@ -1333,6 +1880,7 @@ export function createChannel(streamIn: StreamIn): StreamOut {
     }
     if (color !== void 0) request.color = color;
     if (verbose !== void 0) request.verbose = verbose;
+    if (filter !== void 0) request.filter = filter;
     sendRequest<protocol.AnalyzeMetafileRequest, protocol.AnalyzeMetafileResponse>(refs, request, (error, response) => {
       if (error) return callback(new Error(error), null);
       callback(null, response!.result);
@@ -1565,11 +2113,13 @@ function sanitizeStringArray(values: any[], property: string): string[] {
   return result;
 }
 
-function convertOutputFiles({ path, contents }: protocol.BuildOutputFile): types.OutputFile {
+function convertOutputFiles({ path, contents, bannerLine, footerLine }: protocol.BuildOutputFile): types.OutputFile {
   let text: string | null = null;
   return {
     path,
     contents,
+    bannerLine,
+    footerLine,
     get text() {
       if (text === null) text = protocol.decodeUTF8(contents);
       return text;
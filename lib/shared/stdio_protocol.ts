@@ -26,6 +26,23 @@ export interface ServeRequest {
   port?: number;
   host?: string;
   servedir?: string;
+  projects?: ServeProject[];
+  throttle?: ServeThrottle[];
+  proxy?: string;
+}
+
+export interface ServeThrottle {
+  pattern: string;
+  latency: number;
+  bytesPerSecond: number;
+}
+
+export interface ServeProject {
+  prefix: string;
+  entries: [string, string][]; // Use an array instead of a map to preserve order
+  flags: string[];
+  absWorkingDir: string;
+  nodePaths: string[];
 }
 
 export interface ServeResponse {
@@ -36,12 +53,15 @@ export interface ServeResponse {
 export interface ServeStopRequest {
   command: 'serve-stop';
   serveID: number;
+  graceful?: boolean;
 }
 
 export interface BuildPlugin {
   name: string;
   onResolve: { id: number, filter: string, namespace: string }[];
   onLoad: { id: number, filter: string, namespace: string }[];
+  onTransform: { id: number, filter: string, namespace: string }[];
+  onSourceMap: { id: number, filter: string }[];
 }
 
 export interface BuildResponse {
@@ -49,14 +69,19 @@ export interface BuildResponse {
   warnings: types.Message[];
   outputFiles: BuildOutputFile[];
   metafile: string;
+  nameCache?: string;
+  mangleCache?: string;
   writeToStdout?: Uint8Array;
   rebuildID?: number;
   watchID?: number;
+  watchChanges?: types.WatchChange[];
 }
 
 export interface BuildOutputFile {
   path: string;
   contents: Uint8Array;
+  bannerLine: number;
+  footerLine: number;
 }
 
 export interface PingRequest {
@@ -73,6 +98,15 @@ export interface RebuildDisposeRequest {
   rebuildID: number;
 }
 
+export interface RebuildUpdateRequest {
+  command: 'rebuild-update';
+  rebuildID: number;
+  logLevel?: string;
+  define?: Record<string, string>;
+  banner?: Record<string, string>;
+  drop?: string[];
+}
+
 export interface WatchStopRequest {
   command: 'watch-stop';
   watchID: number;
@@ -112,6 +146,9 @@ export interface TransformResponse {
 
   map: string;
   mapFS: boolean;
+
+  bannerLine: number;
+  footerLine: number;
 }
 
 export interface FormatMsgsRequest {
@@ -131,6 +168,7 @@ export interface AnalyzeMetafileRequest {
   metafile: string;
   color?: boolean;
   verbose?: boolean;
+  filter?: string;
 }
 
 export interface AnalyzeMetafileResponse {
@@ -201,6 +239,51 @@ export interface OnLoadResponse {
   watchDirs?: string[];
 }
 
+export interface OnTransformRequest {
+  command: 'on-transform';
+  key: number;
+  ids: number[];
+  path: string;
+  namespace: string;
+  pluginData: number;
+  loader: string;
+  contents: Uint8Array;
+  sourceMap?: string;
+}
+
+export interface OnTransformResponse {
+  id?: number;
+  pluginName?: string;
+
+  errors?: types.PartialMessage[];
+  warnings?: types.PartialMessage[];
+
+  contents?: Uint8Array;
+  sourceMap?: string;
+
+  watchFiles?: string[];
+  watchDirs?: string[];
+}
+
+export interface OnSourceMapRequest {
+  command: 'on-source-map';
+  key: number;
+  ids: number[];
+  path: string;
+  sourceMapPath: string;
+  sourceMap: string;
+}
+
+export interface OnSourceMapResponse {
+  id?: number;
+  pluginName?: string;
+
+  errors?: types.PartialMessage[];
+  warnings?: types.PartialMessage[];
+
+  sourceMap?: string;
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 export interface Packet {
@@ -1,25 +1,41 @@
 export type Platform = 'browser' | 'node' | 'neutral';
-export type Format = 'iife' | 'cjs' | 'esm';
-export type Loader = 'js' | 'jsx' | 'ts' | 'tsx' | 'css' | 'json' | 'text' | 'base64' | 'file' | 'dataurl' | 'binary' | 'default';
+export type Format = 'iife' | 'cjs' | 'esm' | 'umd';
+export type Loader = 'js' | 'jsx' | 'ts' | 'tsx' | 'css' | 'local-css' | 'json' | 'jsonc' | 'json5' | 'yaml' | 'toml' | 'text' | 'base64' | 'file' | 'copy' | 'dataurl' | 'binary' | 'webmanifest' | 'image' | 'default';
 export type LogLevel = 'verbose' | 'debug' | 'info' | 'warning' | 'error' | 'silent';
 export type Charset = 'ascii' | 'utf8';
+export type Drop = 'console' | 'debugger';
+export type Annotations = 'default' | 'always' | 'never';
 
 interface CommonOptions {
   /** Documentation: https://esbuild.github.io/api/#sourcemap */
   sourcemap?: boolean | 'inline' | 'external' | 'both';
   /** Documentation: https://esbuild.github.io/api/#legal-comments */
   legalComments?: 'none' | 'inline' | 'eof' | 'linked' | 'external';
+  /** Documentation: https://esbuild.github.io/api/#annotations */
+  annotations?: Annotations;
+  /** Documentation: https://esbuild.github.io/api/#magic-comments */
+  magicComments?: Annotations;
+  /** Documentation: https://esbuild.github.io/api/#line-ending */
+  lineEnding?: 'lf' | 'crlf';
+  /** Documentation: https://esbuild.github.io/api/#indent */
+  indent?: number | 'tab';
   /** Documentation: https://esbuild.github.io/api/#source-root */
   sourceRoot?: string;
   /** Documentation: https://esbuild.github.io/api/#sources-content */
   sourcesContent?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#sourcemap-banners */
+  sourcemapBanners?: boolean;
 
   /** Documentation: https://esbuild.github.io/api/#format */
   format?: Format;
   /** Documentation: https://esbuild.github.io/api/#globalName */
   globalName?: string;
+  /** Documentation: https://esbuild.github.io/api/#external-helpers */
+  externalHelpers?: string;
   /** Documentation: https://esbuild.github.io/api/#target */
   target?: string | string[];
+  /** Documentation: https://esbuild.github.io/api/#supported */
+  supported?: Record<string, boolean>;
 
   /** Documentation: https://esbuild.github.io/api/#minify */
   minify?: boolean;
@@ -29,26 +45,61 @@ interface CommonOptions {
   minifyIdentifiers?: boolean;
   /** Documentation: https://esbuild.github.io/api/#minify */
   minifySyntax?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#minify */
+  minifySeed?: string;
   /** Documentation: https://esbuild.github.io/api/#charset */
   charset?: Charset;
+  /** Documentation: https://esbuild.github.io/api/#charset */
+  charsetEscape?: string[];
+  /** Documentation: https://esbuild.github.io/api/#charset */
+  identifierCharset?: Charset;
+  /** Documentation: https://esbuild.github.io/api/#charset */
+  identifierNormalization?: 'nfc' | 'nfd';
   /** Documentation: https://esbuild.github.io/api/#tree-shaking */
   treeShaking?: boolean;
   /** Documentation: https://esbuild.github.io/api/#ignore-annotations */
   ignoreAnnotations?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#infer-pure */
+  inferPure?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#recover-syntax-errors */
+  recoverSyntaxErrors?: boolean;
 
   /** Documentation: https://esbuild.github.io/api/#jsx */
-  jsx?: 'transform' | 'preserve';
+  jsx?: 'transform' | 'preserve' | 'automatic';
   /** Documentation: https://esbuild.github.io/api/#jsx-factory */
   jsxFactory?: string;
   /** Documentation: https://esbuild.github.io/api/#jsx-fragment */
   jsxFragment?: string;
+  /** Documentation: https://esbuild.github.io/api/#jsx-import-source */
+  jsxImportSource?: string;
+
+  /** Documentation: https://esbuild.github.io/api/#ts-version */
+  tsVersion?: string;
+  /** Documentation: https://esbuild.github.io/api/#ts-enums */
+  tsEnums?: 'classic' | 'frozen' | 'inline';
 
   /** Documentation: https://esbuild.github.io/api/#define */
   define?: { [key: string]: string };
+  /** Documentation: https://esbuild.github.io/api/#feature-flags */
+  featureFlags?: { [key: string]: boolean };
   /** Documentation: https://esbuild.github.io/api/#pure */
   pure?: string[];
+  /** Documentation: https://esbuild.github.io/api/#drop */
+  drop?: Drop[];
   /** Documentation: https://esbuild.github.io/api/#keep-names */
   keepNames?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#pragmas */
+  pragmas?: string[];
+  /** Documentation: https://esbuild.github.io/api/#preserve-comments */
+  preserveComments?: RegExp;
+  /** Documentation: https://esbuild.github.io/api/#mangle-props */
+  mangleProps?: RegExp;
+  /** Documentation: https://esbuild.github.io/api/#mangle-props */
+  reserveProps?: RegExp;
+  /** Documentation: https://esbuild.github.io/api/#strip-if */
+  stripIf?: string[];
+  /** Documentation: https://esbuild.github.io/api/#strip-between */
+  stripBetween?: { [start: string]: string };
 
   /** Documentation: https://esbuild.github.io/api/#color */
   color?: boolean;
@@ -63,20 +114,72 @@ export interface BuildOptions extends CommonOptions {
   bundle?: boolean;
   /** Documentation: https://esbuild.github.io/api/#splitting */
   splitting?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#splitting-preset */
+  splittingPreset?: 'none' | 'vendor';
+  /** Documentation: https://esbuild.github.io/api/#formats */
+  formats?: Format[];
+  /** Documentation: https://esbuild.github.io/api/#dual-package */
+  dualPackage?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#unused-exports */
+  unusedExports?: 'ignore' | 'warning' | 'error';
+  /** Documentation: https://esbuild.github.io/api/#dynamic-import-loader */
+  dynamicImportLoader?: 'require' | 'script';
   /** Documentation: https://esbuild.github.io/api/#preserve-symlinks */
   preserveSymlinks?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#node-polyfills */
+  nodePolyfills?: boolean;
   /** Documentation: https://esbuild.github.io/api/#outfile */
   outfile?: string;
   /** Documentation: https://esbuild.github.io/api/#metafile */
   metafile?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#access-list */
+  accessList?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#module-map */
+  moduleMap?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#cjs-wrapper */
+  cjsWrapper?: boolean;
   /** Documentation: https://esbuild.github.io/api/#outdir */
   outdir?: string;
+  /** Documentation: https://esbuild.github.io/api/#precache-manifest */
+  precacheManifest?: string;
+  /** Documentation: https://esbuild.github.io/api/#content-manifest */
+  contentManifest?: string;
+  /** Documentation: https://esbuild.github.io/api/#shared-chunk-dir */
+  sharedChunkDir?: string;
+  /** Documentation: https://esbuild.github.io/api/#service-worker */
+  serviceWorker?: string;
+  /** Documentation: https://esbuild.github.io/api/#csp-report */
+  cspReport?: string;
+  /** Documentation: https://esbuild.github.io/api/#feature-flags */
+  featureReport?: string;
+  /** Documentation: https://esbuild.github.io/api/#css-order-report */
+  cssOrderReport?: string;
+  /** Documentation: https://esbuild.github.io/api/#name-cache */
+  nameCache?: string;
+  /** Documentation: https://esbuild.github.io/api/#mangle-cache */
+  mangleCache?: string;
+  /** Documentation: https://esbuild.github.io/api/#record */
+  record?: string;
   /** Documentation: https://esbuild.github.io/api/#outbase */
   outbase?: string;
   /** Documentation: https://esbuild.github.io/api/#platform */
   platform?: Platform;
   /** Documentation: https://esbuild.github.io/api/#external */
   external?: string[];
+  /** Documentation: https://esbuild.github.io/api/#external-rewrite */
+  externalRewrite?: Record<string, string>;
+  /** Documentation: https://esbuild.github.io/api/#globals */
+  globals?: Record<string, string>;
+  /** Documentation: https://esbuild.github.io/api/#isolate-packages */
+  isolatePackages?: string[];
+  /** Documentation: https://esbuild.github.io/api/#isolate-chunks */
+  isolateChunks?: string[];
+  /** Documentation: https://esbuild.github.io/api/#manual-chunks */
+  chunks?: { [name: string]: string | string[] };
+  /** Documentation: https://esbuild.github.io/api/#lazy-packages */
+  lazyPackages?: string[];
+  /** Documentation: https://esbuild.github.io/api/#css-layers */
+  cssLayers?: Record<string, string>;
   /** Documentation: https://esbuild.github.io/api/#loader */
   loader?: { [ext: string]: Loader };
   /** Documentation: https://esbuild.github.io/api/#resolve-extensions */
@@ -89,8 +192,14 @@ export interface BuildOptions extends CommonOptions {
   write?: boolean;
   /** Documentation: https://esbuild.github.io/api/#allow-overwrite */
   allowOverwrite?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#on-conflict */
+  onConflict?: 'error' | 'rename' | 'overwrite';
+  /** Documentation: https://esbuild.github.io/api/#skip-unchanged */
+  skipUnchanged?: boolean;
   /** Documentation: https://esbuild.github.io/api/#tsconfig */
   tsconfig?: string;
+  /** Documentation: https://esbuild.github.io/api/#type-check */
+  typeCheck?: string;
   /** Documentation: https://esbuild.github.io/api/#out-extension */
   outExtension?: { [ext: string]: string };
   /** Documentation: https://esbuild.github.io/api/#public-path */
@@ -99,10 +208,16 @@ export interface BuildOptions extends CommonOptions {
   entryNames?: string;
   /** Documentation: https://esbuild.github.io/api/#chunk-names */
   chunkNames?: string;
+  /** Documentation: https://esbuild.github.io/api/#chunk-size */
+  chunkMinSize?: number;
+  /** Documentation: https://esbuild.github.io/api/#chunk-size */
+  chunkMaxSize?: number;
   /** Documentation: https://esbuild.github.io/api/#asset-names */
   assetNames?: string;
   /** Documentation: https://esbuild.github.io/api/#inject */
   inject?: string[];
+  /** Documentation: https://esbuild.github.io/api/#inject-css-link */
+  injectCSSLink?: boolean;
   /** Documentation: https://esbuild.github.io/api/#banner */
   banner?: { [type: string]: string };
   /** Documentation: https://esbuild.github.io/api/#footer */
@@ -115,10 +230,42 @@ export interface BuildOptions extends CommonOptions {
   stdin?: StdinOptions;
   /** Documentation: https://esbuild.github.io/plugins/ */
   plugins?: Plugin[];
+  /** Documentation: https://esbuild.github.io/plugins/#timeouts */
+  pluginTimeout?: number;
   /** Documentation: https://esbuild.github.io/api/#working-directory */
   absWorkingDir?: string;
   /** Documentation: https://esbuild.github.io/api/#node-paths */
   nodePaths?: string[]; // The "NODE_PATH" variable from Node.js
+  /** Documentation: https://esbuild.github.io/api/#deno-dir */
+  denoDir?: string; // The "DENO_DIR" variable from Deno
+  /** Documentation: https://esbuild.github.io/api/#workspaces */
+  workspaces?: Record<string, string>;
+  /** Documentation: https://esbuild.github.io/api/#workspaces */
+  detectWorkspaces?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#alias */
+  alias?: Record<string, string>;
+  /** Documentation: https://esbuild.github.io/api/#packages */
+  packages?: 'external';
+  /** Documentation: https://esbuild.github.io/api/#annotations */
+  formatAnnotations?: Partial<Record<Format, Annotations>>;
+  /** Documentation: https://esbuild.github.io/api/#strict-case */
+  strictCase?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#bundle-dynamic-paths */
+  bundleDynamicPaths?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#rewrite-imports */
+  rewriteImports?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#preserve-modules */
+  preserveModules?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#infer-target */
+  inferTarget?: boolean;
+  /** Documentation: https://esbuild.github.io/api/#budgets */
+  budgets?: { bytes?: number, functions?: number, statements?: number };
+  /** Documentation: https://esbuild.github.io/api/#directory-imports */
+  directoryImports?: 'cjs' | 'no-index' | 'node-esm';
+  /** Documentation: https://esbuild.github.io/api/#index-extensions */
+  indexExtensions?: string[];
+  /** Documentation: https://esbuild.github.io/api/#tree-shake-members */
+  treeShakeMembers?: boolean;
   /** Documentation: https://esbuild.github.io/api/#watch */
   watch?: boolean | WatchMode;
 }
@@ -171,13 +318,27 @@ export interface OutputFile {
   contents: Uint8Array;
   /** "contents" as text */
   text: string;
+  /** The zero-based line that the banner starts on, or zero if there is none */
+  bannerLine: number;
+  /** The zero-based line that the footer starts on, or zero if there is none */
+  footerLine: number;
 }
 
 export interface BuildInvalidate {
   (): Promise<BuildIncremental>;
+  /** Changes some of the options of later builds without starting over */
+  update(options: UpdateOptions): Promise<void>;
   dispose(): void;
 }
 
+/** Only the options that are present are changed */
+export interface UpdateOptions {
+  logLevel?: LogLevel;
+  define?: { [key: string]: string };
+  banner?: { [type: string]: string };
+  drop?: Drop[];
+}
+
 export interface BuildIncremental extends BuildResult {
   rebuild: BuildInvalidate;
 }
@@ -193,6 +354,17 @@ export interface BuildResult {
   stop?: () => void;
   /** Only when "metafile: true" */
   metafile?: Metafile;
+  /** Only when "nameCache" is set */
+  nameCache?: Record<string, string>;
+  /** Only when "mangleCache" is set */
+  mangleCache?: Record<string, string>;
+  /** Only for rebuilds triggered by "watch" */
+  watchChanges?: WatchChange[];
+}
+
+export interface WatchChange {
+  path: string;
+  kind: 'modified' | 'added' | 'deleted';
 }
 
 export interface BuildFailure extends Error {
@@ -206,6 +378,21 @@ export interface ServeOptions {
   host?: string;
   servedir?: string;
   onRequest?: (args: ServeOnRequestArgs) => void;
+  /** Builds that are served under a URL path prefix such as "/app1" */
+  projects?: Record<string, BuildOptions>;
+  /** Documentation: https://esbuild.github.io/api/#serve-throttle */
+  throttle?: ServeThrottle[];
+  /** Documentation: https://esbuild.github.io/api/#serve-fallback-proxy */
+  proxy?: string;
+}
+
+export interface ServeThrottle {
+  /** The URL path, where "*" matches any sequence of characters */
+  pattern: string;
+  /** The delay before the response starts */
+  latency?: number;
+  /** The maximum rate at which the response body is sent */
+  bytesPerSecond?: number;
 }
 
 export interface ServeOnRequestArgs {
@@ -222,7 +409,12 @@ export interface ServeResult {
   port: number;
   host: string;
   wait: Promise<void>;
-  stop: () => void;
+  stop: (options?: ServeStopOptions) => void;
+}
+
+export interface ServeStopOptions {
+  /** Wait for active connections to finish instead of closing them */
+  graceful?: boolean;
 }
 
 export interface TransformOptions extends CommonOptions {
@@ -230,6 +422,8 @@ export interface TransformOptions extends CommonOptions {
     compilerOptions?: {
       jsxFactory?: string,
       jsxFragmentFactory?: string,
+      jsx?: string,
+      jsxImportSource?: string,
       useDefineForClassFields?: boolean,
       importsNotUsedAsValues?: 'remove' | 'preserve' | 'error',
       preserveValueImports?: boolean,
@@ -246,6 +440,10 @@ export interface TransformResult {
   code: string;
   map: string;
   warnings: Message[];
+  /** The zero-based line that the banner starts on, or zero if there is none */
+  bannerLine: number;
+  /** The zero-based line that the footer starts on, or zero if there is none */
+  footerLine: number;
 }
 
 export interface TransformFailure extends Error {
@@ -256,6 +454,8 @@ export interface TransformFailure extends Error {
 export interface Plugin {
   name: string;
   setup: (build: PluginBuild) => (void | Promise<void>);
+  /** Documentation: https://esbuild.github.io/plugins/#optional-plugins */
+  optional?: boolean;
 }
 
 export interface PluginBuild {
@@ -268,6 +468,10 @@ export interface PluginBuild {
     (OnResolveResult | null | undefined | Promise<OnResolveResult | null | undefined>)): void;
   onLoad(options: OnLoadOptions, callback: (args: OnLoadArgs) =>
     (OnLoadResult | null | undefined | Promise<OnLoadResult | null | undefined>)): void;
+  onTransform(options: OnTransformOptions, callback: (args: OnTransformArgs) =>
+    (OnTransformResult | null | undefined | Promise<OnTransformResult | null | undefined>)): void;
+  onSourceMap(options: OnSourceMapOptions, callback: (args: OnSourceMapArgs) =>
+    (OnSourceMapResult | null | undefined | Promise<OnSourceMapResult | null | undefined>)): void;
 
   // This is a full copy of the esbuild library in case you need it
   esbuild: {
@@ -316,6 +520,7 @@ export type ImportKind =
   // CSS
   | 'import-rule'
   | 'url-token'
+  | 'composes-from'
 
 export interface OnResolveResult {
   pluginName?: string;
@@ -359,6 +564,67 @@ export interface OnLoadResult {
   watchDirs?: string[];
 }
 
+export interface OnTransformOptions {
+  filter: RegExp;
+  namespace?: string;
+}
+
+export interface OnTransformArgs {
+  path: string;
+  namespace: string;
+  pluginData: any;
+  loader: Loader;
+  contents: string;
+  /** The source map returned by the previous transform callback, if any */
+  sourceMap: string | undefined;
+}
+
+export interface OnTransformResult {
+  pluginName?: string;
+
+  errors?: PartialMessage[];
+  warnings?: PartialMessage[];
+
+  contents?: string;
+  sourceMap?: string;
+
+  watchFiles?: string[];
+  watchDirs?: string[];
+}
+
+export interface OnSourceMapOptions {
+  /** This is matched against the path of the output file */
+  filter: RegExp;
+}
+
+export interface OnSourceMapArgs {
+  /** The output file that the source map is for */
+  path: string;
+  /** This is an empty string if the source map is only inlined */
+  sourceMapPath: string;
+  sourceMap: SourceMapObject;
+}
+
+export interface OnSourceMapResult {
+  pluginName?: string;
+
+  errors?: PartialMessage[];
+  warnings?: PartialMessage[];
+
+  /** The source map is serialized by esbuild, which also generates the source map comment */
+  sourceMap?: SourceMapObject;
+}
+
+export interface SourceMapObject {
+  version: number;
+  sources: string[];
+  sourceRoot?: string;
+  sourcesContent?: (string | null)[];
+  mappings: string;
+  names: string[];
+  [field: string]: any;
+}
+
 export interface PartialMessage {
   pluginName?: string;
   text?: string;
@@ -380,6 +646,14 @@ export interface Metafile {
         path: string
         kind: ImportKind
       }[]
+      namespace?: string
+      specifier?: string
+      plugin?: string
+      pragmas?: {
+        name: string
+        value: string
+      }[]
+      evaluationCost?: number
     }
   }
   outputs: {
@@ -390,14 +664,24 @@ export interface Metafile {
           bytesInOutput: number
         }
       }
+      helpers?: {
+        [name: string]: {
+          bytesInOutput: number
+        }
+      }
       imports: {
         path: string
         kind: ImportKind
       }[]
       exports: string[]
       entryPoint?: string
+      cssBundle?: string
     }
   }
+  /** Only when "accessList: true" */
+  accessed?: {
+    [path: string]: 'file' | 'directory' | 'missing'
+  }
 }
 
 export interface FormatMessagesOptions {
@@ -409,6 +693,7 @@ export interface FormatMessagesOptions {
 export interface AnalyzeMetafileOptions {
   color?: boolean;
   verbose?: boolean;
+  filter?: string;
 }
 
 /**
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/js_printer"
+)
+
+// The access list is every file and directory that the build read or checked
+// for. This includes files that plugins asked to watch as well as the chains
+// of "package.json" and "tsconfig.json" files that the resolver looked at.
+// External build systems can use it to compute the exact inputs of a build.
+func fileAccessList(watchData fs.WatchData) []FileAccess {
+	accesses := make([]FileAccess, 0, len(watchData.Accesses))
+	for path, kind := range watchData.Accesses {
+		access := FileAccess{Path: path}
+		switch kind {
+		case fs.AccessFile:
+			access.Kind = FileAccessFile
+		case fs.AccessDirectory:
+			access.Kind = FileAccessDirectory
+		case fs.AccessMissing:
+			access.Kind = FileAccessMissing
+		}
+		accesses = append(accesses, access)
+	}
+	sort.Slice(accesses, func(i int, j int) bool {
+		return accesses[i].Path < accesses[j].Path
+	})
+	return accesses
+}
+
+// This adds an "accessed" object to the end of the metafile that maps each
+// path (relative to the working directory, like "inputs") to how it was used:
+//
+//	"accessed": {
+//	  "src/index.js": "file",
+//	  "src": "directory",
+//	  "src/package.json": "missing"
+//	}
+func addAccessListToMetafile(realFS fs.FS, metafile string, accesses []FileAccess, asciiOnly bool) string {
+	end := strings.LastIndex(metafile, "\n}")
+	if end == -1 {
+		return metafile
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(metafile[:end])
+	sb.WriteString(",\n  \"accessed\": {")
+	for i, access := range accesses {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		path := access.Path
+		if relPath, ok := realFS.Rel(realFS.Cwd(), path); ok {
+			path = strings.ReplaceAll(relPath, "\\", "/")
+		}
+		sb.WriteString("\n    ")
+		sb.Write(js_printer.QuoteForJSON(path, asciiOnly))
+		sb.WriteString(": \"")
+		sb.WriteString(fileAccessKindText(access.Kind))
+		sb.WriteString("\"")
+	}
+	if len(accesses) > 0 {
+		sb.WriteString("\n  ")
+	}
+	sb.WriteString("}")
+	sb.WriteString(metafile[end:])
+	return sb.String()
+}
+
+func fileAccessKindText(kind FileAccessKind) string {
+	switch kind {
+	case FileAccessDirectory:
+		return "directory"
+	case FileAccessMissing:
+		return "missing"
+	}
+	return "file"
+}
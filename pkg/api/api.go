@@ -77,6 +77,12 @@
 //
 package api
 
+import (
+	"image"
+	"io"
+	"time"
+)
+
 type SourceMap uint8
 
 const (
@@ -105,11 +111,91 @@ const (
 	LegalCommentsExternal
 )
 
+type Annotations uint8
+
+const (
+	AnnotationsDefault Annotations = iota
+	AnnotationsAlways
+	AnnotationsNever
+)
+
+type OnConflict uint8
+
+const (
+	OnConflictDefault OnConflict = iota
+	OnConflictError
+	OnConflictRename
+	OnConflictOverwrite
+)
+
+type DirectoryImports uint8
+
+const (
+	DirectoryImportsDefault DirectoryImports = iota
+	DirectoryImportsCommonJS
+	DirectoryImportsNoIndex
+	DirectoryImportsNodeESM
+)
+
+// Output files that exceed any of these limits generate a warning. A limit of
+// zero means that there is no limit.
+type OutputBudgets struct {
+	Bytes      int
+	Functions  int
+	Statements int
+}
+
+type LineEnding uint8
+
+const (
+	LineEndingDefault LineEnding = iota
+	LineEndingLF
+	LineEndingCRLF
+)
+
+type DynamicImportLoader uint8
+
+const (
+	DynamicImportLoaderRequire DynamicImportLoader = iota
+	DynamicImportLoaderScript
+)
+
+type UnusedExports uint8
+
+const (
+	UnusedExportsIgnore UnusedExports = iota
+	UnusedExportsWarning
+	UnusedExportsError
+)
+
+type TSEnums uint8
+
+const (
+	TSEnumsClassic TSEnums = iota
+	TSEnumsFrozen
+	TSEnumsInline
+)
+
+type SplittingPreset uint8
+
+const (
+	SplittingPresetNone SplittingPreset = iota
+	SplittingPresetVendor
+)
+
+type Packages uint8
+
+const (
+	PackagesDefault Packages = iota
+	PackagesExternal
+)
+
 type JSXMode uint8
 
 const (
 	JSXModeTransform JSXMode = iota
 	JSXModePreserve
+	JSXModeAutomatic
 )
 
 type Target uint8
@@ -136,12 +222,20 @@ const (
 	LoaderTS
 	LoaderTSX
 	LoaderJSON
+	LoaderJSONC
+	LoaderJSON5
+	LoaderYAML
+	LoaderTOML
 	LoaderText
 	LoaderBase64
 	LoaderDataURL
 	LoaderFile
+	LoaderCopy
 	LoaderBinary
 	LoaderCSS
+	LoaderLocalCSS
+	LoaderWebManifest
+	LoaderImage
 	LoaderDefault
 )
 
@@ -160,6 +254,7 @@ const (
 	FormatIIFE
 	FormatCommonJS
 	FormatESModule
+	FormatUMD
 )
 
 type EngineName uint8
@@ -231,6 +326,14 @@ const (
 	CharsetUTF8
 )
 
+type Normalization uint8
+
+const (
+	NormalizationNone Normalization = iota
+	NormalizationNFC
+	NormalizationNFD
+)
+
 type TreeShaking uint8
 
 const (
@@ -239,6 +342,13 @@ const (
 	TreeShakingTrue
 )
 
+type Drop uint8
+
+const (
+	DropConsole Drop = 1 << iota
+	DropDebugger
+)
+
 ////////////////////////////////////////////////////////////////////////////////
 // Build API
 
@@ -247,65 +357,157 @@ type BuildOptions struct {
 	LogLimit int         // Documentation: https://esbuild.github.io/api/#log-limit
 	LogLevel LogLevel    // Documentation: https://esbuild.github.io/api/#log-level
 
-	Sourcemap      SourceMap      // Documentation: https://esbuild.github.io/api/#sourcemap
-	SourceRoot     string         // Documentation: https://esbuild.github.io/api/#source-root
-	SourcesContent SourcesContent // Documentation: https://esbuild.github.io/api/#sources-content
+	RecoverSyntaxErrors bool // Documentation: https://esbuild.github.io/api/#recover-syntax-errors
 
-	Target  Target   // Documentation: https://esbuild.github.io/api/#target
-	Engines []Engine // Documentation: https://esbuild.github.io/api/#target
+	Sourcemap        SourceMap      // Documentation: https://esbuild.github.io/api/#sourcemap
+	SourceRoot       string         // Documentation: https://esbuild.github.io/api/#source-root
+	SourcesContent   SourcesContent // Documentation: https://esbuild.github.io/api/#sources-content
+	SourcemapBanners bool           // Documentation: https://esbuild.github.io/api/#sourcemap-banners
+
+	Target      Target   // Documentation: https://esbuild.github.io/api/#target
+	Engines     []Engine // Documentation: https://esbuild.github.io/api/#target
+	InferTarget bool     // Documentation: https://esbuild.github.io/api/#infer-target
+
+	Supported     map[string]bool // Documentation: https://esbuild.github.io/api/#supported
+	SupportedFile string          // A JSON file that maps feature names to true or false, relative to the working directory. Entries in "Supported" take precedence.
+
+	DynamicImportLoader DynamicImportLoader // Documentation: https://esbuild.github.io/api/#dynamic-import-loader
 
 	MinifyWhitespace  bool          // Documentation: https://esbuild.github.io/api/#minify
 	MinifyIdentifiers bool          // Documentation: https://esbuild.github.io/api/#minify
 	MinifySyntax      bool          // Documentation: https://esbuild.github.io/api/#minify
+	MinifySeed        string        // Documentation: https://esbuild.github.io/api/#minify
 	Charset           Charset       // Documentation: https://esbuild.github.io/api/#charset
+	CharsetEscape     []string      // Documentation: https://esbuild.github.io/api/#charset
+	IdentifierCharset Charset       // Documentation: https://esbuild.github.io/api/#charset
 	TreeShaking       TreeShaking   // Documentation: https://esbuild.github.io/api/#tree-shaking
+	TreeShakeMembers  bool          // Documentation: https://esbuild.github.io/api/#tree-shake-members
 	IgnoreAnnotations bool          // Documentation: https://esbuild.github.io/api/#ignore-annotations
+	InferPure         bool          // Documentation: https://esbuild.github.io/api/#infer-pure
 	LegalComments     LegalComments // Documentation: https://esbuild.github.io/api/#legal-comments
-
-	JSXMode     JSXMode // Documentation: https://esbuild.github.io/api/#jsx-mode
-	JSXFactory  string  // Documentation: https://esbuild.github.io/api/#jsx-factory
-	JSXFragment string  // Documentation: https://esbuild.github.io/api/#jsx-fragment
-
-	Define    map[string]string // Documentation: https://esbuild.github.io/api/#define
-	Pure      []string          // Documentation: https://esbuild.github.io/api/#pure
-	KeepNames bool              // Documentation: https://esbuild.github.io/api/#keep-names
-
-	GlobalName        string            // Documentation: https://esbuild.github.io/api/#global-name
-	Bundle            bool              // Documentation: https://esbuild.github.io/api/#bundle
-	PreserveSymlinks  bool              // Documentation: https://esbuild.github.io/api/#preserve-symlinks
-	Splitting         bool              // Documentation: https://esbuild.github.io/api/#splitting
-	Outfile           string            // Documentation: https://esbuild.github.io/api/#outfile
-	Metafile          bool              // Documentation: https://esbuild.github.io/api/#metafile
-	Outdir            string            // Documentation: https://esbuild.github.io/api/#outdir
-	Outbase           string            // Documentation: https://esbuild.github.io/api/#outbase
-	AbsWorkingDir     string            // Documentation: https://esbuild.github.io/api/#working-directory
-	Platform          Platform          // Documentation: https://esbuild.github.io/api/#platform
-	Format            Format            // Documentation: https://esbuild.github.io/api/#format
-	External          []string          // Documentation: https://esbuild.github.io/api/#external
-	MainFields        []string          // Documentation: https://esbuild.github.io/api/#main-fields
-	Conditions        []string          // Documentation: https://esbuild.github.io/api/#conditions
-	Loader            map[string]Loader // Documentation: https://esbuild.github.io/api/#loader
-	ResolveExtensions []string          // Documentation: https://esbuild.github.io/api/#resolve-extensions
-	Tsconfig          string            // Documentation: https://esbuild.github.io/api/#tsconfig
-	OutExtensions     map[string]string // Documentation: https://esbuild.github.io/api/#out-extension
-	PublicPath        string            // Documentation: https://esbuild.github.io/api/#public-path
-	Inject            []string          // Documentation: https://esbuild.github.io/api/#inject
-	Banner            map[string]string // Documentation: https://esbuild.github.io/api/#banner
-	Footer            map[string]string // Documentation: https://esbuild.github.io/api/#footer
-	NodePaths         []string          // Documentation: https://esbuild.github.io/api/#node-paths
+	Annotations       Annotations   // Documentation: https://esbuild.github.io/api/#annotations
+	MagicComments     Annotations   // Documentation: https://esbuild.github.io/api/#magic-comments
+	LineEnding        LineEnding    // Documentation: https://esbuild.github.io/api/#line-ending
+	Indent            string        // Documentation: https://esbuild.github.io/api/#indent
+
+	IdentifierNormalization Normalization // Documentation: https://esbuild.github.io/api/#charset
+
+	FormatAnnotations map[Format]Annotations // Documentation: https://esbuild.github.io/api/#annotations
+
+	JSXMode         JSXMode // Documentation: https://esbuild.github.io/api/#jsx-mode
+	JSXFactory      string  // Documentation: https://esbuild.github.io/api/#jsx-factory
+	JSXFragment     string  // Documentation: https://esbuild.github.io/api/#jsx-fragment
+	JSXImportSource string  // Documentation: https://esbuild.github.io/api/#jsx-import-source
+
+	TSVersion string  // Documentation: https://esbuild.github.io/api/#ts-version
+	TSEnums   TSEnums // Documentation: https://esbuild.github.io/api/#ts-enums
+
+	Define       map[string]string // Documentation: https://esbuild.github.io/api/#define
+	FeatureFlags map[string]bool   // Documentation: https://esbuild.github.io/api/#feature-flags
+	Pure         []string          // Documentation: https://esbuild.github.io/api/#pure
+	Drop         Drop              // Documentation: https://esbuild.github.io/api/#drop
+	KeepNames    bool              // Documentation: https://esbuild.github.io/api/#keep-names
+	Pragmas      []string          // Documentation: https://esbuild.github.io/api/#pragmas
+
+	PreserveComments string // Documentation: https://esbuild.github.io/api/#preserve-comments
+
+	MangleProps  string // Documentation: https://esbuild.github.io/api/#mangle-props
+	ReserveProps string // Documentation: https://esbuild.github.io/api/#mangle-props
+	MangleCache  string // Documentation: https://esbuild.github.io/api/#mangle-cache
+
+	StripIf      []string          // Documentation: https://esbuild.github.io/api/#strip-if
+	StripBetween map[string]string // Documentation: https://esbuild.github.io/api/#strip-between
+
+	GlobalName         string            // Documentation: https://esbuild.github.io/api/#global-name
+	Bundle             bool              // Documentation: https://esbuild.github.io/api/#bundle
+	PreserveSymlinks   bool              // Documentation: https://esbuild.github.io/api/#preserve-symlinks
+	Splitting          bool              // Documentation: https://esbuild.github.io/api/#splitting
+	SplittingPreset    SplittingPreset   // Documentation: https://esbuild.github.io/api/#splitting-preset
+	ChunkMinSize       int               // Documentation: https://esbuild.github.io/api/#chunk-size
+	ChunkMaxSize       int               // Documentation: https://esbuild.github.io/api/#chunk-size
+	UnusedExports      UnusedExports     // Documentation: https://esbuild.github.io/api/#unused-exports
+	Outfile            string            // Documentation: https://esbuild.github.io/api/#outfile
+	Metafile           bool              // Documentation: https://esbuild.github.io/api/#metafile
+	AccessList         bool              // Documentation: https://esbuild.github.io/api/#access-list
+	ModuleMap          bool              // Documentation: https://esbuild.github.io/api/#module-map
+	CJSWrapper         bool              // Documentation: https://esbuild.github.io/api/#cjs-wrapper
+	PrecacheManifest   string            // Documentation: https://esbuild.github.io/api/#precache-manifest
+	ContentManifest    string            // Documentation: https://esbuild.github.io/api/#content-manifest
+	SharedChunkDir     string            // Documentation: https://esbuild.github.io/api/#shared-chunk-dir
+	ServiceWorker      string            // Documentation: https://esbuild.github.io/api/#service-worker
+	CSPReport          string            // Documentation: https://esbuild.github.io/api/#csp-report
+	FeatureReport      string            // Documentation: https://esbuild.github.io/api/#feature-flags
+	CSSOrderReport     string            // Documentation: https://esbuild.github.io/api/#css-order-report
+	NameCache          string            // Documentation: https://esbuild.github.io/api/#name-cache
+	Outdir             string            // Documentation: https://esbuild.github.io/api/#outdir
+	Outbase            string            // Documentation: https://esbuild.github.io/api/#outbase
+	AbsWorkingDir      string            // Documentation: https://esbuild.github.io/api/#working-directory
+	Platform           Platform          // Documentation: https://esbuild.github.io/api/#platform
+	Format             Format            // Documentation: https://esbuild.github.io/api/#format
+	Formats            []Format          // Documentation: https://esbuild.github.io/api/#formats
+	DualPackage        bool              // Documentation: https://esbuild.github.io/api/#dual-package
+	External           []string          // Documentation: https://esbuild.github.io/api/#external
+	Packages           Packages          // Documentation: https://esbuild.github.io/api/#packages
+	ExternalRewrite    map[string]string // Documentation: https://esbuild.github.io/api/#external-rewrite
+	Globals            map[string]string // Documentation: https://esbuild.github.io/api/#globals
+	ExternalHelpers    string            // Documentation: https://esbuild.github.io/api/#external-helpers
+	IsolatePackages    []string          // Documentation: https://esbuild.github.io/api/#isolate-packages
+	IsolateChunks      []string          // Documentation: https://esbuild.github.io/api/#isolate-chunks
+	LazyPackages       []string          // Documentation: https://esbuild.github.io/api/#lazy-packages
+	CSSLayers          map[string]string // Documentation: https://esbuild.github.io/api/#css-layers
+	MainFields         []string          // Documentation: https://esbuild.github.io/api/#main-fields
+	Conditions         []string          // Documentation: https://esbuild.github.io/api/#conditions
+	Loader             map[string]Loader // Documentation: https://esbuild.github.io/api/#loader
+	ResolveExtensions  []string          // Documentation: https://esbuild.github.io/api/#resolve-extensions
+	Tsconfig           string            // Documentation: https://esbuild.github.io/api/#tsconfig
+	TypeCheck          string            // Documentation: https://esbuild.github.io/api/#type-check
+	OutExtensions      map[string]string // Documentation: https://esbuild.github.io/api/#out-extension
+	PublicPath         string            // Documentation: https://esbuild.github.io/api/#public-path
+	Inject             []string          // Documentation: https://esbuild.github.io/api/#inject
+	InjectCSSLink      bool              // Documentation: https://esbuild.github.io/api/#inject-css-link
+	Banner             map[string]string // Documentation: https://esbuild.github.io/api/#banner
+	Footer             map[string]string // Documentation: https://esbuild.github.io/api/#footer
+	NodePaths          []string          // Documentation: https://esbuild.github.io/api/#node-paths
+	DenoDir            string            // Documentation: https://esbuild.github.io/api/#deno-dir
+	Workspaces         map[string]string // Documentation: https://esbuild.github.io/api/#workspaces
+	DetectWorkspaces   bool              // Documentation: https://esbuild.github.io/api/#workspaces
+	Alias              map[string]string // Documentation: https://esbuild.github.io/api/#alias
+	StrictCase         bool              // Documentation: https://esbuild.github.io/api/#strict-case
+	DirectoryImports   DirectoryImports  // Documentation: https://esbuild.github.io/api/#directory-imports
+	IndexExtensions    []string          // Documentation: https://esbuild.github.io/api/#index-extensions
+	BundleDynamicPaths bool              // Documentation: https://esbuild.github.io/api/#bundle-dynamic-paths
+	NodePolyfills      bool              // Documentation: https://esbuild.github.io/api/#node-polyfills
+	RewriteImports     bool              // Documentation: https://esbuild.github.io/api/#rewrite-imports
+	PreserveModules    bool              // Documentation: https://esbuild.github.io/api/#preserve-modules
+	Budgets            OutputBudgets     // Documentation: https://esbuild.github.io/api/#budgets
 
 	EntryNames string // Documentation: https://esbuild.github.io/api/#entry-names
 	ChunkNames string // Documentation: https://esbuild.github.io/api/#chunk-names
 	AssetNames string // Documentation: https://esbuild.github.io/api/#asset-names
 
+	ManualChunks func(ManualChunkArgs) string // Documentation: https://esbuild.github.io/api/#manual-chunks
+	Chunks       map[string][]string          // Documentation: https://esbuild.github.io/api/#manual-chunks
+
 	EntryPoints         []string     // Documentation: https://esbuild.github.io/api/#entry-points
 	EntryPointsAdvanced []EntryPoint // Documentation: https://esbuild.github.io/api/#entry-points
 
 	Stdin          *StdinOptions // Documentation: https://esbuild.github.io/api/#stdin
 	Write          bool          // Documentation: https://esbuild.github.io/api/#write
 	AllowOverwrite bool          // Documentation: https://esbuild.github.io/api/#allow-overwrite
+	OnConflict     OnConflict    // Documentation: https://esbuild.github.io/api/#on-conflict
+	SkipUnchanged  bool          // Documentation: https://esbuild.github.io/api/#skip-unchanged
+	Record         string        // Documentation: https://esbuild.github.io/api/#record
 	Incremental    bool          // Documentation: https://esbuild.github.io/api/#incremental
 	Plugins        []Plugin      // Documentation: https://esbuild.github.io/plugins/
+	PluginTimeout  time.Duration // Documentation: https://esbuild.github.io/plugins/#timeouts
+	Progress       func(ProgressEvent)
+	ImageEncoders  map[string]ImageEncoder
+
+	// Files that are copied to the output directory without being loaded into
+	// memory (e.g. by the "file" loader) are read back in to fill in the
+	// contents of the output files in the result. If this is true and "Write"
+	// is true, they are left out of the result instead.
+	StreamCopiedFiles bool
 
 	Watch *WatchMode // Documentation: https://esbuild.github.io/api/#watch
 }
@@ -319,6 +521,45 @@ type WatchMode struct {
 	OnRebuild func(BuildResult)
 }
 
+// The "ManualChunks" callback is called once for each JS file when code
+// splitting. It returns the name of the chunk to put the file in, or an empty
+// string to split the file automatically. Entry points always get their own
+// chunk and aren't passed to this callback.
+type ManualChunkArgs struct {
+	Path      string
+	Namespace string
+}
+
+type ProgressPhase uint8
+
+const (
+	ProgressScan ProgressPhase = iota
+	ProgressLink
+	ProgressWrite
+)
+
+// The progress callback is never called concurrently. The file counts are
+// only filled in during the scan phase and the entry point counts are only
+// filled in during the link phase. More files may be discovered as the scan
+// phase makes progress, so the total number of files isn't known in advance.
+type ProgressEvent struct {
+	Phase ProgressPhase
+
+	FilesParsed     int
+	FilesDiscovered int
+
+	EntryPointsLinked int
+	EntryPointsTotal  int
+}
+
+// The "image" loader uses an encoder from this map when an image is converted
+// to a format such as "webp" using a query parameter like "?format=webp". The
+// formats "png", "jpeg", and "gif" can be encoded without a custom encoder.
+// The quality is between 1 and 100, or 0 if it wasn't specified. Images are
+// decoded using the standard "image" package, so support for additional input
+// formats can be added with "image.RegisterFormat".
+type ImageEncoder func(img image.Image, quality int) ([]byte, error)
+
 type StdinOptions struct {
 	Contents   string
 	ResolveDir string
@@ -332,14 +573,83 @@ type BuildResult struct {
 
 	OutputFiles []OutputFile
 	Metafile    string
+	NameCache   string // Only when "NameCache" is set. This is the JSON that was written to that file.
+	MangleCache string // Only when "MangleCache" is set. This is the JSON that was written to that file.
 
 	Rebuild func() BuildResult // Only when "Incremental: true"
-	Stop    func()             // Only when "Watch: true"
+
+	// Only when "Incremental: true" or "Watch: true". This changes some of the
+	// options of the later builds (see "UpdateOptions"). When watching, this
+	// also starts a rebuild.
+	Update func(UpdateOptions) error
+
+	// Only when "Watch: true", or when "Incremental: true" and "TypeCheck" is
+	// set. This also stops the type check command. This waits for a rebuild
+	// that's in progress to finish writing its output files, so it must not be
+	// called from inside an "OnEnd" callback.
+	Stop func()
+
+	// This is only present for rebuilds that were triggered by "Watch". It
+	// contains all file system changes that were detected, sorted by path.
+	WatchChanges []WatchChange
+
+	// Only when "AccessList: true". This contains every file and directory that
+	// the build read or checked for, sorted by path.
+	AccessList []FileAccess
+}
+
+// These are the build options that can be changed between the builds of a
+// build context without starting over. Plugins aren't set up again and the
+// caches are kept, so only the files that mention a changed define (or the
+// "console" or "debugger" statements that are dropped) are parsed again. Only
+// the options that are set are changed. Use an empty map to remove all of the
+// defines or banners.
+type UpdateOptions struct {
+	LogLevel *LogLevel
+	Define   map[string]string
+	Banner   map[string]string
+	Drop     *Drop
+}
+
+type WatchChangeKind uint8
+
+const (
+	WatchChangeModified WatchChangeKind = iota
+	WatchChangeAdded
+	WatchChangeDeleted
+)
+
+type WatchChange struct {
+	Path string // This is an absolute path
+	Kind WatchChangeKind
+}
+
+type FileAccessKind uint8
+
+const (
+	FileAccessFile      FileAccessKind = iota // The file was read
+	FileAccessDirectory                       // The directory's entries were read
+	FileAccessMissing                         // The path was checked but doesn't exist
+)
+
+type FileAccess struct {
+	Path string // This is an absolute path
+	Kind FileAccessKind
 }
 
 type OutputFile struct {
-	Path     string
+	Path string
+
+	// This is nil for files that were copied to the output directory directly
+	// from an input file (e.g. by the "file" loader) when both "Write" and
+	// "StreamCopiedFiles" are true, since those files may be too large to keep
+	// in memory.
 	Contents []byte
+
+	// The zero-based lines that the banner and footer start on, or zero if
+	// this file doesn't have a banner or footer
+	BannerLine int
+	FooterLine int
 }
 
 // Documentation: https://esbuild.github.io/api/#build-api
@@ -347,6 +657,104 @@ func Build(options BuildOptions) BuildResult {
 	return buildImpl(options).result
 }
 
+type ValidateBuildOptionsResult struct {
+	Errors   []Message
+	Warnings []Message
+}
+
+// This runs all of the checks that "Build" does on the build options without
+// actually running a build. Note that plugins are not set up, so problems that
+// would be reported by plugins are not reported here.
+func ValidateBuildOptions(options BuildOptions) ValidateBuildOptionsResult {
+	return validateBuildOptionsImpl(options)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Replay API
+
+type ReplayOptions struct {
+	LogLevel LogLevel
+	Color    StderrColor
+
+	// The path to a file written by a build with the "Record" option
+	Record string
+
+	// Maps recorded input file paths to files on disk. The replayed build uses
+	// the contents of these files instead of the recorded contents. Relative
+	// paths are relative to the current working directory.
+	Substitute map[string]string
+
+	// Overrides where the output files go, which is otherwise wherever the
+	// recorded build put them
+	Outdir string
+
+	Write bool
+}
+
+// This runs a build that was recorded with the "Record" option again using
+// the recorded input files instead of the file system. It's intended for
+// retrying a build or for comparing its output when only a few input files
+// are different.
+//
+// Documentation: https://esbuild.github.io/api/#replay
+func Replay(options ReplayOptions) BuildResult {
+	return replayImpl(options)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Context API
+
+// A build context is a long-lived object for running the same build more than
+// once. All builds from the same context share their caches, so only files
+// that have changed are parsed again and only entry points that are affected
+// by those changes are linked again. Plugins are only set up once.
+//
+// Documentation: https://esbuild.github.io/api/#context
+type BuildContext interface {
+	// This runs a build with the current state of the file system. Only one
+	// build runs at a time, so this waits for any build that's in progress.
+	Rebuild() BuildResult
+
+	// This does an initial build and then rebuilds whenever a file that the
+	// build depends on changes. It's an error to call this more than once.
+	Watch(options WatchMode) error
+
+	// This starts a server that serves the latest build results. Builds are
+	// done on demand when requests come in, and they share the caches of the
+	// other builds of this context. The server can't be restarted with new
+	// build options since the options belong to the context. If the context
+	// is also watching and "Servedir" is set, HTML pages from that directory
+	// are reloaded automatically after each successful rebuild.
+	Serve(options ServeOptions) (ServeResult, error)
+
+	// This changes some of the build options of the context. The next build
+	// uses the new options. If the context is watching, this also starts a
+	// rebuild. A build that's in progress finishes with the old options.
+	Update(options UpdateOptions) error
+
+	// This stops watching and serving and releases the caches. The context
+	// can't be used after it has been disposed.
+	Dispose()
+}
+
+type ContextError struct {
+	Errors []Message
+}
+
+func (err *ContextError) Error() string {
+	if len(err.Errors) == 0 {
+		return "Failed to create the build context"
+	}
+	return err.Errors[0].Text
+}
+
+// The "Watch" and "Incremental" options must not be set since contexts have
+// methods for watching and rebuilding instead. Building doesn't start until
+// one of the methods is called.
+func Context(options BuildOptions) (BuildContext, *ContextError) {
+	return contextImpl(options)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Transform API
 
@@ -355,35 +763,67 @@ type TransformOptions struct {
 	LogLimit int         // Documentation: https://esbuild.github.io/api/#log-limit
 	LogLevel LogLevel    // Documentation: https://esbuild.github.io/api/#log-level
 
-	Sourcemap      SourceMap      // Documentation: https://esbuild.github.io/api/#sourcemap
-	SourceRoot     string         // Documentation: https://esbuild.github.io/api/#source-root
-	SourcesContent SourcesContent // Documentation: https://esbuild.github.io/api/#sources-content
+	RecoverSyntaxErrors bool // Documentation: https://esbuild.github.io/api/#recover-syntax-errors
+
+	Sourcemap        SourceMap      // Documentation: https://esbuild.github.io/api/#sourcemap
+	SourceRoot       string         // Documentation: https://esbuild.github.io/api/#source-root
+	SourcesContent   SourcesContent // Documentation: https://esbuild.github.io/api/#sources-content
+	SourcemapBanners bool           // Documentation: https://esbuild.github.io/api/#sourcemap-banners
 
 	Target  Target   // Documentation: https://esbuild.github.io/api/#target
 	Engines []Engine // Documentation: https://esbuild.github.io/api/#target
 
-	Format     Format // Documentation: https://esbuild.github.io/api/#format
-	GlobalName string // Documentation: https://esbuild.github.io/api/#global-name
+	Supported     map[string]bool // Documentation: https://esbuild.github.io/api/#supported
+	SupportedFile string          // A JSON file that maps feature names to true or false, relative to the current directory. Entries in "Supported" take precedence.
+
+	Format          Format // Documentation: https://esbuild.github.io/api/#format
+	GlobalName      string // Documentation: https://esbuild.github.io/api/#global-name
+	ExternalHelpers string // Documentation: https://esbuild.github.io/api/#external-helpers
 
 	MinifyWhitespace  bool          // Documentation: https://esbuild.github.io/api/#minify
 	MinifyIdentifiers bool          // Documentation: https://esbuild.github.io/api/#minify
 	MinifySyntax      bool          // Documentation: https://esbuild.github.io/api/#minify
+	MinifySeed        string        // Documentation: https://esbuild.github.io/api/#minify
 	Charset           Charset       // Documentation: https://esbuild.github.io/api/#charset
+	CharsetEscape     []string      // Documentation: https://esbuild.github.io/api/#charset
+	IdentifierCharset Charset       // Documentation: https://esbuild.github.io/api/#charset
 	TreeShaking       TreeShaking   // Documentation: https://esbuild.github.io/api/#tree-shaking
 	IgnoreAnnotations bool          // Documentation: https://esbuild.github.io/api/#ignore-annotations
+	InferPure         bool          // Documentation: https://esbuild.github.io/api/#infer-pure
 	LegalComments     LegalComments // Documentation: https://esbuild.github.io/api/#legal-comments
+	Annotations       Annotations   // Documentation: https://esbuild.github.io/api/#annotations
+	MagicComments     Annotations   // Documentation: https://esbuild.github.io/api/#magic-comments
+	LineEnding        LineEnding    // Documentation: https://esbuild.github.io/api/#line-ending
+	Indent            string        // Documentation: https://esbuild.github.io/api/#indent
 
-	JSXMode     JSXMode // Documentation: https://esbuild.github.io/api/#jsx
-	JSXFactory  string  // Documentation: https://esbuild.github.io/api/#jsx-factory
-	JSXFragment string  // Documentation: https://esbuild.github.io/api/#jsx-fragment
+	IdentifierNormalization Normalization // Documentation: https://esbuild.github.io/api/#charset
+
+	JSXMode         JSXMode // Documentation: https://esbuild.github.io/api/#jsx
+	JSXFactory      string  // Documentation: https://esbuild.github.io/api/#jsx-factory
+	JSXFragment     string  // Documentation: https://esbuild.github.io/api/#jsx-fragment
+	JSXImportSource string  // Documentation: https://esbuild.github.io/api/#jsx-import-source
+
+	TSVersion string  // Documentation: https://esbuild.github.io/api/#ts-version
+	TSEnums   TSEnums // Documentation: https://esbuild.github.io/api/#ts-enums
 
 	TsconfigRaw string // Documentation: https://esbuild.github.io/api/#tsconfig-raw
 	Banner      string // Documentation: https://esbuild.github.io/api/#banner
 	Footer      string // Documentation: https://esbuild.github.io/api/#footer
 
-	Define    map[string]string // Documentation: https://esbuild.github.io/api/#define
-	Pure      []string          // Documentation: https://esbuild.github.io/api/#pure
-	KeepNames bool              // Documentation: https://esbuild.github.io/api/#keep-names
+	Define       map[string]string // Documentation: https://esbuild.github.io/api/#define
+	FeatureFlags map[string]bool   // Documentation: https://esbuild.github.io/api/#feature-flags
+	Pure         []string          // Documentation: https://esbuild.github.io/api/#pure
+	Drop         Drop              // Documentation: https://esbuild.github.io/api/#drop
+	KeepNames    bool              // Documentation: https://esbuild.github.io/api/#keep-names
+	Pragmas      []string          // Documentation: https://esbuild.github.io/api/#pragmas
+
+	PreserveComments string // Documentation: https://esbuild.github.io/api/#preserve-comments
+
+	MangleProps  string // Documentation: https://esbuild.github.io/api/#mangle-props
+	ReserveProps string // Documentation: https://esbuild.github.io/api/#mangle-props
+
+	StripIf      []string          // Documentation: https://esbuild.github.io/api/#strip-if
+	StripBetween map[string]string // Documentation: https://esbuild.github.io/api/#strip-between
 
 	Sourcefile string // Documentation: https://esbuild.github.io/api/#sourcefile
 	Loader     Loader // Documentation: https://esbuild.github.io/api/#loader
@@ -395,6 +835,11 @@ type TransformResult struct {
 
 	Code []byte
 	Map  []byte
+
+	// The zero-based lines that the banner and footer start on in "Code", or
+	// zero if there isn't a banner or footer
+	BannerLine int
+	FooterLine int
 }
 
 // Documentation: https://esbuild.github.io/api/#transform-api
@@ -402,6 +847,42 @@ func Transform(input string, options TransformOptions) TransformResult {
 	return transformImpl(input, options)
 }
 
+type CSSTransformOptions struct {
+	Color    StderrColor // Documentation: https://esbuild.github.io/api/#color
+	LogLimit int         // Documentation: https://esbuild.github.io/api/#log-limit
+	LogLevel LogLevel    // Documentation: https://esbuild.github.io/api/#log-level
+
+	Sourcemap      SourceMap      // Documentation: https://esbuild.github.io/api/#sourcemap
+	SourceRoot     string         // Documentation: https://esbuild.github.io/api/#source-root
+	SourcesContent SourcesContent // Documentation: https://esbuild.github.io/api/#sources-content
+
+	Target  Target   // Documentation: https://esbuild.github.io/api/#target
+	Engines []Engine // Documentation: https://esbuild.github.io/api/#target
+
+	Supported map[string]bool // Documentation: https://esbuild.github.io/api/#supported
+
+	MinifyWhitespace bool          // Documentation: https://esbuild.github.io/api/#minify
+	MinifySyntax     bool          // Documentation: https://esbuild.github.io/api/#minify
+	Charset          Charset       // Documentation: https://esbuild.github.io/api/#charset
+	CharsetEscape    []string      // Documentation: https://esbuild.github.io/api/#charset
+	LegalComments    LegalComments // Documentation: https://esbuild.github.io/api/#legal-comments
+	Indent           string        // Documentation: https://esbuild.github.io/api/#indent
+
+	// If true, the input is a list of declarations without a surrounding rule
+	// (such as the contents of a "style" attribute) instead of a stylesheet.
+	Declarations bool
+
+	Sourcefile string // Documentation: https://esbuild.github.io/api/#sourcefile
+}
+
+// This parses, lowers, and prints a single CSS string. Unlike calling
+// "Transform" with the "css" loader, this doesn't go through the bundler so
+// it's cheaper to call many times (for CSS-in-JS libraries, for example).
+// The "Map" field of the result is only set for external source maps.
+func TransformCSS(input string, options CSSTransformOptions) TransformResult {
+	return transformCSSImpl(input, options)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Serve API
 
@@ -411,6 +892,28 @@ type ServeOptions struct {
 	Host      string
 	Servedir  string
 	OnRequest func(ServeOnRequestArgs)
+
+	// Each project is a separate build that is served under its URL path
+	// prefix (e.g. "/app1") instead of at the root. All builds in the same
+	// server share their parsing caches. Restarting the server only changes
+	// the build options of the main build.
+	Projects map[string]BuildOptions
+
+	// Responses for URL paths that match one of these rules are slowed down to
+	// simulate a slow network. The first rule that matches is used.
+	Throttle []ServeThrottle
+
+	// If present, requests that don't match an output file or a file in
+	// "Servedir" are forwarded to this origin (e.g. "http://localhost:3000")
+	// instead of failing with a 404. This includes all non-GET requests.
+	Proxy string
+}
+
+// Documentation: https://esbuild.github.io/api/#serve-throttle
+type ServeThrottle struct {
+	Pattern        string // The URL path, where "*" matches any sequence of characters
+	LatencyInMS    int    // The delay before the response starts
+	BytesPerSecond int    // The maximum rate at which the response body is sent
 }
 
 type ServeOnRequestArgs struct {
@@ -426,12 +929,21 @@ type ServeResult struct {
 	Port uint16
 	Host string
 	Wait func() error
-	Stop func()
+
+	// A graceful stop stops accepting new connections and then waits for all
+	// active connections to finish before returning. Otherwise all connections
+	// are closed immediately.
+	Stop func(graceful bool)
+
+	// This applies new build options without closing the server. The next
+	// build starts from scratch instead of reusing incremental state from the
+	// previous build options. The working directory can't be changed.
+	Restart func(buildOptions BuildOptions) error
 }
 
 // Documentation: https://esbuild.github.io/api/#serve
 func Serve(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResult, error) {
-	return serveImpl(serveOptions, buildOptions)
+	return serveImpl(serveOptions, buildOptions, nil)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -444,17 +956,26 @@ const (
 	SideEffectsFalse
 )
 
+// Failures of optional plugins (panics, thrown errors, and timeouts) are
+// reported as warnings and the failed callback is skipped instead of failing
+// the build. Errors that a plugin returns in its results are still errors.
 type Plugin struct {
-	Name  string
-	Setup func(PluginBuild)
+	Name     string
+	Setup    func(PluginBuild)
+	Optional bool
 }
 
+// The "OnEnd" callbacks of a successful build run before the output files are
+// written, so they can change, remove, or add output files. Replace the
+// contents of an output file instead of modifying them in place.
 type PluginBuild struct {
 	InitialOptions *BuildOptions
 	OnStart        func(callback func() (OnStartResult, error))
 	OnEnd          func(callback func(result *BuildResult))
 	OnResolve      func(options OnResolveOptions, callback func(OnResolveArgs) (OnResolveResult, error))
 	OnLoad         func(options OnLoadOptions, callback func(OnLoadArgs) (OnLoadResult, error))
+	OnTransform    func(options OnTransformOptions, callback func(OnTransformArgs) (OnTransformResult, error))
+	OnSourceMap    func(options OnSourceMapOptions, callback func(OnSourceMapArgs) (OnSourceMapResult, error))
 }
 
 type OnStartResult struct {
@@ -514,10 +1035,76 @@ type OnLoadResult struct {
 	Loader     Loader
 	PluginData interface{}
 
+	// These can be used instead of "Contents" for very large files. With the
+	// "file" loader, these files are copied to the output directory without
+	// ever being loaded into memory all at once. A reader is read to the end
+	// into a temporary file that is deleted when the build ends.
+	ContentsFile   string
+	ContentsReader io.Reader
+
+	WatchFiles []string
+	WatchDirs  []string
+}
+
+type OnTransformOptions struct {
+	Filter    string
+	Namespace string
+}
+
+type OnTransformArgs struct {
+	Path       string
+	Namespace  string
+	PluginData interface{}
+	Loader     Loader
+	Contents   string
+
+	// This is the source map returned by the previous transform callback, or
+	// an empty string if there isn't one
+	SourceMap string
+}
+
+type OnTransformResult struct {
+	PluginName string
+
+	Errors   []Message
+	Warnings []Message
+
+	// Every matching transform callback is run in order, and each one is given
+	// the contents returned by the previous one. Returning nil contents leaves
+	// the contents unchanged. New contents should come with a source map that
+	// maps them back to the original file, or source maps will be incorrect.
+	Contents  *string
+	SourceMap string
+
 	WatchFiles []string
 	WatchDirs  []string
 }
 
+type OnSourceMapOptions struct {
+	Filter string // This is matched against the path of the output file
+}
+
+type OnSourceMapArgs struct {
+	Path          string // The output file that the source map is for
+	SourceMapPath string // This is empty if the source map is only inlined
+
+	// This is the parsed JSON of the source map
+	SourceMap map[string]interface{}
+}
+
+type OnSourceMapResult struct {
+	PluginName string
+
+	Errors   []Message
+	Warnings []Message
+
+	// Every matching source map callback is run in order, and each one is given
+	// the source map returned by the previous one. Returning nil leaves the
+	// source map unchanged. The source map is serialized by esbuild, which also
+	// generates the source map comment in the output file.
+	SourceMap map[string]interface{}
+}
+
 type ResolveKind uint8
 
 const (
@@ -528,6 +1115,7 @@ const (
 	ResolveJSRequireResolve
 	ResolveCSSImportRule
 	ResolveCSSURLToken
+	ResolveCSSComposesFrom
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -556,6 +1144,10 @@ func FormatMessages(msgs []Message, opts FormatMessagesOptions) []string {
 type AnalyzeMetafileOptions struct {
 	Color   bool
 	Verbose bool
+
+	// If this is set, only input files from inside this package's directory in
+	// "node_modules" are included in the report
+	Filter string
 }
 
 // Documentation: https://esbuild.github.io/api/#analyze
@@ -1,18 +1,29 @@
 package api
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"image"
+	"io"
 	"io/ioutil"
 	"math"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/evanw/esbuild/internal/api_helpers"
@@ -21,14 +32,21 @@ import (
 	"github.com/evanw/esbuild/internal/cache"
 	"github.com/evanw/esbuild/internal/compat"
 	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/css_ast"
+	"github.com/evanw/esbuild/internal/css_lexer"
+	"github.com/evanw/esbuild/internal/css_parser"
+	"github.com/evanw/esbuild/internal/css_printer"
 	"github.com/evanw/esbuild/internal/fs"
 	"github.com/evanw/esbuild/internal/graph"
 	"github.com/evanw/esbuild/internal/helpers"
 	"github.com/evanw/esbuild/internal/js_ast"
 	"github.com/evanw/esbuild/internal/js_lexer"
 	"github.com/evanw/esbuild/internal/js_parser"
+	"github.com/evanw/esbuild/internal/js_printer"
 	"github.com/evanw/esbuild/internal/logger"
 	"github.com/evanw/esbuild/internal/resolver"
+	"github.com/evanw/esbuild/internal/sourcemap"
+	"github.com/evanw/esbuild/internal/xxhash"
 )
 
 func validatePathTemplate(template string) []config.PathTemplate {
@@ -120,6 +138,8 @@ func validateFormat(value Format) config.Format {
 		return config.FormatCommonJS
 	case FormatESModule:
 		return config.FormatESModule
+	case FormatUMD:
+		return config.FormatUMD
 	default:
 		panic("Invalid format")
 	}
@@ -165,6 +185,136 @@ func validateLegalComments(value LegalComments, bundle bool) config.LegalComment
 	}
 }
 
+func validateAnnotations(value Annotations) config.Annotations {
+	switch value {
+	case AnnotationsDefault:
+		return config.AnnotationsDefault
+	case AnnotationsAlways:
+		return config.AnnotationsAlways
+	case AnnotationsNever:
+		return config.AnnotationsNever
+	default:
+		panic("Invalid annotations")
+	}
+}
+
+func validateFormatAnnotations(values map[Format]Annotations) map[config.Format]config.Annotations {
+	if len(values) == 0 {
+		return nil
+	}
+	result := make(map[config.Format]config.Annotations, len(values))
+	for format, value := range values {
+		result[validateFormat(format)] = validateAnnotations(value)
+	}
+	return result
+}
+
+// The older "AllowOverwrite" option is the same as "OnConflictOverwrite"
+func validateOnConflict(value OnConflict, allowOverwrite bool) config.OnConflict {
+	switch value {
+	case OnConflictDefault:
+		if allowOverwrite {
+			return config.OnConflictOverwrite
+		}
+		return config.OnConflictError
+	case OnConflictError:
+		return config.OnConflictError
+	case OnConflictRename:
+		return config.OnConflictRename
+	case OnConflictOverwrite:
+		return config.OnConflictOverwrite
+	default:
+		panic("Invalid on conflict")
+	}
+}
+
+func validateDirectoryImports(value DirectoryImports) config.DirectoryImports {
+	switch value {
+	case DirectoryImportsDefault, DirectoryImportsCommonJS:
+		return config.DirectoryImportsCommonJS
+	case DirectoryImportsNoIndex:
+		return config.DirectoryImportsNoIndex
+	case DirectoryImportsNodeESM:
+		return config.DirectoryImportsNodeESM
+	default:
+		panic("Invalid directory imports")
+	}
+}
+
+func validateLineEnding(value LineEnding) config.LineEnding {
+	switch value {
+	case LineEndingDefault, LineEndingLF:
+		return config.LineEndingLF
+	case LineEndingCRLF:
+		return config.LineEndingCRLF
+	default:
+		panic("Invalid line ending")
+	}
+}
+
+// The indent is either a number of spaces or "tab"
+func validateIndent(log logger.Log, value string) string {
+	if value == "" {
+		return ""
+	}
+	if value == "tab" {
+		return "\t"
+	}
+	if n, err := strconv.ParseUint(value, 10, 8); err == nil && n >= 1 && n <= 8 {
+		return strings.Repeat(" ", int(n))
+	}
+	log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid indent: %q (expected a number of spaces from 1 to 8 or \"tab\")", value))
+	return ""
+}
+
+func validateSplittingPreset(value SplittingPreset) config.SplittingPreset {
+	switch value {
+	case SplittingPresetNone:
+		return config.SplittingPresetNone
+	case SplittingPresetVendor:
+		return config.SplittingPresetVendor
+	default:
+		panic("Invalid splitting preset")
+	}
+}
+
+func validateTSEnums(value TSEnums) config.TSEnums {
+	switch value {
+	case TSEnumsClassic:
+		return config.TSEnumsClassic
+	case TSEnumsFrozen:
+		return config.TSEnumsFrozen
+	case TSEnumsInline:
+		return config.TSEnumsInline
+	default:
+		panic("Invalid TypeScript enums")
+	}
+}
+
+func validateDynamicImportLoader(value DynamicImportLoader) config.DynamicImportLoader {
+	switch value {
+	case DynamicImportLoaderRequire:
+		return config.DynamicImportLoaderRequire
+	case DynamicImportLoaderScript:
+		return config.DynamicImportLoaderScript
+	default:
+		panic("Invalid dynamic import loader")
+	}
+}
+
+func validateUnusedExports(value UnusedExports) config.UnusedExports {
+	switch value {
+	case UnusedExportsIgnore:
+		return config.UnusedExportsIgnore
+	case UnusedExportsWarning:
+		return config.UnusedExportsWarning
+	case UnusedExportsError:
+		return config.UnusedExportsError
+	default:
+		panic("Invalid unused exports mode")
+	}
+}
+
 func validateColor(value StderrColor) logger.UseColor {
 	switch value {
 	case ColorIfTerminal:
@@ -208,6 +358,140 @@ func validateASCIIOnly(value Charset) bool {
 	}
 }
 
+func validateIdentifierCharset(value Charset) config.IdentifierCharset {
+	switch value {
+	case CharsetDefault:
+		return config.IdentifierCharsetSameAsStrings
+	case CharsetASCII:
+		return config.IdentifierCharsetASCII
+	case CharsetUTF8:
+		return config.IdentifierCharsetUTF8
+	default:
+		panic("Invalid charset")
+	}
+}
+
+func validateNormalization(value Normalization) config.Normalization {
+	switch value {
+	case NormalizationNone:
+		return config.NormalizationNone
+	case NormalizationNFC:
+		return config.NormalizationNFC
+	case NormalizationNFD:
+		return config.NormalizationNFD
+	default:
+		panic("Invalid normalization")
+	}
+}
+
+func validateCharsetEscapes(log logger.Log, values []string) (result config.CharsetEscapes) {
+	for _, value := range values {
+		if value == "non-bmp" {
+			result.NonBMP = true
+		} else if script, ok := unicode.Scripts[value]; ok {
+			result.Scripts = append(result.Scripts, script)
+		} else {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"Invalid charset escape: %q (valid: \"non-bmp\" or a Unicode script name such as \"Han\")", value))
+		}
+	}
+	return
+}
+
+func validatePragmas(log logger.Log, names []string) []string {
+	for _, name := range names {
+		if name == "" || strings.ContainsAny(name, " \t\r\n@*/") {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid pragma: %q", name))
+		}
+	}
+	return names
+}
+
+func validateTSVersion(log logger.Log, version string) config.TSVersion {
+	if version == "" {
+		return config.TSVersion{}
+	}
+
+	// Accept "major.minor" and "major.minor.patch" (the patch is ignored)
+	parts := strings.Split(version, ".")
+	if len(parts) == 2 || len(parts) == 3 {
+		if major, err := strconv.ParseUint(parts[0], 10, 8); err == nil {
+			if minor, err := strconv.ParseUint(parts[1], 10, 8); err == nil {
+				if len(parts) == 2 || parts[2] != "" {
+					return config.TSVersion{Major: uint8(major), Minor: uint8(minor)}
+				}
+			}
+		}
+	}
+
+	log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid TypeScript version: %q (expected a version such as \"4.9\")", version))
+	return config.TSVersion{}
+}
+
+func validateRegExp(log logger.Log, pattern string, what string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	result, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Invalid regular expression for %s: %q (%s)", what, pattern, err.Error()))
+		return nil
+	}
+	return result
+}
+
+func validateStripIf(log logger.Log, names []string) [][]string {
+	var result [][]string
+	for _, name := range names {
+		parts := strings.Split(name, ".")
+		isValid := true
+		for _, part := range parts {
+			if !js_lexer.IsIdentifier(part) {
+				isValid = false
+				break
+			}
+		}
+		if !isValid {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"Invalid strip condition: %q (expected a name such as \"import.meta.vitest\")", name))
+			continue
+		}
+		result = append(result, parts)
+	}
+	return result
+}
+
+func validateStripBetween(log logger.Log, markers map[string]string) []config.StripMarkers {
+	if len(markers) == 0 {
+		return nil
+	}
+
+	// Sort the markers so the parser options are deterministic
+	starts := make([]string, 0, len(markers))
+	for start := range markers {
+		starts = append(starts, start)
+	}
+	sort.Strings(starts)
+
+	result := make([]config.StripMarkers, 0, len(starts))
+	for _, start := range starts {
+		end := markers[start]
+		if strings.TrimSpace(start) == "" || strings.TrimSpace(end) == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"Invalid strip markers: %q and %q (markers cannot be empty)", start, end))
+			continue
+		}
+		if strings.Contains(start, "*/") || strings.Contains(end, "*/") {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"Invalid strip markers: %q and %q (markers cannot contain \"*/\")", start, end))
+			continue
+		}
+		result = append(result, config.StripMarkers{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)})
+	}
+	return result
+}
+
 func validateTreeShaking(value TreeShaking, bundle bool, format Format) bool {
 	switch value {
 	case TreeShakingDefault:
@@ -217,7 +501,7 @@ func validateTreeShaking(value TreeShaking, bundle bool, format Format) bool {
 		// code to the bundle, you should be doing that by including it in the
 		// bundle instead of concatenating it afterward, so we also assume tree
 		// shaking is safe then. Otherwise we assume tree shaking is not safe.
-		return bundle || format == FormatIIFE
+		return bundle || format == FormatIIFE || format == FormatUMD
 	case TreeShakingFalse:
 		return false
 	case TreeShakingTrue:
@@ -241,6 +525,14 @@ func validateLoader(value Loader) config.Loader {
 		return config.LoaderTSX
 	case LoaderJSON:
 		return config.LoaderJSON
+	case LoaderJSONC:
+		return config.LoaderJSONC
+	case LoaderJSON5:
+		return config.LoaderJSON5
+	case LoaderYAML:
+		return config.LoaderYAML
+	case LoaderTOML:
+		return config.LoaderTOML
 	case LoaderText:
 		return config.LoaderText
 	case LoaderBase64:
@@ -249,10 +541,18 @@ func validateLoader(value Loader) config.Loader {
 		return config.LoaderDataURL
 	case LoaderFile:
 		return config.LoaderFile
+	case LoaderCopy:
+		return config.LoaderCopy
 	case LoaderBinary:
 		return config.LoaderBinary
 	case LoaderCSS:
 		return config.LoaderCSS
+	case LoaderLocalCSS:
+		return config.LoaderLocalCSS
+	case LoaderWebManifest:
+		return config.LoaderWebManifest
+	case LoaderImage:
+		return config.LoaderImage
 	case LoaderDefault:
 		return config.LoaderDefault
 	default:
@@ -260,6 +560,53 @@ func validateLoader(value Loader) config.Loader {
 	}
 }
 
+func loaderFromConfig(value config.Loader) Loader {
+	switch value {
+	case config.LoaderJS:
+		return LoaderJS
+	case config.LoaderJSX:
+		return LoaderJSX
+	case config.LoaderTS, config.LoaderTSNoAmbiguousLessThan:
+		return LoaderTS
+	case config.LoaderTSX:
+		return LoaderTSX
+	case config.LoaderJSON:
+		return LoaderJSON
+	case config.LoaderJSONC:
+		return LoaderJSONC
+	case config.LoaderJSON5:
+		return LoaderJSON5
+	case config.LoaderYAML:
+		return LoaderYAML
+	case config.LoaderTOML:
+		return LoaderTOML
+	case config.LoaderText:
+		return LoaderText
+	case config.LoaderBase64:
+		return LoaderBase64
+	case config.LoaderDataURL:
+		return LoaderDataURL
+	case config.LoaderFile:
+		return LoaderFile
+	case config.LoaderCopy:
+		return LoaderCopy
+	case config.LoaderBinary:
+		return LoaderBinary
+	case config.LoaderCSS:
+		return LoaderCSS
+	case config.LoaderLocalCSS:
+		return LoaderLocalCSS
+	case config.LoaderWebManifest:
+		return LoaderWebManifest
+	case config.LoaderImage:
+		return LoaderImage
+	case config.LoaderDefault:
+		return LoaderDefault
+	default:
+		return LoaderNone
+	}
+}
+
 func validateEngine(value EngineName) compat.Engine {
 	switch value {
 	case EngineChrome:
@@ -281,9 +628,32 @@ func validateEngine(value EngineName) compat.Engine {
 
 var versionRegex = regexp.MustCompile(`^([0-9]+)(?:\.([0-9]+))?(?:\.([0-9]+))?$`)
 
-func validateFeatures(log logger.Log, target Target, engines []Engine) (config.TargetFromAPI, compat.JSFeature, compat.CSSFeature, string) {
+func parseEngineVersion(text string) ([]int, bool) {
+	if match := versionRegex.FindStringSubmatch(text); match != nil {
+		if major, err := strconv.Atoi(match[1]); err == nil {
+			version := []int{major}
+			if minor, err := strconv.Atoi(match[2]); err == nil {
+				version = append(version, minor)
+			}
+			if patch, err := strconv.Atoi(match[3]); err == nil {
+				version = append(version, patch)
+			}
+			return version, true
+		}
+	}
+	return nil, false
+}
+
+func validateFeatures(log logger.Log, target Target, engines []Engine, supported map[string]bool) (config.TargetFromAPI, compat.JSFeature, compat.CSSFeature, string) {
 	if target == DefaultTarget && len(engines) == 0 {
-		return config.TargetWasUnconfigured, 0, 0, ""
+		if len(supported) == 0 {
+			return config.TargetWasUnconfigured, 0, 0, ""
+		}
+
+		// A custom feature matrix is a target too, so don't let the "target" in
+		// "tsconfig.json" add more unsupported features on top of it
+		jsFeatures, cssFeatures := validateSupported(log, 0, 0, supported)
+		return config.TargetWasConfigured, jsFeatures, cssFeatures, ""
 	}
 
 	constraints := make(map[compat.Engine][]int)
@@ -315,33 +685,24 @@ func validateFeatures(log logger.Log, target Target, engines []Engine) (config.T
 	}
 
 	for _, engine := range engines {
-		if match := versionRegex.FindStringSubmatch(engine.Version); match != nil {
-			if major, err := strconv.Atoi(match[1]); err == nil {
-				version := []int{major}
-				if minor, err := strconv.Atoi(match[2]); err == nil {
-					version = append(version, minor)
-				}
-				if patch, err := strconv.Atoi(match[3]); err == nil {
-					version = append(version, patch)
-				}
-				switch engine.Name {
-				case EngineChrome:
-					constraints[compat.Chrome] = version
-				case EngineEdge:
-					constraints[compat.Edge] = version
-				case EngineFirefox:
-					constraints[compat.Firefox] = version
-				case EngineIOS:
-					constraints[compat.IOS] = version
-				case EngineNode:
-					constraints[compat.Node] = version
-				case EngineSafari:
-					constraints[compat.Safari] = version
-				default:
-					panic("Invalid engine name")
-				}
-				continue
+		if version, ok := parseEngineVersion(engine.Version); ok {
+			switch engine.Name {
+			case EngineChrome:
+				constraints[compat.Chrome] = version
+			case EngineEdge:
+				constraints[compat.Edge] = version
+			case EngineFirefox:
+				constraints[compat.Firefox] = version
+			case EngineIOS:
+				constraints[compat.IOS] = version
+			case EngineNode:
+				constraints[compat.Node] = version
+			case EngineSafari:
+				constraints[compat.Safari] = version
+			default:
+				panic("Invalid engine name")
 			}
+			continue
 		}
 
 		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid version: %q", engine.Version))
@@ -363,7 +724,158 @@ func validateFeatures(log logger.Log, target Target, engines []Engine) (config.T
 	sort.Strings(targets)
 	targetEnv := strings.Join(targets, ", ")
 
-	return targetFromAPI, compat.UnsupportedJSFeatures(constraints), compat.UnsupportedCSSFeatures(constraints), targetEnv
+	jsFeatures, cssFeatures := validateSupported(log, compat.UnsupportedJSFeatures(constraints), compat.UnsupportedCSSFeatures(constraints), supported)
+	return targetFromAPI, jsFeatures, cssFeatures, targetEnv
+}
+
+// Explicit feature support overrides whatever was derived from the target
+// environments, which lets people describe engines that esbuild doesn't know
+func validateSupported(log logger.Log, jsFeatures compat.JSFeature, cssFeatures compat.CSSFeature, supported map[string]bool) (compat.JSFeature, compat.CSSFeature) {
+	names := make([]string, 0, len(supported))
+	for name := range supported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if feature, ok := compat.StringToJSFeature[name]; ok {
+			if supported[name] {
+				jsFeatures &= ^feature
+			} else {
+				jsFeatures |= feature
+			}
+		} else if feature, ok := compat.StringToCSSFeature[name]; ok {
+			if supported[name] {
+				cssFeatures &= ^feature
+			} else {
+				cssFeatures |= feature
+			}
+		} else {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid feature name: %q", name))
+		}
+	}
+
+	return jsFeatures, cssFeatures
+}
+
+// Feature overrides can also come from a JSON file that maps feature names to
+// true or false. The overrides in "supported" take precedence over the file.
+func loadSupportedFile(log logger.Log, fs fs.FS, path string, supported map[string]bool) map[string]bool {
+	absPath := validatePath(log, fs, path, "feature support file")
+	if absPath == "" {
+		return supported
+	}
+	contents, err, _ := fs.ReadFile(absPath)
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Failed to read feature support file %q: %s", path, err.Error()))
+		return supported
+	}
+	var result map[string]bool
+	if err := json.Unmarshal([]byte(contents), &result); err != nil || result == nil {
+		text := fmt.Sprintf("Failed to parse feature support file %q", path)
+		if err != nil {
+			text += ": " + err.Error()
+		}
+		log.AddWithNotes(logger.Error, nil, logger.Range{}, text,
+			[]logger.MsgData{{Text: "The file must contain a JSON object that maps feature names to true or false."}})
+		return supported
+	}
+	for name, isSupported := range supported {
+		result[name] = isSupported
+	}
+	return result
+}
+
+// This is used to warn about packages that need a newer version of node
+func validateNodeTarget(engines []Engine) []int {
+	for _, engine := range engines {
+		if engine.Name == EngineNode {
+			if version, ok := parseEngineVersion(engine.Version); ok {
+				return version
+			}
+		}
+	}
+	return nil
+}
+
+// With "infer-target", the oldest version of node allowed by the "engines"
+// field in the nearest "package.json" file is added to the engines. This keeps
+// the syntax that's lowered in sync with the versions the project says it
+// supports.
+func inferTargetFromPackageJSON(log logger.Log, realFS fs.FS, buildOpts BuildOptions) []Engine {
+	engines := buildOpts.Engines
+	if buildOpts.Platform != PlatformNode {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot infer the target from \"package.json\" unless the platform is \"node\"")
+		return engines
+	}
+	for _, engine := range engines {
+		if engine.Name == EngineNode {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot infer the target from \"package.json\" when a node target is also configured")
+			return engines
+		}
+	}
+
+	// Search for "package.json" starting from the working directory
+	dir := realFS.Cwd()
+	for {
+		absPath := realFS.Join(dir, "package.json")
+		if contents, err, _ := realFS.ReadFile(absPath); err == nil {
+			prettyPath, ok := realFS.Rel(realFS.Cwd(), absPath)
+			if !ok {
+				prettyPath = absPath
+			}
+			source := logger.Source{
+				KeyPath:    logger.Path{Text: absPath, Namespace: "file"},
+				PrettyPath: prettyPath,
+				Contents:   contents,
+			}
+			return inferTargetFromPackageJSONSource(log, source, engines)
+		}
+		parent := realFS.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	log.Add(logger.Warning, nil, logger.Range{}, "Cannot infer the target because there is no \"package.json\" file")
+	return engines
+}
+
+func inferTargetFromPackageJSONSource(log logger.Log, source logger.Source, engines []Engine) []Engine {
+	tracker := logger.MakeLineColumnTracker(&source)
+	json, ok := js_parser.ParseJSON(log, source, js_parser.JSONOptions{})
+	if !ok {
+		return engines
+	}
+	getProperty := func(expr js_ast.Expr, name string) (js_ast.Expr, bool) {
+		if object, ok := expr.Data.(*js_ast.EObject); ok {
+			for _, property := range object.Properties {
+				if key, ok := property.Key.Data.(*js_ast.EString); ok && js_lexer.UTF16ToString(key.Value) == name {
+					return property.ValueOrNil, true
+				}
+			}
+		}
+		return js_ast.Expr{}, false
+	}
+
+	if enginesJSON, ok := getProperty(json, "engines"); ok {
+		if nodeJSON, ok := getProperty(enginesJSON, "node"); ok {
+			if str, ok := nodeJSON.Data.(*js_ast.EString); ok {
+				text := js_lexer.UTF16ToString(str.Value)
+				if version, ok := compat.MinVersionOfRange(text); !ok {
+					log.Add(logger.Warning, &tracker, source.RangeOfString(nodeJSON.Loc),
+						fmt.Sprintf("Cannot infer the target from the version range %q", text))
+				} else if compat.CompareVersionArrays(version, nil) > 0 {
+					return append(append([]Engine{}, engines...), Engine{Name: EngineNode, Version: compat.VersionToString(version)})
+				}
+				return engines
+			}
+		}
+	}
+
+	log.Add(logger.Warning, nil, logger.Range{}, fmt.Sprintf(
+		"Cannot infer the target because %q has no \"engines.node\" field", source.PrettyPath))
+	return engines
 }
 
 func validateGlobalName(log logger.Log, text string) []string {
@@ -406,72 +918,368 @@ func validateExternals(log logger.Log, fs fs.FS, paths []string) config.External
 	return result
 }
 
-func isValidExtension(ext string) bool {
-	return len(ext) >= 2 && ext[0] == '.' && ext[len(ext)-1] != '.'
+// Paths with a rewrite rule are implicitly external
+func sortedExternalRewriteKeys(rewrites map[string]string) []string {
+	keys := make([]string, 0, len(rewrites))
+	for key := range rewrites {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func validateResolveExtensions(log logger.Log, order []string) []string {
-	if order == nil {
-		return []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
+// Paths mapped to a global variable are implicitly external too. These must
+// match the import path exactly since the path identifies the variable.
+func validateExternalGlobals(log logger.Log, globals map[string]string) map[string][]string {
+	if len(globals) == 0 {
+		return nil
 	}
-	for _, ext := range order {
-		if !isValidExtension(ext) {
-			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid file extension: %q", ext))
+	result := make(map[string][]string, len(globals))
+	for _, path := range sortedExternalRewriteKeys(globals) {
+		name := globals[path]
+		if strings.ContainsRune(path, '*') {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("External path %q cannot use a \"*\" wildcard with a global name", path))
+			continue
 		}
-	}
-	return order
-}
-
-func validateLoaders(log logger.Log, loaders map[string]Loader) map[string]config.Loader {
-	result := bundler.DefaultExtensionToLoaderMap()
-	if loaders != nil {
-		for ext, loader := range loaders {
-			if !isValidExtension(ext) {
-				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid file extension: %q", ext))
-			}
-			result[ext] = validateLoader(loader)
+		if name == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Missing global name for external path %q", path))
+			continue
+		}
+		if parts := validateGlobalName(log, name); parts != nil {
+			result[path] = parts
 		}
 	}
 	return result
 }
 
-func validateJSXExpr(log logger.Log, text string, name string, kind js_parser.JSXExprKind) config.JSXExpr {
-	if expr, ok := js_parser.ParseJSXExpr(text, kind); ok {
-		return expr
+func validatePackages(value Packages) bool {
+	switch value {
+	case PackagesDefault:
+		return false
+	case PackagesExternal:
+		return true
+	default:
+		panic("Invalid packages")
 	}
-	log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid JSX %s: %q", name, text))
-	return config.JSXExpr{}
 }
 
-func validateDefines(
-	log logger.Log,
-	defines map[string]string,
-	pureFns []string,
-	platform Platform,
-	minify bool,
-) (*config.ProcessedDefines, []config.InjectedDefine) {
-	rawDefines := make(map[string]config.DefineData)
-	var valueToInject map[string]config.InjectedDefine
-	var definesToInject []string
-
-	for key, value := range defines {
-		// The key must be a dot-separated identifier list
-		for _, part := range strings.Split(key, ".") {
-			if !js_lexer.IsIdentifier(part) {
-				if part == key {
-					log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The define key %q must be a valid identifier", key))
-				} else {
-					log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The define key %q contains invalid identifier %q", key, part))
-				}
+func validateExternalRewrites(log logger.Log, rewrites map[string]string) []config.ExternalRewrite {
+	if len(rewrites) == 0 {
+		return nil
+	}
+	var result []config.ExternalRewrite
+	for _, path := range sortedExternalRewriteKeys(rewrites) {
+		replacement := rewrites[path]
+		if replacement == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Missing replacement for external path %q", path))
+			continue
+		}
+		rewrite := config.ExternalRewrite{Pattern: config.WildcardPattern{Prefix: path}, Replacement: replacement}
+		if index := strings.IndexByte(path, '*'); index != -1 {
+			if strings.ContainsRune(path[index+1:], '*') {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("External path %q cannot have more than one \"*\" wildcard", path))
 				continue
 			}
+			rewrite.Pattern = config.WildcardPattern{Prefix: path[:index], Suffix: path[index+1:]}
+			rewrite.IsWildcard = true
 		}
+		result = append(result, rewrite)
+	}
 
-		// Allow substituting for an identifier
-		if js_lexer.IsIdentifier(value) {
-			if _, ok := js_lexer.Keywords[value]; !ok {
-				name := value // The closure must close over a variable inside the loop
-				rawDefines[key] = config.DefineData{
+	// Exact matches take precedence over wildcards, and longer wildcard
+	// patterns take precedence over shorter ones
+	sort.SliceStable(result, func(i int, j int) bool {
+		a, b := result[i], result[j]
+		if a.IsWildcard != b.IsWildcard {
+			return !a.IsWildcard
+		}
+		return len(a.Pattern.Prefix)+len(a.Pattern.Suffix) > len(b.Pattern.Prefix)+len(b.Pattern.Suffix)
+	})
+	return result
+}
+
+func validateWorkspaces(log logger.Log, fs fs.FS, workspaces map[string]string) map[string]string {
+	if len(workspaces) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for name, path := range workspaces {
+		if !resolver.IsPackageName(name) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid workspace package name: %q", name))
+		} else if path == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Missing path for workspace package %q", name))
+		} else if absPath := validatePath(log, fs, path, "workspace path"); absPath != "" {
+			result[name] = absPath
+		}
+	}
+	return result
+}
+
+// Aliases can substitute a package for another package or for a file. Paths
+// to files are relative to the working directory, not to the importing file.
+func validateAlias(log logger.Log, fs fs.FS, alias map[string]string) map[string]string {
+	if len(alias) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for old, new := range alias {
+		if !resolver.IsPackageName(old) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid alias name: %q", old))
+		} else if new == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid alias substitution: %q", new))
+		} else if strings.HasPrefix(new, "./") || strings.HasPrefix(new, "../") || fs.IsAbs(new) {
+			if absPath := validatePath(log, fs, new, "alias substitution"); absPath != "" {
+				result[old] = absPath
+			}
+		} else if resolver.IsPackagePath(new) {
+			result[old] = new
+		} else {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid alias substitution: %q", new))
+		}
+	}
+	return result
+}
+
+func validateIsolatePackages(log logger.Log, names []string) []string {
+	for _, name := range names {
+		if !resolver.IsPackageName(name) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid package name to isolate: %q", name))
+		}
+	}
+	return names
+}
+
+// Each rule is either a package name or a path, which may contain a "*"
+// wildcard. Paths must start with "." or "/" to tell them apart from packages.
+// The chunk for each rule is named after the rule.
+func validateIsolateChunks(log logger.Log, fs fs.FS, rules []string) []config.IsolatedChunk {
+	var result []config.IsolatedChunk
+	for _, rule := range rules {
+		chunk := config.IsolatedChunk{Name: isolatedChunkName(rule)}
+		if resolver.IsPackageName(rule) {
+			chunk.Package = rule
+		} else if strings.HasPrefix(rule, ".") || fs.IsAbs(rule) {
+			if !validateChunkPathPattern(log, fs, rule, "Isolated chunk path", &chunk) {
+				continue
+			}
+		} else {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid package name or path to isolate in a chunk: %q", rule))
+			continue
+		}
+		if chunk.Name == "" {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot derive a chunk name from %q", rule))
+			continue
+		}
+		result = append(result, chunk)
+	}
+	return result
+}
+
+func validateChunkPathPattern(log logger.Log, fs fs.FS, rule string, pathKind string, chunk *config.IsolatedChunk) bool {
+	absPath := validatePath(log, fs, rule, strings.ToLower(pathKind))
+	if index := strings.IndexByte(absPath, '*'); index != -1 {
+		if strings.ContainsRune(absPath[index+1:], '*') {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("%s %q cannot have more than one \"*\" wildcard", pathKind, rule))
+			return false
+		}
+		chunk.Pattern = config.WildcardPattern{Prefix: absPath[:index], Suffix: absPath[index+1:]}
+		chunk.IsWildcard = true
+	} else {
+		chunk.Pattern = config.WildcardPattern{Prefix: absPath}
+	}
+	return true
+}
+
+// Manual chunks map a chunk name to paths relative to the working directory,
+// which may contain a "*" wildcard. Unlike isolated chunks, paths don't need
+// to start with "." and several paths can go in the same chunk.
+func validateManualChunks(log logger.Log, fs fs.FS, chunks map[string][]string) []config.IsolatedChunk {
+	names := make([]string, 0, len(chunks))
+	for name := range chunks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []config.IsolatedChunk
+	for _, name := range names {
+		if !isValidManualChunkName(name) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid chunk name: %q", name))
+			continue
+		}
+		for _, rule := range chunks[name] {
+			chunk := config.IsolatedChunk{Name: name}
+			if validateChunkPathPattern(log, fs, rule, "Manual chunk path", &chunk) {
+				result = append(result, chunk)
+			}
+		}
+	}
+	return result
+}
+
+func validateManualChunksCallback(log logger.Log, callback func(ManualChunkArgs) string) func(logger.Path) string {
+	if callback == nil {
+		return nil
+	}
+
+	// Only report each invalid name once
+	mutex := sync.Mutex{}
+	reported := make(map[string]bool)
+
+	return func(path logger.Path) string {
+		name := callback(ManualChunkArgs{Path: path.Text, Namespace: path.Namespace})
+		if name != "" && !isValidManualChunkName(name) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !reported[name] {
+				reported[name] = true
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid chunk name %q returned for %q", name, path.Text))
+			}
+			return ""
+		}
+		return name
+	}
+}
+
+// Chunk names are used as file names, so they can't contain path separators
+func isValidManualChunkName(name string) bool {
+	return name != "" && isolatedChunkName(name) == name
+}
+
+// For example, "@corp/analytics" becomes "corp-analytics"
+func isolatedChunkName(rule string) string {
+	name := strings.Map(func(c rune) rune {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '.' {
+			return c
+		}
+		return '-'
+	}, strings.TrimPrefix(rule, "@"))
+	return strings.Trim(name, "-.")
+}
+
+func validateLazyPackages(log logger.Log, names []string) []string {
+	for _, name := range names {
+		if !resolver.IsPackageName(name) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid package name to evaluate lazily: %q", name))
+		}
+	}
+	return names
+}
+
+func validateCSSLayers(log logger.Log, layers map[string]string) map[string]string {
+	if len(layers) == 0 {
+		return nil
+	}
+	for name, layer := range layers {
+		if !resolver.IsPackageName(name) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid package name for CSS layer: %q", name))
+			continue
+		}
+		for _, part := range strings.Split(layer, ".") {
+			if !css_lexer.WouldStartIdentifierWithoutEscapes(part) {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid CSS layer name for package %q: %q", name, layer))
+				break
+			}
+		}
+	}
+	return layers
+}
+
+func validateOutputBudgets(log logger.Log, budgets OutputBudgets) config.OutputBudgets {
+	for _, budget := range []struct {
+		name  string
+		value int
+	}{
+		{"bytes", budgets.Bytes},
+		{"functions", budgets.Functions},
+		{"statements", budgets.Statements},
+	} {
+		if budget.value < 0 {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid %s budget: %d", budget.name, budget.value))
+		}
+	}
+	return config.OutputBudgets{
+		Bytes:      budgets.Bytes,
+		Functions:  budgets.Functions,
+		Statements: budgets.Statements,
+	}
+}
+
+func isValidExtension(ext string) bool {
+	return len(ext) >= 2 && ext[0] == '.' && ext[len(ext)-1] != '.'
+}
+
+func validateResolveExtensions(log logger.Log, order []string) []string {
+	if order == nil {
+		return []string{".tsx", ".ts", ".jsx", ".js", ".css", ".json"}
+	}
+	for _, ext := range order {
+		if !isValidExtension(ext) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid file extension: %q", ext))
+		}
+	}
+	return order
+}
+
+func validateIndexExtensions(log logger.Log, order []string) []string {
+	for _, ext := range order {
+		if !isValidExtension(ext) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid file extension: %q", ext))
+		}
+	}
+	return order
+}
+
+func validateLoaders(log logger.Log, loaders map[string]Loader) map[string]config.Loader {
+	result := bundler.DefaultExtensionToLoaderMap()
+	if loaders != nil {
+		for ext, loader := range loaders {
+			if !isValidExtension(ext) {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid file extension: %q", ext))
+			}
+			result[ext] = validateLoader(loader)
+		}
+	}
+	return result
+}
+
+func validateJSXExpr(log logger.Log, text string, name string, kind js_parser.JSXExprKind) config.JSXExpr {
+	if expr, ok := js_parser.ParseJSXExpr(text, kind); ok {
+		return expr
+	}
+	log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid JSX %s: %q", name, text))
+	return config.JSXExpr{}
+}
+
+func validateDefines(
+	log logger.Log,
+	defines map[string]string,
+	featureFlags map[string]bool,
+	pureFns []string,
+	platform Platform,
+	minify bool,
+) (*config.ProcessedDefines, []config.InjectedDefine) {
+	rawDefines := make(map[string]config.DefineData)
+	var valueToInject map[string]config.InjectedDefine
+	var definesToInject []string
+
+	for key, value := range defines {
+		// The key must be a dot-separated identifier list
+		for _, part := range strings.Split(key, ".") {
+			if !js_lexer.IsIdentifier(part) {
+				if part == key {
+					log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The define key %q must be a valid identifier", key))
+				} else {
+					log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The define key %q contains invalid identifier %q", key, part))
+				}
+				continue
+			}
+		}
+
+		// Allow substituting for an identifier
+		if js_lexer.IsIdentifier(value) {
+			if _, ok := js_lexer.Keywords[value]; !ok {
+				name := value // The closure must close over a variable inside the loop
+				rawDefines[key] = config.DefineData{
 					DefineFunc: func(args config.DefineArgs) js_ast.E {
 						return &js_ast.EIdentifier{Ref: args.FindSymbol(args.Loc, name)}
 					},
@@ -513,6 +1321,25 @@ func validateDefines(
 		rawDefines[key] = config.DefineData{DefineFunc: fn}
 	}
 
+	// Feature flags are substituted like defines but their uses are tracked
+	for key, value := range featureFlags {
+		for _, part := range strings.Split(key, ".") {
+			if !js_lexer.IsIdentifier(part) {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The feature flag %q must be a valid identifier", key))
+				break
+			}
+		}
+		if _, ok := defines[key]; ok {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The feature flag %q cannot also be a define", key))
+			continue
+		}
+		value := value // The closure must close over a variable inside the loop
+		rawDefines[key] = config.DefineData{
+			DefineFunc:  func(config.DefineArgs) js_ast.E { return &js_ast.EBoolean{Value: value} },
+			FeatureFlag: key,
+		}
+	}
+
 	// Sort injected defines for determinism, since the imports will be injected
 	// into every file in the order that we return them from this function
 	var injectedDefines []config.InjectedDefine
@@ -575,6 +1402,44 @@ func validateDefines(
 	return &processed, injectedDefines
 }
 
+// Chunks in a shared chunk directory are named by their contents, so multiple
+// builds in the same process often generate the same chunk. Each distinct
+// chunk is only written by the first build that generates it. Other builds
+// wait for that write to finish so that the file exists when they're done.
+type sharedChunkWrite struct {
+	once sync.Once
+	hash uint64
+}
+
+var sharedChunkWrites = struct {
+	mutex  sync.Mutex
+	writes map[string]*sharedChunkWrite
+}{writes: make(map[string]*sharedChunkWrite)}
+
+// This returns true if "write" was called
+func writeSharedChunkOnce(absPath string, contents []byte, write func()) bool {
+	hash := xxhash.Sum64(contents)
+	sharedChunkWrites.mutex.Lock()
+	entry, ok := sharedChunkWrites.writes[absPath]
+	if !ok || entry.hash != hash {
+		entry = &sharedChunkWrite{hash: hash}
+		sharedChunkWrites.writes[absPath] = entry
+	}
+	sharedChunkWrites.mutex.Unlock()
+
+	didWrite := false
+	entry.once.Do(func() {
+		write()
+		didWrite = true
+	})
+	return didWrite
+}
+
+func isInsideDir(fs fs.FS, absDir string, absPath string) bool {
+	relPath, ok := fs.Rel(absDir, absPath)
+	return ok && relPath != ".." && !strings.HasPrefix(relPath, "../") && !strings.HasPrefix(relPath, "..\\")
+}
+
 func validatePath(log logger.Log, fs fs.FS, relPath string, pathKind string) string {
 	if relPath == "" {
 		return ""
@@ -586,6 +1451,55 @@ func validatePath(log logger.Log, fs fs.FS, relPath string, pathKind string) str
 	return absPath
 }
 
+// A missing name cache file is the same as an empty one, since the file is
+// created by the first build that uses it
+func loadNameCache(log logger.Log, fs fs.FS, absPath string) *config.NameCache {
+	if names, ok := loadNameMap(log, fs, absPath, "name cache"); ok {
+		return config.NewNameCache(names)
+	}
+	return nil
+}
+
+// The name cache and the mangle cache are both JSON objects with string values
+func loadNameMap(log logger.Log, fs fs.FS, absPath string, what string) (map[string]string, bool) {
+	names := make(map[string]string)
+	contents, err, originalError := fs.ReadFile(absPath)
+	if err == syscall.ENOENT {
+		return names, true
+	}
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot read %s %q: %s",
+			what, fs.Base(absPath), originalError.Error()))
+		return nil, false
+	}
+
+	source := logger.Source{
+		KeyPath:    logger.Path{Text: absPath, Namespace: "file"},
+		PrettyPath: fs.Base(absPath),
+		Contents:   contents,
+	}
+	tracker := logger.MakeLineColumnTracker(&source)
+	expr, ok := js_parser.ParseJSON(log, source, js_parser.JSONOptions{})
+	if !ok {
+		return nil, false
+	}
+	object, ok := expr.Data.(*js_ast.EObject)
+	if !ok {
+		log.Add(logger.Error, &tracker, logger.Range{Loc: expr.Loc}, fmt.Sprintf("The %s must be a JSON object", what))
+		return nil, false
+	}
+	for _, property := range object.Properties {
+		key := js_lexer.UTF16ToString(property.Key.Data.(*js_ast.EString).Value)
+		if value, ok := property.ValueOrNil.Data.(*js_ast.EString); ok {
+			names[key] = js_lexer.UTF16ToString(value.Value)
+		} else {
+			log.Add(logger.Error, &tracker, js_lexer.RangeOfIdentifier(source, property.ValueOrNil.Loc),
+				fmt.Sprintf("The name for %q in the %s must be a string", key, what))
+		}
+	}
+	return names, true
+}
+
 func validateOutputExtensions(log logger.Log, outExtensions map[string]string) (js string, css string) {
 	for key, value := range outExtensions {
 		if !isValidExtension(value) {
@@ -596,6 +1510,8 @@ func validateOutputExtensions(log logger.Log, outExtensions map[string]string) (
 			js = value
 		case ".css":
 			css = value
+		case "iife", "cjs", "esm", "umd":
+			// These are handled by "validateFormats"
 		default:
 			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Invalid output extension: %q (valid: .css, .js)", key))
 		}
@@ -603,6 +1519,39 @@ func validateOutputExtensions(log logger.Log, outExtensions map[string]string) (
 	return
 }
 
+var defaultFormatOutputExtensions = map[config.Format]string{
+	config.FormatIIFE:     ".iife.js",
+	config.FormatCommonJS: ".cjs",
+	config.FormatESModule: ".mjs",
+	config.FormatUMD:      ".umd.js",
+}
+
+// Each format needs its own JS output extension so that the output files for
+// different formats don't overwrite each other. The default extension can be
+// changed using an output extension keyed by the format's name.
+func validateFormats(log logger.Log, formats []Format, outExtensions map[string]string) []config.FormatOutput {
+	var result []config.FormatOutput
+	seen := make(map[config.Format]bool)
+	for _, value := range formats {
+		if value == FormatDefault {
+			log.Add(logger.Error, nil, logger.Range{}, "Each of the formats must be specified explicitly")
+			continue
+		}
+		format := validateFormat(value)
+		if seen[format] {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("The %q format is listed more than once", format.String()))
+			continue
+		}
+		seen[format] = true
+		extension, ok := outExtensions[format.String()]
+		if !ok {
+			extension = defaultFormatOutputExtensions[format]
+		}
+		result = append(result, config.FormatOutput{Format: format, OutputExtensionJS: extension})
+	}
+	return result
+}
+
 func validateBannerOrFooter(log logger.Log, name string, values map[string]string) (js string, css string) {
 	for key, value := range values {
 		switch key {
@@ -704,9 +1653,20 @@ type internalBuildResult struct {
 	result    BuildResult
 	options   config.Options
 	watchData fs.WatchData
+	resolver  resolver.Resolver
+
+	// This is either nil or whether each output file was left alone because
+	// the file on disk already had the same contents (see "SkipUnchanged")
+	unchanged []bool
 }
 
 func buildImpl(buildOpts BuildOptions) internalBuildResult {
+	return buildImplWithCaches(buildOpts, cache.MakeCacheSet())
+}
+
+// The caches can be shared between unrelated builds since each cache entry
+// also checks the contents and options that were used to create it
+func buildImplWithCaches(buildOpts BuildOptions, caches *cache.CacheSet) internalBuildResult {
 	start := time.Now()
 	logOptions := logger.OutputOptions{
 		IncludeSource: true,
@@ -734,18 +1694,61 @@ func buildImpl(buildOpts BuildOptions) internalBuildResult {
 		panic("Mutating \"AbsWorkingDir\" is not allowed")
 	}
 
-	internalResult := rebuildImpl(buildOpts, cache.MakeCacheSet(), plugins, onEndCallbacks, logOptions, log, false /* isRebuild */)
+	// Rebuilds only need to re-link the entry points that have changed
+	var linkCache *bundler.LinkCache
+	if buildOpts.Watch != nil || buildOpts.Incremental {
+		linkCache = bundler.MakeLinkCache()
+	}
+
+	// Builds that can be rebuilt start the type checker once and don't wait for it
+	var check *typeChecker
+	if buildOpts.TypeCheck != "" && (buildOpts.Watch != nil || buildOpts.Incremental) && !log.HasErrors() {
+		check = startTypeChecker(realFS, buildOpts.TypeCheck, printTypeCheckDiagnostics(logOptions))
+	}
+
+	internalResult := rebuildImpl(buildOpts, caches, linkCache, plugins, onEndCallbacks, logOptions, log, false /* isRebuild */, nil, nil)
+
+	if check != nil {
+		stop := internalResult.result.Stop
+		internalResult.result.Stop = func() {
+			if stop != nil {
+				stop()
+			}
+			check.stop()
+		}
+	}
 
 	// Print a summary of the generated files to stderr. Except don't do
 	// this if the terminal is already being used for something else.
 	if logOptions.LogLevel <= logger.LevelInfo && len(internalResult.result.OutputFiles) > 0 &&
-		buildOpts.Watch == nil && !buildOpts.Incremental && !internalResult.options.WriteToStdout {
-		printSummary(logOptions, internalResult.result.OutputFiles, start)
+		buildOpts.Watch == nil && !buildOpts.Incremental && !internalResult.options.WriteToStdout &&
+		internalResult.options.StdoutStream == config.StdoutStreamNone {
+		printSummary(logOptions, internalResult.result.OutputFiles, internalResult.unchanged, start)
 	}
 
 	return internalResult
 }
 
+func validateBuildOptionsImpl(buildOpts BuildOptions) ValidateBuildOptionsResult {
+	log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+
+	// Validate that the current working directory is an absolute path
+	realFS, err := fs.RealFS(fs.RealFSOptions{
+		AbsWorkingDir: buildOpts.AbsWorkingDir,
+	})
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, err.Error())
+	} else {
+		validateBuildOptions(buildOpts, log, realFS, nil)
+	}
+
+	msgs := log.Done()
+	return ValidateBuildOptionsResult{
+		Errors:   convertMessagesToPublic(logger.Error, msgs),
+		Warnings: convertMessagesToPublic(logger.Warning, msgs),
+	}
+}
+
 func prettyPrintByteCount(n int) string {
 	var size string
 	if n < 1024 {
@@ -760,7 +1763,7 @@ func prettyPrintByteCount(n int) string {
 	return size
 }
 
-func printSummary(logOptions logger.OutputOptions, outputFiles []OutputFile, start time.Time) {
+func printSummary(logOptions logger.OutputOptions, outputFiles []OutputFile, unchanged []bool, start time.Time) {
 	var table logger.SummaryTable = make([]logger.SummaryTableEntry, len(outputFiles))
 
 	if len(outputFiles) > 0 {
@@ -773,12 +1776,19 @@ func printSummary(logOptions logger.OutputOptions, outputFiles []OutputFile, sta
 					}
 					base := realFS.Base(path)
 					n := len(file.Contents)
+					if file.Contents == nil {
+						// Files that were copied without being loaded into memory
+						if info, err := os.Stat(file.Path); err == nil {
+							n = int(info.Size())
+						}
+					}
 					table[i] = logger.SummaryTableEntry{
 						Dir:         path[:len(path)-len(base)],
 						Base:        base,
 						Size:        prettyPrintByteCount(n),
 						Bytes:       n,
 						IsSourceMap: strings.HasSuffix(base, ".map"),
+						IsUnchanged: unchanged != nil && unchanged[i],
 					}
 				}
 			}
@@ -800,173 +1810,57 @@ func printSummary(logOptions logger.OutputOptions, outputFiles []OutputFile, sta
 func rebuildImpl(
 	buildOpts BuildOptions,
 	caches *cache.CacheSet,
+	linkCache *bundler.LinkCache,
 	plugins []config.Plugin,
 	onEndCallbacks []func(*BuildResult),
 	logOptions logger.OutputOptions,
 	log logger.Log,
 	isRebuild bool,
+	watchChanges []WatchChange,
+	inputFS fs.FS,
 ) internalBuildResult {
-	// Convert and validate the buildOpts
-	realFS, err := fs.RealFS(fs.RealFSOptions{
-		AbsWorkingDir: buildOpts.AbsWorkingDir,
-		WantWatchData: buildOpts.Watch != nil,
-	})
-	if err != nil {
-		// This should already have been checked above
-		panic(err.Error())
+	// Convert and validate the buildOpts. Replaying a recorded build provides
+	// its own file system instead of using the real one.
+	realFS := inputFS
+	if realFS == nil {
+		var err error
+		realFS, err = fs.RealFS(fs.RealFSOptions{
+			AbsWorkingDir: buildOpts.AbsWorkingDir,
+			WantWatchData: buildOpts.Watch != nil || buildOpts.AccessList,
+		})
+		if err != nil {
+			// This should already have been checked above
+			panic(err.Error())
+		}
+		if buildOpts.Record != "" {
+			realFS = fs.RecordingFS(realFS)
+		}
 	}
-	targetFromAPI, jsFeatures, cssFeatures, targetEnv := validateFeatures(log, buildOpts.Target, buildOpts.Engines)
-	outJS, outCSS := validateOutputExtensions(log, buildOpts.OutExtensions)
-	bannerJS, bannerCSS := validateBannerOrFooter(log, "banner", buildOpts.Banner)
-	footerJS, footerCSS := validateBannerOrFooter(log, "footer", buildOpts.Footer)
-	minify := buildOpts.MinifyWhitespace && buildOpts.MinifyIdentifiers && buildOpts.MinifySyntax
-	defines, injectedDefines := validateDefines(log, buildOpts.Define, buildOpts.Pure, buildOpts.Platform, minify)
-	options := config.Options{
-		TargetFromAPI:          targetFromAPI,
-		UnsupportedJSFeatures:  jsFeatures,
-		UnsupportedCSSFeatures: cssFeatures,
-		OriginalTargetEnv:      targetEnv,
-		JSX: config.JSXOptions{
-			Preserve: buildOpts.JSXMode == JSXModePreserve,
-			Factory:  validateJSXExpr(log, buildOpts.JSXFactory, "factory", js_parser.JSXFactory),
-			Fragment: validateJSXExpr(log, buildOpts.JSXFragment, "fragment", js_parser.JSXFragment),
-		},
-		Defines:               defines,
-		InjectedDefines:       injectedDefines,
-		Platform:              validatePlatform(buildOpts.Platform),
-		SourceMap:             validateSourceMap(buildOpts.Sourcemap),
-		LegalComments:         validateLegalComments(buildOpts.LegalComments, buildOpts.Bundle),
-		SourceRoot:            buildOpts.SourceRoot,
-		ExcludeSourcesContent: buildOpts.SourcesContent == SourcesContentExclude,
-		MangleSyntax:          buildOpts.MinifySyntax,
-		RemoveWhitespace:      buildOpts.MinifyWhitespace,
-		MinifyIdentifiers:     buildOpts.MinifyIdentifiers,
-		AllowOverwrite:        buildOpts.AllowOverwrite,
-		ASCIIOnly:             validateASCIIOnly(buildOpts.Charset),
-		IgnoreDCEAnnotations:  buildOpts.IgnoreAnnotations,
-		TreeShaking:           validateTreeShaking(buildOpts.TreeShaking, buildOpts.Bundle, buildOpts.Format),
-		GlobalName:            validateGlobalName(log, buildOpts.GlobalName),
-		CodeSplitting:         buildOpts.Splitting,
-		OutputFormat:          validateFormat(buildOpts.Format),
-		AbsOutputFile:         validatePath(log, realFS, buildOpts.Outfile, "outfile path"),
-		AbsOutputDir:          validatePath(log, realFS, buildOpts.Outdir, "outdir path"),
-		AbsOutputBase:         validatePath(log, realFS, buildOpts.Outbase, "outbase path"),
-		NeedsMetafile:         buildOpts.Metafile,
-		EntryPathTemplate:     validatePathTemplate(buildOpts.EntryNames),
-		ChunkPathTemplate:     validatePathTemplate(buildOpts.ChunkNames),
-		AssetPathTemplate:     validatePathTemplate(buildOpts.AssetNames),
-		OutputExtensionJS:     outJS,
-		OutputExtensionCSS:    outCSS,
-		ExtensionToLoader:     validateLoaders(log, buildOpts.Loader),
-		ExtensionOrder:        validateResolveExtensions(log, buildOpts.ResolveExtensions),
-		ExternalModules:       validateExternals(log, realFS, buildOpts.External),
-		TsConfigOverride:      validatePath(log, realFS, buildOpts.Tsconfig, "tsconfig path"),
-		MainFields:            buildOpts.MainFields,
-		Conditions:            append([]string{}, buildOpts.Conditions...),
-		PublicPath:            buildOpts.PublicPath,
-		KeepNames:             buildOpts.KeepNames,
-		InjectAbsPaths:        make([]string, len(buildOpts.Inject)),
-		AbsNodePaths:          make([]string, len(buildOpts.NodePaths)),
-		JSBanner:              bannerJS,
-		JSFooter:              footerJS,
-		CSSBanner:             bannerCSS,
-		CSSFooter:             footerCSS,
-		PreserveSymlinks:      buildOpts.PreserveSymlinks,
-		WatchMode:             buildOpts.Watch != nil,
-		Plugins:               plugins,
-	}
-	if options.MainFields != nil {
-		options.MainFields = append([]string{}, options.MainFields...)
-	}
-	for i, path := range buildOpts.Inject {
-		options.InjectAbsPaths[i] = validatePath(log, realFS, path, "inject path")
-	}
-	for i, path := range buildOpts.NodePaths {
-		options.AbsNodePaths[i] = validatePath(log, realFS, path, "node path")
-	}
-	entryPoints := make([]bundler.EntryPoint, 0, len(buildOpts.EntryPoints)+len(buildOpts.EntryPointsAdvanced))
-	for _, ep := range buildOpts.EntryPoints {
-		entryPoints = append(entryPoints, bundler.EntryPoint{InputPath: ep})
-	}
-	for _, ep := range buildOpts.EntryPointsAdvanced {
-		entryPoints = append(entryPoints, bundler.EntryPoint{InputPath: ep.InputPath, OutputPath: ep.OutputPath})
-	}
-	entryPointCount := len(entryPoints)
-	if buildOpts.Stdin != nil {
-		entryPointCount++
-		options.Stdin = &config.StdinInfo{
-			Loader:        validateLoader(buildOpts.Stdin.Loader),
-			Contents:      buildOpts.Stdin.Contents,
-			SourceFile:    buildOpts.Stdin.Sourcefile,
-			AbsResolveDir: validatePath(log, realFS, buildOpts.Stdin.ResolveDir, "resolve directory path"),
-		}
-	}
-
-	if options.AbsOutputDir == "" && entryPointCount > 1 {
-		log.Add(logger.Error, nil, logger.Range{},
-			"Must use \"outdir\" when there are multiple input files")
-	} else if options.AbsOutputDir == "" && options.CodeSplitting {
-		log.Add(logger.Error, nil, logger.Range{},
-			"Must use \"outdir\" when code splitting is enabled")
-	} else if options.AbsOutputFile != "" && options.AbsOutputDir != "" {
-		log.Add(logger.Error, nil, logger.Range{}, "Cannot use both \"outfile\" and \"outdir\"")
-	} else if options.AbsOutputFile != "" {
-		// If the output file is specified, use it to derive the output directory
-		options.AbsOutputDir = realFS.Dir(options.AbsOutputFile)
-	} else if options.AbsOutputDir == "" {
-		options.WriteToStdout = true
-
-		// Forbid certain features when writing to stdout
-		if options.SourceMap != config.SourceMapNone && options.SourceMap != config.SourceMapInline {
-			log.Add(logger.Error, nil, logger.Range{}, "Cannot use an external source map without an output path")
-		}
-		if options.LegalComments.HasExternalFile() {
-			log.Add(logger.Error, nil, logger.Range{}, "Cannot use linked or external legal comments without an output path")
-		}
-		for _, loader := range options.ExtensionToLoader {
-			if loader == config.LoaderFile {
-				log.Add(logger.Error, nil, logger.Range{}, "Cannot use the \"file\" loader without an output path")
-				break
-			}
-		}
-
-		// Use the current directory as the output directory instead of an empty
-		// string because external modules with relative paths need a base directory.
-		options.AbsOutputDir = realFS.Cwd()
-	}
-
-	if !buildOpts.Bundle {
-		// Disallow bundle-only options when not bundling
-		if len(options.ExternalModules.NodeModules) > 0 || len(options.ExternalModules.AbsPaths) > 0 {
-			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"external\" without \"bundle\"")
-		}
-	} else if options.OutputFormat == config.FormatPreserve {
-		// If the format isn't specified, set the default format using the platform
-		switch options.Platform {
-		case config.PlatformBrowser:
-			options.OutputFormat = config.FormatIIFE
-		case config.PlatformNode:
-			options.OutputFormat = config.FormatCommonJS
-		case config.PlatformNeutral:
-			options.OutputFormat = config.FormatESModule
-		}
+	// "OnEnd" callbacks run before the output files are written, when the log
+	// hasn't been finished yet. Keep track of the warnings for them.
+	var warningsSoFar func() []logger.Msg
+	if len(onEndCallbacks) > 0 {
+		log, warningsSoFar = recordWarnings(log)
 	}
 
-	// Set the output mode using other settings
-	if buildOpts.Bundle {
-		options.Mode = config.ModeBundle
-	} else if options.OutputFormat != config.FormatPreserve {
-		options.Mode = config.ModeConvertFormat
-	}
+	options, entryPoints := validateBuildOptions(buildOpts, log, realFS, plugins)
 
-	// Code splitting is experimental and currently only enabled for ES6 modules
-	if options.CodeSplitting && options.OutputFormat != config.FormatESModule {
-		log.Add(logger.Error, nil, logger.Range{}, "Splitting currently only works with the \"esm\" format")
+	// Type checking runs in parallel with the build. Replaying a recorded build
+	// doesn't type check since the input files may not exist on disk. Builds
+	// that can be rebuilt share a type checker that's started elsewhere.
+	var check *typeChecker
+	if buildOpts.TypeCheck != "" && inputFS == nil && !isRebuild && buildOpts.Watch == nil && !buildOpts.Incremental && !log.HasErrors() {
+		check = startTypeChecker(realFS, buildOpts.TypeCheck, nil)
 	}
 
 	var outputFiles []OutputFile
 	var metafileJSON string
+	var nameCacheJSON string
+	var mangleCacheJSON string
 	var watchData fs.WatchData
+	var accessList []FileAccess
+	var unchanged []bool
+	onEndWasRun := false
 
 	// Stop now if there were errors
 	resolver := resolver.NewResolver(realFS, log, caches, options)
@@ -979,20 +1873,43 @@ func rebuildImpl(
 		// Scan over the bundle
 		bundle := bundler.ScanBundle(log, realFS, resolver, caches, entryPoints, options, timer)
 		watchData = realFS.WatchData()
+		if buildOpts.AccessList {
+			accessList = fileAccessList(watchData)
+		}
 
 		// Stop now if there were errors
 		if !log.HasErrors() {
 			// Compile the bundle
-			results, metafile := bundle.Compile(log, options, timer)
+			results, metafile, caches := bundle.Compile(log, options, timer, linkCache)
+
+			// Copied files may no longer exist on disk when replaying a recorded
+			// build, so load them from the recording instead
+			if inputFS != nil {
+				loadCopiedFilesFromFS(log, realFS, results)
+			}
 
 			// Stop now if there were errors
 			if !log.HasErrors() {
 				metafileJSON = metafile
+				nameCacheJSON = caches.NameCacheJSON
+				mangleCacheJSON = caches.MangleCacheJSON
+				if buildOpts.AccessList && metafile != "" {
+					metafileJSON = addAccessListToMetafile(realFS, metafile, accessList, options.ASCIIOnly)
+				}
 
 				// Flush any deferred warnings now
 				log.AlmostDone()
 
-				if buildOpts.Write {
+				// Plugins can change the output files before they are written
+				if len(onEndCallbacks) > 0 {
+					results = runOnEndCallbacksBeforeWrite(onEndCallbacks, log, realFS, warningsSoFar(), metafileJSON, results)
+					onEndWasRun = true
+				}
+
+				if buildOpts.Write && !log.HasErrors() {
+					if buildOpts.Progress != nil {
+						buildOpts.Progress(ProgressEvent{Phase: ProgressWrite})
+					}
 					timer.Begin("Write output files")
 					if options.WriteToStdout {
 						// Special-case writing to stdout
@@ -1003,55 +1920,132 @@ func rebuildImpl(
 							log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
 								"Failed to write to stdout: %s", err.Error()))
 						}
+					} else if options.StdoutStream != config.StdoutStreamNone {
+						writeOutputFilesToStdout(log, realFS, &options, results)
 					} else {
-						// Write out files in parallel
+						// Write out files in parallel. Files that were reused from the
+						// previous build are left untouched since they haven't changed,
+						// unless they were deleted since then. Files that are identical
+						// to what's already on disk are also left untouched if requested,
+						// which preserves their mtimes.
 						waitGroup := sync.WaitGroup{}
-						waitGroup.Add(len(results))
-						for _, result := range results {
-							go func(result graph.OutputFile) {
+						if buildOpts.SkipUnchanged {
+							unchanged = make([]bool, len(results))
+						}
+						for i, result := range results {
+							if result.IsReused {
+								if _, err := os.Stat(result.AbsPath); err == nil {
+									continue
+								}
+							}
+							waitGroup.Add(1)
+							go func(i int, result graph.OutputFile) {
 								fs.BeforeFileOpen()
 								defer fs.AfterFileClose()
-								if err := fs.MkdirAll(realFS, realFS.Dir(result.AbsPath), 0755); err != nil {
-									log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
-										"Failed to create output directory: %s", err.Error()))
-								} else {
-									var mode os.FileMode = 0644
-									if result.IsExecutable {
-										mode = 0755
-									}
-									if err := ioutil.WriteFile(result.AbsPath, result.Contents, mode); err != nil {
+								write := func() {
+									if err := fs.MkdirAll(realFS, realFS.Dir(result.AbsPath), 0755); err != nil {
 										log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
-											"Failed to write to output file: %s", err.Error()))
+											"Failed to create output directory: %s", err.Error()))
+									} else if result.CopyFromAbsPath != "" {
+										if unchanged != nil && isCopySameAsFileOnDisk(result.AbsPath, result.CopyFromAbsPath) {
+											unchanged[i] = true
+										} else if err := copyFileOnDisk(result.CopyFromAbsPath, result.AbsPath); err != nil {
+											log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+												"Failed to write to output file: %s", err.Error()))
+										}
+									} else if unchanged != nil && isSameAsFileOnDisk(result.AbsPath, result.Contents) {
+										unchanged[i] = true
+									} else {
+										var mode os.FileMode = 0644
+										if result.IsExecutable {
+											mode = 0755
+										}
+										if err := ioutil.WriteFile(result.AbsPath, result.Contents, mode); err != nil {
+											log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+												"Failed to write to output file: %s", err.Error()))
+										}
 									}
 								}
+								if options.AbsSharedChunkDir != "" && isInsideDir(realFS, options.AbsSharedChunkDir, result.AbsPath) {
+									// Identical chunks from other builds in this process are only written once
+									if !writeSharedChunkOnce(result.AbsPath, result.Contents, write) && unchanged != nil {
+										unchanged[i] = true
+									}
+								} else {
+									write()
+								}
 								waitGroup.Done()
-							}(result)
+							}(i, result)
 						}
 						waitGroup.Wait()
 					}
+
+					// The name and mangle caches aren't output files, so they're written
+					// to their own paths even when the output files are written to stdout
+					if nameCacheJSON != "" {
+						writeGeneratedCacheFile(log, realFS, options.AbsNameCacheFile, nameCacheJSON)
+					}
+					if mangleCacheJSON != "" {
+						writeGeneratedCacheFile(log, realFS, options.AbsMangleCacheFile, mangleCacheJSON)
+					}
 					timer.End("Write output files")
 				}
 
 				// Return the results
 				outputFiles = make([]OutputFile, len(results))
 				for i, result := range results {
-					if options.WriteToStdout {
+					if options.WriteToStdout || options.StdoutStream == config.StdoutStreamSingle {
 						result.AbsPath = "<stdout>"
 					}
+
+					// Copied files are only left out if they were written and the caller
+					// asked for that
+					if result.CopyFromAbsPath != "" && !(buildOpts.Write && buildOpts.StreamCopiedFiles) {
+						contents, err := ioutil.ReadFile(result.CopyFromAbsPath)
+						if err != nil {
+							log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+								"Failed to read output file contents: %s", err.Error()))
+						}
+						result.Contents = contents
+					}
 					outputFiles[i] = OutputFile{
-						Path:     result.AbsPath,
-						Contents: result.Contents,
+						Path:       result.AbsPath,
+						Contents:   result.Contents,
+						BannerLine: result.BannerLine,
+						FooterLine: result.FooterLine,
 					}
 				}
+
+				// Save everything this build read so that it can be replayed later
+				if buildOpts.Record != "" && !log.HasErrors() {
+					writeBuildRecord(log, realFS, buildOpts)
+				}
 			}
 		}
 
 		timer.Log(log)
 	}
 
+	// This happens after the output files have been written so that they are
+	// never held up by the type checker
+	if check != nil {
+		for _, msg := range check.wait() {
+			log.AddMsg(msg)
+		}
+	}
+
 	// End the log now, which may print a message
 	msgs := log.Done()
 
+	// Later builds can change some of the options of the top-level build. This
+	// mutex is held for the duration of each later build and while the options
+	// are changed so that a build never sees caches that don't match its options.
+	var liveMutex sync.Mutex
+	liveOptions := func() (BuildOptions, logger.OutputOptions) {
+		liveMutex.Lock()
+		return buildOpts, logOptions
+	}
+
 	// Start watching, but only for the top-level build
 	var watch *watcher
 	var stop func()
@@ -1060,8 +2054,10 @@ func rebuildImpl(
 		watch = &watcher{
 			data:     watchData,
 			resolver: resolver,
-			rebuild: func() fs.WatchData {
-				value := rebuildImpl(buildOpts, caches, plugins, onEndCallbacks, logOptions, logger.NewStderrLog(logOptions), true /* isRebuild */)
+			rebuild: func(changes []WatchChange) fs.WatchData {
+				buildOpts, logOptions := liveOptions()
+				value := rebuildImpl(buildOpts, caches, linkCache, plugins, onEndCallbacks, logOptions, logger.NewStderrLog(logOptions), true /* isRebuild */, changes, nil)
+				liveMutex.Unlock()
 				if onRebuild != nil {
 					go onRebuild(value.result)
 				}
@@ -1075,35 +2071,1278 @@ func rebuildImpl(
 		}
 	}
 
-	var rebuild func() BuildResult
-	if buildOpts.Incremental {
-		rebuild = func() BuildResult {
-			value := rebuildImpl(buildOpts, caches, plugins, onEndCallbacks, logOptions, logger.NewStderrLog(logOptions), true /* isRebuild */)
-			if watch != nil {
-				watch.setWatchData(value.watchData)
-			}
-			return value.result
-		}
+	var rebuild func() BuildResult
+	if buildOpts.Incremental {
+		rebuild = func() BuildResult {
+			buildOpts, logOptions := liveOptions()
+			value := rebuildImpl(buildOpts, caches, linkCache, plugins, onEndCallbacks, logOptions, logger.NewStderrLog(logOptions), true /* isRebuild */, nil, nil)
+			liveMutex.Unlock()
+			if watch != nil {
+				watch.setWatchData(value.watchData)
+			}
+			return value.result
+		}
+	}
+
+	var update func(UpdateOptions) error
+	if (buildOpts.Incremental || buildOpts.Watch != nil) && !isRebuild {
+		update = func(options UpdateOptions) error {
+			liveMutex.Lock()
+			applyUpdateOptions(&buildOpts, &logOptions, caches, linkCache, options)
+			logLevel := buildOpts.LogLevel
+			liveMutex.Unlock()
+			if watch != nil {
+				watch.setLogLevel(logLevel)
+				watch.requestRebuild()
+			}
+			return nil
+		}
+	}
+
+	result := BuildResult{
+		Errors:       convertMessagesToPublic(logger.Error, msgs),
+		Warnings:     convertMessagesToPublic(logger.Warning, msgs),
+		OutputFiles:  outputFiles,
+		Metafile:     metafileJSON,
+		NameCache:    nameCacheJSON,
+		MangleCache:  mangleCacheJSON,
+		Rebuild:      rebuild,
+		Update:       update,
+		Stop:         stop,
+		WatchChanges: watchChanges,
+		AccessList:   accessList,
+	}
+
+	if !onEndWasRun {
+		for _, onEnd := range onEndCallbacks {
+			onEnd(&result)
+		}
+	}
+
+	return internalBuildResult{
+		result:    result,
+		options:   options,
+		watchData: watchData,
+		resolver:  resolver,
+		unchanged: unchanged,
+	}
+}
+
+// Returns a log that also remembers the warnings that have been logged so far
+func recordWarnings(log logger.Log) (logger.Log, func() []logger.Msg) {
+	var mutex sync.Mutex
+	var warnings []logger.Msg
+	addMsg := log.AddMsg
+
+	log.AddMsg = func(msg logger.Msg) {
+		if msg.Kind == logger.Warning {
+			mutex.Lock()
+			defer mutex.Unlock()
+			warnings = append(warnings, msg)
+		}
+		addMsg(msg)
+	}
+
+	return log, func() []logger.Msg {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]logger.Msg{}, warnings...)
+	}
+}
+
+// This runs the "OnEnd" callbacks of a successful build before the output
+// files are written. The callbacks can change, remove, or add output files.
+// Output files that the callbacks didn't change keep their original state so
+// that files reused from the previous build and copied files are still
+// handled efficiently.
+func runOnEndCallbacksBeforeWrite(
+	onEndCallbacks []func(*BuildResult),
+	log logger.Log,
+	realFS fs.FS,
+	warnings []logger.Msg,
+	metafileJSON string,
+	results []graph.OutputFile,
+) []graph.OutputFile {
+	type originalFile struct {
+		file     graph.OutputFile
+		contents []byte
+	}
+	originals := make(map[string]originalFile, len(results))
+	outputFiles := make([]OutputFile, len(results))
+	for i, result := range results {
+		contents := result.Contents
+
+		// Copied files are loaded into memory so the callbacks can see them
+		if result.CopyFromAbsPath != "" {
+			var err error
+			if contents, err = ioutil.ReadFile(result.CopyFromAbsPath); err != nil {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+					"Failed to read output file contents: %s", err.Error()))
+			}
+		}
+
+		originals[result.AbsPath] = originalFile{file: result, contents: contents}
+		outputFiles[i] = OutputFile{
+			Path:       result.AbsPath,
+			Contents:   contents,
+			BannerLine: result.BannerLine,
+			FooterLine: result.FooterLine,
+		}
+	}
+
+	result := BuildResult{
+		Warnings:    convertMessagesToPublic(logger.Warning, warnings),
+		OutputFiles: outputFiles,
+		Metafile:    metafileJSON,
+	}
+	warningCount := len(result.Warnings)
+	for _, onEnd := range onEndCallbacks {
+		onEnd(&result)
+	}
+
+	// Messages added by the callbacks are logged like any other messages. Any
+	// errors mean that the output files aren't written.
+	for _, msg := range convertMessagesToInternal(nil, logger.Error, result.Errors) {
+		log.AddMsg(msg)
+	}
+	if len(result.Warnings) > warningCount {
+		for _, msg := range convertMessagesToInternal(nil, logger.Warning, result.Warnings[warningCount:]) {
+			log.AddMsg(msg)
+		}
+	}
+
+	results = make([]graph.OutputFile, 0, len(result.OutputFiles))
+	for _, outputFile := range result.OutputFiles {
+		original, ok := originals[outputFile.Path]
+		if ok && isSameByteSlice(original.contents, outputFile.Contents) {
+			results = append(results, original.file)
+			continue
+		}
+		if !realFS.IsAbs(outputFile.Path) {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"The path of an output file from an \"onEnd\" callback must be absolute: %q", outputFile.Path))
+			continue
+		}
+		results = append(results, graph.OutputFile{
+			AbsPath:      outputFile.Path,
+			Contents:     outputFile.Contents,
+			IsExecutable: ok && original.file.IsExecutable,
+		})
+	}
+	return results
+}
+
+// Returns true if both slices refer to the same memory, which means that the
+// contents of an output file weren't replaced
+func isSameByteSlice(a []byte, b []byte) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// This converts the public build options into internal options and reports
+// any problems with them to the log. It's shared by the build API and by the
+// "ValidateBuildOptions" API, so it must not have any side effects.
+func validateBuildOptions(
+	buildOpts BuildOptions,
+	log logger.Log,
+	realFS fs.FS,
+	plugins []config.Plugin,
+) (config.Options, []bundler.EntryPoint) {
+	engines := buildOpts.Engines
+	if buildOpts.InferTarget {
+		engines = inferTargetFromPackageJSON(log, realFS, buildOpts)
+	}
+	supported := buildOpts.Supported
+	if buildOpts.SupportedFile != "" {
+		supported = loadSupportedFile(log, realFS, buildOpts.SupportedFile, supported)
+	}
+	targetFromAPI, jsFeatures, cssFeatures, targetEnv := validateFeatures(log, buildOpts.Target, engines, supported)
+	outJS, outCSS := validateOutputExtensions(log, buildOpts.OutExtensions)
+	bannerJS, bannerCSS := validateBannerOrFooter(log, "banner", buildOpts.Banner)
+	footerJS, footerCSS := validateBannerOrFooter(log, "footer", buildOpts.Footer)
+	minify := buildOpts.MinifyWhitespace && buildOpts.MinifyIdentifiers && buildOpts.MinifySyntax
+	defines, injectedDefines := validateDefines(log, buildOpts.Define, buildOpts.FeatureFlags, buildOpts.Pure, buildOpts.Platform, minify)
+	options := config.Options{
+		TargetFromAPI:          targetFromAPI,
+		UnsupportedJSFeatures:  jsFeatures,
+		UnsupportedCSSFeatures: cssFeatures,
+		OriginalTargetEnv:      targetEnv,
+		TargetNodeVersion:      validateNodeTarget(engines),
+		JSX: config.JSXOptions{
+			Preserve:         buildOpts.JSXMode == JSXModePreserve,
+			AutomaticRuntime: buildOpts.JSXMode == JSXModeAutomatic,
+			ImportSource:     buildOpts.JSXImportSource,
+			Factory:          validateJSXExpr(log, buildOpts.JSXFactory, "factory", js_parser.JSXFactory),
+			Fragment:         validateJSXExpr(log, buildOpts.JSXFragment, "fragment", js_parser.JSXFragment),
+		},
+		TS: config.TSOptions{
+			Version: validateTSVersion(log, buildOpts.TSVersion),
+			Enums:   validateTSEnums(buildOpts.TSEnums),
+		},
+		Defines:                 defines,
+		InjectedDefines:         injectedDefines,
+		Platform:                validatePlatform(buildOpts.Platform),
+		SourceMap:               validateSourceMap(buildOpts.Sourcemap),
+		LegalComments:           validateLegalComments(buildOpts.LegalComments, buildOpts.Bundle),
+		Annotations:             validateAnnotations(buildOpts.Annotations),
+		FormatAnnotations:       validateFormatAnnotations(buildOpts.FormatAnnotations),
+		MagicComments:           validateAnnotations(buildOpts.MagicComments),
+		LineEnding:              validateLineEnding(buildOpts.LineEnding),
+		IndentUnit:              validateIndent(log, buildOpts.Indent),
+		SourceRoot:              buildOpts.SourceRoot,
+		ExcludeSourcesContent:   buildOpts.SourcesContent == SourcesContentExclude,
+		SourceMapBanners:        buildOpts.SourcemapBanners,
+		MangleSyntax:            buildOpts.MinifySyntax,
+		RemoveWhitespace:        buildOpts.MinifyWhitespace,
+		MinifyIdentifiers:       buildOpts.MinifyIdentifiers,
+		MinifySeed:              buildOpts.MinifySeed,
+		AbsNameCacheFile:        validatePath(log, realFS, buildOpts.NameCache, "name cache path"),
+		AbsMangleCacheFile:      validatePath(log, realFS, buildOpts.MangleCache, "mangle cache path"),
+		OnConflict:              validateOnConflict(buildOpts.OnConflict, buildOpts.AllowOverwrite),
+		ASCIIOnly:               validateASCIIOnly(buildOpts.Charset),
+		CharsetEscapes:          validateCharsetEscapes(log, buildOpts.CharsetEscape),
+		IdentifierCharset:       validateIdentifierCharset(buildOpts.IdentifierCharset),
+		IdentifierNormalization: validateNormalization(buildOpts.IdentifierNormalization),
+		IgnoreDCEAnnotations:    buildOpts.IgnoreAnnotations,
+		InferPureFunctions:      buildOpts.InferPure,
+		DropConsole:             (buildOpts.Drop & DropConsole) != 0,
+		DropDebugger:            (buildOpts.Drop & DropDebugger) != 0,
+		RecoverSyntaxErrors:     buildOpts.RecoverSyntaxErrors,
+		SyntaxErrorLimit:        buildOpts.LogLimit,
+		TreeShaking:             validateTreeShaking(buildOpts.TreeShaking, buildOpts.Bundle, buildOpts.Format),
+		TreeShakingMembers:      buildOpts.TreeShakeMembers,
+		CustomPragmas:           validatePragmas(log, buildOpts.Pragmas),
+		FeatureFlags:            buildOpts.FeatureFlags,
+		PreserveComments:        validateRegExp(log, buildOpts.PreserveComments, "preserved comments"),
+		MangleProps:             validateRegExp(log, buildOpts.MangleProps, "mangled properties"),
+		ReserveProps:            validateRegExp(log, buildOpts.ReserveProps, "reserved properties"),
+		StripIf:                 validateStripIf(log, buildOpts.StripIf),
+		StripBetween:            validateStripBetween(log, buildOpts.StripBetween),
+		GlobalName:              validateGlobalName(log, buildOpts.GlobalName),
+		CodeSplitting:           buildOpts.Splitting || buildOpts.SplittingPreset != SplittingPresetNone || len(buildOpts.IsolateChunks) > 0 || len(buildOpts.Chunks) > 0 || buildOpts.ManualChunks != nil,
+		SplittingPreset:         validateSplittingPreset(buildOpts.SplittingPreset),
+		UnusedExports:           validateUnusedExports(buildOpts.UnusedExports),
+		DynamicImportLoader:     validateDynamicImportLoader(buildOpts.DynamicImportLoader),
+		OutputFormat:            validateFormat(buildOpts.Format),
+		ExternalHelpers:         buildOpts.ExternalHelpers,
+		AbsOutputFile:           validatePath(log, realFS, buildOpts.Outfile, "outfile path"),
+		AbsOutputDir:            validatePath(log, realFS, buildOpts.Outdir, "outdir path"),
+		AbsOutputBase:           validatePath(log, realFS, buildOpts.Outbase, "outbase path"),
+		NeedsMetafile:           buildOpts.Metafile,
+		ModuleMap:               buildOpts.ModuleMap,
+		CJSWrapper:              buildOpts.CJSWrapper,
+		EntryPathTemplate:       validatePathTemplate(buildOpts.EntryNames),
+		ChunkPathTemplate:       validatePathTemplate(buildOpts.ChunkNames),
+		AssetPathTemplate:       validatePathTemplate(buildOpts.AssetNames),
+		OutputExtensionJS:       outJS,
+		OutputExtensionCSS:      outCSS,
+		ExtensionToLoader:       validateLoaders(log, buildOpts.Loader),
+		ExtensionOrder:          validateResolveExtensions(log, buildOpts.ResolveExtensions),
+		ExternalModules:         validateExternals(log, realFS, append(append(buildOpts.External, sortedExternalRewriteKeys(buildOpts.ExternalRewrite)...), sortedExternalRewriteKeys(buildOpts.Globals)...)),
+		ExternalRewrites:        validateExternalRewrites(log, buildOpts.ExternalRewrite),
+		ExternalGlobals:         validateExternalGlobals(log, buildOpts.Globals),
+		ExternalPackages:        validatePackages(buildOpts.Packages),
+		Workspaces:              validateWorkspaces(log, realFS, buildOpts.Workspaces),
+		DetectWorkspaces:        buildOpts.DetectWorkspaces,
+		PackageAliases:          validateAlias(log, realFS, buildOpts.Alias),
+		StrictCase:              buildOpts.StrictCase,
+		BundleDynamicPaths:      buildOpts.BundleDynamicPaths,
+		NodePolyfills:           buildOpts.NodePolyfills,
+		RewriteImports:          buildOpts.RewriteImports || buildOpts.PreserveModules,
+		PreserveModules:         buildOpts.PreserveModules,
+		DirectoryImports:        validateDirectoryImports(buildOpts.DirectoryImports),
+		IndexExtensions:         validateIndexExtensions(log, buildOpts.IndexExtensions),
+		IsolatedPackages:        validateIsolatePackages(log, buildOpts.IsolatePackages),
+		IsolatedChunks:          append(validateManualChunks(log, realFS, buildOpts.Chunks), validateIsolateChunks(log, realFS, buildOpts.IsolateChunks)...),
+		ManualChunks:            validateManualChunksCallback(log, buildOpts.ManualChunks),
+		ChunkMinSize:            buildOpts.ChunkMinSize,
+		ChunkMaxSize:            buildOpts.ChunkMaxSize,
+		LazyPackages:            validateLazyPackages(log, buildOpts.LazyPackages),
+		CSSLayers:               validateCSSLayers(log, buildOpts.CSSLayers),
+		OutputBudgets:           validateOutputBudgets(log, buildOpts.Budgets),
+		TsConfigOverride:        validatePath(log, realFS, buildOpts.Tsconfig, "tsconfig path"),
+		MainFields:              buildOpts.MainFields,
+		Conditions:              append([]string{}, buildOpts.Conditions...),
+		PublicPath:              buildOpts.PublicPath,
+		KeepNames:               buildOpts.KeepNames,
+		InjectAbsPaths:          make([]string, len(buildOpts.Inject)),
+		InjectCSSLink:           buildOpts.InjectCSSLink,
+		AbsNodePaths:            make([]string, len(buildOpts.NodePaths)),
+		AbsDenoDir:              validatePath(log, realFS, buildOpts.DenoDir, "deno dir"),
+		JSBanner:                bannerJS,
+		JSFooter:                footerJS,
+		CSSBanner:               bannerCSS,
+		CSSFooter:               footerCSS,
+		PreserveSymlinks:        buildOpts.PreserveSymlinks,
+		WatchMode:               buildOpts.Watch != nil,
+		Plugins:                 plugins,
+	}
+	if len(buildOpts.ImageEncoders) > 0 {
+		options.ImageEncoders = make(map[string]func(image.Image, int) ([]byte, error), len(buildOpts.ImageEncoders))
+		for format, encoder := range buildOpts.ImageEncoders {
+			options.ImageEncoders[format] = encoder
+		}
+	}
+	if onProgress := buildOpts.Progress; onProgress != nil {
+		options.OnScanProgress = func(filesParsed int, filesDiscovered int) {
+			onProgress(ProgressEvent{Phase: ProgressScan, FilesParsed: filesParsed, FilesDiscovered: filesDiscovered})
+		}
+		options.OnLinkProgress = func(entryPointsLinked int, entryPointsTotal int) {
+			onProgress(ProgressEvent{Phase: ProgressLink, EntryPointsLinked: entryPointsLinked, EntryPointsTotal: entryPointsTotal})
+		}
+	}
+	if options.MainFields != nil {
+		options.MainFields = append([]string{}, options.MainFields...)
+	}
+	for i, path := range buildOpts.Inject {
+		options.InjectAbsPaths[i] = validatePath(log, realFS, path, "inject path")
+	}
+	for i, path := range buildOpts.NodePaths {
+		options.AbsNodePaths[i] = validatePath(log, realFS, path, "node path")
+	}
+	entryPoints := make([]bundler.EntryPoint, 0, len(buildOpts.EntryPoints)+len(buildOpts.EntryPointsAdvanced))
+	for _, ep := range buildOpts.EntryPoints {
+		entryPoints = append(entryPoints, bundler.EntryPoint{InputPath: ep})
+	}
+	for _, ep := range buildOpts.EntryPointsAdvanced {
+		entryPoints = append(entryPoints, bundler.EntryPoint{InputPath: ep.InputPath, OutputPath: ep.OutputPath})
+	}
+	entryPointCount := len(entryPoints)
+	if buildOpts.Stdin != nil {
+		entryPointCount++
+		options.Stdin = &config.StdinInfo{
+			Loader:        validateLoader(buildOpts.Stdin.Loader),
+			Contents:      buildOpts.Stdin.Contents,
+			SourceFile:    buildOpts.Stdin.Sourcefile,
+			AbsResolveDir: validatePath(log, realFS, buildOpts.Stdin.ResolveDir, "resolve directory path"),
+		}
+	}
+
+	// An output path of "-" streams the output files to stdout. The current
+	// directory is used as the output directory to give them names.
+	if buildOpts.Outfile == "-" || buildOpts.Outdir == "-" {
+		if buildOpts.Outfile != "" && buildOpts.Outdir != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use both \"outfile\" and \"outdir\"")
+		}
+		if buildOpts.Outfile == "-" {
+			options.StdoutStream = config.StdoutStreamSingle
+		} else {
+			options.StdoutStream = config.StdoutStreamTar
+		}
+		options.AbsOutputFile = ""
+		options.AbsOutputDir = realFS.Cwd()
+	}
+
+	if options.AbsOutputDir == "" && entryPointCount > 1 {
+		log.Add(logger.Error, nil, logger.Range{},
+			"Must use \"outdir\" when there are multiple input files")
+	} else if options.AbsOutputDir == "" && options.CodeSplitting {
+		log.Add(logger.Error, nil, logger.Range{},
+			"Must use \"outdir\" when code splitting is enabled")
+	} else if options.AbsOutputDir == "" && buildOpts.DualPackage {
+		log.Add(logger.Error, nil, logger.Range{},
+			"Must use \"outdir\" when generating a dual package")
+	} else if options.AbsOutputDir == "" && len(buildOpts.Formats) > 1 {
+		log.Add(logger.Error, nil, logger.Range{},
+			"Must use \"outdir\" when generating multiple formats")
+	} else if options.AbsOutputDir == "" && buildOpts.PreserveModules {
+		log.Add(logger.Error, nil, logger.Range{},
+			"Must use \"outdir\" when preserving modules")
+	} else if options.AbsOutputFile != "" && options.AbsOutputDir != "" {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use both \"outfile\" and \"outdir\"")
+	} else if options.AbsOutputFile != "" {
+		// If the output file is specified, use it to derive the output directory
+		options.AbsOutputDir = realFS.Dir(options.AbsOutputFile)
+	} else if options.AbsOutputDir == "" {
+		options.WriteToStdout = true
+
+		// Forbid certain features when writing to stdout
+		if options.SourceMap != config.SourceMapNone && options.SourceMap != config.SourceMapInline {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use an external source map without an output path")
+		}
+		if options.LegalComments.HasExternalFile() {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use linked or external legal comments without an output path")
+		}
+		for _, loader := range options.ExtensionToLoader {
+			if loader == config.LoaderFile {
+				log.Add(logger.Error, nil, logger.Range{}, "Cannot use the \"file\" loader without an output path")
+				break
+			}
+			if loader == config.LoaderCopy {
+				log.Add(logger.Error, nil, logger.Range{}, "Cannot use the \"copy\" loader without an output path")
+				break
+			}
+			if loader == config.LoaderImage {
+				log.Add(logger.Error, nil, logger.Range{}, "Cannot use the \"image\" loader without an output path")
+				break
+			}
+		}
+		if buildOpts.PrecacheManifest != "" || buildOpts.ServiceWorker != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a precache manifest or service worker without an output path")
+		}
+		if buildOpts.CSPReport != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a CSP report without an output path")
+		}
+		if buildOpts.FeatureReport != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a feature flag report without an output path")
+		}
+		if buildOpts.CSSOrderReport != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a CSS order report without an output path")
+		}
+		if options.ModuleMap {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a module map without an output path")
+		}
+		if options.CJSWrapper {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a CommonJS wrapper without an output path")
+		}
+		if buildOpts.ContentManifest != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a content manifest without an output directory")
+		}
+
+		// Use the current directory as the output directory instead of an empty
+		// string because external modules with relative paths need a base directory.
+		options.AbsOutputDir = realFS.Cwd()
+	}
+
+	// Content-addressed names replace the output file name, so there must not
+	// be a specific output file
+	if buildOpts.ContentManifest != "" && options.AbsOutputFile != "" {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a content manifest without an output directory")
+	}
+
+	// Each half of a dual package has its own output format and JS extension.
+	// Files that list all output files are only generated by a single build.
+	if buildOpts.DualPackage {
+		options.DualPackage = true
+		if buildOpts.Format != FormatDefault {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"format\" with \"dual-package\"")
+		}
+		if _, ok := buildOpts.OutExtensions[".js"]; ok {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use a \".js\" output extension with \"dual-package\"")
+		}
+		if buildOpts.PrecacheManifest != "" || buildOpts.ServiceWorker != "" || buildOpts.ContentManifest != "" || buildOpts.CSPReport != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a manifest, service worker, or CSP report with \"dual-package\"")
+		}
+		if len(buildOpts.Formats) > 0 {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"formats\" with \"dual-package\"")
+		}
+	}
+
+	// Each format has its own JS extension and is linked separately. A single
+	// format is the same as using "format" instead.
+	if len(buildOpts.Formats) > 1 {
+		if buildOpts.Format != FormatDefault {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use both \"format\" and \"formats\"")
+		}
+		if _, ok := buildOpts.OutExtensions[".js"]; ok {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use a \".js\" output extension with multiple formats")
+		}
+		if buildOpts.PrecacheManifest != "" || buildOpts.ServiceWorker != "" || buildOpts.ContentManifest != "" || buildOpts.CSPReport != "" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot generate a manifest, service worker, or CSP report with multiple formats")
+		}
+		options.OutputFormats = validateFormats(log, buildOpts.Formats, buildOpts.OutExtensions)
+		if len(options.OutputFormats) > 0 {
+			options.OutputFormat = options.OutputFormats[0].Format
+		}
+	} else {
+		if len(buildOpts.Formats) == 1 {
+			if buildOpts.Format != FormatDefault {
+				log.Add(logger.Error, nil, logger.Range{}, "Cannot use both \"format\" and \"formats\"")
+			}
+			options.OutputFormat = validateFormat(buildOpts.Formats[0])
+		}
+		for _, name := range []string{"iife", "cjs", "esm", "umd"} {
+			if _, ok := buildOpts.OutExtensions[name]; ok {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot use an output extension for the %q format without multiple formats", name))
+			}
+		}
+	}
+
+	if options.AbsNameCacheFile != "" {
+		options.NameCache = loadNameCache(log, realFS, options.AbsNameCacheFile)
+	}
+	if options.AbsMangleCacheFile != "" {
+		if options.MangleProps == nil {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use a mangle cache without \"mangle-props\"")
+		} else if names, ok := loadNameMap(log, realFS, options.AbsMangleCacheFile, "mangle cache"); ok {
+			options.MangleCache = names
+		}
+	}
+
+	// The precache manifest, content manifest, service worker, CSP report, and
+	// feature flag report paths are relative to the output directory since they
+	// describe the output files
+	if !options.WriteToStdout {
+		absPathInOutputDir := func(path string) string {
+			if path == "" || realFS.IsAbs(path) {
+				return path
+			}
+			return realFS.Join(options.AbsOutputDir, path)
+		}
+		options.AbsPrecacheManifestFile = absPathInOutputDir(buildOpts.PrecacheManifest)
+		options.AbsContentManifestFile = absPathInOutputDir(buildOpts.ContentManifest)
+		options.AbsServiceWorkerFile = absPathInOutputDir(buildOpts.ServiceWorker)
+		options.AbsCSPReportFile = absPathInOutputDir(buildOpts.CSPReport)
+		options.AbsFeatureReportFile = absPathInOutputDir(buildOpts.FeatureReport)
+		options.AbsCSSOrderReportFile = absPathInOutputDir(buildOpts.CSSOrderReport)
+	}
+
+	if !buildOpts.Bundle {
+		// Disallow bundle-only options when not bundling
+		if len(options.ExternalModules.NodeModules) > 0 || len(options.ExternalModules.AbsPaths) > 0 {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"external\" without \"bundle\"")
+		}
+		if options.ExternalPackages {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"packages\" without \"bundle\"")
+		}
+		if options.TreeShakingMembers {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"tree-shake-members\" without \"bundle\"")
+		}
+		if len(options.IsolatedPackages) > 0 {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"isolate-package\" without \"bundle\"")
+		}
+		if len(options.LazyPackages) > 0 {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"lazy-package\" without \"bundle\"")
+		}
+		if options.ModuleMap {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"module-map\" without \"bundle\"")
+		}
+		if options.NodePolyfills {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"node-polyfills\" without \"bundle\"")
+		}
+		if options.DynamicImportLoader != config.DynamicImportLoaderRequire {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"dynamic-import-loader\" without \"bundle\"")
+		}
+	} else if options.OutputFormat == config.FormatPreserve {
+		// If the format isn't specified, set the default format using the platform
+		switch options.Platform {
+		case config.PlatformBrowser:
+			options.OutputFormat = config.FormatIIFE
+		case config.PlatformNode:
+			options.OutputFormat = config.FormatCommonJS
+		case config.PlatformNeutral:
+			options.OutputFormat = config.FormatESModule
+		}
+	}
+	wrappedFormat := options.OutputFormat
+	for _, output := range options.OutputFormats {
+		if output.Format.IsWrappedInFunction() {
+			wrappedFormat = output.Format
+		}
+	}
+	if options.ExternalHelpers != "" && wrappedFormat.IsWrappedInFunction() {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot use \"external-helpers\" with the %q format", wrappedFormat.String()))
+	}
+	if buildOpts.Bundle && options.PreserveModules {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"preserve-modules\" with \"bundle\"")
+	} else if buildOpts.Bundle && options.RewriteImports {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"rewrite-imports\" with \"bundle\"")
+	}
+	if buildOpts.Record != "" && len(plugins) > 0 {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"record\" with plugins")
+	}
+
+	// Set the output mode using other settings
+	if buildOpts.Bundle {
+		options.Mode = config.ModeBundle
+	} else if options.OutputFormat != config.FormatPreserve {
+		options.Mode = config.ModeConvertFormat
+	}
+
+	if buildOpts.SharedChunkDir != "" {
+		if !options.CodeSplitting {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"shared-chunk-dir\" without \"splitting\"")
+		} else {
+			options.AbsSharedChunkDir = validatePath(log, realFS, buildOpts.SharedChunkDir, "shared chunk directory")
+		}
+	}
+
+	if options.NodePolyfills && options.Platform != config.PlatformBrowser {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"node-polyfills\" unless the platform is \"browser\"")
+	}
+
+	if options.ChunkMinSize < 0 || options.ChunkMaxSize < 0 {
+		log.Add(logger.Error, nil, logger.Range{}, "Chunk size limits must not be negative")
+	} else if (options.ChunkMinSize > 0 || options.ChunkMaxSize > 0) && !options.CodeSplitting {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"chunk-min-size\" or \"chunk-max-size\" without \"splitting\"")
+	} else if options.ChunkMaxSize > 0 && options.ChunkMinSize > options.ChunkMaxSize {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"The minimum chunk size (%d) must not be larger than the maximum chunk size (%d)", options.ChunkMinSize, options.ChunkMaxSize))
+	}
+
+	splittingFormat := options.OutputFormat
+	for _, output := range options.OutputFormats {
+		if output.Format.SupportsCodeSplitting(options.Platform) {
+			splittingFormat = output.Format
+		}
+	}
+	if options.CodeSplitting && !splittingFormat.SupportsCodeSplitting(options.Platform) && !options.DualPackage {
+		if splittingFormat == config.FormatIIFE {
+			log.Add(logger.Error, nil, logger.Range{}, "Splitting with the \"iife\" format only works with the \"browser\" platform")
+		} else {
+			log.Add(logger.Error, nil, logger.Range{}, "Splitting currently only works with the \"esm\", \"cjs\", and \"iife\" formats")
+		}
+	}
+
+	// The wrapper loads an ES module, so there must be one. It's written to a
+	// ".cjs" file next to the entry point, so the entry point can't use that.
+	if options.CJSWrapper {
+		hasESM := options.OutputFormat == config.FormatESModule
+		for _, output := range options.OutputFormats {
+			if output.Format == config.FormatESModule {
+				hasESM = true
+			}
+		}
+		if options.DualPackage {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"cjs-wrapper\" with \"dual-package\"")
+		} else if !hasESM {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"cjs-wrapper\" without the \"esm\" format")
+		} else if options.OutputFormats == nil && options.OutputExtensionJS == ".cjs" {
+			log.Add(logger.Error, nil, logger.Range{}, "Cannot use \"cjs-wrapper\" with a \".cjs\" output extension")
+		}
+	}
+
+	return options, entryPoints
+}
+
+func copyFileOnDisk(srcPath string, dstPath string) error {
+	// Never truncate the source when a file is copied onto itself
+	if srcInfo, err := os.Stat(srcPath); err != nil {
+		return err
+	} else if dstInfo, err := os.Stat(dstPath); err == nil && os.SameFile(srcInfo, dstInfo) {
+		return nil
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func isCopySameAsFileOnDisk(absPath string, srcPath string) bool {
+	// Check the sizes first to avoid reading large files that have changed
+	srcInfo, err := os.Stat(srcPath)
+	if err != nil {
+		return false
+	}
+	if info, err := os.Stat(absPath); err != nil || !info.Mode().IsRegular() || info.Size() != srcInfo.Size() {
+		return false
+	} else if os.SameFile(info, srcInfo) {
+		return true
+	}
+
+	a, err := os.Open(absPath)
+	if err != nil {
+		return false
+	}
+	defer a.Close()
+	b, err := os.Open(srcPath)
+	if err != nil {
+		return false
+	}
+	defer b.Close()
+
+	// Compare the contents in pieces so large files aren't loaded into memory
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		nA, errA := io.ReadFull(a, bufA)
+		nB, errB := io.ReadFull(b, bufB)
+		if nA != nB || !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == errA
+		}
+		if errA != nil || errB != nil {
+			return false
+		}
+	}
+}
+
+func isSameAsFileOnDisk(absPath string, contents []byte) bool {
+	// Check the size first to avoid reading large files that have changed
+	if info, err := os.Stat(absPath); err != nil || !info.Mode().IsRegular() || info.Size() != int64(len(contents)) {
+		return false
+	}
+	existing, err := ioutil.ReadFile(absPath)
+	return err == nil && bytes.Equal(existing, contents)
+}
+
+// Caches that are generated along with the output files aren't output files
+// themselves, so they're written separately
+func writeGeneratedCacheFile(log logger.Log, realFS fs.FS, absPath string, contents string) {
+	fs.BeforeFileOpen()
+	defer fs.AfterFileClose()
+	if err := fs.MkdirAll(realFS, realFS.Dir(absPath), 0755); err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Failed to create output directory: %s", err.Error()))
+	} else if err := ioutil.WriteFile(absPath, []byte(contents), 0644); err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Failed to write to output file: %s", err.Error()))
+	}
+}
+
+// With "--outfile=-" the only output file is written to stdout as-is. With
+// "--outdir=-" all output files are written to stdout as a tar archive using
+// their paths relative to the current directory. The modification times are
+// left empty so that the same build always generates the same archive.
+func writeOutputFilesToStdout(log logger.Log, realFS fs.FS, options *config.Options, results []graph.OutputFile) {
+	buffer := bytes.Buffer{}
+	if options.StdoutStream == config.StdoutStreamSingle {
+		if len(results) != 1 {
+			// This should already have been reported by the bundler
+			return
+		}
+		buffer.Write(results[0].Contents)
+	} else {
+		archive := tar.NewWriter(&buffer)
+		for _, result := range results {
+			contents := result.Contents
+			if result.CopyFromAbsPath != "" {
+				var err error
+				if contents, err = ioutil.ReadFile(result.CopyFromAbsPath); err != nil {
+					log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+						"Failed to read output file contents: %s", err.Error()))
+					return
+				}
+			}
+			path, ok := realFS.Rel(options.AbsOutputDir, result.AbsPath)
+			if !ok {
+				path = result.AbsPath
+			}
+			var mode int64 = 0644
+			if result.IsExecutable {
+				mode = 0755
+			}
+			header := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     filepath.ToSlash(path),
+				Mode:     mode,
+				Size:     int64(len(contents)),
+			}
+			err := archive.WriteHeader(header)
+			if err == nil {
+				_, err = archive.Write(contents)
+			}
+			if err != nil {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+					"Failed to write to stdout: %s", err.Error()))
+				return
+			}
+		}
+		if err := archive.Close(); err != nil {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+				"Failed to write to stdout: %s", err.Error()))
+			return
+		}
+	}
+	if _, err := os.Stdout.Write(buffer.Bytes()); err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Failed to write to stdout: %s", err.Error()))
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Replay API
+
+// This is bumped whenever the format of record files changes in a way that
+// older versions can't read
+const buildRecordVersion = 1
+
+type buildRecord struct {
+	Version   int             `json:"version"`
+	Options   json.RawMessage `json:"options"`
+	Recording fs.Recording    `json:"recording"`
+}
+
+// These build options can't be serialized. Replaying a build doesn't need
+// them since plugins aren't allowed when recording and replays don't watch.
+var unrecordedBuildOptions = map[string]bool{
+	"Plugins":       true,
+	"Progress":      true,
+	"ImageEncoders": true,
+	"Watch":         true,
+}
+
+// The build options are stored as a JSON object keyed by field name. Fields
+// that have their zero value are omitted to keep the record small.
+func encodeBuildOptions(buildOpts BuildOptions) ([]byte, error) {
+	fields := make(map[string]interface{})
+	value := reflect.ValueOf(buildOpts)
+	for i, n := 0, value.NumField(); i < n; i++ {
+		name := value.Type().Field(i).Name
+		if field := value.Field(i); !unrecordedBuildOptions[name] && !field.IsZero() {
+			fields[name] = field.Interface()
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func decodeBuildOptions(data []byte) (BuildOptions, error) {
+	var buildOpts BuildOptions
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return BuildOptions{}, err
+	}
+	value := reflect.ValueOf(&buildOpts).Elem()
+	for name, raw := range fields {
+		if field := value.FieldByName(name); field.IsValid() && !unrecordedBuildOptions[name] {
+			if err := json.Unmarshal(raw, field.Addr().Interface()); err != nil {
+				return BuildOptions{}, fmt.Errorf("Invalid build option %q: %s", name, err.Error())
+			}
+		}
+	}
+	return buildOpts, nil
+}
+
+func writeBuildRecord(log logger.Log, realFS fs.FS, buildOpts BuildOptions) {
+	recording, ok := fs.RecordingOf(realFS)
+	if !ok {
+		return
+	}
+	absPath := validatePath(log, realFS, buildOpts.Record, "record path")
+
+	// The replayed build should run in the same directory as this build
+	buildOpts.AbsWorkingDir = recording.Cwd
+	buildOpts.Record = ""
+	buildOpts.Incremental = false
+
+	options, err := encodeBuildOptions(buildOpts)
+	var contents []byte
+	if err == nil {
+		contents, err = json.Marshal(buildRecord{
+			Version:   buildRecordVersion,
+			Options:   options,
+			Recording: recording,
+		})
+	}
+
+	// Input files compress well, so the record is stored compressed
+	if err == nil {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, err = writer.Write(contents); err == nil {
+			err = writer.Close()
+		}
+		if err == nil {
+			err = ioutil.WriteFile(absPath, buffer.Bytes(), 0644)
+		}
+	}
+
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+			"Failed to write to record file: %s", err.Error()))
+	}
+}
+
+func readBuildRecord(path string) (buildRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return buildRecord{}, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return buildRecord{}, err
+	}
+	var record buildRecord
+	if err := json.NewDecoder(reader).Decode(&record); err != nil {
+		return buildRecord{}, err
+	}
+	if record.Version != buildRecordVersion {
+		return buildRecord{}, fmt.Errorf("Unsupported record version %d (expected %d)", record.Version, buildRecordVersion)
+	}
+	return record, nil
+}
+
+// Files from the "copy" loader are normally copied from their location on
+// disk, which doesn't work when replaying since they may no longer be there
+func loadCopiedFilesFromFS(log logger.Log, inputFS fs.FS, results []graph.OutputFile) {
+	for i, result := range results {
+		if result.CopyFromAbsPath != "" {
+			contents, err, _ := inputFS.ReadFile(result.CopyFromAbsPath)
+			if err != nil {
+				log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf(
+					"Cannot read file %q: %s", result.CopyFromAbsPath, err.Error()))
+			}
+			results[i].Contents = []byte(contents)
+			results[i].CopyFromAbsPath = ""
+		}
+	}
+}
+
+func replayImpl(replayOpts ReplayOptions) BuildResult {
+	start := time.Now()
+	logOptions := logger.OutputOptions{
+		IncludeSource: true,
+		Color:         validateColor(replayOpts.Color),
+		LogLevel:      validateLogLevel(replayOpts.LogLevel),
+	}
+	log := logger.NewStderrLog(logOptions)
+	fail := func(text string) BuildResult {
+		log.Add(logger.Error, nil, logger.Range{}, text)
+		return BuildResult{Errors: convertMessagesToPublic(logger.Error, log.Done())}
+	}
+
+	if replayOpts.Record == "" {
+		return fail("Missing record file")
+	}
+	record, err := readBuildRecord(replayOpts.Record)
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to read record file %q: %s", replayOpts.Record, err.Error()))
+	}
+	buildOpts, err := decodeBuildOptions(record.Options)
+	if err != nil {
+		return fail(fmt.Sprintf("Failed to read record file %q: %s", replayOpts.Record, err.Error()))
+	}
+	recording := record.Recording
+
+	// Substituted files replace the contents of recorded input files. Only
+	// files that the recorded build read can be substituted since the build
+	// never looks at any other files.
+	for recordedPath, path := range replayOpts.Substitute {
+		if !filepath.IsAbs(recordedPath) {
+			recordedPath = filepath.Join(recording.Cwd, recordedPath)
+		}
+		if _, ok := recording.Files[recordedPath]; !ok {
+			return fail(fmt.Sprintf("Cannot substitute %q because the recorded build didn't read it", recordedPath))
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fail(fmt.Sprintf("Failed to read substitute file %q: %s", path, err.Error()))
+		}
+		recording.Files[recordedPath] = string(contents)
+	}
+
+	if replayOpts.Outdir != "" {
+		outdir, err := filepath.Abs(replayOpts.Outdir)
+		if err != nil {
+			return fail(fmt.Sprintf("Invalid outdir path: %s", replayOpts.Outdir))
+		}
+		if buildOpts.Outfile != "" {
+			buildOpts.Outfile = filepath.Join(outdir, filepath.Base(buildOpts.Outfile))
+		} else {
+			buildOpts.Outdir = outdir
+		}
+	}
+
+	buildOpts.LogLevel = replayOpts.LogLevel
+	buildOpts.Color = replayOpts.Color
+	buildOpts.Write = replayOpts.Write
+
+	replayFS, err := fs.ReplayFS(recording)
+	if err != nil {
+		return fail(err.Error())
+	}
+	internalResult := rebuildImpl(buildOpts, cache.MakeCacheSet(), nil, nil, nil, logOptions, log, false /* isRebuild */, nil, replayFS)
+
+	if logOptions.LogLevel <= logger.LevelInfo && len(internalResult.result.OutputFiles) > 0 &&
+		!internalResult.options.WriteToStdout && internalResult.options.StdoutStream == config.StdoutStreamNone {
+		printSummary(logOptions, internalResult.result.OutputFiles, internalResult.unchanged, start)
+	}
+
+	return internalResult.result
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Context API
+
+type buildContext struct {
+	// Only one build runs at a time. This is held for the duration of a build.
+	buildMutex sync.Mutex
+
+	// This guards the fields below
+	mutex      sync.Mutex
+	buildOpts  BuildOptions
+	watchMode  *WatchMode
+	watch      *watcher
+	stopServe  func(graceful bool)
+	liveReload func(internalBuildResult)
+	isServing  bool
+	isDisposed bool
+
+	// While serving, each build uses a copy of the build options that has been
+	// adjusted for the server. The context's own build options are left alone.
+	serveBuildOpts func(BuildOptions) BuildOptions
+
+	caches         *cache.CacheSet
+	linkCache      *bundler.LinkCache
+	plugins        []config.Plugin
+	onEndCallbacks []func(*BuildResult)
+	logOptions     logger.OutputOptions
+
+	// The type check command is started by the first build and is stopped when
+	// the context is disposed. This is guarded by "buildMutex".
+	realFS    fs.FS
+	typeCheck *typeChecker
+}
+
+func contextImpl(buildOpts BuildOptions) (BuildContext, *ContextError) {
+	logOptions := logger.OutputOptions{
+		IncludeSource: true,
+		MessageLimit:  buildOpts.LogLimit,
+		Color:         validateColor(buildOpts.Color),
+		LogLevel:      validateLogLevel(buildOpts.LogLevel),
+	}
+	log := logger.NewStderrLog(logOptions)
+
+	// Validate that the current working directory is an absolute path
+	realFS, err := fs.RealFS(fs.RealFSOptions{
+		AbsWorkingDir: buildOpts.AbsWorkingDir,
+	})
+	if err != nil {
+		log.Add(logger.Error, nil, logger.Range{}, err.Error())
+		return nil, &ContextError{Errors: convertMessagesToPublic(logger.Error, log.Done())}
+	}
+
+	// Watching is started with a method on the context instead
+	if buildOpts.Watch != nil {
+		log.Add(logger.Error, nil, logger.Range{}, "Use the \"Watch\" method of the build context instead of the \"Watch\" option")
+	}
+	if buildOpts.Incremental {
+		log.Add(logger.Error, nil, logger.Range{}, "Use the \"Rebuild\" method of the build context instead of the \"Incremental\" option")
+	}
+
+	// Plugins are only set up once for all builds of the context
+	oldAbsWorkingDir := buildOpts.AbsWorkingDir
+	plugins, onEndCallbacks := loadPlugins(&buildOpts, realFS, log)
+	if buildOpts.AbsWorkingDir != oldAbsWorkingDir {
+		panic("Mutating \"AbsWorkingDir\" is not allowed")
+	}
+
+	if log.HasErrors() {
+		return nil, &ContextError{Errors: convertMessagesToPublic(logger.Error, log.Done())}
+	}
+	log.Done()
+
+	return &buildContext{
+		buildOpts:      buildOpts,
+		caches:         cache.MakeCacheSet(),
+		linkCache:      bundler.MakeLinkCache(),
+		plugins:        plugins,
+		onEndCallbacks: onEndCallbacks,
+		logOptions:     logOptions,
+		realFS:         realFS,
+	}, nil
+}
+
+func (ctx *buildContext) rebuild(changes []WatchChange) internalBuildResult {
+	ctx.buildMutex.Lock()
+	defer ctx.buildMutex.Unlock()
+
+	ctx.mutex.Lock()
+	buildOpts := ctx.buildOpts
+	if ctx.serveBuildOpts != nil {
+		buildOpts = ctx.serveBuildOpts(buildOpts)
+	}
+	isDisposed := ctx.isDisposed
+	watchMode := ctx.watchMode
+	ctx.mutex.Unlock()
+
+	if isDisposed {
+		return internalBuildResult{result: BuildResult{Errors: []Message{{
+			Text: "Cannot rebuild a build context after it has been disposed",
+		}}}}
+	}
+
+	if buildOpts.TypeCheck != "" && ctx.typeCheck == nil {
+		ctx.typeCheck = startTypeChecker(ctx.realFS, buildOpts.TypeCheck, printTypeCheckDiagnostics(ctx.logOptions))
+	}
+
+	// Watch data is only collected while watching since it takes extra memory.
+	// This is passed as a rebuild so that it doesn't start its own watcher.
+	buildOpts.Watch = watchMode
+	return rebuildImpl(buildOpts, ctx.caches, ctx.linkCache, ctx.plugins, ctx.onEndCallbacks,
+		ctx.logOptions, logger.NewStderrLog(ctx.logOptions), true /* isRebuild */, changes, nil)
+}
+
+func (ctx *buildContext) Rebuild() BuildResult {
+	value := ctx.rebuild(nil)
+
+	// Make sure the watcher knows about any files that this build depends on.
+	// The server keeps serving the latest result while watching, so it needs
+	// to know about this one too.
+	ctx.mutex.Lock()
+	watch := ctx.watch
+	liveReload := ctx.liveReload
+	ctx.mutex.Unlock()
+	if watch != nil && value.watchData.Paths != nil {
+		watch.setWatchData(value.watchData)
+	}
+	if watch != nil && liveReload != nil {
+		liveReload(value)
+	}
+
+	return value.result
+}
+
+func (ctx *buildContext) Watch(mode WatchMode) error {
+	ctx.mutex.Lock()
+	if ctx.isDisposed {
+		ctx.mutex.Unlock()
+		return fmt.Errorf("Cannot watch a build context after it has been disposed")
+	}
+	if ctx.watchMode != nil {
+		ctx.mutex.Unlock()
+		return fmt.Errorf("The build context is already watching for changes")
+	}
+	ctx.watchMode = &mode
+	logLevel, color := ctx.buildOpts.LogLevel, ctx.buildOpts.Color
+	ctx.mutex.Unlock()
+
+	// Do an initial build to find out which files to watch
+	initial := ctx.rebuild(nil)
+	watch := &watcher{
+		data:     initial.watchData,
+		resolver: initial.resolver,
+		rebuild: func(changes []WatchChange) fs.WatchData {
+			value := ctx.rebuild(changes)
+			if mode.OnRebuild != nil {
+				go mode.OnRebuild(value.result)
+			}
+
+			// Give the result to the server, which tells any pages that are
+			// being served to reload themselves
+			ctx.mutex.Lock()
+			liveReload := ctx.liveReload
+			ctx.mutex.Unlock()
+			if liveReload != nil {
+				liveReload(value)
+			}
+			return value.watchData
+		},
+	}
+
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if ctx.isDisposed {
+		return nil
+	}
+	ctx.watch = watch
+	watch.start(logLevel, color, mode)
+	return nil
+}
+
+func (ctx *buildContext) Serve(serveOptions ServeOptions) (ServeResult, error) {
+	ctx.mutex.Lock()
+	if ctx.isDisposed {
+		ctx.mutex.Unlock()
+		return ServeResult{}, fmt.Errorf("Cannot serve a build context after it has been disposed")
+	}
+	if ctx.isServing {
+		ctx.mutex.Unlock()
+		return ServeResult{}, fmt.Errorf("The build context is already being served")
+	}
+	ctx.isServing = true
+	buildOpts := ctx.buildOpts
+	ctx.mutex.Unlock()
+
+	result, err := serveImpl(serveOptions, buildOpts, ctx)
+
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if err != nil {
+		ctx.isServing = false
+		ctx.serveBuildOpts = nil
+		return ServeResult{}, err
 	}
+	ctx.stopServe = result.Stop
+	return result, nil
+}
 
-	result := BuildResult{
-		Errors:      convertMessagesToPublic(logger.Error, msgs),
-		Warnings:    convertMessagesToPublic(logger.Warning, msgs),
-		OutputFiles: outputFiles,
-		Metafile:    metafileJSON,
-		Rebuild:     rebuild,
-		Stop:        stop,
+func (ctx *buildContext) Update(options UpdateOptions) error {
+	// Wait for a build that's in progress to finish, since it must not see the
+	// caches change while it's running
+	ctx.buildMutex.Lock()
+	ctx.mutex.Lock()
+	if ctx.isDisposed {
+		ctx.mutex.Unlock()
+		ctx.buildMutex.Unlock()
+		return fmt.Errorf("Cannot update a build context after it has been disposed")
 	}
+	applyUpdateOptions(&ctx.buildOpts, &ctx.logOptions, ctx.caches, ctx.linkCache, options)
+	logLevel := ctx.buildOpts.LogLevel
+	watch := ctx.watch
+	ctx.mutex.Unlock()
+	ctx.buildMutex.Unlock()
+
+	if watch != nil {
+		watch.setLogLevel(logLevel)
+		watch.requestRebuild()
+	}
+	return nil
+}
 
-	for _, onEnd := range onEndCallbacks {
-		onEnd(&result)
+func (ctx *buildContext) isWatching() bool {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	return ctx.watchMode != nil && !ctx.isDisposed
+}
+
+func (ctx *buildContext) setLiveReload(liveReload func(internalBuildResult)) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.liveReload = liveReload
+}
+
+// The server needs to know where the output files go. Unlike with the serve
+// API, the context keeps writing output files to disk if it was configured to.
+// The adjustment is applied to a copy of the build options for each build so
+// that later updates to the context's build options still take effect.
+func (ctx *buildContext) useServeBuildOptions(prepare func(BuildOptions) BuildOptions) error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if ctx.buildOpts.Write && ((ctx.buildOpts.Outdir == "" && ctx.buildOpts.Outfile == "") ||
+		ctx.buildOpts.Outdir == "-" || ctx.buildOpts.Outfile == "-") {
+		return fmt.Errorf("Cannot serve a build context that writes to stdout (set \"Outdir\" or \"Outfile\")")
+	}
+	ctx.serveBuildOpts = func(buildOpts BuildOptions) BuildOptions {
+		serveBuildOpts := prepare(buildOpts)
+		serveBuildOpts.Write = buildOpts.Write
+		serveBuildOpts.Incremental = false
+		return serveBuildOpts
 	}
+	return nil
+}
 
-	return internalBuildResult{
-		result:    result,
-		options:   options,
-		watchData: watchData,
+func (ctx *buildContext) Dispose() {
+	ctx.mutex.Lock()
+	if ctx.isDisposed {
+		ctx.mutex.Unlock()
+		return
+	}
+	ctx.isDisposed = true
+	watch := ctx.watch
+	stopServe := ctx.stopServe
+	ctx.mutex.Unlock()
+
+	if watch != nil {
+		watch.stop()
+	}
+	if stopServe != nil {
+		stopServe(false)
+	}
+
+	// Wait for a build that's in progress to finish before releasing the caches
+	ctx.buildMutex.Lock()
+	if ctx.typeCheck != nil {
+		ctx.typeCheck.stop()
 	}
+	ctx.caches = nil
+	ctx.linkCache = nil
+	ctx.plugins = nil
+	ctx.onEndCallbacks = nil
+	ctx.buildMutex.Unlock()
 }
 
 type watcher struct {
@@ -1111,10 +3350,15 @@ type watcher struct {
 	data              fs.WatchData
 	resolver          resolver.Resolver
 	shouldStop        int32
-	rebuild           func() fs.WatchData
+	rebuildRequested  int32
+	logLevel          int32
+	rebuild           func(changes []WatchChange) fs.WatchData
 	recentItems       []string
 	itemsToScan       []string
 	itemsPerIteration int
+
+	// This is done when the watch loop has exited
+	loopWaitGroup sync.WaitGroup
 }
 
 func (w *watcher) setWatchData(data fs.WatchData) {
@@ -1148,15 +3392,17 @@ const maxIntervalsBeforeUpdate = 20
 
 func (w *watcher) start(logLevel LogLevel, color StderrColor, mode WatchMode) {
 	useColor := validateColor(color)
+	w.setLogLevel(logLevel)
 
+	w.loopWaitGroup.Add(1)
 	go func() {
-		shouldLog := logLevel == LogLevelInfo || logLevel == LogLevelDebug
+		defer w.loopWaitGroup.Done()
 
 		// Note: Do not change these log messages without a breaking version change.
 		// People want to run regexes over esbuild's stderr stream to look for these
 		// messages instead of using esbuild's API.
 
-		if shouldLog {
+		if w.shouldLog() {
 			logger.PrintTextWithColor(os.Stderr, useColor, func(colors logger.Colors) string {
 				return fmt.Sprintf("%s[watch] build finished, watching for changes...%s\n", colors.Dim, colors.Reset)
 			})
@@ -1166,19 +3412,38 @@ func (w *watcher) start(logLevel LogLevel, color StderrColor, mode WatchMode) {
 			// Sleep for the watch interval
 			time.Sleep(watchIntervalSleep)
 
+			// Rebuild if the build options were changed
+			if atomic.SwapInt32(&w.rebuildRequested, 0) != 0 {
+				if w.shouldLog() {
+					logger.PrintTextWithColor(os.Stderr, useColor, func(colors logger.Colors) string {
+						return fmt.Sprintf("%s[watch] build started (options changed)%s\n", colors.Dim, colors.Reset)
+					})
+				}
+
+				// Run the build
+				w.setWatchData(w.rebuild(nil))
+
+				if w.shouldLog() {
+					logger.PrintTextWithColor(os.Stderr, useColor, func(colors logger.Colors) string {
+						return fmt.Sprintf("%s[watch] build finished%s\n", colors.Dim, colors.Reset)
+					})
+				}
+				continue
+			}
+
 			// Rebuild if we're dirty
-			if absPath := w.tryToFindDirtyPath(); absPath != "" {
-				if shouldLog {
+			if change := w.tryToFindDirtyPath(); change.Path != "" {
+				if w.shouldLog() {
 					logger.PrintTextWithColor(os.Stderr, useColor, func(colors logger.Colors) string {
-						prettyPath := w.resolver.PrettyPath(logger.Path{Text: absPath, Namespace: "file"})
+						prettyPath := w.resolver.PrettyPath(logger.Path{Text: change.Path, Namespace: "file"})
 						return fmt.Sprintf("%s[watch] build started (change: %q)%s\n", colors.Dim, prettyPath, colors.Reset)
 					})
 				}
 
 				// Run the build
-				w.setWatchData(w.rebuild())
+				w.setWatchData(w.rebuild(w.collectAllChanges(change)))
 
-				if shouldLog {
+				if w.shouldLog() {
 					logger.PrintTextWithColor(os.Stderr, useColor, func(colors logger.Colors) string {
 						return fmt.Sprintf("%s[watch] build finished%s\n", colors.Dim, colors.Reset)
 					})
@@ -1188,11 +3453,30 @@ func (w *watcher) start(logLevel LogLevel, color StderrColor, mode WatchMode) {
 	}()
 }
 
+func (w *watcher) setLogLevel(logLevel LogLevel) {
+	atomic.StoreInt32(&w.logLevel, int32(logLevel))
+}
+
+func (w *watcher) shouldLog() bool {
+	logLevel := LogLevel(atomic.LoadInt32(&w.logLevel))
+	return logLevel == LogLevelInfo || logLevel == LogLevelDebug
+}
+
+// This is used when the build options are changed. The rebuild happens on the
+// next iteration of the watch loop instead of right away so that it doesn't
+// run at the same time as a rebuild for a file change.
+func (w *watcher) requestRebuild() {
+	atomic.StoreInt32(&w.rebuildRequested, 1)
+}
+
+// This waits for a rebuild that's in progress to finish so that its output
+// files are never left partially written
 func (w *watcher) stop() {
 	atomic.StoreInt32(&w.shouldStop, 1)
+	w.loopWaitGroup.Wait()
 }
 
-func (w *watcher) tryToFindDirtyPath() string {
+func (w *watcher) tryToFindDirtyPath() fs.WatchChange {
 	defer w.mutex.Unlock()
 	w.mutex.Lock()
 
@@ -1219,11 +3503,11 @@ func (w *watcher) tryToFindDirtyPath() string {
 
 	// Always check all recent items every iteration
 	for i, path := range w.recentItems {
-		if dirtyPath := w.data.Paths[path](); dirtyPath != "" {
+		if change := w.data.Paths[path](); change.Path != "" {
 			// Move this path to the back of the list (i.e. the "most recent" position)
 			copy(w.recentItems[i:], w.recentItems[i+1:])
 			w.recentItems[len(w.recentItems)-1] = path
-			return dirtyPath
+			return change
 		}
 	}
 
@@ -1237,7 +3521,7 @@ func (w *watcher) tryToFindDirtyPath() string {
 
 	// Check if any of the entries in this iteration have been modified
 	for _, path := range toCheck {
-		if dirtyPath := w.data.Paths[path](); dirtyPath != "" {
+		if change := w.data.Paths[path](); change.Path != "" {
 			// Mark this item as recent by adding it to the back of the list
 			w.recentItems = append(w.recentItems, path)
 			if len(w.recentItems) > maxRecentItemCount {
@@ -1245,10 +3529,46 @@ func (w *watcher) tryToFindDirtyPath() string {
 				copy(w.recentItems, w.recentItems[1:])
 				w.recentItems = w.recentItems[:maxRecentItemCount]
 			}
-			return dirtyPath
+			return change
 		}
 	}
-	return ""
+	return fs.WatchChange{}
+}
+
+// Only a single change is needed to know that a rebuild is necessary. But
+// once a rebuild is going to happen anyway, it's worth checking everything
+// so that the rebuild can report all of the changes that it includes.
+func (w *watcher) collectAllChanges(first fs.WatchChange) []WatchChange {
+	defer w.mutex.Unlock()
+	w.mutex.Lock()
+
+	seen := map[string]bool{first.Path: true}
+	changes := []WatchChange{convertWatchChangeToPublic(first)}
+	for _, isDirty := range w.data.Paths {
+		if change := isDirty(); change.Path != "" && !seen[change.Path] {
+			seen[change.Path] = true
+			changes = append(changes, convertWatchChangeToPublic(change))
+		}
+	}
+
+	// Sort the changes so the order is deterministic
+	sort.Slice(changes, func(i int, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func convertWatchChangeToPublic(change fs.WatchChange) WatchChange {
+	var kind WatchChangeKind
+	switch change.Kind {
+	case fs.WatchChangeModified:
+		kind = WatchChangeModified
+	case fs.WatchChangeAdded:
+		kind = WatchChangeAdded
+	case fs.WatchChangeDeleted:
+		kind = WatchChangeDeleted
+	}
+	return WatchChange{Path: change.Path, Kind: kind}
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -1266,9 +3586,11 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 	unusedImportsTS := config.UnusedImportsRemoveStmt
 	useDefineForClassFieldsTS := config.Unspecified
 	jsx := config.JSXOptions{
-		Preserve: transformOpts.JSXMode == JSXModePreserve,
-		Factory:  validateJSXExpr(log, transformOpts.JSXFactory, "factory", js_parser.JSXFactory),
-		Fragment: validateJSXExpr(log, transformOpts.JSXFragment, "fragment", js_parser.JSXFragment),
+		Preserve:         transformOpts.JSXMode == JSXModePreserve,
+		AutomaticRuntime: transformOpts.JSXMode == JSXModeAutomatic,
+		ImportSource:     transformOpts.JSXImportSource,
+		Factory:          validateJSXExpr(log, transformOpts.JSXFactory, "factory", js_parser.JSXFactory),
+		Fragment:         validateJSXExpr(log, transformOpts.JSXFragment, "fragment", js_parser.JSXFragment),
 	}
 
 	// Settings from "tsconfig.json" override those
@@ -1287,6 +3609,12 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 			if len(result.JSXFragmentFactory) > 0 {
 				jsx.Fragment = config.JSXExpr{Parts: result.JSXFragmentFactory}
 			}
+			if result.JSXAutomaticRuntime && !jsx.Preserve {
+				jsx.AutomaticRuntime = true
+			}
+			if result.JSXImportSource != "" {
+				jsx.ImportSource = result.JSXImportSource
+			}
 			if result.UseDefineForClassFields != config.Unspecified {
 				useDefineForClassFieldsTS = result.UseDefineForClassFields
 			}
@@ -1306,9 +3634,22 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		transformOpts.Loader = LoaderJS
 	}
 
+	// Transforms don't have a working directory, so the feature support file is
+	// relative to the current directory
+	supported := transformOpts.Supported
+	if transformOpts.SupportedFile != "" {
+		if cwd, err := os.Getwd(); err != nil {
+			log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Failed to get the current directory: %s", err.Error()))
+		} else if realFS, err := fs.RealFS(fs.RealFSOptions{AbsWorkingDir: cwd}); err != nil {
+			log.Add(logger.Error, nil, logger.Range{}, err.Error())
+		} else {
+			supported = loadSupportedFile(log, realFS, transformOpts.SupportedFile, supported)
+		}
+	}
+
 	// Convert and validate the transformOpts
-	targetFromAPI, jsFeatures, cssFeatures, targetEnv := validateFeatures(log, transformOpts.Target, transformOpts.Engines)
-	defines, injectedDefines := validateDefines(log, transformOpts.Define, transformOpts.Pure, PlatformNeutral, false /* minify */)
+	targetFromAPI, jsFeatures, cssFeatures, targetEnv := validateFeatures(log, transformOpts.Target, transformOpts.Engines, supported)
+	defines, injectedDefines := validateDefines(log, transformOpts.Define, transformOpts.FeatureFlags, transformOpts.Pure, PlatformNeutral, false /* minify */)
 	options := config.Options{
 		TargetFromAPI:           targetFromAPI,
 		UnsupportedJSFeatures:   jsFeatures,
@@ -1316,20 +3657,42 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		OriginalTargetEnv:       targetEnv,
 		TSTarget:                tsTarget,
 		JSX:                     jsx,
+		TS:                      config.TSOptions{Version: validateTSVersion(log, transformOpts.TSVersion), Enums: validateTSEnums(transformOpts.TSEnums)},
 		Defines:                 defines,
 		InjectedDefines:         injectedDefines,
 		SourceMap:               validateSourceMap(transformOpts.Sourcemap),
 		LegalComments:           validateLegalComments(transformOpts.LegalComments, false /* bundle */),
+		Annotations:             validateAnnotations(transformOpts.Annotations),
+		MagicComments:           validateAnnotations(transformOpts.MagicComments),
+		LineEnding:              validateLineEnding(transformOpts.LineEnding),
+		IndentUnit:              validateIndent(log, transformOpts.Indent),
 		SourceRoot:              transformOpts.SourceRoot,
 		ExcludeSourcesContent:   transformOpts.SourcesContent == SourcesContentExclude,
+		SourceMapBanners:        transformOpts.SourcemapBanners,
 		OutputFormat:            validateFormat(transformOpts.Format),
 		GlobalName:              validateGlobalName(log, transformOpts.GlobalName),
+		ExternalHelpers:         transformOpts.ExternalHelpers,
 		MangleSyntax:            transformOpts.MinifySyntax,
 		RemoveWhitespace:        transformOpts.MinifyWhitespace,
 		MinifyIdentifiers:       transformOpts.MinifyIdentifiers,
+		MinifySeed:              transformOpts.MinifySeed,
 		ASCIIOnly:               validateASCIIOnly(transformOpts.Charset),
+		CharsetEscapes:          validateCharsetEscapes(log, transformOpts.CharsetEscape),
+		IdentifierCharset:       validateIdentifierCharset(transformOpts.IdentifierCharset),
+		IdentifierNormalization: validateNormalization(transformOpts.IdentifierNormalization),
 		IgnoreDCEAnnotations:    transformOpts.IgnoreAnnotations,
+		InferPureFunctions:      transformOpts.InferPure,
+		DropConsole:             (transformOpts.Drop & DropConsole) != 0,
+		DropDebugger:            (transformOpts.Drop & DropDebugger) != 0,
+		RecoverSyntaxErrors:     transformOpts.RecoverSyntaxErrors,
+		SyntaxErrorLimit:        transformOpts.LogLimit,
 		TreeShaking:             validateTreeShaking(transformOpts.TreeShaking, false /* bundle */, transformOpts.Format),
+		CustomPragmas:           validatePragmas(log, transformOpts.Pragmas),
+		PreserveComments:        validateRegExp(log, transformOpts.PreserveComments, "preserved comments"),
+		MangleProps:             validateRegExp(log, transformOpts.MangleProps, "mangled properties"),
+		ReserveProps:            validateRegExp(log, transformOpts.ReserveProps, "reserved properties"),
+		StripIf:                 validateStripIf(log, transformOpts.StripIf),
+		StripBetween:            validateStripBetween(log, transformOpts.StripBetween),
 		AbsOutputFile:           transformOpts.Sourcefile + "-out",
 		KeepNames:               transformOpts.KeepNames,
 		UseDefineForClassFields: useDefineForClassFieldsTS,
@@ -1340,7 +3703,7 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 			SourceFile: transformOpts.Sourcefile,
 		},
 	}
-	if options.Stdin.Loader == config.LoaderCSS {
+	if options.Stdin.Loader.IsCSS() {
 		options.CSSBanner = transformOpts.Banner
 		options.CSSFooter = transformOpts.Footer
 	} else {
@@ -1358,6 +3721,9 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 	if options.LegalComments.HasExternalFile() {
 		log.Add(logger.Error, nil, logger.Range{}, "Cannot transform with linked or external legal comments")
 	}
+	if options.ExternalHelpers != "" && options.OutputFormat.IsWrappedInFunction() {
+		log.Add(logger.Error, nil, logger.Range{}, fmt.Sprintf("Cannot use \"external-helpers\" with the %q format", options.OutputFormat.String()))
+	}
 
 	// Set the output mode using other settings
 	if options.OutputFormat != config.FormatPreserve {
@@ -1381,7 +3747,7 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		// Stop now if there were errors
 		if !log.HasErrors() {
 			// Compile the bundle
-			results, _ = bundle.Compile(log, options, timer)
+			results, _, _ = bundle.Compile(log, options, timer, nil)
 		}
 
 		timer.Log(log)
@@ -1390,16 +3756,128 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 	// Return the results
 	var code []byte
 	var sourceMap []byte
+	var bannerLine int
+	var footerLine int
 
 	// Unpack the JavaScript file and the source map file
 	if len(results) == 1 {
 		code = results[0].Contents
+		bannerLine, footerLine = results[0].BannerLine, results[0].FooterLine
 	} else if len(results) == 2 {
 		a, b := results[0], results[1]
 		if a.AbsPath == b.AbsPath+".map" {
 			sourceMap, code = a.Contents, b.Contents
+			bannerLine, footerLine = b.BannerLine, b.FooterLine
 		} else if a.AbsPath+".map" == b.AbsPath {
 			code, sourceMap = a.Contents, b.Contents
+			bannerLine, footerLine = a.BannerLine, a.FooterLine
+		}
+	}
+
+	msgs := log.Done()
+	return TransformResult{
+		Errors:     convertMessagesToPublic(logger.Error, msgs),
+		Warnings:   convertMessagesToPublic(logger.Warning, msgs),
+		Code:       code,
+		Map:        sourceMap,
+		BannerLine: bannerLine,
+		FooterLine: footerLine,
+	}
+}
+
+func transformCSSImpl(input string, cssOpts CSSTransformOptions) TransformResult {
+	log := logger.NewStderrLog(logger.OutputOptions{
+		IncludeSource: true,
+		MessageLimit:  cssOpts.LogLimit,
+		Color:         validateColor(cssOpts.Color),
+		LogLevel:      validateLogLevel(cssOpts.LogLevel),
+	})
+
+	// Apply default values
+	if cssOpts.Sourcefile == "" {
+		cssOpts.Sourcefile = "<stdin>"
+	}
+
+	// Convert and validate the options
+	_, _, cssFeatures, _ := validateFeatures(log, cssOpts.Target, cssOpts.Engines, cssOpts.Supported)
+	sourceMap := validateSourceMap(cssOpts.Sourcemap)
+	legalComments := validateLegalComments(cssOpts.LegalComments, false /* bundle */)
+	asciiOnly := validateASCIIOnly(cssOpts.Charset)
+	printOptions := css_printer.Options{
+		RemoveWhitespace: cssOpts.MinifyWhitespace,
+		ASCIIOnly:        asciiOnly,
+		CharsetEscapes:   validateCharsetEscapes(log, cssOpts.CharsetEscape),
+		LegalComments:    legalComments,
+		IndentUnit:       validateIndent(log, cssOpts.Indent),
+	}
+	if sourceMap == config.SourceMapLinkedWithComment {
+		// Linked source maps don't make sense because there's no output file name
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot transform with linked source maps")
+	}
+	if legalComments.HasExternalFile() {
+		log.Add(logger.Error, nil, logger.Range{}, "Cannot transform with linked or external legal comments")
+	}
+
+	var code []byte
+	var sourceMapJSON []byte
+
+	// Stop now if there were errors
+	if !log.HasErrors() {
+		source := logger.Source{
+			KeyPath:    logger.Path{Text: cssOpts.Sourcefile},
+			PrettyPath: cssOpts.Sourcefile,
+			Contents:   input,
+		}
+		parseOptions := css_parser.Options{
+			UnsupportedCSSFeatures: cssFeatures,
+			MangleSyntax:           cssOpts.MinifySyntax,
+			RemoveWhitespace:       cssOpts.MinifyWhitespace,
+		}
+		var tree css_ast.AST
+		if cssOpts.Declarations {
+			tree = css_parser.ParseDeclarationList(log, source, parseOptions)
+		} else {
+			tree = css_parser.Parse(log, source, parseOptions)
+		}
+
+		// Stop now if there were errors
+		if !log.HasErrors() {
+			if sourceMap != config.SourceMapNone {
+				printOptions.AddSourceMappings = true
+				printOptions.LineOffsetTables = sourcemap.GenerateLineOffsetTables(input, tree.ApproximateLineCount)
+			}
+			result := css_printer.Print(tree, printOptions)
+			j := helpers.Joiner{}
+			j.AddBytes(result.CSS)
+
+			// Legal comments that were extracted go at the end of the file
+			if legalComments == config.LegalCommentsEndOfFile && len(result.ExtractedLegalComments) > 0 {
+				texts := make([]string, 0, len(result.ExtractedLegalComments))
+				for text := range result.ExtractedLegalComments {
+					texts = append(texts, text)
+				}
+				sort.Strings(texts)
+				j.EnsureNewlineAtEnd()
+				for _, text := range texts {
+					j.AddString(text)
+					j.AddString("\n")
+				}
+			}
+
+			if sourceMap != config.SourceMapNone {
+				sourceMapJSON = cssSourceMapJSON(source, result.SourceMapChunk, cssOpts, asciiOnly)
+				if sourceMap == config.SourceMapInline || sourceMap == config.SourceMapInlineAndExternal {
+					j.EnsureNewlineAtEnd()
+					j.AddString("/*# sourceMappingURL=data:application/json;base64,")
+					j.AddString(base64.StdEncoding.EncodeToString(sourceMapJSON))
+					j.AddString(" */\n")
+				}
+				if sourceMap == config.SourceMapInline {
+					sourceMapJSON = nil
+				}
+			}
+
+			code = j.Done()
 		}
 	}
 
@@ -1408,17 +3886,75 @@ func transformImpl(input string, transformOpts TransformOptions) TransformResult
 		Errors:   convertMessagesToPublic(logger.Error, msgs),
 		Warnings: convertMessagesToPublic(logger.Warning, msgs),
 		Code:     code,
-		Map:      sourceMap,
+		Map:      sourceMapJSON,
+	}
+}
+
+// There is only one source and no input source map here, so this is a much
+// simpler version of what the linker does to generate a source map
+func cssSourceMapJSON(source logger.Source, chunk sourcemap.Chunk, cssOpts CSSTransformOptions, asciiOnly bool) []byte {
+	j := helpers.Joiner{}
+	j.AddString("{\n  \"version\": 3")
+	j.AddString(",\n  \"sources\": [")
+	j.AddBytes(js_printer.QuoteForJSON(source.PrettyPath, asciiOnly))
+	j.AddString("]")
+	if cssOpts.SourceRoot != "" {
+		j.AddString(",\n  \"sourceRoot\": ")
+		j.AddBytes(js_printer.QuoteForJSON(cssOpts.SourceRoot, asciiOnly))
+	}
+	if cssOpts.SourcesContent != SourcesContentExclude {
+		j.AddString(",\n  \"sourcesContent\": [")
+		j.AddBytes(js_printer.QuoteForJSON(source.Contents, asciiOnly))
+		j.AddString("]")
 	}
+	j.AddString(",\n  \"mappings\": \"")
+	j.AddBytes(chunk.Buffer)
+	j.AddString("\",\n  \"names\": []\n}\n")
+	return j.Done()
 }
 
 ////////////////////////////////////////////////////////////////////////////////
 // Plugin API
 
 type pluginImpl struct {
-	log    logger.Log
-	fs     fs.FS
-	plugin config.Plugin
+	log     logger.Log
+	fs      fs.FS
+	plugin  config.Plugin
+	spooled *spooledFiles
+}
+
+// Contents that plugins return as an "io.Reader" are written to temporary
+// files so they can be streamed more than once (e.g. once to hash them and
+// again to copy them to the output directory). These files are deleted at
+// the end of each build.
+type spooledFiles struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (s *spooledFiles) spool(reader io.Reader) (string, error) {
+	file, err := ioutil.TempFile("", "esbuild-")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	s.mutex.Lock()
+	s.paths = append(s.paths, file.Name())
+	s.mutex.Unlock()
+	return file.Name(), err
+}
+
+func (s *spooledFiles) removeAll() {
+	s.mutex.Lock()
+	paths := s.paths
+	s.paths = nil
+	s.mutex.Unlock()
+	for _, path := range paths {
+		os.Remove(path)
+	}
 }
 
 func (impl *pluginImpl) OnStart(callback func() (OnStartResult, error)) {
@@ -1473,6 +4009,8 @@ func (impl *pluginImpl) OnResolve(options OnResolveOptions, callback func(OnReso
 				kind = ResolveCSSImportRule
 			case ast.ImportURL:
 				kind = ResolveCSSURLToken
+			case ast.ImportComposesFrom:
+				kind = ResolveCSSComposesFrom
 			default:
 				panic("Internal error")
 			}
@@ -1539,6 +4077,19 @@ func (impl *pluginImpl) OnLoad(options OnLoadOptions, callback func(OnLoadArgs)
 
 			result.Contents = response.Contents
 			result.Loader = validateLoader(response.Loader)
+			if response.Contents == nil {
+				if response.ContentsReader != nil {
+					if absPath, err := impl.spooled.spool(response.ContentsReader); err != nil {
+						result.ThrownError = err
+						return
+					} else {
+						result.AbsContentsFile = absPath
+					}
+				} else if response.ContentsFile != "" {
+					pathKind := fmt.Sprintf("contents file path for plugin %q", impl.plugin.Name)
+					result.AbsContentsFile = validatePath(impl.log, impl.fs, response.ContentsFile, pathKind)
+				}
+			}
 			result.PluginData = response.PluginData
 			pathKind := fmt.Sprintf("resolve directory path for plugin %q", impl.plugin.Name)
 			if absPath := validatePath(impl.log, impl.fs, response.ResolveDir, pathKind); absPath != "" {
@@ -1558,6 +4109,155 @@ func (impl *pluginImpl) OnLoad(options OnLoadOptions, callback func(OnLoadArgs)
 	})
 }
 
+func (impl *pluginImpl) OnTransform(options OnTransformOptions, callback func(OnTransformArgs) (OnTransformResult, error)) {
+	filter, err := config.CompileFilterForPlugin(impl.plugin.Name, "OnTransform", options.Filter)
+	if filter == nil {
+		impl.log.Add(logger.Error, nil, logger.Range{}, err.Error())
+		return
+	}
+
+	impl.plugin.OnTransform = append(impl.plugin.OnTransform, config.OnTransform{
+		Filter:    filter,
+		Namespace: options.Namespace,
+		Callback: func(args config.OnTransformArgs) (result config.OnTransformResult) {
+			var sourceMap string
+			if args.SourceMap != nil {
+				sourceMap = *args.SourceMap
+			}
+			response, err := callback(OnTransformArgs{
+				Path:       args.Path.Text,
+				Namespace:  args.Path.Namespace,
+				PluginData: args.PluginData,
+				Loader:     loaderFromConfig(args.Loader),
+				Contents:   args.Contents,
+				SourceMap:  sourceMap,
+			})
+			result.PluginName = response.PluginName
+			result.AbsWatchFiles = impl.validatePathsArray(response.WatchFiles, "watch file")
+			result.AbsWatchDirs = impl.validatePathsArray(response.WatchDirs, "watch directory")
+
+			if err != nil {
+				result.ThrownError = err
+				return
+			}
+
+			result.Contents = response.Contents
+			if response.SourceMap != "" {
+				result.SourceMap = &response.SourceMap
+			}
+
+			// Convert log messages
+			if len(response.Errors)+len(response.Warnings) > 0 {
+				msgs := make(logger.SortableMsgs, 0, len(response.Errors)+len(response.Warnings))
+				msgs = convertMessagesToInternal(msgs, logger.Error, response.Errors)
+				msgs = convertMessagesToInternal(msgs, logger.Warning, response.Warnings)
+				sort.Stable(msgs)
+				result.Msgs = msgs
+			}
+			return
+		},
+	})
+}
+
+func (impl *pluginImpl) OnSourceMap(options OnSourceMapOptions, callback func(OnSourceMapArgs) (OnSourceMapResult, error)) {
+	filter, err := config.CompileFilterForPlugin(impl.plugin.Name, "OnSourceMap", options.Filter)
+	if filter == nil {
+		impl.log.Add(logger.Error, nil, logger.Range{}, err.Error())
+		return
+	}
+
+	impl.plugin.OnSourceMap = append(impl.plugin.OnSourceMap, config.OnSourceMap{
+		Filter: filter,
+		Callback: func(args config.OnSourceMapArgs) (result config.OnSourceMapResult) {
+			var sourceMap map[string]interface{}
+			if err := json.Unmarshal([]byte(args.SourceMap), &sourceMap); err != nil {
+				result.ThrownError = err
+				return
+			}
+			response, err := callback(OnSourceMapArgs{
+				Path:          args.Path,
+				SourceMapPath: args.SourceMapPath,
+				SourceMap:     sourceMap,
+			})
+			result.PluginName = response.PluginName
+
+			if err != nil {
+				result.ThrownError = err
+				return
+			}
+
+			if response.SourceMap != nil {
+				text, err := encodeSourceMap(response.SourceMap)
+				if err != nil {
+					result.ThrownError = fmt.Errorf("Invalid source map: %s", err.Error())
+					return
+				}
+				result.SourceMap = &text
+			}
+
+			// Convert log messages
+			if len(response.Errors)+len(response.Warnings) > 0 {
+				msgs := make(logger.SortableMsgs, 0, len(response.Errors)+len(response.Warnings))
+				msgs = convertMessagesToInternal(msgs, logger.Error, response.Errors)
+				msgs = convertMessagesToInternal(msgs, logger.Warning, response.Warnings)
+				sort.Stable(msgs)
+				result.Msgs = msgs
+			}
+			return
+		},
+	})
+}
+
+// The standard source map fields are written in the order that esbuild uses.
+// Any other fields (such as vendor-specific "x_" fields) come after them in
+// alphabetical order.
+var sourceMapFieldOrder = []string{"version", "file", "sources", "sourceRoot", "sourcesContent", "mappings", "names"}
+
+func encodeSourceMap(sourceMap map[string]interface{}) (string, error) {
+	keys := make([]string, 0, len(sourceMap))
+	isStandard := make(map[string]bool)
+	for _, key := range sourceMapFieldOrder {
+		isStandard[key] = true
+		if _, ok := sourceMap[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	otherKeys := make([]string, 0, len(sourceMap))
+	for key := range sourceMap {
+		if !isStandard[key] {
+			otherKeys = append(otherKeys, key)
+		}
+	}
+	sort.Strings(otherKeys)
+	keys = append(keys, otherKeys...)
+
+	buffer := bytes.Buffer{}
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encode := func(value interface{}) error {
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		buffer.Truncate(buffer.Len() - 1) // Remove the trailing newline
+		return nil
+	}
+
+	buffer.WriteString("{")
+	for i, key := range keys {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		buffer.WriteString("\n  ")
+		encode(key)
+		buffer.WriteString(": ")
+		if err := encode(sourceMap[key]); err != nil {
+			return "", err
+		}
+	}
+	buffer.WriteString("\n}\n")
+	return buffer.String(), nil
+}
+
 func (impl *pluginImpl) validatePathsArray(pathsIn []string, name string) (pathsOut []string) {
 	if len(pathsIn) > 0 {
 		pathKind := fmt.Sprintf("%s path for plugin %q", name, impl.plugin.Name)
@@ -1574,6 +4274,7 @@ func loadPlugins(initialOptions *BuildOptions, fs fs.FS, log logger.Log) (plugin
 	onEnd := func(callback func(*BuildResult)) {
 		onEndCallbacks = append(onEndCallbacks, callback)
 	}
+	spooled := &spooledFiles{}
 
 	// Clone the plugin array to guard against mutation during iteration
 	clone := append(make([]Plugin, 0, len(initialOptions.Plugins)), initialOptions.Plugins...)
@@ -1585,20 +4286,48 @@ func loadPlugins(initialOptions *BuildOptions, fs fs.FS, log logger.Log) (plugin
 		}
 
 		impl := &pluginImpl{
-			fs:     fs,
-			log:    log,
-			plugin: config.Plugin{Name: item.Name},
+			fs:      fs,
+			log:     log,
+			plugin:  config.Plugin{Name: item.Name},
+			spooled: spooled,
+		}
+		guard := pluginGuard{
+			name:     item.Name,
+			optional: item.Optional,
+			timeout:  initialOptions.PluginTimeout,
 		}
 
-		item.Setup(PluginBuild{
-			InitialOptions: initialOptions,
-			OnStart:        impl.OnStart,
-			OnEnd:          onEnd,
-			OnResolve:      impl.OnResolve,
-			OnLoad:         impl.OnLoad,
+		// Setup isn't subject to the timeout since it registers the callbacks
+		err := pluginGuard{}.run("setup", "", func() {
+			item.Setup(PluginBuild{
+				InitialOptions: initialOptions,
+				OnStart:        impl.OnStart,
+				OnEnd:          onEnd,
+				OnResolve:      impl.OnResolve,
+				OnLoad:         impl.OnLoad,
+				OnTransform:    impl.OnTransform,
+				OnSourceMap:    impl.OnSourceMap,
+			})
 		})
+		if err != nil {
+			msgs, thrown := guard.failure(nil, err)
+			for _, msg := range msgs {
+				msg.PluginName = item.Name
+				log.AddMsg(msg)
+			}
+			if thrown != nil {
+				log.AddMsg(logger.Msg{PluginName: item.Name, Kind: logger.Error, Data: logger.MsgData{Text: thrown.Error()}})
+			}
+			continue
+		}
+
+		plugins = append(plugins, guard.guardPlugin(impl.plugin))
+	}
 
-		plugins = append(plugins, impl.plugin)
+	// This must come last so that other "onEnd" callbacks can still read the
+	// output files that were copied from temporary files
+	if len(plugins) > 0 {
+		onEnd(func(*BuildResult) { spooled.removeAll() })
 	}
 	return
 }
@@ -1679,6 +4408,12 @@ func getObjectPropertyArray(expr js_ast.Expr, key string) *js_ast.EArray {
 	return value
 }
 
+// Returns true if this input file path from a metafile is inside the
+// directory for the package "pkg" in a "node_modules" directory
+func isInsidePackage(path string, pkg string) bool {
+	return strings.Contains("/"+strings.ReplaceAll(path, "\\", "/"), "/node_modules/"+pkg+"/")
+}
+
 func analyzeMetafileImpl(metafile string, opts AnalyzeMetafileOptions) string {
 	log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
 	source := logger.Source{Contents: metafile}
@@ -1703,13 +4438,22 @@ func analyzeMetafileImpl(metafile string, opts AnalyzeMetafileOptions) string {
 
 							for _, input := range inputs.Properties {
 								if bytesInOutput := getObjectPropertyNumber(input.ValueOrNil, "bytesInOutput"); bytesInOutput != nil && bytesInOutput.Value > 0 {
+									name := js_lexer.UTF16ToString(input.Key.Data.(*js_ast.EString).Value)
+									if opts.Filter != "" && !isInsidePackage(name, opts.Filter) {
+										continue
+									}
 									children = append(children, metafileEntry{
-										name: js_lexer.UTF16ToString(input.Key.Data.(*js_ast.EString).Value),
+										name: name,
 										size: int(bytesInOutput.Value),
 									})
 								}
 							}
 
+							// Omit output files without any matching input files
+							if opts.Filter != "" && len(children) == 0 {
+								continue
+							}
+
 							sort.Sort(children)
 
 							entries = append(entries, metafileEntry{
@@ -1931,9 +4675,68 @@ func analyzeMetafileImpl(metafile string, opts AnalyzeMetafileOptions) string {
 				))
 			}
 
+			// If we're in verbose mode, also show which packages are the most
+			// expensive to evaluate to help choose packages to evaluate lazily
+			if opts.Verbose {
+				sb.WriteString(analyzeEvaluationCost(result, opts, colors))
+			}
+
 			return sb.String()
 		}
 	}
 
 	return ""
 }
+
+// Files inside packages have an "evaluationCost" property in the metafile,
+// which is the number of top-level statements that run when the file is
+// evaluated. This sums them up for each package.
+func analyzeEvaluationCost(metafile js_ast.Expr, opts AnalyzeMetafileOptions, colors logger.Colors) string {
+	costForPackage := make(map[string]int)
+	if inputs := getObjectPropertyObject(metafile, "inputs"); inputs != nil {
+		for _, input := range inputs.Properties {
+			if cost := getObjectPropertyNumber(input.ValueOrNil, "evaluationCost"); cost != nil {
+				path := strings.ReplaceAll(js_lexer.UTF16ToString(input.Key.Data.(*js_ast.EString).Value), "\\", "/")
+				i := strings.LastIndex("/"+path, "/node_modules/")
+				if i == -1 {
+					continue
+				}
+				parts := strings.Split(path[i+len("node_modules/"):], "/")
+				name := parts[0]
+				if strings.HasPrefix(name, "@") && len(parts) > 1 {
+					name += "/" + parts[1]
+				}
+				if opts.Filter != "" && name != opts.Filter {
+					continue
+				}
+				costForPackage[name] += int(cost.Value)
+			}
+		}
+	}
+	if len(costForPackage) == 0 {
+		return ""
+	}
+
+	var entries metafileArray
+	maxNameLen := 0
+	for name, cost := range costForPackage {
+		entries = append(entries, metafileEntry{name: name, size: cost})
+		if n := utf8.RuneCountInString(name); n > maxNameLen {
+			maxNameLen = n
+		}
+	}
+	sort.Sort(entries)
+
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("\n  %sTop-level statements evaluated by each package%s\n", colors.Bold, colors.Reset))
+	for _, entry := range entries {
+		sb.WriteString(fmt.Sprintf("  %s %s%s%s %d\n",
+			entry.name,
+			colors.Dim,
+			strings.Repeat("─", 1+maxNameLen-utf8.RuneCountInString(entry.name)),
+			colors.Reset,
+			entry.size,
+		))
+	}
+	return sb.String()
+}
@@ -0,0 +1,129 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package api
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func makeContextForTest(t *testing.T, buildOpts BuildOptions) (BuildContext, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "in.js"), []byte("console.log(FOO)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buildOpts.EntryPoints = []string{"in.js"}
+	buildOpts.AbsWorkingDir = dir
+	buildOpts.LogLevel = LogLevelSilent
+	ctx, ctxErr := Context(buildOpts)
+	if ctxErr != nil {
+		t.Fatal(ctxErr)
+	}
+	t.Cleanup(ctx.Dispose)
+	return ctx, dir
+}
+
+func TestContextServeKeepsBuildOptions(t *testing.T) {
+	ctx, dir := makeContextForTest(t, BuildOptions{
+		Define: map[string]string{"FOO": "1"},
+	})
+	before := ctx.(*buildContext).buildOpts
+
+	// The server's adjustments must not leak into the context's build options
+	if _, err := ctx.Serve(ServeOptions{Host: "127.0.0.1", Servedir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	after := ctx.(*buildContext).buildOpts
+	test.AssertEqual(t, reflect.DeepEqual(before, after), true)
+	test.AssertEqual(t, after.Outdir, "")
+
+	// Builds for the server still use the server's output directory
+	result := ctx.Rebuild()
+	test.AssertEqual(t, len(result.Errors), 0)
+	test.AssertEqual(t, result.OutputFiles[0].Path, filepath.Join(dir, "...", "in.js"))
+}
+
+func TestContextFailedServeKeepsBuildOptions(t *testing.T) {
+	ctx, _ := makeContextForTest(t, BuildOptions{})
+
+	// Serving fails after the build options have been checked
+	if _, err := ctx.Serve(ServeOptions{Host: "127.0.0.1", Throttle: []ServeThrottle{{Pattern: "x"}}}); err == nil {
+		t.Fatal("Expected an error")
+	}
+	result := ctx.Rebuild()
+	test.AssertEqual(t, len(result.Errors), 0)
+	test.AssertEqual(t, result.OutputFiles[0].Path, "<stdout>")
+}
+
+func TestContextConcurrentRebuildAndDispose(t *testing.T) {
+	ctx, _ := makeContextForTest(t, BuildOptions{
+		Bundle: true,
+		Define: map[string]string{"FOO": "1"},
+	})
+
+	var wait sync.WaitGroup
+	errors := make(chan string, 32)
+	for i := 0; i < 16; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			result := ctx.Rebuild()
+			for _, msg := range result.Errors {
+				errors <- msg.Text
+			}
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			ctx.Dispose()
+		}()
+	}
+	wait.Wait()
+	close(errors)
+
+	// Each rebuild either finished before the context was disposed or failed
+	for text := range errors {
+		test.AssertEqual(t, text, "Cannot rebuild a build context after it has been disposed")
+	}
+	result := ctx.Rebuild()
+	test.AssertEqual(t, len(result.Errors), 1)
+	test.AssertEqual(t, result.Errors[0].Text, "Cannot rebuild a build context after it has been disposed")
+}
+
+func TestContextConcurrentRebuildAndUpdate(t *testing.T) {
+	ctx, _ := makeContextForTest(t, BuildOptions{
+		Define: map[string]string{"FOO": "1"},
+	})
+
+	var wait sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wait.Add(2)
+		go func() {
+			defer wait.Done()
+			result := ctx.Rebuild()
+			test.AssertEqual(t, len(result.Errors), 0)
+		}()
+		go func() {
+			defer wait.Done()
+			if err := ctx.Update(UpdateOptions{Define: map[string]string{"FOO": "2"}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	result := ctx.Rebuild()
+	test.AssertEqual(t, string(result.OutputFiles[0].Contents), "console.log(2);\n")
+}
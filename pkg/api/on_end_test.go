@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func buildWithOnEndForTest(t *testing.T, contents string, onEnd func(*BuildResult)) (BuildResult, string) {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "in.js"), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := Build(BuildOptions{
+		EntryPoints:   []string{"in.js"},
+		Outdir:        "out",
+		Sourcemap:     SourceMapExternal,
+		AbsWorkingDir: dir,
+		LogLevel:      LogLevelSilent,
+		Write:         true,
+		Plugins: []Plugin{{
+			Name: "on-end",
+			Setup: func(build PluginBuild) {
+				build.OnEnd(onEnd)
+			},
+		}},
+	})
+	return result, filepath.Join(dir, "out")
+}
+
+func readOutputDirForTest(t *testing.T, outdir string) string {
+	t.Helper()
+	entries, err := ioutil.ReadDir(outdir)
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func TestOnEndChangesOutputFiles(t *testing.T) {
+	var seenOnDisk string
+	result, outdir := buildWithOnEndForTest(t, "console.log(1)", func(result *BuildResult) {
+		// The callback runs before anything is written
+		seenOnDisk = readOutputDirForTest(t, filepath.Dir(result.OutputFiles[0].Path))
+
+		var outputFiles []OutputFile
+		for _, file := range result.OutputFiles {
+			switch filepath.Base(file.Path) {
+			case "in.js":
+				file.Contents = bytes.ReplaceAll(file.Contents, []byte("1"), []byte("2"))
+				outputFiles = append(outputFiles, file, OutputFile{
+					Path:     strings.TrimSuffix(file.Path, ".js") + ".html",
+					Contents: []byte("<script src=\"in.js\"></script>\n"),
+				})
+			case "in.js.map":
+				// Leave out the source map
+			default:
+				outputFiles = append(outputFiles, file)
+			}
+		}
+		result.OutputFiles = outputFiles
+		result.Warnings = append(result.Warnings, Message{Text: "from onEnd"})
+	})
+
+	test.AssertEqual(t, seenOnDisk, "")
+	test.AssertEqual(t, len(result.Errors), 0)
+	test.AssertEqual(t, len(result.Warnings), 1)
+	test.AssertEqual(t, result.Warnings[0].Text, "from onEnd")
+	test.AssertEqual(t, readOutputDirForTest(t, outdir), "in.html in.js")
+	js, _ := ioutil.ReadFile(filepath.Join(outdir, "in.js"))
+	test.AssertEqual(t, string(js), "console.log(2);\n")
+	html, _ := ioutil.ReadFile(filepath.Join(outdir, "in.html"))
+	test.AssertEqual(t, string(html), "<script src=\"in.js\"></script>\n")
+}
+
+func TestOnEndErrorStopsWriting(t *testing.T) {
+	result, outdir := buildWithOnEndForTest(t, "console.log(1)", func(result *BuildResult) {
+		result.Errors = append(result.Errors, Message{Text: "from onEnd"})
+	})
+
+	test.AssertEqual(t, len(result.Errors), 1)
+	test.AssertEqual(t, result.Errors[0].Text, "from onEnd")
+	test.AssertEqual(t, readOutputDirForTest(t, outdir), "")
+}
+
+func TestOnEndRelativeOutputPath(t *testing.T) {
+	result, outdir := buildWithOnEndForTest(t, "console.log(1)", func(result *BuildResult) {
+		result.OutputFiles = append(result.OutputFiles, OutputFile{Path: "out/extra.txt", Contents: []byte("extra")})
+	})
+
+	test.AssertEqual(t, len(result.Errors), 1)
+	test.AssertEqual(t, result.Errors[0].Text, "The path of an output file from an \"onEnd\" callback must be absolute: \"out/extra.txt\"")
+	test.AssertEqual(t, readOutputDirForTest(t, outdir), "")
+}
+
+func TestOnEndAfterFailedBuild(t *testing.T) {
+	calls := 0
+	result, outdir := buildWithOnEndForTest(t, "console.log(", func(result *BuildResult) {
+		// The callback for a failed build sees the errors and no output files
+		calls++
+		test.AssertEqual(t, len(result.Errors), 1)
+		test.AssertEqual(t, len(result.OutputFiles), 0)
+	})
+
+	test.AssertEqual(t, calls, 1)
+	test.AssertEqual(t, len(result.Errors), 1)
+	test.AssertEqual(t, readOutputDirForTest(t, outdir), "")
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evanw/esbuild/internal/config"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// Every plugin callback is run inside a guard so that one misbehaving plugin
+// can't take down the whole build. A panic is turned into an error that names
+// the plugin, the callback, and the path it was called for. With a timeout,
+// callbacks that take too long are abandoned with a similar error. Failures
+// of optional plugins are logged as warnings and the callback is skipped.
+type pluginGuard struct {
+	name     string
+	optional bool
+	timeout  time.Duration
+}
+
+// Callbacks that time out keep running on another goroutine since there's no
+// way to stop them. Their results are never used, so callers must only read
+// anything written by the callback if this returns nil.
+func (g pluginGuard) run(hook string, subject string, callback func()) error {
+	what := fmt.Sprintf("The %q callback", hook)
+	if subject != "" {
+		what += fmt.Sprintf(" for %q", subject)
+	}
+
+	if g.timeout <= 0 {
+		return recoverPluginPanic(what, callback)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- recoverPluginPanic(what, callback)
+	}()
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%s timed out after %dms", what, g.timeout.Milliseconds())
+	}
+}
+
+func recoverPluginPanic(what string, callback func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", what, r)
+		}
+	}()
+	callback()
+	return nil
+}
+
+// This returns the messages and error to report for a failed callback
+func (g pluginGuard) failure(msgs []logger.Msg, err error) ([]logger.Msg, error) {
+	if !g.optional {
+		return msgs, err
+	}
+	return append(msgs, logger.Msg{
+		Kind:  logger.Warning,
+		Data:  logger.MsgData{Text: err.Error(), UserDetail: err},
+		Notes: []logger.MsgData{{Text: fmt.Sprintf("The plugin %q is optional, so this error does not fail the build", g.name)}},
+	}), nil
+}
+
+func (g pluginGuard) guardPlugin(plugin config.Plugin) config.Plugin {
+	guarded := config.Plugin{Name: plugin.Name}
+
+	for _, onStart := range plugin.OnStart {
+		callback := onStart.Callback
+		onStart.Callback = func() config.OnStartResult {
+			var response config.OnStartResult
+			if err := g.run("onStart", "", func() { response = callback() }); err != nil {
+				msgs, thrown := g.failure(nil, err)
+				return config.OnStartResult{Msgs: msgs, ThrownError: thrown}
+			}
+			if response.ThrownError != nil && g.optional {
+				msgs, _ := g.failure(response.Msgs, response.ThrownError)
+				return config.OnStartResult{Msgs: msgs}
+			}
+			return response
+		}
+		guarded.OnStart = append(guarded.OnStart, onStart)
+	}
+
+	for _, onResolve := range plugin.OnResolve {
+		callback := onResolve.Callback
+		onResolve.Callback = func(args config.OnResolveArgs) config.OnResolveResult {
+			var response config.OnResolveResult
+			if err := g.run("onResolve", args.Path, func() { response = callback(args) }); err != nil {
+				msgs, thrown := g.failure(nil, err)
+				return config.OnResolveResult{Msgs: msgs, ThrownError: thrown}
+			}
+			if response.ThrownError != nil && g.optional {
+				msgs, _ := g.failure(response.Msgs, response.ThrownError)
+				return config.OnResolveResult{PluginName: response.PluginName, Msgs: msgs}
+			}
+			return response
+		}
+		guarded.OnResolve = append(guarded.OnResolve, onResolve)
+	}
+
+	for _, onLoad := range plugin.OnLoad {
+		callback := onLoad.Callback
+		onLoad.Callback = func(args config.OnLoadArgs) config.OnLoadResult {
+			var response config.OnLoadResult
+			if err := g.run("onLoad", args.Path.Text, func() { response = callback(args) }); err != nil {
+				msgs, thrown := g.failure(nil, err)
+				return config.OnLoadResult{Msgs: msgs, ThrownError: thrown}
+			}
+			if response.ThrownError != nil && g.optional {
+				msgs, _ := g.failure(response.Msgs, response.ThrownError)
+				return config.OnLoadResult{PluginName: response.PluginName, Msgs: msgs}
+			}
+			return response
+		}
+		guarded.OnLoad = append(guarded.OnLoad, onLoad)
+	}
+
+	for _, onTransform := range plugin.OnTransform {
+		callback := onTransform.Callback
+		onTransform.Callback = func(args config.OnTransformArgs) config.OnTransformResult {
+			var response config.OnTransformResult
+			if err := g.run("onTransform", args.Path.Text, func() { response = callback(args) }); err != nil {
+				msgs, thrown := g.failure(nil, err)
+				return config.OnTransformResult{Msgs: msgs, ThrownError: thrown}
+			}
+			if response.ThrownError != nil && g.optional {
+				msgs, _ := g.failure(response.Msgs, response.ThrownError)
+				return config.OnTransformResult{PluginName: response.PluginName, Msgs: msgs}
+			}
+			return response
+		}
+		guarded.OnTransform = append(guarded.OnTransform, onTransform)
+	}
+
+	for _, onSourceMap := range plugin.OnSourceMap {
+		callback := onSourceMap.Callback
+		onSourceMap.Callback = func(args config.OnSourceMapArgs) config.OnSourceMapResult {
+			var response config.OnSourceMapResult
+			if err := g.run("onSourceMap", args.Path, func() { response = callback(args) }); err != nil {
+				msgs, thrown := g.failure(nil, err)
+				return config.OnSourceMapResult{Msgs: msgs, ThrownError: thrown}
+			}
+			if response.ThrownError != nil && g.optional {
+				msgs, _ := g.failure(response.Msgs, response.ThrownError)
+				return config.OnSourceMapResult{PluginName: response.PluginName, Msgs: msgs}
+			}
+			return response
+		}
+		guarded.OnSourceMap = append(guarded.OnSourceMap, onSourceMap)
+	}
+
+	return guarded
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func TestProgress(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.js":      "import './shared.js'; import './a2.js'",
+		"a2.js":     "import './a3.js'",
+		"a3.js":     "console.log('a3')",
+		"b.js":      "import './shared.js'; import('./b2.js')",
+		"b2.js":     "console.log('b2')",
+		"shared.js": "console.log('shared')",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, splitting := range []bool{false, true} {
+		t.Run(fmt.Sprintf("Splitting=%v", splitting), func(t *testing.T) {
+			var events []ProgressEvent
+			result := Build(BuildOptions{
+				EntryPoints:   []string{"a.js", "b.js"},
+				AbsWorkingDir: dir,
+				Outdir:        filepath.Join(dir, fmt.Sprintf("out-%v", splitting)),
+				Bundle:        true,
+				Splitting:     splitting,
+				Format:        FormatESModule,
+				Write:         true,
+				LogLevel:      LogLevelSilent,
+				Progress: func(event ProgressEvent) {
+					events = append(events, event)
+				},
+			})
+			test.AssertEqual(t, len(result.Errors), 0)
+
+			// The phases happen in order and the counts never go down
+			var lastScan, lastLink ProgressEvent
+			phase := ProgressScan
+			writes := 0
+			for i, event := range events {
+				if event.Phase < phase {
+					t.Fatalf("Event %d went back from phase %d to phase %d", i, phase, event.Phase)
+				}
+				phase = event.Phase
+				switch event.Phase {
+				case ProgressScan:
+					if event.FilesParsed < lastScan.FilesParsed || event.FilesDiscovered < lastScan.FilesDiscovered || event.FilesParsed > event.FilesDiscovered {
+						t.Fatalf("Unexpected scan event %d: %+v after %+v", i, event, lastScan)
+					}
+					lastScan = event
+				case ProgressLink:
+					if event.EntryPointsLinked < lastLink.EntryPointsLinked {
+						t.Fatalf("Unexpected link event %d: %+v after %+v", i, event, lastLink)
+					}
+					test.AssertEqual(t, event.EntryPointsTotal, 2)
+					lastLink = event
+				case ProgressWrite:
+					writes++
+				}
+			}
+
+			// Scanning ends once every discovered file was parsed, linking ends
+			// once every entry point was linked, and the last event is the write
+			test.AssertEqual(t, lastScan.FilesParsed, len(files))
+			test.AssertEqual(t, lastScan.FilesDiscovered, len(files))
+			test.AssertEqual(t, lastLink.EntryPointsLinked, 2)
+			test.AssertEqual(t, writes, 1)
+			test.AssertEqual(t, events[len(events)-1].Phase, ProgressWrite)
+		})
+	}
+}
@@ -4,9 +4,12 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"path"
 	"sort"
 	"strconv"
@@ -15,6 +18,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/evanw/esbuild/internal/cache"
 	"github.com/evanw/esbuild/internal/config"
 	"github.com/evanw/esbuild/internal/fs"
 	"github.com/evanw/esbuild/internal/helpers"
@@ -35,6 +39,39 @@ type apiHandler struct {
 	fs               fs.FS
 	serveWaitGroup   sync.WaitGroup
 	serveError       error
+
+	// Each project has its own handler that serves the project's build under
+	// a URL path prefix such as "/app1". The prefix is empty for the main
+	// handler, which serves everything else and owns the project handlers.
+	urlPrefix string
+	projects  []*apiHandler
+
+	// Only the main handler has throttling rules since they match the full URL
+	// path, including the URL path prefix of a project
+	throttle []ServeThrottle
+
+	// Requests that would otherwise fail with a 404 are forwarded to this
+	// backend if present. Only the main handler has one.
+	proxy *httputil.ReverseProxy
+
+	// Live reload is only possible when serving a build context with a
+	// fallback directory. Each connected page has a channel that's signaled
+	// after every successful rebuild done by the context's watcher.
+	isWatching        func() bool
+	liveReloadClients map[chan struct{}]bool
+
+	// This is incremented by "Restart()" so that builds that were started with
+	// the old build options don't replace the state for the new build options
+	generation int
+
+	// This stops the type check command that was started by the first build
+	// with the current build options, if there is one
+	stopRebuilds func()
+
+	// These are used to answer readiness checks
+	hasBuilt           bool
+	lastBuildHadErrors bool
+	isStopping         bool
 }
 
 type runningBuild struct {
@@ -50,11 +87,27 @@ func (h *apiHandler) build() BuildResult {
 			build := &runningBuild{}
 			build.waitGroup.Add(1)
 			h.currentBuild = build
+			rebuild := h.rebuild
+			generation := h.generation
 
 			// Build on another thread
 			go func() {
-				result := h.rebuild()
-				h.rebuild = result.Rebuild
+				result := rebuild()
+				h.mutex.Lock()
+				if generation == h.generation && !h.isStopping {
+					if result.Rebuild != nil {
+						h.rebuild = result.Rebuild
+					}
+					if result.Stop != nil {
+						h.stopRebuilds = result.Stop
+					}
+					h.hasBuilt = true
+					h.lastBuildHadErrors = len(result.Errors) > 0
+				} else if result.Stop != nil {
+					// The build options have changed since this build started
+					go result.Stop()
+				}
+				h.mutex.Unlock()
 				build.result = result
 				build.waitGroup.Done()
 
@@ -64,7 +117,9 @@ func (h *apiHandler) build() BuildResult {
 				time.Sleep(250 * time.Millisecond)
 				h.mutex.Lock()
 				defer h.mutex.Unlock()
-				h.currentBuild = nil
+				if h.currentBuild == build {
+					h.currentBuild = nil
+				}
 			}()
 		}
 		return h.currentBuild
@@ -73,6 +128,16 @@ func (h *apiHandler) build() BuildResult {
 	return build.result
 }
 
+func (h *apiHandler) stopTypeCheck() {
+	h.mutex.Lock()
+	stop := h.stopRebuilds
+	h.stopRebuilds = nil
+	h.mutex.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
 func escapeForHTML(text string) string {
 	text = strings.ReplaceAll(text, "&", "&amp;")
 	text = strings.ReplaceAll(text, "<", "&lt;")
@@ -114,15 +179,243 @@ func errorsToString(errors []Message) string {
 	return sb.String()
 }
 
+// These paths are reserved for health checks. They never trigger a build so
+// that a process supervisor polling them doesn't cause any extra work.
+const (
+	serveHealthPath    = "/__esbuild/healthz"
+	serveReadinessPath = "/__esbuild/readyz"
+)
+
+func (h *apiHandler) serveHealthCheck(res http.ResponseWriter, req *http.Request, start time.Time) bool {
+	var status int
+	var text string
+
+	switch req.URL.Path {
+	case serveHealthPath:
+		// The server is alive as long as it's able to respond at all
+		status = http.StatusOK
+		text = "ok"
+
+	case serveReadinessPath:
+		// The server is only ready once every build has finished without errors
+		h.mutex.Lock()
+		hasBuilt := h.hasBuilt
+		lastBuildHadErrors := h.lastBuildHadErrors
+		isStopping := h.isStopping
+		h.mutex.Unlock()
+		for _, project := range h.projects {
+			project.mutex.Lock()
+			hasBuilt = hasBuilt && project.hasBuilt
+			lastBuildHadErrors = lastBuildHadErrors || project.lastBuildHadErrors
+			project.mutex.Unlock()
+		}
+		switch {
+		case isStopping:
+			status = http.StatusServiceUnavailable
+			text = "stopping"
+		case !hasBuilt:
+			status = http.StatusServiceUnavailable
+			text = "building"
+		case lastBuildHadErrors:
+			status = http.StatusServiceUnavailable
+			text = "build failed"
+		default:
+			status = http.StatusOK
+			text = "ready"
+		}
+
+	default:
+		return false
+	}
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	go h.notifyRequest(time.Since(start), req, status)
+	res.WriteHeader(status)
+	res.Write([]byte(text))
+	return true
+}
+
+// Pages connect to this path to find out when to reload. HTML files in the
+// fallback directory get a script that does this added to them, but only
+// while the build context is watching for changes. The script is a separate
+// file instead of an inline script so that it isn't blocked by a content
+// security policy that only allows scripts from the same origin.
+const serveLiveReloadPath = "/__esbuild/reload"
+const serveLiveReloadScriptPath = "/__esbuild/livereload.js"
+
+const liveReloadScript = `new EventSource("` + serveLiveReloadPath + `").addEventListener("reload",()=>location.reload());` + "\n"
+const liveReloadScriptTag = `<script src="` + serveLiveReloadScriptPath + `"></script>`
+
+func (h *apiHandler) isLiveReloadEnabled() bool {
+	return h.isWatching != nil && h.isWatching()
+}
+
+func (h *apiHandler) serveLiveReload(res http.ResponseWriter, req *http.Request, start time.Time) bool {
+	if (req.URL.Path != serveLiveReloadPath && req.URL.Path != serveLiveReloadScriptPath) || !h.isLiveReloadEnabled() {
+		return false
+	}
+	if req.URL.Path == serveLiveReloadScriptPath {
+		res.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		res.Header().Set("Content-Length", fmt.Sprintf("%d", len(liveReloadScript)))
+		res.Header().Set("Cache-Control", "no-store")
+		go h.notifyRequest(time.Since(start), req, http.StatusOK)
+		res.Write([]byte(liveReloadScript))
+		return true
+	}
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		return false
+	}
+
+	h.mutex.Lock()
+	if h.isStopping {
+		h.mutex.Unlock()
+		return false
+	}
+	client := make(chan struct{}, 1)
+	h.liveReloadClients[client] = true
+	h.mutex.Unlock()
+
+	defer func() {
+		h.mutex.Lock()
+		delete(h.liveReloadClients, client)
+		h.mutex.Unlock()
+	}()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Access-Control-Allow-Origin", "*")
+	go h.notifyRequest(time.Since(start), req, http.StatusOK)
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Keep the connection open until the page goes away or the server stops
+	for {
+		select {
+		case _, ok := <-client:
+			if !ok {
+				return true
+			}
+			if _, err := res.Write([]byte("event: reload\ndata: \n\n")); err != nil {
+				return true
+			}
+			flusher.Flush()
+
+		case <-req.Context().Done():
+			return true
+		}
+	}
+}
+
+// This is called after each rebuild in watch mode. The reloaded page is
+// served from the result of this rebuild instead of building again, and the
+// result stays current until the watcher rebuilds.
+func (h *apiHandler) broadcastLiveReload(build internalBuildResult) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.isStopping {
+		return
+	}
+
+	h.options = &build.options
+	h.currentBuild = &runningBuild{result: build.result}
+	h.hasBuilt = true
+	h.lastBuildHadErrors = len(build.result.Errors) > 0
+	if h.lastBuildHadErrors {
+		return
+	}
+
+	for client := range h.liveReloadClients {
+		select {
+		case client <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Event streams never end on their own, so they must be ended before the
+// server can be shut down gracefully
+func (h *apiHandler) stopLiveReload() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for client := range h.liveReloadClients {
+		close(client)
+		delete(h.liveReloadClients, client)
+	}
+}
+
+// The script goes at the end of the "<head>" element if there is one so
+// that it's loaded early. Otherwise it goes at the end of the page.
+func injectLiveReloadScript(html []byte) []byte {
+	lower := strings.ToLower(string(html))
+	i := strings.Index(lower, "</head>")
+	if i == -1 {
+		i = strings.LastIndex(lower, "</body>")
+	}
+	if i == -1 {
+		i = len(html)
+	}
+	result := make([]byte, 0, len(html)+len(liveReloadScriptTag))
+	result = append(result, html[:i]...)
+	result = append(result, liveReloadScriptTag...)
+	return append(result, html[i:]...)
+}
+
 func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	start := time.Now()
 
+	// Handle health checks
+	if (req.Method == "GET" || req.Method == "HEAD") && h.serveHealthCheck(res, req, start) {
+		return
+	}
+
+	// Handle pages waiting to be reloaded
+	if req.Method == "GET" && h.serveLiveReload(res, req, start) {
+		return
+	}
+
+	// Simulate a slow network for this URL path if requested
+	if throttle := h.throttleForPath(req.URL.Path); throttle != nil {
+		res = &throttledResponseWriter{
+			ResponseWriter: res,
+			latency:        time.Duration(throttle.LatencyInMS) * time.Millisecond,
+			bytesPerSecond: throttle.BytesPerSecond,
+		}
+	}
+
+	// Requests within the URL path prefix of a project are handled by that
+	// project. The projects are sorted so that longer prefixes come first.
+	if req.Method == "GET" {
+		for _, project := range h.projects {
+			if req.URL.Path == project.urlPrefix || strings.HasPrefix(req.URL.Path, project.urlPrefix+"/") {
+				urlPath := req.URL.Path[len(project.urlPrefix):]
+				if urlPath == "" {
+					urlPath = "/"
+				}
+				project.serveRequest(res, req, start, urlPath)
+				return
+			}
+		}
+	}
+
+	h.serveRequest(res, req, start, req.URL.Path)
+}
+
+// The URL path is relative to the URL path prefix of this handler
+func (h *apiHandler) serveRequest(res http.ResponseWriter, req *http.Request, start time.Time, urlPath string) {
 	// Handle get requests
-	if req.Method == "GET" && strings.HasPrefix(req.URL.Path, "/") {
+	if req.Method == "GET" && strings.HasPrefix(urlPath, "/") {
 		res.Header().Set("Access-Control-Allow-Origin", "*")
-		queryPath := path.Clean(req.URL.Path)[1:]
+		queryPath := path.Clean(urlPath)[1:]
 		result := h.build()
 
+		// Restarting may change the output directory, so read it after building
+		h.mutex.Lock()
+		outdirPathPrefix := h.outdirPathPrefix
+		options := h.options
+		h.mutex.Unlock()
+
 		// Requests fail if the build had errors
 		if len(result.Errors) > 0 {
 			go h.notifyRequest(time.Since(start), req, http.StatusServiceUnavailable)
@@ -138,17 +431,17 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		fileEntries := make(map[string]bool)
 
 		// Check for a match with the results if we're within the output directory
-		if strings.HasPrefix(queryPath, h.outdirPathPrefix) {
-			outdirQueryPath := queryPath[len(h.outdirPathPrefix):]
+		if strings.HasPrefix(queryPath, outdirPathPrefix) {
+			outdirQueryPath := queryPath[len(outdirPathPrefix):]
 			if strings.HasPrefix(outdirQueryPath, "/") {
 				outdirQueryPath = outdirQueryPath[1:]
 			}
-			resultKind, inMemoryBytes := h.matchQueryPathToResult(outdirQueryPath, &result, dirEntries, fileEntries)
+			resultKind, inMemoryBytes := h.matchQueryPathToResult(outdirQueryPath, options, &result, dirEntries, fileEntries)
 			kind = resultKind
 			fileContents = &fs.InMemoryOpenedFile{Contents: inMemoryBytes}
 		} else {
 			// Create a fake directory entry for the output path so that it appears to be a real directory
-			p := h.outdirPathPrefix
+			p := outdirPathPrefix
 			for p != "" {
 				var dir string
 				var base string
@@ -167,6 +460,18 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 			}
 		}
 
+		// Create fake directory entries for the URL path prefixes of projects
+		if kind != fs.FileEntry {
+			for _, project := range h.projects {
+				for p := project.urlPrefix; p != "/"; p = path.Dir(p) {
+					if path.Dir(p) == "/"+queryPath {
+						kind = fs.DirEntry
+						dirEntries[path.Base(p)] = true
+					}
+				}
+			}
+		}
+
 		// Check for a file in the fallback directory
 		if h.servedir != "" && kind != fs.FileEntry {
 			absPath := h.fs.Join(h.servedir, queryPath)
@@ -264,6 +569,11 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 				return
 			}
 
+			// Add the live reload client to HTML pages
+			if !isRange && path.Ext(queryPath) == ".html" && h.isLiveReloadEnabled() {
+				fileBytes = injectLiveReloadScript(fileBytes)
+			}
+
 			// If we get here, the request was successful
 			if contentType := helpers.MimeTypeByExtension(path.Ext(queryPath)); contentType != "" {
 				res.Header().Set("Content-Type", contentType)
@@ -282,7 +592,7 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 
 		// Serve a directory listing
 		if kind == fs.DirEntry {
-			html := respondWithDirList(queryPath, dirEntries, fileEntries)
+			html := respondWithDirList(strings.TrimPrefix(path.Join(h.urlPrefix, queryPath), "/"), dirEntries, fileEntries)
 			res.Header().Set("Content-Type", "text/html; charset=utf-8")
 			res.Header().Set("Content-Length", fmt.Sprintf("%d", len(html)))
 			go h.notifyRequest(time.Since(start), req, http.StatusOK)
@@ -291,6 +601,14 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 		}
 	}
 
+	// Forward everything else to the backend if there is one
+	if h.proxy != nil {
+		proxyRes := &proxyResponseWriter{ResponseWriter: res, status: http.StatusOK}
+		h.proxy.ServeHTTP(proxyRes, req)
+		go h.notifyRequest(time.Since(start), req, proxyRes.status)
+		return
+	}
+
 	// Default to a 404
 	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	go h.notifyRequest(time.Since(start), req, http.StatusNotFound)
@@ -298,6 +616,135 @@ func (h *apiHandler) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	res.Write([]byte("404 - Not Found"))
 }
 
+// This remembers the status code of the response from the backend so that it
+// can be passed to "onRequest"
+type proxyResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *proxyResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Streaming responses such as server-sent events must be flushed as they go
+func (w *proxyResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func newServeProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	// Send the host name of the backend so that virtual hosts work, but tell
+	// the backend what the original host name was
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		originalHost := req.Host
+		director(req)
+		req.Host = target.Host
+		if req.Header.Get("X-Forwarded-Host") == "" {
+			req.Header.Set("X-Forwarded-Host", originalHost)
+		}
+	}
+
+	proxy.ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
+		res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		res.WriteHeader(http.StatusBadGateway)
+		res.Write([]byte(fmt.Sprintf("502 - Bad gateway: %s", err.Error())))
+	}
+	return proxy
+}
+
+func (h *apiHandler) throttleForPath(urlPath string) *ServeThrottle {
+	for i, throttle := range h.throttle {
+		if matchesURLPattern(throttle.Pattern, urlPath) {
+			return &h.throttle[i]
+		}
+	}
+	return nil
+}
+
+// A "*" in the pattern matches any sequence of characters (including "/")
+func matchesURLPattern(pattern string, urlPath string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == urlPath
+	}
+
+	// The first and last parts are anchored to the start and end of the path
+	first, last := parts[0], parts[len(parts)-1]
+	if len(urlPath) < len(first)+len(last) || !strings.HasPrefix(urlPath, first) || !strings.HasSuffix(urlPath, last) {
+		return false
+	}
+	urlPath = urlPath[len(first) : len(urlPath)-len(last)]
+
+	// The parts in the middle must appear in order
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(urlPath, part)
+		if i < 0 {
+			return false
+		}
+		urlPath = urlPath[i+len(part):]
+	}
+	return true
+}
+
+// This delays the start of the response and then sends the body in small
+// pieces at a limited rate. Each piece is flushed right away so that the
+// browser receives the response gradually like it would over a slow network.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	latency        time.Duration
+	bytesPerSecond int
+	hasStarted     bool
+}
+
+func (w *throttledResponseWriter) start() {
+	if !w.hasStarted {
+		w.hasStarted = true
+		time.Sleep(w.latency)
+	}
+}
+
+func (w *throttledResponseWriter) WriteHeader(status int) {
+	w.start()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *throttledResponseWriter) Write(bytes []byte) (int, error) {
+	w.start()
+	if w.bytesPerSecond <= 0 {
+		return w.ResponseWriter.Write(bytes)
+	}
+
+	// Send ten pieces per second
+	pieceSize := w.bytesPerSecond / 10
+	if pieceSize < 1 {
+		pieceSize = 1
+	}
+	flusher, _ := w.ResponseWriter.(http.Flusher)
+	written := 0
+	for written < len(bytes) {
+		end := written + pieceSize
+		if end > len(bytes) {
+			end = len(bytes)
+		}
+		n, err := w.ResponseWriter.Write(bytes[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(w.bytesPerSecond))
+	}
+	return written, nil
+}
+
 // Handle enough of the range specification so that video playback works in Safari
 func parseRangeHeader(r string, contentLength int) (int, int, bool) {
 	if strings.HasPrefix(r, "bytes=") {
@@ -334,6 +781,7 @@ func parseRangeInt(text string, maxValue int) (int, bool) {
 
 func (h *apiHandler) matchQueryPathToResult(
 	queryPath string,
+	options *config.Options,
 	result *BuildResult,
 	dirEntries map[string]bool,
 	fileEntries map[string]bool,
@@ -345,8 +793,11 @@ func (h *apiHandler) matchQueryPathToResult(
 	}
 
 	// Check the output files for a match
+	if options == nil {
+		return 0, nil
+	}
 	for _, file := range result.OutputFiles {
-		if relPath, ok := h.fs.Rel(h.options.AbsOutputDir, file.Path); ok {
+		if relPath, ok := h.fs.Rel(options.AbsOutputDir, file.Path); ok {
 			relPath = strings.ReplaceAll(relPath, "\\", "/")
 
 			// An exact match
@@ -433,33 +884,27 @@ func prettyPrintPath(fs fs.FS, path string) string {
 	return path
 }
 
-func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResult, error) {
-	realFS, err := fs.RealFS(fs.RealFSOptions{
-		AbsWorkingDir: buildOptions.AbsWorkingDir,
-
-		// This is a long-lived file system object so do not cache calls to
-		// ReadDirectory() (they are normally cached for the duration of a build
-		// for performance).
-		DoNotCache: true,
-	})
-	if err != nil {
-		return ServeResult{}, err
-	}
+// This is called both when the server is started and when it's restarted
+func prepareServeBuildOptions(realFS fs.FS, servedir string, buildOptions BuildOptions) (BuildOptions, string, error) {
 	buildOptions.Incremental = true
 	buildOptions.Write = false
 
 	// Watch and serve are both different ways of rebuilding, and cannot be combined
 	if buildOptions.Watch != nil {
-		return ServeResult{}, fmt.Errorf("Cannot use \"watch\" with \"serve\"")
+		return BuildOptions{}, "", fmt.Errorf("Cannot use \"watch\" with \"serve\"")
 	}
 
-	// Validate the fallback path
-	if serveOptions.Servedir != "" {
-		if absPath, ok := realFS.Abs(serveOptions.Servedir); ok {
-			serveOptions.Servedir = absPath
+	// Imports in stdin are resolved relative to the directory being served if
+	// there is one, since that's where the other files for the page are. This
+	// lets a server be started with code that was never written to disk.
+	if buildOptions.Stdin != nil && buildOptions.Stdin.ResolveDir == "" {
+		stdin := *buildOptions.Stdin
+		if servedir != "" {
+			stdin.ResolveDir = servedir
 		} else {
-			return ServeResult{}, fmt.Errorf("Invalid serve path: %s", serveOptions.Servedir)
+			stdin.ResolveDir = realFS.Cwd()
 		}
+		buildOptions.Stdin = &stdin
 	}
 
 	// If there is no output directory, set the output directory to something so
@@ -469,35 +914,35 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 	outdirPathPrefix := ""
 	if buildOptions.Outdir == "" && buildOptions.Outfile == "" {
 		buildOptions.Outdir = realFS.Join(realFS.Cwd(), "...")
-	} else if serveOptions.Servedir != "" {
+	} else if servedir != "" {
 		// Compute the output directory
 		var outdir string
 		if buildOptions.Outdir != "" {
 			if absPath, ok := realFS.Abs(buildOptions.Outdir); ok {
 				outdir = absPath
 			} else {
-				return ServeResult{}, fmt.Errorf("Invalid outdir path: %s", buildOptions.Outdir)
+				return BuildOptions{}, "", fmt.Errorf("Invalid outdir path: %s", buildOptions.Outdir)
 			}
 		} else {
 			if absPath, ok := realFS.Abs(buildOptions.Outfile); ok {
 				outdir = realFS.Dir(absPath)
 			} else {
-				return ServeResult{}, fmt.Errorf("Invalid outdir path: %s", buildOptions.Outfile)
+				return BuildOptions{}, "", fmt.Errorf("Invalid outdir path: %s", buildOptions.Outfile)
 			}
 		}
 
 		// Make sure the output directory is contained in the fallback directory
-		relPath, ok := realFS.Rel(serveOptions.Servedir, outdir)
+		relPath, ok := realFS.Rel(servedir, outdir)
 		if !ok {
-			return ServeResult{}, fmt.Errorf(
-				"Cannot compute relative path from %q to %q\n", serveOptions.Servedir, outdir)
+			return BuildOptions{}, "", fmt.Errorf(
+				"Cannot compute relative path from %q to %q\n", servedir, outdir)
 		}
 		relPath = strings.ReplaceAll(relPath, "\\", "/") // Fix paths on Windows
 		if relPath == ".." || strings.HasPrefix(relPath, "../") {
-			return ServeResult{}, fmt.Errorf(
+			return BuildOptions{}, "", fmt.Errorf(
 				"Output directory %q must be contained in serve directory %q",
 				prettyPrintPath(realFS, outdir),
-				prettyPrintPath(realFS, serveOptions.Servedir),
+				prettyPrintPath(realFS, servedir),
 			)
 		}
 		if relPath != "." {
@@ -505,6 +950,101 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 		}
 	}
 
+	return buildOptions, outdirPathPrefix, nil
+}
+
+// When serving a build context, the main set of build options belongs to the
+// context and builds go through the context so that they share its caches.
+func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions, ctx *buildContext) (ServeResult, error) {
+	realFS, err := fs.RealFS(fs.RealFSOptions{
+		AbsWorkingDir: buildOptions.AbsWorkingDir,
+
+		// This is a long-lived file system object so do not cache calls to
+		// ReadDirectory() (they are normally cached for the duration of a build
+		// for performance).
+		DoNotCache: true,
+	})
+	if err != nil {
+		return ServeResult{}, err
+	}
+
+	// Validate the fallback path
+	if serveOptions.Servedir != "" {
+		if absPath, ok := realFS.Abs(serveOptions.Servedir); ok {
+			serveOptions.Servedir = absPath
+		} else {
+			return ServeResult{}, fmt.Errorf("Invalid serve path: %s", serveOptions.Servedir)
+		}
+	}
+
+	buildOptions, outdirPathPrefix, err := prepareServeBuildOptions(realFS, serveOptions.Servedir, buildOptions)
+	if err != nil {
+		return ServeResult{}, err
+	}
+	if ctx != nil {
+		servedir := serveOptions.Servedir
+		if err := ctx.useServeBuildOptions(func(buildOptions BuildOptions) BuildOptions {
+			// This can't fail since it already succeeded for the same paths
+			buildOptions, _, _ = prepareServeBuildOptions(realFS, servedir, buildOptions)
+			return buildOptions
+		}); err != nil {
+			return ServeResult{}, err
+		}
+	}
+
+	// Validate the throttling rules
+	for _, throttle := range serveOptions.Throttle {
+		if !strings.HasPrefix(throttle.Pattern, "/") && !strings.HasPrefix(throttle.Pattern, "*") {
+			return ServeResult{}, fmt.Errorf("Invalid throttle pattern: %q (expected a URL path such as \"/assets/*\")", throttle.Pattern)
+		}
+		if throttle.LatencyInMS < 0 || throttle.BytesPerSecond < 0 {
+			return ServeResult{}, fmt.Errorf("Invalid throttle for %q (the latency and bytes per second cannot be negative)", throttle.Pattern)
+		}
+	}
+
+	// Validate the backend
+	var proxyTarget *url.URL
+	if serveOptions.Proxy != "" {
+		target, err := url.Parse(serveOptions.Proxy)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return ServeResult{}, fmt.Errorf("Invalid proxy URL: %q (expected an origin such as \"http://localhost:3000\")", serveOptions.Proxy)
+		}
+		proxyTarget = target
+	}
+
+	// Validate the projects. Longer URL path prefixes are matched first so that
+	// a project can be nested inside the URL path prefix of another project.
+	projectPrefixes := make([]string, 0, len(serveOptions.Projects))
+	for prefix := range serveOptions.Projects {
+		if !strings.HasPrefix(prefix, "/") || prefix == "/" || path.Clean(prefix) != prefix || strings.HasPrefix(prefix+"/", "/__esbuild/") {
+			return ServeResult{}, fmt.Errorf("Invalid project path prefix: %q (expected a path such as \"/app\")", prefix)
+		}
+		projectPrefixes = append(projectPrefixes, prefix)
+	}
+	sort.Slice(projectPrefixes, func(i int, j int) bool {
+		a, b := projectPrefixes[i], projectPrefixes[j]
+		return len(a) > len(b) || (len(a) == len(b) && a < b)
+	})
+	projectFS := make([]fs.FS, len(projectPrefixes))
+	projectBuildOptions := make([]BuildOptions, len(projectPrefixes))
+	for i, prefix := range projectPrefixes {
+		projectRealFS, err := fs.RealFS(fs.RealFSOptions{
+			AbsWorkingDir: serveOptions.Projects[prefix].AbsWorkingDir,
+			DoNotCache:    true,
+		})
+		if err != nil {
+			return ServeResult{}, err
+		}
+
+		// Projects don't use the fallback directory
+		options, _, err := prepareServeBuildOptions(projectRealFS, "", serveOptions.Projects[prefix])
+		if err != nil {
+			return ServeResult{}, err
+		}
+		projectFS[i] = projectRealFS
+		projectBuildOptions[i] = options
+	}
+
 	// Determine the host
 	var listener net.Listener
 	network := "tcp4"
@@ -552,13 +1092,12 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 	var stoppingMutex sync.Mutex
 	isStopping := false
 
-	// The first build will just build normally
-	var handler *apiHandler
-	handler = &apiHandler{
-		onRequest:        serveOptions.OnRequest,
-		outdirPathPrefix: outdirPathPrefix,
-		servedir:         serveOptions.Servedir,
-		rebuild: func() BuildResult {
+	// Each set of build options gets its own initial build. Later builds reuse
+	// the incremental state from the previous build. All builds share the same
+	// caches so that files used by more than one project are only parsed once.
+	caches := cache.MakeCacheSet()
+	makeRebuild := func(handler *apiHandler, buildFunc func() internalBuildResult, generation int) func() BuildResult {
+		return func() BuildResult {
 			stoppingMutex.Lock()
 			defer stoppingMutex.Unlock()
 
@@ -567,13 +1106,52 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 				return BuildResult{}
 			}
 
-			build := buildImpl(buildOptions)
-			if handler.options == nil {
+			build := buildFunc()
+			handler.mutex.Lock()
+			if generation == handler.generation {
 				handler.options = &build.options
 			}
+			handler.mutex.Unlock()
 			return build.result
-		},
-		fs: realFS,
+		}
+	}
+
+	handler := &apiHandler{
+		onRequest:        serveOptions.OnRequest,
+		outdirPathPrefix: outdirPathPrefix,
+		servedir:         serveOptions.Servedir,
+		fs:               realFS,
+		throttle:         append([]ServeThrottle{}, serveOptions.Throttle...),
+	}
+	if proxyTarget != nil {
+		handler.proxy = newServeProxy(proxyTarget)
+	}
+	buildWithCaches := func(buildOptions BuildOptions) func() internalBuildResult {
+		return func() internalBuildResult {
+			return buildImplWithCaches(buildOptions, caches)
+		}
+	}
+	if ctx != nil {
+		handler.rebuild = makeRebuild(handler, func() internalBuildResult { return ctx.rebuild(nil) }, 0)
+
+		// Pages in the fallback directory are reloaded after each rebuild if
+		// the context is also watching for changes
+		if serveOptions.Servedir != "" {
+			handler.isWatching = ctx.isWatching
+			handler.liveReloadClients = make(map[chan struct{}]bool)
+			ctx.setLiveReload(handler.broadcastLiveReload)
+		}
+	} else {
+		handler.rebuild = makeRebuild(handler, buildWithCaches(buildOptions), 0)
+	}
+	for i, prefix := range projectPrefixes {
+		project := &apiHandler{
+			onRequest: serveOptions.OnRequest,
+			urlPrefix: prefix,
+			fs:        projectFS[i],
+		}
+		project.rebuild = makeRebuild(project, buildWithCaches(projectBuildOptions[i]), 0)
+		handler.projects = append(handler.projects, project)
 	}
 
 	// When wait is called, block until the server's call to "Serve()" returns
@@ -586,21 +1164,82 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 	server := &http.Server{Addr: addr, Handler: handler}
 
 	// When stop is called, block further rebuilds and then close the server
-	result.Stop = func() {
+	result.Stop = func(graceful bool) {
 		stoppingMutex.Lock()
-		defer stoppingMutex.Unlock()
 
 		// Only try to close the server once
 		if isStopping {
+			stoppingMutex.Unlock()
 			return
 		}
 		isStopping = true
 
-		// Close the server and wait for it to close
-		server.Close()
+		// Unlock before closing the server since requests that are still being
+		// drained may need to finish a build, which also takes this lock
+		stoppingMutex.Unlock()
+
+		// Fail readiness checks from now on
+		handler.mutex.Lock()
+		handler.isStopping = true
+		handler.mutex.Unlock()
+		handler.stopLiveReload()
+		handler.stopTypeCheck()
+		for _, project := range handler.projects {
+			project.mutex.Lock()
+			project.isStopping = true
+			project.mutex.Unlock()
+			project.stopTypeCheck()
+		}
+
+		// Close the server and wait for it to close. A graceful stop stops
+		// accepting new connections and then waits for active ones to finish.
+		// Don't let "Wait()" return until then.
+		if graceful {
+			handler.serveWaitGroup.Add(1)
+			server.Shutdown(context.Background())
+			handler.serveWaitGroup.Done()
+		} else {
+			server.Close()
+		}
 		handler.serveWaitGroup.Wait()
 	}
 
+	// When restart is called, switch to the new build options and rebuild while
+	// continuing to listen on the same address
+	result.Restart = func(newBuildOptions BuildOptions) error {
+		if ctx != nil {
+			return fmt.Errorf("Cannot restart a server for a build context (the build options belong to the context)")
+		}
+		if newBuildOptions.AbsWorkingDir != buildOptions.AbsWorkingDir {
+			return fmt.Errorf("Cannot change \"absWorkingDir\" when restarting the server")
+		}
+		newBuildOptions, outdirPathPrefix, err := prepareServeBuildOptions(realFS, serveOptions.Servedir, newBuildOptions)
+		if err != nil {
+			return err
+		}
+
+		stoppingMutex.Lock()
+		defer stoppingMutex.Unlock()
+		if isStopping {
+			return fmt.Errorf("Cannot restart the server after it has been stopped")
+		}
+
+		// Forget about the previous build so the next request doesn't use it
+		handler.mutex.Lock()
+		handler.generation++
+		handler.rebuild = makeRebuild(handler, buildWithCaches(newBuildOptions), handler.generation)
+		handler.outdirPathPrefix = outdirPathPrefix
+		handler.currentBuild = nil
+		handler.hasBuilt = false
+		handler.lastBuildHadErrors = false
+		handler.mutex.Unlock()
+		handler.stopTypeCheck()
+
+		// Start building with the new options right away
+		go handler.build()
+		return nil
+	}
+
 	// Start the server and signal on "serveWaitGroup" when it stops
 	handler.serveWaitGroup.Add(1)
 	go func() {
@@ -614,6 +1253,9 @@ func serveImpl(serveOptions ServeOptions, buildOptions BuildOptions) (ServeResul
 	// immediately so that stuff we print right after this will come first)
 	go func() {
 		time.Sleep(10 * time.Millisecond)
+		for _, project := range handler.projects {
+			go project.build()
+		}
 		handler.build()
 	}()
 	return result, nil
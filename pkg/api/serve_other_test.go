@@ -0,0 +1,242 @@
+//go:build !js || !wasm
+// +build !js !wasm
+
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func TestInjectLiveReloadScript(t *testing.T) {
+	tests := []struct {
+		html     string
+		expected string
+	}{
+		{
+			html:     "<html><head><title>x</title></head><body></body></html>",
+			expected: "<html><head><title>x</title>" + liveReloadScriptTag + "</head><body></body></html>",
+		},
+		{
+			html:     "<HTML><BODY>x</BODY></HTML>",
+			expected: "<HTML><BODY>x" + liveReloadScriptTag + "</BODY></HTML>",
+		},
+		{
+			html:     "x",
+			expected: "x" + liveReloadScriptTag,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.html, func(t *testing.T) {
+			test.AssertEqual(t, string(injectLiveReloadScript([]byte(tt.html))), tt.expected)
+		})
+	}
+
+	// The script must not be inline so that a content security policy of
+	// "script-src 'self'" doesn't block it
+	test.AssertEqual(t, liveReloadScriptTag, `<script src="/__esbuild/livereload.js"></script>`)
+}
+
+func TestBroadcastLiveReloadKeepsResult(t *testing.T) {
+	rebuilds := 0
+	h := &apiHandler{
+		rebuild: func() BuildResult {
+			rebuilds++
+			return BuildResult{}
+		},
+		liveReloadClients: make(map[chan struct{}]bool),
+	}
+	client := make(chan struct{}, 1)
+	h.liveReloadClients[client] = true
+
+	// A successful rebuild is served as-is and reloads the page
+	fresh := internalBuildResult{result: BuildResult{OutputFiles: []OutputFile{{Path: "/out/fresh.js"}}}}
+	h.broadcastLiveReload(fresh)
+	test.AssertEqual(t, len(client), 1)
+	result := h.build()
+	test.AssertEqual(t, rebuilds, 0)
+	test.AssertEqual(t, result.OutputFiles[0].Path, "/out/fresh.js")
+	<-client
+
+	// A failed rebuild is also served as-is, but doesn't reload the page
+	failed := internalBuildResult{result: BuildResult{Errors: []Message{{Text: "failed"}}}}
+	h.broadcastLiveReload(failed)
+	test.AssertEqual(t, len(client), 0)
+	result = h.build()
+	test.AssertEqual(t, rebuilds, 0)
+	test.AssertEqual(t, result.Errors[0].Text, "failed")
+	test.AssertEqual(t, h.lastBuildHadErrors, true)
+}
+
+func TestServeLiveReload(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(path string, contents string) {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("www/index.html", `<html><head><meta http-equiv="Content-Security-Policy" content="script-src 'self'"></head></html>`)
+	writeFile("src/in.js", `console.log("first")`)
+
+	var builds int32
+	ctx, ctxErr := Context(BuildOptions{
+		EntryPoints:   []string{"src/in.js"},
+		Bundle:        true,
+		Outdir:        "www/js",
+		AbsWorkingDir: dir,
+		LogLevel:      LogLevelSilent,
+		Plugins: []Plugin{{
+			Name: "count",
+			Setup: func(build PluginBuild) {
+				build.OnStart(func() (OnStartResult, error) {
+					atomic.AddInt32(&builds, 1)
+					return OnStartResult{}, nil
+				})
+			},
+		}},
+	})
+	if ctxErr != nil {
+		t.Fatal(ctxErr)
+	}
+	defer ctx.Dispose()
+	if err := ctx.Watch(WatchMode{}); err != nil {
+		t.Fatal(err)
+	}
+	server, err := ctx.Serve(ServeOptions{Host: "127.0.0.1", Servedir: filepath.Join(dir, "www")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := fmt.Sprintf("http://127.0.0.1:%d", server.Port)
+
+	get := func(path string) (string, string) {
+		t.Helper()
+		res, err := http.Get(origin + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.AssertEqual(t, res.StatusCode, http.StatusOK)
+		return string(body), res.Header.Get("Content-Type")
+	}
+
+	// The page loads the live reload script from the server
+	html, _ := get("/index.html")
+	test.AssertEqual(t, strings.Contains(html, `<script src="/__esbuild/livereload.js"></script></head>`), true)
+	js, contentType := get("/__esbuild/livereload.js")
+	test.AssertEqual(t, js, liveReloadScript)
+	test.AssertEqual(t, contentType, "text/javascript; charset=utf-8")
+
+	// Listen for reload events like the script does
+	res, err := http.Get(origin + "/__esbuild/reload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	events := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "event: ") {
+				events <- line
+			}
+		}
+	}()
+
+	// The reloaded page is served from the rebuild that triggered the reload
+	out, _ := get("/js/in.js")
+	test.AssertEqual(t, strings.Contains(out, `console.log("first")`), true)
+	writeFile("src/in.js", `console.log("second")`)
+	select {
+	case event := <-events:
+		test.AssertEqual(t, event, "event: reload")
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for the page to be reloaded")
+	}
+	buildsBeforeReload := atomic.LoadInt32(&builds)
+	out, _ = get("/js/in.js")
+	test.AssertEqual(t, strings.Contains(out, `console.log("second")`), true)
+	test.AssertEqual(t, atomic.LoadInt32(&builds), buildsBeforeReload)
+}
+
+func TestServeRestart(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range map[string]string{"a.js": `console.log("a")`, "b.js": `console.log("b")`} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buildOptions := BuildOptions{EntryPoints: []string{"a.js"}, AbsWorkingDir: dir, LogLevel: LogLevelSilent}
+	server, err := Serve(ServeOptions{Host: "127.0.0.1"}, buildOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := fmt.Sprintf("http://127.0.0.1:%d", server.Port)
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := http.Get(origin + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(body)
+	}
+
+	status, body := get("/a.js")
+	test.AssertEqual(t, status, http.StatusOK)
+	test.AssertEqual(t, body, "console.log(\"a\");\n")
+
+	// Restarting keeps listening on the same port but uses the new options
+	buildOptions.EntryPoints = []string{"b.js"}
+	if err := server.Restart(buildOptions); err != nil {
+		t.Fatal(err)
+	}
+	status, body = get("/b.js")
+	test.AssertEqual(t, status, http.StatusOK)
+	test.AssertEqual(t, body, "console.log(\"b\");\n")
+	status, _ = get("/a.js")
+	test.AssertEqual(t, status, http.StatusNotFound)
+	status, body = get("/__esbuild/readyz")
+	test.AssertEqual(t, status, http.StatusOK)
+	test.AssertEqual(t, body, "ready")
+
+	// The working directory can't change
+	buildOptions.AbsWorkingDir = filepath.Join(dir, "other")
+	test.AssertEqual(t, server.Restart(buildOptions).Error(), "Cannot change \"absWorkingDir\" when restarting the server")
+
+	// A stopped server can't be restarted
+	server.Stop(true)
+	test.AssertEqual(t, server.Wait(), nil)
+	buildOptions.AbsWorkingDir = dir
+	test.AssertEqual(t, server.Restart(buildOptions).Error(), "Cannot restart the server after it has been stopped")
+}
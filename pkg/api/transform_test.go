@@ -0,0 +1,54 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/js_parser"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+// Returns the 0-based line and column of the first occurrence of "text"
+func lineAndColumnOf(t *testing.T, contents string, text string) (int32, int32) {
+	t.Helper()
+	index := strings.Index(contents, text)
+	if index == -1 {
+		t.Fatalf("Missing %q in %q", text, contents)
+	}
+	line := strings.Count(contents[:index], "\n")
+	column := index - (strings.LastIndexByte(contents[:index], '\n') + 1)
+	return int32(line), int32(column)
+}
+
+func TestPreserveCommentsSourceMap(t *testing.T) {
+	input := "/* deploy:\n   v1 */\nlet x = 1 // drop me\n\n// deploy: v2\nlet y = 2\n/* deploy: v3 */ let z = 3\n"
+	result := Transform(input, TransformOptions{
+		MinifyWhitespace: true,
+		Sourcemap:        SourceMapExternal,
+		PreserveComments: "^ deploy:",
+	})
+	test.AssertEqual(t, len(result.Errors), 0)
+	code := string(result.Code)
+	test.AssertEqual(t, code, "/* deploy:\n   v1 */let x=1;// deploy: v2\nlet y=2;/* deploy: v3 */let z=3;\n")
+
+	// The kept comments shift the statements after them, including onto
+	// different lines, and the source map must account for that
+	log := logger.NewDeferLog(logger.DeferLogNoVerboseOrDebug)
+	sourceMap := js_parser.ParseSourceMap(log, logger.Source{Contents: string(result.Map)})
+	if sourceMap == nil {
+		t.Fatalf("Invalid source map: %s", result.Map)
+	}
+	for _, text := range []string{"let x", "let y", "let z"} {
+		t.Run(text, func(t *testing.T) {
+			line, column := lineAndColumnOf(t, code, text)
+			mapping := sourceMap.Find(line, column)
+			if mapping == nil {
+				t.Fatalf("Missing mapping for %q", text)
+			}
+			originalLine, originalColumn := lineAndColumnOf(t, input, text)
+			test.AssertEqual(t, mapping.OriginalLine, originalLine)
+			test.AssertEqual(t, mapping.OriginalColumn, originalColumn)
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// The type check command runs as a separate process in parallel with the
+// build. For a single build, its diagnostics are added to the build's log
+// after the output files have been written so that errors still fail the
+// build. When rebuilding (watch mode, serve mode, incremental builds, and
+// build contexts), the command is only started once and should keep running
+// by itself (e.g. "tsc --noEmit --watch"). Its diagnostics are then printed
+// as soon as each of its runs finishes and builds never wait for it.
+type typeChecker struct {
+	cmd    *exec.Cmd
+	output io.ReadCloser
+	done   chan struct{}
+	msgs   []logger.Msg
+
+	mutex     sync.Mutex
+	isStopped bool
+}
+
+// The TypeScript compiler ends each run with a summary line such as "Found 2
+// errors." In watch mode, this is prefixed with the time.
+var typeCheckSummaryRegexp = regexp.MustCompile(`(^|\] |- )Found \d+ errors?\b`)
+
+// If "onReport" is nil, all diagnostics are collected for "wait()" instead
+func startTypeChecker(realFS fs.FS, command string, onReport func([]logger.Msg)) *typeChecker {
+	check := &typeChecker{done: make(chan struct{})}
+	if onReport == nil {
+		onReport = func(msgs []logger.Msg) {
+			check.msgs = append(check.msgs, msgs...)
+		}
+	}
+
+	// Run the command in a shell so that it can use arguments and "PATH"
+	if fs.CheckIfWindows() {
+		check.cmd = exec.Command("cmd", "/C", command)
+	} else {
+		check.cmd = exec.Command("sh", "-c", command)
+	}
+	check.cmd.Dir = realFS.Cwd()
+	output, err := check.cmd.StdoutPipe()
+	if err == nil {
+		check.cmd.Stderr = check.cmd.Stdout
+		err = check.cmd.Start()
+	}
+	if err != nil {
+		onReport([]logger.Msg{{Kind: logger.Error, Data: logger.MsgData{
+			Text: fmt.Sprintf("Failed to run the type check command %q: %s", command, err.Error())}}})
+		close(check.done)
+		return check
+	}
+	check.output = output
+
+	go func() {
+		defer close(check.done)
+		var lines []string
+		lastRunHadErrors := false
+
+		// Report the diagnostics for each run as soon as it's done
+		scanner := bufio.NewScanner(output)
+		scanner.Buffer(nil, 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			lines = append(lines, line)
+			if typeCheckSummaryRegexp.MatchString(typeCheckColorRegexp.ReplaceAllString(line, "")) {
+				msgs := parseTypeCheckOutput(realFS, strings.Join(lines, "\n"))
+				lines = nil
+				lastRunHadErrors = typeCheckHasErrors(msgs)
+				if !check.wasStopped() {
+					onReport(msgs)
+				}
+			}
+		}
+		err := check.cmd.Wait()
+		if check.wasStopped() {
+			return
+		}
+
+		// Make sure a failed check fails the build even if none of its output
+		// could be understood
+		text := strings.Join(lines, "\n")
+		msgs := parseTypeCheckOutput(realFS, text)
+		if err != nil && !lastRunHadErrors && !typeCheckHasErrors(msgs) {
+			msg := logger.Msg{Kind: logger.Error}
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				msg.Data.Text = fmt.Sprintf("The type check command %q failed with exit code %d", command, exitErr.ExitCode())
+			} else {
+				msg.Data.Text = fmt.Sprintf("Failed to run the type check command %q: %s", command, err.Error())
+			}
+			if text := strings.TrimSpace(text); text != "" {
+				msg.Notes = []logger.MsgData{{Text: text}}
+			}
+			msgs = append(msgs, msg)
+		}
+		if len(msgs) > 0 {
+			onReport(msgs)
+		}
+	}()
+
+	return check
+}
+
+// This waits for the command to exit and returns all of its diagnostics
+func (check *typeChecker) wait() []logger.Msg {
+	<-check.done
+	return check.msgs
+}
+
+// This kills the command if it's still running. Closing the output makes sure
+// this doesn't hang if the shell's child process is still holding on to it.
+func (check *typeChecker) stop() {
+	check.mutex.Lock()
+	if check.isStopped || check.output == nil {
+		check.mutex.Unlock()
+		return
+	}
+	check.isStopped = true
+	check.mutex.Unlock()
+
+	check.cmd.Process.Kill()
+	check.output.Close()
+	<-check.done
+}
+
+func (check *typeChecker) wasStopped() bool {
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	return check.isStopped
+}
+
+func typeCheckHasErrors(msgs []logger.Msg) bool {
+	for _, msg := range msgs {
+		if msg.Kind == logger.Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostics from a long-running type check command are printed separately
+// from the build's own messages since builds don't wait for them
+func printTypeCheckDiagnostics(logOptions logger.OutputOptions) func([]logger.Msg) {
+	return func(msgs []logger.Msg) {
+		log := logger.NewStderrLog(logOptions)
+		for _, msg := range msgs {
+			log.AddMsg(msg)
+		}
+		log.Done()
+	}
+}
+
+// This understands both of the formats that the TypeScript compiler uses:
+//
+//	src/app.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'.
+//	src/app.ts:3:7 - error TS2322: Type 'string' is not assignable to type 'number'.
+//
+// Indented lines after a diagnostic elaborate on it and become notes. Other
+// lines (such as the summary at the end) are ignored.
+var typeCheckDiagnosticRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`^(.+)\((\d+),(\d+)\): (error|warning) (TS\d+): (.*)$`),
+	regexp.MustCompile(`^(.+):(\d+):(\d+) - (error|warning) (TS\d+): (.*)$`),
+}
+
+var typeCheckGlobalRegexp = regexp.MustCompile(`^(error|warning) (TS\d+): (.*)$`)
+
+// Strips the color escape sequences that "tsc --pretty" uses
+var typeCheckColorRegexp = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+func parseTypeCheckOutput(realFS fs.FS, output string) []logger.Msg {
+	var msgs []logger.Msg
+	var current *logger.Msg
+	lineTexts := make(map[string][]string)
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(typeCheckColorRegexp.ReplaceAllString(line, ""), "\r")
+
+		if parts := matchTypeCheckDiagnostic(line); parts != nil {
+			path, kind, code, text := parts[1], parts[4], parts[5], parts[6]
+			lineNumber, _ := strconv.Atoi(parts[2])
+			column, _ := strconv.Atoi(parts[3])
+			msgs = append(msgs, logger.Msg{
+				Kind: typeCheckMsgKind(kind),
+				Data: logger.MsgData{
+					Text:     fmt.Sprintf("%s [%s]", text, code),
+					Location: typeCheckLocation(realFS, lineTexts, path, lineNumber, column),
+				},
+			})
+			current = &msgs[len(msgs)-1]
+			continue
+		}
+
+		if parts := typeCheckGlobalRegexp.FindStringSubmatch(line); parts != nil {
+			msgs = append(msgs, logger.Msg{
+				Kind: typeCheckMsgKind(parts[1]),
+				Data: logger.MsgData{Text: fmt.Sprintf("%s [%s]", parts[3], parts[2])},
+			})
+			current = &msgs[len(msgs)-1]
+			continue
+		}
+
+		if current != nil && strings.HasPrefix(line, "  ") && strings.TrimSpace(line) != "" {
+			current.Notes = append(current.Notes, logger.MsgData{Text: strings.TrimSpace(line)})
+			continue
+		}
+
+		// Pretty output prints the source code after each diagnostic, which
+		// is already shown by the location, so only keep indented notes
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") {
+			current = nil
+		}
+	}
+
+	return msgs
+}
+
+func matchTypeCheckDiagnostic(line string) []string {
+	for _, re := range typeCheckDiagnosticRegexps {
+		if parts := re.FindStringSubmatch(line); parts != nil {
+			return parts
+		}
+	}
+	return nil
+}
+
+func typeCheckMsgKind(kind string) logger.MsgKind {
+	if kind == "warning" {
+		return logger.Warning
+	}
+	return logger.Error
+}
+
+func typeCheckLocation(realFS fs.FS, lineTexts map[string][]string, path string, line int, column int) *logger.MsgLocation {
+	absPath := path
+	if !realFS.IsAbs(absPath) {
+		absPath = realFS.Join(realFS.Cwd(), path)
+	}
+	prettyPath := path
+	if relPath, ok := realFS.Rel(realFS.Cwd(), absPath); ok {
+		prettyPath = strings.ReplaceAll(relPath, "\\", "/")
+	}
+
+	// Include the line of source code if the file can be read
+	lines, ok := lineTexts[absPath]
+	if !ok {
+		if contents, err, _ := realFS.ReadFile(absPath); err == nil {
+			lines = strings.Split(contents, "\n")
+		}
+		lineTexts[absPath] = lines
+	}
+	var lineText string
+	if line >= 1 && line <= len(lines) {
+		lineText = strings.TrimRight(lines[line-1], "\r")
+	}
+
+	// The compiler's columns are 1-based
+	if column > 0 {
+		column--
+	}
+	if column > len(lineText) {
+		column = len(lineText)
+	}
+
+	return &logger.MsgLocation{
+		File:     prettyPath,
+		Line:     line,
+		Column:   column,
+		LineText: lineText,
+	}
+}
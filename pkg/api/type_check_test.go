@@ -0,0 +1,213 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/internal/fs"
+	"github.com/evanw/esbuild/internal/logger"
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func formatTypeCheckMsgs(msgs []logger.Msg) string {
+	sb := strings.Builder{}
+	for _, msg := range msgs {
+		kind := "error"
+		if msg.Kind == logger.Warning {
+			kind = "warning"
+		}
+		sb.WriteString(kind)
+		if loc := msg.Data.Location; loc != nil {
+			sb.WriteString(fmt.Sprintf(" %s:%d:%d %q", loc.File, loc.Line, loc.Column, loc.LineText))
+		}
+		sb.WriteString(": " + msg.Data.Text + "\n")
+		for _, note := range msg.Notes {
+			sb.WriteString("  note: " + note.Text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func TestParseTypeCheckOutput(t *testing.T) {
+	realFS := fs.MockFS(map[string]string{
+		"/src/app.ts": "let x: number = 1\nlet y: number = 'a'\n",
+	})
+
+	tests := []struct {
+		name     string
+		output   string
+		expected string
+	}{
+		{
+			name:     "Empty",
+			output:   "",
+			expected: "",
+		},
+		{
+			name:   "Plain",
+			output: "src/app.ts(2,5): error TS2322: Type 'string' is not assignable to type 'number'.\n",
+			expected: `error src/app.ts:2:4 "let y: number = 'a'": Type 'string' is not assignable to type 'number'. [TS2322]
+`,
+		},
+		{
+			name:   "PlainWithCRLF",
+			output: "src/app.ts(2,5): error TS2322: Type 'string' is not assignable to type 'number'.\r\n",
+			expected: `error src/app.ts:2:4 "let y: number = 'a'": Type 'string' is not assignable to type 'number'. [TS2322]
+`,
+		},
+		{
+			name:   "PlainAbsolutePath",
+			output: "/src/app.ts(1,5): warning TS6133: 'x' is declared but its value is never read.\n",
+			expected: `warning src/app.ts:1:4 "let x: number = 1": 'x' is declared but its value is never read. [TS6133]
+`,
+		},
+		{
+			name: "PlainWithNotes",
+			output: "src/app.ts(2,5): error TS2322: Type '{ a: string; }' is not assignable to type 'Foo'.\n" +
+				"  Types of property 'a' are incompatible.\n" +
+				"    Type 'string' is not assignable to type 'number'.\n",
+			expected: `error src/app.ts:2:4 "let y: number = 'a'": Type '{ a: string; }' is not assignable to type 'Foo'. [TS2322]
+  note: Types of property 'a' are incompatible.
+  note: Type 'string' is not assignable to type 'number'.
+`,
+		},
+		{
+			name:   "MissingFile",
+			output: "src/missing.ts(10,3): error TS2304: Cannot find name 'foo'.\n",
+			expected: `error src/missing.ts:10:0 "": Cannot find name 'foo'. [TS2304]
+`,
+		},
+		{
+			name: "Pretty",
+			output: "\x1b[96msrc/app.ts\x1b[0m:\x1b[93m2\x1b[0m:\x1b[93m5\x1b[0m - \x1b[91merror\x1b[0m\x1b[90m TS2322: \x1b[0mType 'string' is not assignable to type 'number'.\n" +
+				"\n" +
+				"\x1b[7m2\x1b[0m let y: number = 'a'\n" +
+				"\x1b[7m \x1b[0m \x1b[91m    ~\x1b[0m\n" +
+				"\n" +
+				"\n" +
+				"Found 1 error in src/app.ts\x1b[90m:2\x1b[0m\n",
+			expected: `error src/app.ts:2:4 "let y: number = 'a'": Type 'string' is not assignable to type 'number'. [TS2322]
+`,
+		},
+		{
+			name: "PrettyWithoutColors",
+			output: "src/app.ts:1:5 - warning TS6133: 'x' is declared but its value is never read.\n" +
+				"\n" +
+				"1 let x: number = 1\n" +
+				"      ~\n" +
+				"src/app.ts:2:5 - error TS2322: Type 'string' is not assignable to type 'number'.\n" +
+				"\n" +
+				"2 let y: number = 'a'\n" +
+				"      ~\n" +
+				"\n" +
+				"Found 2 errors in the same file, starting at: src/app.ts:1\n",
+			expected: `warning src/app.ts:1:4 "let x: number = 1": 'x' is declared but its value is never read. [TS6133]
+error src/app.ts:2:4 "let y: number = 'a'": Type 'string' is not assignable to type 'number'. [TS2322]
+`,
+		},
+		{
+			name: "Global",
+			output: "error TS5083: Cannot read file 'tsconfig.json'.\n" +
+				"\n" +
+				"Found 1 error.\n",
+			expected: `error: Cannot read file 'tsconfig.json'. [TS5083]
+`,
+		},
+		{
+			name: "WatchMode",
+			output: "12:00:00 AM - Starting compilation in watch mode...\n" +
+				"\n" +
+				"src/app.ts(2,5): error TS2322: Type 'string' is not assignable to type 'number'.\n" +
+				"\n" +
+				"12:00:01 AM - Found 1 error. Watching for file changes.\n",
+			expected: `error src/app.ts:2:4 "let y: number = 'a'": Type 'string' is not assignable to type 'number'. [TS2322]
+`,
+		},
+		{
+			name:     "UnrelatedOutput",
+			output:   "npm WARN config production Use `--omit=dev` instead.\nDone\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			test.AssertEqualWithDiff(t, formatTypeCheckMsgs(parseTypeCheckOutput(realFS, tt.output)), tt.expected)
+		})
+	}
+}
+
+func TestTypeCheckSummary(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected bool
+	}{
+		{"Found 1 error.", true},
+		{"Found 2 errors in the same file, starting at: src/app.ts:1", true},
+		{"Found 1 error in src/app.ts:2", true},
+		{"12:00:01 AM - Found 0 errors. Watching for file changes.", true},
+		{"[12:00:01 AM] Found 3 errors. Watching for file changes.", true},
+		{"12:00:00 AM - Starting compilation in watch mode...", false},
+		{"src/app.ts(2,5): error TS2322: Found 1 error in the type.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			test.AssertEqual(t, typeCheckSummaryRegexp.MatchString(tt.line), tt.expected)
+		})
+	}
+}
+
+func TestTypeCheckerReportsEachRun(t *testing.T) {
+	if fs.CheckIfWindows() {
+		t.Skip("This test uses a POSIX shell")
+	}
+	realFS, err := fs.RealFS(fs.RealFSOptions{AbsWorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The command keeps running after each run like "tsc --watch" does
+	reports := make(chan string, 10)
+	check := startTypeChecker(realFS,
+		"echo 'error TS1: first'; echo 'Found 1 error.'; echo 'Found 0 errors.'; sleep 60",
+		func(msgs []logger.Msg) { reports <- formatTypeCheckMsgs(msgs) })
+	test.AssertEqual(t, <-reports, "error: first [TS1]\n")
+	test.AssertEqual(t, <-reports, "")
+
+	// Stopping must not wait for the command to exit by itself
+	stopped := make(chan struct{})
+	go func() {
+		check.stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stopping the type checker timed out")
+	}
+	if len(reports) != 0 {
+		t.Fatalf("Unexpected report after stopping: %q", <-reports)
+	}
+}
+
+func TestTypeCheckerFailure(t *testing.T) {
+	if fs.CheckIfWindows() {
+		t.Skip("This test uses a POSIX shell")
+	}
+	realFS, err := fs.RealFS(fs.RealFSOptions{AbsWorkingDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Output that isn't understood is included when the command fails
+	check := startTypeChecker(realFS, "echo 'something went wrong'; exit 3", nil)
+	test.AssertEqual(t, formatTypeCheckMsgs(check.wait()),
+		"error: The type check command \"echo 'something went wrong'; exit 3\" failed with exit code 3\n  note: something went wrong\n")
+
+	// A failure with errors in the output doesn't add another error
+	check = startTypeChecker(realFS, "echo 'error TS1: first'; echo 'Found 1 error.'; exit 2", nil)
+	test.AssertEqual(t, formatTypeCheckMsgs(check.wait()), "error: first [TS1]\n")
+}
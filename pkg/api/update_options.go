@@ -0,0 +1,93 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/evanw/esbuild/internal/bundler"
+	"github.com/evanw/esbuild/internal/cache"
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+// This changes the options of a build that's going to be run again. The
+// caches are changed to match, so this must not be called while a build is
+// in progress. Parsed files are only thrown away if they mention one of the
+// defines that changed (or "console" or "debugger" if the statements that are
+// dropped changed). Any change other than the log level means all entry
+// points are linked again, since the link cache doesn't know about options.
+func applyUpdateOptions(
+	buildOpts *BuildOptions,
+	logOptions *logger.OutputOptions,
+	caches *cache.CacheSet,
+	linkCache *bundler.LinkCache,
+	update UpdateOptions,
+) {
+	var changedNames []string
+	affectsOutput := false
+
+	if update.LogLevel != nil {
+		buildOpts.LogLevel = *update.LogLevel
+		logOptions.LogLevel = validateLogLevel(*update.LogLevel)
+	}
+
+	if update.Define != nil {
+		for key, value := range update.Define {
+			if oldValue, ok := buildOpts.Define[key]; !ok || oldValue != value {
+				changedNames = append(changedNames, lastPartOfDefine(key))
+			}
+		}
+		for key := range buildOpts.Define {
+			if _, ok := update.Define[key]; !ok {
+				changedNames = append(changedNames, lastPartOfDefine(key))
+			}
+		}
+		buildOpts.Define = copyStringMap(update.Define)
+	}
+
+	if update.Drop != nil {
+		changed := buildOpts.Drop ^ *update.Drop
+		if (changed & DropConsole) != 0 {
+			changedNames = append(changedNames, "console")
+		}
+		if (changed & DropDebugger) != 0 {
+			changedNames = append(changedNames, "debugger")
+		}
+		buildOpts.Drop = *update.Drop
+	}
+
+	if update.Banner != nil {
+		if len(update.Banner) != len(buildOpts.Banner) {
+			affectsOutput = true
+		}
+		for key, value := range update.Banner {
+			if oldValue, ok := buildOpts.Banner[key]; !ok || oldValue != value {
+				affectsOutput = true
+			}
+		}
+		buildOpts.Banner = copyStringMap(update.Banner)
+	}
+
+	if len(changedNames) > 0 {
+		caches.JSCache.InvalidateFilesMentioning(changedNames)
+		affectsOutput = true
+	}
+	if affectsOutput && linkCache != nil {
+		linkCache.Clear()
+	}
+}
+
+// Code can only match the define "process.env.NODE_ENV" if it mentions
+// "NODE_ENV", so this is the name to look for in each file
+func lastPartOfDefine(key string) string {
+	if dot := strings.LastIndexByte(key, '.'); dot != -1 {
+		return key[dot+1:]
+	}
+	return key
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	clone := make(map[string]string, len(m))
+	for key, value := range m {
+		clone[key] = value
+	}
+	return clone
+}
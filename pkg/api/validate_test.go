@@ -0,0 +1,88 @@
+package api
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/internal/test"
+)
+
+func formatValidateMsgs(result ValidateBuildOptionsResult) string {
+	sb := strings.Builder{}
+	for _, msg := range result.Errors {
+		sb.WriteString("error: " + msg.Text + "\n")
+	}
+	for _, msg := range result.Warnings {
+		sb.WriteString("warning: " + msg.Text + "\n")
+	}
+	return sb.String()
+}
+
+func TestValidateBuildOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		options  BuildOptions
+		expected string
+	}{
+		{
+			name:     "Valid",
+			options:  BuildOptions{EntryPoints: []string{"in.js"}, Bundle: true, Outdir: "out", Loader: map[string]Loader{".png": LoaderFile}},
+			expected: "",
+		},
+		{
+			name:     "OutfileAndOutdir",
+			options:  BuildOptions{Outfile: "out.js", Outdir: "out"},
+			expected: "error: Cannot use both \"outfile\" and \"outdir\"\n",
+		},
+		{
+			name:     "MultipleEntryPointsWithOutfile",
+			options:  BuildOptions{EntryPoints: []string{"a.js", "b.js"}, Outfile: "out.js"},
+			expected: "error: Must use \"outdir\" when there are multiple input files\n",
+		},
+		{
+			name:     "SplittingWithIIFE",
+			options:  BuildOptions{Splitting: true, Format: FormatIIFE, Platform: PlatformNode, Outdir: "out"},
+			expected: "error: Splitting with the \"iife\" format only works with the \"browser\" platform\n",
+		},
+		{
+			name:    "InvalidLoaderExtension",
+			options: BuildOptions{Loader: map[string]Loader{"png": LoaderFile}},
+			expected: "error: Invalid file extension: \"png\"\n" +
+				"error: Cannot use the \"file\" loader without an output path\n",
+		},
+		{
+			name:     "InvalidDefine",
+			options:  BuildOptions{Define: map[string]string{"DEBUG": "1+"}},
+			expected: "error: Invalid define value (must be valid JSON syntax or a single identifier): 1+\n",
+		},
+		{
+			name:     "RelativeWorkingDirectory",
+			options:  BuildOptions{AbsWorkingDir: "relative"},
+			expected: "error: The working directory \"relative\" is not an absolute path\n",
+		},
+		{
+			name:     "Warning",
+			options:  BuildOptions{InferTarget: true, Platform: PlatformNode},
+			expected: "warning: Cannot infer the target because there is no \"package.json\" file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.options.AbsWorkingDir == "" {
+				tt.options.AbsWorkingDir = dir
+			}
+			test.AssertEqualWithDiff(t, formatValidateMsgs(ValidateBuildOptions(tt.options)), tt.expected)
+		})
+	}
+
+	// Validation must not write anything, not even the output directory
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertEqual(t, len(entries), 0)
+}
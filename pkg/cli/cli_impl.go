@@ -6,9 +6,11 @@ import (
 	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/evanw/esbuild/internal/cli_helpers"
 	"github.com/evanw/esbuild/internal/fs"
@@ -18,16 +20,25 @@ import (
 
 func newBuildOptions() api.BuildOptions {
 	return api.BuildOptions{
-		Loader: make(map[string]api.Loader),
-		Define: make(map[string]string),
-		Banner: make(map[string]string),
-		Footer: make(map[string]string),
+		Loader:       make(map[string]api.Loader),
+		Define:       make(map[string]string),
+		FeatureFlags: make(map[string]bool),
+		Supported:    make(map[string]bool),
+		Banner:       make(map[string]string),
+		Footer:       make(map[string]string),
+
+		ExternalRewrite: make(map[string]string),
+		Globals:         make(map[string]string),
+		Workspaces:      make(map[string]string),
+		Alias:           make(map[string]string),
 	}
 }
 
 func newTransformOptions() api.TransformOptions {
 	return api.TransformOptions{
-		Define: make(map[string]string),
+		Define:       make(map[string]string),
+		FeatureFlags: make(map[string]bool),
+		Supported:    make(map[string]bool),
 	}
 }
 
@@ -55,12 +66,48 @@ func parseOptionsImpl(
 		case arg == "--preserve-symlinks" && buildOpts != nil:
 			buildOpts.PreserveSymlinks = true
 
+		case arg == "--detect-workspaces" && buildOpts != nil:
+			buildOpts.DetectWorkspaces = true
+
+		case arg == "--strict-case" && buildOpts != nil:
+			buildOpts.StrictCase = true
+
+		case arg == "--bundle-dynamic-paths" && buildOpts != nil:
+			buildOpts.BundleDynamicPaths = true
+
+		case arg == "--node-polyfills" && buildOpts != nil:
+			buildOpts.NodePolyfills = true
+
+		case arg == "--rewrite-imports" && buildOpts != nil:
+			buildOpts.RewriteImports = true
+
+		case arg == "--preserve-modules" && buildOpts != nil:
+			buildOpts.PreserveModules = true
+
+		case arg == "--infer-target" && buildOpts != nil:
+			buildOpts.InferTarget = true
+
+		case arg == "--inject-css-link" && buildOpts != nil:
+			buildOpts.InjectCSSLink = true
+
+		case arg == "--tree-shake-members" && buildOpts != nil:
+			buildOpts.TreeShakeMembers = true
+
 		case arg == "--splitting" && buildOpts != nil:
 			buildOpts.Splitting = true
 
+		case arg == "--dual-package" && buildOpts != nil:
+			buildOpts.DualPackage = true
+
 		case arg == "--allow-overwrite" && buildOpts != nil:
 			buildOpts.AllowOverwrite = true
 
+		case arg == "--skip-unchanged" && buildOpts != nil:
+			buildOpts.SkipUnchanged = true
+
+		case arg == "--access-list" && buildOpts != nil:
+			buildOpts.AccessList = true
+
 		case arg == "--watch" && buildOpts != nil:
 			buildOpts.Watch = &api.WatchMode{}
 
@@ -96,6 +143,14 @@ func parseOptionsImpl(
 				transformOpts.MinifyIdentifiers = true
 			}
 
+		case strings.HasPrefix(arg, "--minify-seed="):
+			value := arg[len("--minify-seed="):]
+			if buildOpts != nil {
+				buildOpts.MinifySeed = value
+			} else {
+				transformOpts.MinifySeed = value
+			}
+
 		case strings.HasPrefix(arg, "--legal-comments="):
 			value := arg[len("--legal-comments="):]
 			var legalComments api.LegalComments
@@ -113,7 +168,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"none\", \"inline\", \"eof\", \"linked\", or \"external\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["legal-comments"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -122,6 +177,151 @@ func parseOptionsImpl(
 				transformOpts.LegalComments = legalComments
 			}
 
+		case strings.HasPrefix(arg, "--annotations="):
+			annotations, err := parseAnnotations(arg[len("--annotations="):], arg)
+			if err != nil {
+				return err, nil
+			}
+			if buildOpts != nil {
+				buildOpts.Annotations = annotations
+			} else {
+				transformOpts.Annotations = annotations
+			}
+
+		case strings.HasPrefix(arg, "--annotations:") && buildOpts != nil:
+			value := arg[len("--annotations:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to specify the output format that the setting applies to. "+
+						"For example, \"--annotations:esm=never\" omits annotations from \"esm\" output.",
+				), nil
+			}
+			var format api.Format
+			switch value[:equals] {
+			case "iife":
+				format = api.FormatIIFE
+			case "cjs":
+				format = api.FormatCommonJS
+			case "esm":
+				format = api.FormatESModule
+			case "umd":
+				format = api.FormatUMD
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid format %q in %q", value[:equals], arg),
+					"Valid formats are \"iife\", \"cjs\", \"esm\", or \"umd\".",
+				), nil
+			}
+			annotations, err := parseAnnotations(value[equals+1:], arg)
+			if err != nil {
+				return err, nil
+			}
+			if buildOpts.FormatAnnotations == nil {
+				buildOpts.FormatAnnotations = make(map[api.Format]api.Annotations)
+			}
+			buildOpts.FormatAnnotations[format] = annotations
+
+		case strings.HasPrefix(arg, "--magic-comments="):
+			magicComments, err := parseAnnotations(arg[len("--magic-comments="):], arg)
+			if err != nil {
+				return err, nil
+			}
+			if buildOpts != nil {
+				buildOpts.MagicComments = magicComments
+			} else {
+				transformOpts.MagicComments = magicComments
+			}
+
+		case strings.HasPrefix(arg, "--on-conflict=") && buildOpts != nil:
+			value := arg[len("--on-conflict="):]
+			switch value {
+			case "error":
+				buildOpts.OnConflict = api.OnConflictError
+			case "rename":
+				buildOpts.OnConflict = api.OnConflictRename
+			case "overwrite":
+				buildOpts.OnConflict = api.OnConflictOverwrite
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["on-conflict"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--line-ending="):
+			value := arg[len("--line-ending="):]
+			var lineEnding api.LineEnding
+			switch value {
+			case "lf":
+				lineEnding = api.LineEndingLF
+			case "crlf":
+				lineEnding = api.LineEndingCRLF
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["line-ending"]),
+				), nil
+			}
+			if buildOpts != nil {
+				buildOpts.LineEnding = lineEnding
+			} else {
+				transformOpts.LineEnding = lineEnding
+			}
+
+		case strings.HasPrefix(arg, "--indent="):
+			value := arg[len("--indent="):]
+			if buildOpts != nil {
+				buildOpts.Indent = value
+			} else {
+				transformOpts.Indent = value
+			}
+
+		case strings.HasPrefix(arg, "--splitting-preset=") && buildOpts != nil:
+			value := arg[len("--splitting-preset="):]
+			switch value {
+			case "none":
+				buildOpts.SplittingPreset = api.SplittingPresetNone
+			case "vendor":
+				buildOpts.SplittingPreset = api.SplittingPresetVendor
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["splitting-preset"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--dynamic-import-loader=") && buildOpts != nil:
+			value := arg[len("--dynamic-import-loader="):]
+			switch value {
+			case "require":
+				buildOpts.DynamicImportLoader = api.DynamicImportLoaderRequire
+			case "script":
+				buildOpts.DynamicImportLoader = api.DynamicImportLoaderScript
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["dynamic-import-loader"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--unused-exports=") && buildOpts != nil:
+			value := arg[len("--unused-exports="):]
+			switch value {
+			case "ignore":
+				buildOpts.UnusedExports = api.UnusedExportsIgnore
+			case "warning":
+				buildOpts.UnusedExports = api.UnusedExportsWarning
+			case "error":
+				buildOpts.UnusedExports = api.UnusedExportsError
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["unused-exports"]),
+				), nil
+			}
+
 		case strings.HasPrefix(arg, "--charset="):
 			var value *api.Charset
 			if buildOpts != nil {
@@ -138,7 +338,55 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", name, arg),
-					"Valid values are \"ascii\" or \"utf8\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["charset"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--charset-escape="):
+			value := splitWithEmptyCheck(arg[len("--charset-escape="):], ",")
+			if buildOpts != nil {
+				buildOpts.CharsetEscape = value
+			} else {
+				transformOpts.CharsetEscape = value
+			}
+
+		case strings.HasPrefix(arg, "--charset-identifiers="):
+			var value *api.Charset
+			if buildOpts != nil {
+				value = &buildOpts.IdentifierCharset
+			} else {
+				value = &transformOpts.IdentifierCharset
+			}
+			name := arg[len("--charset-identifiers="):]
+			switch name {
+			case "ascii":
+				*value = api.CharsetASCII
+			case "utf8":
+				*value = api.CharsetUTF8
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", name, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["charset-identifiers"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--charset-normalization="):
+			var value *api.Normalization
+			if buildOpts != nil {
+				value = &buildOpts.IdentifierNormalization
+			} else {
+				value = &transformOpts.IdentifierNormalization
+			}
+			name := arg[len("--charset-normalization="):]
+			switch name {
+			case "nfc":
+				*value = api.NormalizationNFC
+			case "nfd":
+				*value = api.NormalizationNFD
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", name, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["charset-normalization"]),
 				), nil
 			}
 
@@ -158,7 +406,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", name, arg),
-					"Valid values are \"true\" or \"false\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["tree-shaking"]),
 				), nil
 			}
 
@@ -169,6 +417,20 @@ func parseOptionsImpl(
 				transformOpts.IgnoreAnnotations = true
 			}
 
+		case arg == "--recover-syntax-errors":
+			if buildOpts != nil {
+				buildOpts.RecoverSyntaxErrors = true
+			} else {
+				transformOpts.RecoverSyntaxErrors = true
+			}
+
+		case arg == "--infer-pure":
+			if buildOpts != nil {
+				buildOpts.InferPure = true
+			} else {
+				transformOpts.InferPure = true
+			}
+
 		case arg == "--keep-names":
 			if buildOpts != nil {
 				buildOpts.KeepNames = true
@@ -176,6 +438,13 @@ func parseOptionsImpl(
 				transformOpts.KeepNames = true
 			}
 
+		case arg == "--sourcemap-banners":
+			if buildOpts != nil {
+				buildOpts.SourcemapBanners = true
+			} else {
+				transformOpts.SourcemapBanners = true
+			}
+
 		case arg == "--sourcemap":
 			if buildOpts != nil {
 				buildOpts.Sourcemap = api.SourceMapLinked
@@ -197,7 +466,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"inline\", \"external\", or \"both\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["sourcemap"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -226,7 +495,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"true\" or \"false\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["sources-content"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -248,6 +517,25 @@ func parseOptionsImpl(
 		case strings.HasPrefix(arg, "--resolve-extensions=") && buildOpts != nil:
 			buildOpts.ResolveExtensions = splitWithEmptyCheck(arg[len("--resolve-extensions="):], ",")
 
+		case strings.HasPrefix(arg, "--index-extensions=") && buildOpts != nil:
+			buildOpts.IndexExtensions = splitWithEmptyCheck(arg[len("--index-extensions="):], ",")
+
+		case strings.HasPrefix(arg, "--directory-imports=") && buildOpts != nil:
+			value := arg[len("--directory-imports="):]
+			switch value {
+			case "cjs":
+				buildOpts.DirectoryImports = api.DirectoryImportsCommonJS
+			case "no-index":
+				buildOpts.DirectoryImports = api.DirectoryImportsNoIndex
+			case "node-esm":
+				buildOpts.DirectoryImports = api.DirectoryImportsNodeESM
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["directory-imports"]),
+				), nil
+			}
+
 		case strings.HasPrefix(arg, "--main-fields=") && buildOpts != nil:
 			buildOpts.MainFields = splitWithEmptyCheck(arg[len("--main-fields="):], ",")
 
@@ -272,6 +560,39 @@ func parseOptionsImpl(
 			buildOpts.Metafile = true
 			metafile = &metafilePath
 
+		case arg == "--module-map" && buildOpts != nil:
+			buildOpts.ModuleMap = true
+
+		case arg == "--cjs-wrapper" && buildOpts != nil:
+			buildOpts.CJSWrapper = true
+
+		case strings.HasPrefix(arg, "--precache-manifest=") && buildOpts != nil:
+			buildOpts.PrecacheManifest = arg[len("--precache-manifest="):]
+
+		case strings.HasPrefix(arg, "--content-manifest=") && buildOpts != nil:
+			buildOpts.ContentManifest = arg[len("--content-manifest="):]
+
+		case strings.HasPrefix(arg, "--shared-chunk-dir=") && buildOpts != nil:
+			buildOpts.SharedChunkDir = arg[len("--shared-chunk-dir="):]
+
+		case strings.HasPrefix(arg, "--service-worker=") && buildOpts != nil:
+			buildOpts.ServiceWorker = arg[len("--service-worker="):]
+
+		case strings.HasPrefix(arg, "--csp-report=") && buildOpts != nil:
+			buildOpts.CSPReport = arg[len("--csp-report="):]
+
+		case strings.HasPrefix(arg, "--css-order-report=") && buildOpts != nil:
+			buildOpts.CSSOrderReport = arg[len("--css-order-report="):]
+
+		case strings.HasPrefix(arg, "--name-cache=") && buildOpts != nil:
+			buildOpts.NameCache = arg[len("--name-cache="):]
+
+		case strings.HasPrefix(arg, "--mangle-cache=") && buildOpts != nil:
+			buildOpts.MangleCache = arg[len("--mangle-cache="):]
+
+		case strings.HasPrefix(arg, "--record=") && buildOpts != nil:
+			buildOpts.Record = arg[len("--record="):]
+
 		case strings.HasPrefix(arg, "--outfile=") && buildOpts != nil:
 			buildOpts.Outfile = arg[len("--outfile="):]
 
@@ -281,9 +602,15 @@ func parseOptionsImpl(
 		case strings.HasPrefix(arg, "--outbase=") && buildOpts != nil:
 			buildOpts.Outbase = arg[len("--outbase="):]
 
+		case strings.HasPrefix(arg, "--deno-dir=") && buildOpts != nil:
+			buildOpts.DenoDir = arg[len("--deno-dir="):]
+
 		case strings.HasPrefix(arg, "--tsconfig=") && buildOpts != nil:
 			buildOpts.Tsconfig = arg[len("--tsconfig="):]
 
+		case strings.HasPrefix(arg, "--type-check=") && buildOpts != nil:
+			buildOpts.TypeCheck = arg[len("--type-check="):]
+
 		case strings.HasPrefix(arg, "--tsconfig-raw=") && transformOpts != nil:
 			transformOpts.TsconfigRaw = arg[len("--tsconfig-raw="):]
 
@@ -312,6 +639,87 @@ func parseOptionsImpl(
 				transformOpts.Define[value[:equals]] = value[equals+1:]
 			}
 
+		case strings.HasPrefix(arg, "--feature:"):
+			value := arg[len("--feature:"):]
+			name, flag := value, true
+			if equals := strings.IndexByte(value, '='); equals != -1 {
+				name = value[:equals]
+				switch value[equals+1:] {
+				case "true":
+				case "false":
+					flag = false
+				default:
+					return cli_helpers.MakeErrorWithNote(
+						fmt.Sprintf("Invalid value for feature flag %q: %q", name, value[equals+1:]),
+						"Feature flags must be either \"true\" or \"false\". "+
+							"For example, \"--feature:NEW_CHECKOUT=false\" turns off the \"NEW_CHECKOUT\" flag.",
+					), nil
+				}
+			}
+			if buildOpts != nil {
+				buildOpts.FeatureFlags[name] = flag
+			} else {
+				transformOpts.FeatureFlags[name] = flag
+			}
+
+		case strings.HasPrefix(arg, "--supported:"):
+			value := arg[len("--supported:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to use \"=\" to specify whether the feature is supported. "+
+						"For example, \"--supported:bigint=false\" marks the \"bigint\" feature as unsupported.",
+				), nil
+			}
+			name := value[:equals]
+			var isSupported bool
+			switch value[equals+1:] {
+			case "true":
+				isSupported = true
+			case "false":
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value[equals+1:], arg),
+					cli_helpers.ValidValuesNote(colonFlagValues["supported"]),
+				), nil
+			}
+			if buildOpts != nil {
+				buildOpts.Supported[name] = isSupported
+			} else {
+				transformOpts.Supported[name] = isSupported
+			}
+
+		case strings.HasPrefix(arg, "--supported-file="):
+			value := arg[len("--supported-file="):]
+			if buildOpts != nil {
+				buildOpts.SupportedFile = value
+			} else {
+				transformOpts.SupportedFile = value
+			}
+
+		case strings.HasPrefix(arg, "--feature-report=") && buildOpts != nil:
+			buildOpts.FeatureReport = arg[len("--feature-report="):]
+
+		case strings.HasPrefix(arg, "--drop:"):
+			var drop api.Drop
+			switch value := arg[len("--drop:"):]; value {
+			case "console":
+				drop = api.DropConsole
+			case "debugger":
+				drop = api.DropDebugger
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(colonFlagKeys["drop"]),
+				), nil
+			}
+			if buildOpts != nil {
+				buildOpts.Drop |= drop
+			} else {
+				transformOpts.Drop |= drop
+			}
+
 		case strings.HasPrefix(arg, "--pure:"):
 			value := arg[len("--pure:"):]
 			if buildOpts != nil {
@@ -320,6 +728,69 @@ func parseOptionsImpl(
 				transformOpts.Pure = append(transformOpts.Pure, value)
 			}
 
+		case strings.HasPrefix(arg, "--preserve-comments="):
+			value := trimRegExpSlashes(arg[len("--preserve-comments="):])
+			if buildOpts != nil {
+				buildOpts.PreserveComments = value
+			} else {
+				transformOpts.PreserveComments = value
+			}
+
+		case strings.HasPrefix(arg, "--mangle-props="):
+			value := trimRegExpSlashes(arg[len("--mangle-props="):])
+			if buildOpts != nil {
+				buildOpts.MangleProps = value
+			} else {
+				transformOpts.MangleProps = value
+			}
+
+		case strings.HasPrefix(arg, "--reserve-props="):
+			value := trimRegExpSlashes(arg[len("--reserve-props="):])
+			if buildOpts != nil {
+				buildOpts.ReserveProps = value
+			} else {
+				transformOpts.ReserveProps = value
+			}
+
+		case strings.HasPrefix(arg, "--pragma:"):
+			value := arg[len("--pragma:"):]
+			if buildOpts != nil {
+				buildOpts.Pragmas = append(buildOpts.Pragmas, value)
+			} else {
+				transformOpts.Pragmas = append(transformOpts.Pragmas, value)
+			}
+
+		case strings.HasPrefix(arg, "--strip-if:"):
+			value := arg[len("--strip-if:"):]
+			if buildOpts != nil {
+				buildOpts.StripIf = append(buildOpts.StripIf, value)
+			} else {
+				transformOpts.StripIf = append(transformOpts.StripIf, value)
+			}
+
+		case strings.HasPrefix(arg, "--strip-between:"):
+			value := arg[len("--strip-between:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to use \"=\" to separate the start marker from the end marker. "+
+						"For example, \"--strip-between:test:start=test:end\" removes the code "+
+						"between \"/* test:start */\" and \"/* test:end */\".",
+				), nil
+			}
+			if buildOpts != nil {
+				if buildOpts.StripBetween == nil {
+					buildOpts.StripBetween = make(map[string]string)
+				}
+				buildOpts.StripBetween[value[:equals]] = value[equals+1:]
+			} else {
+				if transformOpts.StripBetween == nil {
+					transformOpts.StripBetween = make(map[string]string)
+				}
+				transformOpts.StripBetween[value[:equals]] = value[equals+1:]
+			}
+
 		case strings.HasPrefix(arg, "--loader:") && buildOpts != nil:
 			value := arg[len("--loader:"):]
 			equals := strings.IndexByte(value, '=')
@@ -343,11 +814,11 @@ func parseOptionsImpl(
 			if err != nil {
 				return err, nil
 			}
-			if loader == api.LoaderFile {
+			if loader == api.LoaderFile || loader == api.LoaderCopy || loader == api.LoaderImage {
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("%q is not supported when transforming stdin", arg),
-					"Using esbuild to transform stdin only generates one output file, so you cannot use the \"file\" loader "+
-						"since that needs to generate two output files.",
+					fmt.Sprintf("Using esbuild to transform stdin only generates one output file, so you cannot use the %q loader "+
+						"since that needs to generate two output files.", cli_helpers.LoaderName(loader)),
 				), nil
 			}
 			if buildOpts != nil {
@@ -378,61 +849,240 @@ func parseOptionsImpl(
 			if equals == -1 {
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Missing \"=\" in %q", arg),
-					"You need to use either \"--out-extension:.js=...\" or \"--out-extension:.css=...\" "+
-						"to specify the file type that the output extension applies to .",
+					"You need to use either \"--out-extension:.js=...\" or \"--out-extension:.css=...\" "+
+						"to specify the file type that the output extension applies to .",
+				), nil
+			}
+			if buildOpts.OutExtensions == nil {
+				buildOpts.OutExtensions = make(map[string]string)
+			}
+			buildOpts.OutExtensions[value[:equals]] = value[equals+1:]
+
+		case strings.HasPrefix(arg, "--platform=") && buildOpts != nil:
+			value := arg[len("--platform="):]
+			switch value {
+			case "browser":
+				buildOpts.Platform = api.PlatformBrowser
+			case "node":
+				buildOpts.Platform = api.PlatformNode
+			case "neutral":
+				buildOpts.Platform = api.PlatformNeutral
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["platform"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--format=") && buildOpts != nil && strings.ContainsRune(arg, ','):
+			value := arg[len("--format="):]
+			buildOpts.Format = api.FormatDefault
+			buildOpts.Formats = nil
+			for _, name := range strings.Split(value, ",") {
+				switch name {
+				case "iife":
+					buildOpts.Formats = append(buildOpts.Formats, api.FormatIIFE)
+				case "cjs":
+					buildOpts.Formats = append(buildOpts.Formats, api.FormatCommonJS)
+				case "esm":
+					buildOpts.Formats = append(buildOpts.Formats, api.FormatESModule)
+				case "umd":
+					buildOpts.Formats = append(buildOpts.Formats, api.FormatUMD)
+				default:
+					return cli_helpers.MakeErrorWithNote(
+						fmt.Sprintf("Invalid format %q in %q", name, arg),
+						cli_helpers.ValidValuesNote(equalsFlagValues["format"]),
+					), nil
+				}
+			}
+
+		case strings.HasPrefix(arg, "--format="):
+			value := arg[len("--format="):]
+			if buildOpts != nil {
+				buildOpts.Formats = nil
+			}
+			switch value {
+			case "iife":
+				if buildOpts != nil {
+					buildOpts.Format = api.FormatIIFE
+				} else {
+					transformOpts.Format = api.FormatIIFE
+				}
+			case "cjs":
+				if buildOpts != nil {
+					buildOpts.Format = api.FormatCommonJS
+				} else {
+					transformOpts.Format = api.FormatCommonJS
+				}
+			case "esm":
+				if buildOpts != nil {
+					buildOpts.Format = api.FormatESModule
+				} else {
+					transformOpts.Format = api.FormatESModule
+				}
+			case "umd":
+				if buildOpts != nil {
+					buildOpts.Format = api.FormatUMD
+				} else {
+					transformOpts.Format = api.FormatUMD
+				}
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["format"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--external:") && buildOpts != nil:
+			value := arg[len("--external:"):]
+			if equals := strings.IndexByte(value, '='); equals != -1 {
+				// "--external:react=React" reads the module from a global variable
+				buildOpts.Globals[value[:equals]] = value[equals+1:]
+			} else {
+				buildOpts.External = append(buildOpts.External, value)
+			}
+
+		case strings.HasPrefix(arg, "--packages=") && buildOpts != nil:
+			value := arg[len("--packages="):]
+			switch value {
+			case "external":
+				buildOpts.Packages = api.PackagesExternal
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["packages"]),
+				), nil
+			}
+
+		case strings.HasPrefix(arg, "--external-helpers="):
+			value := arg[len("--external-helpers="):]
+			if buildOpts != nil {
+				buildOpts.ExternalHelpers = value
+			} else {
+				transformOpts.ExternalHelpers = value
+			}
+
+		case strings.HasPrefix(arg, "--external-rewrite:") && buildOpts != nil:
+			value := arg[len("--external-rewrite:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to specify both the import path and the path to use in the output. "+
+						"For example, \"--external-rewrite:lodash=https://cdn.example.com/lodash\" keeps imports of \"lodash\" external and imports \"https://cdn.example.com/lodash\" instead.",
+				), nil
+			}
+			buildOpts.ExternalRewrite[value[:equals]] = value[equals+1:]
+
+		case strings.HasPrefix(arg, "--isolate-package:") && buildOpts != nil:
+			buildOpts.IsolatePackages = append(buildOpts.IsolatePackages, arg[len("--isolate-package:"):])
+
+		case (strings.HasPrefix(arg, "--chunk-min-size=") || strings.HasPrefix(arg, "--chunk-max-size=")) && buildOpts != nil:
+			value := arg[len("--chunk-min-size="):]
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 0 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					"The chunk size must be a non-negative integer number of bytes.",
+				), nil
+			}
+			if strings.HasPrefix(arg, "--chunk-min-size=") {
+				buildOpts.ChunkMinSize = size
+			} else {
+				buildOpts.ChunkMaxSize = size
+			}
+
+		case strings.HasPrefix(arg, "--isolate-chunk:") && buildOpts != nil:
+			buildOpts.IsolateChunks = append(buildOpts.IsolateChunks, arg[len("--isolate-chunk:"):])
+
+		case strings.HasPrefix(arg, "--lazy-package:") && buildOpts != nil:
+			buildOpts.LazyPackages = append(buildOpts.LazyPackages, arg[len("--lazy-package:"):])
+
+		case strings.HasPrefix(arg, "--chunk:") && buildOpts != nil:
+			value := arg[len("--chunk:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to specify both the chunk name and the path. "+
+						"For example, \"--chunk:vendor=node_modules/*\" puts all files inside \"node_modules\" in a chunk called \"vendor\".",
+				), nil
+			}
+			if buildOpts.Chunks == nil {
+				buildOpts.Chunks = make(map[string][]string)
+			}
+			name := value[:equals]
+			buildOpts.Chunks[name] = append(buildOpts.Chunks[name], value[equals+1:])
+
+		case strings.HasPrefix(arg, "--css-layer:") && buildOpts != nil:
+			value := arg[len("--css-layer:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to specify both the package name and the layer name. "+
+						"For example, \"--css-layer:bootstrap=framework\" puts all CSS files from the \"bootstrap\" package in the \"framework\" layer.",
+				), nil
+			}
+			if buildOpts.CSSLayers == nil {
+				buildOpts.CSSLayers = make(map[string]string)
+			}
+			buildOpts.CSSLayers[value[:equals]] = value[equals+1:]
+
+		case strings.HasPrefix(arg, "--budget:") && buildOpts != nil:
+			value := arg[len("--budget:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to use \"--budget:bytes=...\", \"--budget:functions=...\", or \"--budget:statements=...\" "+
+						"to specify what the budget applies to.",
 				), nil
 			}
-			if buildOpts.OutExtensions == nil {
-				buildOpts.OutExtensions = make(map[string]string)
+			limit, err := strconv.Atoi(value[equals+1:])
+			if err != nil || limit < 0 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value[equals+1:], arg),
+					"The budget must be a non-negative integer.",
+				), nil
 			}
-			buildOpts.OutExtensions[value[:equals]] = value[equals+1:]
-
-		case strings.HasPrefix(arg, "--platform=") && buildOpts != nil:
-			value := arg[len("--platform="):]
-			switch value {
-			case "browser":
-				buildOpts.Platform = api.PlatformBrowser
-			case "node":
-				buildOpts.Platform = api.PlatformNode
-			case "neutral":
-				buildOpts.Platform = api.PlatformNeutral
+			switch value[:equals] {
+			case "bytes":
+				buildOpts.Budgets.Bytes = limit
+			case "functions":
+				buildOpts.Budgets.Functions = limit
+			case "statements":
+				buildOpts.Budgets.Statements = limit
 			default:
 				return cli_helpers.MakeErrorWithNote(
-					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"browser\", \"node\", or \"neutral\".",
+					fmt.Sprintf("Invalid budget %q in %q", value[:equals], arg),
+					"Valid budgets are \"bytes\", \"functions\", or \"statements\".",
 				), nil
 			}
 
-		case strings.HasPrefix(arg, "--format="):
-			value := arg[len("--format="):]
-			switch value {
-			case "iife":
-				if buildOpts != nil {
-					buildOpts.Format = api.FormatIIFE
-				} else {
-					transformOpts.Format = api.FormatIIFE
-				}
-			case "cjs":
-				if buildOpts != nil {
-					buildOpts.Format = api.FormatCommonJS
-				} else {
-					transformOpts.Format = api.FormatCommonJS
-				}
-			case "esm":
-				if buildOpts != nil {
-					buildOpts.Format = api.FormatESModule
-				} else {
-					transformOpts.Format = api.FormatESModule
-				}
-			default:
+		case strings.HasPrefix(arg, "--workspace:") && buildOpts != nil:
+			value := arg[len("--workspace:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
 				return cli_helpers.MakeErrorWithNote(
-					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"iife\", \"cjs\", or \"esm\".",
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to specify both the package name and the directory for the package. "+
+						"For example, \"--workspace:@app/utils=packages/utils\" resolves imports of \"@app/utils\" to the \"packages/utils\" directory.",
 				), nil
 			}
+			buildOpts.Workspaces[value[:equals]] = value[equals+1:]
 
-		case strings.HasPrefix(arg, "--external:") && buildOpts != nil:
-			buildOpts.External = append(buildOpts.External, arg[len("--external:"):])
+		case strings.HasPrefix(arg, "--alias:") && buildOpts != nil:
+			value := arg[len("--alias:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Missing \"=\" in %q", arg),
+					"You need to use \"=\" to specify both the original package name and the replacement package name. "+
+						"For example, \"--alias:react=preact/compat\" replaces imports of \"react\" with imports of \"preact/compat\".",
+				), nil
+			}
+			buildOpts.Alias[value[:equals]] = value[equals+1:]
 
 		case strings.HasPrefix(arg, "--inject:") && buildOpts != nil:
 			buildOpts.Inject = append(buildOpts.Inject, arg[len("--inject:"):])
@@ -445,10 +1095,12 @@ func parseOptionsImpl(
 				mode = api.JSXModeTransform
 			case "preserve":
 				mode = api.JSXModePreserve
+			case "automatic":
+				mode = api.JSXModeAutomatic
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"transform\" or \"preserve\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["jsx"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -473,6 +1125,44 @@ func parseOptionsImpl(
 				transformOpts.JSXFragment = value
 			}
 
+		case strings.HasPrefix(arg, "--jsx-import-source="):
+			value := arg[len("--jsx-import-source="):]
+			if buildOpts != nil {
+				buildOpts.JSXImportSource = value
+			} else {
+				transformOpts.JSXImportSource = value
+			}
+
+		case strings.HasPrefix(arg, "--ts-version="):
+			value := arg[len("--ts-version="):]
+			if buildOpts != nil {
+				buildOpts.TSVersion = value
+			} else {
+				transformOpts.TSVersion = value
+			}
+
+		case strings.HasPrefix(arg, "--ts-enums="):
+			value := arg[len("--ts-enums="):]
+			var enums api.TSEnums
+			switch value {
+			case "classic":
+				enums = api.TSEnumsClassic
+			case "frozen":
+				enums = api.TSEnumsFrozen
+			case "inline":
+				enums = api.TSEnumsInline
+			default:
+				return cli_helpers.MakeErrorWithNote(
+					fmt.Sprintf("Invalid value %q in %q", value, arg),
+					cli_helpers.ValidValuesNote(equalsFlagValues["ts-enums"]),
+				), nil
+			}
+			if buildOpts != nil {
+				buildOpts.TSEnums = enums
+			} else {
+				transformOpts.TSEnums = enums
+			}
+
 		case strings.HasPrefix(arg, "--banner=") && transformOpts != nil:
 			transformOpts.Banner = arg[len("--banner="):]
 
@@ -528,7 +1218,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"true\" or \"false\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["color"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -557,7 +1247,7 @@ func parseOptionsImpl(
 			default:
 				return cli_helpers.MakeErrorWithNote(
 					fmt.Sprintf("Invalid value %q in %q", value, arg),
-					"Valid values are \"verbose\", \"debug\", \"info\", \"warning\", \"error\", or \"silent\".",
+					cli_helpers.ValidValuesNote(equalsFlagValues["log-level"]),
 				), nil
 			}
 			if buildOpts != nil {
@@ -584,68 +1274,6 @@ func parseOptionsImpl(
 			}
 
 		default:
-			bare := map[string]bool{
-				"allow-overwrite":    true,
-				"bundle":             true,
-				"ignore-annotations": true,
-				"keep-names":         true,
-				"metafile":           true,
-				"minify-identifiers": true,
-				"minify-syntax":      true,
-				"minify-whitespace":  true,
-				"minify":             true,
-				"preserve-symlinks":  true,
-				"sourcemap":          true,
-				"splitting":          true,
-				"watch":              true,
-			}
-
-			equals := map[string]bool{
-				"legal-comments":     true,
-				"charset":            true,
-				"tree-shaking":       true,
-				"sourcemap":          true,
-				"source-root":        true,
-				"sources-content":    true,
-				"sourcefile":         true,
-				"resolve-extensions": true,
-				"main-fields":        true,
-				"conditions":         true,
-				"public-path":        true,
-				"global-name":        true,
-				"outfile":            true,
-				"outdir":             true,
-				"outbase":            true,
-				"tsconfig":           true,
-				"tsconfig-raw":       true,
-				"entry-names":        true,
-				"chunk-names":        true,
-				"asset-names":        true,
-				"loader":             true,
-				"target":             true,
-				"platform":           true,
-				"format":             true,
-				"jsx":                true,
-				"jsx-factory":        true,
-				"jsx-fragment":       true,
-				"banner":             true,
-				"footer":             true,
-				"log-limit":          true,
-				"color":              true,
-				"log-level":          true,
-			}
-
-			colon := map[string]bool{
-				"define":        true,
-				"pure":          true,
-				"loader":        true,
-				"out-extension": true,
-				"external":      true,
-				"inject":        true,
-				"banner":        true,
-				"footer":        true,
-			}
-
 			note := ""
 
 			// Try to provide helpful hints when we can recognize the mistake
@@ -657,28 +1285,28 @@ func parseOptionsImpl(
 				note = "Use \"--log-level=verbose\" to generate verbose logs instead of \"-v\"."
 
 			case strings.HasPrefix(arg, "--"):
-				if i := strings.IndexByte(arg, '='); i != -1 && colon[arg[2:i]] {
+				if i := strings.IndexByte(arg, '='); i != -1 && colonFlags[arg[2:i]] {
 					note = fmt.Sprintf("Use %q instead of %q. Flags that can be re-specified multiple times use \":\" instead of \"=\".",
 						arg[:i]+":"+arg[i+1:], arg)
 				}
 
-				if i := strings.IndexByte(arg, ':'); i != -1 && equals[arg[2:i]] {
+				if i := strings.IndexByte(arg, ':'); i != -1 && equalsFlags[arg[2:i]] {
 					note = fmt.Sprintf("Use %q instead of %q. Flags that can only be specified once use \"=\" instead of \":\".",
 						arg[:i]+"="+arg[i+1:], arg)
 				}
 
 			case strings.HasPrefix(arg, "-"):
-				isValid := bare[arg[1:]]
+				isValid := bareFlags[arg[1:]]
 				fix := "-" + arg
 
-				if i := strings.IndexByte(arg, '='); i != -1 && equals[arg[1:i]] {
+				if i := strings.IndexByte(arg, '='); i != -1 && equalsFlags[arg[1:i]] {
 					isValid = true
-				} else if i != -1 && colon[arg[1:i]] {
+				} else if i != -1 && colonFlags[arg[1:i]] {
 					isValid = true
 					fix = fmt.Sprintf("-%s:%s", arg[:i], arg[i+1:])
-				} else if i := strings.IndexByte(arg, ':'); i != -1 && colon[arg[1:i]] {
+				} else if i := strings.IndexByte(arg, ':'); i != -1 && colonFlags[arg[1:i]] {
 					isValid = true
-				} else if i != -1 && equals[arg[1:i]] {
+				} else if i != -1 && equalsFlags[arg[1:i]] {
 					isValid = true
 					fix = fmt.Sprintf("-%s=%s", arg[:i], arg[i+1:])
 				}
@@ -698,29 +1326,298 @@ func parseOptionsImpl(
 	}
 
 	// If we're building, the last source map flag is "--sourcemap", and there
-	// is no output path, change the source map option to "inline" because we're
-	// going to be writing to stdout which can only represent a single file.
-	if buildOpts != nil && hasBareSourceMapFlag && buildOpts.Outfile == "" && buildOpts.Outdir == "" {
+	// is no output path (or the output path is "--outfile=-"), change the source
+	// map option to "inline" because we're going to be writing to stdout which
+	// can only represent a single file.
+	if buildOpts != nil && hasBareSourceMapFlag && (buildOpts.Outfile == "" || buildOpts.Outfile == "-") && buildOpts.Outdir == "" {
 		buildOpts.Sourcemap = api.SourceMapInline
 	}
 
 	return
 }
 
-func parseTargets(targets []string, arg string) (target api.Target, engines []api.Engine, err *cli_helpers.ErrorWithNote) {
-	validTargets := map[string]api.Target{
-		"esnext": api.ESNext,
-		"es5":    api.ES5,
-		"es6":    api.ES2015,
-		"es2015": api.ES2015,
-		"es2016": api.ES2016,
-		"es2017": api.ES2017,
-		"es2018": api.ES2018,
-		"es2019": api.ES2019,
-		"es2020": api.ES2020,
-		"es2021": api.ES2021,
+// These tables contain the names of all flags that take no value, flags that
+// take a value after "=", and flags that take a value after ":" (which can be
+// specified multiple times). They are used to provide hints for mistyped flags
+// and to generate shell completion scripts and the output of "--flags-json".
+var (
+	bareFlags = map[string]bool{
+		"access-list":           true,
+		"allow-overwrite":       true,
+		"analyze":               true,
+		"bundle":                true,
+		"bundle-dynamic-paths":  true,
+		"cjs-wrapper":           true,
+		"detect-workspaces":     true,
+		"dual-package":          true,
+		"flags-json":            true,
+		"ignore-annotations":    true,
+		"infer-pure":            true,
+		"infer-target":          true,
+		"inject-css-link":       true,
+		"keep-names":            true,
+		"metafile":              true,
+		"minify-identifiers":    true,
+		"minify-syntax":         true,
+		"minify-whitespace":     true,
+		"minify":                true,
+		"module-map":            true,
+		"node-polyfills":        true,
+		"preserve-modules":      true,
+		"preserve-symlinks":     true,
+		"recover-syntax-errors": true,
+		"rewrite-imports":       true,
+		"serve":                 true,
+		"skip-unchanged":        true,
+		"sourcemap":             true,
+		"sourcemap-banners":     true,
+		"splitting":             true,
+		"strict-case":           true,
+		"tree-shake-members":    true,
+		"watch":                 true,
+	}
+
+	equalsFlags = map[string]bool{
+		"legal-comments":        true,
+		"annotations":           true,
+		"magic-comments":        true,
+		"line-ending":           true,
+		"on-conflict":           true,
+		"indent":                true,
+		"log-file":              true,
+		"log-file-format":       true,
+		"config":                true,
+		"completions":           true,
+		"charset":               true,
+		"charset-escape":        true,
+		"charset-identifiers":   true,
+		"charset-normalization": true,
+		"tree-shaking":          true,
+		"sourcemap":             true,
+		"splitting-preset":      true,
+		"unused-exports":        true,
+		"dynamic-import-loader": true,
+		"source-root":           true,
+		"sources-content":       true,
+		"sourcefile":            true,
+		"resolve-extensions":    true,
+		"index-extensions":      true,
+		"directory-imports":     true,
+		"main-fields":           true,
+		"minify-seed":           true,
+		"conditions":            true,
+		"public-path":           true,
+		"global-name":           true,
+		"metafile":              true,
+		"outfile":               true,
+		"outdir":                true,
+		"outbase":               true,
+		"packages":              true,
+		"precache-manifest":     true,
+		"content-manifest":      true,
+		"preserve-comments":     true,
+		"mangle-cache":          true,
+		"mangle-props":          true,
+		"reserve-props":         true,
+		"service-worker":        true,
+		"shared-chunk-dir":      true,
+		"csp-report":            true,
+		"css-order-report":      true,
+		"feature-report":        true,
+		"supported-file":        true,
+		"name-cache":            true,
+		"record":                true,
+		"deno-dir":              true,
+		"tsconfig":              true,
+		"tsconfig-raw":          true,
+		"type-check":            true,
+		"ts-enums":              true,
+		"ts-version":            true,
+		"entry-names":           true,
+		"chunk-names":           true,
+		"chunk-min-size":        true,
+		"chunk-max-size":        true,
+		"asset-names":           true,
+		"loader":                true,
+		"target":                true,
+		"platform":              true,
+		"format":                true,
+		"external-helpers":      true,
+		"jsx":                   true,
+		"jsx-factory":           true,
+		"jsx-fragment":          true,
+		"jsx-import-source":     true,
+		"banner":                true,
+		"footer":                true,
+		"log-limit":             true,
+		"watch":                 true,
+		"watch-max-failures":    true,
+		"color":                 true,
+		"log-level":             true,
+		"serve":                 true,
+		"servedir":              true,
+		"serve-fallback-proxy":  true,
+	}
+
+	colonFlags = map[string]bool{
+		"define":           true,
+		"feature":          true,
+		"supported":        true,
+		"pure":             true,
+		"drop":             true,
+		"pragma":           true,
+		"strip-if":         true,
+		"strip-between":    true,
+		"loader":           true,
+		"out-extension":    true,
+		"external":         true,
+		"external-rewrite": true,
+		"isolate-package":  true,
+		"isolate-chunk":    true,
+		"chunk":            true,
+		"lazy-package":     true,
+		"css-layer":        true,
+		"budget":           true,
+		"serve-throttle":   true,
+		"inject":           true,
+		"banner":           true,
+		"footer":           true,
+		"workspace":        true,
+		"alias":            true,
+		"annotations":      true,
+	}
+
+	// These are the values accepted after the "=" for flags that only accept
+	// certain values, in the order that they are listed in error messages
+	equalsFlagValues = map[string][]string{
+		"annotations":           {"default", "always", "never"},
+		"charset":               {"ascii", "utf8"},
+		"charset-identifiers":   {"ascii", "utf8"},
+		"charset-normalization": {"nfc", "nfd"},
+		"color":                 {"true", "false"},
+		"completions":           {"bash", "zsh", "fish", "powershell"},
+		"directory-imports":     {"cjs", "no-index", "node-esm"},
+		"dynamic-import-loader": {"require", "script"},
+		"format":                {"iife", "cjs", "esm", "umd"},
+		"jsx":                   {"transform", "preserve", "automatic"},
+		"legal-comments":        {"none", "inline", "eof", "linked", "external"},
+		"line-ending":           {"lf", "crlf"},
+		"loader":                append(cli_helpers.LoaderNames(), "default"),
+		"log-file-format":       {"text", "json"},
+		"log-level":             {"verbose", "debug", "info", "warning", "error", "silent"},
+		"magic-comments":        {"default", "always", "never"},
+		"on-conflict":           {"error", "rename", "overwrite"},
+		"packages":              {"external"},
+		"platform":              {"browser", "node", "neutral"},
+		"sourcemap":             {"inline", "external", "both"},
+		"sources-content":       {"true", "false"},
+		"splitting-preset":      {"none", "vendor"},
+		"target":                targetNames(),
+		"tree-shaking":          {"true", "false"},
+		"ts-enums":              {"classic", "frozen", "inline"},
+		"unused-exports":        {"ignore", "warning", "error"},
+		"watch":                 {"forever", "once"},
+	}
+
+	// These are the values accepted after the "=" for flags that take a key
+	// after the ":" and then one of certain values (e.g. "--loader:.png=file")
+	colonFlagValues = map[string][]string{
+		"annotations": equalsFlagValues["annotations"],
+		"loader":      equalsFlagValues["loader"],
+		"supported":   {"true", "false"},
+	}
+
+	// These are the keys accepted after the ":" for flags that only accept
+	// certain keys
+	colonFlagKeys = map[string][]string{
+		"drop": {"console", "debugger"},
+	}
+
+	// The key after the ":" for these flags is followed by "=" and a value
+	colonFlagsWithValue = map[string]bool{
+		"alias":            true,
+		"annotations":      true,
+		"banner":           true,
+		"budget":           true,
+		"chunk":            true,
+		"css-layer":        true,
+		"define":           true,
+		"external-rewrite": true,
+		"footer":           true,
+		"loader":           true,
+		"out-extension":    true,
+		"serve-throttle":   true,
+		"strip-between":    true,
+		"supported":        true,
+		"workspace":        true,
+	}
+
+	// The key after the ":" for these flags can optionally be followed by "="
+	// and a value
+	colonFlagsWithOptionalValue = map[string]bool{
+		"external": true,
+		"feature":  true,
+	}
+
+	// The value after the "=" for these flags is a comma-separated list
+	listFlags = map[string]bool{
+		"charset-escape":     true,
+		"conditions":         true,
+		"format":             true,
+		"index-extensions":   true,
+		"main-fields":        true,
+		"resolve-extensions": true,
+		"target":             true,
+	}
+
+	// The value after the "=" for these flags is an integer
+	integerFlags = map[string]bool{
+		"chunk-max-size":     true,
+		"chunk-min-size":     true,
+		"log-limit":          true,
+		"watch-max-failures": true,
+	}
+)
+
+func parseAnnotations(value string, arg string) (api.Annotations, *cli_helpers.ErrorWithNote) {
+	switch value {
+	case "default":
+		return api.AnnotationsDefault, nil
+	case "always":
+		return api.AnnotationsAlways, nil
+	case "never":
+		return api.AnnotationsNever, nil
+	default:
+		return api.AnnotationsDefault, cli_helpers.MakeErrorWithNote(
+			fmt.Sprintf("Invalid value %q in %q", value, arg),
+			cli_helpers.ValidValuesNote(equalsFlagValues["annotations"]),
+		)
 	}
+}
+
+var validTargets = map[string]api.Target{
+	"esnext": api.ESNext,
+	"es5":    api.ES5,
+	"es6":    api.ES2015,
+	"es2015": api.ES2015,
+	"es2016": api.ES2016,
+	"es2017": api.ES2017,
+	"es2018": api.ES2018,
+	"es2019": api.ES2019,
+	"es2020": api.ES2020,
+	"es2021": api.ES2021,
+}
+
+func targetNames() []string {
+	names := make([]string, 0, len(validTargets))
+	for name := range validTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
+func parseTargets(targets []string, arg string) (target api.Target, engines []api.Engine, err *cli_helpers.ErrorWithNote) {
 	validEngines := map[string]api.EngineName{
 		"chrome":  api.EngineChrome,
 		"firefox": api.EngineFirefox,
@@ -768,6 +1665,12 @@ outer:
 
 // This returns either BuildOptions, TransformOptions, or an error
 func parseOptionsForRun(osArgs []string) (*api.BuildOptions, *string, *api.TransformOptions, *cli_helpers.ErrorWithNote) {
+	// Options from the config file go first so command-line flags override them
+	osArgs, err := expandConfigFile(osArgs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// If there's an entry point or we're bundling, then we're building
 	for _, arg := range osArgs {
 		if !strings.HasPrefix(arg, "-") || arg == "--bundle" {
@@ -777,6 +1680,7 @@ func parseOptionsForRun(osArgs []string) (*api.BuildOptions, *string, *api.Trans
 			options.LogLimit = 6
 			options.LogLevel = api.LogLevelInfo
 			options.Write = true
+			options.StreamCopiedFiles = true
 
 			err, metafile := parseOptionsImpl(osArgs, &options, nil, kindInternal)
 			if err != nil {
@@ -793,7 +1697,7 @@ func parseOptionsForRun(osArgs []string) (*api.BuildOptions, *string, *api.Trans
 	options.LogLimit = 6
 	options.LogLevel = api.LogLevelInfo
 
-	err, _ := parseOptionsImpl(osArgs, nil, &options, kindInternal)
+	err, _ = parseOptionsImpl(osArgs, nil, &options, kindInternal)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -825,12 +1729,199 @@ func splitWithEmptyCheck(s string, sep string) []string {
 	return strings.Split(s, sep)
 }
 
+// Allow regular expression patterns to be written like a JavaScript regular
+// expression (i.e. surrounded by slashes)
+func trimRegExpSlashes(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "/") && strings.HasSuffix(value, "/") {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// This implements "esbuild analyze", which prints the same report as the
+// "--analyze" flag but for a metafile from a previous build
+func analyzeImpl(osArgs []string) int {
+	options := api.AnalyzeMetafileOptions{}
+	metafilePath := ""
+
+	for _, arg := range osArgs {
+		switch {
+		case arg == "--verbose":
+			options.Verbose = true
+
+		case strings.HasPrefix(arg, "--filter="):
+			options.Filter = arg[len("--filter="):]
+
+		case arg == "--color=true" || arg == "--color=false":
+			// This is handled by "logger.OutputOptionsForArgs"
+
+		case !strings.HasPrefix(arg, "-") && metafilePath == "":
+			metafilePath = arg
+
+		default:
+			logger.PrintMessageToStderr(osArgs, logger.Msg{
+				Kind:  logger.Error,
+				Data:  logger.MsgData{Text: fmt.Sprintf("Invalid analyze flag: %q", arg)},
+				Notes: []logger.MsgData{{Text: "Usage: esbuild analyze [--verbose] [--filter=pkg] metafile.json"}},
+			})
+			return 1
+		}
+	}
+
+	if metafilePath == "" {
+		logger.PrintErrorToStderr(osArgs, "Missing metafile path (usage: esbuild analyze [--verbose] [--filter=pkg] metafile.json)")
+		return 1
+	}
+
+	bytes, err := ioutil.ReadFile(metafilePath)
+	if err != nil {
+		logger.PrintErrorToStderr(osArgs, fmt.Sprintf("Could not read from %q: %s", metafilePath, err.Error()))
+		return 1
+	}
+
+	// Print the analysis to stdout since it's the only output of this command
+	var result string
+	logger.PrintTextWithColor(os.Stdout, logger.OutputOptionsForArgs(osArgs).Color, func(colors logger.Colors) string {
+		options.Color = colors != logger.Colors{}
+		result = api.AnalyzeMetafile(string(bytes), options)
+		return strings.TrimPrefix(result, "\n")
+	})
+
+	if result == "" {
+		if options.Filter != "" {
+			logger.PrintErrorToStderr(osArgs, fmt.Sprintf("No input files in %q are from the package %q", metafilePath, options.Filter))
+		} else {
+			logger.PrintErrorToStderr(osArgs, fmt.Sprintf("Could not find any output files in %q", metafilePath))
+		}
+		return 1
+	}
+	return 0
+}
+
+func replayImpl(osArgs []string) int {
+	options := api.ReplayOptions{
+		LogLevel:   api.LogLevelInfo,
+		Substitute: make(map[string]string),
+		Write:      true,
+	}
+	for _, arg := range osArgs {
+		switch {
+		case strings.HasPrefix(arg, "--substitute:"):
+			value := arg[len("--substitute:"):]
+			equals := strings.IndexByte(value, '=')
+			if equals == -1 {
+				logger.PrintMessageToStderr(osArgs, logger.Msg{
+					Kind: logger.Error,
+					Data: logger.MsgData{Text: fmt.Sprintf("Missing \"=\" in %q", arg)},
+					Notes: []logger.MsgData{{Text: "You need to use \"=\" to specify both the recorded path and the file to use instead. " +
+						"For example, \"--substitute:src/app.js=app-fixed.js\" replays the build with the contents of \"app-fixed.js\" instead of \"src/app.js\"."}},
+				})
+				return 1
+			}
+			options.Substitute[value[:equals]] = value[equals+1:]
+
+		case strings.HasPrefix(arg, "--outdir="):
+			options.Outdir = arg[len("--outdir="):]
+
+		case strings.HasPrefix(arg, "--log-level="):
+			switch arg[len("--log-level="):] {
+			case "verbose":
+				options.LogLevel = api.LogLevelVerbose
+			case "debug":
+				options.LogLevel = api.LogLevelDebug
+			case "info":
+				options.LogLevel = api.LogLevelInfo
+			case "warning":
+				options.LogLevel = api.LogLevelWarning
+			case "error":
+				options.LogLevel = api.LogLevelError
+			case "silent":
+				options.LogLevel = api.LogLevelSilent
+			default:
+				logger.PrintErrorToStderr(osArgs, fmt.Sprintf("Invalid log level: %q", arg))
+				return 1
+			}
+
+		case arg == "--color=true":
+			options.Color = api.ColorAlways
+
+		case arg == "--color=false":
+			options.Color = api.ColorNever
+
+		case !strings.HasPrefix(arg, "-") && options.Record == "":
+			options.Record = arg
+
+		default:
+			logger.PrintMessageToStderr(osArgs, logger.Msg{
+				Kind:  logger.Error,
+				Data:  logger.MsgData{Text: fmt.Sprintf("Invalid replay flag: %q", arg)},
+				Notes: []logger.MsgData{{Text: "Usage: esbuild replay [--substitute:recorded=file] [--outdir=dir] record-file"}},
+			})
+			return 1
+		}
+	}
+
+	if options.Record == "" {
+		logger.PrintErrorToStderr(osArgs, "Missing record file path (usage: esbuild replay [--substitute:recorded=file] [--outdir=dir] record-file)")
+		return 1
+	}
+
+	result := api.Replay(options)
+	if len(result.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Commands such as "esbuild dev" are only recognized when there's no file or
+// directory with that name in the current directory. Otherwise the argument
+// is an entry point, which is what it meant before the command existed.
+func isCommand(osArgs []string, name string) bool {
+	if len(osArgs) == 0 || osArgs[0] != name {
+		return false
+	}
+	_, err := os.Stat(name)
+	return err != nil
+}
+
 func runImpl(osArgs []string) int {
+	// Special-case the "analyze" command
+	if isCommand(osArgs, "analyze") {
+		return analyzeImpl(osArgs[1:])
+	}
+
+	// Special-case the "replay" command
+	if isCommand(osArgs, "replay") {
+		return replayImpl(osArgs[1:])
+	}
+
+	// Special-case the "dev" command, which can also be written as "--dev"
+	if isCommand(osArgs, "dev") {
+		return devImpl(osArgs[1:])
+	}
+	if args, ok := removeDevFlag(osArgs); ok {
+		return devImpl(args)
+	}
+
 	analyze := false
 	analyzeVerbose := false
+	logFilePath := ""
+	logFileFormat := logFileText
+	watchOnce := false
+	watchMaxFailures := 0
 	end := 0
 
 	for _, arg := range osArgs {
+		// Special-case printing a shell completion script
+		if strings.HasPrefix(arg, "--completions=") {
+			return completionsImpl(osArgs, arg[len("--completions="):])
+		}
+
+		// Special-case printing the flag metadata
+		if arg == "--flags-json" {
+			return flagsJSONImpl()
+		}
+
 		// Special-case running a server
 		if arg == "--serve" || strings.HasPrefix(arg, "--serve=") || strings.HasPrefix(arg, "--servedir=") {
 			if err := serveImpl(osArgs); err != nil {
@@ -852,11 +1943,72 @@ func runImpl(osArgs []string) int {
 			continue
 		}
 
+		// Special-case the log file just for our CLI
+		if strings.HasPrefix(arg, "--log-file=") {
+			logFilePath = arg[len("--log-file="):]
+			continue
+		}
+		if strings.HasPrefix(arg, "--log-file-format=") {
+			switch value := arg[len("--log-file-format="):]; value {
+			case "text":
+				logFileFormat = logFileText
+			case "json":
+				logFileFormat = logFileJSON
+			default:
+				logger.PrintMessageToStderr(osArgs, logger.Msg{
+					Kind:  logger.Error,
+					Data:  logger.MsgData{Text: fmt.Sprintf("Invalid value %q in %q", value, arg)},
+					Notes: []logger.MsgData{{Text: cli_helpers.ValidValuesNote(equalsFlagValues["log-file-format"])}},
+				})
+				return 1
+			}
+			continue
+		}
+
+		// Special-case when to stop watching just for our CLI
+		if strings.HasPrefix(arg, "--watch=") {
+			switch value := arg[len("--watch="):]; value {
+			case "forever":
+				watchOnce = false
+			case "once":
+				watchOnce = true
+			default:
+				logger.PrintMessageToStderr(osArgs, logger.Msg{
+					Kind:  logger.Error,
+					Data:  logger.MsgData{Text: fmt.Sprintf("Invalid value %q in %q", value, arg)},
+					Notes: []logger.MsgData{{Text: cli_helpers.ValidValuesNote(equalsFlagValues["watch"])}},
+				})
+				return 1
+			}
+			arg = "--watch"
+		} else if strings.HasPrefix(arg, "--watch-max-failures=") {
+			value := arg[len("--watch-max-failures="):]
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				logger.PrintErrorToStderr(osArgs, fmt.Sprintf(
+					"Invalid value %q in %q (expected a positive integer)", value, arg))
+				return 1
+			}
+			watchMaxFailures = n
+			continue
+		}
+
 		osArgs[end] = arg
 		end++
 	}
 	osArgs = osArgs[:end]
 
+	// Mirror all errors and warnings to the log file, if there is one
+	writeLogFileForResult := func(errors []api.Message, warnings []api.Message) {
+		if logFilePath == "" {
+			return
+		}
+		if err := writeLogFile(logFilePath, logFileFormat, errors, warnings); err != nil {
+			logger.PrintErrorToStderr(osArgs, fmt.Sprintf(
+				"Failed to write to log file: %s", err.Error()))
+		}
+	}
+
 	buildOptions, metafile, transformOptions, err := parseOptionsForRun(osArgs)
 
 	switch {
@@ -877,6 +2029,12 @@ func runImpl(osArgs []string) int {
 			}
 		}
 
+		// Also read "DENO_DIR" from the environment so "npm:" specifiers can be
+		// found in the same cache that Deno uses, unless it's been overridden
+		if buildOptions.DenoDir == "" {
+			buildOptions.DenoDir = os.Getenv("DENO_DIR")
+		}
+
 		// Read from stdin when there are no entry points
 		if len(buildOptions.EntryPoints)+len(buildOptions.EntryPointsAdvanced) == 0 {
 			if buildOptions.Stdin == nil {
@@ -947,13 +2105,6 @@ func runImpl(osArgs []string) int {
 					}
 				}
 			}
-
-			// Write out the metafile whenever we rebuild
-			if buildOptions.Watch != nil {
-				buildOptions.Watch.OnRebuild = func(result api.BuildResult) {
-					writeMetafile(result.Metafile)
-				}
-			}
 		}
 
 		// Always generate a metafile if we're analyzing, even if it won't be written out
@@ -961,6 +2112,55 @@ func runImpl(osArgs []string) int {
 			buildOptions.Metafile = true
 		}
 
+		// In watch mode, the metafile and the log file are written at the end of
+		// every build. This is done in an "onEnd" callback instead of in
+		// "OnRebuild" so that it's always finished by the time watching stops.
+		// This also decides when watch mode should end on its own.
+		watchExitCode := make(chan int, 1)
+		if buildOptions.Watch != nil {
+			buildCount := 0
+			failureCount := 0
+			buildOptions.Plugins = append(buildOptions.Plugins, api.Plugin{
+				Name: "watch",
+				Setup: func(build api.PluginBuild) {
+					build.OnEnd(func(result *api.BuildResult) {
+						if writeMetafile != nil {
+							writeMetafile(result.Metafile)
+						}
+						writeLogFileForResult(result.Errors, result.Warnings)
+
+						// Only consecutive failures count toward the limit
+						buildCount++
+						if len(result.Errors) > 0 {
+							failureCount++
+						} else {
+							failureCount = 0
+						}
+
+						exitCode := -1
+						if watchMaxFailures > 0 && failureCount >= watchMaxFailures {
+							exitCode = 1
+						} else if watchOnce && buildCount > 1 {
+							if len(result.Errors) > 0 {
+								exitCode = 1
+							} else {
+								exitCode = 0
+							}
+						}
+						if exitCode != -1 {
+							select {
+							case watchExitCode <- exitCode:
+							default:
+							}
+						}
+					})
+				},
+			})
+		} else if watchMaxFailures > 0 {
+			logger.PrintErrorToStderr(osArgs, "Cannot use \"--watch-max-failures\" without \"--watch\"")
+			return 1
+		}
+
 		// Run the build
 		result := api.Build(*buildOptions)
 
@@ -975,15 +2175,31 @@ func runImpl(osArgs []string) int {
 			os.Stderr.WriteString("\n")
 		}
 
+		// Keep watching until we're told to stop or until watch mode ends on its
+		// own. A rebuild that's in progress is allowed to finish first so that
+		// output files are never left partially written.
+		if buildOptions.Watch != nil {
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+			exitCode := 0
+			select {
+			case <-signals:
+			case exitCode = <-watchExitCode:
+			}
+			signal.Stop(signals)
+			if result.Stop != nil {
+				result.Stop()
+			}
+			return exitCode
+		}
+
 		// Write the metafile to the file system
 		if writeMetafile != nil {
 			writeMetafile(result.Metafile)
 		}
 
-		// Do not exit if we're in watch mode
-		if buildOptions.Watch != nil {
-			<-make(chan bool)
-		}
+		// Write the log file to the file system
+		writeLogFileForResult(result.Errors, result.Warnings)
 
 		// Stop if there were errors
 		if len(result.Errors) > 0 {
@@ -1001,6 +2217,7 @@ func runImpl(osArgs []string) int {
 
 		// Run the transform and stop if there were errors
 		result := api.Transform(string(bytes), *transformOptions)
+		writeLogFileForResult(result.Errors, result.Warnings)
 		if len(result.Errors) > 0 {
 			return 1
 		}
@@ -1027,6 +2244,8 @@ func parseServeOptionsImpl(osArgs []string) (api.ServeOptions, []string, error)
 	host := ""
 	portText := "0"
 	servedir := ""
+	proxy := ""
+	var throttle []api.ServeThrottle
 
 	// Filter out server-specific flags
 	filteredArgs := make([]string, 0, len(osArgs))
@@ -1037,6 +2256,14 @@ func parseServeOptionsImpl(osArgs []string) (api.ServeOptions, []string, error)
 			portText = arg[len("--serve="):]
 		} else if strings.HasPrefix(arg, "--servedir=") {
 			servedir = arg[len("--servedir="):]
+		} else if strings.HasPrefix(arg, "--serve-fallback-proxy=") {
+			proxy = arg[len("--serve-fallback-proxy="):]
+		} else if strings.HasPrefix(arg, "--serve-throttle:") {
+			rule, err := parseServeThrottle(arg)
+			if err != nil {
+				return api.ServeOptions{}, nil, err
+			}
+			throttle = append(throttle, rule)
 		} else {
 			filteredArgs = append(filteredArgs, arg)
 		}
@@ -1064,9 +2291,48 @@ func parseServeOptionsImpl(osArgs []string) (api.ServeOptions, []string, error)
 		Port:     uint16(port),
 		Host:     host,
 		Servedir: servedir,
+		Throttle: throttle,
+		Proxy:    proxy,
 	}, filteredArgs, nil
 }
 
+// The value is a comma-separated list of a latency such as "300ms" and/or a
+// bandwidth such as "50kb/s", for example "--serve-throttle:/js/*=300ms,50kb/s"
+func parseServeThrottle(arg string) (api.ServeThrottle, error) {
+	value := arg[len("--serve-throttle:"):]
+	equals := strings.IndexByte(value, '=')
+	if equals == -1 {
+		return api.ServeThrottle{}, fmt.Errorf("Missing \"=\" in %q", arg)
+	}
+	rule := api.ServeThrottle{Pattern: value[:equals]}
+
+	for _, part := range strings.Split(value[equals+1:], ",") {
+		text, scale := part, 0
+		switch {
+		case strings.HasSuffix(part, "ms"):
+			text = part[:len(part)-len("ms")]
+		case strings.HasSuffix(part, "kb/s"):
+			text, scale = part[:len(part)-len("kb/s")], 1024
+		case strings.HasSuffix(part, "mb/s"):
+			text, scale = part[:len(part)-len("mb/s")], 1024*1024
+		case strings.HasSuffix(part, "b/s"):
+			text, scale = part[:len(part)-len("b/s")], 1
+		default:
+			return api.ServeThrottle{}, fmt.Errorf("Invalid throttle %q in %q (expected a latency such as \"300ms\" or a bandwidth such as \"50kb/s\")", part, arg)
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 0 {
+			return api.ServeThrottle{}, fmt.Errorf("Invalid throttle %q in %q", part, arg)
+		}
+		if scale == 0 {
+			rule.LatencyInMS = n
+		} else {
+			rule.BytesPerSecond = n * scale
+		}
+	}
+	return rule, nil
+}
+
 func serveImpl(osArgs []string) error {
 	serveOptions, filteredArgs, err := parseServeOptionsImpl(osArgs)
 	if err != nil {
@@ -1084,6 +2350,30 @@ func serveImpl(osArgs []string) error {
 		return errors.New(err.Text)
 	}
 
+	// Serve stdin when there are no entry points. Unlike with a normal build,
+	// this isn't done if stdin is a terminal because serving only the files in
+	// "servedir" without any entry points is also useful. The resolve directory
+	// is left empty so that it defaults to the serve directory.
+	if len(options.EntryPoints)+len(options.EntryPointsAdvanced) == 0 {
+		if options.Stdin != nil || !logger.GetTerminalInfo(os.Stdin).IsTTY {
+			bytes, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("Could not read from stdin: %s", err.Error())
+			}
+			if len(bytes) > 0 || options.Stdin != nil {
+				if options.Stdin == nil {
+					options.Stdin = &api.StdinOptions{}
+				}
+				options.Stdin.Contents = string(bytes)
+			}
+		}
+	} else if options.Stdin != nil {
+		if options.Stdin.Sourcefile != "" {
+			return errors.New("\"sourcefile\" only applies when reading from stdin")
+		}
+		return errors.New("\"loader\" without extension only applies when reading from stdin")
+	}
+
 	serveOptions.OnRequest = func(args api.ServeOnRequestArgs) {
 		logger.PrintText(os.Stderr, logger.LevelInfo, filteredArgs, func(colors logger.Colors) string {
 			statusColor := colors.Red
@@ -1098,8 +2388,25 @@ func serveImpl(osArgs []string) error {
 		})
 	}
 
-	result, err := api.Serve(serveOptions, options)
-	if err != nil {
+	// Combining "--serve" with "--watch" uses a build context so that files
+	// are rebuilt when they change instead of only when they are requested.
+	// This also reloads HTML pages in "servedir" after each successful rebuild.
+	var result api.ServeResult
+	if watch := options.Watch; watch != nil {
+		options.Watch = nil
+		ctx, ctxErr := api.Context(options)
+		if ctxErr != nil {
+			return ctxErr
+		}
+		if result, err = ctx.Serve(serveOptions); err != nil {
+			ctx.Dispose()
+			return err
+		}
+		if err := ctx.Watch(*watch); err != nil {
+			ctx.Dispose()
+			return err
+		}
+	} else if result, err = api.Serve(serveOptions, options); err != nil {
 		return err
 	}
 
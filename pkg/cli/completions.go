@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/logger"
+)
+
+func completionsImpl(osArgs []string, shell string) int {
+	var script string
+	switch shell {
+	case "bash":
+		script = bashCompletions()
+	case "zsh":
+		script = zshCompletions()
+	case "fish":
+		script = fishCompletions()
+	case "powershell":
+		script = powershellCompletions()
+	default:
+		logger.PrintErrorToStderr(osArgs, fmt.Sprintf(
+			"Invalid shell %q for \"--completions\" (valid shells are bash, zsh, fish, and powershell)", shell))
+		return 1
+	}
+	os.Stdout.WriteString(script)
+	return 0
+}
+
+// Flags that take a value end with "=" or ":" so that the value can be typed
+// immediately after completing the flag name
+func allCompletionFlags() (flagsWithValues []string, flagsWithoutValues []string) {
+	for name := range bareFlags {
+		flagsWithoutValues = append(flagsWithoutValues, "--"+name)
+	}
+	for name := range equalsFlags {
+		flagsWithValues = append(flagsWithValues, "--"+name+"=")
+	}
+	for name := range colonFlags {
+		flagsWithValues = append(flagsWithValues, "--"+name+":")
+	}
+	flagsWithoutValues = append(flagsWithoutValues, "--help", "--version")
+	sort.Strings(flagsWithValues)
+	sort.Strings(flagsWithoutValues)
+	return
+}
+
+type completionValueCase struct {
+	pattern string
+	values  string
+}
+
+// Returns a glob pattern and a space-separated list of values for each flag
+// with enumerated values. Patterns for flags with a ":" come first since
+// they are more specific.
+func completionValueCases() (cases []completionValueCase) {
+	for _, name := range sortedKeys(colonFlagValues) {
+		cases = append(cases, completionValueCase{
+			pattern: "--" + name + ":*=*",
+			values:  strings.Join(sortedValues(colonFlagValues[name]), " "),
+		})
+	}
+	for _, name := range sortedKeys(equalsFlagValues) {
+		cases = append(cases, completionValueCase{
+			pattern: "--" + name + "=*",
+			values:  strings.Join(sortedValues(equalsFlagValues[name]), " "),
+		})
+	}
+	return
+}
+
+func sortedValues(values []string) []string {
+	sorted := append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func sortedKeys(values map[string][]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func bashCompletions() string {
+	flagsWithValues, flagsWithoutValues := allCompletionFlags()
+	sb := strings.Builder{}
+	sb.WriteString(`# esbuild completions for bash. Load them with:
+#
+#   source <(esbuild --completions=bash)
+#
+_esbuild() {
+  local cur="${COMP_LINE:0:COMP_POINT}"
+  cur="${cur##* }"
+  local values=""
+  case "$cur" in
+`)
+	for _, c := range completionValueCases() {
+		sb.WriteString(fmt.Sprintf("    %s) values=%q ;;\n", c.pattern, c.values))
+	}
+	sb.WriteString(fmt.Sprintf(`  esac
+  if [[ -n "$values" ]]; then
+    COMPREPLY=($(compgen -P "${cur%%%%=*}=" -W "$values" -- "${cur#*=}"))
+  elif [[ "$cur" == -* ]]; then
+    COMPREPLY=($(compgen -W %q -- "$cur"))
+  else
+    COMPREPLY=($(compgen -f -- "$cur"))
+  fi
+
+  # Bash may split the current word at "=" and ":", in which case only the
+  # part after the last split must be replaced
+  local word="${COMP_WORDS[COMP_CWORD]}"
+  local strip="${cur%%"$word"}"
+  if [[ -n "$strip" ]]; then
+    COMPREPLY=("${COMPREPLY[@]#"$strip"}")
+  fi
+  if [[ ${#COMPREPLY[@]} -eq 1 && "${COMPREPLY[0]}" == *[=:] ]]; then
+    compopt -o nospace
+  fi
+}
+complete -o default -F _esbuild esbuild
+`, strings.Join(append(flagsWithValues, flagsWithoutValues...), " ")))
+	return sb.String()
+}
+
+func zshCompletions() string {
+	flagsWithValues, flagsWithoutValues := allCompletionFlags()
+	sb := strings.Builder{}
+	sb.WriteString(`#compdef esbuild
+# esbuild completions for zsh. Load them with:
+#
+#   source <(esbuild --completions=zsh)
+#
+_esbuild() {
+  case "$PREFIX" in
+`)
+	for _, c := range completionValueCases() {
+		sb.WriteString(fmt.Sprintf("    %s) compset -P '*='; compadd -- %s ;;\n", c.pattern, c.values))
+	}
+	sb.WriteString(fmt.Sprintf(`    -*)
+      compadd -S '' -- %s
+      compadd -- %s
+      ;;
+    *) _files ;;
+  esac
+}
+compdef _esbuild esbuild
+`, strings.Join(flagsWithValues, " "), strings.Join(flagsWithoutValues, " ")))
+	return sb.String()
+}
+
+func fishCompletions() string {
+	flagsWithValues, flagsWithoutValues := allCompletionFlags()
+	sb := strings.Builder{}
+	sb.WriteString(`# esbuild completions for fish. Load them with:
+#
+#   esbuild --completions=fish | source
+#
+function __esbuild_complete
+  set -l cur (commandline -ct)
+  set -l prefix (string replace -r '=.*' '=' -- $cur)
+  switch $cur
+`)
+	for _, c := range completionValueCases() {
+		sb.WriteString(fmt.Sprintf("    case '%s'\n      for value in %s; echo $prefix$value; end\n", c.pattern, c.values))
+	}
+	sb.WriteString(fmt.Sprintf(`    case '-*'
+      printf '%%s\n' %s
+  end
+end
+complete -c esbuild -f -a '(__esbuild_complete)'
+complete -c esbuild -n 'not string match -q -- "-*" (commandline -ct)' -F
+`, strings.Join(append(flagsWithValues, flagsWithoutValues...), " ")))
+	return sb.String()
+}
+
+func powershellCompletions() string {
+	flagsWithValues, flagsWithoutValues := allCompletionFlags()
+	quote := func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = "'" + value + "'"
+		}
+		return strings.Join(quoted, ", ")
+	}
+	sb := strings.Builder{}
+	sb.WriteString(`# esbuild completions for PowerShell. Load them with:
+#
+#   esbuild --completions=powershell | Out-String | Invoke-Expression
+#
+Register-ArgumentCompleter -Native -CommandName esbuild -ScriptBlock {
+  param($wordToComplete, $commandAst, $cursorPosition)
+  $values = $null
+  switch -Wildcard ($wordToComplete) {
+`)
+	for _, c := range completionValueCases() {
+		sb.WriteString(fmt.Sprintf("    '%s' { $values = @(%s); break }\n", c.pattern, quote(strings.Split(c.values, " "))))
+	}
+	sb.WriteString(fmt.Sprintf(`  }
+  if ($values) {
+    $prefix = $wordToComplete.Substring(0, $wordToComplete.IndexOf('=') + 1)
+    $candidates = $values | ForEach-Object { $prefix + $_ }
+  } elseif ($wordToComplete -like '-*') {
+    $candidates = @(%s)
+  } else {
+    return
+  }
+  $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+  }
+}
+`, quote(append(flagsWithValues, flagsWithoutValues...))))
+	return sb.String()
+}
@@ -0,0 +1,455 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/internal/cli_helpers"
+)
+
+// The "--config=" flag loads build options from a JSON file. If it's absent,
+// a file called "esbuild.config.json" in the working directory is used if it
+// exists. The keys in the file have the same names as the options in the JS
+// API. Each option is converted to the equivalent command-line flag and these
+// flags are placed before the flags on the command line, so flags on the
+// command line override the config file (or add to it for flags that can be
+// specified multiple times). Relative paths are relative to the working
+// directory just like for command-line flags.
+//
+// The "dev" key holds more options that are only used by the "dev" command
+// (see "dev.go"). They go after the options from the rest of the file.
+
+const defaultConfigFile = "esbuild.config.json"
+
+type configFlagKind uint8
+
+const (
+	configFlagBare        configFlagKind = iota // "true" => "--flag"
+	configFlagBool                              // "true" => "--flag=true"
+	configFlagString                            // "x" => "--flag=x"
+	configFlagList                              // ["a", "b"] => "--flag=a,b"
+	configFlagRepeat                            // ["a", "b"] => "--flag:a --flag:b"
+	configFlagMap                               // {"a": "b"} => "--flag:a=b"
+	configFlagSourceMap                         // "true" => "--flag" and "x" => "--flag=x"
+	configFlagEntryPoints                       // ["a"] => "a" and {"a": "b"} => "a=b"
+)
+
+type configFlag struct {
+	name string
+	kind configFlagKind
+}
+
+var configFlags = map[string]configFlag{
+	"accessList":              {"access-list", configFlagBare},
+	"alias":                   {"alias", configFlagMap},
+	"allowOverwrite":          {"allow-overwrite", configFlagBare},
+	"annotations":             {"annotations", configFlagString},
+	"assetNames":              {"asset-names", configFlagString},
+	"banner":                  {"banner", configFlagMap},
+	"budgets":                 {"budget", configFlagMap},
+	"bundle":                  {"bundle", configFlagBare},
+	"bundleDynamicPaths":      {"bundle-dynamic-paths", configFlagBare},
+	"charset":                 {"charset", configFlagString},
+	"charsetEscape":           {"charset-escape", configFlagList},
+	"chunkMaxSize":            {"chunk-max-size", configFlagString},
+	"chunkMinSize":            {"chunk-min-size", configFlagString},
+	"chunkNames":              {"chunk-names", configFlagString},
+	"chunks":                  {"chunk", configFlagMap},
+	"cjsWrapper":              {"cjs-wrapper", configFlagBare},
+	"color":                   {"color", configFlagBool},
+	"conditions":              {"conditions", configFlagList},
+	"contentManifest":         {"content-manifest", configFlagString},
+	"cspReport":               {"csp-report", configFlagString},
+	"cssOrderReport":          {"css-order-report", configFlagString},
+	"cssLayers":               {"css-layer", configFlagMap},
+	"define":                  {"define", configFlagMap},
+	"denoDir":                 {"deno-dir", configFlagString},
+	"detectWorkspaces":        {"detect-workspaces", configFlagBare},
+	"directoryImports":        {"directory-imports", configFlagString},
+	"drop":                    {"drop", configFlagRepeat},
+	"dualPackage":             {"dual-package", configFlagBare},
+	"dynamicImportLoader":     {"dynamic-import-loader", configFlagString},
+	"entryNames":              {"entry-names", configFlagString},
+	"entryPoints":             {"", configFlagEntryPoints},
+	"external":                {"external", configFlagRepeat},
+	"externalHelpers":         {"external-helpers", configFlagString},
+	"externalRewrite":         {"external-rewrite", configFlagMap},
+	"featureFlags":            {"feature", configFlagMap},
+	"featureReport":           {"feature-report", configFlagString},
+	"footer":                  {"footer", configFlagMap},
+	"format":                  {"format", configFlagString},
+	"formats":                 {"format", configFlagList},
+	"formatAnnotations":       {"annotations", configFlagMap},
+	"globalName":              {"global-name", configFlagString},
+	"globals":                 {"external", configFlagMap},
+	"identifierCharset":       {"charset-identifiers", configFlagString},
+	"identifierNormalization": {"charset-normalization", configFlagString},
+	"ignoreAnnotations":       {"ignore-annotations", configFlagBare},
+	"indent":                  {"indent", configFlagString},
+	"indexExtensions":         {"index-extensions", configFlagList},
+	"inferPure":               {"infer-pure", configFlagBare},
+	"inferTarget":             {"infer-target", configFlagBare},
+	"inject":                  {"inject", configFlagRepeat},
+	"injectCSSLink":           {"inject-css-link", configFlagBare},
+	"isolateChunks":           {"isolate-chunk", configFlagRepeat},
+	"isolatePackages":         {"isolate-package", configFlagRepeat},
+	"jsx":                     {"jsx", configFlagString},
+	"jsxFactory":              {"jsx-factory", configFlagString},
+	"jsxFragment":             {"jsx-fragment", configFlagString},
+	"jsxImportSource":         {"jsx-import-source", configFlagString},
+	"keepNames":               {"keep-names", configFlagBare},
+	"lazyPackages":            {"lazy-package", configFlagRepeat},
+	"legalComments":           {"legal-comments", configFlagString},
+	"lineEnding":              {"line-ending", configFlagString},
+	"loader":                  {"loader", configFlagMap},
+	"logLevel":                {"log-level", configFlagString},
+	"logLimit":                {"log-limit", configFlagString},
+	"magicComments":           {"magic-comments", configFlagString},
+	"mainFields":              {"main-fields", configFlagList},
+	"metafile":                {"metafile", configFlagString},
+	"minify":                  {"minify", configFlagBare},
+	"minifyIdentifiers":       {"minify-identifiers", configFlagBare},
+	"mangleCache":             {"mangle-cache", configFlagString},
+	"mangleProps":             {"mangle-props", configFlagString},
+	"minifySeed":              {"minify-seed", configFlagString},
+	"nameCache":               {"name-cache", configFlagString},
+	"minifySyntax":            {"minify-syntax", configFlagBare},
+	"minifyWhitespace":        {"minify-whitespace", configFlagBare},
+	"moduleMap":               {"module-map", configFlagBare},
+	"nodePolyfills":           {"node-polyfills", configFlagBare},
+	"onConflict":              {"on-conflict", configFlagString},
+	"outExtension":            {"out-extension", configFlagMap},
+	"outbase":                 {"outbase", configFlagString},
+	"outdir":                  {"outdir", configFlagString},
+	"outfile":                 {"outfile", configFlagString},
+	"packages":                {"packages", configFlagString},
+	"platform":                {"platform", configFlagString},
+	"pragmas":                 {"pragma", configFlagRepeat},
+	"precacheManifest":        {"precache-manifest", configFlagString},
+	"preserveComments":        {"preserve-comments", configFlagString},
+	"preserveModules":         {"preserve-modules", configFlagBare},
+	"preserveSymlinks":        {"preserve-symlinks", configFlagBare},
+	"publicPath":              {"public-path", configFlagString},
+	"pure":                    {"pure", configFlagRepeat},
+	"record":                  {"record", configFlagString},
+	"recoverSyntaxErrors":     {"recover-syntax-errors", configFlagBare},
+	"reserveProps":            {"reserve-props", configFlagString},
+	"rewriteImports":          {"rewrite-imports", configFlagBare},
+	"resolveExtensions":       {"resolve-extensions", configFlagList},
+	"serviceWorker":           {"service-worker", configFlagString},
+	"sharedChunkDir":          {"shared-chunk-dir", configFlagString},
+	"skipUnchanged":           {"skip-unchanged", configFlagBare},
+	"sourceRoot":              {"source-root", configFlagString},
+	"sourcemap":               {"sourcemap", configFlagSourceMap},
+	"sourcemapBanners":        {"sourcemap-banners", configFlagBare},
+	"sourcesContent":          {"sources-content", configFlagBool},
+	"splitting":               {"splitting", configFlagBare},
+	"splittingPreset":         {"splitting-preset", configFlagString},
+	"strictCase":              {"strict-case", configFlagBare},
+	"stripBetween":            {"strip-between", configFlagMap},
+	"stripIf":                 {"strip-if", configFlagRepeat},
+	"supported":               {"supported", configFlagMap},
+	"target":                  {"target", configFlagList},
+	"treeShakeMembers":        {"tree-shake-members", configFlagBare},
+	"treeShaking":             {"tree-shaking", configFlagBool},
+	"tsEnums":                 {"ts-enums", configFlagString},
+	"tsVersion":               {"ts-version", configFlagString},
+	"tsconfig":                {"tsconfig", configFlagString},
+	"typeCheck":               {"type-check", configFlagString},
+	"unusedExports":           {"unused-exports", configFlagString},
+	"watch":                   {"watch", configFlagBare},
+	"workspaces":              {"workspace", configFlagMap},
+}
+
+// These options configure the development server, so they are only allowed
+// in the "dev" section of the config file
+var configDevFlags = map[string]configFlag{
+	"serve":    {"serve", configFlagString},
+	"servedir": {"servedir", configFlagString},
+}
+
+// This removes any "--config=" flag from the arguments and returns the flags
+// from the config file followed by the remaining arguments
+func expandConfigFile(osArgs []string) ([]string, *cli_helpers.ErrorWithNote) {
+	configArgs, _, remainingArgs, err := readConfigFile(osArgs)
+	if err != nil {
+		return nil, err
+	}
+	return append(configArgs, remainingArgs...), nil
+}
+
+// This removes any "--config=" flag from the arguments and returns the flags
+// from the config file, the flags from its "dev" section, and the remaining
+// arguments separately
+func readConfigFile(osArgs []string) (configArgs []string, devArgs []string, remainingArgs []string, err *cli_helpers.ErrorWithNote) {
+	configPath := ""
+	isExplicit := false
+	remainingArgs = make([]string, 0, len(osArgs))
+
+	for _, arg := range osArgs {
+		if strings.HasPrefix(arg, "--config=") {
+			configPath = arg[len("--config="):]
+			isExplicit = true
+			continue
+		}
+		remainingArgs = append(remainingArgs, arg)
+	}
+
+	// An explicit "--config=" with an empty path disables auto-detection
+	if !isExplicit {
+		if info, err := os.Stat(defaultConfigFile); err != nil || info.IsDir() {
+			return nil, nil, remainingArgs, nil
+		}
+		configPath = defaultConfigFile
+	} else if configPath == "" {
+		return nil, nil, remainingArgs, nil
+	}
+
+	contents, readErr := ioutil.ReadFile(configPath)
+	if readErr != nil {
+		return nil, nil, nil, cli_helpers.MakeErrorWithNote(
+			fmt.Sprintf("Failed to read config file %q: %s", configPath, readErr.Error()), "")
+	}
+
+	configArgs, devArgs, err = configFileToArgs(configPath, contents)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return configArgs, devArgs, remainingArgs, nil
+}
+
+func configFileToArgs(configPath string, contents []byte) ([]string, []string, *cli_helpers.ErrorWithNote) {
+	var options map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &options); err != nil {
+		return nil, nil, cli_helpers.MakeErrorWithNote(
+			fmt.Sprintf("Failed to parse config file %q: %s", configPath, err.Error()),
+			"The config file must contain a JSON object whose keys are the names of options in the JS API.")
+	}
+
+	var devArgs []string
+	if dev, ok := options["dev"]; ok {
+		delete(options, "dev")
+		var devOptions map[string]json.RawMessage
+		if json.Unmarshal(dev, &devOptions) != nil {
+			return nil, nil, cli_helpers.MakeErrorWithNote(
+				fmt.Sprintf("Invalid value for option \"dev\" in config file %q", configPath),
+				"The \"dev\" option must be an object containing the options to use with the \"dev\" command.")
+		}
+		var err *cli_helpers.ErrorWithNote
+		if devArgs, err = configOptionsToArgs(configPath, devOptions, true); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	args, err := configOptionsToArgs(configPath, options, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return args, devArgs, nil
+}
+
+func configOptionsToArgs(configPath string, options map[string]json.RawMessage, isDev bool) ([]string, *cli_helpers.ErrorWithNote) {
+	// Sort the keys so the generated flags don't depend on map iteration order
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		flag, ok := configFlags[key]
+		if !ok && isDev {
+			flag, ok = configDevFlags[key]
+		}
+		if !ok {
+			return nil, cli_helpers.MakeErrorWithNote(
+				fmt.Sprintf("Invalid option %q in config file %q", key, configPath),
+				"The keys in the config file must be the names of options in the JS API, such as \"entryPoints\" or \"outdir\".")
+		}
+		flagArgs, ok := configValueToArgs(flag, options[key])
+		if !ok {
+			return nil, cli_helpers.MakeErrorWithNote(
+				fmt.Sprintf("Invalid value for option %q in config file %q", key, configPath),
+				configKindNote(flag.kind))
+		}
+		args = append(args, flagArgs...)
+	}
+	return args, nil
+}
+
+func configValueToArgs(flag configFlag, value json.RawMessage) ([]string, bool) {
+	switch flag.kind {
+	case configFlagBare:
+		var b bool
+		if json.Unmarshal(value, &b) != nil {
+			return nil, false
+		}
+		if b {
+			return []string{"--" + flag.name}, true
+		}
+		return nil, true
+
+	case configFlagBool:
+		var b bool
+		if json.Unmarshal(value, &b) != nil {
+			return nil, false
+		}
+		return []string{fmt.Sprintf("--%s=%t", flag.name, b)}, true
+
+	case configFlagString:
+		if s, ok := configScalarToString(value); ok {
+			return []string{fmt.Sprintf("--%s=%s", flag.name, s)}, true
+		}
+		return nil, false
+
+	case configFlagList:
+		if s, ok := configScalarToString(value); ok {
+			return []string{fmt.Sprintf("--%s=%s", flag.name, s)}, true
+		}
+		var list []string
+		if json.Unmarshal(value, &list) != nil {
+			return nil, false
+		}
+		for _, item := range list {
+			if strings.ContainsRune(item, ',') {
+				return nil, false
+			}
+		}
+		return []string{fmt.Sprintf("--%s=%s", flag.name, strings.Join(list, ","))}, true
+
+	case configFlagRepeat:
+		var list []string
+		if json.Unmarshal(value, &list) != nil {
+			return nil, false
+		}
+		args := make([]string, 0, len(list))
+		for _, item := range list {
+			args = append(args, fmt.Sprintf("--%s:%s", flag.name, item))
+		}
+		return args, true
+
+	case configFlagMap:
+		m, ok := configObjectToStrings(value)
+		if !ok {
+			return nil, false
+		}
+		args := make([]string, 0, len(m))
+		for _, key := range sortedStringKeys(m) {
+			if strings.ContainsRune(key, '=') {
+				return nil, false
+			}
+			args = append(args, fmt.Sprintf("--%s:%s=%s", flag.name, key, m[key]))
+		}
+		return args, true
+
+	case configFlagSourceMap:
+		var b bool
+		if json.Unmarshal(value, &b) == nil {
+			if b {
+				return []string{"--" + flag.name}, true
+			}
+			return nil, true
+		}
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			return nil, false
+		}
+		return []string{fmt.Sprintf("--%s=%s", flag.name, s)}, true
+
+	case configFlagEntryPoints:
+		var list []string
+		if json.Unmarshal(value, &list) == nil {
+			for _, item := range list {
+				if strings.HasPrefix(item, "-") {
+					return nil, false
+				}
+			}
+			return list, true
+		}
+		m, ok := configObjectToStrings(value)
+		if !ok {
+			return nil, false
+		}
+		args := make([]string, 0, len(m))
+		for _, key := range sortedStringKeys(m) {
+			if strings.HasPrefix(key, "-") || strings.ContainsRune(key, '=') {
+				return nil, false
+			}
+			args = append(args, key+"="+m[key])
+		}
+		return args, true
+	}
+
+	return nil, false
+}
+
+// Numbers are allowed anywhere strings are since flags are strings anyway.
+// Note that "json.Number" preserves the original text of the number.
+func configScalarToString(value json.RawMessage) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var v interface{}
+	if decoder.Decode(&v) != nil {
+		return "", false
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return fmt.Sprintf("%t", v), true
+	}
+	return "", false
+}
+
+func configObjectToStrings(value json.RawMessage) (map[string]string, bool) {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(value, &raw) != nil || raw == nil {
+		return nil, false
+	}
+	result := make(map[string]string, len(raw))
+	for key, item := range raw {
+		s, ok := configScalarToString(item)
+		if !ok {
+			return nil, false
+		}
+		result[key] = s
+	}
+	return result, true
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func configKindNote(kind configFlagKind) string {
+	switch kind {
+	case configFlagBare, configFlagBool:
+		return "This option must be a boolean."
+	case configFlagString:
+		return "This option must be a string."
+	case configFlagList:
+		return "This option must be a string or an array of strings that don't contain commas."
+	case configFlagRepeat:
+		return "This option must be an array of strings."
+	case configFlagMap:
+		return "This option must be an object whose values are strings."
+	case configFlagSourceMap:
+		return "This option must be a boolean or a string."
+	case configFlagEntryPoints:
+		return "This option must be an array of strings or an object whose values are strings."
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package cli
+
+import "github.com/evanw/esbuild/internal/logger"
+
+// The "dev" command (or the "--dev" flag) is a preset for local development.
+// It serves the output files with live reload and rebuilds them whenever an
+// input file changes, which is the same as passing "--serve" and "--watch".
+// It also uses settings that make the output easy to debug.
+//
+// Flags are applied in this order, so later ones override earlier ones:
+//
+//   - Options from the config file, except for the flags that enable minification
+//   - The flags from this preset
+//   - Options from the "dev" section of the config file
+//   - Flags on the command line
+var devPresetArgs = []string{
+	"--serve",
+	"--watch",
+	"--sourcemap=inline",
+	"--define:process.env.NODE_ENV=\"development\"",
+}
+
+// Other minify-related flags such as "--minify-seed=" are kept since they
+// don't do anything unless minification is enabled on the command line
+var devIgnoredConfigArgs = map[string]bool{
+	"--minify":             true,
+	"--minify-identifiers": true,
+	"--minify-syntax":      true,
+	"--minify-whitespace":  true,
+}
+
+func devImpl(osArgs []string) int {
+	configArgs, devArgs, osArgs, err := readConfigFile(osArgs)
+	if err != nil {
+		msg := logger.Msg{
+			Kind: logger.Error,
+			Data: logger.MsgData{Text: err.Text},
+		}
+		if err.Note != "" {
+			msg.Notes = []logger.MsgData{{Text: err.Note}}
+		}
+		logger.PrintMessageToStderr(osArgs, msg)
+		return 1
+	}
+
+	args := make([]string, 0, len(configArgs)+len(devPresetArgs)+len(devArgs)+len(osArgs))
+	for _, arg := range configArgs {
+		// The config file usually describes the production build, but the output
+		// is never minified during development unless asked for explicitly
+		if devIgnoredConfigArgs[arg] {
+			continue
+		}
+		args = append(args, arg)
+	}
+	args = append(args, devPresetArgs...)
+	args = append(args, devArgs...)
+	args = append(args, osArgs...)
+
+	if err := serveImpl(args); err != nil {
+		logger.PrintErrorToStderr(args, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// This removes the "--dev" flag and returns whether it was present
+func removeDevFlag(osArgs []string) ([]string, bool) {
+	for i, arg := range osArgs {
+		if arg == "--dev" {
+			return append(append([]string{}, osArgs[:i]...), osArgs[i+1:]...), true
+		}
+	}
+	return osArgs, false
+}
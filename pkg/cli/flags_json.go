@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The "--flags-json" flag prints every flag in the tables in "cli_impl.go"
+// along with what kind of value it takes and which commands it applies to.
+// This lets wrapper tools and config file schemas stay in sync with the CLI.
+//
+// The tables below are keyed by flag in the same form used for completions:
+// "--name" for a flag without a value, "--name=" for a flag with a value, and
+// "--name:" for a flag that can be specified multiple times. They only cover
+// flags that the parser can't be asked about because they are handled before
+// the parser runs.
+
+// These start a local HTTP server, and only apply when serving
+var serveOnlyFlags = map[string]bool{
+	"--serve":                 true,
+	"--serve-fallback-proxy=": true,
+	"--serve-throttle:":       true,
+	"--serve=":                true,
+	"--servedir=":             true,
+}
+
+// These print something and exit instead of running a command
+var standaloneFlags = map[string]bool{
+	"--completions=": true,
+	"--flags-json":   true,
+	"--help":         true,
+	"--version":      true,
+}
+
+// These are handled by "runImpl" before the other flags are parsed
+var runOnlyFlags = map[string][]string{
+	"--analyze":             {"build", "serve"},
+	"--config=":             {"build", "serve", "transform"},
+	"--log-file-format=":    {"build", "serve", "transform"},
+	"--log-file=":           {"build", "serve", "transform"},
+	"--watch-max-failures=": {"build", "serve"},
+	"--watch=":              {"build", "serve"},
+}
+
+type flagJSON struct {
+	Flag   string `json:"flag"`
+	Name   string `json:"name"`
+	Syntax string `json:"syntax"`
+	Type   string `json:"type"`
+
+	// For a flag with a ":" that only takes a key, these are the valid keys
+	Values []string `json:"values,omitempty"`
+
+	AppliesTo []string `json:"appliesTo"`
+}
+
+func flagsJSONImpl() int {
+	var flags []flagJSON
+	add := func(flag string, name string, syntax string, valueType string, values []string) {
+		appliesTo := []string{}
+		switch {
+		case standaloneFlags[flag]:
+		case serveOnlyFlags[flag]:
+			appliesTo = append(appliesTo, "serve")
+		case runOnlyFlags[flag] != nil:
+			appliesTo = append(appliesTo, runOnlyFlags[flag]...)
+		default:
+			build, transform := flagAppliesTo(exampleArg(flag, valueType, values))
+			if build {
+				appliesTo = append(appliesTo, "build", "serve")
+			}
+			if transform {
+				appliesTo = append(appliesTo, "transform")
+			}
+		}
+		flags = append(flags, flagJSON{
+			Flag:      flag,
+			Name:      name,
+			Syntax:    syntax,
+			Type:      valueType,
+			Values:    values,
+			AppliesTo: appliesTo,
+		})
+	}
+
+	for name := range bareFlags {
+		add("--"+name, name, "bare", "boolean", nil)
+	}
+	add("--help", "help", "bare", "boolean", nil)
+	add("--version", "version", "bare", "boolean", nil)
+
+	for name := range equalsFlags {
+		valueType := "string"
+		values := equalsFlagValues[name]
+		if listFlags[name] {
+			valueType = "list"
+		} else if values != nil {
+			valueType = "enum"
+		} else if integerFlags[name] {
+			valueType = "integer"
+		}
+		add("--"+name+"=", name, "equals", valueType, values)
+	}
+
+	for name := range colonFlags {
+		valueType := "key"
+		if colonFlagsWithValue[name] {
+			valueType = "key=value"
+		} else if colonFlagsWithOptionalValue[name] {
+			valueType = "key[=value]"
+		}
+		values := colonFlagValues[name]
+		if keys := colonFlagKeys[name]; keys != nil {
+			values = keys
+		}
+		add("--"+name+":", name, "colon", valueType, values)
+	}
+
+	sort.Slice(flags, func(i int, j int) bool {
+		return flags[i].Flag < flags[j].Flag
+	})
+	bytes, _ := json.MarshalIndent(struct {
+		Flags []flagJSON `json:"flags"`
+	}{
+		Flags: flags,
+	}, "", "  ")
+	os.Stdout.Write(append(bytes, '\n'))
+	return 0
+}
+
+// This returns an argument that uses the flag with a value of the right type
+func exampleArg(flag string, valueType string, values []string) string {
+	switch {
+	case values != nil && strings.Contains(valueType, "=value"):
+		return flag + "x=" + values[0]
+	case values != nil:
+		return flag + values[0]
+	case valueType == "integer":
+		return flag + "1"
+	case valueType == "key=value" || valueType == "key[=value]":
+		return flag + "x=y"
+	case strings.HasSuffix(flag, "=") || strings.HasSuffix(flag, ":"):
+		return flag + "x"
+	default:
+		return flag
+	}
+}
+
+// This asks the parser whether the command line accepts an argument when
+// building and when transforming. The argument doesn't have to be valid since
+// the parser only reports "Invalid build flag" or "Invalid transform flag" for
+// flags that don't apply.
+func flagAppliesTo(arg string) (build bool, transform bool) {
+	buildOpts := newBuildOptions()
+	err, _ := parseOptionsImpl([]string{arg}, &buildOpts, nil, kindInternal)
+	build = err == nil || !strings.HasPrefix(err.Text, "Invalid build flag:")
+
+	transformOpts := newTransformOptions()
+	err, _ = parseOptionsImpl([]string{arg}, nil, &transformOpts, kindInternal)
+	transform = err == nil || !strings.HasPrefix(err.Text, "Invalid transform flag:")
+	return
+}
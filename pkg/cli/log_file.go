@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// The "--log-file" flag writes all errors and warnings from a build to a file
+// in addition to printing them to stderr. This is useful in CI where capturing
+// stderr can be awkward. Every message is written regardless of the log level
+// and the log limit, since those only exist to keep the terminal readable.
+
+type logFileFormat uint8
+
+const (
+	logFileText logFileFormat = iota
+	logFileJSON
+)
+
+type logFileLocation struct {
+	File       string `json:"file"`
+	Namespace  string `json:"namespace"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	Length     int    `json:"length"`
+	LineText   string `json:"lineText"`
+	Suggestion string `json:"suggestion"`
+}
+
+type logFileNote struct {
+	Text     string           `json:"text"`
+	Location *logFileLocation `json:"location"`
+}
+
+type logFileMessage struct {
+	PluginName string           `json:"pluginName"`
+	Text       string           `json:"text"`
+	Location   *logFileLocation `json:"location"`
+	Notes      []logFileNote    `json:"notes"`
+}
+
+func encodeLogFileLocation(loc *api.Location) *logFileLocation {
+	if loc == nil {
+		return nil
+	}
+	return &logFileLocation{
+		File:       loc.File,
+		Namespace:  loc.Namespace,
+		Line:       loc.Line,
+		Column:     loc.Column,
+		Length:     loc.Length,
+		LineText:   loc.LineText,
+		Suggestion: loc.Suggestion,
+	}
+}
+
+func encodeLogFileMessages(msgs []api.Message) []logFileMessage {
+	values := make([]logFileMessage, len(msgs))
+	for i, msg := range msgs {
+		notes := make([]logFileNote, len(msg.Notes))
+		for j, note := range msg.Notes {
+			notes[j] = logFileNote{
+				Text:     note.Text,
+				Location: encodeLogFileLocation(note.Location),
+			}
+		}
+		values[i] = logFileMessage{
+			PluginName: msg.PluginName,
+			Text:       msg.Text,
+			Location:   encodeLogFileLocation(msg.Location),
+			Notes:      notes,
+		}
+	}
+	return values
+}
+
+func logFileContents(format logFileFormat, errors []api.Message, warnings []api.Message) []byte {
+	switch format {
+	case logFileJSON:
+		bytes, _ := json.MarshalIndent(struct {
+			Errors   []logFileMessage `json:"errors"`
+			Warnings []logFileMessage `json:"warnings"`
+		}{
+			Errors:   encodeLogFileMessages(errors),
+			Warnings: encodeLogFileMessages(warnings),
+		}, "", "  ")
+		return append(bytes, '\n')
+
+	default:
+		sb := strings.Builder{}
+		for _, text := range api.FormatMessages(errors, api.FormatMessagesOptions{Kind: api.ErrorMessage}) {
+			sb.WriteString(text)
+		}
+		for _, text := range api.FormatMessages(warnings, api.FormatMessagesOptions{Kind: api.WarningMessage}) {
+			sb.WriteString(text)
+		}
+		return []byte(sb.String())
+	}
+}
+
+// The log file is written to a temporary file and then renamed over the final
+// path so that something watching the log file never observes a partial write
+func writeLogFile(path string, format logFileFormat, errors []api.Message, warnings []api.Message) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+
+	_, err = file.Write(logFileContents(format, errors, warnings))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
@@ -295,6 +295,18 @@ function upper(text) {
   return text[0].toUpperCase() + text.slice(1)
 }
 
+// These names are used by the "supported" setting to override the tables
+function featureName(x) {
+  if (x === 'BigInt') return 'bigint'
+  return x.replace(/([a-z])([A-Z])/g, '$1-$2').toLowerCase()
+}
+
+function writeFeatureNames(features) {
+  const names = features.map(x => [JSON.stringify(featureName(x)) + ':', x])
+  const maxLength = names.reduce((a, [b]) => Math.max(a, b.length + 1), 0)
+  return names.sort(([a], [b]) => a < b ? -1 : a > b ? 1 : 0).map(([name, x]) => `\t${name.padEnd(maxLength)}${x},`).join('\n')
+}
+
 function writeInnerMap(obj) {
   const keys = Object.keys(obj).sort()
   const maxLength = keys.reduce((a, b) => Math.max(a, b.length + 1), 0)
@@ -336,6 +348,10 @@ func (features JSFeature) Has(feature JSFeature) bool {
 \treturn (features & feature) != 0
 }
 
+var StringToJSFeature = map[string]JSFeature{
+${writeFeatureNames(Object.keys(versions))}
+}
+
 var jsTable = map[JSFeature]map[Engine][]versionRange{
 ${Object.keys(versions).sort().map(x => `\t${x}: ${writeInnerMap(versions[x])},`).join('\n')}
 }
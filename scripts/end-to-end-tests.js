@@ -16,6 +16,8 @@
   const { default: { buildBinary, dirname, removeRecursiveSync } } = await import('./esbuild.js')
   const assert = await import('assert')
   const path = await import('path')
+  const http = await import('http')
+  const net = await import('net')
   const util = await import('util')
   const url = await import('url')
   const fs = (await import('fs')).promises
@@ -5382,6 +5384,775 @@
     }),
   )
 
+  // Test loading options from a config file
+  tests.push(
+    test([], {
+      'esbuild.config.json': `{ "entryPoints": ["in.js"], "bundle": true, "outfile": "node.js", "define": { "VALUE": "123" } }`,
+      'in.js': `if (VALUE !== 123) throw 'fail'`,
+    }),
+    test(['--define:VALUE=234'], {
+      'esbuild.config.json': `{ "entryPoints": ["in.js"], "bundle": true, "outfile": "node.js", "define": { "VALUE": "123" } }`,
+      'in.js': `if (VALUE !== 234) throw 'fail'`,
+    }),
+    test(['--config=build.json', '--outfile=node.js'], {
+      'build.json': `{ "entryPoints": ["in.js"], "format": "cjs", "banner": { "js": "const bannerDefined = true;" } }`,
+      'in.js': `if (!bannerDefined) throw 'fail'`,
+    }),
+    test(['in.js', '--outfile=node.js', '--config='], {
+      'esbuild.config.json': `{ "define": { "VALUE": "123" } }`,
+      'in.js': `if (typeof VALUE !== 'undefined') throw 'fail'`,
+    }),
+    test(['in.js', '--outfile=node.js'], {
+      'esbuild.config.json': `{ "minify": "yes" }`,
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid value for option "minify" in config file "esbuild.config.json"
+
+  This option must be a boolean.
+
+`,
+    }),
+  )
+
+  // Tests for "esbuild analyze"
+  tests.push(
+    testCommands([
+      ['entry.js', '--bundle', '--metafile=meta.json', '--outfile=out.js', '--log-level=warning'],
+      ['analyze', 'meta.json', '--color=false'],
+    ], {
+      'entry.js': `import "./file.js"; console.log(1)`,
+      'file.js': `console.log("file")`,
+    }, `  out.js       84b   100.0%
+   ├ file.js   23b    27.4%
+   └ entry.js  18b    21.4%
+`),
+
+    // A file named "analyze" is still an entry point
+    test(['analyze', '--outfile=node.js'], {
+      'analyze': `console.log(1)`,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Do not know how to load path: analyze
+
+`,
+    }),
+  )
+
+  // A file named "replay" is still an entry point
+  tests.push(
+    test(['replay', '--outfile=node.js'], {
+      'replay': `console.log(1)`,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Do not know how to load path: replay
+
+`,
+    }),
+  )
+
+  // Tests for "--completions"
+  tests.push(
+    test(['in.js', '--completions=nope'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid shell "nope" for "--completions" (valid shells are bash, zsh, fish, and powershell)
+
+`,
+    }),
+  )
+  if (process.platform !== 'win32') {
+    tests.push(
+      testBashCompletions('esbuild --form', ['--format=']),
+      testBashCompletions('esbuild --minify-w', ['--minify-whitespace']),
+      testBashCompletions('esbuild --minify', ['--minify-seed=', '--minify', '--minify-identifiers', '--minify-syntax', '--minify-whitespace']),
+      testBashCompletions('esbuild in.js --format=e', ['esm']),
+      testBashCompletions('esbuild --platform=n', ['neutral', 'node']),
+      testBashCompletions('esbuild --loader:.png=fi', ['file']),
+      testBashCompletions('esbuild --supported:bigint=t', ['true']),
+      testBashCompletions('esbuild --completions=', ['=bash', '=fish', '=powershell', '=zsh']),
+      testBashCompletions('esbuild --define:DEBUG=f', []),
+
+      // These values come from the parser, so they can't fall behind it
+      testBashCompletions('esbuild --loader:.png=i', ['image']),
+      testBashCompletions('esbuild --loader=w', ['webmanifest']),
+      testBashCompletions('esbuild --on-conflict=r', ['rename']),
+      testBashCompletions('esbuild --directory-imports=n', ['no-index', 'node-esm']),
+      testBashCompletions('esbuild --line-ending=c', ['crlf']),
+    )
+  }
+
+  // Tests for "--flags-json"
+  tests.push(async () => {
+    try {
+      const { stdout } = await execFileAsync(esbuildPath, ['--flags-json'], { stdio: 'pipe' })
+      const { flags } = JSON.parse(stdout)
+
+      // Flags are sorted and unique, and each one is spelled the way its syntax says
+      const names = flags.map(flag => flag.flag)
+      assert.deepStrictEqual(names, [...new Set(names)].sort())
+      for (const flag of flags) {
+        assert.strictEqual(flag.flag, '--' + flag.name + { bare: '', equals: '=', colon: ':' }[flag.syntax])
+      }
+
+      // Everything else is checked against what the parser actually does with
+      // each flag instead of against a copy of the tables used to generate it
+      const dir = path.join(testDir, '' + testCount++)
+      let runCount = 0
+      const run = async (mode, arg) => {
+        const cwd = path.join(dir, '' + runCount++)
+        await fs.mkdir(cwd, { recursive: true })
+        await fs.writeFile(path.join(cwd, 'in.js'), `console.log(1)`)
+        const args = mode === 'build' ? ['in.js', '--outdir=out', '--log-level=warning', arg] : ['--log-level=warning', arg]
+        const promise = execFileAsync(esbuildPath, args, { cwd, stdio: 'pipe' })
+        promise.child.stdin.end()
+        try {
+          return (await promise).stderr
+        } catch (e) {
+          return e.stderr
+        }
+      }
+      const key = flag => flag.flag === '--annotations:' ? 'esm' : 'x'
+      const withValue = (flag, value) => flag.type.includes('=value') ? flag.flag + key(flag) + '=' + value : flag.flag + value
+      const example = flag => flag.values ? withValue(flag, flag.values[0]) :
+        flag.type === 'integer' ? flag.flag + '1' :
+          flag.type.includes('=value') ? flag.flag + key(flag) + '=y' :
+            flag.syntax === 'bare' ? flag.flag : flag.flag + 'x'
+      const isRejected = (stderr, value) => new RegExp(`Invalid [a-z ]+:? "${value}"`).test(stderr)
+      // These are consumed before parsing, and "--bundle" always means building
+      const notCheckedWhenTransforming = ['--analyze', '--bundle', '--watch-max-failures=']
+      const startsWatchMode = arg => arg === '--watch' || arg === '--watch=forever'
+
+      // This runs one command at a time to avoid slowing down timing-sensitive tests
+      for (const flag of flags) {
+        if (flag.appliesTo.length === 0 || flag.appliesTo.join() === 'serve' || startsWatchMode(example(flag))) continue
+        const mode = flag.appliesTo.includes('build') ? 'build' : 'transform'
+
+        // Flags apply to exactly the commands that the parser accepts them for
+        for (const other of ['build', 'transform']) {
+          if (other === 'transform' && notCheckedWhenTransforming.includes(flag.flag)) continue
+          const stderr = await run(other, example(flag))
+          assert.strictEqual(stderr.includes(`Invalid ${other} flag`), !flag.appliesTo.includes(other), `${flag.flag} ${other}: ${stderr}`)
+        }
+
+        // Every listed value is accepted, and flags with listed values reject anything else
+        for (const value of flag.values || []) {
+          if (startsWatchMode(withValue(flag, value))) continue
+          const stderr = await run(mode, withValue(flag, value))
+          assert.strictEqual(isRejected(stderr, value), false, `${flag.flag}${value}: ${stderr}`)
+        }
+        const stderr = await run(mode, withValue(flag, 'bogus'))
+        if (flag.values || flag.type === 'integer') {
+          assert.strictEqual(isRejected(stderr, 'bogus'), true, `${flag.flag}bogus: ${stderr}`)
+        } else {
+          assert.strictEqual(/Valid values are|The only valid value/.test(stderr), false, `${flag.flag}bogus: ${stderr}`)
+        }
+      }
+      removeRecursiveSync(dir)
+    } catch (e) {
+      console.error(`❌ test failed: ${e && e.message || e}
+  args: --flags-json`)
+      return false
+    }
+
+    return true
+  })
+
+  // Tests for "--serve-throttle"
+  tests.push(
+    testDev(['dev', 'slow.js', 'fast.js', '--outdir=out', '--serve-throttle:/slow.*=300ms,1kb/s'], {
+      'slow.js': `console.log(${JSON.stringify('x'.repeat(200))})`,
+      'fast.js': `console.log(${JSON.stringify('x'.repeat(200))})`,
+    }, async fetch => {
+      let start = Date.now()
+      const slow = await fetch('/slow.js')
+      assert.strictEqual(slow.startsWith('console.log("xxx'), true)
+      assert.strictEqual(Date.now() - start >= 300 + 100, true)
+
+      // Paths that don't match a rule aren't slowed down
+      start = Date.now()
+      await fetch('/fast.js')
+      assert.strictEqual(Date.now() - start < 300, true)
+    }),
+    test(['in.js', '--serve=127.0.0.1:0', '--serve-throttle:/in.js=300'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid throttle "300" in "--serve-throttle:/in.js=300" (expected a latency such as "300ms" or a bandwidth such as "50kb/s")
+
+`,
+    }),
+    test(['in.js', '--serve=127.0.0.1:0', '--serve-throttle:in.js=300ms'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid throttle pattern: "in.js" (expected a URL path such as "/assets/*")
+
+`,
+    }),
+  )
+
+  // Tests for "--serve-fallback-proxy"
+  {
+    // The backend echoes back the request
+    const startBackend = async () => {
+      const backend = http.createServer((req, res) => {
+        res.writeHead(200, { 'Content-Type': 'text/plain' })
+        res.end(`backend: ${req.method} ${req.url} ${req.headers['x-forwarded-host']}`)
+      })
+      await new Promise(resolve => backend.listen(0, '127.0.0.1', resolve))
+      return backend
+    }
+
+    tests.push(
+      async () => {
+        const backend = await startBackend()
+        try {
+          return await testDev(['dev', 'in.js', '--outdir=out', `--serve-fallback-proxy=http://127.0.0.1:${backend.address().port}`], {
+            'in.js': `console.log(123)`,
+          }, async fetch => {
+            assert.strictEqual((await fetch('/in.js')).startsWith(`console.log(123);\n`), true)
+            const body = await fetch('/api/items?id=1')
+            assert.strictEqual(/^backend: GET \/api\/items\?id=1 127\.0\.0\.1:\d+$/.test(body), true)
+          })()
+        } finally {
+          backend.close()
+        }
+      },
+      test(['in.js', '--serve=127.0.0.1:0', '--serve-fallback-proxy=localhost:3000'], {
+        'in.js': ``,
+      }, {
+        expectedStderr: `${errorIcon} [ERROR] Invalid proxy URL: "localhost:3000" (expected an origin such as "http://localhost:3000")
+
+`,
+      }),
+    )
+  }
+
+  // Tests for "--skip-unchanged"
+  tests.push(
+    testInDir({
+      'a.js': `console.log('a')`,
+      'b.js': `console.log('b')`,
+    }, async (run, dir) => {
+      await run(['a.js', 'b.js', '--outdir=out', '--skip-unchanged', '--log-level=warning'])
+
+      // Move the modification times into the past so rewriting a file is noticeable
+      const past = new Date(2000, 0, 1)
+      await fs.utimes(path.join(dir, 'out', 'a.js'), past, past)
+      await fs.utimes(path.join(dir, 'out', 'b.js'), past, past)
+      await fs.writeFile(path.join(dir, 'b.js'), `console.log('b2')`)
+
+      const { stderr } = await run(['a.js', 'b.js', '--outdir=out', '--skip-unchanged'])
+      assert.strictEqual(stderr.includes('\n  1 changed, 1 unchanged\n'), true)
+      assert.strictEqual((await fs.stat(path.join(dir, 'out', 'a.js'))).mtimeMs, past.getTime())
+      assert.notStrictEqual((await fs.stat(path.join(dir, 'out', 'b.js'))).mtimeMs, past.getTime())
+      assert.strictEqual(await fs.readFile(path.join(dir, 'out', 'b.js'), 'utf8'), `console.log("b2");\n`)
+
+      // Every file is rewritten without the flag
+      const { stderr: stderr2 } = await run(['a.js', 'b.js', '--outdir=out'])
+      assert.strictEqual(stderr2.includes('unchanged'), false)
+      assert.notStrictEqual((await fs.stat(path.join(dir, 'out', 'a.js'))).mtimeMs, past.getTime())
+    }),
+  )
+
+  // Tests for "--log-file"
+  tests.push(
+    testInDir({
+      'in.js': `if (x === -0) console.log(x)`,
+    }, async (run, dir) => {
+      // Messages are written to the log file even when they aren't logged
+      const { stderr } = await run(['in.js', '--outfile=out.js', '--log-level=silent', '--log-file=logs/build.log'])
+      assert.strictEqual(stderr, '')
+      assert.strictEqual(await fs.readFile(path.join(dir, 'logs', 'build.log'), 'utf8'),
+        `▲ [WARNING] Comparison with -0 using the "===" operator will also match 0
+
+    in.js:1:10:
+      1 │ if (x === -0) console.log(x)
+        ╵           ~~
+
+  Floating-point equality is defined such that 0 and -0 are equal, so "x === -0" returns true for both 0 and -0. You need to use "Object.is(x, -0)" instead to test for -0.
+
+`)
+      assert.deepStrictEqual(await fs.readdir(path.join(dir, 'logs')), ['build.log'])
+    }),
+    testInDir({
+      'in.js': `import "./missing"\nif (x === -0) console.log(x)`,
+    }, async (run, dir) => {
+      // The log file is also written when the build fails
+      try {
+        await run(['in.js', '--bundle', '--outfile=out.js', '--log-level=silent', '--log-file=build.json', '--log-file-format=json'])
+        throw new Error('Expected build failure')
+      } catch (e) {
+        if (e.code !== 1) throw e
+      }
+      const { errors, warnings } = JSON.parse(await fs.readFile(path.join(dir, 'build.json'), 'utf8'))
+      assert.strictEqual(errors.length, 1)
+      assert.strictEqual(errors[0].text, 'Could not resolve "./missing"')
+      assert.deepStrictEqual(errors[0].location, {
+        file: 'in.js', namespace: '', line: 1, column: 7, length: 11, lineText: 'import "./missing"', suggestion: '',
+      })
+      assert.strictEqual(warnings.length, 1)
+      assert.strictEqual(warnings[0].text, 'Comparison with -0 using the "===" operator will also match 0')
+      assert.strictEqual(warnings[0].notes.length, 1)
+    }),
+    test(['in.js', '--log-file=build.log', '--log-file-format=xml'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid value "xml" in "--log-file-format=xml"
+
+  Valid values are "text" or "json".
+
+`,
+    }),
+  )
+
+  // The log file is rewritten after each rebuild in watch mode
+  tests.push(
+    testInDir({
+      'in.js': `if (x === -0) console.log(x)`,
+    }, async (run, dir) => {
+      const logPath = path.join(dir, 'build.json')
+      const waitForWarnings = async count => {
+        for (let i = 0; i < 300; i++) {
+          const json = await fs.readFile(logPath, 'utf8').catch(() => null)
+          if (json && JSON.parse(json).warnings.length === count) return
+          await new Promise(resolve => setTimeout(resolve, 100))
+        }
+        throw new Error(`Timed out waiting for ${count} warnings in the log file`)
+      }
+
+      // Watch mode stops when stdin is closed, so keep it open
+      const child = childProcess.spawn(esbuildPath, ['in.js', '--outfile=out.js', '--watch', '--log-level=silent',
+        '--log-file=build.json', '--log-file-format=json'], { cwd: dir, stdio: ['pipe', 'pipe', 'pipe'] })
+      try {
+        await waitForWarnings(1)
+        await fs.writeFile(path.join(dir, 'in.js'), `console.log(x)`)
+        await waitForWarnings(0)
+      } finally {
+        child.kill()
+      }
+    }),
+  )
+
+  // Tests for how watch mode exits
+  {
+    // Watch mode stops when stdin is closed, so keep it open
+    const spawnWatch = async (dir, args) => {
+      await fs.mkdir(path.join(dir, 'tmp'))
+      const child = childProcess.spawn(esbuildPath, args, { cwd: dir, stdio: ['pipe', 'pipe', 'pipe'] })
+      let stderr = ''
+      child.stderr.on('data', data => stderr += data)
+      let exitCode = null
+      child.on('close', code => exitCode = code)
+
+      // Kill the child on a timeout so that a failed test doesn't leave it running
+      const waitFor = async (condition, what) => {
+        for (let i = 0; i < 300; i++) {
+          if (condition()) return
+          await new Promise(resolve => setTimeout(resolve, 100))
+        }
+        child.kill()
+        throw new Error(`Timed out waiting for ${what}:\n${stderr}`)
+      }
+      const waitForBuilds = count => waitFor(() => stderr.split('[watch] build finished').length > count, `${count} builds`)
+      const waitForExit = async () => {
+        await waitFor(() => exitCode !== null, 'esbuild to exit')
+        return exitCode
+      }
+      return { child, waitForBuilds, waitForExit }
+    }
+
+    // Files are replaced atomically so that a rebuild never sees a partially
+    // written file. The temporary file is in a directory that esbuild doesn't
+    // read so that creating it doesn't cause an extra rebuild.
+    const replaceFile = async (dir, file, contents) => {
+      const temp = path.join(dir, 'tmp', file)
+      await fs.writeFile(temp, contents)
+      await fs.rename(temp, path.join(dir, file))
+    }
+
+    if (process.platform !== 'win32') {
+      for (const signal of ['SIGINT', 'SIGTERM']) {
+        tests.push(
+          testInDir({
+            'in.js': `console.log(1)`,
+          }, async (run, dir) => {
+            const { child, waitForBuilds, waitForExit } = await spawnWatch(dir, ['in.js', '--outfile=out.js', '--watch'])
+            await waitForBuilds(1)
+            child.kill(signal)
+            assert.strictEqual(await waitForExit(), 0)
+          }),
+        )
+      }
+    }
+
+    tests.push(
+      // Watching once exits after the first rebuild, and the metafile is
+      // written before exiting
+      testInDir({
+        'in.js': `console.log(1)`,
+        'other.js': `console.log(2)`,
+      }, async (run, dir) => {
+        const { waitForBuilds, waitForExit } = await spawnWatch(dir, ['in.js', '--outfile=out.js', '--bundle', '--metafile=meta.json', '--watch=once'])
+        await waitForBuilds(1)
+        await replaceFile(dir, 'in.js', `import './other.js'`)
+        assert.strictEqual(await waitForExit(), 0)
+        const { inputs } = JSON.parse(await fs.readFile(path.join(dir, 'meta.json'), 'utf8'))
+        assert.deepStrictEqual(Object.keys(inputs).sort(), ['in.js', 'other.js'])
+      }),
+      testInDir({
+        'in.js': `console.log(1)`,
+      }, async (run, dir) => {
+        const { waitForBuilds, waitForExit } = await spawnWatch(dir, ['in.js', '--outfile=out.js', '--watch=once'])
+        await waitForBuilds(1)
+        await replaceFile(dir, 'in.js', `console.log(`)
+        assert.strictEqual(await waitForExit(), 1)
+      }),
+
+      // Only failures in a row count toward the limit
+      testInDir({
+        'in.js': `console.log(`,
+      }, async (run, dir) => {
+        const { child, waitForBuilds, waitForExit } = await spawnWatch(dir, ['in.js', '--outfile=out.js', '--watch', '--watch-max-failures=2'])
+        await waitForBuilds(1)
+        await replaceFile(dir, 'in.js', `console.log(1)`)
+        await waitForBuilds(2)
+        await replaceFile(dir, 'in.js', `console.log(2`)
+        await waitForBuilds(3)
+        assert.strictEqual(child.exitCode, null)
+        await replaceFile(dir, 'in.js', `console.log(3`)
+        assert.strictEqual(await waitForExit(), 1)
+      }),
+
+      test(['in.js', '--watch=sometimes'], {
+        'in.js': ``,
+      }, {
+        expectedStderr: `${errorIcon} [ERROR] Invalid value "sometimes" in "--watch=sometimes"
+
+  Valid values are "forever" or "once".
+
+`,
+      }),
+      test(['in.js', '--watch', '--watch-max-failures=0'], {
+        'in.js': ``,
+      }, {
+        expectedStderr: `${errorIcon} [ERROR] Invalid value "0" in "--watch-max-failures=0" (expected a positive integer)
+
+`,
+      }),
+      test(['in.js', '--watch-max-failures=2'], {
+        'in.js': ``,
+      }, {
+        expectedStderr: `${errorIcon} [ERROR] Cannot use "--watch-max-failures" without "--watch"
+
+`,
+      }),
+    )
+  }
+
+  // Tests for "--supported" and "--supported-file"
+  tests.push(
+    // The file overrides the target in both directions
+    testInDir({
+      'in.js': `let a = { ...b }, c = 1n`,
+      'tv.json': `{ "object-rest-spread": false, "bigint": true }`,
+    }, async run => {
+      const { stdout } = await run(['in.js', '--target=es2019', '--supported-file=tv.json', '--log-level=warning'])
+      assert.strictEqual(stdout.endsWith(`let a = __spreadValues({}, b), c = 1n;\n`), true)
+    }),
+
+    // Overrides also work without a target, and "--supported:" overrides the file
+    testInDir({
+      'in.js': `let f = () => 1`,
+      'tv.json': `{ "arrow": true }`,
+    }, async run => {
+      const unsupported = await run(['in.js', '--supported:arrow=false', '--log-level=warning'])
+      assert.strictEqual(unsupported.stdout, `let f = function() {\n  return 1;\n};\n`)
+      for (const args of [['--supported:arrow=false', '--supported-file=tv.json'], ['--supported-file=tv.json', '--supported:arrow=false']]) {
+        const overridden = await run(['in.js', ...args, '--log-level=warning'])
+        assert.strictEqual(overridden.stdout, `let f = function() {\n  return 1;\n};\n`)
+      }
+    }),
+
+    // The JSON error message comes from Go and differs between Go versions
+    testInDir({
+      'in.js': ``,
+      'bad.json': `{ "arrow": "no" }`,
+    }, async run => {
+      try {
+        await run(['in.js', '--supported-file=bad.json'])
+        throw new Error('Expected an error')
+      } catch (e) {
+        if (!e.stderr) throw e
+        assert.strictEqual(e.stderr.startsWith(`${errorIcon} [ERROR] Failed to parse feature support file "bad.json": json: `), true)
+        assert.strictEqual(e.stderr.includes(`\n  The file must contain a JSON object that maps feature names to true or false.\n`), true)
+      }
+    }),
+
+    test(['in.js', '--supported-file=missing.json'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Failed to read feature support file "missing.json": ${process.platform === 'win32' ? 'The system cannot find the file specified.' : 'no such file or directory'}
+
+`,
+    }),
+    test(['in.js', '--supported:nope=true'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid feature name: "nope"
+
+`,
+    }),
+    test(['in.js', '--supported:arrow=yes'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Invalid value "yes" in "--supported:arrow=yes"
+
+  Valid values are "true" or "false".
+
+`,
+    }),
+    test(['in.js', '--supported:arrow'], {
+      'in.js': ``,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Missing "=" in "--supported:arrow"
+
+  You need to use "=" to specify whether the feature is supported. For example, "--supported:bigint=false" marks the "bigint" feature as unsupported.
+
+`,
+    }),
+  )
+
+  // Tests for "--name-cache"
+  tests.push(
+    // The name cache isn't an output file, so it doesn't stop the output from
+    // being written to stdout
+    testInDir({
+      'in.js': `function helper() { return 1 }\nconsole.log(helper())`,
+    }, async (run, dir) => {
+      for (const args of [[], ['--outfile=-']]) {
+        const { stdout } = await run(['in.js', '--bundle', '--minify', '--name-cache=nc.json', '--log-level=warning'].concat(args))
+        assert.strictEqual(stdout, `(()=>{function a(){return 1}console.log(a());})();\n`)
+        const cache = JSON.parse(await fs.readFile(path.join(dir, 'nc.json'), 'utf8'))
+        assert.strictEqual(cache['in.js:helper'], 'a')
+        await fs.unlink(path.join(dir, 'nc.json'))
+      }
+    }),
+  )
+
+  // Tests for "--mangle-cache"
+  tests.push(
+    // The mangle cache isn't an output file either
+    testInDir({
+      'in.js': `console.log({ foo_: 1 }.foo_)`,
+    }, async (run, dir) => {
+      for (const args of [[], ['--outfile=-']]) {
+        const { stdout } = await run(['in.js', '--mangle-props=_$', '--mangle-cache=mc.json', '--log-level=warning'].concat(args))
+        assert.strictEqual(stdout, `console.log({ a: 1 }.a);\n`)
+        assert.deepStrictEqual(JSON.parse(await fs.readFile(path.join(dir, 'mc.json'), 'utf8')), { foo_: 'a' })
+        await fs.unlink(path.join(dir, 'mc.json'))
+      }
+    }),
+  )
+
+  // Tests for "--access-list"
+  tests.push(
+    testInDir({
+      'src/in.js': `import 'dep'; import './util'`,
+      'src/util.js': ``,
+      'node_modules/dep/package.json': `{ "main": "main.js" }`,
+      'node_modules/dep/main.js': `console.log(1)`,
+    }, async (run, dir) => {
+      await run(['src/in.js', '--bundle', '--outfile=out.js', '--metafile=meta.json', '--access-list', '--log-level=warning'])
+
+      // Leave out the parent directories of the test directory, which differ between machines
+      const { accessed } = JSON.parse(await fs.readFile(path.join(dir, 'meta.json'), 'utf8'))
+      const local = Object.entries(accessed).filter(([key]) => !key.startsWith('..'))
+      assert.deepStrictEqual(Object.fromEntries(local), {
+        '.': 'directory',
+        'node_modules': 'directory',
+        'node_modules/dep': 'directory',
+        'node_modules/dep/main.js': 'file',
+        'node_modules/dep/package.json': 'file',
+        'src': 'directory',
+        'src/in.js': 'file',
+        'src/util.js': 'file',
+      })
+
+      // The metafile is unchanged without "--access-list"
+      await run(['src/in.js', '--bundle', '--outfile=out.js', '--metafile=meta.json', '--log-level=warning'])
+      assert.strictEqual('accessed' in JSON.parse(await fs.readFile(path.join(dir, 'meta.json'), 'utf8')), false)
+    }),
+  )
+
+  // Tests for "--node-polyfills"
+  tests.push(
+    test(['in.js', '--bundle', '--outfile=node.js', '--platform=browser', '--node-polyfills'], {
+      'in.js': `
+        import { Buffer as ImportedBuffer } from 'buffer'
+        if (Buffer !== ImportedBuffer || Buffer === globalThis.Buffer) throw 'fail: global'
+        const check = (actual, expected) => {
+          if (actual !== expected) throw 'fail: ' + actual + ' !== ' + expected
+        }
+        const throws = (callback, expected) => {
+          try { callback() } catch (e) { return check(e.message, expected) }
+          throw 'fail: expected an error'
+        }
+
+        // Strings
+        check(Buffer.from('héllo').toString('hex'), '68c3a96c6c6f')
+        check(Buffer.from('68c3a96c6c6f', 'hex').toString(), 'héllo')
+        check(Buffer.from('héllo').toString('base64'), 'aMOpbGxv')
+        check(Buffer.from('aMOpbGxv', 'base64').toString(), 'héllo')
+        check(Buffer.from([0xfb, 0xff]).toString('base64url'), '-_8')
+        check(Buffer.from('hi', 'utf16le').toString('hex'), '68006900')
+        check(Buffer.byteLength('héllo'), 6)
+        check(JSON.stringify(Buffer.from('ab')), '{"type":"Buffer","data":[97,98]}')
+
+        // Reading and writing integers
+        const buf = Buffer.alloc(16)
+        check(buf.writeUInt32BE(0xdeadbeef, 0), 4)
+        check(buf.readUInt32LE(0), 0xefbeadde)
+        check(buf.readUInt16BE(1), 0xadbe)
+        check(buf.readInt16BE(0), -8531)
+        check(buf.readInt8(0), -34)
+        check(buf.readUInt8(0), 0xde)
+        check(buf.readUIntBE(0, 3), 0xdeadbe)
+        check(buf.readUintLE(0, 3), 0xbeadde)
+        check(buf.writeIntLE(-123456, 4, 3), 7)
+        check(buf.readIntLE(4, 3), -123456)
+        check(buf.writeUIntBE(0x123456789abc, 8, 6), 14)
+        check(buf.readUIntBE(8, 6), 0x123456789abc)
+        check(buf.writeInt32LE(-2, 0), 4)
+        check(buf.readInt32LE(0), -2)
+        check(buf.readUInt32LE(0), 0xfffffffe)
+
+        // Reading and writing other numbers
+        check(buf.writeDoubleLE(1.5, 0), 8)
+        check(buf.readDoubleLE(0), 1.5)
+        check(buf.writeFloatBE(-0.25, 8), 12)
+        check(buf.readFloatBE(8), -0.25)
+        check(buf.writeBigInt64BE(-5n, 8), 16)
+        check(buf.readBigInt64BE(8), -5n)
+        check(buf.readBigUInt64BE(8), 0xfffffffffffffffbn)
+
+        // Out of range offsets and values
+        throws(() => buf.readUInt32LE(13), 'The value of "offset" is out of range. It must be >= 0 and <= 12. Received 13')
+        throws(() => buf.writeUInt8(256), 'The value of "value" is out of range. It must be >= 0 and <= 255. Received 256')
+        throws(() => buf.writeInt16LE(-32769), 'The value of "value" is out of range. It must be >= -32768 and <= 32767. Received -32769')
+        throws(() => buf.readUIntLE(0, 7), 'The value of "byteLength" is out of range. It must be >= 1 and <= 6. Received 7')
+        throws(() => Buffer.alloc(2).readUInt32LE(0), 'Attempt to access memory outside buffer bounds')
+
+        // Other methods
+        check(Buffer.concat([Buffer.from('ab'), Buffer.from('cd')]).toString(), 'abcd')
+        check(Buffer.compare(Buffer.from('a'), Buffer.from('b')), -1)
+        check(Buffer.from('abcabc').indexOf('bc'), 1)
+        check(Buffer.from('abcabc').lastIndexOf('bc'), 4)
+        check(Buffer.from('abc').subarray(1).toString(), 'bc')
+        check(Buffer.isBuffer(Buffer.from('abc').slice(1)), true)
+        check(Buffer.alloc(5, 'ab').toString(), 'ababa')
+        check(Buffer.of(1, 2, 3, 4).swap16().toString('hex'), '02010403')
+        check(Buffer.of(1, 2, 3, 4).swap32().toString('hex'), '04030201')
+        throws(() => Buffer.of(1, 2, 3).swap16(), 'Buffer size must be a multiple of 16-bits')
+      `,
+    }),
+    test(['in.js', '--bundle', '--outfile=node.js', '--platform=browser', '--node-polyfills'], {
+      'in.js': `
+        import EventEmitter, { once } from 'events'
+        const calls = []
+        const emitter = new EventEmitter()
+        emitter.on('x', value => calls.push('on ' + value))
+        emitter.prependListener('x', value => calls.push('first ' + value))
+        emitter.once('x', value => calls.push('once ' + value))
+        if (!emitter.emit('x', 1) || !emitter.emit('x', 2) || emitter.emit('y')) throw 'fail: emit'
+        if (calls.join() !== 'first 1,on 1,once 1,first 2,on 2') throw 'fail: ' + calls.join()
+        if (emitter.listenerCount('x') !== 2) throw 'fail: listenerCount'
+        emitter.removeAllListeners('x')
+        if (emitter.listenerCount('x') !== 0) throw 'fail: removeAllListeners'
+        try {
+          emitter.emit('error', new Error('boom'))
+          throw 'fail: error'
+        } catch (e) {
+          if (e.message !== 'boom') throw e
+        }
+        const promise = once(emitter, 'done')
+        emitter.emit('done', 1, 2)
+        promise.then(args => { if (args.join() !== '1,2') throw 'fail: once' })
+      `,
+    }),
+    test(['in.js', '--bundle', '--outfile=node.js', '--platform=browser', '--node-polyfills'], {
+      'in.js': `
+        import path from 'path'
+        const check = (actual, expected) => {
+          if (actual !== expected) throw 'fail: ' + actual + ' !== ' + expected
+        }
+        check(path.join('/a/b', '../c', './d.js'), '/a/c/d.js')
+        check(path.normalize('a//b/../c/'), 'a/c/')
+        check(path.resolve('/a', 'b', '../c'), '/a/c')
+        check(path.relative('/a/b/c', '/a/d'), '../../d')
+        check(path.dirname('/a/b/c.js'), '/a/b')
+        check(path.basename('/a/b/c.js', '.js'), 'c')
+        check(path.extname('c.test.js'), '.js')
+        check(path.isAbsolute('a/b'), false)
+        check(path.format(path.parse('/a/b/c.js')), '/a/b/c.js')
+        check(path.posix, path)
+        check(path.sep, '/')
+      `,
+    }),
+    test(['in.js', '--bundle', '--outfile=node.js', '--platform=browser', '--node-polyfills'], {
+      'in.js': `
+        if (process.platform !== 'browser' || process.browser !== true) throw 'fail: global'
+        if (typeof process.env.HOME !== 'undefined' || process.cwd() !== '/') throw 'fail: env'
+        const [seconds, nanoseconds] = process.hrtime()
+        if (typeof seconds !== 'number' || typeof nanoseconds !== 'number') throw 'fail: hrtime'
+        let ticked = false
+        process.nextTick((a, b) => { ticked = a + b === 3 }, 1, 2)
+        if (ticked) throw 'fail: nextTick ran synchronously'
+        setTimeout(() => { if (!ticked) throw 'fail: nextTick' }, 10)
+      `,
+    }),
+  )
+
+  // Tests for "esbuild dev"
+  tests.push(
+    testDev(['dev', 'in.js', '--bundle', '--outdir=out'], {
+      'in.js': `console.log(process.env.NODE_ENV)`,
+    }, async fetch => {
+      const js = await fetch('/in.js')
+      assert.strictEqual(js.includes('console.log("development");'), true)
+      assert.strictEqual(js.includes('//# sourceMappingURL=data:application/json;base64,'), true)
+    }),
+    testDev(['--dev', 'in.js', '--bundle', '--outdir=out'], {
+      'in.js': `console.log(process.env.NODE_ENV)`,
+    }, async fetch => {
+      const js = await fetch('/in.js')
+      assert.strictEqual(js.includes('console.log("development");'), true)
+    }),
+
+    // Minification from the config file is ignored, but other minify options are kept
+    testDev(['dev'], {
+      'esbuild.config.json': `{ "entryPoints": ["in.js"], "bundle": true, "outdir": "out", "minify": true, "minifySeed": "abc" }`,
+      'in.js': `function foo() { let alpha = 1, beta = 2; return alpha + beta }\nconsole.log(foo())`,
+    }, async fetch => {
+      const js = await fetch('/in.js')
+      assert.strictEqual(js.includes('  function foo() {\n    let alpha = 1, beta = 2;\n'), true)
+    }),
+    testDev(['dev', '--minify-identifiers'], {
+      'esbuild.config.json': `{ "entryPoints": ["in.js"], "bundle": true, "outdir": "out", "minify": true, "minifySeed": "abc" }`,
+      'in.js': `function foo() { let alpha = 1, beta = 2; return alpha + beta }\nconsole.log(foo())`,
+    }, async (fetch, dir) => {
+      const js = await fetch('/in.js')
+      const { stdout } = await execFileAsync(esbuildPath,
+        ['in.js', '--bundle', '--minify-identifiers', '--minify-seed=abc', '--config=', '--log-level=warning'], { cwd: dir, stdio: 'pipe' })
+      assert.strictEqual(js.slice(0, js.indexOf('//# sourceMappingURL=')), stdout)
+    }),
+
+    // A file named "dev" is still an entry point
+    test(['dev', '--outfile=node.js'], {
+      'dev': `console.log(1)`,
+    }, {
+      expectedStderr: `${errorIcon} [ERROR] Do not know how to load path: dev
+
+`,
+    }),
+  )
+
   // Test "imports" and "exports" in package.json
   for (const flags of [[], ['--bundle']]) {
     tests.push(
@@ -5816,6 +6587,35 @@
         `,
         'dir1/file.js': `export default 123`,
         'Dir2/file.js': `export default 234`,
+      }, {
+        expectedStderr: `▲ [WARNING] Use "dir1/file.js" instead of "Dir1/file.js" to avoid issues with case-sensitive file systems
+
+    in.js:2:24:
+      2 │           import x from "./Dir1/file.js"
+        ╵                         ~~~~~~~~~~~~~~~~
+
+▲ [WARNING] Use "Dir2/file.js" instead of "dir2/file.js" to avoid issues with case-sensitive file systems
+
+    in.js:3:24:
+      3 │           import y from "./dir2/file.js"
+        ╵                         ~~~~~~~~~~~~~~~~
+
+`,
+      }),
+      test(['in.js', '--bundle', '--outfile=node.js', '--strict-case'], {
+        'in.js': `
+          import x from "./File.js"
+          if (x !== 123) throw 'fail'
+        `,
+        'file.js': `export default 123`,
+      }, {
+        expectedStderr: `${errorIcon} [ERROR] Use "file.js" instead of "File.js" to avoid issues with case-sensitive file systems
+
+    in.js:2:24:
+      2 │           import x from "./File.js"
+        ╵                         ~~~~~~~~~~~
+
+`,
       }),
 
       // Warn when importing something inside node_modules
@@ -5856,6 +6656,38 @@
         'node_modules/pkg/file1.js': `export default 123`,
         'node_modules/pkg/File2.js': `export default 234`,
       }),
+
+      // Warn when the same file is imported using paths that differ only in case
+      test(['in.js', '--bundle', '--outfile=node.js'], {
+        'in.js': `
+          import x from "./file.js"
+          import y from "./File.js"
+          if (x !== 123 || y !== 123) throw 'fail'
+        `,
+        'file.js': `export default 123`,
+      }, {
+        expectedStderr: `▲ [WARNING] Use "file.js" instead of "File.js" to avoid issues with case-sensitive file systems
+
+    in.js:3:24:
+      3 │           import y from "./File.js"
+        ╵                         ~~~~~~~~~~~
+
+▲ [WARNING] The paths "file.js" and "File.js" differ only in case
+
+    in.js:3:24:
+      3 │           import y from "./File.js"
+        ╵                         ~~~~~~~~~~~
+
+  The other path was imported here:
+
+    in.js:2:24:
+      2 │           import x from "./file.js"
+        ╵                         ~~~~~~~~~~~
+
+  Both paths are bundled as the same file, which is only correct on case-insensitive file systems. On case-sensitive file systems, these paths don't refer to the same file.
+
+`,
+      }),
     )
   }
 
@@ -5973,6 +6805,157 @@
     }
   }
 
+  // This runs several commands in the same directory one after another and
+  // checks the stdout of the last one
+  function testCommands(commands, files, expectedStdout) {
+    return async () => {
+      const thisTestDir = path.join(testDir, '' + testCount++)
+
+      try {
+        for (const file in files) {
+          const filePath = path.join(thisTestDir, file)
+          await fs.mkdir(path.dirname(filePath), { recursive: true })
+          await fs.writeFile(filePath, files[file])
+        }
+
+        let stdout
+        for (const args of commands) {
+          stdout = (await execFileAsync(esbuildPath, args, { cwd: thisTestDir, stdio: 'pipe' })).stdout
+        }
+        assert.strictEqual(stdout, expectedStdout)
+
+        // Clean up test output
+        removeRecursiveSync(thisTestDir)
+      } catch (e) {
+        console.error(`❌ test failed: ${e && e.message || e}
+  dir: ${path.relative(dirname, thisTestDir)}
+  commands: ${commands.map(args => `\n    ${args.join(' ')}`).join('')}`)
+        return false
+      }
+
+      return true
+    }
+  }
+
+  // This writes the files and then passes a function that runs esbuild in the
+  // test directory to the callback, for tests that need to check more than the
+  // output of a single command
+  function testInDir(files, callback) {
+    return async () => {
+      const thisTestDir = path.join(testDir, '' + testCount++)
+
+      try {
+        for (const file in files) {
+          const filePath = path.join(thisTestDir, file)
+          await fs.mkdir(path.dirname(filePath), { recursive: true })
+          await fs.writeFile(filePath, files[file])
+        }
+
+        const run = args => execFileAsync(esbuildPath, args, { cwd: thisTestDir, stdio: 'pipe' })
+        await callback(run, thisTestDir)
+
+        // Clean up test output
+        removeRecursiveSync(thisTestDir)
+      } catch (e) {
+        console.error(`❌ test failed: ${e && e.message || e}
+  dir: ${path.relative(dirname, thisTestDir)}`)
+        return false
+      }
+
+      return true
+    }
+  }
+
+  // This loads the bash completion script and checks what the completion
+  // function returns for the last word of the command line. Bash splits the
+  // command line into words at "=" and ":" as well as at spaces.
+  function testBashCompletions(line, expected) {
+    return async () => {
+      const words = line.split(' ').flatMap(word => word.split(/([=:])/).filter(Boolean))
+
+      try {
+        const { stdout: script } = await execFileAsync(esbuildPath, ['--completions=bash'], { stdio: 'pipe' })
+        const { stdout } = await execFileAsync('bash', ['-c', script + `
+          COMP_LINE="$1"
+          COMP_POINT=\${#1}
+          shift
+          COMP_WORDS=("$@")
+          COMP_CWORD=$(($# - 1))
+          _esbuild 2>/dev/null
+          printf '%s\\n' "\${COMPREPLY[@]}"
+        `, 'bash', line].concat(words), { stdio: 'pipe' })
+        assert.deepStrictEqual(stdout.split('\n').filter(Boolean), expected)
+      } catch (e) {
+        console.error(`❌ test failed: ${e && e.message || e}
+  line: ${line}`)
+        return false
+      }
+
+      return true
+    }
+  }
+
+  // This runs "esbuild dev" until the first build has finished and then passes
+  // a function that fetches a path from the development server to the callback
+  function testDev(args, files, callback) {
+    return async () => {
+      const thisTestDir = path.join(testDir, '' + testCount++)
+      let child
+
+      try {
+        for (const file in files) {
+          const filePath = path.join(thisTestDir, file)
+          await fs.mkdir(path.dirname(filePath), { recursive: true })
+          await fs.writeFile(filePath, files[file])
+        }
+
+        // Find a free port, since port 0 means the default port
+        const port = await new Promise((resolve, reject) => {
+          const server = net.createServer()
+          server.on('error', reject)
+          server.listen(0, '127.0.0.1', () => {
+            const { port } = server.address()
+            server.close(() => resolve(port))
+          })
+        })
+
+        // Watch mode stops when stdin is closed, so keep it open
+        child = childProcess.spawn(esbuildPath, args.concat(`--serve=127.0.0.1:${port}`), { cwd: thisTestDir, stdio: ['pipe', 'pipe', 'pipe'] })
+        let stderr = ''
+        await new Promise((resolve, reject) => {
+          child.stderr.on('data', data => {
+            stderr += data
+            if (stderr.includes('[watch] build finished')) resolve()
+          })
+          child.on('close', code => reject(new Error(`esbuild exited with code ${code}:\n${stderr}`)))
+        })
+
+        const fetch = urlPath => new Promise((resolve, reject) => {
+          http.get(`http://127.0.0.1:${port}${urlPath}`, res => {
+            let body = ''
+            res.setEncoding('utf8')
+            res.on('data', chunk => body += chunk)
+            res.on('end', () => res.statusCode === 200 ? resolve(body) : reject(new Error(`${urlPath}: ${res.statusCode} ${body}`)))
+          }).on('error', reject)
+        })
+        await callback(fetch, thisTestDir)
+
+        // Clean up test output
+        child.kill()
+        child = null
+        removeRecursiveSync(thisTestDir)
+      } catch (e) {
+        if (child) child.kill()
+        console.error(`❌ test failed: ${e && e.message || e}
+  dir: ${path.relative(dirname, thisTestDir)}
+  args: ${args.join(' ')}`)
+        return false
+      }
+
+      return true
+    }
+  }
+
   // There's a feature where bundling without "outfile" or "outdir" writes to stdout instead
   function testStdout(input, args, callback) {
     return async () => {
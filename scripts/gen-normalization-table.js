@@ -0,0 +1,72 @@
+const fs = require('fs');
+const path = require('path');
+
+// This reads the Unicode character database from "github/ucd", which is
+// downloaded by "make normalization-table". The version must match the one
+// used by "gen-unicode-table.js".
+//
+// UnicodeData.txt: https://www.unicode.org/Public/14.0.0/ucd/UnicodeData.txt
+// CompositionExclusions.txt: https://www.unicode.org/Public/14.0.0/ucd/CompositionExclusions.txt
+const ucdDir = path.join(__dirname, '..', 'github', 'ucd');
+
+function readLines(name) {
+  return fs.readFileSync(path.join(ucdDir, name), 'utf8')
+    .split('\n')
+    .map(line => line.replace(/#.*/, '').trim())
+    .filter(line => line !== '');
+}
+
+// Field 3 is the canonical combining class and field 5 is the decomposition
+// mapping. Compatibility mappings start with a "<tag>" and are ignored since
+// only the canonical normalization forms (NFC and NFD) are supported.
+const combiningClass = new Map();
+const decomposition = new Map();
+for (const line of readLines('UnicodeData.txt')) {
+  const fields = line.split(';');
+  const codePoint = parseInt(fields[0], 16);
+  const ccc = +fields[3];
+  if (ccc !== 0) combiningClass.set(codePoint, ccc);
+  if (fields[5] !== '' && fields[5][0] !== '<') decomposition.set(codePoint, fields[5].split(' ').map(x => parseInt(x, 16)));
+}
+
+// A code point is a primary composite if it has a canonical decomposition and
+// isn't in the "Full_Composition_Exclusion" set. That set is the union of the
+// explicit exclusions, singletons, and non-starter decompositions.
+const excluded = new Set(readLines('CompositionExclusions.txt').map(line => parseInt(line, 16)));
+const composition = [];
+for (const [codePoint, mapping] of decomposition) {
+  if (mapping.length !== 2 || excluded.has(codePoint)) continue;
+  if (combiningClass.has(codePoint) || combiningClass.has(mapping[0])) continue;
+  composition.push([mapping[0], mapping[1], codePoint]);
+}
+composition.sort((x, y) => x[0] - y[0] || x[1] - y[1]);
+
+const hex = n => `0x${n.toString(16).toUpperCase()}`;
+const sortedKeys = map => [...map.keys()].sort((a, b) => a - b);
+
+fs.writeFileSync(path.join(__dirname, '..', 'internal', 'js_lexer', 'normalization_tables.go'),
+  `// This file was automatically generated by ${path.basename(__filename)}. Do not edit.
+package js_lexer
+
+// Each entry is {code point, canonical combining class} sorted by code point.
+// Code points with a canonical combining class of zero are omitted.
+var combiningClasses = [][2]rune{
+${sortedKeys(combiningClass).map(c => `\t{${hex(c)}, ${combiningClass.get(c)}},`).join('\n')}
+}
+
+// Each entry is {code point, first, second} sorted by code point. Canonical
+// decompositions are either one or two code points long. Singleton
+// decompositions use zero for the second code point.
+var canonicalDecompositions = [][3]rune{
+${sortedKeys(decomposition).map(c => {
+    const [a, b] = decomposition.get(c);
+    return `\t{${hex(c)}, ${hex(a)}, ${hex(b || 0)}},`;
+  }).join('\n')}
+}
+
+// Each entry is {first, second, primary composite} sorted by the first and
+// then the second code point
+var canonicalCompositions = [][3]rune{
+${composition.map(([a, b, c]) => `\t{${hex(a)}, ${hex(b)}, ${hex(c)}},`).join('\n')}
+}
+`);
@@ -1254,6 +1254,122 @@ body {
     })
   },
 
+  async metafileCSSBundle({ esbuild, testDir }) {
+    const entry = path.join(testDir, 'entry.js')
+    const imported = path.join(testDir, 'imported.css')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(entry, `import './imported.css'`)
+    await writeFileAsync(imported, `a { color: red }`)
+    const result = await esbuild.build({
+      entryPoints: [entry],
+      bundle: true,
+      outdir,
+      metafile: true,
+      entryNames: '[name]-[hash]',
+      injectCSSLink: true,
+      write: false,
+    })
+
+    const cwd = process.cwd()
+    const makePath = absPath => path.relative(cwd, absPath).split(path.sep).join('/')
+    const [js, css] = result.outputFiles
+    assert(js.path.endsWith('.js'))
+    assert(css.path.endsWith('.css'))
+    assert.strictEqual(result.metafile.outputs[makePath(js.path)].cssBundle, makePath(css.path))
+    assert.strictEqual(result.metafile.outputs[makePath(css.path)].cssBundle, undefined)
+    assert(js.text.includes(`new URL("./${path.basename(css.path)}", document.currentScript`), js.text)
+  },
+
+  async metafileAccessList({ esbuild, testDir }) {
+    const entry = path.join(testDir, 'entry.js')
+    const imported = path.join(testDir, 'imported.js')
+    const watched = path.join(testDir, 'watched.txt')
+    const missing = path.join(testDir, 'missing.txt')
+    await writeFileAsync(entry, `import "./imported"; import "virtual"`)
+    await writeFileAsync(imported, `console.log(1)`)
+    await writeFileAsync(watched, ``)
+    const result = await esbuild.build({
+      entryPoints: [entry],
+      bundle: true,
+      write: false,
+      metafile: true,
+      accessList: true,
+      plugins: [{
+        name: 'virtual',
+        setup(build) {
+          build.onResolve({ filter: /^virtual$/ }, () => ({ path: 'virtual', namespace: 'virtual' }))
+          build.onLoad({ filter: /.*/, namespace: 'virtual' }, () => ({ contents: ``, watchFiles: [watched, missing] }))
+        },
+      }],
+    })
+
+    // Files that plugins asked to watch are inputs of the build too
+    const makePath = absPath => path.relative(process.cwd(), absPath).split(path.sep).join('/')
+    const { accessed } = result.metafile
+    assert.strictEqual(accessed[makePath(testDir)], 'directory')
+    assert.strictEqual(accessed[makePath(entry)], 'file')
+    assert.strictEqual(accessed[makePath(imported)], 'file')
+    assert.strictEqual(accessed[makePath(watched)], 'file')
+    assert.strictEqual(accessed[makePath(missing)], 'missing')
+
+    // The list is only in the metafile when asked for
+    const result2 = await esbuild.build({ entryPoints: [imported], write: false, metafile: true })
+    assert.strictEqual(result2.metafile.accessed, undefined)
+  },
+
+  async nameCacheIsNotAnOutputFile({ esbuild, testDir }) {
+    const entry = path.join(testDir, 'entry.js')
+    const nameCache = path.join(testDir, 'names.json')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(entry, `function helper() { return 1 }\nconsole.log(helper())`)
+    const result = await esbuild.build({
+      entryPoints: [entry],
+      bundle: true,
+      minify: true,
+      nameCache,
+      outdir,
+      metafile: true,
+      write: false,
+    })
+
+    // The name cache is returned separately from the output files
+    assert.strictEqual(result.outputFiles.length, 1)
+    assert.strictEqual(result.outputFiles[0].path, path.join(outdir, 'entry.js'))
+    assert.strictEqual(Object.keys(result.metafile.outputs).length, 1)
+    assert.strictEqual(result.nameCache[path.relative(process.cwd(), entry).split(path.sep).join('/') + ':helper'], 'a')
+    assert.strictEqual(fs.existsSync(nameCache), false)
+
+    // It's still written to disk when the output files are
+    const result2 = await esbuild.build({ entryPoints: [entry], bundle: true, minify: true, nameCache, outdir })
+    assert.deepStrictEqual(JSON.parse(await readFileAsync(nameCache, 'utf8')), result2.nameCache)
+  },
+
+  async mangleCacheIsNotAnOutputFile({ esbuild, testDir }) {
+    const entry = path.join(testDir, 'entry.js')
+    const mangleCache = path.join(testDir, 'mangle.json')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(entry, `console.log({ foo_: 1 }.foo_)`)
+    const result = await esbuild.build({
+      entryPoints: [entry],
+      mangleProps: /_$/,
+      mangleCache,
+      outdir,
+      metafile: true,
+      write: false,
+    })
+
+    // The mangle cache is returned separately from the output files
+    assert.strictEqual(result.outputFiles.length, 1)
+    assert.strictEqual(result.outputFiles[0].text, `console.log({ a: 1 }.a);\n`)
+    assert.strictEqual(Object.keys(result.metafile.outputs).length, 1)
+    assert.deepStrictEqual(result.mangleCache, { foo_: 'a' })
+    assert.strictEqual(fs.existsSync(mangleCache), false)
+
+    // It's still written to disk when the output files are
+    await esbuild.build({ entryPoints: [entry], mangleProps: /_$/, mangleCache, outdir })
+    assert.deepStrictEqual(JSON.parse(await readFileAsync(mangleCache, 'utf8')), { foo_: 'a' })
+  },
+
   async metafileLoaderFileMultipleEntry({ esbuild, testDir }) {
     const entry1 = path.join(testDir, 'entry1.js')
     const entry2 = path.join(testDir, 'entry2.js')
@@ -1355,6 +1471,74 @@ body {
     assert.strictEqual(result.default, 123)
   },
 
+  async onConflictRename({ esbuild, testDir }) {
+    const inputA = path.join(testDir, 'a', 'entry.js')
+    const inputB = path.join(testDir, 'b', 'entry.js')
+    const outdir = path.join(testDir, 'out')
+    await mkdirAsync(path.dirname(inputA), { recursive: true })
+    await mkdirAsync(path.dirname(inputB), { recursive: true })
+    await writeFileAsync(inputA, `console.log('a')`)
+    await writeFileAsync(inputB, `console.log('b')`)
+
+    // Fail without "onConflict"
+    try {
+      await esbuild.build({
+        entryPoints: [inputA, inputB],
+        entryNames: '[name]',
+        outdir,
+        logLevel: 'silent',
+      })
+      throw new Error('Expected build failure');
+    } catch (e) {
+      if (!e || !e.errors || !e.errors.length || !e.errors[0].text.includes('Two output files share the same path'))
+        throw e
+    }
+
+    // Succeed with "onConflict"
+    await esbuild.build({
+      entryPoints: [inputA, inputB],
+      entryNames: '[name]',
+      outdir,
+      onConflict: 'rename',
+    })
+    assert((await readFileAsync(path.join(outdir, 'entry.js'), 'utf8')).includes(`console.log("a")`))
+    assert((await readFileAsync(path.join(outdir, 'entry-2.js'), 'utf8')).includes(`console.log("b")`))
+  },
+
+  async directoryImports({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const index = path.join(testDir, 'lib', 'index.js')
+    await mkdirAsync(path.dirname(index), { recursive: true })
+    await writeFileAsync(input, `import x from './lib'; console.log(x)`)
+    await writeFileAsync(index, `export default 123`)
+
+    // Fail with "node-esm"
+    try {
+      await esbuild.build({
+        entryPoints: [input],
+        bundle: true,
+        write: false,
+        directoryImports: 'node-esm',
+        logLevel: 'silent',
+      })
+      throw new Error('Expected build failure');
+    } catch (e) {
+      if (!e || !e.errors || e.errors.length !== 1 || e.errors[0].text !== 'Could not resolve "./lib"')
+        throw e
+      assert.strictEqual(e.errors[0].notes[0].text, `Importing the directory "${path.relative(process.cwd(), path.dirname(index)).split(path.sep).join('/')}" is not supported in node's ECMAScript module mode`)
+      assert.strictEqual(e.errors[0].notes[1].location.suggestion, '"./lib/index.js"')
+    }
+
+    // Succeed with "cjs"
+    const result = await esbuild.build({
+      entryPoints: [input],
+      bundle: true,
+      write: false,
+      directoryImports: 'cjs',
+    })
+    assert(result.outputFiles[0].text.includes('123'))
+  },
+
   async splittingRelativeSameDir({ esbuild, testDir }) {
     const inputA = path.join(testDir, 'a.js')
     const inputB = path.join(testDir, 'b.js')
@@ -2085,6 +2269,29 @@ require("/assets/file.png");
     assert.strictEqual(code, `/* banner */\ndiv {\n  color: red;\n}\n/* footer */\n`)
   },
 
+  async bannerFooterSourceMapBuild({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const outfile = path.join(testDir, 'out.js')
+    await writeFileAsync(input, `console.log(123)`)
+    const { outputFiles } = await esbuild.build({
+      entryPoints: [input],
+      outfile,
+      sourcemap: true,
+      sourcemapBanners: true,
+      write: false,
+      banner: { js: '/* banner 1 */\n/* banner 2 */' },
+      footer: { js: '/* footer */' },
+    })
+    const [map, js] = outputFiles
+    assert.strictEqual(js.text, `/* banner 1 */\n/* banner 2 */\nconsole.log(123);\n/* footer */\n//# sourceMappingURL=out.js.map\n`)
+    assert.strictEqual(js.bannerLine, 0)
+    assert.strictEqual(js.footerLine, 3)
+    const json = JSON.parse(map.text)
+    assert.deepStrictEqual(json.sources, ['<banner>', 'in.js', '<footer>'])
+    assert.deepStrictEqual(json.sourcesContent, ['/* banner 1 */\n/* banner 2 */', 'console.log(123)', '/* footer */'])
+    assert.strictEqual(json.mappings, 'AAAA;AACA;ACDA,QAAQ,IAAI;ACAZ')
+  },
+
   async buildRelativeIssue693({ esbuild }) {
     const result = await esbuild.build({
       stdin: {
@@ -2152,6 +2359,44 @@ require("/assets/file.png");
     }
   },
 
+  async rebuildUpdate({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const output = path.join(testDir, 'out.js')
+    await writeFileAsync(input, `if (DEBUG) console.log('debug'); debugger`)
+    const result1 = await esbuild.build({
+      entryPoints: [input],
+      outfile: output,
+      format: 'esm',
+      define: { DEBUG: 'true' },
+      incremental: true,
+    })
+    assert.strictEqual(await readFileAsync(output, 'utf8'), `if (true)\n  console.log("debug");\ndebugger;\n`)
+
+    // Changing the defines affects the next build
+    await result1.rebuild.update({ define: { DEBUG: 'false' } })
+    await result1.rebuild()
+    assert.strictEqual(await readFileAsync(output, 'utf8'), `if (false)\n  console.log("debug");\ndebugger;\n`)
+
+    // Options that aren't mentioned are left alone
+    await result1.rebuild.update({ banner: { js: '// banner' }, drop: ['debugger'] })
+    await result1.rebuild()
+    assert.strictEqual(await readFileAsync(output, 'utf8'), `// banner\nif (false)\n  console.log("debug");\n`)
+
+    // An empty object removes all banners
+    await result1.rebuild.update({ banner: {} })
+    await result1.rebuild()
+    assert.strictEqual(await readFileAsync(output, 'utf8'), `if (false)\n  console.log("debug");\n`)
+
+    try {
+      await result1.rebuild.update({ format: 'cjs' })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.message, 'Invalid option in update() call: "format"')
+    }
+
+    result1.rebuild.dispose()
+  },
+
   async rebuildIndependent({ esbuild, testDir }) {
     const inputA = path.join(testDir, 'in-a.js')
     const inputB = path.join(testDir, 'in-b.js')
@@ -2219,6 +2464,79 @@ require("/assets/file.png");
     }
   },
 
+  async rebuildRestoresDeletedOutput({ esbuild, testDir }) {
+    const inputA = path.join(testDir, 'a.js')
+    const inputB = path.join(testDir, 'b.js')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(inputA, `console.log('a')`)
+    await writeFileAsync(inputB, `console.log('b')`)
+    const result = await esbuild.build({
+      entryPoints: [inputA, inputB],
+      outdir,
+      format: 'esm',
+      incremental: true,
+    })
+
+    // Output files that are reused from the previous build must still be
+    // written again if they were deleted in the meantime
+    await fs.promises.unlink(path.join(outdir, 'a.js'))
+    await writeFileAsync(inputB, `console.log('b2')`)
+    await result.rebuild()
+    assert.strictEqual(await readFileAsync(path.join(outdir, 'a.js'), 'utf8'), `console.log("a");\n`)
+    assert.strictEqual(await readFileAsync(path.join(outdir, 'b.js'), 'utf8'), `console.log("b2");\n`)
+
+    result.rebuild.dispose()
+  },
+
+  async rebuildFileLoaderHash({ esbuild, testDir }) {
+    const inputA = path.join(testDir, 'a.js')
+    const inputB = path.join(testDir, 'b.js')
+    const image = path.join(testDir, 'img.png')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(inputA, `import url from './img.png'; console.log(url)`)
+    await writeFileAsync(inputB, `import url from './img.png'; console.log(url)`)
+    await writeFileAsync(image, 'first')
+
+    // Each entry point is linked separately without code splitting, so entry
+    // points that didn't change can be reused when rebuilding
+    const assetFromOutput = async () => {
+      const js = await readFileAsync(path.join(outdir, 'a.js'), 'utf8')
+      const name = /"\.\/(img-[^"]+\.png)"/.exec(js)[1]
+      return { name, contents: await readFileAsync(path.join(outdir, name), 'utf8') }
+    }
+    const result1 = await esbuild.build({
+      entryPoints: [inputA, inputB],
+      outdir,
+      bundle: true,
+      loader: { '.png': 'file' },
+      assetNames: '[name]-[hash]',
+      incremental: true,
+      logLevel: 'silent',
+    })
+    const asset1 = await assetFromOutput()
+    assert.strictEqual(asset1.contents, 'first')
+
+    // Changing the asset must change its hashed name in the reused output
+    await writeFileAsync(image, 'second')
+    await result1.rebuild()
+    const asset2 = await assetFromOutput()
+    assert.notStrictEqual(asset2.name, asset1.name)
+    assert.strictEqual(asset2.contents, 'second')
+
+    // The new name must match the one that a fresh build would generate
+    const result2 = await esbuild.build({
+      entryPoints: [inputA, inputB],
+      outdir,
+      bundle: true,
+      loader: { '.png': 'file' },
+      assetNames: '[name]-[hash]',
+      write: false,
+    })
+    assert(result2.outputFiles.some(file => path.basename(file.path) === asset2.name))
+
+    result1.rebuild.dispose()
+  },
+
   async rebuildParallel({ esbuild, testDir }) {
     const input = path.join(testDir, 'in.js')
     const output = path.join(testDir, 'out.js')
@@ -2414,6 +2732,38 @@ require("/assets/file.png");
     assert.strictEqual(outputFiles[1].path, path.join(testDir, 'entry', 'out', '3KY7NOSR-2.js'))
   },
 
+  async skipUnchanged({ esbuild, testDir }) {
+    const a = path.join(testDir, 'a.js')
+    const b = path.join(testDir, 'b.js')
+    const outdir = path.join(testDir, 'out')
+    await writeFileAsync(a, `console.log('a')`)
+    await writeFileAsync(b, `console.log('b')`)
+    await esbuild.build({ entryPoints: [a, b], outdir, skipUnchanged: true })
+
+    // Move the modification times into the past so rewriting a file is noticeable
+    const past = new Date(2000, 0, 1)
+    fs.utimesSync(path.join(outdir, 'a.js'), past, past)
+    fs.utimesSync(path.join(outdir, 'b.js'), past, past)
+    await writeFileAsync(b, `console.log('b2')`)
+    await esbuild.build({ entryPoints: [a, b], outdir, skipUnchanged: true })
+    assert.strictEqual(fs.statSync(path.join(outdir, 'a.js')).mtimeMs, past.getTime())
+    assert.notStrictEqual(fs.statSync(path.join(outdir, 'b.js')).mtimeMs, past.getTime())
+    assert.strictEqual(await readFileAsync(path.join(outdir, 'b.js'), 'utf8'), `console.log("b2");\n`)
+
+    // Every file is rewritten without the option
+    await esbuild.build({ entryPoints: [a, b], outdir })
+    assert.notStrictEqual(fs.statSync(path.join(outdir, 'a.js')).mtimeMs, past.getTime())
+
+    try {
+      await esbuild.build({ entryPoints: [a], outdir, skipUnchanged: 'yes', logLevel: 'silent' })
+      throw new Error('Expected build failure');
+    } catch (e) {
+      if (!e.errors || !e.errors[0] || e.errors[0].text !== '"skipUnchanged" must be a boolean') {
+        throw e;
+      }
+    }
+  },
+
   async nodeColonPrefixImport({ esbuild }) {
     const tryTargetESM = async target => {
       const result = await esbuild.build({
@@ -2543,6 +2893,7 @@ let watchTests = {
         assert.strictEqual(error2, null)
         assert.strictEqual(result2.outputFiles, void 0)
         assert.strictEqual(result2.stop, result.stop)
+        assert.deepStrictEqual(result2.watchChanges, [{ path: input, kind: 'modified' }])
       }
 
       // Second rebuild: edit
@@ -2951,6 +3302,36 @@ let serveTests = {
     await result.wait;
   },
 
+  async serveStdinWithFallbackDir({ esbuild, testDir }) {
+    const index = path.join(testDir, 'index.html')
+    const lib = path.join(testDir, 'lib.js')
+    await writeFileAsync(index, `<!doctype html><script src="stdin.js"></script>`)
+    await writeFileAsync(lib, `export default 123`)
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      servedir: testDir,
+    }, {
+      stdin: {
+        contents: `import x from './lib'; console.log(x)`,
+      },
+      bundle: true,
+      format: 'esm',
+    })
+
+    // Imports in stdin are resolved relative to the serve directory by default,
+    // but file comments are still relative to the working directory
+    const libComment = path.relative(process.cwd(), lib).split(path.sep).join('/')
+    let buffer = await fetch(result.host, result.port, '/stdin.js')
+    assert.strictEqual(buffer.toString(), `// ${libComment}\nvar lib_default = 123;\n\n// <stdin>\nconsole.log(lib_default);\n`);
+
+    buffer = await fetch(result.host, result.port, '/')
+    assert.strictEqual(buffer.toString(), `<!doctype html><script src="stdin.js"></script>`);
+
+    result.stop();
+    await result.wait;
+  },
+
   async serveRange({ esbuild, testDir }) {
     const big = path.join(testDir, 'big.txt')
     const byteCount = 16 * 1024 * 1024
@@ -2988,6 +3369,287 @@ let serveTests = {
     result.stop();
     await result.wait;
   },
+
+  async serveProjects({ esbuild, testDir }) {
+    const shared = path.join(testDir, 'shared.js')
+    const app1 = path.join(testDir, 'app1.js')
+    const app2 = path.join(testDir, 'app2.js')
+    await writeFileAsync(shared, `export let name = 'shared'`)
+    await writeFileAsync(app1, `import {name} from './shared'; console.log(1, name)`)
+    await writeFileAsync(app2, `import {name} from './shared'; console.log(2, name)`)
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      projects: {
+        '/app1': { entryPoints: [app1], bundle: true, format: 'esm', absWorkingDir: testDir },
+        '/nested/app2': { entryPoints: [app2], bundle: true, format: 'esm', absWorkingDir: testDir },
+      },
+    }, {
+      entryPoints: [shared],
+      format: 'esm',
+    })
+
+    assert.strictEqual((await fetch(result.host, result.port, '/shared.js')).toString(), `let name = "shared";\nexport {\n  name\n};\n`);
+    assert.strictEqual((await fetch(result.host, result.port, '/app1/app1.js')).toString(), `// shared.js\nvar name = "shared";\n\n// app1.js\nconsole.log(1, name);\n`);
+    assert.strictEqual((await fetch(result.host, result.port, '/nested/app2/app2.js')).toString(), `// shared.js\nvar name = "shared";\n\n// app2.js\nconsole.log(2, name);\n`);
+
+    // The main build doesn't see files from the projects
+    try {
+      await fetch(result.host, result.port, '/app1.js')
+      throw new Error('Expected a 404 error')
+    } catch (e) {
+      if (!e.message.startsWith('404 when fetching /app1.js:')) throw e
+    }
+
+    // The root directory listing links to the projects
+    const listing = (await fetch(result.host, result.port, '/')).toString()
+    assert(listing.includes(`<a href="/app1/">app1/</a>`), listing)
+    assert(listing.includes(`<a href="/nested/">nested/</a>`), listing)
+
+    result.stop();
+    await result.wait;
+  },
+
+  async serveStopGraceful({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `console.log(123)`)
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      throttle: [{ pattern: '/in.js', latency: 500 }],
+    }, {
+      entryPoints: [input],
+      format: 'esm',
+    })
+
+    // A graceful stop waits for the active request to finish
+    const active = fetch(result.host, result.port, '/in.js')
+    await new Promise(r => setTimeout(r, 100))
+    let isWaitDone = false
+    const wait = result.wait.then(() => isWaitDone = true)
+    result.stop({ graceful: true })
+    assert.strictEqual((await active).toString(), `console.log(123);\n`)
+    await wait
+    assert.strictEqual(isWaitDone, true)
+
+    // New connections are refused once the server has stopped
+    try {
+      await fetch(result.host, result.port, '/in.js')
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (e.code !== 'ECONNREFUSED') throw e
+    }
+  },
+
+  async serveStopNotGraceful({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `console.log(123)`)
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      throttle: [{ pattern: '/in.js', latency: 500 }],
+    }, {
+      entryPoints: [input],
+      format: 'esm',
+    })
+
+    // Stopping without the graceful flag closes the active request
+    const active = fetch(result.host, result.port, '/in.js')
+    await new Promise(r => setTimeout(r, 100))
+    result.stop()
+    try {
+      await active
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (e.code !== 'ECONNRESET') throw e
+    }
+    await result.wait
+
+    try {
+      result.stop({ graceful: 1 })
+      throw new Error('Expected an error')
+    } catch (e) {
+      assert.strictEqual(e.message, `"graceful" must be a boolean`)
+    }
+  },
+
+  async serveThrottle({ esbuild, testDir }) {
+    const slow = path.join(testDir, 'slow.js')
+    const big = path.join(testDir, 'big.js')
+    await writeFileAsync(slow, `console.log(123)`)
+    await writeFileAsync(big, `console.log(${JSON.stringify('x'.repeat(2000))})`)
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      throttle: [
+        { pattern: '/slow.*', latency: 300 },
+        { pattern: '*.js', bytesPerSecond: 4000 },
+      ],
+    }, {
+      entryPoints: [slow, big],
+      format: 'esm',
+    })
+
+    // The first matching rule adds latency before the response starts
+    let start = Date.now()
+    assert.strictEqual((await fetch(result.host, result.port, '/slow.js')).toString(), `console.log(123);\n`)
+    assert(Date.now() - start >= 300)
+
+    // A response with a limited bandwidth is sent in pieces, 10 per second
+    start = Date.now()
+    assert.strictEqual((await fetch(result.host, result.port, '/big.js')).length, 2017)
+    assert(Date.now() - start >= 400)
+
+    result.stop();
+    await result.wait;
+
+    try {
+      await esbuild.serve({ throttle: [{ latency: 300 }] }, { entryPoints: [slow], logLevel: 'silent' })
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (!e.errors || !e.errors[0] || e.errors[0].text !== 'Missing "pattern" in serve() throttle rule') {
+        throw e;
+      }
+    }
+    try {
+      await esbuild.serve({ throttle: [{ pattern: 'slow.js', latency: 300 }] }, { entryPoints: [slow], logLevel: 'silent' })
+      throw new Error('Expected an error')
+    } catch (e) {
+      assert.strictEqual(e.message, 'Invalid throttle pattern: "slow.js" (expected a URL path such as "/assets/*")')
+    }
+  },
+
+  async serveFallbackProxy({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `console.log(123)`)
+
+    // The backend echoes back what it was sent
+    const backend = http.createServer((req, res) => {
+      const chunks = []
+      req.on('data', chunk => chunks.push(chunk))
+      req.on('end', () => {
+        res.writeHead(201, { 'Content-Type': 'application/json' })
+        res.end(JSON.stringify({
+          method: req.method,
+          url: req.url,
+          host: req.headers.host,
+          forwardedHost: req.headers['x-forwarded-host'],
+          body: Buffer.concat(chunks).toString(),
+        }))
+      })
+    })
+    await new Promise(resolve => backend.listen(0, '127.0.0.1', resolve))
+    const backendHost = `127.0.0.1:${backend.address().port}`
+
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+      proxy: `http://${backendHost}`,
+    }, {
+      entryPoints: [input],
+      format: 'esm',
+    })
+    const request = (method, path, body) => new Promise((resolve, reject) => {
+      const req = http.request({ host: result.host, port: result.port, path, method }, res => {
+        const chunks = []
+        res.on('data', chunk => chunks.push(chunk))
+        res.on('end', () => resolve({ status: res.statusCode, body: Buffer.concat(chunks).toString() }))
+      }).on('error', reject)
+      req.end(body)
+    })
+
+    // Output files are still served by esbuild
+    assert.strictEqual((await fetch(result.host, result.port, '/in.js')).toString(), `console.log(123);\n`)
+
+    // Anything else goes to the backend, with the original host name in "X-Forwarded-Host"
+    let res = await request('GET', '/api/items?id=1')
+    assert.strictEqual(res.status, 201)
+    assert.deepStrictEqual(JSON.parse(res.body), {
+      method: 'GET',
+      url: '/api/items?id=1',
+      host: backendHost,
+      forwardedHost: `${result.host}:${result.port}`,
+      body: '',
+    })
+
+    // Non-GET requests go to the backend even if they match an output file
+    res = await request('POST', '/in.js', 'data')
+    assert.strictEqual(res.status, 201)
+    assert.strictEqual(JSON.parse(res.body).method, 'POST')
+    assert.strictEqual(JSON.parse(res.body).body, 'data')
+
+    // A backend that can't be reached is a 502
+    await new Promise(resolve => backend.close(resolve))
+    res = await request('GET', '/api/items')
+    assert.strictEqual(res.status, 502)
+    assert(res.body.startsWith('502 - Bad gateway: '))
+
+    result.stop();
+    await result.wait;
+
+    try {
+      await esbuild.serve({ proxy: 'localhost:3000' }, { entryPoints: [input], logLevel: 'silent' })
+      throw new Error('Expected an error')
+    } catch (e) {
+      assert.strictEqual(e.message, 'Invalid proxy URL: "localhost:3000" (expected an origin such as "http://localhost:3000")')
+    }
+  },
+
+  async serveHealthChecks({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `console.log(123)`)
+
+    let builds = 0
+    const result = await esbuild.serve({
+      host: '127.0.0.1',
+    }, {
+      entryPoints: [input],
+      format: 'esm',
+      plugins: [{ name: 'count', setup(build) { build.onStart(() => { builds++ }) } }],
+      logLevel: 'silent',
+    })
+
+    // The server is alive right away and is ready once the first build is done
+    assert.strictEqual((await fetch(result.host, result.port, '/__esbuild/healthz')).toString(), 'ok')
+    let readiness
+    while (true) {
+      try {
+        readiness = (await fetch(result.host, result.port, '/__esbuild/readyz')).toString()
+        break
+      } catch (e) {
+        assert.strictEqual(e.message, '503 when fetching /__esbuild/readyz: building')
+        await new Promise(r => setTimeout(r, 10))
+      }
+    }
+    assert.strictEqual(readiness, 'ready')
+
+    // Health checks never trigger a build
+    const buildsBefore = builds
+    for (let i = 0; i < 3; i++) {
+      await fetch(result.host, result.port, '/__esbuild/healthz')
+      await fetch(result.host, result.port, '/__esbuild/readyz')
+    }
+    assert.strictEqual(builds, buildsBefore)
+
+    // The server isn't ready after a failed build. Wait for the previous build
+    // result to expire first since it's reused for a little bit.
+    await writeFileAsync(input, `console.log(`)
+    await new Promise(r => setTimeout(r, 500))
+    try {
+      await fetch(result.host, result.port, '/in.js')
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (!e.message.startsWith('503 when fetching /in.js:')) throw e
+    }
+    try {
+      await fetch(result.host, result.port, '/__esbuild/readyz')
+      throw new Error('Expected an error')
+    } catch (e) {
+      assert.strictEqual(e.message, '503 when fetching /__esbuild/readyz: build failed')
+    }
+
+    result.stop();
+    await result.wait;
+  },
 }
 
 async function futureSyntax(esbuild, js, targetBelow, targetAbove) {
@@ -3137,6 +3799,19 @@ let transformTests = {
     result.footer()
   },
 
+  async bannerFooterLinesTransform({ esbuild }) {
+    var { code, map, bannerLine, footerLine } = await esbuild.transform(`let x = 1`, {
+      sourcemap: true,
+      sourcemapBanners: true,
+      banner: '/* banner */',
+      footer: '/* footer 1 */\n/* footer 2 */',
+    })
+    assert.strictEqual(code, `/* banner */\nlet x = 1;\n/* footer 1 */\n/* footer 2 */\n`)
+    assert.strictEqual(bannerLine, 0)
+    assert.strictEqual(footerLine, 2)
+    assert.deepStrictEqual(JSON.parse(map).sources, ['<banner>', '<stdin>', '<footer>'])
+  },
+
   async cssBannerFooterTransform({ esbuild }) {
     var { code } = await esbuild.transform(`
       div { color: red }
@@ -3450,6 +4125,50 @@ let transformTests = {
     assert.strictEqual(code, `fn(), React.createElement("div", null);\n`)
   },
 
+  async preserveComments({ esbuild }) {
+    const { code } = await esbuild.transform(`// deploy: v1\nlet x = 1\n// DEPLOY: v2\nlet y = 2\n// drop me\nlet z = 3`, {
+      minify: true,
+      preserveComments: /^ deploy:/,
+    })
+    assert.strictEqual(code, `// deploy: v1\nlet x=1,y=2,z=3;\n`)
+  },
+
+  async preserveCommentsFlags({ esbuild }) {
+    const { code } = await esbuild.transform(`// deploy: v1\nlet x = 1\n// DEPLOY: v2\nlet y = 2\n/* deploy:\nv3 */\nlet z = 3`, {
+      minify: true,
+      preserveComments: /^ deploy:.v\d *$/isu,
+    })
+    assert.strictEqual(code, `// deploy: v1\nlet x=1;// DEPLOY: v2\nlet y=2;/* deploy:\nv3 */let z=3;\n`)
+
+    try {
+      await esbuild.transform(``, { preserveComments: /deploy/y })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.errors[0].text, 'The regular expression flag "y" is not supported in "preserveComments"')
+    }
+  },
+
+  async recoverSyntaxErrorsDefault({ esbuild }) {
+    try {
+      await esbuild.transform(`let a = ;\nlet b = ;`, {})
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.errors.length, 1)
+      assert.strictEqual(e.errors[0].location.line, 1)
+    }
+  },
+
+  async recoverSyntaxErrorsTrue({ esbuild }) {
+    try {
+      await esbuild.transform(`let a = ;\nlet b = ;`, { recoverSyntaxErrors: true })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.errors.length, 2)
+      assert.strictEqual(e.errors[0].location.line, 1)
+      assert.strictEqual(e.errors[1].location.line, 2)
+    }
+  },
+
   async jsCharsetDefault({ esbuild }) {
     const { code } = await esbuild.transform(`let π = 'π'`, {})
     assert.strictEqual(code, `let \\u03C0 = "\\u03C0";\n`)
@@ -3704,6 +4423,34 @@ let transformTests = {
     assert.strictEqual(code, `var define_process_env_NODE_ENV_default = [1, 2, 3];\nconsole.log(define_process_env_NODE_ENV_default);\n`)
   },
 
+  async supported({ esbuild }) {
+    const { code } = await esbuild.transform(`let f = () => 1`, { supported: { arrow: false } })
+    assert.strictEqual(code, `let f = function() {\n  return 1;\n};\n`)
+
+    // Overrides apply on top of the target in both directions
+    const { code: code2 } = await esbuild.transform(`let a = { ...b }, c = 1n`, { target: 'es2019', supported: { 'object-rest-spread': false, bigint: true } })
+    assert.strictEqual(code2.endsWith(`let a = __spreadValues({}, b), c = 1n;\n`), true)
+
+    // CSS features can be overridden too
+    const { code: code3 } = await esbuild.transform(`a { color: #ff000080 }`, { loader: 'css', supported: { 'hex-rgba': false } })
+    assert.strictEqual(code3, `a {\n  color: rgba(255, 0, 0, 0.502);\n}\n`)
+  },
+
+  async supportedErrors({ esbuild }) {
+    try {
+      await esbuild.transform(``, { supported: { nope: true }, logLevel: 'silent' })
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (!e.errors || !e.errors[0] || e.errors[0].text !== 'Invalid feature name: "nope"') throw e
+    }
+    try {
+      await esbuild.transform(``, { supported: { arrow: 'no' }, logLevel: 'silent' })
+      throw new Error('Expected an error')
+    } catch (e) {
+      if (!e.errors || !e.errors[0] || e.errors[0].text !== 'Expected the value of supported feature "arrow" to be a boolean') throw e
+    }
+  },
+
   async json({ esbuild }) {
     const { code } = await esbuild.transform(`{ "x": "y" }`, { loader: 'json' })
     assert.strictEqual(code, `module.exports = { x: "y" };\n`)
@@ -3965,6 +4712,30 @@ let transformTests = {
     }
   },
 
+  async transformLineEnding({ esbuild }) {
+    assert.strictEqual((await esbuild.transform(`if (x) { y() }`, { lineEnding: 'lf' })).code, `if (x) {\n  y();\n}\n`)
+    assert.strictEqual((await esbuild.transform(`if (x) { y() }`, { lineEnding: 'crlf' })).code, `if (x) {\r\n  y();\r\n}\r\n`)
+    assert.strictEqual((await esbuild.transform(`y{}`, { loader: 'css', lineEnding: 'crlf' })).code, `y {\r\n}\r\n`)
+    const lf = await esbuild.transform(`//!x\ny()`, { legalComments: 'eof', sourcemap: true })
+    const crlf = await esbuild.transform(`//!x\ny()`, { lineEnding: 'crlf', legalComments: 'eof', sourcemap: true })
+    assert.strictEqual(crlf.code, `y();\r\n//!x\r\n`)
+    assert.strictEqual(JSON.parse(crlf.map).mappings, JSON.parse(lf.map).mappings)
+  },
+
+  async transformIndent({ esbuild }) {
+    assert.strictEqual((await esbuild.transform(`if (x) { y() }`, { indent: 4 })).code, `if (x) {\n    y();\n}\n`)
+    assert.strictEqual((await esbuild.transform(`if (x) { y() }`, { indent: 'tab' })).code, `if (x) {\n\ty();\n}\n`)
+    assert.strictEqual((await esbuild.transform(`y(1)`, { indent: 'tab', format: 'iife' })).code, `(() => {\n\ty(1);\n})();\n`)
+    assert.strictEqual((await esbuild.transform(`y{color:red}`, { loader: 'css', indent: 'tab' })).code, `y {\n\tcolor: red;\n}\n`)
+    try {
+      await esbuild.transform(``, { indent: 0 })
+      throw new Error('Expected a transform failure')
+    } catch (e) {
+      if (!e || !e.errors || !e.errors[0] || e.errors[0].text !== 'Invalid indent: "0" (expected a number of spaces from 1 to 8 or "tab")')
+        throw e
+    }
+  },
+
   async tsDecorators({ esbuild }) {
     const { code } = await esbuild.transform(`
       let observed = [];
@@ -962,6 +962,57 @@ let pluginTests = {
     assert.strictEqual(result.outputFiles[3].text, `// virtual-ns:input a/b/c.d.e\nconsole.log("input a/b/c.d.e");\n`)
   },
 
+  async metafileVirtualModules({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `import 'virtual:config'; import 'virtual:generated'`)
+    const result = await esbuild.build({
+      entryPoints: [input],
+      bundle: true,
+      write: false,
+      metafile: true,
+      plugins: [{
+        name: 'resolver',
+        setup(build) {
+          build.onResolve({ filter: /^virtual:/ }, args => {
+            return { path: args.path.slice('virtual:'.length), namespace: 'virtual-ns' }
+          })
+        },
+      }, {
+        name: 'loader',
+        setup(build) {
+          build.onLoad({ filter: /^generated$/, namespace: 'virtual-ns' }, () => {
+            return { contents: `console.log("generated")` }
+          })
+        },
+      }, {
+        name: 'fallback',
+        setup(build) {
+          build.onLoad({ filter: /.*/, namespace: 'virtual-ns' }, () => {
+            return { contents: `console.log("config")` }
+          })
+        },
+      }],
+    })
+    const inputs = result.metafile.inputs
+    const relInput = path.relative(process.cwd(), input).split(path.sep).join('/')
+    assert.strictEqual(inputs[relInput].namespace, void 0)
+    assert.strictEqual(inputs[relInput].plugin, void 0)
+    assert.deepStrictEqual(inputs['virtual-ns:config'], {
+      bytes: 21,
+      imports: [],
+      namespace: 'virtual-ns',
+      specifier: 'virtual:config',
+      plugin: 'fallback',
+    })
+    assert.deepStrictEqual(inputs['virtual-ns:generated'], {
+      bytes: 24,
+      imports: [],
+      namespace: 'virtual-ns',
+      specifier: 'virtual:generated',
+      plugin: 'loader',
+    })
+  },
+
   async entryPointFileNamespace({ esbuild, testDir }) {
     const input = path.join(testDir, 'in.js')
     let worked = false
@@ -2068,6 +2119,180 @@ let pluginTests = {
       throw new Error('Unexpected value for the "esbuild" property')
     }
   },
+
+  async onTransformChained({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.ts')
+    await writeFileAsync(input, `export let x: number = 1`)
+    const seen = []
+    const result = await esbuild.build({
+      entryPoints: [input],
+      write: false,
+      format: 'esm',
+      plugins: [{
+        name: 'first',
+        setup(build) {
+          build.onTransform({ filter: /\.ts$/ }, args => {
+            seen.push([args.loader, args.contents, args.sourceMap])
+            return { contents: args.contents + `\nexport let y = 2`, sourceMap: '{}' }
+          })
+        },
+      }, {
+        name: 'second',
+        setup(build) {
+          build.onTransform({ filter: /\.ts$/ }, args => {
+            seen.push([args.loader, args.contents, args.sourceMap])
+          })
+        },
+      }],
+    })
+    assert.deepStrictEqual(seen, [
+      ['ts', `export let x: number = 1`, undefined],
+      ['ts', `export let x: number = 1\nexport let y = 2`, '{}'],
+    ])
+    assert.strictEqual(result.outputFiles[0].text, `let x = 1;\nlet y = 2;\nexport {\n  x,\n  y\n};\n`)
+  },
+
+  async onTransformError({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `export default 1`)
+    try {
+      await esbuild.build({
+        entryPoints: [input],
+        write: false,
+        logLevel: 'silent',
+        plugins: [{
+          name: 'plugin',
+          setup(build) {
+            build.onTransform({ filter: /.*/ }, () => {
+              throw new Error('some error')
+            })
+          },
+        }],
+      })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.errors.length, 1)
+      assert.strictEqual(e.errors[0].pluginName, 'plugin')
+      assert.strictEqual(e.errors[0].text, 'some error')
+    }
+  },
+
+  async pluginTimeout({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `export default 1`)
+    try {
+      await esbuild.build({
+        entryPoints: [input],
+        write: false,
+        logLevel: 'silent',
+        pluginTimeout: 50,
+        plugins: [{
+          name: 'plugin',
+          setup(build) {
+            build.onLoad({ filter: /.*/ }, () => new Promise(() => { }))
+          },
+        }],
+      })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      assert.strictEqual(e.errors.length, 1)
+      assert.strictEqual(e.errors[0].pluginName, 'plugin')
+      assert.strictEqual(e.errors[0].text, `The "onLoad" callback for ${JSON.stringify(input)} timed out after 50ms`)
+    }
+  },
+
+  async optionalPluginFailure({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `export default 1`)
+    const result = await esbuild.build({
+      entryPoints: [input],
+      write: false,
+      logLevel: 'silent',
+      format: 'esm',
+      plugins: [{
+        name: 'optional',
+        optional: true,
+        setup(build) {
+          build.onLoad({ filter: /.*/ }, () => {
+            throw new Error('some error')
+          })
+          build.onTransform({ filter: /.*/ }, () => {
+            throw new Error('another error')
+          })
+        },
+      }],
+    })
+    assert.strictEqual(result.errors.length, 0)
+    assert.strictEqual(result.warnings.length, 2)
+    assert.strictEqual(result.warnings[0].pluginName, 'optional')
+    assert.strictEqual(result.warnings[0].text, 'some error')
+    assert.strictEqual(result.warnings[1].text, 'another error')
+    assert.strictEqual(result.warnings[1].notes[result.warnings[1].notes.length - 1].text,
+      'The plugin "optional" is optional, so this error does not fail the build')
+    assert.strictEqual(result.outputFiles[0].text, `var in_default = 1;\nexport {\n  in_default as default\n};\n`)
+  },
+
+  async onSourceMapChained({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const outfile = path.join(testDir, 'out.js')
+    await writeFileAsync(input, `console.log(1)`)
+    const seen = []
+    const result = await esbuild.build({
+      entryPoints: [input],
+      outfile,
+      sourcemap: true,
+      write: false,
+      plugins: [{
+        name: 'first',
+        setup(build) {
+          build.onSourceMap({ filter: /\.js$/ }, args => {
+            seen.push([args.path, args.sourceMapPath, args.sourceMap.sources])
+            return { sourceMap: { ...args.sourceMap, sources: ['webpack:///in.js'], x_vendor: 123 } }
+          })
+        },
+      }, {
+        name: 'second',
+        setup(build) {
+          build.onSourceMap({ filter: /\.js$/ }, args => {
+            seen.push([args.path, args.sourceMapPath, args.sourceMap.sources])
+          })
+        },
+      }],
+    })
+    assert.deepStrictEqual(seen, [
+      [outfile, outfile + '.map', ['in.js']],
+      [outfile, outfile + '.map', ['webpack:///in.js']],
+    ])
+    assert.strictEqual(result.outputFiles.length, 2)
+    const map = JSON.parse(result.outputFiles[0].text)
+    assert.deepStrictEqual(map.sources, ['webpack:///in.js'])
+    assert.strictEqual(map.x_vendor, 123)
+    assert.strictEqual(result.outputFiles[1].text, `console.log(1);\n//# sourceMappingURL=out.js.map\n`)
+  },
+
+  async onSourceMapInline({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    await writeFileAsync(input, `console.log(1)`)
+    const result = await esbuild.build({
+      entryPoints: [input],
+      outfile: path.join(testDir, 'out.js'),
+      sourcemap: 'inline',
+      write: false,
+      plugins: [{
+        name: 'plugin',
+        setup(build) {
+          build.onSourceMap({ filter: /.*/ }, args => {
+            assert.strictEqual(args.sourceMapPath, '')
+            return { sourceMap: { ...args.sourceMap, sourcesContent: undefined } }
+          })
+        },
+      }],
+    })
+    const base64 = result.outputFiles[0].text.split('base64,')[1]
+    const map = JSON.parse(Buffer.from(base64, 'base64').toString())
+    assert.strictEqual(map.sourcesContent, undefined)
+    assert.deepStrictEqual(map.sources, ['in.js'])
+  },
 }
 
 // These tests have to run synchronously
@@ -2445,6 +2670,38 @@ let syncTests = {
     result.rebuild.dispose()
   },
 
+  async onEndCallbackRunsAfterWrite({ esbuild, testDir }) {
+    const input = path.join(testDir, 'in.js')
+    const outfile = path.join(testDir, 'out.js')
+    await writeFileAsync(input, `console.log(1)`)
+
+    // Unlike in Go, "onEnd" callbacks in JavaScript run after the output files
+    // have been written, so changing the output files doesn't change the files
+    // on disk and adding an error doesn't stop them from being written
+    let contentsOnDisk
+    try {
+      await esbuild.build({
+        entryPoints: [input],
+        outfile,
+        logLevel: 'silent',
+        plugins: [{
+          name: 'some-plugin',
+          setup(build) {
+            build.onEnd(async result => {
+              contentsOnDisk = await readFileAsync(outfile, 'utf8')
+              result.errors.push({ text: 'test failure' })
+            })
+          },
+        }],
+      })
+      throw new Error('Expected an error to be thrown')
+    } catch (e) {
+      if (!e.errors || !e.errors[0] || e.errors[0].text !== 'test failure') throw e
+    }
+    assert.strictEqual(contentsOnDisk, `console.log(1);\n`)
+    assert.strictEqual(await readFileAsync(outfile, 'utf8'), `console.log(1);\n`)
+  },
+
   async onStartOnEndWatchMode({ esbuild, testDir }) {
     const srcDir = path.join(testDir, 'src')
     const outfile = path.join(testDir, 'out.js')